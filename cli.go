@@ -0,0 +1,1294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/audit"
+	"github.com/crazywolf132/repo-ranger/pkg/budget"
+	"github.com/crazywolf132/repo-ranger/pkg/config"
+	"github.com/crazywolf132/repo-ranger/pkg/dashboard"
+	"github.com/crazywolf132/repo-ranger/pkg/digest"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/history"
+	"github.com/crazywolf132/repo-ranger/pkg/locale"
+	"github.com/crazywolf132/repo-ranger/pkg/mcp"
+	"github.com/crazywolf132/repo-ranger/pkg/queue"
+	"github.com/crazywolf132/repo-ranger/pkg/runner"
+	"github.com/crazywolf132/repo-ranger/pkg/server"
+	"github.com/crazywolf132/repo-ranger/pkg/tenant"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	"github.com/crazywolf132/repo-ranger/pkg/workload"
+	log "github.com/sirupsen/logrus"
+)
+
+const usage = `Usage: repo-ranger [flags]
+       repo-ranger <command> [args]
+
+Without a command, repo-ranger reviews the configured diff using the INPUT_*
+environment variables, as it does when run as a GitHub Action.
+
+Flags:
+  --diff-file <path>    Review a unified diff read from path ("-" for stdin)
+                        instead of running the diff command
+  --diff-from-pull-request
+                        Fetch the pull request's diff from the GitHub API
+                        instead of running the diff command
+  --format <format>     Print the results to stdout: markdown, diagnostics
+                        (file:line:col: severity: message), or
+                        diagnostics-json (VS Code problem-matcher fields)
+
+Commands:
+  audit verify <path>       Verify the hash chain of an audit log
+  review [flags]            Review several open pull requests in one run, e.g.
+                            to catch up after the bot was disabled (see
+                            review --help)
+  config validate [path]    Check the INPUT_* environment and the config file
+                            (default: $INPUT_CONFIG_FILE or .repo-ranger.yml)
+  publish <dir>             Post a review bundle written by an unprivileged run,
+                            e.g. from a workflow_run workflow for forked PRs
+  serve [flags]             Receive GitHub webhooks and review pull requests
+                            from a durable queue (see serve --help)
+  release-notes [flags]     Write release notes from the pull requests merged
+                            between two refs (see release-notes --help)
+  nudge [flags]             Remind stale pull requests with a summary
+                            re-review against their base (see nudge --help)
+  digest [flags]            Email a summary of the reviews recorded in the
+                            history (see digest --help)
+  dashboard [flags]         Render the history as a static HTML dashboard,
+                            e.g. for GitHub Pages (see dashboard --help)
+  workload [flags]          Report how AI pre-review relates to human review
+                            latency across an organization (see workload --help)
+  mcp                       Serve review-diff, review-files, and explain-change
+                            as Model Context Protocol tools over stdio
+`
+
+// cliFlags holds flags accepted when running a review.
+type cliFlags struct {
+	DiffFile string
+	Format   string
+	// DiffFromPullRequest fetches the pull request's diff from the GitHub
+	// API instead of running the diff command.
+	DiffFromPullRequest bool
+	// PublishOnly builds an orchestrator that only posts results, so no
+	// model settings are required.
+	PublishOnly bool
+}
+
+func parseFlags(args []string) (cliFlags, error) {
+	var flags cliFlags
+	fs := flag.NewFlagSet("repo-ranger", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	fs.StringVar(&flags.DiffFile, "diff-file", "", "")
+	fs.StringVar(&flags.Format, "format", "", "")
+	fs.BoolVar(&flags.DiffFromPullRequest, "diff-from-pull-request", false, "")
+	if err := fs.Parse(args); err != nil {
+		return flags, err
+	}
+	if flags.Format != "" && !contains(runner.OutputFormats, flags.Format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q; use %s\n", flags.Format, strings.Join(runner.OutputFormats, ", "))
+		return flags, fmt.Errorf("unknown format %q", flags.Format)
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected argument %q\n\n%s", fs.Arg(0), usage)
+		return flags, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return flags, nil
+}
+
+// runCommand dispatches CLI subcommands and returns the process exit code.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "audit":
+		return runAuditCommand(args[1:])
+	case "config":
+		return runConfigCommand(args[1:])
+	case "mcp":
+		return runMCPCommand(args[1:])
+	case "publish":
+		return runPublishCommand(args[1:])
+	case "serve":
+		return runServeCommand(args[1:])
+	case "review":
+		return runReviewCommand(args[1:])
+	case "release-notes":
+		return runReleaseNotesCommand(args[1:])
+	case "nudge":
+		return runNudgeCommand(args[1:])
+	case "digest":
+		return runDigestCommand(args[1:])
+	case "dashboard":
+		return runDashboardCommand(args[1:])
+	case "workload":
+		return runWorkloadCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+	return 2
+}
+
+func runAuditCommand(args []string) int {
+	if len(args) != 2 || args[0] != "verify" {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	n, err := audit.Verify(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit log verification failed after %d valid entries: %v\n", n, err)
+		return 1
+	}
+	fmt.Printf("audit log verified: %d entries intact\n", n)
+	return 0
+}
+
+func runConfigCommand(args []string) int {
+	if len(args) < 1 || len(args) > 2 || args[0] != "validate" {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	path := os.Getenv("INPUT_CONFIG_FILE")
+	if len(args) == 2 {
+		path = args[1]
+	}
+	if path == "" {
+		path = config.DefaultPath
+	}
+
+	problems := validateConfiguration(path, true)
+	errors := 0
+	for _, p := range problems {
+		fmt.Println(p)
+		if !p.Warning {
+			errors++
+		}
+	}
+	fmt.Printf("%d errors, %d warnings\n", errors, len(problems)-errors)
+	if errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runMCPCommand(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	// Stdout carries the protocol, so logs and anything else the review
+	// pipeline prints go to stderr instead.
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	log.SetOutput(os.Stderr)
+
+	orchestrator, cleanup := newOrchestrator(cliFlags{})
+	defer cleanup()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := mcp.NewServer(orchestrator).Serve(ctx, os.Stdin, stdout); err != nil && err != context.Canceled {
+		log.WithError(err).Error("MCP server failed")
+		return 1
+	}
+	return 0
+}
+
+func runPublishCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	orchestrator, cleanup := newOrchestrator(cliFlags{PublishOnly: true})
+	defer cleanup()
+
+	if err := orchestrator.PublishBundle(context.Background(), args[0]); err != nil {
+		log.WithError(err).Error("Failed to publish review bundle")
+		return 1
+	}
+	return 0
+}
+
+const serveUsage = `Usage: repo-ranger serve [flags]
+
+Receives GitHub webhooks on /webhook and reviews pull requests from a
+durable queue, using the INPUT_* environment for review settings. Each
+review runs in its own process. GITHUB_WEBHOOK_SECRET must hold the
+webhook's secret, which deliveries are verified against; set
+REPO_RANGER_ADMIN_TOKEN to enable the /admin endpoints.
+
+Flags:
+  --addr <addr>          Address to listen on (default ":8080")
+  --queue-dir <path>     Directory holding the queue (default "repo-ranger-queue")
+  --workers <n>          Reviews to run concurrently (default 2)
+  --max-attempts <n>     Attempts before a review is dead-lettered (default 3)
+  --pprof-addr <addr>    Serve /debug/pprof on this address, e.g. localhost:6060
+  --monthly-budget <usd> Cap each repository's review spend per calendar
+                         month (default 0, no cap)
+  --tenants-dir <path>   Serve only the organizations configured by the
+                         <name>.yml files in this directory
+  --history-dir <path>   Directory recording each review, for digests
+                         (default "<queue-dir>/history")
+  --insecure-skip-signature
+                         Accept unsigned deliveries when GITHUB_WEBHOOK_SECRET
+                         is unset; only for local testing
+
+Endpoints:
+  /healthz               Liveness: the process is up
+  /readyz                Readiness: the queue, model API, and GitHub API are usable
+`
+
+func runServeCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, serveUsage) }
+	addr := fs.String("addr", ":8080", "")
+	queueDir := fs.String("queue-dir", "repo-ranger-queue", "")
+	workers := fs.Int("workers", 2, "")
+	maxAttempts := fs.Int("max-attempts", 3, "")
+	pprofAddr := fs.String("pprof-addr", "", "")
+	tenantsDir := fs.String("tenants-dir", "", "")
+	monthlyBudget := fs.Float64("monthly-budget", 0, "")
+	historyDir := fs.String("history-dir", "", "")
+	insecure := fs.Bool("insecure-skip-signature", false, "")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprint(os.Stderr, serveUsage)
+		return 2
+	}
+	secret := os.Getenv("GITHUB_WEBHOOK_SECRET")
+	if secret == "" && !*insecure {
+		log.Error("GITHUB_WEBHOOK_SECRET is not set; set it to the webhook's secret, or pass --insecure-skip-signature to accept unverified deliveries")
+		return 2
+	}
+
+	// Check the configuration once up front; every review builds its own
+	// orchestrator from it.
+	_, cleanup := newOrchestrator(cliFlags{DiffFromPullRequest: true})
+	cleanup()
+
+	budgets, err := budget.Open(filepath.Join(*queueDir, "budgets"))
+	if err != nil {
+		log.WithError(err).Error("Failed to open budget ledger")
+		return 1
+	}
+	if *historyDir == "" {
+		*historyDir = filepath.Join(*queueDir, "history")
+	}
+	reviewer := &serveReviewer{budgets: budgets, monthlyBudget: *monthlyBudget, historyDir: *historyDir,
+		deferredDir: filepath.Join(*queueDir, "deferred")}
+	if *tenantsDir != "" {
+		tenants, err := tenant.LoadDir(*tenantsDir)
+		if err != nil {
+			log.WithError(err).Error("Failed to load tenants")
+			return 1
+		}
+		log.WithField("tenants", tenants.Len()).Info("Loaded tenant configuration")
+		reviewer.tenants = tenants
+	}
+
+	policy := queue.DefaultRetryPolicy
+	policy.Attempts = *maxAttempts
+	q, err := queue.Open(*queueDir, queue.WithRetryPolicy(policy))
+	if err != nil {
+		log.WithError(err).Error("Failed to open review queue")
+		return 1
+	}
+	reviewer.queue = q
+
+	if secret == "" {
+		log.Warn("GITHUB_WEBHOOK_SECRET is not set; webhook deliveries are not verified")
+	}
+	srv := server.New(q, reviewer.review,
+		server.WithAdmission(reviewer.admit),
+		server.WithWebhookSecret(secret),
+		server.WithAdminToken(os.Getenv("REPO_RANGER_ADMIN_TOKEN")),
+		server.WithWorkers(*workers),
+		server.WithPprof(*pprofAddr),
+		// Any response from the model API means it is reachable; probing
+		// with a real completion would cost tokens.
+		server.WithReadinessCheck("llm", server.HTTPCheck(outboundClient(), "GET", os.Getenv("INPUT_API_URL"), nil,
+			func(status int) bool { return status < 500 })),
+		server.WithReadinessCheck("github", server.HTTPCheck(outboundClient(), "GET", "https://api.github.com/rate_limit",
+			http.Header{"Authorization": {"token " + os.Getenv("INPUT_GITHUB_TOKEN")}},
+			func(status int) bool { return status == http.StatusOK })),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := srv.ListenAndServe(ctx, *addr); err != nil {
+		log.WithError(err).Error("Server failed")
+		return 1
+	}
+	return 0
+}
+
+const reviewUsage = `Usage: repo-ranger review --prs <numbers> | --query <search> [flags]
+
+Reviews a set of open pull requests one after another in one run, with
+the INPUT_* configuration of a single review, sharing its clients and
+caches, then prints the cost of each review and the total. Each pull
+request's diff is fetched from the GitHub API.
+
+Flags:
+  --repo <owner/name>    Repository of the pull requests
+                         (default $GITHUB_REPOSITORY)
+  --prs <numbers>        Comma-separated pull request numbers, e.g. 101,102
+  --query <search>       GitHub search qualifiers selecting pull requests,
+                         e.g. "is:open label:needs-review"
+`
+
+func runReviewCommand(args []string) int {
+	fs := flag.NewFlagSet("review", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, reviewUsage) }
+	repo := fs.String("repo", os.Getenv("GITHUB_REPOSITORY"), "")
+	prs := fs.String("prs", "", "")
+	query := fs.String("query", "", "")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || *repo == "" || (*prs == "") == (*query == "") {
+		fmt.Fprint(os.Stderr, reviewUsage)
+		return 2
+	}
+
+	var numbers []int
+	for _, field := range strings.Split(*prs, ",") {
+		if field = strings.TrimPrefix(strings.TrimSpace(field), "#"); field == "" {
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid pull request number %q\n", field)
+			return 2
+		}
+		numbers = append(numbers, n)
+	}
+
+	orchestrator, cleanup := newOrchestrator(cliFlags{DiffFromPullRequest: true})
+	defer cleanup()
+	// Keep stdout for the cost report.
+	log.SetOutput(os.Stderr)
+	client := newGitHubClient(os.Getenv("INPUT_GITHUB_TOKEN"))
+	if *query != "" {
+		var err error
+		if numbers, err = client.SearchPullRequests(*repo, *query); err != nil {
+			log.WithError(err).Error("Failed to search pull requests")
+			return 1
+		}
+	}
+	if len(numbers) == 0 {
+		log.Info("No pull requests to review")
+		return 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	type result struct {
+		number   int
+		outcome  string
+		findings int
+		cost     float64
+	}
+	var results []result
+	failed := false
+	for _, number := range numbers {
+		if ctx.Err() != nil {
+			break
+		}
+		entry := log.WithFields(log.Fields{"repo": *repo, "pr": number})
+		res := result{number: number}
+		run, err := reviewPullRequest(ctx, orchestrator, client, *repo, number)
+		switch {
+		case errors.Is(err, errNotOpen):
+			entry.Warn("Pull request is not open; skipping")
+			res.outcome = "not open"
+		case err != nil:
+			entry.WithError(err).Error("Review failed")
+			res.outcome = "failed"
+			failed = true
+		case !run.Reviewed():
+			res.outcome = "skipped"
+		default:
+			res.outcome = "reviewed"
+		}
+		if run != nil {
+			res.findings, res.cost = run.Findings(), run.Cost()
+		}
+		results = append(results, res)
+	}
+
+	total := 0.0
+	fmt.Printf("%-8s %-10s %8s %10s\n", "PR", "OUTCOME", "FINDINGS", "COST")
+	for _, res := range results {
+		total += res.cost
+		fmt.Printf("#%-7d %-10s %8d %10s\n", res.number, res.outcome, res.findings, fmt.Sprintf("$%.4f", res.cost))
+	}
+	fmt.Printf("%-8s %-10s %8s %10s\n", "total", fmt.Sprintf("%d PRs", len(results)), "", fmt.Sprintf("$%.4f", total))
+	if failed || ctx.Err() != nil {
+		return 1
+	}
+	return 0
+}
+
+// errNotOpen reports that a pull request of a batch is closed or merged.
+var errNotOpen = errors.New("pull request is not open")
+
+// reviewPullRequest reviews one pull request of a batch as a pull_request
+// event for it.
+func reviewPullRequest(ctx context.Context, base *runner.Orchestrator, client github.Client, repo string, number int) (*runner.Orchestrator, error) {
+	event, err := client.GetPullRequest(repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pull request: %w", err)
+	}
+	if event.PullRequest.State != "open" {
+		return nil, errNotOpen
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write event payload: %w", err)
+	}
+	f, err := os.CreateTemp("", "repo-ranger-event-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write event payload: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(payload); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write event payload: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write event payload: %w", err)
+	}
+
+	run := base.ForEvent("pull_request", f.Name())
+	return run, run.Run(ctx)
+}
+
+const releaseNotesUsage = `Usage: repo-ranger release-notes --from <ref> [flags]
+
+Writes user-facing release notes in Markdown for the pull requests merged
+between two git refs of the current checkout, found from their merge and
+squash commits. Each pull request is summarized from its diff, then the
+summaries are rewritten for users under Breaking Changes, Features, Bug
+Fixes, Performance, Documentation, and Other Changes, chosen by labels or
+conventional-commit titles. Pull requests labelled skip-changelog or
+no-release-notes are left out. Uses the INPUT_* model configuration.
+
+Flags:
+  --from <ref>           Ref of the previous release, e.g. v1.2.0
+  --to <ref>             Ref of the new release (default HEAD)
+  --repo <owner/name>    Repository of the pull requests
+                         (default $GITHUB_REPOSITORY)
+  --draft-release <tag>  Create a draft GitHub release of tag with the notes
+                         instead of printing them
+  --name <name>          Name of the draft release (default the tag)
+`
+
+func runReleaseNotesCommand(args []string) int {
+	fs := flag.NewFlagSet("release-notes", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, releaseNotesUsage) }
+	from := fs.String("from", "", "")
+	to := fs.String("to", "HEAD", "")
+	repo := fs.String("repo", os.Getenv("GITHUB_REPOSITORY"), "")
+	tag := fs.String("draft-release", "", "")
+	name := fs.String("name", "", "")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || *from == "" || *repo == "" {
+		fmt.Fprint(os.Stderr, releaseNotesUsage)
+		return 2
+	}
+
+	orchestrator, cleanup := newOrchestrator(cliFlags{})
+	defer cleanup()
+	// Keep stdout for the notes.
+	log.SetOutput(os.Stderr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	notes, err := orchestrator.ReleaseNotes(ctx, *repo, *from, *to)
+	if err != nil {
+		log.WithError(err).Error("Failed to write release notes")
+		return 1
+	}
+	if *tag == "" {
+		fmt.Print(notes)
+		return 0
+	}
+	if *name == "" {
+		*name = *tag
+	}
+	client := newGitHubClient(os.Getenv("INPUT_GITHUB_TOKEN"))
+	url, err := client.CreateDraftRelease(*repo, *tag, *name, notes)
+	if err != nil {
+		log.WithError(err).Error("Failed to create draft release")
+		return 1
+	}
+	log.WithField("url", url).Info("Draft release created")
+	return 0
+}
+
+const nudgeUsage = `Usage: repo-ranger nudge [flags]
+
+Reminds open pull requests that have gone without updates for a number of
+days, for running from a schedule. Each stale pull request gets a gentle
+comment saying whether it conflicts with its base, which of its files the
+base has changed since, and a summary-only re-review against the current
+base. The repository's .repo-ranger.yml can turn nudges off, change the
+number of days, include drafts, or replace the greeting under its nudge
+key. Nudging a pull request updates it, so it is nudged again only after
+another stale period. Uses the INPUT_* model configuration.
+
+Flags:
+  --repo <owner/name>    Repository of the pull requests
+                         (default $GITHUB_REPOSITORY)
+  --days <n>             Days without updates that make a pull request
+                         stale, unless the repository sets nudge.days
+                         (default 14)
+  --dry-run              Print the nudges instead of posting them
+`
+
+func runNudgeCommand(args []string) int {
+	fs := flag.NewFlagSet("nudge", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, nudgeUsage) }
+	repo := fs.String("repo", os.Getenv("GITHUB_REPOSITORY"), "")
+	days := fs.Int("days", 14, "")
+	dryRun := fs.Bool("dry-run", false, "")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || *repo == "" || *days <= 0 {
+		fmt.Fprint(os.Stderr, nudgeUsage)
+		return 2
+	}
+
+	client := newGitHubClient(os.Getenv("INPUT_GITHUB_TOKEN"))
+	var settings config.Nudge
+	data, err := client.FileContents(*repo, config.DefaultPath)
+	switch {
+	case errors.Is(err, github.ErrNotFound):
+	case err != nil:
+		log.WithError(err).Error("Failed to fetch repository configuration")
+		return 1
+	default:
+		cfg, err := config.Parse(config.DefaultPath, data)
+		if err != nil {
+			log.WithError(err).Error("Invalid repository configuration")
+			return 1
+		}
+		settings = cfg.Nudge
+	}
+	if settings.Disabled {
+		log.WithField("repo", *repo).Info("Nudges are disabled for this repository")
+		return 0
+	}
+	if settings.Days > 0 {
+		*days = settings.Days
+	}
+
+	orchestrator, cleanup := newOrchestrator(cliFlags{DiffFromPullRequest: true})
+	defer cleanup()
+	// Keep stdout for the results, or the nudges of a dry run.
+	log.SetOutput(os.Stderr)
+
+	now := time.Now().UTC()
+	cutoff := now.AddDate(0, 0, -*days)
+	numbers, err := client.SearchPullRequests(*repo, "is:open updated:<"+cutoff.Format("2006-01-02"))
+	if err != nil {
+		log.WithError(err).Error("Failed to search pull requests")
+		return 1
+	}
+	if len(numbers) == 0 {
+		log.Info("No stale pull requests to nudge")
+		return 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	type result struct {
+		number  int
+		idle    int
+		outcome string
+	}
+	var results []result
+	failed := false
+	for _, number := range numbers {
+		if ctx.Err() != nil {
+			break
+		}
+		entry := log.WithFields(log.Fields{"repo": *repo, "pr": number})
+		res := result{number: number}
+		event, err := client.GetPullRequest(*repo, number)
+		if err != nil {
+			entry.WithError(err).Error("Failed to fetch pull request")
+			res.outcome = "failed"
+			failed = true
+			results = append(results, res)
+			continue
+		}
+		idle := now.Sub(event.PullRequest.UpdatedAt)
+		res.idle = int(idle.Hours() / 24)
+		switch {
+		case event.PullRequest.State != "open":
+			res.outcome = "not open"
+		case event.PullRequest.Draft && !settings.Drafts:
+			res.outcome = "draft"
+		case event.PullRequest.UpdatedAt.After(cutoff):
+			// Search results lag behind updates.
+			res.outcome = "updated"
+		default:
+			body, err := orchestrator.Nudge(ctx, event, idle, settings.Message)
+			if err == nil {
+				if *dryRun {
+					fmt.Printf("## #%d\n\n%s\n\n", number, body)
+				} else {
+					err = client.PostPRComment(event, body)
+				}
+			}
+			if err != nil {
+				entry.WithError(err).Error("Nudge failed")
+				res.outcome = "failed"
+				failed = true
+			} else {
+				res.outcome = "nudged"
+			}
+		}
+		results = append(results, res)
+	}
+
+	fmt.Printf("%-8s %6s %-10s\n", "PR", "IDLE", "OUTCOME")
+	for _, res := range results {
+		fmt.Printf("#%-7d %6s %-10s\n", res.number, fmt.Sprintf("%dd", res.idle), res.outcome)
+	}
+	if failed || ctx.Err() != nil {
+		return 1
+	}
+	return 0
+}
+
+const digestUsage = `Usage: repo-ranger digest [flags]
+
+Summarizes the reviews recorded in the history of the last day or week,
+up to midnight in INPUT_TIMEZONE (default UTC), and emails it: reviews performed, findings by
+severity, the most severe findings, the share of findings addressed, and
+spend. Run it from cron. The SMTP server is configured by SMTP_HOST,
+SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD, and DIGEST_FROM.
+Numbers and dates are written for INPUT_LOCALE (default en-US).
+
+Flags:
+  --history-dir <path>   History written by serve or INPUT_HISTORY_DIR
+                         (default "repo-ranger-queue/history")
+  --period <period>      daily or weekly (default "weekly")
+  --teams <path>         YAML file of teams, each emailed the activity of
+                         its repositories: teams: [{name, emails, repos}]
+  --to <emails>          Comma-separated recipients of the activity of
+                         every repository
+  --dry-run              Print the digests instead of emailing them
+`
+
+// reportLocale reads INPUT_LOCALE and INPUT_TIMEZONE, which set how reports
+// write numbers and dates and where their days begin.
+func reportLocale() (locale.Locale, error) {
+	return locale.Parse(os.Getenv("INPUT_LOCALE"), os.Getenv("INPUT_TIMEZONE"))
+}
+
+func runDigestCommand(args []string) int {
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, digestUsage) }
+	historyDir := fs.String("history-dir", filepath.Join("repo-ranger-queue", "history"), "")
+	period := fs.String("period", "weekly", "")
+	teamsFile := fs.String("teams", "", "")
+	to := fs.String("to", "", "")
+	dryRun := fs.Bool("dry-run", false, "")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprint(os.Stderr, digestUsage)
+		return 2
+	}
+
+	var days int
+	switch *period {
+	case "daily":
+		days = 1
+	case "weekly":
+		days = 7
+	default:
+		fmt.Fprintf(os.Stderr, "unknown period %q; use daily or weekly\n", *period)
+		return 2
+	}
+	loc, err := reportLocale()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	var teams []digest.Team
+	if *teamsFile != "" {
+		var err error
+		if teams, err = digest.LoadTeams(*teamsFile); err != nil {
+			log.WithError(err).Error("Failed to load teams")
+			return 1
+		}
+	}
+	if *to != "" {
+		teams = append(teams, digest.Team{Emails: strings.Split(*to, ",")})
+	}
+	if len(teams) == 0 {
+		fmt.Fprint(os.Stderr, "no recipients; set --to or --teams\n\n"+digestUsage)
+		return 2
+	}
+
+	var mailer digest.Mailer
+	if !*dryRun {
+		var err error
+		if mailer, err = digest.MailerFromEnv(); err != nil {
+			log.WithError(err).Error("Failed to configure email")
+			return 1
+		}
+	}
+
+	store, err := history.Open(*historyDir)
+	if err != nil {
+		log.WithError(err).Error("Failed to open review history")
+		return 1
+	}
+	end := loc.Midnight(time.Now())
+	start := end.AddDate(0, 0, -days)
+	records, err := store.Between(start, end)
+	if err != nil {
+		log.WithError(err).Error("Failed to read review history")
+		return 1
+	}
+
+	failed := false
+	for _, team := range teams {
+		d := digest.Build(records, start, end, team.Repos)
+		d.Title, d.Locale = team.Name, loc
+		if *dryRun {
+			fmt.Printf("To: %s\n%s\n", strings.Join(team.Emails, ", "), d.Render())
+			continue
+		}
+		entry := log.WithField("to", strings.Join(team.Emails, ", "))
+		if err := mailer.Send(team.Emails, d); err != nil {
+			entry.WithError(err).Error("Failed to email digest")
+			failed = true
+			continue
+		}
+		entry.Info("Digest emailed")
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+const dashboardUsage = `Usage: repo-ranger dashboard [flags]
+
+Renders the reviews recorded in the history as a static HTML page, with no
+scripts or external assets: findings over time by severity, spend per
+repository, and the files drawing the most findings. Publish the output
+directory to GitHub Pages or any static host.
+
+Flags:
+  --history-dir <path>   History written by serve or INPUT_HISTORY_DIR
+                         (default "repo-ranger-queue/history")
+  --days <n>             Days of history to show, up to today (default 90)
+  --out <dir>            Directory to write index.html to (default "dashboard")
+
+Days begin at midnight in INPUT_TIMEZONE (default UTC), and numbers and
+dates are written for INPUT_LOCALE (default en-US).
+`
+
+func runDashboardCommand(args []string) int {
+	fs := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, dashboardUsage) }
+	historyDir := fs.String("history-dir", filepath.Join("repo-ranger-queue", "history"), "")
+	days := fs.Int("days", 90, "")
+	out := fs.String("out", "dashboard", "")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || *days <= 0 {
+		fmt.Fprint(os.Stderr, dashboardUsage)
+		return 2
+	}
+	loc, err := reportLocale()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	store, err := history.Open(*historyDir)
+	if err != nil {
+		log.WithError(err).Error("Failed to open review history")
+		return 1
+	}
+	end := loc.Midnight(time.Now()).AddDate(0, 0, 1)
+	start := end.AddDate(0, 0, -*days)
+	records, err := store.Between(start, end)
+	if err != nil {
+		log.WithError(err).Error("Failed to read review history")
+		return 1
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.WithError(err).Error("Failed to create the output directory")
+		return 1
+	}
+	path := filepath.Join(*out, "index.html")
+	f, err := os.Create(path)
+	if err != nil {
+		log.WithError(err).Error("Failed to write the dashboard")
+		return 1
+	}
+	board := dashboard.Build(records, start, end)
+	board.Locale = loc
+	if err := board.Render(f); err != nil {
+		f.Close()
+		log.WithError(err).Error("Failed to write the dashboard")
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		log.WithError(err).Error("Failed to write the dashboard")
+		return 1
+	}
+	log.WithFields(log.Fields{"path": path, "records": len(records)}).Info("Dashboard written")
+	return 0
+}
+
+const workloadUsage = `Usage: repo-ranger workload --org <org> [flags]
+
+Reports on the pull requests an organization merged in the last days, up
+to midnight in INPUT_TIMEZONE (default UTC), comparing those Repo Ranger
+reviewed with the rest: how long each waited for its first human review
+and for approval, and how many change requests it drew, overall and by
+repository, with the repositories Repo Ranger does not yet cover. Numbers
+and dates are written for INPUT_LOCALE (default en-US). Run it from cron. Reads the
+history for Repo Ranger's reviews and the GitHub API, with
+INPUT_GITHUB_TOKEN, for human reviews. GitHub search returns at most
+1,000 pull requests.
+
+Flags:
+  --org <org>            Organization whose pull requests to report on
+  --history-dir <path>   History written by serve or INPUT_HISTORY_DIR
+                         (default "repo-ranger-queue/history")
+  --days <n>             Days of merged pull requests to cover (default 30)
+  --out <path>           File to write the Markdown report to (default stdout)
+`
+
+func runWorkloadCommand(args []string) int {
+	fs := flag.NewFlagSet("workload", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, workloadUsage) }
+	org := fs.String("org", "", "")
+	historyDir := fs.String("history-dir", filepath.Join("repo-ranger-queue", "history"), "")
+	days := fs.Int("days", 30, "")
+	out := fs.String("out", "", "")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || *org == "" || *days <= 0 {
+		fmt.Fprint(os.Stderr, workloadUsage)
+		return 2
+	}
+	loc, err := reportLocale()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if *out == "" {
+		// Keep stdout for the report.
+		log.SetOutput(os.Stderr)
+	}
+	store, err := history.Open(*historyDir)
+	if err != nil {
+		log.WithError(err).Error("Failed to open review history")
+		return 1
+	}
+	client := newGitHubClient(os.Getenv("INPUT_GITHUB_TOKEN"))
+	end := loc.Midnight(time.Now())
+	start := end.AddDate(0, 0, -*days)
+	merged, err := client.SearchPullRequestActivity(fmt.Sprintf("org:%s is:merged merged:%s..%s",
+		*org, start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02")))
+	if err != nil {
+		log.WithError(err).Error("Failed to search pull requests")
+		return 1
+	}
+
+	// Pull requests merged in the period may have been reviewed before it.
+	opened := start
+	var prs []workload.PullRequest
+	for _, pr := range merged {
+		var event types.PullRequestEvent
+		event.Repository.FullName = pr.Repo
+		event.PullRequest.Number = pr.Number
+		reviews, err := client.ListReviews(event)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{"repo": pr.Repo, "pr": pr.Number}).Warn("Failed to list reviews; leaving the pull request out")
+			continue
+		}
+		prs = append(prs, workload.Observe(pr, reviews))
+		if pr.CreatedAt.Before(opened) {
+			opened = pr.CreatedAt
+		}
+	}
+	records, err := store.Between(opened, end)
+	if err != nil {
+		log.WithError(err).Error("Failed to read review history")
+		return 1
+	}
+	workload.Correlate(prs, records)
+	report := workload.Build(prs, start, end)
+	report.Title, report.Locale = *org, loc
+
+	if *out == "" {
+		fmt.Print(report.Render())
+		return 0
+	}
+	if err := os.WriteFile(*out, []byte(report.Render()), 0644); err != nil {
+		log.WithError(err).Error("Failed to write the workload report")
+		return 1
+	}
+	log.WithFields(log.Fields{"path": *out, "pullRequests": len(prs)}).Info("Workload report written")
+	return 0
+}
+
+// serveReviewer runs the reviews of serve mode.
+type serveReviewer struct {
+	// tenants, when set, limits the server to the configured accounts and
+	// applies their settings.
+	tenants *tenant.Registry
+	// budgets records each repository's spend; monthlyBudget caps it,
+	// unless the repository's tenant sets its own cap.
+	budgets       *budget.Ledger
+	monthlyBudget float64
+	// budgetRuns holds a *sync.Mutex per repository, so its budgeted runs
+	// go one at a time.
+	budgetRuns sync.Map
+	// historyDir is where reviews record their history, for digests.
+	historyDir string
+	// queue receives the reviews held back by quiet hours, to publish once
+	// they end from their bundles under deferredDir.
+	queue       *queue.Queue
+	deferredDir string
+}
+
+// deferredEvent marks a queued job publishing a review held back by quiet
+// hours; its payload is the delivery that was reviewed.
+const deferredEvent = "repo-ranger.deferred"
+
+// deliveryTarget is the part of a webhook payload identifying the account
+// and repository it concerns.
+type deliveryTarget struct {
+	Repository struct {
+		FullName string `json:"full_name"`
+		Owner    struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+	Installation struct {
+		ID int64 `json:"id"`
+	} `json:"installation"`
+}
+
+// tenantFor finds the tenant serving a delivery.
+func (r *serveReviewer) tenantFor(payload []byte) (tenant.Tenant, deliveryTarget, error) {
+	var target deliveryTarget
+	if err := json.Unmarshal(payload, &target); err != nil {
+		return tenant.Tenant{}, target, fmt.Errorf("invalid payload: %w", err)
+	}
+	t, ok := r.tenants.Lookup(target.Repository.Owner.Login, target.Installation.ID)
+	if !ok {
+		return t, target, fmt.Errorf("account %q is not served here", target.Repository.Owner.Login)
+	}
+	if !t.Allows(target.Repository.FullName) {
+		return t, target, fmt.Errorf("repository %q is not allowed for tenant %q", target.Repository.FullName, t.Name)
+	}
+	return t, target, nil
+}
+
+// admit rejects deliveries no tenant serves.
+func (r *serveReviewer) admit(event string, payload []byte) error {
+	if r.tenants == nil {
+		return nil
+	}
+	_, _, err := r.tenantFor(payload)
+	return err
+}
+
+// review runs a review for a queued webhook delivery in a child process,
+// so each review gets its own environment and tenants never share
+// credentials.
+func (r *serveReviewer) review(ctx context.Context, job queue.Job) error {
+	env := map[string]string{}
+	var target deliveryTarget
+	if err := json.Unmarshal(job.Payload, &target); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	limit := r.monthlyBudget
+	if r.tenants != nil {
+		t, _, err := r.tenantFor(job.Payload)
+		if err != nil {
+			// The configuration changed since the delivery was queued.
+			log.WithError(err).WithField("delivery", job.ID).Warn("Skipping delivery")
+			return nil
+		}
+		var orgInputs map[string]string
+		if t.OrgConfig {
+			orgInputs = r.orgInputs(t, target.Repository.Owner.Login)
+		}
+		env = t.Env(orgInputs)
+		if t.MonthlyBudget > 0 {
+			limit = t.MonthlyBudget
+		}
+	}
+	if job.Event == deferredEvent {
+		return r.publishDeferred(ctx, job, env)
+	}
+
+	repo := target.Repository.FullName
+	if limit > 0 {
+		// Each run is capped by what is left of the budget, so runs on one
+		// repository wait for the last to record its spend; otherwise
+		// concurrent runs would each be given the whole remainder.
+		lock, _ := r.budgetRuns.LoadOrStore(strings.ToLower(repo), &sync.Mutex{})
+		lock.(*sync.Mutex).Lock()
+		defer lock.(*sync.Mutex).Unlock()
+
+		spent, err := r.budgets.Spent(repo, time.Now())
+		if err != nil {
+			return err
+		}
+		remaining := limit - spent
+		if remaining <= 0 {
+			log.WithFields(log.Fields{"repo": repo, "budget": limit}).Warn("Monthly budget exhausted; not reviewing")
+			r.skipForBudget(env, job.Payload, limit)
+			return nil
+		}
+		// The run may spend what is left of the month's budget, or less
+		// if its own cap is lower.
+		if perRun, err := strconv.ParseFloat(lookupEnv(env, "INPUT_MAX_COST_PER_RUN"), 64); err != nil || perRun <= 0 || perRun > remaining {
+			env["INPUT_MAX_COST_PER_RUN"] = strconv.FormatFloat(remaining, 'f', -1, 64)
+		}
+	}
+
+	f, err := os.CreateTemp("", "repo-ranger-event-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write event payload: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(job.Payload); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write event payload: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write event payload: %w", err)
+	}
+	env["GITHUB_EVENT_NAME"] = job.Event
+	env["GITHUB_EVENT_PATH"] = f.Name()
+	if r.historyDir != "" {
+		env["INPUT_HISTORY_DIR"] = r.historyDir
+	}
+	// Reviews finished in quiet hours are held in a bundle and published
+	// from the queue once the hours end.
+	deferredID := job.ID + "-deferred"
+	if lookupEnv(env, "INPUT_QUIET_HOURS") != "" && lookupEnv(env, "INPUT_QUIET_HOURS_MODE") == "" {
+		env["INPUT_QUIET_HOURS_MODE"] = runner.QuietDefer
+	}
+	if strings.EqualFold(lookupEnv(env, "INPUT_QUIET_HOURS_MODE"), runner.QuietDefer) {
+		env["INPUT_REVIEW_BUNDLE"] = filepath.Join(r.deferredDir, deferredID)
+	}
+
+	// The run reports its spend through its outputs.
+	outputs, err := os.CreateTemp("", "repo-ranger-output-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	outputs.Close()
+	defer os.Remove(outputs.Name())
+	env["GITHUB_OUTPUT"] = outputs.Name()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, exe, "--diff-from-pull-request")
+	cmd.Env = mergeEnv(os.Environ(), env)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	// Failed runs may still have spent tokens.
+	if cost, err := strconv.ParseFloat(readOutput(outputs.Name(), "cost"), 64); err == nil && repo != "" {
+		if err := r.budgets.Add(repo, time.Now(), cost); err != nil {
+			log.WithError(err).WithField("repo", repo).Error("Failed to record spend")
+		}
+	}
+	if runErr != nil {
+		return fmt.Errorf("review failed: %w", runErr)
+	}
+	if after := readOutput(outputs.Name(), "post_after"); after != "" {
+		return r.deferPublishing(job, deferredID, after)
+	}
+	if bundle, ok := env["INPUT_REVIEW_BUNDLE"]; ok && strings.HasPrefix(bundle, r.deferredDir) {
+		os.RemoveAll(bundle)
+	}
+	return nil
+}
+
+// deferPublishing queues the publication of a review held back by quiet
+// hours for when they end.
+func (r *serveReviewer) deferPublishing(job queue.Job, id, after string) error {
+	notBefore, err := time.Parse(time.RFC3339, after)
+	if err != nil {
+		return fmt.Errorf("invalid post_after output %q: %w", after, err)
+	}
+	deferred := queue.Job{ID: id, Event: deferredEvent, Payload: job.Payload, NotBefore: notBefore}
+	if err := r.queue.Enqueue(deferred); err != nil {
+		return fmt.Errorf("failed to queue deferred review: %w", err)
+	}
+	log.WithFields(log.Fields{"delivery": job.ID, "after": after}).Info("Review held for quiet hours; queued for publishing")
+	return nil
+}
+
+// publishDeferred publishes a review held back by quiet hours from its
+// bundle, in a child process like the review itself.
+func (r *serveReviewer) publishDeferred(ctx context.Context, job queue.Job, env map[string]string) error {
+	dir := filepath.Join(r.deferredDir, job.ID)
+	// The review created its check runs when it ran.
+	env["INPUT_USE_CHECKS"] = "false"
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, exe, "publish", dir)
+	cmd.Env = mergeEnv(os.Environ(), env)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to publish deferred review: %w", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log.WithError(err).WithField("path", dir).Warn("Failed to remove published review bundle")
+	}
+	return nil
+}
+
+// skipForBudget leaves a neutral check run on a pull request whose
+// repository has spent its monthly budget, when check runs are enabled.
+func (r *serveReviewer) skipForBudget(env map[string]string, payload []byte, limit float64) {
+	if useChecks, _ := strconv.ParseBool(lookupEnv(env, "INPUT_USE_CHECKS")); !useChecks {
+		return
+	}
+	var event types.PullRequestEvent
+	if err := json.Unmarshal(payload, &event); err != nil || event.PullRequest.Head.SHA == "" {
+		return
+	}
+	run := github.CheckRun{
+		Name:       "Repo Ranger",
+		Conclusion: "neutral",
+		Title:      "Review skipped: monthly budget reached",
+		Summary:    fmt.Sprintf("This repository has spent its $%.2f review budget for %s. Reviews resume next month.", limit, time.Now().UTC().Format("January 2006")),
+	}
+	client := newGitHubClient(lookupEnv(env, "INPUT_GITHUB_TOKEN"))
+	if err := client.CreateCheckRun(event, run); err != nil {
+		log.WithError(err).Error("Failed to create GitHub Check Run")
+	}
+}
+
+// lookupEnv returns the overridden value of an environment variable, or
+// the server's own.
+func lookupEnv(overrides map[string]string, name string) string {
+	if v, ok := overrides[name]; ok {
+		return v
+	}
+	return os.Getenv(name)
+}
+
+// readOutput returns the last value of an output a run wrote in the
+// GITHUB_OUTPUT "name<<delimiter" format.
+func readOutput(path, name string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var value string
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		key, delimiter, ok := strings.Cut(lines[i], "<<")
+		if !ok {
+			continue
+		}
+		var body []string
+		for i++; i < len(lines) && lines[i] != delimiter; i++ {
+			body = append(body, lines[i])
+		}
+		if key == name {
+			value = strings.Join(body, "\n")
+		}
+	}
+	return value
+}
+
+// orgInputs reads the review preferences an organization keeps in its
+// .github repository. Problems are logged and the file ignored, so a
+// broken org file never blocks reviews.
+func (r *serveReviewer) orgInputs(t tenant.Tenant, owner string) map[string]string {
+	token := os.Getenv("INPUT_GITHUB_TOKEN")
+	if t.GitHubTokenEnv != "" {
+		token = os.Getenv(t.GitHubTokenEnv)
+	}
+	entry := log.WithFields(log.Fields{"tenant": t.Name, "owner": owner})
+	data, err := newGitHubClient(token).FileContents(owner+"/.github", tenant.OrgConfigPath)
+	if errors.Is(err, github.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		entry.WithError(err).Warn("Failed to read organization configuration")
+		return nil
+	}
+	inputs, ignored, err := tenant.ParseOrgConfig(data)
+	if err != nil {
+		entry.WithError(err).Warn("Ignoring invalid organization configuration")
+		return nil
+	}
+	if len(ignored) > 0 {
+		entry.WithField("inputs", ignored).Warn("Organization configuration sets inputs reserved for the server operator; ignoring them")
+	}
+	return inputs
+}
+
+// mergeEnv returns base with the variables in overrides replaced.
+func mergeEnv(base []string, overrides map[string]string) []string {
+	env := make([]string, 0, len(base)+len(overrides))
+	for _, kv := range base {
+		if _, ok := overrides[strings.SplitN(kv, "=", 2)[0]]; !ok {
+			env = append(env, kv)
+		}
+	}
+	for name, value := range overrides {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		output  string
+		want    string
+	}{
+		{"single value", "cost<<EOF\n0.42\nEOF\n", "cost", "0.42"},
+		{"multi-line value", "review<<ghadelimiter_1\nline one\nline two\nghadelimiter_1\n", "review", "line one\nline two"},
+		{"last value wins", "cost<<A\n1\nA\ncost<<B\n2\nB\n", "cost", "2"},
+		{"other outputs skipped", "review<<A\ncost<<not a header\nA\ncost<<B\n3\nB\n", "cost", "3"},
+		{"missing output", "review<<A\ntext\nA\n", "cost", ""},
+		{"unterminated value", "cost<<A\n5\n", "cost", "5\n"},
+		{"empty file", "", "cost", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "output")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if got := readOutput(path, tt.output); got != tt.want {
+				t.Errorf("readOutput(%q) = %q, want %q", tt.output, got, tt.want)
+			}
+		})
+	}
+	if got := readOutput(filepath.Join(t.TempDir(), "missing"), "cost"); got != "" {
+		t.Errorf("readOutput of a missing file = %q, want empty", got)
+	}
+}
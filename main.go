@@ -3,27 +3,46 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	// Quiet hours name time zones the runtime image has no database for.
+	_ "time/tzdata"
 
+	"github.com/crazywolf132/repo-ranger/pkg/analyzer"
 	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/artifact"
+	"github.com/crazywolf132/repo-ranger/pkg/audit"
+	"github.com/crazywolf132/repo-ranger/pkg/config"
 	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/event"
+	"github.com/crazywolf132/repo-ranger/pkg/featureflag"
 	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/history"
+	"github.com/crazywolf132/repo-ranger/pkg/i18n"
+	"github.com/crazywolf132/repo-ranger/pkg/metrics"
+	"github.com/crazywolf132/repo-ranger/pkg/runner"
+	"github.com/crazywolf132/repo-ranger/pkg/secrets"
+	"github.com/crazywolf132/repo-ranger/pkg/tenant"
 	"github.com/crazywolf132/repo-ranger/pkg/types"
+	"github.com/crazywolf132/repo-ranger/pkg/webhook"
 	log "github.com/sirupsen/logrus"
 )
 
-const (
-	maxChunkSize = 10000 // maximum characters per diff chunk
-)
+// defaultDiffCommand is the diff_command default of action.yml.
+const defaultDiffCommand = "git --no-pager diff HEAD~1 HEAD"
 
 func init() {
 	// Configure logrus
 	log.SetFormatter(&log.JSONFormatter{})
 	log.SetOutput(os.Stdout)
+	// Tag every line with the run and chunk it belongs to.
+	log.AddHook(runner.CorrelationHook{})
 
 	// Set log level based on environment variable, default to info
 	logLevel := os.Getenv("LOG_LEVEL")
@@ -38,30 +57,303 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+	flags, err := parseFlags(os.Args[1:])
+	if err != nil {
+		os.Exit(2)
+	}
+
+	orchestrator, cleanup := newOrchestrator(flags)
+	defer cleanup()
+
+	if err := orchestrator.Run(context.Background()); err != nil {
+		log.WithError(err).Fatal("Review failed")
+	}
+}
+
+var (
+	outboundOnce sync.Once
+	outbound     api.HTTPClient
+)
+
+// outboundClient returns the HTTP client every outbound request goes
+// through. With INPUT_AUDIT_LOG set, it records each request to that
+// tamper-evident log. The log is opened once per process, so the review
+// and the subcommands around it extend one unbroken hash chain.
+func outboundClient() api.HTTPClient {
+	outboundOnce.Do(func() {
+		outbound = &http.Client{}
+		if auditPath := os.Getenv("INPUT_AUDIT_LOG"); auditPath != "" {
+			auditLogger, err := audit.Open(auditPath)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to open audit log")
+			}
+			outbound = auditLogger.Wrap(outbound)
+			log.WithField("path", auditPath).Info("Recording outbound requests to audit log")
+		}
+	})
+	return outbound
+}
+
+// newGitHubClient returns a GitHub client for the subcommands that sends
+// its requests through outboundClient and retries them as configured in
+// INPUT_CONFIG_FILE.
+func newGitHubClient(token string) github.Client {
+	configFile := os.Getenv("INPUT_CONFIG_FILE")
+	if configFile == "" {
+		configFile = config.DefaultPath
+	}
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		log.WithError(err).Warn("Failed to read the retry policy; using the default")
+	}
+	return github.NewClient(token, outboundClient(),
+		github.WithRetryPolicy(cfg.Retry.GitHub.Apply(github.DefaultRetryPolicy)))
+}
+
+// newOrchestrator builds a review orchestrator from the INPUT_* environment,
+// exiting on invalid configuration. The returned function releases the
+// resources it opened.
+func newOrchestrator(flags cliFlags) (*runner.Orchestrator, func()) {
+	var closers []func()
+	cleanup := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+
 	// Configure logging
 	log.SetFormatter(&log.JSONFormatter{})
+	if flags.Format != "" {
+		// Keep stdout for the results.
+		log.SetOutput(os.Stderr)
+	}
 	if level := os.Getenv("LOG_LEVEL"); level != "" {
 		if parsedLevel, err := log.ParseLevel(level); err == nil {
 			log.SetLevel(parsedLevel)
 		}
 	}
 
-	// Get configuration from environment
+	// Get configuration from environment. A profile supplies defaults for
+	// inputs that are not set individually.
+	profile := config.Profile{ReviewDepth: runner.DepthStandard, MaxInlineComments: 25}
+	if name := os.Getenv("INPUT_PROFILE"); name != "" {
+		if p, ok := config.LookupProfile(name); ok {
+			profile = p
+		}
+	}
 	apiURL := os.Getenv("INPUT_API_URL")
 	apiKey := os.Getenv("INPUT_API_KEY")
 	model := os.Getenv("INPUT_MODEL")
+	if reviewModel := os.Getenv("INPUT_REVIEW_MODEL"); reviewModel != "" {
+		model = reviewModel
+	}
+	triageModel := os.Getenv("INPUT_TRIAGE_MODEL")
 	diffCommand := os.Getenv("INPUT_DIFF_COMMAND")
 	diffTimeoutSec := getEnvAsInt("INPUT_DIFF_TIMEOUT", 30)
 	apiTimeoutSec := getEnvAsInt("INPUT_API_TIMEOUT", 30)
 	postPRComment := getEnvAsBool("INPUT_POST_PR_COMMENT", true)
+	commentMode := strings.ToLower(os.Getenv("INPUT_COMMENT_MODE"))
+	if commentMode == "" {
+		commentMode = runner.CommentAppend
+	}
+	quiet := getEnvAsBool("INPUT_QUIET", false)
+	botLogin := os.Getenv("INPUT_BOT_LOGIN")
+	ccOwners := getEnvAsBool("INPUT_CC_OWNERS", false)
+	quietHours, err := runner.ParseQuietHours(os.Getenv("INPUT_QUIET_HOURS"), os.Getenv("INPUT_QUIET_HOURS_TIMEZONE"))
+	if err != nil {
+		log.WithError(err).Fatal("Invalid INPUT_QUIET_HOURS")
+	}
+	loc, err := reportLocale()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid INPUT_LOCALE or INPUT_TIMEZONE")
+	}
+	commentTemplate, err := runner.LoadCommentTemplate(os.Getenv("INPUT_COMMENT_TEMPLATE"))
+	if err != nil {
+		log.WithError(err).Fatal("Invalid INPUT_COMMENT_TEMPLATE")
+	}
+	quietHoursMode := strings.ToLower(os.Getenv("INPUT_QUIET_HOURS_MODE"))
+	if quietHoursMode == "" {
+		quietHoursMode = runner.QuietChecks
+	}
+	preflight := getEnvAsBool("INPUT_PREFLIGHT", true)
+	mergeQueueTimeoutSec := getEnvAsInt("INPUT_MERGE_QUEUE_TIMEOUT", 180)
 	useChecks := getEnvAsBool("INPUT_USE_CHECKS", false)
+	checkActions := getEnvAsBool("INPUT_CHECK_ACTIONS", false)
+	longReviewTarget := strings.ToLower(os.Getenv("INPUT_LONG_REVIEW_TARGET"))
+	if longReviewTarget == "" {
+		longReviewTarget = runner.LongReviewTruncate
+	}
+	gistToken := os.Getenv("INPUT_GIST_TOKEN")
+	updateDescription := getEnvAsBool("INPUT_UPDATE_DESCRIPTION", false)
+	trackFindings := getEnvAsBool("INPUT_TRACK_FINDINGS", false)
+	trackingLabel := os.Getenv("INPUT_TRACKING_LABEL")
+	if trackingLabel == "" {
+		trackingLabel = "repo-ranger"
+	}
+	trackingMilestone := getEnvAsInt("INPUT_TRACKING_MILESTONE", 0)
+	skipPatterns := getEnvAsList("INPUT_SKIP_PATTERNS")
+	if len(skipPatterns) == 0 {
+		skipPatterns = []string{"[skip ranger]", "[no review]"}
+	}
+	settleSeconds := getEnvAsInt("INPUT_SETTLE_SECONDS", 0)
+	maxReviewsPerDay := getEnvAsInt("INPUT_MAX_REVIEWS_PER_DAY", 0)
+	checksPerDirectory := getEnvAsBool("INPUT_CHECKS_PER_DIRECTORY", false)
+	checksDirectoryDepth := getEnvAsInt("INPUT_CHECKS_DIRECTORY_DEPTH", 1)
 	inlineComments := getEnvAsBool("INPUT_INLINE_COMMENTS", false)
+	maxInlineComments := getEnvAsInt("INPUT_MAX_INLINE_COMMENTS", profile.MaxInlineComments)
+	resolveThreads := getEnvAsBool("INPUT_RESOLVE_THREADS", false)
+	submitVerdict := getEnvAsBool("INPUT_SUBMIT_VERDICT", false)
+	fileComments := getEnvAsBool("INPUT_FILE_COMMENTS", false)
+	annotations := getEnvAsBool("INPUT_ANNOTATIONS", false)
+	renameSimilarity := getEnvAsInt("INPUT_RENAME_SIMILARITY", 50)
+	formatDetectors := getEnvAsList("INPUT_IGNORE_FORMATTING")
+	switch {
+	case len(formatDetectors) == 0:
+		formatDetectors = diff.DefaultDetectors
+	case len(formatDetectors) == 1 && formatDetectors[0] == "none":
+		formatDetectors = nil
+	}
 	githubToken := os.Getenv("INPUT_GITHUB_TOKEN")
 	temperature := getEnvFloat("INPUT_TEMPERATURE", 0.7)
+	maxCostPerRun := getEnvFloat("INPUT_MAX_COST_PER_RUN", 0)
+	tokenBudget := getEnvInt("INPUT_TOKEN_BUDGET", 0)
+	churnDays := getEnvInt("INPUT_CHURN_DAYS", 90)
+	chunkOverlap := getEnvInt("INPUT_CHUNK_OVERLAP", 0)
+	stackContext := getEnvAsBool("INPUT_STACK_CONTEXT", true)
 	maxTokens := getEnvInt("INPUT_MAX_TOKENS", 2000)
+	var modelCapabilities map[string]api.CapabilityOverride
+	if v := os.Getenv("INPUT_MODEL_CAPABILITIES"); strings.TrimSpace(v) != "" {
+		if err := json.Unmarshal([]byte(v), &modelCapabilities); err != nil {
+			log.WithError(err).Fatal("Invalid INPUT_MODEL_CAPABILITIES; expected a JSON object keyed by model name")
+		}
+	}
+	apiPath := os.Getenv("INPUT_API_PATH")
+	extraHeaders, err := getEnvAsStringMap("INPUT_EXTRA_HEADERS")
+	if err != nil {
+		log.WithError(err).Fatal("Invalid INPUT_EXTRA_HEADERS; expected a JSON object of header names to values")
+	}
+	coverageFile := os.Getenv("INPUT_COVERAGE_FILE")
+	spellingCheck := getEnvAsBool("INPUT_SPELLING_CHECK", false)
+	piiCheck := getEnvAsBool("INPUT_PII_CHECK", true)
+	apiDocCheck := getEnvAsBool("INPUT_API_DOC_CHECK", false)
+	i18nCheck := getEnvAsBool("INPUT_I18N_CHECK", false)
+	errorAudit := getEnvAsBool("INPUT_ERROR_AUDIT", false)
+	spellingWordList := os.Getenv("INPUT_SPELLING_WORDLIST")
+	styleGuides := getEnvAsList("INPUT_STYLE_GUIDES")
+	cacheDir := os.Getenv("INPUT_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = ".repo-ranger-cache"
+	}
+	focus := os.Getenv("INPUT_FOCUS")
+	minConfidence := getEnvFloat("INPUT_MIN_CONFIDENCE", 0)
+	minSeverity := types.Severity(strings.ToLower(os.Getenv("INPUT_MIN_SEVERITY")))
+	if minSeverity == "" {
+		minSeverity = profile.MinSeverity
+	}
+	tone := os.Getenv("INPUT_TONE")
+	if tone == "" {
+		tone = profile.Tone
+	}
+	reviewDepth := strings.ToLower(os.Getenv("INPUT_REVIEW_DEPTH"))
+	switch reviewDepth {
+	case runner.DepthSummary, runner.DepthStandard, runner.DepthDeep:
+	case "":
+		reviewDepth = profile.ReviewDepth
+	default:
+		log.WithField("reviewDepth", reviewDepth).Warn("Unknown review depth; using standard")
+		reviewDepth = runner.DepthStandard
+	}
+	baseRef := os.Getenv("INPUT_BASE_REF")
+	if baseRef == "" {
+		baseRef = "HEAD~1"
+	}
+	headRef := os.Getenv("INPUT_HEAD_REF")
+	if headRef == "" {
+		headRef = "HEAD"
+	}
+	// Events without a pull request name the commits to review in their
+	// payload; use them unless the range was set explicitly.
+	if ev, err := event.Load(os.Getenv("GITHUB_EVENT_NAME"), os.Getenv("GITHUB_EVENT_PATH")); err != nil {
+		log.WithError(err).Debug("Could not read a diff range from the event")
+	} else if ev.Output == event.OutputCheckRun && ev.HasRange() &&
+		baseRef == "HEAD~1" && headRef == "HEAD" && (diffCommand == "" || diffCommand == defaultDiffCommand) {
+		log.WithFields(log.Fields{"event": ev.Name, "base": ev.Base, "head": ev.Head}).Info("Reviewing the range named by the event")
+		baseRef, headRef, diffCommand = ev.Base, ev.Head, ev.DiffCommand()
+	}
+	diffMode := os.Getenv("INPUT_DIFF_MODE")
+	diffFile := flags.DiffFile
+	if diffFile == "" {
+		diffFile = os.Getenv("INPUT_DIFF_FILE")
+	}
+	configFile := os.Getenv("INPUT_CONFIG_FILE")
+	if configFile == "" {
+		configFile = config.DefaultPath
+	}
+	// Validate everything up front so misconfiguration fails fast with
+	// actionable messages instead of midway through a run.
+	problems := validateConfiguration(configFile, !flags.PublishOnly)
+	for _, p := range problems {
+		entry := log.WithField("field", p.Field)
+		if p.Warning {
+			entry.Warn(p.Message)
+		} else {
+			entry.Error(p.Message)
+		}
+	}
+	if config.HasErrors(problems) {
+		log.Fatal("Invalid configuration; run `repo-ranger config validate` for details")
+	}
+
+	httpClient := outboundClient()
+
+	// An organization's policy is always enforced on top of the repository's
+	// own configuration; a repository cannot opt out of it.
+	policy, err := loadOrgPolicy(githubToken, httpClient)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load organization policy")
+	}
+	repoConfig, overridden, err := config.LoadWithPolicy(configFile, policy)
+	if err != nil {
+		log.WithError(err).WithField("path", configFile).Fatal("Failed to load config file")
+	}
+	for _, p := range overridden {
+		log.WithField("field", p.Field).Warn(p.Message)
+	}
+	if capped, ok := policy.Temperature(temperature); ok {
+		log.WithFields(log.Fields{"temperature": temperature, "max": capped}).Warn("Temperature exceeds the organization policy; lowering it")
+		temperature = capped
+	}
+	categoryModes := map[types.Category]string{}
+	for category, mode := range repoConfig.Categories {
+		categoryModes[types.Category(category)] = mode
+	}
+	categoryLabels := map[types.Category]string{}
+	for category, label := range repoConfig.Labels {
+		categoryLabels[types.Category(category)] = label
+	}
+
+	// Fetch the API key from a secret manager when configured, so
+	// long-lived keys never need to live in repository secrets.
+	if sourceName := os.Getenv("INPUT_API_KEY_SOURCE"); sourceName != "" && !flags.PublishOnly {
+		key, err := fetchAPIKey(sourceName, httpClient)
+		if err != nil {
+			log.WithError(err).WithField("source", sourceName).Fatal("Failed to fetch API key from secret source")
+		}
+		// Make sure the runner redacts the key from all further output.
+		// The runner reads workflow commands from stderr too, which keeps
+		// them out of a review written to stdout.
+		if os.Getenv("GITHUB_ACTIONS") == "true" {
+			fmt.Fprintf(os.Stderr, "::add-mask::%s\n", key)
+		}
+		apiKey = key
+		log.WithField("source", sourceName).Info("Fetched API key from secret source")
+	}
 
 	// Validate required inputs
-	if apiURL == "" || apiKey == "" || model == "" {
+	if (apiURL == "" || apiKey == "" || model == "") && !flags.PublishOnly {
 		log.WithFields(log.Fields{
 			"apiURL": apiURL != "",
 			"apiKey": apiKey != "",
@@ -70,107 +362,219 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Token usage is always metered, for the cost output; metrics are
+	// optional.
+	usage := api.NewUsageMeter()
+	runnerOpts := []runner.Option{runner.WithUsageMeter(usage)}
+	if statsdAddr := os.Getenv("INPUT_STATSD_ADDR"); statsdAddr != "" {
+		prefix := os.Getenv("INPUT_METRICS_PREFIX")
+		if prefix == "" {
+			prefix = metrics.DefaultPrefix
+		}
+		sink, err := metrics.NewStatsD(statsdAddr, metrics.WithPrefix(prefix), metrics.WithTags(getEnvAsList("INPUT_METRICS_TAGS")...))
+		if err != nil {
+			log.WithError(err).Warn("Failed to set up metrics; continuing without them")
+		} else {
+			closers = append(closers, func() {
+				if err := sink.Close(); err != nil {
+					log.WithError(err).Debug("Failed to close metrics sink")
+				}
+			})
+			runnerOpts = append(runnerOpts, runner.WithMetrics(sink, usage))
+		}
+	}
+
 	// Initialize clients
 	apiClient := api.NewClient(apiURL, apiKey,
-		api.WithRetry(2, 3*time.Second),
+		api.WithUsageMeter(usage),
+		api.WithRetryPolicy(repoConfig.Retry.API.Apply(api.DefaultRetryPolicy)),
 		api.WithTemperature(temperature),
 		api.WithMaxTokens(maxTokens),
+		api.WithPath(apiPath),
+		api.WithHeaders(extraHeaders),
+		api.WithModelCapabilities(modelCapabilities),
+		api.WithHTTPClient(httpClient),
 	)
-	diffRunner := diff.NewRunner()
-	githubClient := github.NewClient(githubToken, nil)
-
-	// Get diff
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(diffTimeoutSec)*time.Second)
-	defer cancel()
-
-	log.WithFields(log.Fields{
-		"command": diffCommand,
-		"timeout": diffTimeoutSec,
-	}).Info("Executing diff command")
-
-	diffOutput, err := diffRunner.Run(ctx, diffCommand)
-	if err != nil {
-		log.WithError(err).Fatal("Failed to execute diff command")
-	}
-
-	trimmedDiff := strings.TrimSpace(diffOutput)
-	if trimmedDiff == "" {
-		log.Info("No code changes detected")
-		os.Exit(0)
+	diffRunner := diff.NewRunner(diff.WithShell(os.Getenv("INPUT_DIFF_SHELL")))
+	if diffMode == "go-git" {
+		// The in-process runner takes a revision range instead of a command.
+		diffRunner = diff.NewGitRunner(".")
+		diffCommand = baseRef + ".." + headRef
 	}
+	githubClient := github.NewClient(githubToken, httpClient,
+		github.WithRetryPolicy(repoConfig.Retry.GitHub.Apply(github.DefaultRetryPolicy)),
+		github.WithGistToken(gistToken),
+	)
 
-	// Process the diff
-	var finalReview string
-	ctx, cancel = context.WithTimeout(context.Background(), time.Duration(apiTimeoutSec)*time.Second)
-	defer cancel()
-
-	if len(trimmedDiff) <= maxChunkSize {
-		log.WithField("diffSize", len(trimmedDiff)).Debug("Diff size is within limits")
-		finalReview, err = apiClient.Review(ctx, model, buildDetailedPrompt(trimmedDiff))
+	if dir := os.Getenv("INPUT_HISTORY_DIR"); dir != "" {
+		store, err := history.Open(dir)
 		if err != nil {
-			log.WithError(err).Fatal("Failed during API call")
+			log.WithError(err).Fatal("Failed to open review history")
 		}
-	} else {
-		log.WithField("diffSize", len(trimmedDiff)).Info("Large diff detected; performing multi-step review")
-
-		chunks := diffRunner.SplitIntoChunks(trimmedDiff, maxChunkSize)
-		var reviews []string
+		runnerOpts = append(runnerOpts, runner.WithHistory(store))
+	}
 
-		for i, chunk := range chunks {
-			log.WithFields(log.Fields{
-				"chunk": i + 1,
-				"total": len(chunks),
-				"size":  len(chunk),
-			}).Info("Reviewing chunk")
+	if webhookURL := os.Getenv("INPUT_RESULTS_WEBHOOK"); webhookURL != "" {
+		results := webhook.NewClient(webhookURL, os.Getenv("INPUT_RESULTS_WEBHOOK_SECRET"), httpClient)
+		runnerOpts = append(runnerOpts, runner.WithResultsWebhook(results))
+	}
 
-			review, err := apiClient.Review(ctx, model, buildDetailedPrompt(chunk))
-			if err != nil {
-				log.WithFields(log.Fields{
-					"chunk": i + 1,
-					"error": err,
-				}).Fatal("Failed during detailed review")
-			}
-			reviews = append(reviews, review)
+	// The review bundle is uploaded as a workflow artifact when the runner
+	// provides the artifact service. Forked pull requests write one even
+	// when INPUT_REVIEW_BUNDLE is not set.
+	reviewBundle := os.Getenv("INPUT_REVIEW_BUNDLE")
+	saveTranscripts := os.Getenv("INPUT_SAVE_TRANSCRIPTS")
+	suggestionPatch := getEnvAsBool("INPUT_SUGGESTION_PATCH", false)
+	if artifacts, ok := artifact.FromEnv(httpClient); ok {
+		name := os.Getenv("INPUT_BUNDLE_ARTIFACT")
+		if name == "" {
+			name = "repo-ranger-review"
 		}
-
-		finalReview = strings.Join(reviews, "\n\n")
+		runnerOpts = append(runnerOpts, runner.WithBundleUpload(artifacts, name))
 	}
 
-	log.Debug("Review output generated successfully")
-
-	// Handle GitHub integration
-	if prEvent, err := parsePullRequestEvent(); err == nil && prEvent.PullRequest.Number > 0 {
-		if postPRComment {
-			if err := githubClient.PostPRComment(prEvent, finalReview); err != nil {
-				log.WithError(err).Error("Failed to post PR comment")
-			} else {
-				log.Info("PR comment posted successfully")
-			}
+	// Compiled-in analyzers always run; external ones are configured per
+	// repository.
+	analyzers := analyzer.Registered()
+	if i18nCheck {
+		analyzers = append(analyzers, i18n.NewAnalyzer())
+	}
+	for _, a := range repoConfig.Analyzers {
+		analyzers = append(analyzers, analyzer.NewExec(a.Name, a.Command, a.Args, a.Timeout))
+	}
+	if ff := repoConfig.FeatureFlags; len(ff.Files) > 0 {
+		a, err := featureflag.NewAnalyzer(diffRunner, ff.Files, ff.References)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid feature_flags configuration")
 		}
+		analyzers = append(analyzers, a)
+	}
 
-		if useChecks {
-			if err := githubClient.CreateCheckRun(finalReview); err != nil {
-				log.WithError(err).Error("Failed to create GitHub Check Run")
-			} else {
-				log.Info("GitHub Check Run created successfully")
-			}
+	// Confidential paths are pinned to providers of the repository's
+	// choosing, sharing the settings of the review model's client.
+	var egress []runner.EgressRule
+	for _, rule := range repoConfig.Egress.Rules {
+		r := runner.EgressRule{Paths: rule.Paths, Provider: rule.Provider}
+		if p, ok := repoConfig.Egress.Providers[rule.Provider]; ok {
+			r.Model = p.Model
+			r.Client = api.NewClient(p.APIURL, os.Getenv(p.APIKeyEnv),
+				api.WithUsageMeter(usage),
+				api.WithRetryPolicy(repoConfig.Retry.API.Apply(api.DefaultRetryPolicy)),
+				api.WithTemperature(temperature),
+				api.WithMaxTokens(maxTokens),
+				api.WithPath(p.APIPath),
+				api.WithModelCapabilities(modelCapabilities),
+				api.WithHTTPClient(httpClient),
+			)
 		}
+		egress = append(egress, r)
+	}
 
-		if inlineComments {
-			comments := parseInlineComments(finalReview)
-			if len(comments) > 0 {
-				if err := githubClient.PostInlineComments(prEvent, comments); err != nil {
-					log.WithError(err).Error("Failed to post inline comments")
-				} else {
-					log.WithField("count", len(comments)).Info("Inline comments posted successfully")
-				}
-			} else {
-				log.Debug("No inline comments found in the aggregated review")
-			}
-		}
-	} else {
-		log.WithError(err).Debug("No valid pull request event detected")
+	return runner.New(runner.Config{
+		Model:                model,
+		TriageModel:          triageModel,
+		MaxTokens:            maxTokens,
+		ModelCapabilities:    modelCapabilities,
+		APITimeout:           time.Duration(apiTimeoutSec) * time.Second,
+		DiffCommand:          diffCommand,
+		DiffFile:             diffFile,
+		OutputFormat:         flags.Format,
+		DiffFromPullRequest:  flags.DiffFromPullRequest,
+		DiffTimeout:          time.Duration(diffTimeoutSec) * time.Second,
+		BaseRef:              baseRef,
+		ReviewDepth:          reviewDepth,
+		MaxCostPerRun:        maxCostPerRun,
+		TokenBudget:          tokenBudget,
+		Priorities:           repoConfig.Priorities,
+		CategoryModes:        categoryModes,
+		CategoryLabels:       categoryLabels,
+		ChurnDays:            churnDays,
+		ChunkOverlap:         chunkOverlap,
+		StackContext:         stackContext,
+		Focus:                focus,
+		CoverageFile:         coverageFile,
+		SpellingCheck:        spellingCheck,
+		PIICheck:             piiCheck,
+		APIDocCheck:          apiDocCheck,
+		ErrorAudit:           errorAudit,
+		SpellingWordList:     spellingWordList,
+		StyleGuides:          styleGuides,
+		CacheDir:             cacheDir,
+		FormatDetectors:      formatDetectors,
+		MinRenameSimilarity:  renameSimilarity,
+		Scopes:               repoConfig.Scopes,
+		PostProcessor:        repoConfig.PostProcessor,
+		Analyzers:            analyzers,
+		Egress:               egress,
+		PostPRComment:        postPRComment,
+		CommentMode:          commentMode,
+		Quiet:                quiet,
+		BotLogin:             botLogin,
+		CCOwners:             ccOwners,
+		QuietHours:           quietHours,
+		CommentTemplate:      commentTemplate,
+		QuietHoursMode:       quietHoursMode,
+		Preflight:            preflight,
+		MergeQueueTimeout:    time.Duration(mergeQueueTimeoutSec) * time.Second,
+		UseChecks:            useChecks,
+		CheckActions:         checkActions,
+		LongReviewTarget:     longReviewTarget,
+		UpdateDescription:    updateDescription,
+		TrackFindings:        trackFindings,
+		TrackingLabel:        trackingLabel,
+		TrackingMilestone:    trackingMilestone,
+		TrackingProject:      os.Getenv("INPUT_TRACKING_PROJECT"),
+		ChecksPerDirectory:   checksPerDirectory,
+		ChecksDirectoryDepth: checksDirectoryDepth,
+		InlineComments:       inlineComments,
+		MaxInlineComments:    maxInlineComments,
+		ResolveThreads:       resolveThreads,
+		FileComments:         fileComments,
+		SubmitVerdict:        submitVerdict,
+		Annotations:          annotations,
+		ReviewBundle:         reviewBundle,
+		SuggestionPatch:      suggestionPatch,
+		SaveTranscripts:      saveTranscripts,
+		Secrets:              []string{apiKey, githubToken, gistToken, os.Getenv("INPUT_RESULTS_WEBHOOK_SECRET")},
+		MinSeverity:          minSeverity,
+		MinConfidence:        minConfidence,
+		Tone:                 tone,
+		SkipPatterns:         skipPatterns,
+		SettleDelay:          time.Duration(settleSeconds) * time.Second,
+		MaxReviewsPerDay:     maxReviewsPerDay,
+		TimeZone:             loc.Location(),
+		EventName:            os.Getenv("GITHUB_EVENT_NAME"),
+		EventPath:            os.Getenv("GITHUB_EVENT_PATH"),
+		HeadSHA:              os.Getenv("GITHUB_SHA"),
+		OutputPath:           os.Getenv("GITHUB_OUTPUT"),
+		RunURL:               workflowRunURL(),
+		StepSummaryPath:      os.Getenv("GITHUB_STEP_SUMMARY"),
+	}, diffRunner, apiClient, githubClient, runnerOpts...), cleanup
+}
+
+// fetchAPIKey reads the API key from the named secret source using the
+// workflow's OIDC token.
+func fetchAPIKey(sourceName string, httpClient secrets.HTTPClient) (string, error) {
+	source, err := secrets.New(sourceName, secrets.Config{
+		SecretID:                    os.Getenv("INPUT_SECRET_ID"),
+		SecretKey:                   os.Getenv("INPUT_SECRET_KEY"),
+		Audience:                    os.Getenv("INPUT_OIDC_AUDIENCE"),
+		AWSRoleARN:                  os.Getenv("INPUT_AWS_ROLE_ARN"),
+		AWSRegion:                   os.Getenv("INPUT_AWS_REGION"),
+		GCPWorkloadIdentityProvider: os.Getenv("INPUT_GCP_WORKLOAD_IDENTITY_PROVIDER"),
+		GCPServiceAccount:           os.Getenv("INPUT_GCP_SERVICE_ACCOUNT"),
+		VaultAddr:                   os.Getenv("INPUT_VAULT_ADDR"),
+		VaultRole:                   os.Getenv("INPUT_VAULT_ROLE"),
+		VaultAuthPath:               os.Getenv("INPUT_VAULT_AUTH_PATH"),
+		VaultNamespace:              os.Getenv("INPUT_VAULT_NAMESPACE"),
+	}, httpClient)
+	if err != nil {
+		return "", err
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return source.Fetch(ctx)
 }
 
 func getEnvAsInt(name string, defaultVal int) int {
@@ -192,86 +596,102 @@ func getEnvAsBool(name string, defaultVal bool) bool {
 	return defaultVal
 }
 
-func getEnvFloat(key string, defaultVal float64) float64 {
-	if val := os.Getenv(key); val != "" {
-		if parsed, err := strconv.ParseFloat(val, 64); err == nil {
-			return parsed
+// loadOrgPolicy reads the policies set in repo-ranger.yml in the .github
+// repository of the organization owning the repository. Without the file,
+// or outside GitHub, there is no policy.
+func loadOrgPolicy(token string, httpClient api.HTTPClient) (config.Policy, error) {
+	owner := repositoryOwner()
+	if owner == "" {
+		// A local run outside GitHub has no organization.
+		log.Debug("No repository owner; skipping the organization policy")
+		return config.Policy{}, nil
+	}
+	data, err := github.NewClient(token, httpClient).FileContents(owner+"/.github", tenant.OrgConfigPath)
+	if errors.Is(err, github.ErrNotFound) {
+		log.WithField("owner", owner).Debug("No organization policy")
+		return config.Policy{}, nil
+	}
+	if err != nil {
+		return config.Policy{}, fmt.Errorf("failed to read %s/.github/%s: %w", owner, tenant.OrgConfigPath, err)
+	}
+	policy, problems := config.ParsePolicy(data)
+	for _, p := range problems {
+		if !p.Warning {
+			return policy, fmt.Errorf("invalid organization policy: %s: %s", p.Field, p.Message)
 		}
 	}
-	return defaultVal
+	return policy, nil
 }
 
-func getEnvInt(key string, defaultVal int) int {
-	if val := os.Getenv(key); val != "" {
-		if parsed, err := strconv.Atoi(val); err == nil {
-			return parsed
-		}
+// repositoryOwner returns the owner of the repository under review, from
+// the Actions environment or, in server mode, the event payload.
+func repositoryOwner() string {
+	if owner := os.Getenv("GITHUB_REPOSITORY_OWNER"); owner != "" {
+		return owner
 	}
-	return defaultVal
+	data, err := os.ReadFile(os.Getenv("GITHUB_EVENT_PATH"))
+	if err != nil {
+		return ""
+	}
+	var event struct {
+		Repository struct {
+			Owner github.User `json:"owner"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return ""
+	}
+	return event.Repository.Owner.Login
 }
 
-func buildDetailedPrompt(diff string) string {
-	var b strings.Builder
-	b.WriteString("Perform a detailed, line-by-line review of the following code changes. ")
-	b.WriteString("For each changed line, output your review in the following format (each on a separate line):\n")
-	b.WriteString("InlineComment:\n")
-	b.WriteString("File: <file path>\n")
-	b.WriteString("Line: <line number>\n")
-	b.WriteString("Code Suggestion: <your suggested code change>\n")
-	b.WriteString("Reasoning: <explanation for the suggestion>\n")
-	b.WriteString("\nThen, provide an aggregated summary at the top.\n\n")
-	b.WriteString(diff)
-	return b.String()
+// workflowRunURL links to the current GitHub Actions run, or is empty
+// outside of Actions.
+func workflowRunURL() string {
+	server, repo, id := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")
+	if server == "" || repo == "" || id == "" {
+		return ""
+	}
+	return server + "/" + repo + "/actions/runs/" + id
 }
 
-func parsePullRequestEvent() (types.PullRequestEvent, error) {
-	var event types.PullRequestEvent
-	eventPath := os.Getenv("GITHUB_EVENT_PATH")
-	if eventPath == "" {
-		return event, fmt.Errorf("GITHUB_EVENT_PATH not set")
+// getEnvAsList splits a comma- or newline-separated input into trimmed values.
+func getEnvAsList(name string) []string {
+	var values []string
+	for _, v := range strings.FieldsFunc(os.Getenv(name), func(r rune) bool { return r == ',' || r == '\n' }) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
 	}
+	return values
+}
 
-	data, err := os.ReadFile(eventPath)
-	if err != nil {
-		return event, fmt.Errorf("failed to read event file: %w", err)
+// getEnvAsStringMap decodes a JSON object input such as {"X-Title": "ranger"}.
+func getEnvAsStringMap(name string) (map[string]string, error) {
+	v := os.Getenv(name)
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
 	}
-
-	if err := json.Unmarshal(data, &event); err != nil {
-		return event, fmt.Errorf("failed to parse event data: %w", err)
+	var m map[string]string
+	if err := json.Unmarshal([]byte(v), &m); err != nil {
+		return nil, err
 	}
-
-	return event, nil
+	return m, nil
 }
 
-func parseInlineComments(review string) []types.InlineComment {
-	var comments []types.InlineComment
-	lines := strings.Split(review, "\n")
-	var current *types.InlineComment
-
-	for _, line := range lines {
-		switch {
-		case strings.HasPrefix(line, "InlineComment:"):
-			if current != nil {
-				comments = append(comments, *current)
-			}
-			current = &types.InlineComment{}
-		case strings.HasPrefix(line, "File: ") && current != nil:
-			current.File = strings.TrimPrefix(line, "File: ")
-		case strings.HasPrefix(line, "Line: ") && current != nil:
-			lineStr := strings.TrimPrefix(line, "Line: ")
-			if line, err := strconv.Atoi(lineStr); err == nil {
-				current.Line = line
-			}
-		case strings.HasPrefix(line, "Code Suggestion: ") && current != nil:
-			current.Suggestion = strings.TrimPrefix(line, "Code Suggestion: ")
-		case strings.HasPrefix(line, "Reasoning: ") && current != nil:
-			current.Reasoning = strings.TrimPrefix(line, "Reasoning: ")
+func getEnvFloat(key string, defaultVal float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		if parsed, err := strconv.ParseFloat(val, 64); err == nil {
+			return parsed
 		}
 	}
+	return defaultVal
+}
 
-	if current != nil {
-		comments = append(comments, *current)
+func getEnvInt(key string, defaultVal int) int {
+	if val := os.Getenv(key); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil {
+			return parsed
+		}
 	}
-
-	return comments
+	return defaultVal
 }
@@ -0,0 +1,91 @@
+// Package analyzer is the extension point for deterministic checks: an
+// Analyzer inspects the parsed diff and reports findings without calling a
+// model. Findings from analyzers are merged into the same report, check
+// runs, and gating as the built-in checks.
+//
+// Analyzers are compiled in by registering them, usually from an init
+// function, or run as external executables speaking a JSON protocol; see
+// Exec.
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// Analyzer is a deterministic check over a diff.
+type Analyzer interface {
+	// Name identifies the analyzer. It is the Source of findings that do
+	// not set their own.
+	Name() string
+	// Analyze returns the findings in the diff. An error leaves the
+	// analyzer's findings out of the review without failing it.
+	Analyze(ctx context.Context, in Input) ([]types.Finding, error)
+}
+
+// Input is what an analyzer inspects.
+type Input struct {
+	Files []diff.FileDiff
+	// BaseRef is the git ref the diff is against, for reading the old
+	// contents of files.
+	BaseRef string
+	// Root is the directory of the repository's checkout, for reading the
+	// new contents of files and searching the rest of the repository.
+	Root string
+}
+
+var (
+	mu       sync.Mutex
+	registry = map[string]Analyzer{}
+)
+
+// Register adds an analyzer to every review. It panics if an analyzer of
+// the same name is already registered.
+func Register(a Analyzer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[a.Name()]; ok {
+		panic(fmt.Sprintf("analyzer: %q is already registered", a.Name()))
+	}
+	registry[a.Name()] = a
+}
+
+// Registered returns the registered analyzers, ordered by name.
+func Registered() []Analyzer {
+	mu.Lock()
+	defer mu.Unlock()
+	analyzers := make([]Analyzer, 0, len(registry))
+	for _, a := range registry {
+		analyzers = append(analyzers, a)
+	}
+	sort.Slice(analyzers, func(i, j int) bool { return analyzers[i].Name() < analyzers[j].Name() })
+	return analyzers
+}
+
+// Run runs each analyzer and returns their findings, with Source set to
+// the analyzer's name where a finding leaves it empty. Analyzers that fail
+// are reported through onError and skipped.
+func Run(ctx context.Context, analyzers []Analyzer, in Input, onError func(Analyzer, error)) []types.Finding {
+	var findings []types.Finding
+	for _, a := range analyzers {
+		found, err := a.Analyze(ctx, in)
+		if err != nil {
+			if onError != nil {
+				onError(a, err)
+			}
+			continue
+		}
+		for _, f := range found {
+			if f.Source == "" {
+				f.Source = a.Name()
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,157 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// ProtocolVersion is the version of the JSON exchanged with external
+// analyzers. It changes only when the protocol changes incompatibly.
+const ProtocolVersion = 1
+
+// DefaultTimeout bounds an external analyzer run when none is configured.
+const DefaultTimeout = 30 * time.Second
+
+// Request is the JSON written to an external analyzer's stdin.
+type Request struct {
+	Version int    `json:"version"`
+	BaseRef string `json:"base_ref,omitempty"`
+	// Root is the checkout's directory, which the executable also runs in.
+	Root  string `json:"root,omitempty"`
+	Files []File `json:"files"`
+}
+
+// File is a changed file in a Request.
+type File struct {
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+	// Status is added, deleted, renamed, or modified.
+	Status string `json:"status"`
+	Binary bool   `json:"binary,omitempty"`
+	Hunks  []Hunk `json:"hunks"`
+}
+
+// Hunk is a block of changes in a File.
+type Hunk struct {
+	OldStart int    `json:"old_start"`
+	OldLines int    `json:"old_lines"`
+	NewStart int    `json:"new_start"`
+	NewLines int    `json:"new_lines"`
+	Lines    []Line `json:"lines"`
+}
+
+// Line is a line of a Hunk.
+type Line struct {
+	// Kind is context, added, or removed.
+	Kind    string `json:"kind"`
+	Content string `json:"content"`
+	// OldLine is 0 for added lines and NewLine is 0 for removed lines.
+	OldLine int `json:"old_line,omitempty"`
+	NewLine int `json:"new_line,omitempty"`
+}
+
+// Response is the JSON an external analyzer writes to stdout.
+type Response struct {
+	Findings []types.Finding `json:"findings"`
+}
+
+// NewRequest builds the request for an input.
+func NewRequest(in Input) Request {
+	req := Request{Version: ProtocolVersion, BaseRef: in.BaseRef, Root: in.Root, Files: []File{}}
+	for _, f := range in.Files {
+		file := File{Path: f.Path(), Status: "modified", Binary: f.IsBinary, Hunks: []Hunk{}}
+		switch {
+		case f.IsNew:
+			file.Status = "added"
+		case f.IsDeleted:
+			file.Status = "deleted"
+		case f.IsRenamed:
+			file.Status = "renamed"
+			file.OldPath = f.OldPath
+		}
+		for _, h := range f.Hunks {
+			hunk := Hunk{OldStart: h.OldStart, OldLines: h.OldLines, NewStart: h.NewStart, NewLines: h.NewLines, Lines: []Line{}}
+			for _, l := range h.Lines {
+				kind := "context"
+				switch l.Kind {
+				case diff.LineAdded:
+					kind = "added"
+				case diff.LineRemoved:
+					kind = "removed"
+				}
+				hunk.Lines = append(hunk.Lines, Line{Kind: kind, Content: l.Content, OldLine: l.OldLine, NewLine: l.NewLine})
+			}
+			file.Hunks = append(file.Hunks, hunk)
+		}
+		req.Files = append(req.Files, file)
+	}
+	return req
+}
+
+// Exec is an analyzer run as an external executable. It receives a
+// Request as JSON on stdin and writes a Response to stdout; a non-zero
+// exit is a failure.
+type Exec struct {
+	name    string
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+// NewExec creates an analyzer running command with args. A timeout of 0
+// uses DefaultTimeout.
+func NewExec(name, command string, args []string, timeout time.Duration) *Exec {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Exec{name: name, command: command, args: args, timeout: timeout}
+}
+
+// Name returns the configured name.
+func (e *Exec) Name() string {
+	return e.name
+}
+
+// Analyze runs the executable.
+func (e *Exec) Analyze(ctx context.Context, in Input) ([]types.Finding, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	input, err := json.Marshal(NewRequest(in))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal analyzer request: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.command, e.args...)
+	cmd.Dir = in.Root
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("analyzer failed with stderr: %s: %w", bytes.TrimSpace(stderr.Bytes()), err)
+		}
+		return nil, fmt.Errorf("failed to run analyzer: %w", err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse analyzer output: %w", err)
+	}
+	for i, f := range resp.Findings {
+		if f.File == "" || f.Message == "" {
+			return nil, fmt.Errorf("finding %d has no file or message", i)
+		}
+	}
+	return resp.Findings, nil
+}
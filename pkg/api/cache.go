@@ -0,0 +1,43 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// CacheBreakpoint marks the end of the part of a prompt that repeats across
+// a review's requests, such as the instructions and context every chunk is
+// sent with, so providers can cache it. It is removed before sending.
+const CacheBreakpoint = "\x1e"
+
+// StripCacheBreakpoint removes the cache breakpoint from prompt, with the
+// blank line prompts separate it from what follows by.
+func StripCacheBreakpoint(prompt string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(prompt, CacheBreakpoint+"\n\n", ""), CacheBreakpoint, "")
+}
+
+// buildMessages shapes the system prompt and prompt into chat messages for
+// a model. The system prompt comes first and the prompt keeps its order,
+// so the prefix repeated across requests stays identical for providers
+// that cache prefixes by themselves. For PromptCacheControl providers the
+// prefix up to the cache breakpoint is sent as its own content part,
+// marked for caching.
+func buildMessages(caps ModelCapabilities, prompt string) []types.OpenAIMessage {
+	prefix, rest, marked := strings.Cut(prompt, CacheBreakpoint)
+	user := types.OpenAIMessage{Role: "user", Content: StripCacheBreakpoint(prompt)}
+	if !caps.SystemRole {
+		user.Content = systemPrompt + "\n\n" + user.Content
+		prefix = systemPrompt + "\n\n" + prefix
+	}
+	if marked && caps.PromptCache == PromptCacheControl && strings.TrimSpace(prefix) != "" {
+		user.Parts = []types.ContentPart{
+			{Type: "text", Text: prefix, CacheControl: &types.CacheControl{Type: "ephemeral"}},
+			{Type: "text", Text: StripCacheBreakpoint(strings.TrimPrefix(rest, "\n\n"))},
+		}
+	}
+	if !caps.SystemRole {
+		return []types.OpenAIMessage{user}
+	}
+	return []types.OpenAIMessage{{Role: "system", Content: systemPrompt}, user}
+}
@@ -4,21 +4,33 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/crazywolf132/repo-ranger/pkg/retry"
 	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
 	defaultTemperature = 0.7
 	defaultMaxTokens   = 2000
 	openAIEndpoint    = "https://api.openai.com/v1/chat/completions"
+	openAIBaseURL     = "https://api.openai.com"
+	systemPrompt      = "You are an expert code reviewer. Analyze the code changes and provide detailed, actionable feedback."
 )
 
+// DefaultRetryPolicy is used unless the client is configured otherwise.
+var DefaultRetryPolicy = retry.Policy{
+	Attempts:  3,
+	BaseDelay: 3 * time.Second,
+	MaxDelay:  30 * time.Second,
+}
+
 // Client represents an API client for the code review service.
 type Client interface {
 	Review(ctx context.Context, model, prompt string) (string, error)
@@ -33,20 +45,31 @@ type client struct {
 	baseURL     string
 	apiKey      string
 	httpClient  HTTPClient
-	retryCount  int
-	retryDelay  time.Duration
+	retry       retry.Policy
 	temperature float64
 	maxTokens   int
+	path        string
+	headers     map[string]string
+	overrides   map[string]CapabilityOverride
+	usage       *UsageMeter
 }
 
 // ClientOption is a function that configures a client.
 type ClientOption func(*client)
 
-// WithRetry sets the retry configuration for the client.
+// WithRetry sets the number of retries and a fixed delay between them.
 func WithRetry(count int, delay time.Duration) ClientOption {
 	return func(c *client) {
-		c.retryCount = count
-		c.retryDelay = delay
+		c.retry.Attempts = count + 1
+		c.retry.BaseDelay = delay
+		c.retry.MaxDelay = delay
+	}
+}
+
+// WithRetryPolicy sets the full retry policy for the client.
+func WithRetryPolicy(policy retry.Policy) ClientOption {
+	return func(c *client) {
+		c.retry = policy
 	}
 }
 
@@ -71,14 +94,44 @@ func WithMaxTokens(maxTokens int) ClientOption {
 	}
 }
 
+// WithPath sets a request path appended to the base URL, for gateways that
+// do not serve chat completions at the base URL itself.
+func WithPath(path string) ClientOption {
+	return func(c *client) {
+		c.path = path
+	}
+}
+
+// WithHeaders sets extra headers sent with every request. They are applied
+// after the defaults, so they may override them.
+func WithHeaders(headers map[string]string) ClientOption {
+	return func(c *client) {
+		c.headers = headers
+	}
+}
+
+// WithModelCapabilities overrides the built-in capability table for
+// specific model names.
+func WithModelCapabilities(overrides map[string]CapabilityOverride) ClientOption {
+	return func(c *client) {
+		c.overrides = overrides
+	}
+}
+
+// WithUsageMeter records the token usage of every response in m.
+func WithUsageMeter(m *UsageMeter) ClientOption {
+	return func(c *client) {
+		c.usage = m
+	}
+}
+
 // NewClient creates a new API client.
 func NewClient(baseURL, apiKey string, opts ...ClientOption) Client {
 	c := &client{
 		baseURL:     baseURL,
 		apiKey:      apiKey,
 		httpClient:  &http.Client{},
-		retryCount:  2,
-		retryDelay:  3 * time.Second,
+		retry:       DefaultRetryPolicy,
 		temperature: defaultTemperature,
 		maxTokens:   defaultMaxTokens,
 	}
@@ -90,48 +143,44 @@ func NewClient(baseURL, apiKey string, opts ...ClientOption) Client {
 	return c
 }
 
-// Review sends a review request to the API.
+// Review sends a review request to the API. A prompt the provider filters
+// or the model refuses is retried once with its string literals redacted.
 func (c *client) Review(ctx context.Context, model, prompt string) (string, error) {
-	var lastErr error
-	for i := 0; i <= c.retryCount; i++ {
-		if i > 0 {
-			log.WithFields(log.Fields{
-				"attempt": i,
-				"delay":   c.retryDelay,
-			}).Debug("Retrying API call")
-			time.Sleep(c.retryDelay)
-		}
-
-		review, err := c.makeRequest(ctx, model, prompt)
-		if err == nil {
-			return review, nil
-		}
-		lastErr = err
-		log.WithFields(log.Fields{
-			"attempt": i + 1,
-			"error":   err,
-		}).Warn("API call failed")
+	review, err := c.review(ctx, model, prompt)
+	if errors.Is(err, ErrContentFiltered) || errors.Is(err, ErrRefused) {
+		log.WithError(err).Warn("Review was declined; retrying with a sanitized prompt")
+		review, err = c.review(ctx, model, sanitizePrompt(prompt))
+	}
+	if err != nil {
+		return "", fmt.Errorf("API call failed: %w", err)
 	}
-	return "", fmt.Errorf("API call failed after %d attempts: %w", c.retryCount+1, lastErr)
+	return review, nil
+}
+
+func (c *client) review(ctx context.Context, model, prompt string) (string, error) {
+	var review string
+	err := retry.Do(ctx, c.retry, func() error {
+		var err error
+		review, err = c.makeRequest(ctx, model, prompt)
+		return err
+	})
+	return review, err
 }
 
 func (c *client) makeRequest(ctx context.Context, model, prompt string) (string, error) {
-	messages := []types.OpenAIMessage{
-		{
-			Role:    "system",
-			Content: "You are an expert code reviewer. Analyze the code changes and provide detailed, actionable feedback.",
-		},
-		{
-			Role:    "user",
-			Content: prompt,
-		},
-	}
+	caps := CapabilitiesFor(model, c.overrides)
 
 	payload := types.OpenAIRequest{
-		Model:       model,
-		Messages:    messages,
-		Temperature: c.temperature,
-		MaxTokens:   c.maxTokens,
+		Model:    model,
+		Messages: buildMessages(caps, prompt),
+	}
+	if caps.Temperature {
+		payload.Temperature = c.temperature
+	}
+	if caps.TokenParam == TokenParamMaxCompletionTokens {
+		payload.MaxCompletionTokens = c.maxTokens
+	} else {
+		payload.MaxTokens = c.maxTokens
 	}
 
 	jsonData, err := json.Marshal(payload)
@@ -139,23 +188,20 @@ func (c *client) makeRequest(ctx context.Context, model, prompt string) (string,
 		return "", fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	// Use OpenAI endpoint if baseURL is not specified
-	endpoint := c.baseURL
-	if endpoint == "" {
-		endpoint = openAIEndpoint
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint(), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", fmt.Errorf("%w: failed to send request: %w", ErrTransient, err)
 	}
 	defer resp.Body.Close()
 
@@ -165,7 +211,13 @@ func (c *client) makeRequest(ctx context.Context, model, prompt string) (string,
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API returned non-200 status code %d: %s", resp.StatusCode, string(body))
+		err := classifyError(resp.StatusCode, resp.Header, body)
+		// Retrying the same prompt cannot fix these, whatever the status.
+		if errors.Is(err, ErrContextLengthExceeded) || errors.Is(err, ErrAuth) || errors.Is(err, ErrContentFiltered) ||
+			!c.retry.Retryable(resp.StatusCode) {
+			return "", retry.Permanent(err)
+		}
+		return "", err
 	}
 
 	var apiResp types.OpenAIResponse
@@ -173,10 +225,41 @@ func (c *client) makeRequest(ctx context.Context, model, prompt string) (string,
 		return "", fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
+	if c.usage != nil {
+		c.usage.Add(model, apiResp.Usage)
+	}
+
 	if len(apiResp.Choices) == 0 {
 		return "", fmt.Errorf("no choices returned in API response")
 	}
 
+	if apiResp.Choices[0].FinishReason == "content_filter" {
+		return "", retry.Permanent(fmt.Errorf("%w: completion stopped by the provider's content filter", ErrContentFiltered))
+	}
+
 	review := apiResp.Choices[0].Message.Content
+	if refusal := apiResp.Choices[0].Message.Refusal; refusal != "" || isRefusal(review) {
+		if refusal == "" {
+			refusal = review
+		}
+		return "", retry.Permanent(fmt.Errorf("%w: %s", ErrRefused, strings.TrimSpace(refusal)))
+	}
 	return review, nil
 }
+
+// endpoint resolves the URL requests are sent to.
+func (c *client) endpoint() string {
+	if c.path == "" {
+		// Use OpenAI endpoint if baseURL is not specified
+		if c.baseURL == "" {
+			return openAIEndpoint
+		}
+		return c.baseURL
+	}
+
+	base := c.baseURL
+	if base == "" {
+		base = openAIBaseURL
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(c.path, "/")
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrContextLengthExceeded is returned when the prompt does not fit into
+	// the model's context window. Re-chunking the input may help.
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+	// ErrAuth is returned when the provider rejects the API key. Retrying
+	// cannot help.
+	ErrAuth = errors.New("authentication failed")
+	// ErrContentFiltered is returned when the provider's content filter
+	// blocked the prompt or the completion.
+	ErrContentFiltered = errors.New("content filtered")
+	// ErrRefused is returned when the model declined to review the prompt.
+	ErrRefused = errors.New("model refused the request")
+	// ErrTransient is returned for failures that may succeed when retried,
+	// such as server errors and timeouts.
+	ErrTransient = errors.New("transient provider error")
+)
+
+// ProviderError is returned for any unsuccessful response from the review
+// API. It unwraps to one of the sentinel errors above when the failure
+// could be classified.
+type ProviderError struct {
+	StatusCode int
+	Body       string
+	kind       error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("API returned non-200 status code %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *ProviderError) Unwrap() error { return e.kind }
+
+// RateLimitError is returned when the provider rate limited the request.
+// Wait is zero when the provider did not say how long to wait.
+type RateLimitError struct {
+	Wait time.Duration
+	Err  *ProviderError
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Wait > 0 {
+		return fmt.Sprintf("rate limited, retry after %s: %v", e.Wait, e.Err)
+	}
+	return fmt.Sprintf("rate limited: %v", e.Err)
+}
+
+func (e *RateLimitError) Unwrap() error { return e.Err }
+
+// RetryAfter returns how long the provider asked to wait, for retry.Do.
+func (e *RateLimitError) RetryAfter() time.Duration { return e.Wait }
+
+// classifyError turns an unsuccessful response into a typed error.
+func classifyError(statusCode int, header http.Header, body []byte) error {
+	providerErr := &ProviderError{StatusCode: statusCode, Body: string(body)}
+	code := errorCode(body)
+	switch {
+	case isContextLengthError(body):
+		providerErr.kind = ErrContextLengthExceeded
+	case code == "content_filter" || code == "content_policy_violation":
+		providerErr.kind = ErrContentFiltered
+	case statusCode == http.StatusTooManyRequests:
+		return &RateLimitError{Wait: retryAfter(header), Err: providerErr}
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		providerErr.kind = ErrAuth
+	case statusCode >= 500 || statusCode == http.StatusRequestTimeout:
+		providerErr.kind = ErrTransient
+	}
+	return providerErr
+}
+
+// errorCode extracts the OpenAI-style error.code or error.type field.
+func errorCode(body []byte) string {
+	var payload struct {
+		Error struct {
+			Code interface{} `json:"code"`
+			Type string      `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	if code, ok := payload.Error.Code.(string); ok && code != "" {
+		return code
+	}
+	return payload.Error.Type
+}
+
+func isContextLengthError(body []byte) bool {
+	text := strings.ToLower(string(body))
+	return strings.Contains(text, "context_length_exceeded") ||
+		strings.Contains(text, "maximum context length") ||
+		strings.Contains(text, "prompt is too long")
+}
+
+// retryAfter reads a Retry-After header, given either in seconds or as an
+// HTTP date.
+func retryAfter(header http.Header) time.Duration {
+	v := header.Get("Retry-After")
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
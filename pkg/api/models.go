@@ -0,0 +1,182 @@
+package api
+
+import (
+	"strings"
+)
+
+// Token limit parameter names used by OpenAI-compatible APIs.
+const (
+	TokenParamMaxTokens           = "max_tokens"
+	TokenParamMaxCompletionTokens = "max_completion_tokens"
+)
+
+// How providers cache the prompt prefix shared by a review's requests.
+const (
+	// PromptCacheAutomatic providers, such as OpenAI, cache long prompt
+	// prefixes by themselves.
+	PromptCacheAutomatic = "automatic"
+	// PromptCacheControl providers, such as Anthropic, cache a prefix only
+	// when its end is marked with a cache_control block.
+	PromptCacheControl = "cache_control"
+)
+
+// ModelCapabilities describes how requests for a model must be shaped.
+type ModelCapabilities struct {
+	// Temperature reports whether the model accepts a temperature parameter.
+	Temperature bool
+	// TokenParam is the name of the parameter limiting completion length.
+	TokenParam string
+	// SystemRole reports whether the model accepts system messages.
+	SystemRole bool
+	// ContextWindow is the total number of tokens the model accepts,
+	// including the completion.
+	ContextWindow int
+	// InputCost and OutputCost are the prices of a million prompt and
+	// completion tokens in US dollars; zero when unknown.
+	InputCost  float64
+	OutputCost float64
+	// CachedInputCost is the price of a million prompt tokens read from
+	// the provider's cache; zero when they cost InputCost.
+	CachedInputCost float64
+	// PromptCache is how the provider caches prompt prefixes:
+	// PromptCacheAutomatic or PromptCacheControl.
+	PromptCache string
+}
+
+// CapabilityOverride adjusts the detected capabilities of a model. Unset
+// fields keep the detected value.
+type CapabilityOverride struct {
+	Temperature     *bool    `json:"temperature,omitempty"`
+	TokenParam      *string  `json:"token_param,omitempty"`
+	SystemRole      *bool    `json:"system_role,omitempty"`
+	ContextWindow   *int     `json:"context_window,omitempty"`
+	InputCost       *float64 `json:"input_cost,omitempty"`
+	OutputCost      *float64 `json:"output_cost,omitempty"`
+	CachedInputCost *float64 `json:"cached_input_cost,omitempty"`
+	PromptCache     *string  `json:"prompt_cache,omitempty"`
+}
+
+// defaultCapabilities apply to unknown models. The context window is kept
+// conservative so unknown models are never overfilled.
+var defaultCapabilities = ModelCapabilities{
+	Temperature:   true,
+	TokenParam:    TokenParamMaxTokens,
+	SystemRole:    true,
+	ContextWindow: 8192,
+	PromptCache:   PromptCacheAutomatic,
+}
+
+// modelCapabilities maps model name prefixes to their capabilities. The
+// longest matching prefix wins.
+var modelCapabilities = map[string]ModelCapabilities{
+	"gpt-3.5-turbo": {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 16385, InputCost: 0.5, OutputCost: 1.5, PromptCache: PromptCacheAutomatic},
+	"gpt-4":         {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 8192, InputCost: 30, OutputCost: 60, PromptCache: PromptCacheAutomatic},
+	"gpt-4-32k":     {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 32768, InputCost: 60, OutputCost: 120, PromptCache: PromptCacheAutomatic},
+	"gpt-4-turbo":   {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 128000, InputCost: 10, OutputCost: 30, PromptCache: PromptCacheAutomatic},
+	"gpt-4o-mini":   {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 128000, InputCost: 0.15, OutputCost: 0.6, CachedInputCost: 0.075, PromptCache: PromptCacheAutomatic},
+	"gpt-4o":        {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 128000, InputCost: 2.5, OutputCost: 10, CachedInputCost: 1.25, PromptCache: PromptCacheAutomatic},
+	"gpt-4.1":       {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 1047576, InputCost: 2, OutputCost: 8, CachedInputCost: 0.5, PromptCache: PromptCacheAutomatic},
+	"o1":            {Temperature: false, TokenParam: TokenParamMaxCompletionTokens, SystemRole: true, ContextWindow: 200000, InputCost: 15, OutputCost: 60, CachedInputCost: 7.5, PromptCache: PromptCacheAutomatic},
+	"o1-mini":       {Temperature: false, TokenParam: TokenParamMaxCompletionTokens, SystemRole: false, ContextWindow: 128000, InputCost: 1.1, OutputCost: 4.4, CachedInputCost: 0.55, PromptCache: PromptCacheAutomatic},
+	"o1-preview":    {Temperature: false, TokenParam: TokenParamMaxCompletionTokens, SystemRole: false, ContextWindow: 128000, InputCost: 15, OutputCost: 60, CachedInputCost: 7.5, PromptCache: PromptCacheAutomatic},
+	"o3":            {Temperature: false, TokenParam: TokenParamMaxCompletionTokens, SystemRole: true, ContextWindow: 200000, InputCost: 2, OutputCost: 8, CachedInputCost: 0.5, PromptCache: PromptCacheAutomatic},
+	"o4":            {Temperature: false, TokenParam: TokenParamMaxCompletionTokens, SystemRole: true, ContextWindow: 200000, InputCost: 1.1, OutputCost: 4.4, CachedInputCost: 0.275, PromptCache: PromptCacheAutomatic},
+	"gpt-5":         {Temperature: false, TokenParam: TokenParamMaxCompletionTokens, SystemRole: true, ContextWindow: 400000, InputCost: 1.25, OutputCost: 10, CachedInputCost: 0.125, PromptCache: PromptCacheAutomatic},
+	// Claude models behind OpenAI-compatible gateways. Prices are only
+	// known for the versions listed.
+	"claude":            {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 200000, PromptCache: PromptCacheControl},
+	"claude-3-5-haiku":  {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 200000, InputCost: 0.8, OutputCost: 4, CachedInputCost: 0.08, PromptCache: PromptCacheControl},
+	"claude-3-5-sonnet": {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 200000, InputCost: 3, OutputCost: 15, CachedInputCost: 0.3, PromptCache: PromptCacheControl},
+	"claude-3-7-sonnet": {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 200000, InputCost: 3, OutputCost: 15, CachedInputCost: 0.3, PromptCache: PromptCacheControl},
+	"claude-sonnet-4":   {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 200000, InputCost: 3, OutputCost: 15, CachedInputCost: 0.3, PromptCache: PromptCacheControl},
+	"claude-opus-4":     {Temperature: true, TokenParam: TokenParamMaxTokens, SystemRole: true, ContextWindow: 200000, InputCost: 15, OutputCost: 75, CachedInputCost: 1.5, PromptCache: PromptCacheControl},
+}
+
+// CapabilitiesFor returns the capabilities of model, applying any override
+// registered for the exact model name.
+func CapabilitiesFor(model string, overrides map[string]CapabilityOverride) ModelCapabilities {
+	caps, _ := lookupModel(model)
+	if o, ok := overrides[model]; ok {
+		if o.Temperature != nil {
+			caps.Temperature = *o.Temperature
+		}
+		if o.TokenParam != nil {
+			caps.TokenParam = *o.TokenParam
+		}
+		if o.SystemRole != nil {
+			caps.SystemRole = *o.SystemRole
+		}
+		if o.ContextWindow != nil {
+			caps.ContextWindow = *o.ContextWindow
+		}
+		if o.InputCost != nil {
+			caps.InputCost = *o.InputCost
+		}
+		if o.OutputCost != nil {
+			caps.OutputCost = *o.OutputCost
+		}
+		if o.CachedInputCost != nil {
+			caps.CachedInputCost = *o.CachedInputCost
+		}
+		if o.PromptCache != nil {
+			caps.PromptCache = *o.PromptCache
+		}
+	}
+	return caps
+}
+
+// KnownModel reports whether model matches an entry of the built-in
+// capability table.
+func KnownModel(model string) bool {
+	_, ok := lookupModel(model)
+	return ok
+}
+
+// lookupModel finds the capabilities of the longest matching table entry,
+// falling back to conservative defaults.
+func lookupModel(model string) (ModelCapabilities, bool) {
+	// Gateways such as OpenRouter prefix models with a provider ("openai/o1").
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	caps := defaultCapabilities
+	longest := 0
+	for prefix, c := range modelCapabilities {
+		if len(prefix) > longest && (name == prefix || strings.HasPrefix(name, prefix+"-")) {
+			caps, longest = c, len(prefix)
+		}
+	}
+	return caps, longest > 0
+}
+
+// charsPerToken approximates how many characters of code make up a token.
+// It errs low so budgets stay on the safe side.
+const charsPerToken = 3
+
+// minDiffBudget keeps chunking usable even when the overhead estimate
+// leaves little room.
+const minDiffBudget = 1000
+
+// EstimateTokens roughly estimates the token count of text.
+func EstimateTokens(text string) int {
+	return len(text)/charsPerToken + 1
+}
+
+// CharsForTokens is the inverse of EstimateTokens: roughly how many
+// characters of code fit in tokens.
+func CharsForTokens(tokens int) int {
+	return tokens * charsPerToken
+}
+
+// DiffBudget returns how many characters of diff fit into a single request,
+// after reserving the system prompt, the completion, and the rest of the
+// prompt given as overhead.
+func DiffBudget(caps ModelCapabilities, maxCompletionTokens int, overhead string) int {
+	tokens := caps.ContextWindow - maxCompletionTokens - EstimateTokens(systemPrompt) - EstimateTokens(overhead)
+	if budget := tokens * charsPerToken; budget > minDiffBudget {
+		return budget
+	}
+	return minDiffBudget
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"regexp"
+	"strings"
+)
+
+// refusalPrefixes open the stock replies models give when they decline a
+// request.
+var refusalPrefixes = []string{
+	"i can't", "i can’t", "i cannot", "i can not", "i won't", "i won’t",
+	"i'm sorry, but", "i’m sorry, but", "i am sorry, but",
+	"i'm unable", "i’m unable", "i am unable", "i'm not able", "i am not able",
+	"sorry, i can", "sorry, but i",
+}
+
+// maxRefusalLength bounds the length of a reply treated as a refusal; a
+// real review that happens to open with "I can't" is much longer.
+const maxRefusalLength = 400
+
+// isRefusal reports whether a completion is the model declining the request
+// rather than a review.
+func isRefusal(content string) bool {
+	content = strings.ToLower(strings.TrimSpace(content))
+	if content == "" || len(content) > maxRefusalLength {
+		return false
+	}
+	for _, prefix := range refusalPrefixes {
+		if strings.HasPrefix(content, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var stringLiteral = regexp.MustCompile("\"(?:[^\"\\\\]|\\\\.)*\"|'(?:[^'\\\\]|\\\\.)*'|`[^`]*`")
+
+// sanitizePrompt redacts the string literals on the diff lines of a prompt.
+// Literals such as test fixtures, sample payloads, and error messages are
+// what usually trips content filters, and a review rarely depends on them.
+func sanitizePrompt(prompt string) string {
+	lines := strings.Split(prompt, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") || strings.HasPrefix(line, " ") {
+			lines[i] = stringLiteral.ReplaceAllString(line, `"<redacted>"`)
+		}
+	}
+	return "String literals in the code below have been replaced with \"<redacted>\"; review the code around them.\n\n" +
+		strings.Join(lines, "\n")
+}
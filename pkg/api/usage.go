@@ -0,0 +1,92 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// UsageMeter accumulates the token usage reported by the provider across
+// every request of a run. It is safe for concurrent use.
+type UsageMeter struct {
+	mu       sync.Mutex
+	usage    types.Usage
+	byModel  map[string]types.Usage
+	requests int
+}
+
+// NewUsageMeter creates an empty meter.
+func NewUsageMeter() *UsageMeter {
+	return &UsageMeter{}
+}
+
+// Add records the usage of one request to model.
+func (m *UsageMeter) Add(model string, u types.Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage = AddUsage(m.usage, u)
+	if m.byModel == nil {
+		m.byModel = map[string]types.Usage{}
+	}
+	m.byModel[model] = AddUsage(m.byModel[model], u)
+	m.requests++
+}
+
+// Total returns the usage recorded so far.
+func (m *UsageMeter) Total() types.Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+// ByModel returns the usage recorded so far, keyed by model.
+func (m *UsageMeter) ByModel() map[string]types.Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]types.Usage, len(m.byModel))
+	for model, u := range m.byModel {
+		out[model] = u
+	}
+	return out
+}
+
+// Requests returns the number of requests recorded so far.
+func (m *UsageMeter) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests
+}
+
+// AddUsage returns the sum of a and b.
+func AddUsage(a, b types.Usage) types.Usage {
+	return types.Usage{
+		PromptTokens:        a.PromptTokens + b.PromptTokens,
+		CompletionTokens:    a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:         a.TotalTokens + b.TotalTokens,
+		PromptTokensDetails: types.PromptTokensDetails{CachedTokens: a.PromptTokensDetails.CachedTokens + b.PromptTokensDetails.CachedTokens},
+	}
+}
+
+// SubtractUsage returns the usage of a beyond b, such as a meter's total
+// since an earlier reading.
+func SubtractUsage(a, b types.Usage) types.Usage {
+	return types.Usage{
+		PromptTokens:        a.PromptTokens - b.PromptTokens,
+		CompletionTokens:    a.CompletionTokens - b.CompletionTokens,
+		TotalTokens:         a.TotalTokens - b.TotalTokens,
+		PromptTokensDetails: types.PromptTokensDetails{CachedTokens: a.PromptTokensDetails.CachedTokens - b.PromptTokensDetails.CachedTokens},
+	}
+}
+
+// Cost estimates the cost of usage in US dollars from the model's prices,
+// billing cached prompt tokens at the model's cached price when it has
+// one. It is zero for models without known prices.
+func Cost(caps ModelCapabilities, u types.Usage) float64 {
+	cached := u.PromptTokensDetails.CachedTokens
+	cachedCost := caps.InputCost
+	if caps.CachedInputCost > 0 {
+		cachedCost = caps.CachedInputCost
+	}
+	return float64(u.PromptTokens-cached)*caps.InputCost/1e6 + float64(cached)*cachedCost/1e6 +
+		float64(u.CompletionTokens)*caps.OutputCost/1e6
+}
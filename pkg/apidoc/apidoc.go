@@ -0,0 +1,303 @@
+// Package apidoc checks that changes to a Go package's API are documented:
+// exported symbols a change adds or alters should have doc comments, and
+// changes to exported symbols should come with a README or changelog
+// update.
+package apidoc
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+const source = "apidoc"
+
+// maxSource bounds the source of a symbol kept for drafting its doc comment.
+const maxSource = 60
+
+// Symbol is an exported Go declaration a change touched.
+type Symbol struct {
+	File string
+	// Name is the symbol's name, with its receiver for methods, such as
+	// "(Client).Do".
+	Name string
+	// Kind is func, method, type, const, or var.
+	Kind string
+	// Line is the first line of the declaration, which a doc comment goes
+	// above, and Declaration the text of that line.
+	Line        int
+	Declaration string
+	// Source is the declaration's source, cut to its first lines.
+	Source     string
+	Documented bool
+	// New is set for symbols the change adds, and Changed for existing
+	// symbols whose declaration it alters, comments aside.
+	New     bool
+	Changed bool
+}
+
+// IsGoSource reports whether path is a Go file whose exported symbols are
+// API: not a test.
+func IsGoSource(path string) bool {
+	return strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go")
+}
+
+// declaration is an exported declaration found in a file.
+type declaration struct {
+	Symbol
+	// text is the declaration printed without comments, to compare
+	// versions of it.
+	text       string
+	start, end int
+}
+
+// Changes returns the exported symbols of the Go file after a change that
+// are new or changed since before, which is empty for new files, and whose
+// declarations overlap the touched lines. Files of package
+// main and generated files have no API and return nothing.
+func Changes(file, before, after string, touched []int) ([]Symbol, error) {
+	current, err := declarations(file, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	previous := map[string]declaration{}
+	if before != "" {
+		if previous, err = declarations(file, before); err != nil {
+			// The old version may not have compiled; compare nothing.
+			previous = map[string]declaration{}
+		}
+	}
+
+	var symbols []Symbol
+	for key, d := range current {
+		if !overlaps(touched, d.start, d.end) {
+			continue
+		}
+		old, existed := previous[key]
+		d.New = !existed
+		d.Changed = existed && old.text != d.text
+		if d.New || d.Changed {
+			symbols = append(symbols, d.Symbol)
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].Line < symbols[j].Line })
+	return symbols, nil
+}
+
+// declarations returns the exported declarations of a Go file, keyed by
+// kind and name.
+func declarations(file, src string) (map[string]declaration, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	decls := map[string]declaration{}
+	if f.Name.Name == "main" || ast.IsGenerated(f) {
+		return decls, nil
+	}
+	lines := strings.Split(src, "\n")
+
+	add := func(kind, name string, node ast.Node, doc bool, start token.Pos) {
+		d := declaration{
+			Symbol: Symbol{File: file, Name: name, Kind: kind, Documented: doc},
+			start:  fset.Position(start).Line,
+			end:    fset.Position(node.End()).Line,
+		}
+		d.Line = d.start
+		d.Declaration = lines[d.Line-1]
+		last := d.end
+		if last > d.start+maxSource-1 {
+			last = d.start + maxSource - 1
+		}
+		d.Source = strings.Join(lines[d.start-1:last], "\n")
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, withoutDocs(node)); err == nil {
+			d.text = buf.String()
+		}
+		decls[kind+" "+name] = d
+	}
+
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+			if decl.Recv == nil {
+				add("func", decl.Name.Name, decl, decl.Doc != nil, decl.Pos())
+				continue
+			}
+			recv := receiverName(decl)
+			if !ast.IsExported(recv) {
+				continue
+			}
+			add("method", fmt.Sprintf("(%s).%s", recv, decl.Name.Name), decl, decl.Doc != nil, decl.Pos())
+		case *ast.GenDecl:
+			grouped := decl.Lparen.IsValid()
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if !spec.Name.IsExported() {
+						continue
+					}
+					start := spec.Pos()
+					if !grouped {
+						start = decl.Pos()
+					}
+					add("type", spec.Name.Name, spec, spec.Doc != nil || decl.Doc != nil, start)
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if !name.IsExported() {
+							continue
+						}
+						start := spec.Pos()
+						if !grouped {
+							start = decl.Pos()
+						}
+						// A comment on the group documents its members, as
+						// for enumerations.
+						doc := spec.Doc != nil || spec.Comment != nil || decl.Doc != nil
+						add(decl.Tok.String(), name.Name, spec, doc, start)
+					}
+				}
+			}
+		}
+	}
+	return decls, nil
+}
+
+// withoutDocs returns a copy of a declaration without its doc and line
+// comments, which the printer would otherwise print, so that documenting a
+// symbol does not count as changing it.
+func withoutDocs(node ast.Node) ast.Node {
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		c := *n
+		c.Doc = nil
+		return &c
+	case *ast.TypeSpec:
+		c := *n
+		c.Doc, c.Comment = nil, nil
+		return &c
+	case *ast.ValueSpec:
+		c := *n
+		c.Doc, c.Comment = nil, nil
+		return &c
+	}
+	return node
+}
+
+func receiverName(fn *ast.FuncDecl) string {
+	if len(fn.Recv.List) == 0 {
+		return ""
+	}
+	t := fn.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch x := t.(type) {
+	case *ast.IndexExpr:
+		t = x.X
+	case *ast.IndexListExpr:
+		t = x.X
+	}
+	if ident, ok := t.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// overlaps reports whether any touched line lies from start to end.
+func overlaps(touched []int, start, end int) bool {
+	for _, l := range touched {
+		if l >= start && l <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDocFile reports whether path is a README, a changelog, or under a docs
+// directory, where changes to an API are described.
+func IsDocFile(p string) bool {
+	base := strings.ToUpper(path.Base(p))
+	for _, prefix := range []string{"README", "CHANGELOG", "CHANGES", "HISTORY", "RELEASE"} {
+		if strings.HasPrefix(base, prefix) {
+			return true
+		}
+	}
+	return strings.HasPrefix(p, "docs/") || strings.Contains(p, "/docs/")
+}
+
+// IsFinding reports whether a finding came from this package.
+func IsFinding(f types.Finding) bool {
+	return f.Source == source
+}
+
+// Undocumented returns a minor docs finding for each symbol without a doc
+// comment.
+func Undocumented(symbols []Symbol) []types.Finding {
+	var findings []types.Finding
+	for _, s := range symbols {
+		if s.Documented {
+			continue
+		}
+		verb := "changed"
+		if s.New {
+			verb = "added"
+		}
+		findings = append(findings, types.Finding{
+			File:     s.File,
+			Line:     s.Line,
+			Severity: types.SeverityMinor,
+			Source:   source,
+			Message:  fmt.Sprintf("exported %s %s is %s without a doc comment", s.Kind, s.Name, verb),
+			Category: types.CategoryDocs,
+		})
+	}
+	return findings
+}
+
+// UndescribedChanges returns one docs finding, on the first changed
+// symbol, listing the existing exported symbols the change alters when it
+// updates no README, changelog, or docs; docsUpdated says whether it does.
+func UndescribedChanges(symbols []Symbol, docsUpdated bool) []types.Finding {
+	if docsUpdated {
+		return nil
+	}
+	var names []string
+	var first *Symbol
+	for i, s := range symbols {
+		if !s.Changed {
+			continue
+		}
+		if first == nil {
+			first = &symbols[i]
+		}
+		names = append(names, "`"+s.Name+"`")
+	}
+	if first == nil {
+		return nil
+	}
+	if len(names) > 5 {
+		names = append(names[:5], fmt.Sprintf("%d more", len(names)-5))
+	}
+	return []types.Finding{{
+		File:     first.File,
+		Line:     first.Line,
+		Severity: types.SeverityNit,
+		Source:   source,
+		Message: fmt.Sprintf("exported API changed (%s) but no README, changelog, or docs were updated; describe changes in behavior for users",
+			strings.Join(names, ", ")),
+		Category: types.CategoryDocs,
+	}}
+}
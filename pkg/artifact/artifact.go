@@ -0,0 +1,256 @@
+// Package artifact uploads files as workflow run artifacts through the
+// GitHub Actions artifact service, as actions/upload-artifact@v4 does.
+package artifact
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/retry"
+)
+
+const servicePath = "/twirp/github.actions.results.api.v1.ArtifactService/"
+
+// DefaultRetryPolicy is used unless the client is configured otherwise.
+var DefaultRetryPolicy = retry.Policy{
+	Attempts:  3,
+	BaseDelay: time.Second,
+	MaxDelay:  10 * time.Second,
+}
+
+// HTTPClient represents the interface for making HTTP requests.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Client uploads artifacts for the current workflow run.
+type Client struct {
+	resultsURL string
+	token      string
+	httpClient HTTPClient
+	retry      retry.Policy
+}
+
+// ClientOption is a function that configures a client.
+type ClientOption func(*Client)
+
+// WithRetryPolicy sets the retry policy for service calls.
+func WithRetryPolicy(p retry.Policy) ClientOption {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// NewClient creates a client for the artifact service at resultsURL,
+// authenticated with the job's runtime token.
+func NewClient(resultsURL, token string, httpClient HTTPClient, opts ...ClientOption) *Client {
+	c := &Client{
+		resultsURL: strings.TrimSuffix(resultsURL, "/"),
+		token:      token,
+		httpClient: httpClient,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// FromEnv creates a client from the ACTIONS_RESULTS_URL and
+// ACTIONS_RUNTIME_TOKEN variables the runner provides. It returns false
+// outside of GitHub Actions.
+func FromEnv(httpClient HTTPClient, opts ...ClientOption) (*Client, bool) {
+	resultsURL, token := os.Getenv("ACTIONS_RESULTS_URL"), os.Getenv("ACTIONS_RUNTIME_TOKEN")
+	if resultsURL == "" || token == "" {
+		return nil, false
+	}
+	return NewClient(resultsURL, token, httpClient, opts...), true
+}
+
+// UploadDir zips the files under dir and uploads them as an artifact
+// called name, returning the artifact's ID.
+func (c *Client) UploadDir(ctx context.Context, name, dir string) (int64, error) {
+	runID, jobID, err := backendIDs(c.token)
+	if err != nil {
+		return 0, err
+	}
+	archive, err := zipDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+
+	var created struct {
+		OK              bool   `json:"ok"`
+		SignedUploadURL string `json:"signed_upload_url"`
+	}
+	err = c.call(ctx, "CreateArtifact", map[string]interface{}{
+		"workflow_run_backend_id":     runID,
+		"workflow_job_run_backend_id": jobID,
+		"name":                        name,
+		"version":                     4,
+	}, &created)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create artifact: %w", err)
+	}
+	if !created.OK || created.SignedUploadURL == "" {
+		return 0, fmt.Errorf("artifact service did not accept artifact %q", name)
+	}
+
+	if err := c.upload(ctx, created.SignedUploadURL, archive); err != nil {
+		return 0, fmt.Errorf("failed to upload artifact: %w", err)
+	}
+
+	sum := sha256.Sum256(archive)
+	var finalized struct {
+		OK         bool   `json:"ok"`
+		ArtifactID string `json:"artifact_id"`
+	}
+	err = c.call(ctx, "FinalizeArtifact", map[string]interface{}{
+		"workflow_run_backend_id":     runID,
+		"workflow_job_run_backend_id": jobID,
+		"name":                        name,
+		"size":                        strconv.Itoa(len(archive)),
+		"hash":                        "sha256:" + hex.EncodeToString(sum[:]),
+	}, &finalized)
+	if err != nil {
+		return 0, fmt.Errorf("failed to finalize artifact: %w", err)
+	}
+	if !finalized.OK {
+		return 0, fmt.Errorf("artifact service did not finalize artifact %q", name)
+	}
+	id, _ := strconv.ParseInt(finalized.ArtifactID, 10, 64)
+	return id, nil
+}
+
+// call invokes a method of the artifact service.
+func (c *Client) call(ctx context.Context, method string, payload, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return retry.Do(ctx, c.retry, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.resultsURL+servicePath+method, bytes.NewReader(body))
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("failed to create request: %w", err))
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "repo-ranger")
+
+		respBody, err := c.do(req)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return retry.Permanent(fmt.Errorf("failed to decode response: %w", err))
+		}
+		return nil
+	})
+}
+
+// upload puts the archive to the signed blob storage URL.
+func (c *Client) upload(ctx context.Context, url string, archive []byte) error {
+	return retry.Do(ctx, c.retry, func() error {
+		req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(archive))
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("failed to create request: %w", err))
+		}
+		req.Header.Set("Content-Type", "application/zip")
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+		_, err = c.do(req)
+		return err
+	})
+}
+
+// do sends a request and returns the response body, marking errors that
+// are not worth retrying as permanent.
+func (c *Client) do(req *http.Request) ([]byte, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("artifact service returned status code %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+		if !c.retry.Retryable(resp.StatusCode) {
+			return nil, retry.Permanent(err)
+		}
+		return nil, err
+	}
+	return respBody, nil
+}
+
+// backendIDs extracts the workflow run and job backend IDs from the
+// runtime token, whose scp claim includes "Actions.Results:<run>:<job>".
+func backendIDs(token string) (string, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", "", fmt.Errorf("runtime token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode runtime token: %w", err)
+	}
+	var claims struct {
+		Scp string `json:"scp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", "", fmt.Errorf("failed to decode runtime token: %w", err)
+	}
+	for _, scope := range strings.Fields(claims.Scp) {
+		fields := strings.Split(scope, ":")
+		if len(fields) == 3 && fields[0] == "Actions.Results" {
+			return fields[1], fields[2], nil
+		}
+	}
+	return "", "", fmt.Errorf("runtime token has no Actions.Results scope")
+}
+
+// zipDir archives the regular files under dir with paths relative to it.
+func zipDir(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
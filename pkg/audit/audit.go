@@ -0,0 +1,264 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HTTPClient represents the interface for making HTTP requests.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Entry is one audited outbound request. Entries form a hash chain: each
+// entry's Hash covers its contents and the previous entry's hash, so any
+// edit, insertion, or deletion breaks verification of every later entry.
+type Entry struct {
+	Seq              int    `json:"seq"`
+	Timestamp        string `json:"timestamp"`
+	Method           string `json:"method"`
+	Endpoint         string `json:"endpoint"`
+	Status           int    `json:"status,omitempty"`
+	DurationMs       int64  `json:"duration_ms"`
+	RequestBytes     int    `json:"request_bytes"`
+	RequestHash      string `json:"request_sha256"`
+	ResponseBytes    int    `json:"response_bytes"`
+	ResponseHash     string `json:"response_sha256,omitempty"`
+	PromptTokens     int    `json:"prompt_tokens,omitempty"`
+	CompletionTokens int    `json:"completion_tokens,omitempty"`
+	TotalTokens      int    `json:"total_tokens,omitempty"`
+	Error            string `json:"error,omitempty"`
+	PrevHash         string `json:"prev_hash"`
+	Hash             string `json:"hash"`
+}
+
+// Logger appends entries to a JSON-lines audit log.
+type Logger struct {
+	mu       sync.Mutex
+	file     *os.File
+	seq      int
+	prevHash string
+}
+
+// Open opens or creates the audit log at path. An existing log is
+// continued, chaining new entries onto its last entry.
+func Open(path string) (*Logger, error) {
+	l := &Logger{}
+	if data, err := os.ReadFile(path); err == nil {
+		entries, err := parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read existing audit log: %w", err)
+		}
+		if n := len(entries); n > 0 {
+			l.seq = entries[n-1].Seq
+			l.prevHash = entries[n-1].Hash
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = f
+	return l, nil
+}
+
+// Close closes the underlying file.
+func (l *Logger) Close() error {
+	return l.file.Close()
+}
+
+// Record seals and appends an entry.
+func (l *Logger) Record(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	e.Seq = l.seq
+	e.PrevHash = l.prevHash
+	e.Hash = ""
+	e.Hash = entryHash(e)
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	l.prevHash = e.Hash
+	return nil
+}
+
+// Wrap returns an HTTP client that records every request made through it.
+func (l *Logger) Wrap(client HTTPClient) HTTPClient {
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &auditedClient{logger: l, client: client}
+}
+
+// LoggerOf returns the logger recording the requests made through client,
+// or nil when client is not audited.
+func LoggerOf(client HTTPClient) *Logger {
+	if c, ok := client.(*auditedClient); ok {
+		return c.logger
+	}
+	return nil
+}
+
+// Transfer records an outbound transfer that does not go through an HTTP
+// client, such as a git push, given the bytes it sent and when it started.
+func (l *Logger) Transfer(method, endpoint string, sent []byte, start time.Time, err error) {
+	entry := Entry{
+		Timestamp:    start.UTC().Format(time.RFC3339Nano),
+		Method:       method,
+		Endpoint:     endpoint,
+		DurationMs:   time.Since(start).Milliseconds(),
+		RequestBytes: len(sent),
+		RequestHash:  hashBytes(sent),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	(&auditedClient{logger: l}).record(entry)
+}
+
+type auditedClient struct {
+	logger *Logger
+	client HTTPClient
+}
+
+func (c *auditedClient) Do(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body for audit: %w", err)
+		}
+		reqBody = data
+		req.Body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	// Query strings can carry credentials, so only the origin and path are logged.
+	entry := Entry{
+		Timestamp:    time.Now().UTC().Format(time.RFC3339Nano),
+		Method:       req.Method,
+		Endpoint:     fmt.Sprintf("%s://%s%s", req.URL.Scheme, req.URL.Host, req.URL.Path),
+		RequestBytes: len(reqBody),
+		RequestHash:  hashBytes(reqBody),
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	entry.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		entry.Error = err.Error()
+		c.record(entry)
+		return nil, err
+	}
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	entry.Status = resp.StatusCode
+	entry.ResponseBytes = len(respBody)
+	entry.ResponseHash = hashBytes(respBody)
+	if readErr != nil {
+		entry.Error = readErr.Error()
+	}
+
+	var usage struct {
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if json.Unmarshal(respBody, &usage) == nil {
+		entry.PromptTokens = usage.Usage.PromptTokens
+		entry.CompletionTokens = usage.Usage.CompletionTokens
+		entry.TotalTokens = usage.Usage.TotalTokens
+	}
+
+	c.record(entry)
+	return resp, readErr
+}
+
+func (c *auditedClient) record(e Entry) {
+	if err := c.logger.Record(e); err != nil {
+		// The audit trail must not silently lose entries, but failing the
+		// request would leave the caller unable to act either; surface it.
+		log.WithFields(log.Fields{"method": e.Method, "endpoint": e.Endpoint}).WithError(err).Error("Failed to record audit entry")
+	}
+}
+
+// Verify checks the hash chain of the audit log at path and returns the
+// number of entries verified.
+func Verify(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	entries, err := parse(data)
+	if err != nil {
+		return 0, err
+	}
+
+	prev := ""
+	for i, e := range entries {
+		if e.Seq != i+1 {
+			return i, fmt.Errorf("entry %d has sequence number %d", i+1, e.Seq)
+		}
+		if e.PrevHash != prev {
+			return i, fmt.Errorf("entry %d does not chain to the previous entry", e.Seq)
+		}
+		want := e.Hash
+		e.Hash = ""
+		if entryHash(e) != want {
+			return i, fmt.Errorf("entry %d has been modified", e.Seq)
+		}
+		prev = want
+	}
+	return len(entries), nil
+}
+
+func parse(data []byte) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("malformed audit entry %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func entryHash(e Entry) string {
+	data, _ := json.Marshal(e)
+	return hashBytes(data)
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
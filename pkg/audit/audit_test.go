@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLog records n entries to a new audit log and returns its lines.
+func writeLog(t *testing.T, path string, n int) []string {
+	t.Helper()
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := 0; i < n; i++ {
+		if err := l.Record(Entry{Method: "POST", Endpoint: "https://api.example.com/v1/chat/completions", Status: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+		n      int
+		err    string
+	}{
+		{
+			name:   "untouched",
+			tamper: func(lines []string) []string { return lines },
+			n:      3,
+		},
+		{
+			name: "edited entry",
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"status":200`, `"status":403`, 1)
+				return lines
+			},
+			n:   1,
+			err: "entry 2 has been modified",
+		},
+		{
+			name: "deleted entry",
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			n:   1,
+			err: "sequence number 3",
+		},
+		{
+			name: "reordered entries",
+			tamper: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			n:   1,
+			err: "sequence number 3",
+		},
+		{
+			name: "malformed entry",
+			tamper: func(lines []string) []string {
+				lines[2] = lines[2][:len(lines[2])/2]
+				return lines
+			},
+			err: "malformed audit entry 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			lines := tt.tamper(writeLog(t, path, 3))
+			if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := Verify(path)
+			if n != tt.n {
+				t.Errorf("Verify verified %d entries, want %d", n, tt.n)
+			}
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Verify = %v, want no error", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("Verify = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestOpenContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeLog(t, path, 2)
+	writeLog(t, path, 2)
+	if n, err := Verify(path); n != 4 || err != nil {
+		t.Errorf("Verify = %d, %v, want 4 entries chained across reopening", n, err)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if LoggerOf(http.DefaultClient) != nil {
+		t.Error("LoggerOf returned a logger for an unaudited client")
+	}
+	logger := LoggerOf(l.Wrap(nil))
+	if logger != l {
+		t.Fatal("LoggerOf did not return the logger of an audited client")
+	}
+	logger.Transfer("POST", "https://github.com/acme/app.wiki.git/git-receive-pack", []byte("page"), time.Now(), errors.New("rejected"))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Endpoint != "https://github.com/acme/app.wiki.git/git-receive-pack" || e.RequestBytes != 4 || e.RequestHash != hashBytes([]byte("page")) || e.Error != "rejected" {
+		t.Errorf("entry = %+v, want the push with its payload and error", e)
+	}
+	if n, err := Verify(path); n != 1 || err != nil {
+		t.Errorf("Verify = %d, %v, want 1 chained entry", n, err)
+	}
+}
@@ -0,0 +1,103 @@
+// Package budget records what server mode spends on each repository per
+// calendar month, so monthly budgets hold across restarts.
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ledger is a directory of monthly spend files, one per UTC month, each
+// mapping "owner/name" to US dollars spent.
+type Ledger struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open opens the ledger in dir, creating the directory if needed.
+func Open(dir string) (*Ledger, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create budget directory: %w", err)
+	}
+	return &Ledger{dir: dir}, nil
+}
+
+// Spent returns what repo has spent in the month of at.
+func (l *Ledger) Spent(repo string, at time.Time) (float64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	spend, err := l.read(at)
+	if err != nil {
+		return 0, err
+	}
+	return spend[strings.ToLower(repo)], nil
+}
+
+// Add records cost against repo in the month of at.
+func (l *Ledger) Add(repo string, at time.Time, cost float64) error {
+	if cost <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	spend, err := l.read(at)
+	if err != nil {
+		return err
+	}
+	spend[strings.ToLower(repo)] += cost
+	return l.write(at, spend)
+}
+
+func (l *Ledger) path(at time.Time) string {
+	return filepath.Join(l.dir, at.UTC().Format("2006-01")+".json")
+}
+
+func (l *Ledger) read(at time.Time) (map[string]float64, error) {
+	spend := map[string]float64{}
+	data, err := os.ReadFile(l.path(at))
+	if errors.Is(err, os.ErrNotExist) {
+		return spend, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read budget ledger: %w", err)
+	}
+	if err := json.Unmarshal(data, &spend); err != nil {
+		return nil, fmt.Errorf("failed to parse budget ledger: %w", err)
+	}
+	return spend, nil
+}
+
+// write replaces the month's file atomically, so a crash never leaves a
+// partial file behind.
+func (l *Ledger) write(at time.Time, spend map[string]float64) error {
+	data, err := json.MarshalIndent(spend, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal budget ledger: %w", err)
+	}
+	tmp, err := os.CreateTemp(l.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to write budget ledger: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write budget ledger: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write budget ledger: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write budget ledger: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path(at)); err != nil {
+		return fmt.Errorf("failed to write budget ledger: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package budget
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLedger(t *testing.T) {
+	march := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	// Still March in UTC, though April in Auckland.
+	auckland := march.In(time.FixedZone("NZDT", 13*60*60))
+	april := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	type add struct {
+		repo string
+		at   time.Time
+		cost float64
+	}
+	tests := []struct {
+		name string
+		adds []add
+		repo string
+		at   time.Time
+		want float64
+	}{
+		{name: "nothing spent", repo: "acme/app", at: march},
+		{name: "adds up", adds: []add{{"acme/app", march, 1.5}, {"acme/app", march, 0.25}}, repo: "acme/app", at: march, want: 1.75},
+		{name: "per repository", adds: []add{{"acme/app", march, 1}, {"acme/web", march, 2}}, repo: "acme/web", at: march, want: 2},
+		{name: "case-insensitive", adds: []add{{"Acme/App", march, 1}}, repo: "acme/app", at: march, want: 1},
+		{name: "per month", adds: []add{{"acme/app", march, 1}, {"acme/app", april, 2}}, repo: "acme/app", at: april, want: 2},
+		{name: "months in UTC", adds: []add{{"acme/app", auckland, 1}}, repo: "acme/app", at: march, want: 1},
+		{name: "no refunds", adds: []add{{"acme/app", march, 1}, {"acme/app", march, -1}, {"acme/app", march, 0}}, repo: "acme/app", at: march, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := Open(filepath.Join(t.TempDir(), "budget"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, a := range tt.adds {
+				if err := l.Add(a.repo, a.at, a.cost); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := l.Spent(tt.repo, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Spent(%s) = %v, want %v", tt.repo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLedgerPersists(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Add("acme/app", now, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Spent("acme/app", now); got != 3 || err != nil {
+		t.Errorf("Spent after reopening = %v, %v, want 3", got, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || strings.HasPrefix(entries[0].Name(), ".tmp-") {
+		t.Errorf("ledger directory holds %v, want only the month's file", entries)
+	}
+}
+
+func TestLedgerCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(l.path(now), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Spent("acme/app", now); err == nil {
+		t.Error("Spent read a corrupt ledger without error")
+	}
+	if err := l.Add("acme/app", now, 1); err == nil {
+		t.Error("Add overwrote a corrupt ledger")
+	}
+}
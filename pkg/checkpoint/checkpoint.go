@@ -0,0 +1,81 @@
+// Package checkpoint persists per-chunk review results so that a re-run
+// after a partial failure only reviews the chunks that did not succeed.
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Store holds the reviews of chunks that have already succeeded for a
+// particular diff.
+type Store struct {
+	path    string
+	reviews map[string]string
+}
+
+// Open loads the checkpoint for key from dir, or starts an empty one if
+// none exists.
+func Open(dir, key string) (*Store, error) {
+	s := &Store{
+		path:    filepath.Join(dir, "checkpoint-"+hash(key)[:16]+".json"),
+		reviews: map[string]string{},
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.reviews); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	return s, nil
+}
+
+// Len returns the number of saved chunk reviews.
+func (s *Store) Len() int {
+	return len(s.reviews)
+}
+
+// Get returns the saved review of chunk, if any.
+func (s *Store) Get(chunk string) (string, bool) {
+	review, ok := s.reviews[hash(chunk)]
+	return review, ok
+}
+
+// Put records the review of chunk and writes the checkpoint to disk.
+func (s *Store) Put(chunk, review string) error {
+	s.reviews[hash(chunk)] = review
+
+	data, err := json.Marshal(s.reviews)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the checkpoint once the review has completed.
+func (s *Store) Remove() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
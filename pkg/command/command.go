@@ -0,0 +1,140 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Prefix is the trigger that starts every repo-ranger slash command.
+const Prefix = "/ranger"
+
+// Command is a parsed slash command such as
+// `/ranger review --focus "error handling"`.
+type Command struct {
+	Name  string
+	Args  []string
+	Flags map[string]string
+}
+
+// Flag returns the value of a flag, or the empty string if it was not given.
+func (c Command) Flag(name string) string {
+	return c.Flags[name]
+}
+
+// Parse looks for a repo-ranger slash command on the first non-empty line
+// of a comment body. It returns false when the comment is not a command.
+func Parse(body string) (Command, bool) {
+	var line string
+	for _, l := range strings.Split(body, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			line = l
+			break
+		}
+	}
+
+	tokens := tokenize(line)
+	if len(tokens) < 2 || tokens[0] != Prefix {
+		return Command{}, false
+	}
+
+	cmd := Command{Name: strings.ToLower(tokens[1]), Flags: map[string]string{}}
+	for i := 2; i < len(tokens); i++ {
+		tok := tokens[i]
+		if !strings.HasPrefix(tok, "--") {
+			cmd.Args = append(cmd.Args, tok)
+			continue
+		}
+		name := strings.TrimPrefix(tok, "--")
+		if key, value, ok := strings.Cut(name, "="); ok {
+			cmd.Flags[key] = value
+			continue
+		}
+		if i+1 < len(tokens) && !strings.HasPrefix(tokens[i+1], "--") {
+			cmd.Flags[name] = tokens[i+1]
+			i++
+			continue
+		}
+		cmd.Flags[name] = "true"
+	}
+	return cmd, true
+}
+
+// tokenize splits a line on whitespace, keeping single- or double-quoted
+// sections together.
+func tokenize(line string) []string {
+	var tokens []string
+	var cur strings.Builder
+	var quote rune
+	inToken := false
+
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inToken = true
+		case r == ' ' || r == '\t':
+			if inToken {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+		default:
+			cur.WriteRune(r)
+			inToken = true
+		}
+	}
+	if inToken {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens
+}
+
+// Target is a file reference with an optional line range, written as
+// "path", "path:12", or "path:12-40".
+type Target struct {
+	Path  string
+	Start int
+	End   int
+}
+
+// ParseTarget parses a file reference argument.
+func ParseTarget(arg string) Target {
+	path, lines, ok := strings.Cut(arg, ":")
+	if !ok {
+		return Target{Path: arg}
+	}
+	t := Target{Path: path}
+	startStr, endStr, isRange := strings.Cut(lines, "-")
+	start, err := strconv.Atoi(startStr)
+	if err != nil || start < 1 {
+		return Target{Path: arg}
+	}
+	t.Start, t.End = start, start
+	if isRange {
+		if end, err := strconv.Atoi(endStr); err == nil && end >= start {
+			t.End = end
+		}
+	}
+	return t
+}
+
+// HasRange reports whether the target names specific lines.
+func (t Target) HasRange() bool {
+	return t.Start > 0
+}
+
+// String renders the target back into its argument form.
+func (t Target) String() string {
+	switch {
+	case !t.HasRange():
+		return t.Path
+	case t.Start == t.End:
+		return fmt.Sprintf("%s:%d", t.Path, t.Start)
+	}
+	return fmt.Sprintf("%s:%d-%d", t.Path, t.Start, t.End)
+}
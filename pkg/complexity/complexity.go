@@ -0,0 +1,172 @@
+package complexity
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Function holds size and complexity metrics for a function touched by a
+// change, alongside its metrics before the change when it already existed.
+type Function struct {
+	File           string
+	Name           string
+	Line           int
+	Lines          int
+	Complexity     int
+	PrevLines      int
+	PrevComplexity int
+	IsNew          bool
+}
+
+// Grew reports whether the function got longer or more complex.
+func (f Function) Grew() bool {
+	return !f.IsNew && (f.Lines > f.PrevLines || f.Complexity > f.PrevComplexity)
+}
+
+type funcInfo struct {
+	name       string
+	start, end int
+	complexity int
+}
+
+// Analyze computes metrics for the functions in a Go file that contain any
+// of the touched lines. before may be empty for newly added files.
+func Analyze(path, before, after string, touched []int) ([]Function, error) {
+	current, err := parseFuncs(path, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	previous := map[string]funcInfo{}
+	if before != "" {
+		// A previous version that no longer parses is not worth failing over;
+		// its functions are simply treated as new.
+		if funcs, err := parseFuncs(path, before); err == nil {
+			for _, fn := range funcs {
+				previous[fn.name] = fn
+			}
+		}
+	}
+
+	var result []Function
+	for _, fn := range current {
+		if !containsAny(fn.start, fn.end, touched) {
+			continue
+		}
+		f := Function{
+			File:       path,
+			Name:       fn.name,
+			Line:       fn.start,
+			Lines:      fn.end - fn.start + 1,
+			Complexity: fn.complexity,
+		}
+		if prev, ok := previous[fn.name]; ok {
+			f.PrevLines = prev.end - prev.start + 1
+			f.PrevComplexity = prev.complexity
+		} else {
+			f.IsNew = true
+		}
+		result = append(result, f)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Line < result[j].Line })
+	return result, nil
+}
+
+func parseFuncs(path, src string) ([]funcInfo, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var funcs []funcInfo
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		funcs = append(funcs, funcInfo{
+			name:       funcName(fn),
+			start:      fset.Position(fn.Pos()).Line,
+			end:        fset.Position(fn.End()).Line,
+			complexity: cyclomatic(fn),
+		})
+	}
+	return funcs, nil
+}
+
+// funcName returns "Name" or "(Recv).Name" for methods.
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	switch t := recv.(type) {
+	case *ast.IndexExpr:
+		recv = t.X
+	case *ast.IndexListExpr:
+		recv = t.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return fmt.Sprintf("(%s).%s", ident.Name, fn.Name.Name)
+	}
+	return fn.Name.Name
+}
+
+// cyclomatic computes the cyclomatic complexity of a function: one plus the
+// number of decision points.
+func cyclomatic(fn *ast.FuncDecl) int {
+	complexity := 1
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			complexity++
+		case *ast.CaseClause:
+			if n.List != nil {
+				complexity++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				complexity++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				complexity++
+			}
+		}
+		return true
+	})
+	return complexity
+}
+
+func containsAny(start, end int, lines []int) bool {
+	for _, l := range lines {
+		if l >= start && l <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe renders a one-line summary such as
+// "(client).Review: 40 → 220 lines, complexity 5 → 18".
+func (f Function) Describe() string {
+	if f.IsNew {
+		return fmt.Sprintf("%s (new): %d lines, complexity %d", f.Name, f.Lines, f.Complexity)
+	}
+	return fmt.Sprintf("%s: %d → %d lines, complexity %d → %d",
+		f.Name, f.PrevLines, f.Lines, f.PrevComplexity, f.Complexity)
+}
+
+// IsGoFile reports whether path is a Go source file.
+func IsGoFile(path string) bool {
+	return strings.HasSuffix(path, ".go")
+}
@@ -0,0 +1,111 @@
+package complexity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	const before = `package p
+
+func Plain() {}
+
+func (c *Client) Review(ok bool) {
+	if ok {
+		return
+	}
+}
+`
+	const after = `package p
+
+func Plain() {}
+
+func (c *Client) Review(ok bool) {
+	if ok && c != nil {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		switch i {
+		case 1:
+		default:
+		}
+	}
+}
+
+func (s *Set[T]) Add(v T) {}
+`
+
+	tests := []struct {
+		name    string
+		before  string
+		after   string
+		touched []int
+		want    []string
+		err     bool
+	}{
+		{
+			name:    "grown method",
+			before:  before,
+			after:   after,
+			touched: []int{6},
+			want:    []string{"(Client).Review: 5 → 11 lines, complexity 2 → 5"},
+		},
+		{
+			name:    "new generic method",
+			before:  before,
+			after:   after,
+			touched: []int{17},
+			want:    []string{"(Set).Add (new): 1 lines, complexity 1"},
+		},
+		{
+			name:    "new file",
+			after:   after,
+			touched: []int{3, 17},
+			want:    []string{"Plain (new): 1 lines, complexity 1", "(Set).Add (new): 1 lines, complexity 1"},
+		},
+		{
+			name:    "unparsable previous version",
+			before:  "package p\nfunc {",
+			after:   after,
+			touched: []int{3},
+			want:    []string{"Plain (new): 1 lines, complexity 1"},
+		},
+		{name: "untouched", before: before, after: after, touched: []int{1}},
+		{name: "unparsable", after: "package p\nfunc {", touched: []int{2}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			funcs, err := Analyze("p.go", tt.before, tt.after, tt.touched)
+			if (err != nil) != tt.err {
+				t.Fatalf("Analyze() error = %v, want error %v", err, tt.err)
+			}
+			var got []string
+			for _, f := range funcs {
+				got = append(got, f.Describe())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Analyze() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrew(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   Function
+		want bool
+	}{
+		{"longer", Function{Lines: 20, PrevLines: 10, Complexity: 2, PrevComplexity: 2}, true},
+		{"more complex", Function{Lines: 10, PrevLines: 10, Complexity: 3, PrevComplexity: 2}, true},
+		{"shrank", Function{Lines: 5, PrevLines: 10, Complexity: 1, PrevComplexity: 2}, false},
+		{"new", Function{Lines: 50, Complexity: 9, IsNew: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn.Grew(); got != tt.want {
+				t.Errorf("Grew() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+// Package config loads the optional repository configuration file.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/retry"
+)
+
+// DefaultPath is where the configuration file is looked up when no path is
+// configured.
+const DefaultPath = ".repo-ranger.yml"
+
+// Config is the contents of the repository configuration file.
+type Config struct {
+	// Scopes split a single run into several independent reviews, each
+	// covering the files matched by its paths.
+	Scopes []Scope `yaml:"scopes"`
+
+	// Priorities weight the risk of the files matching their paths when a
+	// token budget leaves some files only summarized.
+	Priorities []Priority `yaml:"priorities"`
+
+	// Retry tunes the retry policy of each outbound API.
+	Retry struct {
+		API    RetryPolicy `yaml:"api"`
+		GitHub RetryPolicy `yaml:"github"`
+	} `yaml:"retry"`
+
+	// Categories switch whole categories of findings off or to warnings,
+	// keyed by category; see the CategoryMode constants.
+	Categories map[string]string `yaml:"categories"`
+
+	// Labels map finding categories to pull request labels, added when a
+	// review has findings in the category.
+	Labels map[string]string `yaml:"labels"`
+
+	// PostProcessor is an executable that receives the findings as JSON on
+	// stdin and writes the findings to post on stdout.
+	PostProcessor PostProcessor `yaml:"postprocessor"`
+
+	// Analyzers are external executables run as deterministic checks,
+	// whose findings join those of the built-in checks.
+	Analyzers []Analyzer `yaml:"analyzers"`
+
+	// Nudge configures the reminders the nudge command leaves on stale
+	// pull requests.
+	Nudge Nudge `yaml:"nudge"`
+
+	// Egress keeps confidential paths from the review model.
+	Egress Egress `yaml:"egress"`
+
+	// FeatureFlags turns on the feature flag analyzer for the flags
+	// defined in its files.
+	FeatureFlags FeatureFlags `yaml:"feature_flags"`
+}
+
+// FeatureFlags configures the feature flag analyzer, which reports flags
+// added or removed by a change and references to flags that are not
+// defined.
+type FeatureFlags struct {
+	// Files are globs of the JSON, YAML, or key=value files defining the
+	// repository's flags. The analyzer runs when any are given.
+	Files []string `yaml:"files"`
+	// References are regular expressions matching a use of a flag in code,
+	// whose first group is the flag's name. Empty uses the analyzer's
+	// defaults, which match the calls of common flag SDKs.
+	References []string `yaml:"references"`
+}
+
+// Egress decides which files may be sent to the review model. Files
+// matching a rule go to the rule's provider instead, such as a model
+// hosted in-house, or, when the rule names none, are not reviewed at all.
+type Egress struct {
+	// Providers are the models rules can pin files to, keyed by name.
+	Providers map[string]Provider `yaml:"providers"`
+	// Rules are applied in order; the first matching a file decides
+	// where it goes.
+	Rules []EgressRule `yaml:"rules"`
+}
+
+// Provider is an OpenAI-compatible API serving a model.
+type Provider struct {
+	APIURL string `yaml:"api_url"`
+	// APIPath overrides the path of the chat completions endpoint.
+	APIPath string `yaml:"api_path"`
+	// APIKeyEnv names the environment variable holding the API key, so
+	// keys stay out of the repository. Local servers may need none.
+	APIKeyEnv string `yaml:"api_key_env"`
+	Model     string `yaml:"model"`
+}
+
+// EgressRule pins the files matching its paths to a provider, or, when
+// Provider is empty, keeps them from every model.
+type EgressRule struct {
+	Paths    []string `yaml:"paths"`
+	Provider string   `yaml:"provider"`
+}
+
+// Matches reports whether path falls under the rule.
+func (r EgressRule) Matches(path string) bool {
+	for _, pattern := range r.Paths {
+		if MatchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Nudge configures reminders on stale pull requests.
+type Nudge struct {
+	// Disabled leaves the repository's pull requests alone.
+	Disabled bool `yaml:"disabled"`
+	// Days without updates make a pull request stale; 0 keeps the
+	// command's default.
+	Days int `yaml:"days"`
+	// Drafts nudges draft pull requests too.
+	Drafts bool `yaml:"drafts"`
+	// Message opens the nudge in place of the default greeting.
+	Message string `yaml:"message"`
+}
+
+// Modes a category of findings can be switched to.
+const (
+	// CategoryOn reports findings as usual.
+	CategoryOn = "on"
+	// CategoryOff neither asks for nor reports findings.
+	CategoryOff = "off"
+	// CategoryWarnOnly reports findings as non-blocking warnings in the
+	// summary instead of as comments.
+	CategoryWarnOnly = "warn-only"
+)
+
+// PostProcessor configures the findings post-processor.
+type PostProcessor struct {
+	Command string        `yaml:"command"`
+	Args    []string      `yaml:"args"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Analyzer configures an external analyzer.
+type Analyzer struct {
+	Name    string        `yaml:"name"`
+	Command string        `yaml:"command"`
+	Args    []string      `yaml:"args"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RetryPolicy overrides parts of a default retry policy. Unset fields keep
+// the default.
+type RetryPolicy struct {
+	Attempts        int           `yaml:"attempts"`
+	BaseDelay       time.Duration `yaml:"base_delay"`
+	MaxDelay        time.Duration `yaml:"max_delay"`
+	RetryableStatus []int         `yaml:"retryable_status"`
+}
+
+// Apply returns def with the configured fields overridden.
+func (r RetryPolicy) Apply(def retry.Policy) retry.Policy {
+	if r.Attempts > 0 {
+		def.Attempts = r.Attempts
+	}
+	if r.BaseDelay > 0 {
+		def.BaseDelay = r.BaseDelay
+	}
+	if r.MaxDelay > 0 {
+		def.MaxDelay = r.MaxDelay
+	}
+	if len(r.RetryableStatus) > 0 {
+		def.RetryableStatus = r.RetryableStatus
+	}
+	return def
+}
+
+// Scope is a named subset of the diff reviewed with its own settings.
+type Scope struct {
+	Name  string   `yaml:"name"`
+	Paths []string `yaml:"paths"`
+	Depth string   `yaml:"depth"`
+	Focus string   `yaml:"focus"`
+}
+
+// Priority scales the review priority of the files matching its paths.
+// A weight above 1 reviews them sooner, below 1 later, and 0 only ever
+// summarizes them under a token budget.
+type Priority struct {
+	Paths  []string `yaml:"paths"`
+	Weight *float64 `yaml:"weight"`
+}
+
+// Load reads the configuration file at path. A missing file yields an empty
+// configuration. Any error-level problem Validate would report fails the load.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return Parse(path, data)
+}
+
+// Parse reads a configuration file's contents, such as one fetched from
+// another repository; path names it in errors.
+func Parse(path string, data []byte) (Config, error) {
+	cfg, problems := decode(path, data)
+	for _, p := range problems {
+		if !p.Warning {
+			return cfg, fmt.Errorf("invalid config file: %s: %s", p.Field, p.Message)
+		}
+	}
+	return cfg, nil
+}
+
+// Matches reports whether path falls within the scope.
+func (s Scope) Matches(path string) bool {
+	for _, pattern := range s.Paths {
+		if MatchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPath reports whether path matches a glob pattern. "*" and "?" match
+// within a single path segment and "**" matches across segments.
+func MatchPath(pattern, path string) bool {
+	re, err := regexp.Compile(globToRegexp(pattern))
+	if err != nil {
+		return false
+	}
+	return re.MatchString(path)
+}
+
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is what an organization mandates for all of its repositories,
+// under policies in repo-ranger.yml in its .github repository. A
+// repository's configuration may extend a policy but not override it.
+type Policy struct {
+	// Categories set the modes of categories, which repositories may not
+	// change; they may set the modes of other categories.
+	Categories map[string]string `yaml:"categories"`
+	// RequiredCategories may never be switched off or to warn-only.
+	RequiredCategories []string `yaml:"required_categories"`
+	// Labels map categories to labels, which repositories may not remap;
+	// they may label other categories.
+	Labels map[string]string `yaml:"labels"`
+	// MaxTemperature caps the model temperature.
+	MaxTemperature *float64 `yaml:"max_temperature"`
+}
+
+// ParsePolicy reads the policies section of an organization's
+// repo-ranger.yml. Other sections of the file are ignored.
+func ParsePolicy(data []byte) (Policy, []Problem) {
+	var policy Policy
+	var file struct {
+		Policies yaml.Node `yaml:"policies"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return policy, []Problem{{Field: "policies", Message: strings.TrimPrefix(err.Error(), "yaml: ")}}
+	}
+	if file.Policies.Kind == 0 {
+		return policy, nil
+	}
+	// Decode the section strictly, so misspelled policies are reported
+	// rather than silently not enforced.
+	section, err := yaml.Marshal(&file.Policies)
+	if err != nil {
+		return policy, []Problem{{Field: "policies", Message: err.Error()}}
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(section))
+	dec.KnownFields(true)
+	if err := dec.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return policy, []Problem{{Field: "policies", Message: strings.TrimPrefix(err.Error(), "yaml: ")}}
+	}
+
+	var problems []Problem
+	for category, mode := range policy.Categories {
+		if !types.Category(category).Valid() {
+			problems = append(problems, Problem{Field: "policies.categories." + category, Message: "unknown category; use bug, security, privacy, performance, style, tests, docs, or maintainability"})
+		}
+		switch mode {
+		case CategoryOn, CategoryOff, CategoryWarnOnly:
+		default:
+			problems = append(problems, Problem{Field: "policies.categories." + category, Message: fmt.Sprintf("unknown mode %q; use on, off, or warn-only", mode)})
+		}
+	}
+	for i, category := range policy.RequiredCategories {
+		field := fmt.Sprintf("policies.required_categories[%d]", i)
+		if !types.Category(category).Valid() {
+			problems = append(problems, Problem{Field: field, Message: fmt.Sprintf("unknown category %q; use bug, security, privacy, performance, style, tests, docs, or maintainability", category)})
+		}
+		if mode := policy.Categories[category]; mode != "" && mode != CategoryOn {
+			problems = append(problems, Problem{Field: field, Message: fmt.Sprintf("%s is required but policies.categories sets it to %s", category, mode)})
+		}
+	}
+	for category, label := range policy.Labels {
+		if !types.Category(category).Valid() {
+			problems = append(problems, Problem{Field: "policies.labels." + category, Message: "unknown category; use bug, security, privacy, performance, style, tests, docs, or maintainability"})
+		}
+		if strings.TrimSpace(label) == "" {
+			problems = append(problems, Problem{Field: "policies.labels." + category, Message: "label name is empty"})
+		}
+	}
+	if t := policy.MaxTemperature; t != nil && (*t < 0 || *t > 2) {
+		problems = append(problems, Problem{Field: "policies.max_temperature", Message: "must be between 0 and 2"})
+	}
+	return policy, problems
+}
+
+// Enforce applies the policy to a repository's configuration. Settings
+// the policy fixes replace the repository's, and each one the repository
+// tried to change is reported as a warning.
+func (p Policy) Enforce(cfg Config) (Config, []Problem) {
+	var problems []Problem
+	overridden := func(field, message string) {
+		problems = append(problems, Problem{Field: field, Message: message + "; the organization policy takes precedence", Warning: true})
+	}
+
+	categories := map[string]string{}
+	for category, mode := range cfg.Categories {
+		categories[category] = mode
+	}
+	for _, category := range sortedKeys(p.Categories) {
+		mode := p.Categories[category]
+		if repo, ok := categories[category]; ok && repo != mode {
+			overridden("categories."+category, fmt.Sprintf("the organization sets %s to %s", category, mode))
+		}
+		categories[category] = mode
+	}
+	for _, category := range p.RequiredCategories {
+		if mode := categories[category]; mode != "" && mode != CategoryOn {
+			overridden("categories."+category, fmt.Sprintf("the organization requires %s findings", category))
+			categories[category] = CategoryOn
+		}
+	}
+	if len(categories) > 0 {
+		cfg.Categories = categories
+	}
+
+	labels := map[string]string{}
+	for category, label := range cfg.Labels {
+		labels[category] = label
+	}
+	for _, category := range sortedKeys(p.Labels) {
+		label := p.Labels[category]
+		if repo, ok := labels[category]; ok && repo != label {
+			overridden("labels."+category, fmt.Sprintf("the organization labels %s findings %q", category, label))
+		}
+		labels[category] = label
+	}
+	if len(labels) > 0 {
+		cfg.Labels = labels
+	}
+	return cfg, problems
+}
+
+// Temperature caps a model temperature at the policy's maximum, reporting
+// whether it was lowered.
+func (p Policy) Temperature(t float64) (float64, bool) {
+	if p.MaxTemperature != nil && t > *p.MaxTemperature {
+		return *p.MaxTemperature, true
+	}
+	return t, false
+}
+
+// LoadWithPolicy is Load with an organization policy enforced on the
+// result. The problems are the settings the policy overrode.
+func LoadWithPolicy(path string, policy Policy) (Config, []Problem, error) {
+	cfg, err := Load(path)
+	if err != nil {
+		return cfg, nil, err
+	}
+	cfg, problems := policy.Enforce(cfg)
+	return cfg, problems, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// Profile is a curated set of defaults selected with a single input.
+// Individually set inputs still override it.
+type Profile struct {
+	ReviewDepth       string
+	MaxInlineComments int
+	// MinSeverity drops inline findings below this severity.
+	MinSeverity types.Severity
+	// Tone is added to the prompt to set the register of the review.
+	Tone string
+}
+
+// Profiles are the built-in presets, keyed by name.
+var Profiles = map[string]Profile{
+	"strict": {
+		ReviewDepth:       "deep",
+		MaxInlineComments: 50,
+		MinSeverity:       types.SeverityNit,
+		Tone:              "Be rigorous and direct. Flag every deviation from best practice, including nits, and do not soften findings.",
+	},
+	"balanced": {
+		ReviewDepth:       "standard",
+		MaxInlineComments: 25,
+		MinSeverity:       types.SeverityMinor,
+		Tone:              "Be constructive and concise. Focus on issues that affect correctness, security, or maintainability and skip matters of taste.",
+	},
+	"lenient": {
+		ReviewDepth:       "standard",
+		MaxInlineComments: 10,
+		MinSeverity:       types.SeverityMajor,
+		Tone:              "Be encouraging. Only raise issues that would cause bugs, security problems, or significant maintenance cost.",
+	},
+}
+
+// LookupProfile returns the named profile, ignoring case.
+func LookupProfile(name string) (Profile, bool) {
+	p, ok := Profiles[strings.ToLower(strings.TrimSpace(name))]
+	return p, ok
+}
+
+// ProfileNames returns the names of the built-in profiles, sorted.
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
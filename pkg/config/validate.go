@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is an issue found while validating the configuration.
+type Problem struct {
+	// Field names the offending input or configuration key.
+	Field   string
+	Message string
+	// Warning problems do not stop a run.
+	Warning bool
+}
+
+func (p Problem) String() string {
+	level := "error"
+	if p.Warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%s: %s: %s", level, p.Field, p.Message)
+}
+
+// HasErrors reports whether any problem is not a warning.
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if !p.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the configuration file at path for syntax errors, unknown
+// keys, and invalid values. A missing file is valid.
+func Validate(path string) []Problem {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return []Problem{{Field: path, Message: err.Error()}}
+	}
+	_, problems := decode(path, data)
+	return problems
+}
+
+// decode parses the configuration strictly, so that misspelled keys are
+// reported rather than silently ignored.
+func decode(path string, data []byte) (Config, []Problem) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return cfg, []Problem{{
+			Field:   path,
+			Message: strings.TrimPrefix(err.Error(), "yaml: ") + " (check the key names against the README)",
+		}}
+	}
+
+	var problems []Problem
+	names := map[string]bool{}
+	for i, s := range cfg.Scopes {
+		field := fmt.Sprintf("scopes[%d]", i)
+		if s.Name == "" {
+			problems = append(problems, Problem{Field: field + ".name", Message: "every scope needs a name; it titles the scope's section and check run"})
+		} else if names[s.Name] {
+			problems = append(problems, Problem{Field: field + ".name", Message: fmt.Sprintf("scope name %q is used more than once", s.Name)})
+		}
+		names[s.Name] = true
+
+		if len(s.Paths) == 0 {
+			problems = append(problems, Problem{Field: field + ".paths", Message: "list at least one glob, e.g. \"src/**\""})
+		}
+		for j, pattern := range s.Paths {
+			if msg := checkGlob(pattern); msg != "" {
+				problems = append(problems, Problem{Field: fmt.Sprintf("%s.paths[%d]", field, j), Message: msg})
+			}
+		}
+
+		switch strings.ToLower(s.Depth) {
+		case "", "summary", "standard", "deep":
+		default:
+			problems = append(problems, Problem{Field: field + ".depth", Message: fmt.Sprintf("unknown depth %q; use summary, standard, or deep", s.Depth)})
+		}
+	}
+
+	for i, p := range cfg.Priorities {
+		field := fmt.Sprintf("priorities[%d]", i)
+		if len(p.Paths) == 0 {
+			problems = append(problems, Problem{Field: field + ".paths", Message: "list at least one glob, e.g. \"internal/auth/**\""})
+		}
+		for j, pattern := range p.Paths {
+			if msg := checkGlob(pattern); msg != "" {
+				problems = append(problems, Problem{Field: fmt.Sprintf("%s.paths[%d]", field, j), Message: msg})
+			}
+		}
+		if p.Weight == nil {
+			problems = append(problems, Problem{Field: field + ".weight", Message: "required, e.g. 3 to review matching files first or 0 to only summarize them"})
+		} else if *p.Weight < 0 {
+			problems = append(problems, Problem{Field: field + ".weight", Message: "must not be negative"})
+		}
+	}
+
+	for category, mode := range cfg.Categories {
+		if !types.Category(category).Valid() {
+			problems = append(problems, Problem{Field: "categories." + category, Message: "unknown category; use bug, security, privacy, performance, style, tests, docs, or maintainability"})
+		}
+		switch mode {
+		case CategoryOn, CategoryOff, CategoryWarnOnly:
+		default:
+			problems = append(problems, Problem{Field: "categories." + category, Message: fmt.Sprintf("unknown mode %q; use on, off, or warn-only", mode)})
+		}
+	}
+	for category, label := range cfg.Labels {
+		if !types.Category(category).Valid() {
+			problems = append(problems, Problem{Field: "labels." + category, Message: "unknown category; use bug, security, privacy, performance, style, tests, docs, or maintainability"})
+		}
+		if strings.TrimSpace(label) == "" {
+			problems = append(problems, Problem{Field: "labels." + category, Message: "label name is empty"})
+		}
+	}
+
+	for name, r := range map[string]RetryPolicy{"retry.api": cfg.Retry.API, "retry.github": cfg.Retry.GitHub} {
+		if r.Attempts < 0 {
+			problems = append(problems, Problem{Field: name + ".attempts", Message: "must not be negative"})
+		}
+		if r.MaxDelay > 0 && r.BaseDelay > r.MaxDelay {
+			problems = append(problems, Problem{Field: name + ".base_delay", Message: "is longer than max_delay, so max_delay always applies", Warning: true})
+		}
+		for _, code := range r.RetryableStatus {
+			if code < 100 || code > 599 {
+				problems = append(problems, Problem{Field: name + ".retryable_status", Message: fmt.Sprintf("%d is not an HTTP status code", code)})
+			}
+		}
+	}
+	if pp := cfg.PostProcessor; pp.Command == "" && (len(pp.Args) > 0 || pp.Timeout != 0) {
+		problems = append(problems, Problem{Field: "postprocessor.command", Message: "required when other postprocessor settings are given"})
+	} else if pp.Timeout < 0 {
+		problems = append(problems, Problem{Field: "postprocessor.timeout", Message: "must not be negative"})
+	}
+	analyzers := map[string]bool{}
+	for i, a := range cfg.Analyzers {
+		field := fmt.Sprintf("analyzers[%d]", i)
+		switch {
+		case strings.TrimSpace(a.Name) == "":
+			problems = append(problems, Problem{Field: field + ".name", Message: "required"})
+		case analyzers[a.Name]:
+			problems = append(problems, Problem{Field: field + ".name", Message: fmt.Sprintf("%q is already used by another analyzer", a.Name)})
+		}
+		analyzers[a.Name] = true
+		if a.Command == "" {
+			problems = append(problems, Problem{Field: field + ".command", Message: "required"})
+		}
+		if a.Timeout < 0 {
+			problems = append(problems, Problem{Field: field + ".timeout", Message: "must not be negative"})
+		}
+	}
+	if cfg.Nudge.Days < 0 {
+		problems = append(problems, Problem{Field: "nudge.days", Message: "must not be negative"})
+	}
+	for name, p := range cfg.Egress.Providers {
+		field := "egress.providers." + name
+		if p.APIURL == "" {
+			problems = append(problems, Problem{Field: field + ".api_url", Message: "required"})
+		}
+		if p.Model == "" {
+			problems = append(problems, Problem{Field: field + ".model", Message: "required"})
+		}
+	}
+	for i, r := range cfg.Egress.Rules {
+		field := fmt.Sprintf("egress.rules[%d]", i)
+		if len(r.Paths) == 0 {
+			problems = append(problems, Problem{Field: field + ".paths", Message: "list at least one glob, e.g. \"crypto/**\""})
+		}
+		for j, pattern := range r.Paths {
+			if msg := checkGlob(pattern); msg != "" {
+				problems = append(problems, Problem{Field: fmt.Sprintf("%s.paths[%d]", field, j), Message: msg})
+			}
+		}
+		if _, ok := cfg.Egress.Providers[r.Provider]; r.Provider != "" && !ok {
+			problems = append(problems, Problem{Field: field + ".provider", Message: fmt.Sprintf("unknown provider %q; define it under egress.providers, or leave provider out to keep the files from every model", r.Provider)})
+		}
+	}
+	for i, pattern := range cfg.FeatureFlags.Files {
+		if msg := checkGlob(pattern); msg != "" {
+			problems = append(problems, Problem{Field: fmt.Sprintf("feature_flags.files[%d]", i), Message: msg})
+		}
+	}
+	if len(cfg.FeatureFlags.Files) == 0 && len(cfg.FeatureFlags.References) > 0 {
+		problems = append(problems, Problem{Field: "feature_flags.files", Message: "required when references are given"})
+	}
+	for i, ref := range cfg.FeatureFlags.References {
+		field := fmt.Sprintf("feature_flags.references[%d]", i)
+		if re, err := regexp.Compile(ref); err != nil {
+			problems = append(problems, Problem{Field: field, Message: fmt.Sprintf("invalid regular expression: %v", err)})
+		} else if re.NumSubexp() == 0 {
+			problems = append(problems, Problem{Field: field, Message: "needs a group capturing the flag's name, e.g. isEnabled\\(\"([^\"]+)\"\\)"})
+		}
+	}
+	return cfg, problems
+}
+
+// checkGlob returns why pattern can never match a diff path, or "".
+func checkGlob(pattern string) string {
+	switch {
+	case pattern == "":
+		return "empty pattern"
+	case strings.HasPrefix(pattern, "/"), strings.HasPrefix(pattern, "./"):
+		return fmt.Sprintf("%q will never match; paths are relative to the repository root, so drop the leading %q", pattern, pattern[:strings.Index(pattern, "/")+1])
+	case strings.Contains(pattern, "\\"):
+		return fmt.Sprintf("%q will never match; use forward slashes in paths", pattern)
+	}
+	if _, err := regexp.Compile(globToRegexp(pattern)); err != nil {
+		return fmt.Sprintf("invalid pattern %q: %v", pattern, err)
+	}
+	return ""
+}
@@ -0,0 +1,261 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Profile maps source files to per-line coverage. Only instrumented lines
+// are present; a line maps to true when it was executed at least once.
+type Profile map[string]map[int]bool
+
+// Load reads a coverage report from path. Go coverprofiles (starting with a
+// "mode:" line) and lcov tracefiles are supported.
+func Load(path string) (Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coverage report: %w", err)
+	}
+
+	content := string(data)
+	if strings.HasPrefix(strings.TrimSpace(content), "mode:") {
+		return parseGoProfile(content)
+	}
+	return parseLCOV(content)
+}
+
+func parseGoProfile(content string) (Profile, error) {
+	profile := Profile{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+
+		// file.go:startLine.startCol,endLine.endCol numStmts count
+		file, rest, ok := strings.Cut(line, ":")
+		fields := strings.Fields(rest)
+		if !ok || len(fields) != 3 {
+			return nil, fmt.Errorf("malformed coverprofile line: %q", line)
+		}
+		start, end, ok := strings.Cut(fields[0], ",")
+		if !ok {
+			return nil, fmt.Errorf("malformed coverprofile block: %q", fields[0])
+		}
+		startLine, err := strconv.Atoi(strings.Split(start, ".")[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed coverprofile block: %q", fields[0])
+		}
+		endLine, err := strconv.Atoi(strings.Split(end, ".")[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed coverprofile block: %q", fields[0])
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed coverprofile count: %q", fields[2])
+		}
+
+		lines := profile[file]
+		if lines == nil {
+			lines = map[int]bool{}
+			profile[file] = lines
+		}
+		for l := startLine; l <= endLine; l++ {
+			lines[l] = lines[l] || count > 0
+		}
+	}
+	return profile, scanner.Err()
+}
+
+func parseLCOV(content string) (Profile, error) {
+	profile := Profile{}
+	var lines map[int]bool
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			file := strings.TrimPrefix(line, "SF:")
+			lines = profile[file]
+			if lines == nil {
+				lines = map[int]bool{}
+				profile[file] = lines
+			}
+		case strings.HasPrefix(line, "DA:") && lines != nil:
+			parts := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(parts) < 2 {
+				return nil, fmt.Errorf("malformed lcov line: %q", line)
+			}
+			n, err := strconv.Atoi(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("malformed lcov line: %q", line)
+			}
+			hits, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("malformed lcov line: %q", line)
+			}
+			lines[n] = lines[n] || hits > 0
+		case line == "end_of_record":
+			lines = nil
+		}
+	}
+	if len(profile) == 0 {
+		return nil, fmt.Errorf("coverage report is neither a Go coverprofile nor lcov")
+	}
+	return profile, scanner.Err()
+}
+
+// Lookup returns the coverage for a repository-relative path. Reports often
+// use import paths or absolute paths, so the file is matched by suffix on a
+// path boundary. A report file that is the path under a prefix every report
+// file shares, such as the module path or the checkout directory, wins;
+// otherwise the file sharing the most trailing path elements does. When
+// several files tie, the path is treated as missing from the report rather
+// than reading another file's coverage.
+func (p Profile) Lookup(path string) (map[int]bool, bool) {
+	path = filepath.ToSlash(path)
+	if lines, ok := p[path]; ok {
+		return lines, true
+	}
+
+	var rooted, longest []string
+	best := 0
+	for file := range p {
+		slashed := filepath.ToSlash(file)
+		if !strings.HasSuffix(slashed, "/"+path) && !strings.HasSuffix(path, "/"+slashed) {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(slashed, "/"+path); ok && p.allUnder(prefix) {
+			rooted = append(rooted, file)
+		}
+		switch n := sharedElements(slashed, path); {
+		case n > best:
+			best, longest = n, []string{file}
+		case n == best:
+			longest = append(longest, file)
+		}
+	}
+	switch {
+	case len(rooted) == 1:
+		return p[rooted[0]], true
+	case len(rooted) == 0 && len(longest) == 1:
+		return p[longest[0]], true
+	}
+	return nil, false
+}
+
+// allUnder reports whether every file in the report is below dir.
+func (p Profile) allUnder(dir string) bool {
+	for file := range p {
+		if !strings.HasPrefix(filepath.ToSlash(file), dir+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// sharedElements counts the trailing path elements a and b have in common.
+func sharedElements(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	n := 0
+	for n < len(as) && n < len(bs) && as[len(as)-1-n] == bs[len(bs)-1-n] {
+		n++
+	}
+	return n
+}
+
+// FileResult describes coverage of the changed lines in one file.
+type FileResult struct {
+	File      string
+	Covered   int
+	Uncovered []int
+}
+
+// Result summarises coverage of changed lines across the diff.
+type Result struct {
+	Files []FileResult
+}
+
+// Changes records the changed lines per file to evaluate.
+type Changes map[string][]int
+
+// Evaluate checks which changed lines are covered. Lines that are not
+// instrumented (comments, declarations, files missing from the report) are
+// ignored.
+func (p Profile) Evaluate(changes Changes) Result {
+	var result Result
+	files := make([]string, 0, len(changes))
+	for f := range changes {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		lines, ok := p.Lookup(file)
+		if !ok {
+			continue
+		}
+		fr := FileResult{File: file}
+		for _, l := range changes[file] {
+			covered, instrumented := lines[l]
+			switch {
+			case !instrumented:
+			case covered:
+				fr.Covered++
+			default:
+				fr.Uncovered = append(fr.Uncovered, l)
+			}
+		}
+		if fr.Covered+len(fr.Uncovered) > 0 {
+			result.Files = append(result.Files, fr)
+		}
+	}
+	return result
+}
+
+// Instrumented returns the number of changed lines the report knows about.
+func (r Result) Instrumented() int {
+	n := 0
+	for _, f := range r.Files {
+		n += f.Covered + len(f.Uncovered)
+	}
+	return n
+}
+
+// Percent returns the percentage of instrumented changed lines that are covered.
+func (r Result) Percent() float64 {
+	total := r.Instrumented()
+	if total == 0 {
+		return 0
+	}
+	covered := 0
+	for _, f := range r.Files {
+		covered += f.Covered
+	}
+	return float64(covered) * 100 / float64(total)
+}
+
+// Ranges collapses sorted line numbers into "a-b" ranges.
+func Ranges(lines []int) []string {
+	var ranges []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(lines[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return ranges
+}
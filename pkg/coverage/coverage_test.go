@@ -0,0 +1,51 @@
+package coverage
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	const module = "github.com/acme/app"
+	profile := Profile{
+		module + "/main.go":          {1: true},
+		module + "/cmd/tool/main.go": {2: true},
+		module + "/pkg/a/util.go":    {3: true},
+		module + "/pkg/b/util.go":    {4: true},
+		module + "/pkg/c/x/doc.go":   {5: true},
+		module + "/pkg/d/x/doc.go":   {6: true},
+	}
+	lcov := Profile{
+		"/home/runner/work/app/app/src/index.js": {7: true},
+		"lib/index.js":                           {8: true},
+	}
+
+	tests := []struct {
+		name    string
+		profile Profile
+		path    string
+		line    int
+		found   bool
+	}{
+		{"exact", Profile{"pkg/a/util.go": {9: true}}, "pkg/a/util.go", 9, true},
+		{"module relative at the root", profile, "main.go", 1, true},
+		{"module relative in a package", profile, "cmd/tool/main.go", 2, true},
+		{"same file name in two packages", profile, "pkg/b/util.go", 4, true},
+		{"bare file name shared by two packages", profile, "util.go", 0, false},
+		{"suffix shared by two packages", profile, "x/doc.go", 0, false},
+		{"missing", profile, "pkg/e/util.go", 0, false},
+		{"absolute path", lcov, "src/index.js", 7, true},
+		{"report relative to a subdirectory", lcov, "web/lib/index.js", 8, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map iteration order varies, so look up repeatedly.
+			for i := 0; i < 20; i++ {
+				lines, ok := tt.profile.Lookup(tt.path)
+				if ok != tt.found {
+					t.Fatalf("Lookup(%q) found = %v, want %v", tt.path, ok, tt.found)
+				}
+				if ok && !lines[tt.line] {
+					t.Fatalf("Lookup(%q) = %v, want coverage of line %d", tt.path, lines, tt.line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Package dashboard renders the review history as a static HTML page of
+// findings over time, spend per repository, and the files drawing the most
+// findings, for publishing to GitHub Pages or any static host.
+package dashboard
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/history"
+	"github.com/crazywolf132/repo-ranger/pkg/locale"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// hotFiles is how many of the files with the most findings are listed.
+const hotFiles = 20
+
+// severities in the order the charts stack them, the empty severity being
+// findings the model did not rate.
+var severities = []types.Severity{types.SeverityCritical, types.SeverityMajor, types.SeverityMinor, types.SeverityNit, ""}
+
+// Dashboard is the review activity of a range of time.
+type Dashboard struct {
+	From, To     time.Time
+	Reviews      int
+	PullRequests int
+	// Findings counts distinct findings; re-reviews of a pull request
+	// repeat its findings, which count once, in the period first reported.
+	Findings int
+	// Posted counts the model's inline findings, of which Addressed were
+	// later marked addressed by a push.
+	Posted    int
+	Addressed int
+	Cost      float64
+	Periods   []Period
+	Repos     []Repo
+	HotFiles  []HotFile
+	// Locale writes the dashboard's numbers and dates.
+	Locale locale.Locale
+}
+
+// Period is the findings first reported in a day or week.
+type Period struct {
+	Start    time.Time
+	Findings map[types.Severity]int
+}
+
+// Total is the number of findings in the period.
+func (p Period) Total() int {
+	total := 0
+	for _, n := range p.Findings {
+		total += n
+	}
+	return total
+}
+
+// Repo is the activity of one repository.
+type Repo struct {
+	Repo     string
+	Reviews  int
+	Findings int
+	Cost     float64
+}
+
+// HotFile is a file and the findings reported on it.
+type HotFile struct {
+	Repo     string
+	File     string
+	Findings int
+	// Worst is the most severe of them.
+	Worst types.Severity
+}
+
+// Build summarizes the records from from up to to. Findings are charted
+// per day for ranges of up to a month, and per week beyond.
+func Build(records []history.Record, from, to time.Time) Dashboard {
+	d := Dashboard{From: from, To: to}
+	step := 24 * time.Hour
+	if to.Sub(from) > 31*24*time.Hour {
+		step *= 7
+	}
+	for start := from; start.Before(to); start = start.Add(step) {
+		d.Periods = append(d.Periods, Period{Start: start, Findings: map[types.Severity]int{}})
+	}
+
+	pulls := map[string]bool{}
+	repos := map[string]*Repo{}
+	files := map[string]*HotFile{}
+	seen := map[string]bool{}
+	for _, r := range records {
+		switch r.Kind {
+		case history.KindAddressed:
+			d.Addressed += r.Addressed
+			continue
+		case history.KindReview:
+		default:
+			continue
+		}
+		repo, ok := repos[r.Repo]
+		if !ok {
+			repo = &Repo{Repo: r.Repo}
+			repos[r.Repo] = repo
+		}
+		d.Reviews++
+		d.Cost += r.Cost
+		repo.Reviews++
+		repo.Cost += r.Cost
+		pulls[fmt.Sprintf("%s#%d", r.Repo, r.PullRequest)] = true
+
+		period := int(r.Time.Sub(from) / step)
+		for _, f := range r.Findings {
+			key := fmt.Sprintf("%s#%d %s:%d %s", r.Repo, r.PullRequest, f.File, f.Line, f.Message)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			d.Findings++
+			repo.Findings++
+			if f.Source == "review" && f.Line > 0 {
+				d.Posted++
+			}
+			if period >= 0 && period < len(d.Periods) {
+				d.Periods[period].Findings[f.Severity]++
+			}
+			if f.File == "" {
+				continue
+			}
+			hot, ok := files[r.Repo+"\x00"+f.File]
+			if !ok {
+				hot = &HotFile{Repo: r.Repo, File: f.File, Worst: f.Severity}
+				files[r.Repo+"\x00"+f.File] = hot
+			}
+			hot.Findings++
+			if f.Severity.Rank() > hot.Worst.Rank() {
+				hot.Worst = f.Severity
+			}
+		}
+	}
+	d.PullRequests = len(pulls)
+
+	for _, repo := range repos {
+		d.Repos = append(d.Repos, *repo)
+	}
+	sort.Slice(d.Repos, func(i, j int) bool {
+		if d.Repos[i].Cost != d.Repos[j].Cost {
+			return d.Repos[i].Cost > d.Repos[j].Cost
+		}
+		return d.Repos[i].Repo < d.Repos[j].Repo
+	})
+	for _, hot := range files {
+		d.HotFiles = append(d.HotFiles, *hot)
+	}
+	sort.Slice(d.HotFiles, func(i, j int) bool {
+		a, b := d.HotFiles[i], d.HotFiles[j]
+		if a.Findings != b.Findings {
+			return a.Findings > b.Findings
+		}
+		if a.Worst.Rank() != b.Worst.Rank() {
+			return a.Worst.Rank() > b.Worst.Rank()
+		}
+		return a.Repo+"/"+a.File < b.Repo+"/"+b.File
+	})
+	if len(d.HotFiles) > hotFiles {
+		d.HotFiles = d.HotFiles[:hotFiles]
+	}
+	return d
+}
+
+// AcceptanceRate is the share of posted findings later addressed, from 0
+// to 1, or 0 when none were posted.
+func (d Dashboard) AcceptanceRate() float64 {
+	if d.Posted == 0 {
+		return 0
+	}
+	rate := float64(d.Addressed) / float64(d.Posted)
+	if rate > 1 {
+		rate = 1
+	}
+	return rate
+}
@@ -0,0 +1,216 @@
+package dashboard
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// Dimensions of the findings chart, in SVG user units.
+const (
+	chartWidth  = 960
+	chartHeight = 240
+	chartLeft   = 40
+	chartBottom = 24
+)
+
+var severityColors = map[types.Severity]string{
+	types.SeverityCritical: "#cf222e",
+	types.SeverityMajor:    "#bc4c00",
+	types.SeverityMinor:    "#bf8700",
+	types.SeverityNit:      "#6e7781",
+	"":                     "#afb8c1",
+}
+
+func severityName(s types.Severity) string {
+	if s == "" {
+		return "unrated"
+	}
+	return string(s)
+}
+
+// rect is one segment of a stacked bar.
+type rect struct {
+	X, Y, W, H float64
+	Color      string
+	Title      string
+}
+
+// tick is a label on an axis of the findings chart.
+type tick struct {
+	X, Y float64
+	Text string
+}
+
+// legend is a severity and its color.
+type legend struct {
+	Name  string
+	Color string
+}
+
+// page is what the template renders.
+type page struct {
+	Dashboard
+	Generated time.Time
+	// Last is the final day of the range, which ends at midnight.
+	Last       time.Time
+	Width      int
+	Height     int
+	Bars       []rect
+	XTicks     []tick
+	YTicks     []tick
+	Legend     []legend
+	MaxCost    float64
+	Acceptance string
+}
+
+// Render writes the dashboard as a self-contained HTML page, with no
+// scripts or external assets.
+func (d Dashboard) Render(w io.Writer) error {
+	p := page{
+		Dashboard: d,
+		Generated: time.Now(),
+		Last:      d.To.Add(-time.Second),
+		Width:     chartWidth,
+		Height:    chartHeight,
+	}
+	if d.Posted > 0 {
+		p.Acceptance = d.Locale.Percent(d.AcceptanceRate())
+	}
+	for _, repo := range d.Repos {
+		if repo.Cost > p.MaxCost {
+			p.MaxCost = repo.Cost
+		}
+	}
+	for _, s := range severities {
+		p.Legend = append(p.Legend, legend{Name: severityName(s), Color: severityColors[s]})
+	}
+	p.chart()
+	if err := pageTemplate.Execute(w, p); err != nil {
+		return fmt.Errorf("failed to render dashboard: %w", err)
+	}
+	return nil
+}
+
+// chart lays out the stacked bars of findings per period.
+func (p *page) chart() {
+	if len(p.Periods) == 0 {
+		return
+	}
+	highest := 0
+	for _, period := range p.Periods {
+		if total := period.Total(); total > highest {
+			highest = total
+		}
+	}
+	if highest == 0 {
+		highest = 1
+	}
+	plotWidth := float64(chartWidth - chartLeft)
+	plotHeight := float64(chartHeight - chartBottom - 8)
+	slot := plotWidth / float64(len(p.Periods))
+	gap := slot * 0.15
+	perFinding := plotHeight / float64(highest)
+
+	// Label about a dozen periods, however many there are.
+	every := (len(p.Periods) + 11) / 12
+	for i, period := range p.Periods {
+		x := chartLeft + float64(i)*slot
+		y := float64(chartHeight - chartBottom)
+		for _, s := range severities {
+			n := period.Findings[s]
+			if n == 0 {
+				continue
+			}
+			h := float64(n) * perFinding
+			y -= h
+			p.Bars = append(p.Bars, rect{
+				X: x + gap/2, Y: y, W: slot - gap, H: h,
+				Color: severityColors[s],
+				Title: fmt.Sprintf("%s: %s %s", p.Locale.ShortDate(period.Start), p.Locale.Int(n), severityName(s)),
+			})
+		}
+		if i%every == 0 {
+			p.XTicks = append(p.XTicks, tick{X: x + slot/2, Y: chartHeight - 6, Text: p.Locale.ShortDate(period.Start)})
+		}
+	}
+	ticks := []int{0, highest}
+	if highest >= 4 {
+		ticks = []int{0, highest / 2, highest}
+	}
+	for _, n := range ticks {
+		y := float64(chartHeight-chartBottom) - float64(n)*perFinding
+		p.YTicks = append(p.YTicks, tick{X: chartLeft - 6, Y: y + 4, Text: p.Locale.Int(n)})
+	}
+}
+
+var pageTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"percent": func(v, max float64) string {
+		if max == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.1f%%", v/max*100)
+	},
+	"color":    func(s types.Severity) string { return severityColors[s] },
+	"severity": severityName,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Repo Ranger dashboard</title>
+<style>
+body { font: 14px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; margin: 0 auto; max-width: 1000px; padding: 24px; }
+h1 { font-size: 24px; margin-bottom: 0; }
+h2 { font-size: 18px; margin-top: 32px; border-bottom: 1px solid #d0d7de; padding-bottom: 4px; }
+.muted { color: #656d76; }
+.stats { display: flex; flex-wrap: wrap; gap: 12px; margin-top: 16px; }
+.stat { border: 1px solid #d0d7de; border-radius: 6px; padding: 8px 16px; min-width: 120px; }
+.stat b { display: block; font-size: 22px; }
+.legend span { display: inline-block; margin-right: 12px; }
+.legend i { display: inline-block; width: 10px; height: 10px; margin-right: 4px; border-radius: 2px; }
+svg text { font-size: 11px; fill: #656d76; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #d8dee4; vertical-align: middle; }
+td.num, th.num { text-align: right; white-space: nowrap; }
+.bar { background: #0969da; height: 10px; border-radius: 2px; min-width: 1px; }
+code { font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>Repo Ranger</h1>
+<p class="muted">{{.Locale.Date .From}} to {{.Locale.Date .Last}} &middot; generated {{.Locale.DateTime .Generated}}</p>
+
+<div class="stats">
+<div class="stat"><b>{{.Locale.Int .Reviews}}</b>reviews</div>
+<div class="stat"><b>{{.Locale.Int .PullRequests}}</b>pull requests</div>
+<div class="stat"><b>{{.Locale.Int .Findings}}</b>findings</div>
+{{if .Acceptance}}<div class="stat"><b>{{.Acceptance}}</b>addressed</div>{{end}}
+<div class="stat"><b>{{.Locale.Money .Cost}}</b>spend</div>
+</div>
+
+<h2>Findings over time</h2>
+<p class="legend">{{range .Legend}}<span><i style="background: {{.Color}}"></i>{{.Name}}</span>{{end}}</p>
+<svg viewBox="0 0 {{.Width}} {{.Height}}" width="100%" role="img" aria-label="Findings over time">
+{{range .YTicks}}<text x="{{.X}}" y="{{printf "%.1f" .Y}}" text-anchor="end">{{.Text}}</text>
+{{end}}{{range .Bars}}<rect x="{{printf "%.1f" .X}}" y="{{printf "%.1f" .Y}}" width="{{printf "%.1f" .W}}" height="{{printf "%.1f" .H}}" fill="{{.Color}}"><title>{{.Title}}</title></rect>
+{{end}}{{range .XTicks}}<text x="{{printf "%.1f" .X}}" y="{{.Y}}" text-anchor="middle">{{.Text}}</text>
+{{end}}</svg>
+
+<h2>Spend by repository</h2>
+{{if .Repos}}<table>
+<tr><th>Repository</th><th class="num">Reviews</th><th class="num">Findings</th><th class="num">Spend</th><th style="width: 40%"></th></tr>
+{{range .Repos}}<tr><td>{{.Repo}}</td><td class="num">{{$.Locale.Int .Reviews}}</td><td class="num">{{$.Locale.Int .Findings}}</td><td class="num">{{$.Locale.Money .Cost}}</td><td><div class="bar" style="width: {{percent .Cost $.MaxCost}}"></div></td></tr>
+{{end}}</table>{{else}}<p class="muted">No reviews in this period.</p>{{end}}
+
+<h2>Hot files</h2>
+{{if .HotFiles}}<table>
+<tr><th>File</th><th>Repository</th><th class="num">Findings</th><th>Most severe</th></tr>
+{{range .HotFiles}}<tr><td><code>{{.File}}</code></td><td>{{.Repo}}</td><td class="num">{{$.Locale.Int .Findings}}</td><td><span style="color: {{color .Worst}}">{{severity .Worst}}</span></td></tr>
+{{end}}</table>{{else}}<p class="muted">No findings in this period.</p>{{end}}
+</body>
+</html>
+`))
@@ -4,26 +4,69 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"runtime"
 	"strings"
+	"time"
 )
 
 // Runner handles running diff commands.
 type Runner interface {
 	Run(ctx context.Context, command string) (string, error)
 	SplitIntoChunks(diff string, maxChunkSize int) []string
+	FileAt(ctx context.Context, ref, path string) (string, error)
+	// Churn counts the commits reachable from ref since the given time
+	// that touched each of paths.
+	Churn(ctx context.Context, ref string, paths []string, since time.Time) (map[string]int, error)
+	// Messages returns the messages of the commits reachable from to but
+	// not from from, newest first.
+	Messages(ctx context.Context, from, to string) ([]string, error)
 }
 
-type runner struct{}
+// Shells the diff command can be run with. ShellNone runs the command
+// directly, splitting it into arguments without any shell interpretation.
+const (
+	ShellAuto       = ""
+	ShellSh         = "sh"
+	ShellBash       = "bash"
+	ShellCmd        = "cmd"
+	ShellPowerShell = "powershell"
+	ShellPwsh       = "pwsh"
+	ShellNone       = "none"
+)
+
+type runner struct {
+	shell string
+}
+
+// RunnerOption is a function that configures a runner.
+type RunnerOption func(*runner)
+
+// WithShell sets the shell used to execute diff commands.
+func WithShell(shell string) RunnerOption {
+	return func(r *runner) {
+		r.shell = shell
+	}
+}
 
 // NewRunner creates a new diff runner.
-func NewRunner() Runner {
-	return &runner{}
+func NewRunner(opts ...RunnerOption) Runner {
+	r := &runner{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // Run executes a diff command and returns its output.
 func (r *runner) Run(ctx context.Context, command string) (string, error) {
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	name, args, err := shellCommand(r.shell, runtime.GOOS, command)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
 	output, err := cmd.Output()
 	if err != nil {
 		var exitErr *exec.ExitError
@@ -36,6 +79,143 @@ func (r *runner) Run(ctx context.Context, command string) (string, error) {
 	return string(output), nil
 }
 
+// shellCommand returns the program and arguments that run command with the
+// given shell. The automatic choice is cmd on Windows and sh elsewhere.
+func shellCommand(shell, goos, command string) (string, []string, error) {
+	if shell == ShellAuto {
+		shell = ShellSh
+		if goos == "windows" {
+			shell = ShellCmd
+		}
+	}
+
+	switch shell {
+	case ShellSh, ShellBash:
+		return shell, []string{"-c", command}, nil
+	case ShellCmd:
+		return "cmd", []string{"/C", command}, nil
+	case ShellPowerShell, ShellPwsh:
+		return shell, []string{"-NoProfile", "-NonInteractive", "-Command", command}, nil
+	case ShellNone:
+		args := splitArgs(command)
+		if len(args) == 0 {
+			return "", nil, fmt.Errorf("diff command is empty")
+		}
+		return args[0], args[1:], nil
+	}
+	return "", nil, fmt.Errorf("unsupported diff shell %q", shell)
+}
+
+// splitArgs splits a command line on whitespace, honouring single and
+// double quotes but no other shell syntax.
+func splitArgs(command string) []string {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// FileAt returns the contents of path at the given git ref.
+func (r *runner) FileAt(ctx context.Context, ref, path string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "show", fmt.Sprintf("%s:%s", ref, path))
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git show failed with stderr: %s: %w", exitErr.Stderr, err)
+		}
+		return "", fmt.Errorf("failed to execute git show: %w", err)
+	}
+
+	return string(output), nil
+}
+
+// Churn counts the commits touching each path with git log.
+func (r *runner) Churn(ctx context.Context, ref string, paths []string, since time.Time) (map[string]int, error) {
+	churn := map[string]int{}
+	if len(paths) == 0 {
+		return churn, nil
+	}
+	args := []string{"log", "--since=" + since.Format(time.RFC3339), "--format=", "--name-only", ref, "--"}
+	for _, p := range paths {
+		// Paths are relative to the top of the repository, like git's output.
+		args = append(args, ":(top)"+p)
+	}
+	output, err := exec.CommandContext(ctx, "git", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("git log failed with stderr: %s: %w", exitErr.Stderr, err)
+		}
+		return nil, fmt.Errorf("failed to execute git log: %w", err)
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			churn[line]++
+		}
+	}
+	return churn, nil
+}
+
+// Messages lists commit messages with git log.
+func (r *runner) Messages(ctx context.Context, from, to string) ([]string, error) {
+	output, err := exec.CommandContext(ctx, "git", "log", "-z", "--format=%B", from+".."+to, "--").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("git log failed with stderr: %s: %w", exitErr.Stderr, err)
+		}
+		return nil, fmt.Errorf("failed to execute git log: %w", err)
+	}
+	var messages []string
+	for _, message := range strings.Split(string(output), "\x00") {
+		if message = strings.TrimSpace(message); message != "" {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// Load reads a unified diff from path, or from standard input when path is "-".
+func Load(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read diff: %w", err)
+	}
+	return string(data), nil
+}
+
 // SplitIntoChunks splits the diff into chunks not exceeding maxChunkSize.
 func (r *runner) SplitIntoChunks(diff string, maxChunkSize int) []string {
 	if len(diff) <= maxChunkSize {
@@ -63,3 +243,100 @@ func (r *runner) SplitIntoChunks(diff string, maxChunkSize int) []string {
 
 	return chunks
 }
+
+// Overlap prefixes every chunk after the first with up to lines lines from
+// the end of the chunk before it, so a change near a chunk boundary is seen
+// with what surrounds it. When the overlap starts inside a file, it is
+// headed by that file's header and a hunk header for the lines it repeats,
+// so the chunk still parses. Lines are dropped from the front of the
+// overlap to keep each chunk within maxChunkSize.
+func Overlap(chunks []string, lines, maxChunkSize int) []string {
+	if lines <= 0 || len(chunks) < 2 {
+		return chunks
+	}
+
+	// Where each line of the whole diff sits: the index of its file's
+	// header line and of its hunk's header, or -1, and the old and
+	// new line numbers it starts at.
+	type position struct {
+		file, hunk int
+		old, new   int
+	}
+	var all []string
+	var ends []int
+	for _, chunk := range chunks {
+		all = append(all, strings.Split(strings.TrimSuffix(chunk, "\n"), "\n")...)
+		ends = append(ends, len(all))
+	}
+	positions := make([]position, len(all))
+	headerEnd := map[int]int{}
+	current := position{file: -1, hunk: -1}
+	starts := fileStarts(all)
+	for i, line := range all {
+		switch {
+		case starts[i]:
+			current = position{file: i, hunk: -1}
+		case current.file >= 0 && strings.HasPrefix(line, "@@"):
+			if h, ok := parseHunkHeader(line); ok {
+				if current.hunk < 0 {
+					headerEnd[current.file] = i
+				}
+				current.hunk, current.old, current.new = i, h.OldStart, h.NewStart
+			}
+		}
+		positions[i] = current
+		if current.hunk >= 0 && i > current.hunk {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				current.new++
+			case strings.HasPrefix(line, "-"):
+				current.old++
+			case strings.HasPrefix(line, " "):
+				current.old++
+				current.new++
+			}
+		}
+	}
+
+	overlapped := []string{chunks[0]}
+	for i := 1; i < len(chunks); i++ {
+		start, end := ends[i-1]-lines, ends[i-1]
+		if i > 1 && start < ends[i-2] {
+			start = ends[i-2]
+		}
+		if start < 0 {
+			start = 0
+		}
+		var prefix []string
+		for ; start < end; start++ {
+			p := positions[start]
+			var head []string
+			switch {
+			case p.file < 0 || p.file == start:
+			case p.hunk < 0:
+				// Inside a file header; repeat the whole header instead.
+				head = all[p.file:start]
+			case p.hunk == start:
+				head = all[p.file:headerEnd[p.file]]
+			default:
+				h, _ := parseHunkHeader(all[p.hunk])
+				header := fmt.Sprintf("@@ -%d,%d +%d,%d @@", p.old, h.OldLines-(p.old-h.OldStart), p.new, h.NewLines-(p.new-h.NewStart))
+				if h.Header != "" {
+					header += " " + h.Header
+				}
+				head = append(append([]string{}, all[p.file:headerEnd[p.file]]...), header)
+			}
+			prefix = append(head, all[start:end]...)
+			if size := len(strings.Join(prefix, "\n")) + 1 + len(chunks[i]); size <= maxChunkSize {
+				break
+			}
+			prefix = nil
+		}
+		if len(prefix) == 0 {
+			overlapped = append(overlapped, chunks[i])
+			continue
+		}
+		overlapped = append(overlapped, strings.Join(prefix, "\n")+"\n"+chunks[i])
+	}
+	return overlapped
+}
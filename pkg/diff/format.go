@@ -0,0 +1,177 @@
+package diff
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Detectors of cosmetic hunks, which change how code looks but not what it
+// does.
+const (
+	DetectWhitespace = "whitespace" // lines differ only in whitespace
+	DetectImports    = "imports"    // import lines are reordered
+	DetectFormatter  = "formatter"  // code is re-wrapped without changing its tokens
+)
+
+// DefaultDetectors are the detectors used when none are configured.
+var DefaultDetectors = []string{DetectWhitespace, DetectImports, DetectFormatter}
+
+// Cosmetic reports which of the detectors, if any, recognizes the hunk as a
+// cosmetic change.
+func Cosmetic(h Hunk, detectors []string) (string, bool) {
+	var removed, added []string
+	for _, l := range h.Lines {
+		switch l.Kind {
+		case LineRemoved:
+			removed = append(removed, l.Content)
+		case LineAdded:
+			added = append(added, l.Content)
+		}
+	}
+	if len(removed) == 0 && len(added) == 0 {
+		return "", false
+	}
+
+	for _, d := range detectors {
+		var match bool
+		switch d {
+		case DetectWhitespace:
+			match = sameLines(removed, added, stripSpace)
+		case DetectImports:
+			match = allImports(removed) && allImports(added) && sameLineSet(removed, added, strings.TrimSpace)
+		case DetectFormatter:
+			match = stripSpace(strings.Join(removed, "")) == stripSpace(strings.Join(added, ""))
+		}
+		if match {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+// StripCosmetic removes the hunks the detectors recognize as cosmetic from a
+// unified git diff, dropping files left without hunks. It returns the
+// remaining diff and the number of hunks removed.
+func StripCosmetic(diff string, detectors []string) (string, int) {
+	if len(detectors) == 0 {
+		return diff, 0
+	}
+
+	var b strings.Builder
+	removed := 0
+	for _, section := range splitFiles(diff) {
+		header, hunks := splitHunks(section)
+		var kept []string
+		for _, hunk := range hunks {
+			files := Parse(strings.Join(append(append([]string{}, header...), hunk...), "\n"))
+			if len(files) == 1 && len(files[0].Hunks) == 1 {
+				if _, ok := Cosmetic(files[0].Hunks[0], detectors); ok {
+					removed++
+					continue
+				}
+			}
+			kept = append(kept, hunk...)
+		}
+		if len(hunks) > 0 && len(kept) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(strings.Join(append(header, kept...), "\n"))
+	}
+	return b.String(), removed
+}
+
+// splitFiles splits a unified diff into its per-file sections.
+func splitFiles(diff string) [][]string {
+	var sections [][]string
+	lines := strings.Split(diff, "\n")
+	starts := fileStarts(lines)
+	for i, line := range lines {
+		if starts[i] || len(sections) == 0 {
+			sections = append(sections, nil)
+		}
+		sections[len(sections)-1] = append(sections[len(sections)-1], line)
+	}
+	return sections
+}
+
+// splitHunks splits a file section into its header and hunks.
+func splitHunks(section []string) ([]string, [][]string) {
+	var header []string
+	var hunks [][]string
+	for _, line := range section {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			hunks = append(hunks, []string{line})
+		case len(hunks) == 0:
+			header = append(header, line)
+		default:
+			hunks[len(hunks)-1] = append(hunks[len(hunks)-1], line)
+		}
+	}
+	return header, hunks
+}
+
+// sameLines reports whether a and b hold the same lines in the same order
+// once normalized.
+func sameLines(a, b []string, normalize func(string) string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if normalize(a[i]) != normalize(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameLineSet reports whether a and b hold the same lines in any order once
+// normalized.
+func sameLineSet(a, b []string, normalize func(string) string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	na := make([]string, len(a))
+	nb := make([]string, len(b))
+	for i := range a {
+		na[i], nb[i] = normalize(a[i]), normalize(b[i])
+	}
+	sort.Strings(na)
+	sort.Strings(nb)
+	return sameLines(na, nb, func(s string) string { return s })
+}
+
+// allImports reports whether every line is an import statement or a line of
+// a Go import block.
+func allImports(lines []string) bool {
+	for _, line := range lines {
+		t := strings.TrimSpace(line)
+		switch {
+		case t == "":
+		case strings.HasPrefix(t, "import "), strings.HasPrefix(t, "from ") && strings.Contains(t, " import "):
+		case strings.HasPrefix(t, `"`) && strings.HasSuffix(t, `"`):
+		case strings.HasPrefix(t, "using ") && strings.HasSuffix(t, ";"):
+		case strings.HasPrefix(t, "#include "):
+		default:
+			// Go allows named imports such as `log "github.com/sirupsen/logrus"`.
+			if fields := strings.Fields(t); len(fields) == 2 && strings.HasPrefix(fields[1], `"`) {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
+
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,199 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+type gitRunner struct {
+	runner
+	repoPath string
+}
+
+// NewGitRunner creates a diff runner that reads the repository at repoPath
+// in-process with go-git. It needs neither a shell nor a git binary, and
+// its "command" is a revision range such as "main..HEAD" rather than a
+// shell command, so it cannot be used for command injection.
+func NewGitRunner(repoPath string) Runner {
+	return &gitRunner{repoPath: repoPath}
+}
+
+// Run computes the unified diff between the two revisions of a range given
+// as "base..head" or "base head". A single revision is diffed against HEAD.
+func (g *gitRunner) Run(ctx context.Context, revisions string) (string, error) {
+	base, head := parseRevisionRange(revisions)
+	repo, err := g.open()
+	if err != nil {
+		return "", err
+	}
+
+	baseTree, err := resolveTree(repo, base)
+	if err != nil {
+		return "", err
+	}
+	headTree, err := resolveTree(repo, head)
+	if err != nil {
+		return "", err
+	}
+
+	changes, err := object.DiffTreeWithOptions(ctx, baseTree, headTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s and %s: %w", base, head, err)
+	}
+	patch, err := changes.PatchContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to build patch: %w", err)
+	}
+	return patch.String(), nil
+}
+
+// FileAt returns the contents of path at the given revision.
+func (g *gitRunner) FileAt(ctx context.Context, ref, path string) (string, error) {
+	repo, err := g.open()
+	if err != nil {
+		return "", err
+	}
+	tree, err := resolveTree(repo, ref)
+	if err != nil {
+		return "", err
+	}
+	file, err := tree.File(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s at %s: %w", path, ref, err)
+	}
+	return file.Contents()
+}
+
+// maxChurnCommits bounds the history walked for each path.
+const maxChurnCommits = 100
+
+// Churn counts the commits touching each path by walking the history
+// in-process.
+func (g *gitRunner) Churn(ctx context.Context, ref string, paths []string, since time.Time) (map[string]int, error) {
+	churn := map[string]int{}
+	if len(paths) == 0 {
+		return churn, nil
+	}
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve revision %s: %w", ref, err)
+	}
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		path := path
+		commits, err := repo.Log(&git.LogOptions{From: *hash, FileName: &path, Since: &since})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history of %s: %w", path, err)
+		}
+		n := 0
+		err = commits.ForEach(func(*object.Commit) error {
+			if n++; n >= maxChurnCommits {
+				return storer.ErrStop
+			}
+			return nil
+		})
+		commits.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history of %s: %w", path, err)
+		}
+		if n > 0 {
+			churn[path] = n
+		}
+	}
+	return churn, nil
+}
+
+// Messages returns the messages of the commits in from..to.
+func (g *gitRunner) Messages(ctx context.Context, from, to string) ([]string, error) {
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]plumbing.Hash, 2)
+	for i, rev := range []string{from, to} {
+		hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve revision %s: %w", rev, err)
+		}
+		hashes[i] = *hash
+	}
+
+	excluded := map[plumbing.Hash]bool{}
+	commits, err := repo.Log(&git.LogOptions{From: hashes[0]})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of %s: %w", from, err)
+	}
+	err = commits.ForEach(func(c *object.Commit) error {
+		excluded[c.Hash] = true
+		return ctx.Err()
+	})
+	commits.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of %s: %w", from, err)
+	}
+
+	var messages []string
+	commits, err = repo.Log(&git.LogOptions{From: hashes[1]})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of %s: %w", to, err)
+	}
+	err = commits.ForEach(func(c *object.Commit) error {
+		if !excluded[c.Hash] {
+			messages = append(messages, strings.TrimSpace(c.Message))
+		}
+		return ctx.Err()
+	})
+	commits.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of %s: %w", to, err)
+	}
+	return messages, nil
+}
+
+func (g *gitRunner) open() (*git.Repository, error) {
+	repo, err := git.PlainOpenWithOptions(g.repoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository: %w", err)
+	}
+	return repo, nil
+}
+
+func resolveTree(repo *git.Repository, rev string) (*object.Tree, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve revision %s: %w", rev, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load commit %s: %w", rev, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tree of %s: %w", rev, err)
+	}
+	return tree, nil
+}
+
+func parseRevisionRange(revisions string) (string, string) {
+	revisions = strings.TrimSpace(revisions)
+	if base, head, ok := strings.Cut(revisions, ".."); ok {
+		return strings.TrimSpace(base), strings.TrimSpace(head)
+	}
+	if fields := strings.Fields(revisions); len(fields) == 2 {
+		return fields[0], fields[1]
+	}
+	return revisions, "HEAD"
+}
@@ -0,0 +1,363 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+)
+
+// LineKind identifies whether a diff line was added, removed, or is context.
+type LineKind int
+
+const (
+	LineContext LineKind = iota
+	LineAdded
+	LineRemoved
+)
+
+// Line is a single line within a hunk.
+type Line struct {
+	Kind    LineKind
+	Content string
+	OldLine int // line number in the old file, 0 for added lines
+	NewLine int // line number in the new file, 0 for removed lines
+}
+
+// Hunk is a contiguous block of changes within a file.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Header   string
+	Lines    []Line
+}
+
+// FileDiff describes the changes to a single file.
+type FileDiff struct {
+	OldPath   string
+	NewPath   string
+	IsNew     bool
+	IsDeleted bool
+	IsBinary  bool
+	IsRenamed bool
+	// Similarity is git's similarity index for renames, from 0 to 100.
+	Similarity int
+	Hunks      []Hunk
+}
+
+// Path returns the most relevant path for the file: the new path unless the
+// file was deleted.
+func (f FileDiff) Path() string {
+	if f.IsDeleted || f.NewPath == "" {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+// AddedLines returns all added lines in the file.
+func (f FileDiff) AddedLines() []Line {
+	var lines []Line
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind == LineAdded {
+				lines = append(lines, l)
+			}
+		}
+	}
+	return lines
+}
+
+// RemovedLines returns all removed lines in the file.
+func (f FileDiff) RemovedLines() []Line {
+	var lines []Line
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind == LineRemoved {
+				lines = append(lines, l)
+			}
+		}
+	}
+	return lines
+}
+
+// TouchedLines returns the new-file line numbers affected by the change:
+// every added line, plus the position at which lines were removed.
+func (f FileDiff) TouchedLines() []int {
+	var lines []int
+	for _, h := range f.Hunks {
+		newLine := h.NewStart
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case LineAdded:
+				// A replaced line is both removed and added there.
+				if len(lines) == 0 || lines[len(lines)-1] != l.NewLine {
+					lines = append(lines, l.NewLine)
+				}
+				newLine = l.NewLine + 1
+			case LineContext:
+				newLine = l.NewLine + 1
+			case LineRemoved:
+				if len(lines) == 0 || lines[len(lines)-1] != newLine {
+					lines = append(lines, newLine)
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// Parse parses a unified diff into per-file changes. Git diffs are split
+// at their "diff --git" lines; diffs from other tools, such as hg or svn,
+// at their "---" and "+++" file headers.
+func Parse(diff string) []FileDiff {
+	var files []FileDiff
+	var current *FileDiff
+	var hunk *Hunk
+	oldLine, newLine := 0, 0
+
+	flushHunk := func() {
+		if current != nil && hunk != nil {
+			current.Hunks = append(current.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	flushFile := func() {
+		flushHunk()
+		if current != nil {
+			files = append(files, *current)
+		}
+		current = nil
+	}
+
+	lines := strings.Split(diff, "\n")
+	starts := fileStarts(lines)
+	for i, line := range lines {
+		if starts[i] {
+			flushFile()
+			current = &FileDiff{}
+		}
+		switch {
+		case current == nil:
+			continue
+		case hunk == nil && strings.HasPrefix(line, "diff --git "):
+			if a, b, ok := parseGitHeader(line); ok {
+				current.OldPath, current.NewPath = a, b
+			}
+		case hunk == nil && strings.HasPrefix(line, "--- "):
+			if p := trimPathPrefix(strings.TrimPrefix(line, "--- ")); p != "" {
+				current.OldPath = p
+			} else {
+				current.IsNew = true
+			}
+		case hunk == nil && strings.HasPrefix(line, "+++ "):
+			if p := trimPathPrefix(strings.TrimPrefix(line, "+++ ")); p != "" {
+				current.NewPath = p
+			} else {
+				current.IsDeleted = true
+			}
+		case hunk == nil && strings.HasPrefix(line, "new file mode"):
+			current.IsNew = true
+		case hunk == nil && strings.HasPrefix(line, "deleted file mode"):
+			current.IsDeleted = true
+		case hunk == nil && strings.HasPrefix(line, "Binary files"):
+			current.IsBinary = true
+		case hunk == nil && strings.HasPrefix(line, "similarity index "):
+			current.Similarity, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "similarity index "), "%"))
+		case hunk == nil && strings.HasPrefix(line, "rename from "):
+			current.IsRenamed = true
+			current.OldPath = strings.TrimPrefix(line, "rename from ")
+		case hunk == nil && strings.HasPrefix(line, "rename to "):
+			current.IsRenamed = true
+			current.NewPath = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "@@"):
+			flushHunk()
+			h, ok := parseHunkHeader(line)
+			if !ok {
+				continue
+			}
+			hunk = &h
+			oldLine, newLine = h.OldStart, h.NewStart
+		case hunk == nil:
+			continue
+		case strings.HasPrefix(line, "+"):
+			hunk.Lines = append(hunk.Lines, Line{Kind: LineAdded, Content: line[1:], NewLine: newLine})
+			newLine++
+		case strings.HasPrefix(line, "-"):
+			hunk.Lines = append(hunk.Lines, Line{Kind: LineRemoved, Content: line[1:], OldLine: oldLine})
+			oldLine++
+		case strings.HasPrefix(line, " "):
+			hunk.Lines = append(hunk.Lines, Line{Kind: LineContext, Content: line[1:], OldLine: oldLine, NewLine: newLine})
+			oldLine++
+			newLine++
+		}
+	}
+	flushFile()
+
+	return files
+}
+
+// fileStarts reports which of lines start a file's section. In a git
+// diff that is each "diff --git" line. Otherwise it is each "---" line
+// followed by a "+++" line outside a hunk, or the "Index:", "====" or
+// "diff" lines that svn and hg put right before it.
+func fileStarts(lines []string) []bool {
+	starts := make([]bool, len(lines))
+	git := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") {
+			starts[i] = true
+			git = true
+		}
+	}
+	if git {
+		return starts
+	}
+
+	// Lines left in the current hunk, so removed lines that begin with
+	// "--" are not taken for headers.
+	oldLeft, newLeft := 0, 0
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, " "), line == "":
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			if h, ok := parseHunkHeader(line); ok {
+				oldLeft, newLeft = h.OldLines, h.NewLines
+			}
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			start := i
+			for start > 0 && !starts[start-1] && isPlainPreamble(lines[start-1]) {
+				start--
+			}
+			starts[start] = true
+			i++
+		}
+	}
+	return starts
+}
+
+// isPlainPreamble reports whether line is one svn or hg writes ahead of a
+// file's "---" header.
+func isPlainPreamble(line string) bool {
+	return strings.HasPrefix(line, "Index: ") || strings.HasPrefix(line, "====") || strings.HasPrefix(line, "diff ")
+}
+
+// parseGitHeader extracts the a/ and b/ paths from a "diff --git" line.
+func parseGitHeader(line string) (string, string, bool) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	idx := strings.Index(rest, " b/")
+	if !strings.HasPrefix(rest, "a/") || idx < 0 {
+		return "", "", false
+	}
+	return rest[2:idx], rest[idx+3:], true
+}
+
+func trimPathPrefix(p string) string {
+	if i := strings.IndexByte(p, '\t'); i >= 0 {
+		p = p[:i]
+	}
+	switch {
+	case p == "/dev/null":
+		return ""
+	case strings.HasPrefix(p, "a/"), strings.HasPrefix(p, "b/"):
+		return p[2:]
+	}
+	return p
+}
+
+// parseHunkHeader parses "@@ -a,b +c,d @@ header".
+func parseHunkHeader(line string) (Hunk, bool) {
+	var h Hunk
+	end := strings.Index(line[2:], "@@")
+	if end < 0 {
+		return h, false
+	}
+	fields := strings.Fields(line[2 : end+2])
+	if len(fields) < 2 {
+		return h, false
+	}
+
+	var ok bool
+	if h.OldStart, h.OldLines, ok = parseRange(strings.TrimPrefix(fields[0], "-")); !ok {
+		return h, false
+	}
+	if h.NewStart, h.NewLines, ok = parseRange(strings.TrimPrefix(fields[1], "+")); !ok {
+		return h, false
+	}
+	h.Header = strings.TrimSpace(line[end+4:])
+	return h, true
+}
+
+func parseRange(s string) (int, int, bool) {
+	start, count, hasCount := strings.Cut(s, ",")
+	a, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !hasCount {
+		return a, 1, true
+	}
+	b, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, false
+	}
+	return a, b, true
+}
+
+// Filter returns the sections of a unified diff whose file path
+// satisfies keep.
+func Filter(diff string, keep func(path string) bool) string {
+	var kept []string
+	eachSection(diff, func(path, text string) {
+		if keep(path) {
+			kept = append(kept, text)
+		}
+	})
+	return strings.Join(kept, "\n")
+}
+
+// SplitFiles returns the section of a unified diff for each file,
+// keyed by path.
+func SplitFiles(diff string) map[string]string {
+	sections := map[string]string{}
+	eachSection(diff, func(path, text string) {
+		sections[path] = text
+	})
+	return sections
+}
+
+// eachSection calls fn with the path and text of each file's section of
+// diff, in order.
+func eachSection(diff string, fn func(path, text string)) {
+	var section []string
+	flush := func() {
+		if len(section) == 0 {
+			return
+		}
+		text := strings.Join(section, "\n")
+		if files := Parse(text); len(files) > 0 {
+			fn(files[0].Path(), text)
+		}
+		section = nil
+	}
+	lines := strings.Split(diff, "\n")
+	starts := fileStarts(lines)
+	for i, line := range lines {
+		if starts[i] {
+			flush()
+		}
+		section = append(section, line)
+	}
+	flush()
+}
@@ -0,0 +1,225 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,4 @@ package main
+ package main
+-import "fmt"
++import "log"
+
+ func main() {
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++one
++two
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+diff --git a/a.go b/b.go
+similarity index 90%
+rename from a.go
+rename to b.go
+diff --git a/logo.png b/logo.png
+Binary files a/logo.png and b/logo.png differ`
+
+func TestParse(t *testing.T) {
+	files := Parse(sampleDiff)
+
+	tests := []struct {
+		path     string
+		old      string
+		isNew    bool
+		deleted  bool
+		binary   bool
+		renamed  bool
+		similar  int
+		added    []string
+		removed  []string
+		touched  []int
+		header   string
+		newStart int
+	}{
+		{path: "main.go", old: "main.go", added: []string{`import "log"`}, removed: []string{`import "fmt"`}, touched: []int{2}, header: "package main", newStart: 1},
+		{path: "new.txt", old: "new.txt", isNew: true, added: []string{"one", "two"}, touched: []int{1, 2}, newStart: 1},
+		{path: "old.txt", old: "old.txt", deleted: true, removed: []string{"gone"}, touched: []int{0}},
+		{path: "b.go", old: "a.go", renamed: true, similar: 90},
+		{path: "logo.png", old: "logo.png", binary: true},
+	}
+	if len(files) != len(tests) {
+		t.Fatalf("Parse returned %d files, want %d", len(files), len(tests))
+	}
+	for i, tt := range tests {
+		f := files[i]
+		t.Run(tt.path, func(t *testing.T) {
+			if f.Path() != tt.path || f.OldPath != tt.old {
+				t.Errorf("paths = %q from %q, want %q from %q", f.Path(), f.OldPath, tt.path, tt.old)
+			}
+			if f.IsNew != tt.isNew || f.IsDeleted != tt.deleted || f.IsBinary != tt.binary || f.IsRenamed != tt.renamed {
+				t.Errorf("new, deleted, binary, renamed = %v, %v, %v, %v, want %v, %v, %v, %v",
+					f.IsNew, f.IsDeleted, f.IsBinary, f.IsRenamed, tt.isNew, tt.deleted, tt.binary, tt.renamed)
+			}
+			if f.Similarity != tt.similar {
+				t.Errorf("similarity = %d, want %d", f.Similarity, tt.similar)
+			}
+			if got := contents(f.AddedLines()); !reflect.DeepEqual(got, tt.added) {
+				t.Errorf("added = %q, want %q", got, tt.added)
+			}
+			if got := contents(f.RemovedLines()); !reflect.DeepEqual(got, tt.removed) {
+				t.Errorf("removed = %q, want %q", got, tt.removed)
+			}
+			if got := f.TouchedLines(); !reflect.DeepEqual(got, tt.touched) {
+				t.Errorf("touched = %v, want %v", got, tt.touched)
+			}
+			if len(f.Hunks) > 0 && (f.Hunks[0].Header != tt.header || f.Hunks[0].NewStart != tt.newStart) {
+				t.Errorf("hunk header %q at %d, want %q at %d", f.Hunks[0].Header, f.Hunks[0].NewStart, tt.header, tt.newStart)
+			}
+		})
+	}
+}
+
+func TestParseLineNumbers(t *testing.T) {
+	files := Parse("diff --git a/x b/x\n--- a/x\n+++ b/x\n@@ -10,3 +20,3 @@\n ctx\n-old\n+new\n ctx")
+	want := []Line{
+		{Kind: LineContext, Content: "ctx", OldLine: 10, NewLine: 20},
+		{Kind: LineRemoved, Content: "old", OldLine: 11},
+		{Kind: LineAdded, Content: "new", NewLine: 21},
+		{Kind: LineContext, Content: "ctx", OldLine: 12, NewLine: 22},
+	}
+	if len(files) != 1 || len(files[0].Hunks) != 1 {
+		t.Fatalf("Parse returned %+v, want one file with one hunk", files)
+	}
+	if got := files[0].Hunks[0].Lines; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		in           string
+		start, count int
+		ok           bool
+	}{
+		{"12", 12, 1, true},
+		{"12,3", 12, 3, true},
+		{"0,0", 0, 0, true},
+		{"x,3", 0, 0, false},
+		{"12,y", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, count, ok := parseRange(tt.in)
+		if start != tt.start || count != tt.count || ok != tt.ok {
+			t.Errorf("parseRange(%q) = %d, %d, %v, want %d, %d, %v", tt.in, start, count, ok, tt.start, tt.count, tt.ok)
+		}
+	}
+}
+
+func TestFilterAndSplitFiles(t *testing.T) {
+	filtered := Filter(sampleDiff, func(path string) bool { return path == "new.txt" || path == "b.go" })
+	var paths []string
+	for _, f := range Parse(filtered) {
+		paths = append(paths, f.Path())
+	}
+	if want := []string{"new.txt", "b.go"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Filter kept %v, want %v", paths, want)
+	}
+
+	sections := SplitFiles(sampleDiff)
+	if len(sections) != 5 {
+		t.Errorf("SplitFiles returned %d sections, want 5", len(sections))
+	}
+	if got := Parse(sections["old.txt"]); len(got) != 1 || !got[0].IsDeleted {
+		t.Errorf("old.txt section parses to %+v", got)
+	}
+}
+
+func TestParsePlainDiffs(t *testing.T) {
+	const svn = `Index: db/schema.sql
+===================================================================
+--- db/schema.sql	(revision 41)
++++ db/schema.sql	(working copy)
+@@ -1,2 +1,2 @@
+--- users
+-+++ legacy
++-- accounts
++CREATE TABLE accounts;
+Index: docs/new.md
+===================================================================
+--- /dev/null	(nonexistent)
++++ docs/new.md	(working copy)
+@@ -0,0 +1 @@
++hello`
+	const hg = `diff -r 1111111 -r 2222222 main.go
+--- a/main.go	Thu Jan 01 00:00:00 1970 +0000
++++ b/main.go	Thu Jan 01 00:00:00 1970 +0000
+@@ -1 +1 @@
+-package old
++package main
+diff -r 1111111 -r 2222222 gone.txt
+--- a/gone.txt	Thu Jan 01 00:00:00 1970 +0000
++++ /dev/null	Thu Jan 01 00:00:00 1970 +0000
+@@ -1 +0,0 @@
+-bye`
+
+	tests := []struct {
+		name    string
+		diff    string
+		paths   []string
+		removed [][]string
+		isNew   []bool
+		deleted []bool
+	}{
+		{"svn", svn, []string{"db/schema.sql", "docs/new.md"}, [][]string{{"-- users", "+++ legacy"}, nil}, []bool{false, true}, []bool{false, false}},
+		{"hg", hg, []string{"main.go", "gone.txt"}, [][]string{{"package old"}, {"bye"}}, []bool{false, false}, []bool{false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := Parse(tt.diff)
+			if len(files) != len(tt.paths) {
+				t.Fatalf("Parse returned %d files, want %d", len(files), len(tt.paths))
+			}
+			for i, f := range files {
+				if f.Path() != tt.paths[i] {
+					t.Errorf("file %d path = %q, want %q", i, f.Path(), tt.paths[i])
+				}
+				if got := contents(f.RemovedLines()); !reflect.DeepEqual(got, tt.removed[i]) {
+					t.Errorf("%s removed = %q, want %q", f.Path(), got, tt.removed[i])
+				}
+				if f.IsNew != tt.isNew[i] || f.IsDeleted != tt.deleted[i] {
+					t.Errorf("%s new, deleted = %v, %v, want %v, %v", f.Path(), f.IsNew, f.IsDeleted, tt.isNew[i], tt.deleted[i])
+				}
+			}
+
+			sections := SplitFiles(tt.diff)
+			if len(sections) != len(tt.paths) {
+				t.Fatalf("SplitFiles returned %d sections, want %d", len(sections), len(tt.paths))
+			}
+			second := sections[tt.paths[1]]
+			if !strings.HasPrefix(second, "Index: ") && !strings.HasPrefix(second, "diff -r ") {
+				t.Errorf("section of %s starts %q, want it headed by its preamble", tt.paths[1], second)
+			}
+		})
+	}
+}
+
+// contents returns the text of lines.
+func contents(lines []Line) []string {
+	var text []string
+	for _, l := range lines {
+		text = append(text, l.Content)
+	}
+	return text
+}
@@ -0,0 +1,159 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// patchContext is how many unchanged lines surround each change of a
+// generated patch, as git writes them.
+const patchContext = 3
+
+// Edit replaces lines Start to End of a file, counting from 1, with Lines.
+type Edit struct {
+	Start, End int
+	Lines      []string
+}
+
+// splitContent splits a file into lines, reporting whether it ends with a
+// newline.
+func splitContent(content string) ([]string, bool) {
+	if content == "" {
+		return nil, true
+	}
+	eol := strings.HasSuffix(content, "\n")
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), eol
+}
+
+// EditPatch renders edits to content, the file at path, as a diff section
+// that git apply accepts. Edits must be in order and must not overlap.
+func EditPatch(path, content string, edits []Edit) (string, error) {
+	lines, eol := splitContent(content)
+	last := 0
+	for _, e := range edits {
+		if e.Start < 1 || e.End < e.Start || e.End > len(lines) {
+			return "", fmt.Errorf("lines %d-%d are outside %s, which has %d", e.Start, e.End, path, len(lines))
+		}
+		if e.Start <= last {
+			return "", fmt.Errorf("edits of %s overlap at line %d", path, e.Start)
+		}
+		if !eol && e.End == len(lines) && len(e.Lines) == 0 {
+			// The line before would lose its newline, which git writes as
+			// a change to that line too.
+			return "", fmt.Errorf("cannot delete the last line of %s, which has no final newline", path)
+		}
+		last = e.End
+	}
+	if len(edits) == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", path, path, path, path))
+	shift := 0
+	for i := 0; i < len(edits); {
+		// Edits whose context would touch are written as one hunk.
+		j := i + 1
+		for j < len(edits) && edits[j].Start-patchContext <= edits[j-1].End+patchContext+1 {
+			j++
+		}
+		group := edits[i:j]
+		from := group[0].Start - patchContext
+		if from < 1 {
+			from = 1
+		}
+		to := group[len(group)-1].End + patchContext
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		var body strings.Builder
+		oldCount, newCount := 0, 0
+		// write writes a line with its marker, noting a missing final
+		// newline as git does.
+		write := func(prefix, text string, final bool) {
+			body.WriteString(prefix + text + "\n")
+			if final && !eol {
+				body.WriteString("\\ No newline at end of file\n")
+			}
+		}
+		line := from
+		for _, e := range group {
+			for ; line < e.Start; line++ {
+				write(" ", lines[line-1], line == len(lines))
+				oldCount++
+				newCount++
+			}
+			for ; line <= e.End; line++ {
+				write("-", lines[line-1], line == len(lines))
+				oldCount++
+			}
+			for k, added := range e.Lines {
+				write("+", added, e.End == len(lines) && k == len(e.Lines)-1)
+				newCount++
+			}
+		}
+		for ; line <= to; line++ {
+			write(" ", lines[line-1], line == len(lines))
+			oldCount++
+			newCount++
+		}
+
+		newStart := from + shift
+		if newCount == 0 {
+			// An empty range starts at the line before it.
+			newStart--
+		}
+		b.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", from, oldCount, newStart, newCount))
+		b.WriteString(body.String())
+		shift += newCount - oldCount
+		i = j
+	}
+	return b.String(), nil
+}
+
+// Apply applies the hunks of a parsed file diff to content, failing if any
+// line they keep or remove differs from content, as git apply would.
+func Apply(content string, f FileDiff) (string, error) {
+	lines, eol := splitContent(content)
+	var out []string
+	next := 1
+	for _, h := range f.Hunks {
+		start := h.OldStart
+		if h.OldLines == 0 {
+			// A hunk adding lines only starts after its old line.
+			start++
+		}
+		if start < next || start-1 > len(lines) {
+			return "", fmt.Errorf("hunk at line %d of %s is out of place", h.OldStart, f.Path())
+		}
+		out = append(out, lines[next-1:start-1]...)
+		next = start
+		oldCount, newCount := 0, 0
+		for _, l := range h.Lines {
+			if l.Kind == LineAdded {
+				out = append(out, l.Content)
+				newCount++
+				continue
+			}
+			if next > len(lines) || lines[next-1] != l.Content {
+				return "", fmt.Errorf("line %d of %s does not match the patch", next, f.Path())
+			}
+			if l.Kind == LineContext {
+				out = append(out, l.Content)
+				newCount++
+			}
+			oldCount++
+			next++
+		}
+		if oldCount != h.OldLines || newCount != h.NewLines {
+			return "", fmt.Errorf("hunk at line %d of %s has the wrong length", h.OldStart, f.Path())
+		}
+	}
+	out = append(out, lines[next-1:]...)
+	result := strings.Join(out, "\n")
+	if eol && len(out) > 0 {
+		result += "\n"
+	}
+	return result, nil
+}
@@ -0,0 +1,65 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rewrite passes each file section of a unified git diff, with its parsed
+// form, to rewrite and joins the sections it returns. Returning "" drops
+// the file.
+func Rewrite(diff string, rewrite func(f FileDiff, section string) string) string {
+	var out []string
+	for _, lines := range splitFiles(diff) {
+		section := strings.Join(lines, "\n")
+		files := Parse(section)
+		if len(files) != 1 {
+			out = append(out, section)
+			continue
+		}
+		if section = rewrite(files[0], section); section != "" {
+			out = append(out, section)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// DeleteAndAdd renders a rename as the deletion of the old file followed by
+// the addition of the new one, for renames too dissimilar to review as edits.
+func DeleteAndAdd(f FileDiff, oldContent, newContent string) string {
+	var b strings.Builder
+	writeWholeFile(&b, f.OldPath, oldContent, "-")
+	b.WriteString("\n")
+	writeWholeFile(&b, f.NewPath, newContent, "+")
+	return b.String()
+}
+
+// writeWholeFile writes a diff section deleting (prefix "-") or adding
+// (prefix "+") the whole of content.
+func writeWholeFile(b *strings.Builder, path, content, prefix string) {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+	b.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", path, path))
+	if prefix == "-" {
+		b.WriteString("deleted file mode 100644\n")
+		b.WriteString(fmt.Sprintf("--- a/%s\n+++ /dev/null\n", path))
+		b.WriteString(fmt.Sprintf("@@ -1,%d +0,0 @@", len(lines)))
+	} else {
+		b.WriteString("new file mode 100644\n")
+		b.WriteString(fmt.Sprintf("--- /dev/null\n+++ b/%s\n", path))
+		b.WriteString(fmt.Sprintf("@@ -0,0 +1,%d @@", len(lines)))
+	}
+	for _, line := range lines {
+		b.WriteString("\n" + prefix + line)
+	}
+}
+
+// WholeFile renders content as the addition of a new file at path, so that
+// existing files can be reviewed in full.
+func WholeFile(path, content string) string {
+	var b strings.Builder
+	writeWholeFile(&b, path, content, "+")
+	return b.String()
+}
@@ -0,0 +1,214 @@
+// Package digest summarizes the review history of a period, such as a day
+// or a week, for emailing to the teams owning the repositories.
+package digest
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/history"
+	"github.com/crazywolf132/repo-ranger/pkg/locale"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// topFindings is how many of the most severe findings a digest lists.
+const topFindings = 10
+
+// Digest is the review activity of a period.
+type Digest struct {
+	From, To time.Time
+	// Title names whose activity this is, such as a team.
+	Title        string
+	Reviews      int
+	PullRequests int
+	Findings     map[types.Severity]int
+	// Posted counts the model's inline findings, of which Addressed were
+	// later marked addressed by a push.
+	Posted    int
+	Addressed int
+	Cost      float64
+	Top       []Finding
+	Repos     []RepoActivity
+	// Locale writes the digest's numbers and dates.
+	Locale locale.Locale
+}
+
+// Finding is a finding with the pull request it was made on.
+type Finding struct {
+	history.Finding
+	Repo        string
+	PullRequest int
+}
+
+// RepoActivity is the activity of one repository.
+type RepoActivity struct {
+	Repo     string
+	Reviews  int
+	Findings int
+	Cost     float64
+}
+
+// Build summarizes the records of repositories matching any of repos,
+// which are "owner/name" globs; with none, every repository counts.
+func Build(records []history.Record, from, to time.Time, repos []string) Digest {
+	d := Digest{From: from, To: to, Findings: map[types.Severity]int{}}
+	pulls := map[string]bool{}
+	activity := map[string]*RepoActivity{}
+	seen := map[string]bool{}
+	for _, r := range records {
+		if !matches(repos, r.Repo) {
+			continue
+		}
+		switch r.Kind {
+		case history.KindAddressed:
+			d.Addressed += r.Addressed
+			continue
+		case history.KindReview:
+		default:
+			continue
+		}
+		a, ok := activity[r.Repo]
+		if !ok {
+			a = &RepoActivity{Repo: r.Repo}
+			activity[r.Repo] = a
+		}
+		d.Reviews++
+		d.Cost += r.Cost
+		a.Reviews++
+		a.Cost += r.Cost
+		pulls[fmt.Sprintf("%s#%d", r.Repo, r.PullRequest)] = true
+		for _, f := range r.Findings {
+			d.Findings[f.Severity]++
+			a.Findings++
+			if f.Source == "review" && f.Line > 0 {
+				d.Posted++
+			}
+			// Re-reviews of a pull request repeat its findings.
+			key := fmt.Sprintf("%s#%d %s:%d %s", r.Repo, r.PullRequest, f.File, f.Line, f.Message)
+			if !seen[key] {
+				seen[key] = true
+				d.Top = append(d.Top, Finding{Finding: f, Repo: r.Repo, PullRequest: r.PullRequest})
+			}
+		}
+	}
+	d.PullRequests = len(pulls)
+
+	sort.SliceStable(d.Top, func(i, j int) bool { return d.Top[i].Severity.Rank() > d.Top[j].Severity.Rank() })
+	if len(d.Top) > topFindings {
+		d.Top = d.Top[:topFindings]
+	}
+	for _, a := range activity {
+		d.Repos = append(d.Repos, *a)
+	}
+	sort.Slice(d.Repos, func(i, j int) bool {
+		if d.Repos[i].Reviews != d.Repos[j].Reviews {
+			return d.Repos[i].Reviews > d.Repos[j].Reviews
+		}
+		return d.Repos[i].Repo < d.Repos[j].Repo
+	})
+	return d
+}
+
+func matches(patterns []string, repo string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(repo)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptanceRate is the share of posted findings later addressed, from 0
+// to 1, or 0 when none were posted.
+func (d Digest) AcceptanceRate() float64 {
+	if d.Posted == 0 {
+		return 0
+	}
+	rate := float64(d.Addressed) / float64(d.Posted)
+	if rate > 1 {
+		rate = 1
+	}
+	return rate
+}
+
+// Subject is the subject line of the digest's email.
+func (d Digest) Subject() string {
+	first, last := d.Locale.ShortDate(d.From), d.Locale.ShortDate(d.To.Add(-time.Second))
+	subject := "Repo Ranger digest, " + first
+	if last != first {
+		subject += " to " + last
+	}
+	if d.Title != "" {
+		subject += " (" + d.Title + ")"
+	}
+	return subject
+}
+
+// Render formats the digest as plain text.
+func (d Digest) Render() string {
+	var b strings.Builder
+	b.WriteString(d.Subject() + "\n\n")
+	if d.Reviews == 0 {
+		b.WriteString("No reviews in this period.\n")
+		return b.String()
+	}
+
+	total := 0
+	var bySeverity []string
+	for _, s := range []types.Severity{types.SeverityCritical, types.SeverityMajor, types.SeverityMinor, types.SeverityNit, ""} {
+		if n := d.Findings[s]; n > 0 {
+			total += n
+			name := string(s)
+			if s == "" {
+				name = "unrated"
+			}
+			bySeverity = append(bySeverity, fmt.Sprintf("%s %s", d.Locale.Int(n), name))
+		}
+	}
+	b.WriteString(fmt.Sprintf("Reviews:     %s of %s pull request(s) in %s repositories\n", d.Locale.Int(d.Reviews), d.Locale.Int(d.PullRequests), d.Locale.Int(len(d.Repos))))
+	b.WriteString(fmt.Sprintf("Findings:    %s", d.Locale.Int(total)))
+	if len(bySeverity) > 0 {
+		b.WriteString(" (" + strings.Join(bySeverity, ", ") + ")")
+	}
+	b.WriteString("\n")
+	if d.Posted > 0 {
+		b.WriteString(fmt.Sprintf("Acceptance:  %s of inline findings addressed (%s of %s)\n", d.Locale.Percent(d.AcceptanceRate()), d.Locale.Int(d.Addressed), d.Locale.Int(d.Posted)))
+	}
+	b.WriteString(fmt.Sprintf("Spend:       %s\n", d.Locale.Money(d.Cost)))
+
+	if len(d.Top) > 0 {
+		b.WriteString("\nTop findings:\n")
+		for _, f := range d.Top {
+			location := f.File
+			if f.Line > 0 {
+				location = fmt.Sprintf("%s:%d", f.File, f.Line)
+			}
+			severity := strings.ToUpper(string(f.Severity))
+			if severity == "" {
+				severity = "UNRATED"
+			}
+			b.WriteString(fmt.Sprintf("- %s %s#%d %s: %s\n", severity, f.Repo, f.PullRequest, location, oneLine(f.Message, 160)))
+		}
+	}
+
+	b.WriteString("\nBy repository:\n")
+	for _, a := range d.Repos {
+		b.WriteString(fmt.Sprintf("- %s: %s review(s), %s finding(s), %s\n", a.Repo, d.Locale.Int(a.Reviews), d.Locale.Int(a.Findings), d.Locale.Money(a.Cost)))
+	}
+	return b.String()
+}
+
+// oneLine collapses s onto one line of at most limit characters.
+func oneLine(s string, limit int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > limit {
+		s = string(r[:limit-1]) + "…"
+	}
+	return s
+}
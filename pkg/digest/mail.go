@@ -0,0 +1,110 @@
+package digest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Team is a group of recipients and the repositories they own.
+type Team struct {
+	Name string `yaml:"name"`
+	// Emails receive the team's digest.
+	Emails []string `yaml:"emails"`
+	// Repos are "owner/name" globs; with none, the team gets every
+	// repository's activity.
+	Repos []string `yaml:"repos"`
+}
+
+// LoadTeams reads a teams file of the form "teams: [{name, emails, repos}]".
+func LoadTeams(file string) ([]Team, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read teams file: %w", err)
+	}
+	var teams struct {
+		Teams []Team `yaml:"teams"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&teams); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid teams file: %w", err)
+	}
+	for i, t := range teams.Teams {
+		if len(t.Emails) == 0 {
+			return nil, fmt.Errorf("invalid teams file: teams[%d] has no emails", i)
+		}
+		for _, pattern := range t.Repos {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid teams file: bad repos glob %q", pattern)
+			}
+		}
+	}
+	return teams.Teams, nil
+}
+
+// Mailer sends digests through an SMTP server.
+type Mailer struct {
+	// Addr is the server's host:port.
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// MailerFromEnv configures a mailer from SMTP_HOST, SMTP_PORT (default
+// 587), SMTP_USERNAME, SMTP_PASSWORD, and DIGEST_FROM.
+func MailerFromEnv() (Mailer, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return Mailer{}, fmt.Errorf("SMTP_HOST is not set")
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	m := Mailer{
+		Addr:     net.JoinHostPort(host, port),
+		From:     os.Getenv("DIGEST_FROM"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+	if m.From == "" {
+		m.From = m.Username
+	}
+	if m.From == "" {
+		return Mailer{}, fmt.Errorf("DIGEST_FROM is not set")
+	}
+	return m, nil
+}
+
+// Send emails the digest to the recipients as plain text.
+func (m Mailer) Send(to []string, d Digest) error {
+	var msg strings.Builder
+	msg.WriteString("From: " + m.From + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + d.Subject() + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(d.Render(), "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	if err := smtp.SendMail(m.Addr, auth, m.From, to, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send digest: %w", err)
+	}
+	return nil
+}
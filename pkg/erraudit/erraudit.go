@@ -0,0 +1,300 @@
+// Package erraudit audits the error handling of changed Go code: errors
+// discarded with the blank identifier, errors formatted into new errors
+// without %w, and fmt.Errorf calls that add no context to the error they
+// return.
+package erraudit
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+const source = "erraudit"
+
+// Kinds of issues.
+const (
+	// Swallowed is an error discarded with the blank identifier.
+	Swallowed = "swallowed"
+	// Unwrapped is an error formatted into a new one without %w, which
+	// errors.Is and errors.As cannot see through.
+	Unwrapped = "unwrapped"
+	// NoContext is a fmt.Errorf call formatting only errors with a format
+	// of only verbs, adding nothing to the error it wraps.
+	NoContext = "no-context"
+)
+
+// Issue is an error handling problem on a changed line.
+type Issue struct {
+	File string
+	Line int
+	Kind string
+	// Code is the offending statement or call, cut to one line.
+	Code string
+}
+
+// IsGoSource reports whether path is Go code the audit covers: not a test.
+func IsGoSource(path string) bool {
+	return strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go")
+}
+
+// Audit returns the issues in a Go file that start on one of lines.
+// Generated files return nothing. A discard with a trailing comment on its
+// line, or a //nolint:errcheck comment on the line above, is taken as
+// deliberate and not reported.
+func Audit(file, src string, lines []int) ([]Issue, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	if ast.IsGenerated(f) {
+		return nil, nil
+	}
+	wanted := map[int]bool{}
+	for _, l := range lines {
+		wanted[l] = true
+	}
+	commented, nolint := map[int]bool{}, map[int]bool{}
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			line := fset.Position(c.Pos()).Line
+			commented[line] = true
+			if isNolint(c.Text) {
+				nolint[line] = true
+			}
+		}
+	}
+	info := typeCheck(fset, f)
+	text := strings.Split(src, "\n")
+
+	var issues []Issue
+	add := func(node ast.Node, kind string) {
+		line := fset.Position(node.Pos()).Line
+		if !wanted[line] {
+			return
+		}
+		issues = append(issues, Issue{File: file, Line: line, Kind: kind, Code: strings.TrimSpace(text[line-1])})
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			line := fset.Position(n.Pos()).Line
+			end := fset.Position(n.End()).Line
+			if discardsError(n, info) && !commented[end] && !nolint[line-1] {
+				add(n, Swallowed)
+			}
+		case *ast.CallExpr:
+			format, args, ok := errorf(n)
+			if !ok {
+				break
+			}
+			errs := 0
+			for _, arg := range args {
+				if isError(arg) {
+					errs++
+				}
+			}
+			switch {
+			case errs == 0:
+			case errs == len(args) && onlyVerbs(format):
+				add(n, NoContext)
+			case !strings.Contains(format, "%w"):
+				add(n, Unwrapped)
+			}
+		}
+		return true
+	})
+	return issues, nil
+}
+
+// discardsError reports whether an assignment discards an error result of
+// a call, as in "_ = f.Close()" or "v, _ := strconv.Atoi(s)". Whether the
+// discarded result is an error is taken from info; when the call could not
+// be typed, such as a call into a package outside the standard library,
+// only the unambiguous "_ = f()" is reported, since "v, _ := f()" as often
+// drops an ok flag or a size. Discards of map lookups, type assertions, and
+// channel receives are not calls and are left alone.
+func discardsError(n *ast.AssignStmt, info *gotypes.Info) bool {
+	if len(n.Rhs) != 1 {
+		return false
+	}
+	call, ok := n.Rhs[0].(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	last, ok := n.Lhs[len(n.Lhs)-1].(*ast.Ident)
+	if !ok || last.Name != "_" {
+		return false
+	}
+	typed := info.TypeOf(call)
+	if typed == nil || typed == gotypes.Typ[gotypes.Invalid] {
+		return len(n.Lhs) == 1
+	}
+	if tuple, ok := typed.(*gotypes.Tuple); ok {
+		return tuple.Len() == len(n.Lhs) && isErrorType(tuple.At(tuple.Len()-1).Type())
+	}
+	return len(n.Lhs) == 1 && isErrorType(typed)
+}
+
+// isErrorType reports whether a value of type t is an error.
+func isErrorType(t gotypes.Type) bool {
+	errorType := gotypes.Universe.Lookup("error").Type().Underlying().(*gotypes.Interface)
+	return gotypes.Implements(t, errorType)
+}
+
+// isNolint reports whether a comment tells linters the discard is meant,
+// as "//nolint" or "//nolint:errcheck" do.
+func isNolint(comment string) bool {
+	directive, ok := strings.CutPrefix(comment, "//nolint")
+	if !ok {
+		return false
+	}
+	if directive == "" || strings.HasPrefix(directive, " ") {
+		return true
+	}
+	linters, _, _ := strings.Cut(strings.TrimPrefix(directive, ":"), " ")
+	for _, linter := range strings.Split(linters, ",") {
+		if linter == "errcheck" || linter == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+// stdImports type-checks standard library imports from source, caching
+// them across audits. It is not safe for concurrent use, so typeCheck
+// holds typeMu while using it.
+var (
+	typeMu     sync.Mutex
+	stdImports = importer.ForCompiler(token.NewFileSet(), "source", nil)
+)
+
+// stdImporter imports only the standard library. Other imports are left
+// unresolved, so the audit never builds or downloads modules, and calls
+// into them stay untyped.
+type stdImporter struct{}
+
+func (stdImporter) Import(path string) (*gotypes.Package, error) {
+	if first, _, _ := strings.Cut(path, "/"); strings.Contains(first, ".") || path == "C" {
+		return nil, fmt.Errorf("%s is not in the standard library", path)
+	}
+	return stdImports.Import(path)
+}
+
+// typeCheck types what it can of a file on its own. Declarations in the
+// package's other files are missing, so type errors are expected and
+// ignored; expressions that depend on them are left untyped.
+func typeCheck(fset *token.FileSet, f *ast.File) *gotypes.Info {
+	info := &gotypes.Info{Types: map[ast.Expr]gotypes.TypeAndValue{}}
+	conf := gotypes.Config{Importer: stdImporter{}, Error: func(error) {}}
+	typeMu.Lock()
+	defer typeMu.Unlock()
+	// Type errors go to conf.Error; whatever could be typed is in info.
+	conf.Check(f.Name.Name, fset, []*ast.File{f}, info)
+	return info
+}
+
+// errorf returns the format and arguments of a fmt.Errorf call with a
+// literal format.
+func errorf(call *ast.CallExpr) (string, []ast.Expr, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Errorf" || len(call.Args) == 0 {
+		return "", nil, false
+	}
+	if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "fmt" {
+		return "", nil, false
+	}
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", nil, false
+	}
+	format, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", nil, false
+	}
+	return format, call.Args[1:], true
+}
+
+// onlyVerbs reports whether a format has no words besides its verbs, such
+// as "%w" or "%v: %w".
+func onlyVerbs(format string) bool {
+	if !strings.Contains(format, "%") {
+		return false
+	}
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c == '%' && i+1 < len(format) {
+			// Skip the verb with its flags, width, and precision.
+			i++
+			for i < len(format) && strings.IndexByte("+-# 0123456789.*[]", format[i]) >= 0 {
+				i++
+			}
+			continue
+		}
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// isError reports whether an argument looks like an error: a variable
+// named err or ending in Err, or a call of its Error method.
+func isError(arg ast.Expr) bool {
+	switch a := arg.(type) {
+	case *ast.Ident:
+		return a.Name == "err" || strings.HasSuffix(a.Name, "Err")
+	case *ast.CallExpr:
+		sel, ok := a.Fun.(*ast.SelectorExpr)
+		return ok && sel.Sel.Name == "Error" && len(a.Args) == 0
+	}
+	return false
+}
+
+// Describe explains an issue.
+func (i Issue) Describe() string {
+	switch i.Kind {
+	case Swallowed:
+		return "error is discarded with `_`; handle it, return it, or say in a comment on the same line why it can be ignored"
+	case Unwrapped:
+		return "error is formatted without %w, so callers cannot match it with errors.Is or errors.As; use %w"
+	case NoContext:
+		return "fmt.Errorf adds no context to the error; say what failed, as in \"failed to read config: %w\""
+	}
+	return i.Kind
+}
+
+// Findings converts issues into findings: discarded errors are minor bugs,
+// unwrapped errors minor maintainability findings, and context-free errors
+// maintainability nits.
+func Findings(issues []Issue) []types.Finding {
+	var findings []types.Finding
+	for _, i := range issues {
+		f := types.Finding{
+			File:     i.File,
+			Line:     i.Line,
+			Severity: types.SeverityNit,
+			Source:   source,
+			Message:  i.Describe(),
+			Category: types.CategoryMaintainability,
+		}
+		switch i.Kind {
+		case Swallowed:
+			f.Severity = types.SeverityMinor
+			f.Category = types.CategoryBug
+		case Unwrapped:
+			f.Severity = types.SeverityMinor
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
@@ -0,0 +1,91 @@
+package erraudit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuditDiscards(t *testing.T) {
+	const header = `package p
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"example.com/ext"
+)
+
+var m sync.Map
+
+func f(s string, k any) {
+`
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"closed without checking", `_ = os.Remove(s)`, true},
+		{"conversion error", `n, _ := strconv.Atoi(s); _ = n`, true},
+		{"comma ok", `v, _ := m.Load(k); _ = v`, false},
+		{"load or store", `v, _ := m.LoadOrStore(k, 1); _ = v`, false},
+		{"rune size", `r, _ := utf8.DecodeRuneInString(s); _ = r`, false},
+		{"untyped single discard", `_ = ext.Close()`, true},
+		{"untyped pair", `v, _ := ext.Lookup(k); _ = v`, false},
+		{"trailing comment", `_ = os.Remove(s) // best effort`, false},
+		{"nolint above", "//nolint:errcheck\n\t_ = os.Remove(s)", false},
+		{"unrelated comment above", "// TODO: tidy up\n\t_ = os.Remove(s)", true},
+		{"other linter above", "//nolint:gosec\n\t_ = os.Remove(s)", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := header + "\t" + tt.code + "\n}\n"
+			line := strings.Count(header, "\n") + strings.Count(tt.code, "\n") + 1
+			issues, err := Audit("p.go", src, []int{line})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(issues) == 1 && issues[0].Kind == Swallowed; got != tt.want || len(issues) > 1 {
+				t.Errorf("Audit() = %+v, want discard reported: %v", issues, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditErrorf(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"wrapped", `return fmt.Errorf("failed to read: %w", err)`, ""},
+		{"unwrapped", `return fmt.Errorf("failed to read: %v", err)`, Unwrapped},
+		{"error text", `return fmt.Errorf("failed to read: %s", err.Error())`, Unwrapped},
+		{"no context", `return fmt.Errorf("%w", err)`, NoContext},
+		{"no error", `return fmt.Errorf("bad size %d", 3)`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package p\n\nimport \"fmt\"\n\nfunc f(err error) error {\n\t" + tt.code + "\n}\n"
+			issues, err := Audit("p.go", src, []int{6})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if len(issues) > 0 {
+				got = issues[0].Kind
+			}
+			if got != tt.want || len(issues) > 1 {
+				t.Errorf("Audit() = %+v, want %q", issues, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditGenerated(t *testing.T) {
+	src := "// Code generated by tool. DO NOT EDIT.\n\npackage p\n\nimport \"os\"\n\nfunc f() {\n\t_ = os.Remove(\"x\")\n}\n"
+	if issues, err := Audit("p.go", src, []int{8}); err != nil || len(issues) != 0 {
+		t.Errorf("Audit() = %+v, %v, want nothing for generated code", issues, err)
+	}
+}
@@ -0,0 +1,190 @@
+// Package event reads the payload of the GitHub event that started a run
+// and derives what to review from it: the commits the diff spans, the pull
+// request, if any, and where results can be reported.
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Names of the events a review can run on.
+const (
+	PullRequest              = "pull_request"
+	PullRequestTarget        = "pull_request_target"
+	IssueComment             = "issue_comment"
+	PullRequestReviewComment = "pull_request_review_comment"
+	CheckRun                 = "check_run"
+	Push                     = "push"
+	WorkflowDispatch         = "workflow_dispatch"
+	MergeGroup               = "merge_group"
+)
+
+// Where the results of a review can be reported.
+const (
+	// OutputPullRequest reports on a pull request, with comments, reviews,
+	// and check runs.
+	OutputPullRequest = "pull_request"
+	// OutputCheckRun reports with check runs on the head commit, for
+	// events without a pull request.
+	OutputCheckRun = "check_run"
+)
+
+// zeroSHA stands for a missing commit, such as the parent of a new branch.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// Event is what a run reviews and where it reports.
+type Event struct {
+	Name string
+	Repo string
+	// Base and Head are the commits the diff spans. Base is empty when the
+	// payload does not say, such as for a comment or the first push of a
+	// branch.
+	Base string
+	Head string
+	// PullRequest is the number of the pull request, or 0 for events
+	// without one.
+	PullRequest int
+	Output      string
+}
+
+// HasRange reports whether the event names both ends of its diff.
+func (e Event) HasRange() bool {
+	return e.Base != "" && e.Head != ""
+}
+
+// DiffCommand is the git command printing the event's diff.
+func (e Event) DiffCommand() string {
+	return fmt.Sprintf("git --no-pager diff %s %s", e.Base, e.Head)
+}
+
+// payload holds the fields of every supported event that matter here.
+type payload struct {
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+
+	// pull_request and pull_request_target
+	Number      int `json:"number"`
+	PullRequest struct {
+		Number int `json:"number"`
+		Base   struct {
+			SHA string `json:"sha"`
+		} `json:"base"`
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+
+	// issue_comment
+	Issue struct {
+		Number      int       `json:"number"`
+		PullRequest *struct{} `json:"pull_request"`
+	} `json:"issue"`
+
+	// check_run
+	CheckRun struct {
+		HeadSHA      string `json:"head_sha"`
+		PullRequests []struct {
+			Number int `json:"number"`
+			Base   struct {
+				SHA string `json:"sha"`
+			} `json:"base"`
+		} `json:"pull_requests"`
+	} `json:"check_run"`
+
+	// push
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Deleted bool   `json:"deleted"`
+
+	// workflow_dispatch
+	Inputs map[string]interface{} `json:"inputs"`
+
+	// merge_group
+	MergeGroup struct {
+		BaseSHA string `json:"base_sha"`
+		HeadSHA string `json:"head_sha"`
+	} `json:"merge_group"`
+}
+
+// Load reads the payload of the named event from path.
+func Load(name, path string) (Event, error) {
+	if path == "" {
+		return Event{}, fmt.Errorf("no event payload path set")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to read event file: %w", err)
+	}
+	return Parse(name, data)
+}
+
+// Parse reads the payload of the named event. A workflow_dispatch event
+// takes its range from the base_ref and head_ref inputs of the workflow,
+// with head_ref defaulting to HEAD.
+func Parse(name string, data []byte) (Event, error) {
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Event{}, fmt.Errorf("failed to parse event data: %w", err)
+	}
+	e := Event{Name: name, Repo: p.Repository.FullName, Output: OutputPullRequest}
+	switch name {
+	case PullRequest, PullRequestTarget:
+		e.PullRequest = p.PullRequest.Number
+		if e.PullRequest == 0 {
+			e.PullRequest = p.Number
+		}
+		e.Base, e.Head = p.PullRequest.Base.SHA, p.PullRequest.Head.SHA
+	case IssueComment, PullRequestReviewComment:
+		switch {
+		case p.Issue.Number > 0 && p.Issue.PullRequest != nil:
+			e.PullRequest = p.Issue.Number
+		case p.PullRequest.Number > 0:
+			// Review comment payloads reference the pull request directly.
+			e.PullRequest = p.PullRequest.Number
+			e.Base, e.Head = p.PullRequest.Base.SHA, p.PullRequest.Head.SHA
+		default:
+			return Event{}, fmt.Errorf("comment was not left on a pull request")
+		}
+	case CheckRun:
+		e.Head = p.CheckRun.HeadSHA
+		if len(p.CheckRun.PullRequests) > 0 {
+			e.PullRequest = p.CheckRun.PullRequests[0].Number
+			e.Base = p.CheckRun.PullRequests[0].Base.SHA
+		} else {
+			e.Output = OutputCheckRun
+		}
+	case Push:
+		if p.Deleted {
+			return Event{}, fmt.Errorf("push deleted the branch, so there is nothing to review")
+		}
+		e.Output = OutputCheckRun
+		e.Head = p.After
+		if p.Before != zeroSHA {
+			e.Base = p.Before
+		}
+	case WorkflowDispatch:
+		e.Output = OutputCheckRun
+		e.Base = input(p.Inputs, "base_ref")
+		if e.Head = input(p.Inputs, "head_ref"); e.Head == "" && e.Base != "" {
+			e.Head = "HEAD"
+		}
+	case MergeGroup:
+		e.Output = OutputCheckRun
+		e.Base, e.Head = p.MergeGroup.BaseSHA, p.MergeGroup.HeadSHA
+	default:
+		return Event{}, fmt.Errorf("unsupported event %q", name)
+	}
+	return e, nil
+}
+
+// input returns a workflow_dispatch input as a string.
+func input(inputs map[string]interface{}, name string) string {
+	if v, ok := inputs[name].(string); ok {
+		return strings.TrimSpace(v)
+	}
+	return ""
+}
@@ -0,0 +1,431 @@
+// Package featureflag is an analyzer for feature flag hygiene. It compares
+// the flags defined in a repository's flag files before and after a change
+// with the flags its code references, and reports flags a change adds,
+// flags it removes while code still reads them, code reading flags that
+// are not defined, and flags whose last reference a change removes.
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/analyzer"
+	"github.com/crazywolf132/repo-ranger/pkg/config"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+const source = "feature-flags"
+
+// maxListed bounds the references named in a finding.
+const maxListed = 5
+
+// maxFileSize bounds the files searched for references.
+const maxFileSize = 1 << 20
+
+// DefaultReferences match the flag lookups of common flag SDKs, such as
+// isEnabled("new-checkout"), useFlag('beta'), or
+// client.BoolVariation("dark-mode", ctx, false).
+var DefaultReferences = []string{
+	`\b(?:isEnabled|IsEnabled|isFeatureEnabled|IsFeatureEnabled|featureEnabled|isOn|useFlag|useFeatureFlag|useFeatureIsOn|getFeatureValue|variation|boolVariation|BoolVariation|stringVariation|StringVariation)\(\s*["'` + "`" + `]([\w.:/-]+)["'` + "`" + `]`,
+}
+
+// containerKeys hold the flags of definition files that nest them.
+var containerKeys = []string{"flags", "features", "feature_flags", "featureFlags", "toggles"}
+
+// codeExts are the files searched for references to flags.
+var codeExts = map[string]bool{
+	".go": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".mjs": true, ".cjs": true,
+	".vue": true, ".svelte": true, ".py": true, ".rb": true, ".erb": true, ".java": true, ".kt": true,
+	".scala": true, ".swift": true, ".cs": true, ".php": true, ".rs": true, ".dart": true,
+	".ex": true, ".exs": true, ".c": true, ".cc": true, ".cpp": true, ".h": true, ".hpp": true,
+	".m": true, ".lua": true, ".html": true,
+}
+
+// skippedDirs are not searched for definitions or references.
+var skippedDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "dist": true, "build": true,
+}
+
+// Analyzer reports feature flag hygiene problems. It reads files from the
+// repository's checkout, and their old versions through a diff runner.
+type Analyzer struct {
+	runner   diff.Runner
+	files    []string
+	patterns []*regexp.Regexp
+}
+
+// NewAnalyzer creates a feature flag analyzer for the flags defined in the
+// files matching the files globs, found in code by the references regular
+// expressions, or DefaultReferences when none are given. Each expression's
+// first group is a flag's name.
+func NewAnalyzer(runner diff.Runner, files, references []string) (*Analyzer, error) {
+	if len(references) == 0 {
+		references = DefaultReferences
+	}
+	a := &Analyzer{runner: runner, files: files}
+	for _, ref := range references {
+		re, err := regexp.Compile(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference pattern %q: %w", ref, err)
+		}
+		if re.NumSubexp() == 0 {
+			return nil, fmt.Errorf("reference pattern %q has no group capturing the flag's name", ref)
+		}
+		a.patterns = append(a.patterns, re)
+	}
+	return a, nil
+}
+
+var _ analyzer.Analyzer = (*Analyzer)(nil)
+
+// Name implements analyzer.Analyzer.
+func (a *Analyzer) Name() string {
+	return source
+}
+
+// location is where code references a flag.
+type location struct {
+	file string
+	line int
+}
+
+func (l location) String() string {
+	return fmt.Sprintf("%s:%d", l.file, l.line)
+}
+
+// Analyze implements analyzer.Analyzer.
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) ([]types.Finding, error) {
+	if in.Root == "" {
+		// Without a checkout there are no flag files to compare.
+		return nil, nil
+	}
+	after, err := a.definitions(in.Root)
+	if err != nil {
+		return nil, err
+	}
+	before := map[string]map[string]bool{}
+	for file, names := range after {
+		before[file] = names
+	}
+	var addedRefs []location
+	var addedNames []string
+	removedRefs := map[string]bool{}
+	changed := false
+	for _, f := range in.Files {
+		if f.IsBinary {
+			continue
+		}
+		if a.isDefinition(f.Path()) || a.isDefinition(f.OldPath) {
+			changed = true
+			delete(before, f.Path())
+			if f.IsNew {
+				continue
+			}
+			content, err := a.runner.FileAt(ctx, in.BaseRef, f.OldPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s at %s: %w", f.OldPath, in.BaseRef, err)
+			}
+			if names, err := parseDefinitions(f.OldPath, content); err == nil {
+				before[f.OldPath] = names
+			}
+			continue
+		}
+		if !codeExts[filepath.Ext(f.Path())] {
+			continue
+		}
+		for _, l := range f.AddedLines() {
+			for _, name := range a.referenced(l.Content) {
+				addedRefs = append(addedRefs, location{f.Path(), l.NewLine})
+				addedNames = append(addedNames, name)
+			}
+		}
+		for _, l := range f.RemovedLines() {
+			for _, name := range a.referenced(l.Content) {
+				removedRefs[name] = true
+			}
+		}
+	}
+	if !changed && len(addedRefs) == 0 && len(removedRefs) == 0 {
+		return nil, nil
+	}
+
+	defined, wasDefined := definedIn(after), definedIn(before)
+	var added, removed, orphaned []string
+	for name := range defined {
+		if _, ok := wasDefined[name]; !ok {
+			added = append(added, name)
+		} else if removedRefs[name] {
+			orphaned = append(orphaned, name)
+		}
+	}
+	for name := range wasDefined {
+		if _, ok := defined[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(orphaned)
+
+	var findings []types.Finding
+	var refs map[string][]location
+	if len(added) > 0 || len(removed) > 0 || len(orphaned) > 0 {
+		if refs, err = a.references(ctx, in.Root); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range added {
+		file := defined[name]
+		f := types.Finding{
+			File:     file,
+			Line:     lineOf(in.Root, file, name),
+			Severity: types.SeverityNit,
+			Source:   source,
+			Message:  fmt.Sprintf("feature flag `%s` is added; plan its removal once it is rolled out, so it does not linger as dead code", name),
+			Category: types.CategoryMaintainability,
+		}
+		if len(refs[name]) == 0 {
+			f.Severity = types.SeverityMinor
+			f.Message = fmt.Sprintf("feature flag `%s` is added but no code references it", name)
+		}
+		findings = append(findings, f)
+	}
+	for _, name := range removed {
+		if len(refs[name]) == 0 {
+			continue
+		}
+		findings = append(findings, types.Finding{
+			File:     wasDefined[name],
+			Severity: types.SeverityMajor,
+			Source:   source,
+			Message: fmt.Sprintf("feature flag `%s` is removed from %s but still referenced at %s; remove the references, keeping the code path the flag had rolled out",
+				name, wasDefined[name], listLocations(refs[name])),
+			Category: types.CategoryMaintainability,
+		})
+	}
+	for i, l := range addedRefs {
+		name := addedNames[i]
+		if _, ok := defined[name]; ok {
+			continue
+		}
+		if _, ok := wasDefined[name]; ok {
+			// Reported with the removal of its definition.
+			continue
+		}
+		findings = append(findings, types.Finding{
+			File:     l.file,
+			Line:     l.line,
+			Severity: types.SeverityMinor,
+			Source:   source,
+			Message:  fmt.Sprintf("feature flag `%s` is not defined in any flag file (%s)", name, strings.Join(a.files, ", ")),
+			Category: types.CategoryMaintainability,
+		})
+	}
+	for _, name := range orphaned {
+		if len(refs[name]) > 0 {
+			continue
+		}
+		file := defined[name]
+		findings = append(findings, types.Finding{
+			File:     file,
+			Line:     lineOf(in.Root, file, name),
+			Severity: types.SeverityNit,
+			Source:   source,
+			Message:  fmt.Sprintf("no code references feature flag `%s` any more; remove its definition", name),
+			Category: types.CategoryMaintainability,
+		})
+	}
+	return findings, ctx.Err()
+}
+
+// isDefinition reports whether path is a flag definition file.
+func (a *Analyzer) isDefinition(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, pattern := range a.files {
+		if config.MatchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// referenced returns the names of the flags a line references.
+func (a *Analyzer) referenced(line string) []string {
+	var names []string
+	for _, re := range a.patterns {
+		for _, m := range re.FindAllStringSubmatch(line, -1) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// definitions returns the flags defined in the flag files of the checkout
+// at root, keyed by file.
+func (a *Analyzer) definitions(root string) (map[string]map[string]bool, error) {
+	defs := map[string]map[string]bool{}
+	err := walk(root, func(path string) error {
+		if !a.isDefinition(path) {
+			return nil
+		}
+		content, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			return err
+		}
+		names, err := parseDefinitions(path, string(content))
+		if err != nil {
+			return fmt.Errorf("failed to parse flag file %s: %w", path, err)
+		}
+		defs[path] = names
+		return nil
+	})
+	return defs, err
+}
+
+// references returns where the code of the checkout at root references
+// each flag.
+func (a *Analyzer) references(ctx context.Context, root string) (map[string][]location, error) {
+	refs := map[string][]location{}
+	err := walk(root, func(path string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !codeExts[filepath.Ext(path)] || a.isDefinition(path) {
+			return nil
+		}
+		full := filepath.Join(root, path)
+		if info, err := os.Stat(full); err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		content, err := os.ReadFile(full)
+		if err != nil {
+			return nil
+		}
+		for i, line := range strings.Split(string(content), "\n") {
+			for _, name := range a.referenced(line) {
+				refs[name] = append(refs[name], location{path, i + 1})
+			}
+		}
+		return nil
+	})
+	return refs, err
+}
+
+// walk calls fn with the slash-separated path, relative to root, of each
+// file under root, skipping dependency and build directories.
+func walk(root string, fn func(path string) error) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		return fn(filepath.ToSlash(rel))
+	})
+}
+
+// parseDefinitions returns the flags a definition file defines: the keys
+// of a JSON or YAML file, or of its flags, features, or similar object
+// when it has one, or the keys of the key=value or key: value lines of
+// other files.
+func parseDefinitions(path, content string) (map[string]bool, error) {
+	names := map[string]bool{}
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		if err := json.Unmarshal([]byte(content), &doc); err != nil {
+			return nil, err
+		}
+	case ".yml", ".yaml":
+		if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+			return nil, err
+		}
+	default:
+		for _, line := range strings.Split(content, "\n") {
+			line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "export "))
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "[") {
+				continue
+			}
+			if i := strings.IndexAny(line, "=:"); i > 0 {
+				names[strings.TrimSpace(line[:i])] = true
+			}
+		}
+		return names, nil
+	}
+	for _, key := range containerKeys {
+		if nested, ok := doc[key].(map[string]interface{}); ok {
+			doc = nested
+			break
+		}
+	}
+	for name := range doc {
+		names[name] = true
+	}
+	return names, nil
+}
+
+// definedIn returns the file defining each flag of defs.
+func definedIn(defs map[string]map[string]bool) map[string]string {
+	files := make([]string, 0, len(defs))
+	for file := range defs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	defined := map[string]string{}
+	for _, file := range files {
+		for name := range defs[file] {
+			if _, ok := defined[name]; !ok {
+				defined[name] = file
+			}
+		}
+	}
+	return defined
+}
+
+// lineOf returns the first line of a file of the checkout at root naming a
+// flag, or 0.
+func lineOf(root, file, name string) int {
+	content, err := os.ReadFile(filepath.Join(root, file))
+	if err != nil {
+		return 0
+	}
+	re := regexp.MustCompile(`(?:^|[^\w.-])` + regexp.QuoteMeta(name) + `(?:$|[^\w.-])`)
+	for i, line := range strings.Split(string(content), "\n") {
+		if re.MatchString(line) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// listLocations lists the first locations, counting the rest.
+func listLocations(locs []location) string {
+	var names []string
+	for i, l := range locs {
+		if i == maxListed {
+			names = append(names, fmt.Sprintf("%d more", len(locs)-maxListed))
+			break
+		}
+		names = append(names, l.String())
+	}
+	return strings.Join(names, ", ")
+}
@@ -2,25 +2,293 @@ package github
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/crazywolf132/repo-ranger/pkg/retry"
 	"github.com/crazywolf132/repo-ranger/pkg/types"
 )
 
 // Client represents a GitHub API client.
 type Client interface {
 	PostPRComment(event types.PullRequestEvent, comment string) error
-	CreateCheckRun(review string) error
+	CreateCheckRun(event types.PullRequestEvent, run CheckRun) error
 	PostInlineComments(event types.PullRequestEvent, comments []types.InlineComment) error
+	PostReview(event types.PullRequestEvent, body string, comments []types.InlineComment) error
+	PostFileComments(event types.PullRequestEvent, comments []types.FileComment) error
+	ReplyToReviewComment(event types.PullRequestEvent, commentID int64, body string) error
+	PullRequestHead(event types.PullRequestEvent) (string, error)
+	PullRequestBody(event types.PullRequestEvent) (string, error)
+	UpdatePullRequestBody(event types.PullRequestEvent, body string) error
+	PullRequestDiff(event types.PullRequestEvent) (string, error)
+	FindPullRequest(repo, head, headSHA string) (int, error)
+	GetPullRequest(repo string, number int) (types.PullRequestEvent, error)
+	SearchPullRequests(repo, query string) ([]int, error)
+	SearchPullRequestActivity(query string) ([]PullRequestActivity, error)
+	CompareCommits(repo, base, head string) (Comparison, error)
+	FileContents(repo, path string) ([]byte, error)
+	FileContentsAt(repo, ref, path string) ([]byte, error)
+	BranchProtected(repo, branch string) (bool, error)
+	CommitFiles(repo, branch, parent, message string, files map[string]string) (Commit, error)
+	CommitMessage(event types.PullRequestEvent, sha string) (string, error)
+	ListIssueComments(event types.PullRequestEvent) ([]IssueComment, error)
+	ListReviewComments(event types.PullRequestEvent) ([]ReviewComment, error)
+	ListReviews(event types.PullRequestEvent) ([]Review, error)
+	ListReviewThreads(event types.PullRequestEvent) ([]ReviewThread, error)
+	ResolveReviewThread(threadID string) error
+	ListConversationComments(event types.PullRequestEvent) ([]ConversationComment, error)
+	MinimizeComment(commentID string) error
+	UpdatePRComment(event types.PullRequestEvent, commentID int64, comment string) error
+	CheckPermission(event types.PullRequestEvent, p Permission) error
+	SubmitReview(event types.PullRequestEvent, verdict, body string) error
+	UpdateReview(event types.PullRequestEvent, reviewID int64, body string) error
+	DismissReview(event types.PullRequestEvent, reviewID int64, message string) error
+	AddLabels(event types.PullRequestEvent, labels []string) error
+	CreateIssue(repo string, issue NewIssue) (Issue, error)
+	ListIssues(repo, label string) ([]Issue, error)
+	CloseIssue(repo string, number int, comment string) error
+	AddToProject(project, contentID string) error
+	CreateGist(description, filename, content string) (string, error)
+	CreateDraftRelease(repo, tag, name, body string) (string, error)
+	PublishWikiPage(repo, title, content string) (string, error)
+	Login() (string, error)
+}
+
+// Review verdicts accepted by SubmitReview.
+const (
+	VerdictApprove        = "APPROVE"
+	VerdictRequestChanges = "REQUEST_CHANGES"
+	VerdictComment        = "COMMENT"
+)
+
+// User is the author of a comment or review.
+type User struct {
+	Login string `json:"login"`
+	Type  string `json:"type"` // "User" or "Bot"
+}
+
+// IssueComment is a top-level comment on a pull request.
+type IssueComment struct {
+	ID        int64     `json:"id"`
+	NodeID    string    `json:"node_id"`
+	Body      string    `json:"body"`
+	User      User      `json:"user"`
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReviewComment is an inline comment on a line of the pull request diff.
+type ReviewComment struct {
+	ID          int64     `json:"id"`
+	NodeID      string    `json:"node_id"`
+	Body        string    `json:"body"`
+	User        User      `json:"user"`
+	Path        string    `json:"path"`
+	Line        int       `json:"line"`
+	StartLine   int       `json:"start_line"`
+	Side        string    `json:"side"`
+	CommitID    string    `json:"commit_id"`
+	InReplyToID int64     `json:"in_reply_to_id"`
+	DiffHunk    string    `json:"diff_hunk"`
+	HTMLURL     string    `json:"html_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Review is a submitted pull request review.
+type Review struct {
+	ID          int64     `json:"id"`
+	NodeID      string    `json:"node_id"`
+	Body        string    `json:"body"`
+	User        User      `json:"user"`
+	State       string    `json:"state"` // APPROVED, CHANGES_REQUESTED, COMMENTED, ...
+	CommitID    string    `json:"commit_id"`
+	HTMLURL     string    `json:"html_url"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// Issue is an issue in a repository.
+type Issue struct {
+	Number  int    `json:"number"`
+	NodeID  string `json:"node_id"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	// PullRequest is set when the issue is a pull request.
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+// NewIssue describes an issue to create.
+type NewIssue struct {
+	Title     string   `json:"title"`
+	Body      string   `json:"body"`
+	Labels    []string `json:"labels,omitempty"`
+	Milestone int      `json:"milestone,omitempty"`
+}
+
+// CheckRun describes a completed check run to create.
+type CheckRun struct {
+	Name       string
+	HeadSHA    string
+	Conclusion string // success, neutral, failure, ...
+	Title      string
+	Summary    string
+	// DetailsURL links to where the run can be followed up, such as the
+	// workflow run's logs; GitHub expects it on action_required runs.
+	DetailsURL string
+	// Actions are buttons shown on the run; clicking one sends a check_run
+	// event with the requested_action.
+	Actions []CheckRunAction
+}
+
+// CheckRunAction is a button on a check run.
+type CheckRunAction struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Identifier  string `json:"identifier"`
+}
+
+// FindingMarker is a hidden marker identifying inline comments posted by
+// repo-ranger.
+const FindingMarker = "<!-- repo-ranger:finding -->"
+
+// severityMarker records the severity of a finding in its comment, so it
+// can be read back from the review thread.
+func severityMarker(severity types.Severity) string {
+	if severity == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n<!-- repo-ranger:severity=%s -->", severity)
+}
+
+// idMarker records the ID of a finding in its comment, so the summary can
+// link to the comment.
+func idMarker(id string) string {
+	if id == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n<!-- repo-ranger:id=%s -->", id)
+}
+
+// ChunkMarkerPrefix starts the hidden marker naming the chunk of the diff
+// whose review produced a finding.
+const ChunkMarkerPrefix = "<!-- repo-ranger:chunk="
+
+// ChunkMarker records the correlation ID of the chunk a finding came from,
+// so it can be traced to the exchange with the model.
+func ChunkMarker(id string) string {
+	return ChunkMarkerPrefix + id + " -->"
+}
+
+// ChunkID returns the chunk ID recorded in body, or "" when there is none.
+func ChunkID(body string) string {
+	return markerValue(body, ChunkMarkerPrefix)
+}
+
+// FindingID returns the ID recorded in the body of a finding's comment, or
+// "" when there is none.
+func FindingID(body string) string {
+	return markerValue(body, "<!-- repo-ranger:id=")
+}
+
+// FindingSeverity returns the severity recorded in the body of a finding's
+// comment, or "" when there is none.
+func FindingSeverity(body string) types.Severity {
+	return types.Severity(markerValue(body, "<!-- repo-ranger:severity="))
+}
+
+// markerValue returns the value of the hidden marker starting with prefix
+// in body, or "" when there is none.
+func markerValue(body, prefix string) string {
+	i := strings.Index(body, prefix)
+	if i < 0 {
+		return ""
+	}
+	rest := body[i+len(prefix):]
+	end := strings.Index(rest, " -->")
+	if end < 0 {
+		return ""
+	}
+	return rest[:end]
+}
+
+// maxCheckRunSummary is the largest summary GitHub accepts for a check run.
+const maxCheckRunSummary = 65535
+
+// MaxCommentLength is the largest body GitHub accepts for a comment.
+const MaxCommentLength = 65536
+
+// DefaultRetryPolicy is used unless the client is configured otherwise.
+var DefaultRetryPolicy = retry.Policy{
+	Attempts:  3,
+	BaseDelay: time.Second,
+	MaxDelay:  10 * time.Second,
 }
 
 type client struct {
 	token      string
 	httpClient HTTPClient
+	retry      retry.Policy
+	// gistToken authenticates CreateGist; the token of a workflow or app
+	// installation cannot create gists.
+	gistToken string
+	writes    *writePacer
+}
+
+// writePacer spaces out writes, as GitHub asks of clients creating content
+// to avoid its secondary rate limits.
+type writePacer struct {
+	mu   sync.Mutex
+	pace time.Duration
+	last time.Time
+}
+
+// wait blocks until the pace allows another write.
+func (p *writePacer) wait() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if wait := p.pace - time.Since(p.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	p.last = time.Now()
+}
+
+// DefaultWritePace is the least time between writes GitHub recommends.
+const DefaultWritePace = time.Second
+
+// ClientOption is a function that configures a client.
+type ClientOption func(*client)
+
+// WithRetryPolicy sets the retry policy for GitHub API calls.
+func WithRetryPolicy(policy retry.Policy) ClientOption {
+	return func(c *client) {
+		c.retry = policy
+	}
+}
+
+// WithGistToken sets the token used to create gists. It needs the gist
+// scope.
+func WithGistToken(token string) ClientOption {
+	return func(c *client) {
+		c.gistToken = token
+	}
+}
+
+// WithWritePace sets the least time between writes; 0 sends them as fast
+// as they come.
+func WithWritePace(pace time.Duration) ClientOption {
+	return func(c *client) {
+		c.writes.pace = pace
+	}
 }
 
 // HTTPClient represents the interface for making HTTP requests.
@@ -29,14 +297,20 @@ type HTTPClient interface {
 }
 
 // NewClient creates a new GitHub client.
-func NewClient(token string, httpClient HTTPClient) Client {
+func NewClient(token string, httpClient HTTPClient, opts ...ClientOption) Client {
 	if httpClient == nil {
 		httpClient = &http.Client{}
 	}
-	return &client{
+	c := &client{
 		token:      token,
 		httpClient: httpClient,
+		retry:      DefaultRetryPolicy,
+		writes:     &writePacer{pace: DefaultWritePace},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *client) PostPRComment(event types.PullRequestEvent, comment string) error {
@@ -47,16 +321,117 @@ func (c *client) PostPRComment(event types.PullRequestEvent, comment string) err
 	return c.postToGitHub(url, payload)
 }
 
-func (c *client) CreateCheckRun(review string) error {
-	// Implementation would depend on your specific GitHub Check Run requirements
-	// This is a placeholder for the actual implementation
-	log.Info("Creating GitHub Check Run")
-	return nil
+// UpdatePRComment replaces the body of a top-level comment on the pull
+// request.
+func (c *client) UpdatePRComment(event types.PullRequestEvent, commentID int64, comment string) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/issues/comments/%d",
+		event.Repository.FullName, commentID)
+	return c.sendToGitHub("PATCH", url, map[string]string{"body": comment})
+}
+
+func (c *client) CreateCheckRun(event types.PullRequestEvent, run CheckRun) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/check-runs", event.Repository.FullName)
+
+	headSHA := run.HeadSHA
+	if headSHA == "" {
+		headSHA = event.PullRequest.Head.SHA
+	}
+	if headSHA == "" {
+		return fmt.Errorf("no head SHA to attach check run %q to", run.Name)
+	}
+	conclusion := run.Conclusion
+	if conclusion == "" {
+		conclusion = "neutral"
+	}
+	summary := run.Summary
+	if len(summary) > maxCheckRunSummary {
+		summary = summary[:maxCheckRunSummary-len("\n\n[truncated]")] + "\n\n[truncated]"
+	}
+
+	log.WithField("name", run.Name).Info("Creating GitHub Check Run")
+	payload := map[string]interface{}{
+		"name":       run.Name,
+		"head_sha":   headSHA,
+		"status":     "completed",
+		"conclusion": conclusion,
+		"output": map[string]string{
+			"title":   run.Title,
+			"summary": summary,
+		},
+	}
+	if run.DetailsURL != "" {
+		payload["details_url"] = run.DetailsURL
+	}
+	if len(run.Actions) > 0 {
+		payload["actions"] = run.Actions
+	}
+	return c.postToGitHub(url, payload)
 }
 
+// PostInlineComments posts the comments as a single review, so they
+// notify once rather than once per comment.
 func (c *client) PostInlineComments(event types.PullRequestEvent, comments []types.InlineComment) error {
+	return c.PostReview(event, "", comments)
+}
+
+// PostReview submits a comment-only review with body and the inline
+// comments. When GitHub rejects the batch, usually over a line outside the
+// diff, the body and comments are posted separately, skipping the ones it
+// rejects.
+func (c *client) PostReview(event types.PullRequestEvent, body string, comments []types.InlineComment) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/reviews",
+		event.Repository.FullName, event.PullRequest.Number)
+
+	batch := make([]map[string]interface{}, 0, len(comments))
+	for _, comment := range comments {
+		side := comment.Side
+		if side == "" {
+			side = types.SideRight
+		}
+		entry := map[string]interface{}{
+			"body": inlineCommentBody(comment),
+			"path": comment.File,
+			"line": comment.Line,
+			"side": side,
+		}
+		if comment.StartLine > 0 && comment.StartLine < comment.Line {
+			entry["start_line"] = comment.StartLine
+			entry["start_side"] = side
+		}
+		batch = append(batch, entry)
+	}
+	payload := map[string]interface{}{"event": VerdictComment, "body": body, "comments": batch}
+	if event.PullRequest.Head.SHA != "" {
+		payload["commit_id"] = event.PullRequest.Head.SHA
+	}
+	err := c.postToGitHub(url, payload)
+	var validation *ErrValidation
+	if !errors.As(err, &validation) {
+		return err
+	}
+	log.WithError(err).WithField("comments", len(comments)).Warn("GitHub rejected the batched review; posting its comments one by one")
+	if body != "" {
+		if err := c.PostPRComment(event, body); err != nil {
+			return err
+		}
+	}
+	return c.postInlineCommentsSeparately(event, comments)
+}
+
+func (c *client) postInlineCommentsSeparately(event types.PullRequestEvent, comments []types.InlineComment) error {
 	for _, comment := range comments {
-		if err := c.postInlineComment(event, comment); err != nil {
+		err := c.postInlineComment(event, comment)
+		var validation *ErrValidation
+		if errors.As(err, &validation) {
+			// Usually a line outside the diff; skip it rather than losing
+			// the remaining comments.
+			log.WithError(err).WithFields(log.Fields{
+				"file": comment.File,
+				"line": comment.Line,
+			}).Warn("GitHub rejected inline comment; skipping it")
+			continue
+		}
+		if err != nil {
 			return fmt.Errorf("failed to post inline comment: %w", err)
 		}
 	}
@@ -68,40 +443,674 @@ func (c *client) postInlineComment(event types.PullRequestEvent, comment types.I
 		event.Repository.FullName, event.PullRequest.Number)
 
 	payload := map[string]interface{}{
-		"body":     fmt.Sprintf("%s\n\nReasoning: %s", comment.Suggestion, comment.Reasoning),
-		"path":     comment.File,
-		"line":     comment.Line,
-		"position": comment.Line,
+		"body": inlineCommentBody(comment),
+		"path": comment.File,
+		"line": comment.Line,
+	}
+	switch {
+	case comment.Side == types.SideLeft:
+		// Comments on removed code are addressed by old-file line number.
+		payload["side"] = types.SideLeft
+	case comment.StartLine > 0 && comment.StartLine < comment.Line:
+		payload["side"] = types.SideRight
+		payload["start_line"] = comment.StartLine
+		payload["start_side"] = types.SideRight
+	default:
+		payload["position"] = comment.Line
+	}
+	if event.PullRequest.Head.SHA != "" {
+		payload["commit_id"] = event.PullRequest.Head.SHA
 	}
 
 	return c.postToGitHub(url, payload)
 }
 
-func (c *client) postToGitHub(url string, payload interface{}) error {
-	jsonData, err := json.Marshal(payload)
+func inlineCommentBody(comment types.InlineComment) string {
+	var heading string
+	if comment.ID != "" {
+		heading = "**" + comment.ID + "**\n\n"
+	}
+	var chunk string
+	if comment.Chunk != "" {
+		chunk = "\n" + ChunkMarker(comment.Chunk)
+	}
+	return fmt.Sprintf("%s%s\n\nReasoning: %s%s\n\n%s%s%s%s", heading, suggestionBlock(comment), comment.Reasoning,
+		findingNote(comment.Category, comment.Confidence), FindingMarker, severityMarker(comment.Severity), idMarker(comment.ID), chunk)
+}
+
+// suggestionBlock renders a comment's suggestion as a suggested change the
+// author can apply, replacing the commented lines. Suggestions on removed
+// code cannot be applied, so they are shown as plain code.
+func suggestionBlock(comment types.InlineComment) string {
+	code := strings.Trim(comment.Suggestion, "\n")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	lang := "suggestion"
+	if comment.Side == types.SideLeft {
+		lang = ""
+	}
+	fence := "```"
+	for strings.Contains(code, fence) {
+		// The code holds a fence of its own.
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+// findingNote shows the category of a finding and the model's confidence
+// in it, so readers can judge how much to trust it.
+func findingNote(category types.Category, confidence *types.Confidence) string {
+	var parts []string
+	if category != "" {
+		parts = append(parts, "Category: "+string(category))
+	}
+	if confidence != nil {
+		parts = append(parts, "Confidence: "+confidence.String())
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "\n\n_" + strings.Join(parts, " · ") + "_"
+}
+
+// PostFileComments posts review comments attached to whole files rather
+// than to lines.
+func (c *client) PostFileComments(event types.PullRequestEvent, comments []types.FileComment) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/comments",
+		event.Repository.FullName, event.PullRequest.Number)
+
+	for _, comment := range comments {
+		body := comment.Summary
+		if comment.Severity != "" {
+			body = fmt.Sprintf("%s **%s** %s", comment.Severity.Badge(), strings.ToUpper(string(comment.Severity)), body)
+		}
+		body += findingNote(comment.Category, comment.Confidence)
+		payload := map[string]interface{}{
+			"body":         body + "\n\n" + FindingMarker + severityMarker(comment.Severity),
+			"path":         comment.File,
+			"subject_type": "file",
+			"commit_id":    event.PullRequest.Head.SHA,
+		}
+		err := c.postToGitHub(url, payload)
+		var validation *ErrValidation
+		if errors.As(err, &validation) {
+			log.WithError(err).WithField("file", comment.File).Warn("GitHub rejected file comment; skipping it")
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to post file comment: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReplyToReviewComment posts a reply in the thread of an existing review comment.
+func (c *client) ReplyToReviewComment(event types.PullRequestEvent, commentID int64, body string) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/comments/%d/replies",
+		event.Repository.FullName, event.PullRequest.Number, commentID)
+
+	payload := map[string]string{"body": body}
+	return c.postToGitHub(url, payload)
+}
+
+// SubmitReview submits a pull request review with the given verdict.
+func (c *client) SubmitReview(event types.PullRequestEvent, verdict, body string) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/reviews",
+		event.Repository.FullName, event.PullRequest.Number)
+
+	payload := map[string]string{"event": verdict, "body": body}
+	if event.PullRequest.Head.SHA != "" {
+		payload["commit_id"] = event.PullRequest.Head.SHA
+	}
+	return c.postToGitHub(url, payload)
+}
+
+// UpdateReview replaces the body of a submitted review, keeping its verdict.
+func (c *client) UpdateReview(event types.PullRequestEvent, reviewID int64, body string) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/reviews/%d",
+		event.Repository.FullName, event.PullRequest.Number, reviewID)
+	return c.sendToGitHub("PUT", url, map[string]string{"body": body})
+}
+
+// DismissReview dismisses a submitted review, withdrawing its verdict.
+func (c *client) DismissReview(event types.PullRequestEvent, reviewID int64, message string) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/reviews/%d/dismissals",
+		event.Repository.FullName, event.PullRequest.Number, reviewID)
+	return c.sendToGitHub("PUT", url, map[string]string{"message": message, "event": "DISMISS"})
+}
+
+// AddLabels adds labels to the pull request, creating any the repository
+// does not have yet.
+func (c *client) AddLabels(event types.PullRequestEvent, labels []string) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/issues/%d/labels",
+		event.Repository.FullName, event.PullRequest.Number)
+	return c.postToGitHub(url, map[string][]string{"labels": labels})
+}
+
+// CreateGist creates a secret gist with a single file and returns its URL.
+func (c *client) CreateGist(description, filename, content string) (string, error) {
+	if c.gistToken == "" {
+		return "", fmt.Errorf("no gist token set")
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"description": description,
+		"public":      false,
+		"files":       map[string]map[string]string{filename: {"content": content}},
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	gists := *c
+	gists.token = c.gistToken
+	body, _, err := gists.do("POST", "https://api.github.com/gists", payload)
+	if err != nil {
+		return "", err
 	}
+	var gist struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(body, &gist); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return gist.HTMLURL, nil
+}
+
+// CreateDraftRelease creates a draft release of tag in repo and returns
+// its URL. The tag need not exist yet; it is created when the release is
+// published.
+func (c *client) CreateDraftRelease(repo, tag, name, body string) (string, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/releases", repo)
+	payload, err := json.Marshal(map[string]interface{}{
+		"tag_name": tag,
+		"name":     name,
+		"body":     body,
+		"draft":    true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	respBody, _, err := c.do("POST", url, payload)
+	if err != nil {
+		return "", err
+	}
+	var release struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(respBody, &release); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return release.HTMLURL, nil
+}
+
+// CreateIssue opens an issue in repo.
+func (c *client) CreateIssue(repo string, issue NewIssue) (Issue, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/issues", repo)
+	payload, err := json.Marshal(issue)
+	if err != nil {
+		return Issue{}, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	body, _, err := c.do("POST", url, payload)
+	if err != nil {
+		return Issue{}, err
+	}
+	var created Issue
+	if err := json.Unmarshal(body, &created); err != nil {
+		return Issue{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return created, nil
+}
+
+// ListIssues returns the open issues in repo with the label, leaving out
+// pull requests.
+func (c *client) ListIssues(repo, label string) ([]Issue, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/issues?state=open&per_page=100&labels=%s",
+		repo, neturl.QueryEscape(label))
+
+	var issues []Issue
+	err := c.listFromGitHub(url, func(page []byte) error {
+		var batch []Issue
+		if err := json.Unmarshal(page, &batch); err != nil {
+			return err
+		}
+		for _, issue := range batch {
+			if issue.PullRequest == nil {
+				issues = append(issues, issue)
+			}
+		}
+		return nil
+	})
+	return issues, err
+}
+
+// CloseIssue comments on an issue in repo and closes it as completed.
+func (c *client) CloseIssue(repo string, number int, comment string) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/issues/%d", repo, number)
+	if comment != "" {
+		if err := c.postToGitHub(url+"/comments", map[string]string{"body": comment}); err != nil {
+			return err
+		}
+	}
+	return c.sendToGitHub("PATCH", url, map[string]string{"state": "closed", "state_reason": "completed"})
+}
+
+// PullRequestHead returns the current head commit SHA of the pull request.
+func (c *client) PullRequestHead(event types.PullRequestEvent) (string, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d",
+		event.Repository.FullName, event.PullRequest.Number)
+
+	var pr struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	if err := c.getFromGitHub(url, &pr); err != nil {
+		return "", err
+	}
+	return pr.Head.SHA, nil
+}
+
+// PullRequestBody returns the current description of the pull request.
+func (c *client) PullRequestBody(event types.PullRequestEvent) (string, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d",
+		event.Repository.FullName, event.PullRequest.Number)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	var pr struct {
+		Body string `json:"body"`
+	}
+	if err := c.getFromGitHub(url, &pr); err != nil {
+		return "", err
+	}
+	return pr.Body, nil
+}
+
+// UpdatePullRequestBody replaces the description of the pull request.
+func (c *client) UpdatePullRequestBody(event types.PullRequestEvent, body string) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d",
+		event.Repository.FullName, event.PullRequest.Number)
+	return c.sendToGitHub("PATCH", url, map[string]string{"body": body})
+}
+
+// PullRequestDiff returns the unified diff of the pull request.
+func (c *client) PullRequestDiff(event types.PullRequestEvent) (string, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d",
+		event.Repository.FullName, event.PullRequest.Number)
+
+	body, _, err := c.doAccept("GET", url, "application/vnd.github.v3.diff", nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", err
 	}
+	return string(body), nil
+}
+
+// FileContents returns a file from the default branch of repo. It returns
+// ErrNotFound when the file or repository does not exist.
+func (c *client) FileContents(repo, path string) ([]byte, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/contents/%s", repo, path)
+	body, _, err := c.doAccept("GET", url, "application/vnd.github.raw", nil)
+	return body, err
+}
 
-	req.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+// FindPullRequest returns the number of the open pull request in repo
+// whose head is the branch head ("owner:branch") at headSHA. It returns
+// ErrNotFound when there is none, e.g. because the branch has moved on.
+func (c *client) FindPullRequest(repo, head, headSHA string) (int, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls?state=open&per_page=100&head=%s",
+		repo, neturl.QueryEscape(head))
 
-	resp, err := c.httpClient.Do(req)
+	number := 0
+	err := c.listFromGitHub(url, func(page []byte) error {
+		var batch []struct {
+			Number int `json:"number"`
+			Head   struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		}
+		if err := json.Unmarshal(page, &batch); err != nil {
+			return err
+		}
+		for _, pr := range batch {
+			if pr.Head.SHA == headSHA && number == 0 {
+				number = pr.Number
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return 0, err
+	}
+	if number == 0 {
+		return 0, fmt.Errorf("%w: no open pull request for %s at %s", ErrNotFound, head, headSHA)
+	}
+	return number, nil
+}
+
+// GetPullRequest returns a pull request in repo as the payload of a
+// pull_request event.
+func (c *client) GetPullRequest(repo string, number int) (types.PullRequestEvent, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d", repo, number)
+
+	var event types.PullRequestEvent
+	if err := c.getFromGitHub(url, &event.PullRequest); err != nil {
+		return event, err
+	}
+	event.Action = "synchronize"
+	event.Repository.FullName = repo
+	return event, nil
+}
+
+// SearchPullRequests returns the numbers of the pull requests in repo
+// matching a search query, such as "is:open label:needs-review".
+func (c *client) SearchPullRequests(repo, query string) ([]int, error) {
+	url := fmt.Sprintf("https://api.github.com/search/issues?per_page=100&q=%s",
+		neturl.QueryEscape(fmt.Sprintf("repo:%s is:pr %s", repo, query)))
+
+	var numbers []int
+	err := c.listFromGitHub(url, func(page []byte) error {
+		var result struct {
+			Items []struct {
+				Number int `json:"number"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal(page, &result); err != nil {
+			return err
+		}
+		for _, item := range result.Items {
+			numbers = append(numbers, item.Number)
+		}
+		return nil
+	})
+	return numbers, err
+}
+
+// PullRequestActivity is a pull request as search results describe it.
+type PullRequestActivity struct {
+	Repo      string
+	Number    int
+	Author    User
+	CreatedAt time.Time
+	// MergedAt is zero for a pull request that was not merged.
+	MergedAt time.Time
+}
+
+// SearchPullRequestActivity returns the pull requests matching a search
+// query across repositories, such as "org:acme is:merged
+// merged:>=2024-01-01". GitHub returns at most the first 1,000.
+func (c *client) SearchPullRequestActivity(query string) ([]PullRequestActivity, error) {
+	url := fmt.Sprintf("https://api.github.com/search/issues?per_page=100&q=%s",
+		neturl.QueryEscape("is:pr "+query))
+
+	var found []PullRequestActivity
+	err := c.listFromGitHub(url, func(page []byte) error {
+		var result struct {
+			Items []struct {
+				Number        int       `json:"number"`
+				User          User      `json:"user"`
+				CreatedAt     time.Time `json:"created_at"`
+				RepositoryURL string    `json:"repository_url"`
+				PullRequest   struct {
+					MergedAt *time.Time `json:"merged_at"`
+				} `json:"pull_request"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal(page, &result); err != nil {
+			return err
+		}
+		for _, item := range result.Items {
+			pr := PullRequestActivity{
+				Repo:      strings.TrimPrefix(item.RepositoryURL, "https://api.github.com/repos/"),
+				Number:    item.Number,
+				Author:    item.User,
+				CreatedAt: item.CreatedAt,
+			}
+			if item.PullRequest.MergedAt != nil {
+				pr.MergedAt = *item.PullRequest.MergedAt
+			}
+			found = append(found, pr)
+		}
+		return nil
+	})
+	return found, err
+}
+
+// Comparison is how a commit differs from another, counted from their merge
+// base.
+type Comparison struct {
+	// AheadBy counts the commits of head that base lacks, and Files are
+	// the files they change.
+	AheadBy int
+	Files   []string
+}
+
+// CompareCommits compares head with base, which may be commits or branch
+// names. Files lists at most the 300 files GitHub returns.
+func (c *client) CompareCommits(repo, base, head string) (Comparison, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/compare/%s...%s?per_page=1",
+		repo, base, head)
+
+	var result struct {
+		AheadBy int `json:"ahead_by"`
+		Files   []struct {
+			Filename string `json:"filename"`
+		} `json:"files"`
+	}
+	if err := c.getFromGitHub(url, &result); err != nil {
+		return Comparison{}, err
+	}
+	comparison := Comparison{AheadBy: result.AheadBy}
+	for _, f := range result.Files {
+		comparison.Files = append(comparison.Files, f.Filename)
+	}
+	return comparison, nil
+}
+
+// CommitMessage returns the full message of a commit in the repository.
+func (c *client) CommitMessage(event types.PullRequestEvent, sha string) (string, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/commits/%s", event.Repository.FullName, sha)
+
+	var commit struct {
+		Commit struct {
+			Message string `json:"message"`
+		} `json:"commit"`
+	}
+	if err := c.getFromGitHub(url, &commit); err != nil {
+		return "", err
 	}
-	defer resp.Body.Close()
+	return commit.Commit.Message, nil
+}
+
+// ListIssueComments returns every top-level comment on the pull request.
+func (c *client) ListIssueComments(event types.PullRequestEvent) ([]IssueComment, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/issues/%d/comments?per_page=100",
+		event.Repository.FullName, event.PullRequest.Number)
+
+	var comments []IssueComment
+	err := c.listFromGitHub(url, func(page []byte) error {
+		var batch []IssueComment
+		if err := json.Unmarshal(page, &batch); err != nil {
+			return err
+		}
+		comments = append(comments, batch...)
+		return nil
+	})
+	return comments, err
+}
 
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("GitHub API returned status %d: %s", resp.StatusCode, string(body))
+// ListReviewComments returns every inline review comment on the pull request.
+func (c *client) ListReviewComments(event types.PullRequestEvent) ([]ReviewComment, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/comments?per_page=100",
+		event.Repository.FullName, event.PullRequest.Number)
+
+	var comments []ReviewComment
+	err := c.listFromGitHub(url, func(page []byte) error {
+		var batch []ReviewComment
+		if err := json.Unmarshal(page, &batch); err != nil {
+			return err
+		}
+		comments = append(comments, batch...)
+		return nil
+	})
+	return comments, err
+}
+
+// ListReviews returns every review submitted on the pull request.
+func (c *client) ListReviews(event types.PullRequestEvent) ([]Review, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/reviews?per_page=100",
+		event.Repository.FullName, event.PullRequest.Number)
+
+	var reviews []Review
+	err := c.listFromGitHub(url, func(page []byte) error {
+		var batch []Review
+		if err := json.Unmarshal(page, &batch); err != nil {
+			return err
+		}
+		reviews = append(reviews, batch...)
+		return nil
+	})
+	return reviews, err
+}
+
+// listFromGitHub fetches url and every following page named by the Link
+// header, passing each page's body to add.
+func (c *client) listFromGitHub(url string, add func(page []byte) error) error {
+	for url != "" {
+		body, header, err := c.do("GET", url, nil)
+		if err != nil {
+			return err
+		}
+		if err := add(body); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+		url = nextPageURL(header.Get("Link"))
+	}
+	return nil
+}
+
+// nextPageURL extracts the rel="next" URL from a Link header, or returns ""
+// on the last page.
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		sections := strings.Split(part, ";")
+		if len(sections) < 2 {
+			continue
+		}
+		for _, param := range sections[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(sections[0]), "<>")
+			}
+		}
 	}
+	return ""
+}
 
+func (c *client) getFromGitHub(url string, out interface{}) error {
+	body, _, err := c.do("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
 	return nil
 }
+
+func (c *client) postToGitHub(url string, payload interface{}) error {
+	return c.sendToGitHub("POST", url, payload)
+}
+
+func (c *client) sendToGitHub(method, url string, payload interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	c.writes.wait()
+	_, _, err = c.do(method, url, jsonData)
+	return err
+}
+
+// do sends a request to the GitHub API under the client's retry policy and
+// returns the response body and headers.
+func (c *client) do(method, url string, payload []byte) ([]byte, http.Header, error) {
+	return c.doAccept(method, url, "application/vnd.github.v3+json", payload)
+}
+
+// idempotent reports whether repeating a request is harmless: reads,
+// PUTs, DELETEs, and GraphQL queries, but not REST POSTs and PATCHes or
+// GraphQL mutations, which could post a comment or review twice.
+func idempotent(method, url string, payload []byte) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE":
+		return true
+	case "POST":
+		if url != graphQLEndpoint {
+			return false
+		}
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return false
+		}
+		return strings.HasPrefix(strings.TrimSpace(req.Query), "query")
+	}
+	return false
+}
+
+// doAccept is do with a custom media type, for endpoints that can respond
+// with something other than JSON.
+func (c *client) doAccept(method, url, accept string, payload []byte) ([]byte, http.Header, error) {
+	var body []byte
+	var header http.Header
+	err := retry.Do(context.Background(), c.retry, func() error {
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequest(method, url, reqBody)
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
+		req.Header.Set("Accept", accept)
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			err = fmt.Errorf("failed to send request: %w", err)
+			if !idempotent(method, url, payload) {
+				// The request may have been applied; retrying could
+				// post it twice.
+				return retry.Permanent(err)
+			}
+			return err
+		}
+		defer resp.Body.Close()
+
+		header = resp.Header
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response body: %w", err)
+		}
+		if resp.StatusCode >= 300 {
+			err := newAPIError(resp.StatusCode, resp.Header, body)
+			// Rate-limited requests were not applied, so any request may be
+			// retried; after other failures only idempotent ones are.
+			var rateLimited *ErrRateLimited
+			if errors.As(err, &rateLimited) {
+				return err
+			}
+			if !c.retry.Retryable(resp.StatusCode) || !idempotent(method, url, payload) {
+				return retry.Permanent(err)
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, header, nil
+}
@@ -0,0 +1,30 @@
+package github
+
+import "testing"
+
+func TestIdempotent(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		payload string
+		want    bool
+	}{
+		{"GET", "GET", "https://api.github.com/repos/o/r", "", true},
+		{"PUT", "PUT", "https://api.github.com/repos/o/r/pulls/1/merge", "{}", true},
+		{"DELETE", "DELETE", "https://api.github.com/repos/o/r/issues/comments/1", "", true},
+		{"REST POST", "POST", "https://api.github.com/repos/o/r/issues/1/comments", `{"body":"hi"}`, false},
+		{"PATCH", "PATCH", "https://api.github.com/repos/o/r/issues/comments/1", `{"body":"hi"}`, false},
+		{"GraphQL query", "POST", graphQLEndpoint, `{"query":"  query($owner: String!) { viewer { login } }"}`, true},
+		{"GraphQL mutation", "POST", graphQLEndpoint, `{"query":"mutation { minimizeComment(input: {}) { clientMutationId } }"}`, false},
+		{"GraphQL shorthand", "POST", graphQLEndpoint, `{"query":"{ viewer { login } }"}`, false},
+		{"GraphQL garbage", "POST", graphQLEndpoint, `not json`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idempotent(tt.method, tt.url, []byte(tt.payload)); got != tt.want {
+				t.Errorf("idempotent(%s, %s) = %v, want %v", tt.method, tt.url, got, tt.want)
+			}
+		})
+	}
+}
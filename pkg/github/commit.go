@@ -0,0 +1,117 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+)
+
+// Commit is a commit created on a branch.
+type Commit struct {
+	SHA     string `json:"sha"`
+	HTMLURL string `json:"html_url"`
+}
+
+// FileContentsAt returns a file of repo as of ref, a branch, tag, or
+// commit SHA. It returns ErrNotFound when the file does not exist there.
+func (c *client) FileContentsAt(repo, ref, path string) ([]byte, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/contents/%s?ref=%s", repo, path, neturl.QueryEscape(ref))
+	body, _, err := c.doAccept("GET", url, "application/vnd.github.raw", nil)
+	return body, err
+}
+
+// BranchProtected reports whether branch of repo is protected. Protection
+// rules can require reviews, status checks, or signed commits, or limit who
+// may push, so a token's pushes to a protected branch may be refused.
+func (c *client) BranchProtected(repo, branch string) (bool, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/branches/%s", repo, neturl.PathEscape(branch))
+	var b struct {
+		Protected bool `json:"protected"`
+	}
+	if err := c.getFromGitHub(url, &b); err != nil {
+		return false, err
+	}
+	return b.Protected, nil
+}
+
+// CommitFiles commits new contents of files, keyed by path, on top of
+// parent and moves branch of repo to the commit. It uses the git data API,
+// so no checkout is needed, and keeps each file's mode. Moving the branch
+// fails, rather than forcing, if the branch no longer points at parent.
+func (c *client) CommitFiles(repo, branch, parent, message string, files map[string]string) (Commit, error) {
+	base := fmt.Sprintf("https://api.github.com/repos/%s/git", repo)
+
+	var parentCommit struct {
+		Tree struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+	}
+	if err := c.getFromGitHub(base+"/commits/"+parent, &parentCommit); err != nil {
+		return Commit{}, fmt.Errorf("failed to read commit %s: %w", parent, err)
+	}
+	var tree struct {
+		Tree []struct {
+			Path string `json:"path"`
+			Mode string `json:"mode"`
+		} `json:"tree"`
+	}
+	if err := c.getFromGitHub(base+"/trees/"+parentCommit.Tree.SHA+"?recursive=1", &tree); err != nil {
+		return Commit{}, fmt.Errorf("failed to read tree of %s: %w", parent, err)
+	}
+	modes := map[string]string{}
+	for _, entry := range tree.Tree {
+		modes[entry.Path] = entry.Mode
+	}
+
+	var entries []map[string]string
+	for path, content := range files {
+		mode := modes[path]
+		if mode == "" {
+			// Trees too large to list in full leave some modes unknown.
+			mode = "100644"
+		}
+		entries = append(entries, map[string]string{"path": path, "mode": mode, "type": "blob", "content": content})
+	}
+	var newTree struct {
+		SHA string `json:"sha"`
+	}
+	if err := c.writeForResult("POST", base+"/trees", map[string]interface{}{
+		"base_tree": parentCommit.Tree.SHA,
+		"tree":      entries,
+	}, &newTree); err != nil {
+		return Commit{}, fmt.Errorf("failed to create tree: %w", err)
+	}
+
+	var commit Commit
+	if err := c.writeForResult("POST", base+"/commits", map[string]interface{}{
+		"message": message,
+		"tree":    newTree.SHA,
+		"parents": []string{parent},
+	}, &commit); err != nil {
+		return Commit{}, fmt.Errorf("failed to create commit: %w", err)
+	}
+	if err := c.sendToGitHub("PATCH", base+"/refs/heads/"+branch, map[string]interface{}{
+		"sha":   commit.SHA,
+		"force": false,
+	}); err != nil {
+		return Commit{}, fmt.Errorf("failed to update %s: %w", branch, err)
+	}
+	return commit, nil
+}
+
+// writeForResult is sendToGitHub for writes whose response is needed.
+func (c *client) writeForResult(method, url string, payload, out interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	c.writes.wait()
+	body, _, err := c.do(method, url, data)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
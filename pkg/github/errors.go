@@ -0,0 +1,113 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the resource does not exist or the token
+	// cannot see it.
+	ErrNotFound = errors.New("GitHub resource not found")
+	// ErrForbidden is returned when the token lacks the permissions the
+	// request needs.
+	ErrForbidden = errors.New("GitHub request forbidden")
+)
+
+// APIError is returned for any unsuccessful GitHub API response. It
+// unwraps to ErrNotFound or ErrForbidden where applicable.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Body       string
+	kind       error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitHub API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error { return e.kind }
+
+// ErrRateLimited is returned when the primary or a secondary rate limit was
+// hit. ResetAt is when requests are expected to succeed again.
+type ErrRateLimited struct {
+	ResetAt time.Time
+	Err     *APIError
+}
+
+func (e *ErrRateLimited) Error() string {
+	return fmt.Sprintf("GitHub rate limit exceeded until %s: %v", e.ResetAt.Format(time.RFC3339), e.Err)
+}
+
+func (e *ErrRateLimited) Unwrap() error { return e.Err }
+
+// RetryAfter returns how long until the rate limit lifts, for retry.Do.
+func (e *ErrRateLimited) RetryAfter() time.Duration {
+	if d := time.Until(e.ResetAt); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// ErrValidation is returned when GitHub rejects the request payload, for
+// example an inline comment on a line outside the diff. Field names the
+// first rejected field, when GitHub reports one.
+type ErrValidation struct {
+	Field string
+	Err   *APIError
+}
+
+func (e *ErrValidation) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("GitHub rejected the request: %v", e.Err)
+	}
+	return fmt.Sprintf("GitHub rejected field %q: %v", e.Field, e.Err)
+}
+
+func (e *ErrValidation) Unwrap() error { return e.Err }
+
+// newAPIError classifies an unsuccessful response.
+func newAPIError(statusCode int, header http.Header, body []byte) error {
+	var payload struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	_ = json.Unmarshal(body, &payload)
+
+	apiErr := &APIError{StatusCode: statusCode, Message: payload.Message, Body: string(body)}
+	switch {
+	case statusCode == http.StatusTooManyRequests ||
+		statusCode == http.StatusForbidden && (header.Get("X-RateLimit-Remaining") == "0" || header.Get("Retry-After") != ""):
+		return &ErrRateLimited{ResetAt: rateLimitReset(header), Err: apiErr}
+	case statusCode == http.StatusNotFound:
+		apiErr.kind = ErrNotFound
+	case statusCode == http.StatusForbidden || statusCode == http.StatusUnauthorized:
+		apiErr.kind = ErrForbidden
+	case statusCode == http.StatusUnprocessableEntity:
+		validation := &ErrValidation{Err: apiErr}
+		if len(payload.Errors) > 0 {
+			validation.Field = payload.Errors[0].Field
+		}
+		return validation
+	}
+	return apiErr
+}
+
+// rateLimitReset reads when a rate limit lifts from the response headers,
+// defaulting to one minute from now as GitHub recommends.
+func rateLimitReset(header http.Header) time.Time {
+	if v, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(v) * time.Second)
+	}
+	if v, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		return time.Unix(v, 0)
+	}
+	return time.Now().Add(time.Minute)
+}
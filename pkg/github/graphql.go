@@ -0,0 +1,323 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+const graphQLEndpoint = "https://api.github.com/graphql"
+
+// ReviewThread is a thread of inline review comments.
+type ReviewThread struct {
+	ID         string
+	IsResolved bool
+	// IsOutdated reports whether the commented lines changed since the
+	// thread was started.
+	IsOutdated   bool
+	Path         string
+	Line         int // 0 when the thread is outdated
+	OriginalLine int
+	Comments     []ThreadComment
+}
+
+// ThreadComment is a comment within a review thread.
+type ThreadComment struct {
+	DatabaseID int64
+	Body       string
+	Author     string
+}
+
+const reviewThreadsQuery = `query($owner: String!, $name: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          id isResolved isOutdated path line originalLine
+          comments(first: 50) { nodes { databaseId body author { login } } }
+        }
+      }
+    }
+  }
+}`
+
+// ListReviewThreads returns every review thread on the pull request.
+func (c *client) ListReviewThreads(event types.PullRequestEvent) ([]ReviewThread, error) {
+	owner, name, ok := strings.Cut(event.Repository.FullName, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid repository name %q", event.Repository.FullName)
+	}
+
+	var threads []ReviewThread
+	var cursor *string
+	for {
+		var data struct {
+			Repository struct {
+				PullRequest struct {
+					ReviewThreads struct {
+						PageInfo struct {
+							HasNextPage bool   `json:"hasNextPage"`
+							EndCursor   string `json:"endCursor"`
+						} `json:"pageInfo"`
+						Nodes []struct {
+							ID           string `json:"id"`
+							IsResolved   bool   `json:"isResolved"`
+							IsOutdated   bool   `json:"isOutdated"`
+							Path         string `json:"path"`
+							Line         int    `json:"line"`
+							OriginalLine int    `json:"originalLine"`
+							Comments     struct {
+								Nodes []struct {
+									DatabaseID int64  `json:"databaseId"`
+									Body       string `json:"body"`
+									Author     struct {
+										Login string `json:"login"`
+									} `json:"author"`
+								} `json:"nodes"`
+							} `json:"comments"`
+						} `json:"nodes"`
+					} `json:"reviewThreads"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}
+		vars := map[string]interface{}{
+			"owner":  owner,
+			"name":   name,
+			"number": event.PullRequest.Number,
+			"cursor": cursor,
+		}
+		if err := c.graphQL(reviewThreadsQuery, vars, &data); err != nil {
+			return nil, err
+		}
+
+		page := data.Repository.PullRequest.ReviewThreads
+		for _, n := range page.Nodes {
+			thread := ReviewThread{
+				ID:           n.ID,
+				IsResolved:   n.IsResolved,
+				IsOutdated:   n.IsOutdated,
+				Path:         n.Path,
+				Line:         n.Line,
+				OriginalLine: n.OriginalLine,
+			}
+			for _, cm := range n.Comments.Nodes {
+				thread.Comments = append(thread.Comments, ThreadComment{
+					DatabaseID: cm.DatabaseID,
+					Body:       cm.Body,
+					Author:     cm.Author.Login,
+				})
+			}
+			threads = append(threads, thread)
+		}
+		if !page.PageInfo.HasNextPage {
+			return threads, nil
+		}
+		next := page.PageInfo.EndCursor
+		cursor = &next
+	}
+}
+
+// ConversationComment is a top-level comment on a pull request, as GraphQL
+// reports it.
+type ConversationComment struct {
+	ID          string
+	DatabaseID  int64
+	Body        string
+	Author      string
+	IsMinimized bool
+	CreatedAt   time.Time
+}
+
+const conversationCommentsQuery = `query($owner: String!, $name: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      comments(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes { id databaseId body isMinimized createdAt author { login } }
+      }
+    }
+  }
+}`
+
+// ListConversationComments returns every top-level comment on the pull
+// request, oldest first, with whether it is minimized.
+func (c *client) ListConversationComments(event types.PullRequestEvent) ([]ConversationComment, error) {
+	owner, name, ok := strings.Cut(event.Repository.FullName, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid repository name %q", event.Repository.FullName)
+	}
+
+	var comments []ConversationComment
+	var cursor *string
+	for {
+		var data struct {
+			Repository struct {
+				PullRequest struct {
+					Comments struct {
+						PageInfo struct {
+							HasNextPage bool   `json:"hasNextPage"`
+							EndCursor   string `json:"endCursor"`
+						} `json:"pageInfo"`
+						Nodes []struct {
+							ID          string    `json:"id"`
+							DatabaseID  int64     `json:"databaseId"`
+							Body        string    `json:"body"`
+							IsMinimized bool      `json:"isMinimized"`
+							CreatedAt   time.Time `json:"createdAt"`
+							Author      struct {
+								Login string `json:"login"`
+							} `json:"author"`
+						} `json:"nodes"`
+					} `json:"comments"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}
+		vars := map[string]interface{}{
+			"owner":  owner,
+			"name":   name,
+			"number": event.PullRequest.Number,
+			"cursor": cursor,
+		}
+		if err := c.graphQL(conversationCommentsQuery, vars, &data); err != nil {
+			return nil, err
+		}
+
+		page := data.Repository.PullRequest.Comments
+		for _, n := range page.Nodes {
+			comments = append(comments, ConversationComment{
+				ID:          n.ID,
+				DatabaseID:  n.DatabaseID,
+				Body:        n.Body,
+				Author:      n.Author.Login,
+				IsMinimized: n.IsMinimized,
+				CreatedAt:   n.CreatedAt,
+			})
+		}
+		if !page.PageInfo.HasNextPage {
+			return comments, nil
+		}
+		next := page.PageInfo.EndCursor
+		cursor = &next
+	}
+}
+
+// MinimizeComment collapses a comment, by node ID, as outdated.
+func (c *client) MinimizeComment(commentID string) error {
+	const mutation = `mutation($id: ID!) { minimizeComment(input: {subjectId: $id, classifier: OUTDATED}) { minimizedComment { isMinimized } } }`
+	var data struct{}
+	return c.graphQL(mutation, map[string]interface{}{"id": commentID}, &data)
+}
+
+// ResolveReviewThread marks a review thread as resolved.
+func (c *client) ResolveReviewThread(threadID string) error {
+	const mutation = `mutation($id: ID!) { resolveReviewThread(input: {threadId: $id}) { thread { id } } }`
+	var data struct{}
+	return c.graphQL(mutation, map[string]interface{}{"id": threadID}, &data)
+}
+
+// AddToProject adds an issue or pull request, by node ID, to a project.
+// The project is given by node ID or by URL, such as
+// https://github.com/orgs/acme/projects/3.
+func (c *client) AddToProject(project, contentID string) error {
+	projectID, err := c.projectID(project)
+	if err != nil {
+		return err
+	}
+	const mutation = `mutation($project: ID!, $content: ID!) {
+  addProjectV2ItemById(input: {projectId: $project, contentId: $content}) { item { id } }
+}`
+	var data struct{}
+	return c.graphQL(mutation, map[string]interface{}{"project": projectID, "content": contentID}, &data)
+}
+
+// projectID resolves a project URL to the project's node ID. Anything that
+// is not a URL is taken to be a node ID already.
+func (c *client) projectID(project string) (string, error) {
+	if !strings.HasPrefix(project, "https://") {
+		return project, nil
+	}
+	// https://github.com/{orgs|users}/{login}/projects/{number}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(project, "https://github.com/"), "/"), "/")
+	if len(parts) < 4 || parts[2] != "projects" || (parts[0] != "orgs" && parts[0] != "users") {
+		return "", fmt.Errorf("invalid project URL %q", project)
+	}
+	number, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return "", fmt.Errorf("invalid project URL %q", project)
+	}
+	owner := "organization"
+	if parts[0] == "users" {
+		owner = "user"
+	}
+	query := fmt.Sprintf(`query($login: String!, $number: Int!) { %s(login: $login) { projectV2(number: $number) { id } } }`, owner)
+	var data map[string]struct {
+		ProjectV2 struct {
+			ID string `json:"id"`
+		} `json:"projectV2"`
+	}
+	if err := c.graphQL(query, map[string]interface{}{"login": parts[1], "number": number}, &data); err != nil {
+		return "", fmt.Errorf("failed to look up project: %w", err)
+	}
+	id := data[owner].ProjectV2.ID
+	if id == "" {
+		return "", fmt.Errorf("project %q not found", project)
+	}
+	return id, nil
+}
+
+// Login returns the login of the user or app the token authenticates as.
+func (c *client) Login() (string, error) {
+	var data struct {
+		Viewer struct {
+			Login string `json:"login"`
+		} `json:"viewer"`
+	}
+	if err := c.graphQL(`query { viewer { login } }`, nil, &data); err != nil {
+		return "", err
+	}
+	if data.Viewer.Login == "" {
+		return "", fmt.Errorf("GitHub did not name the authenticated user")
+	}
+	return data.Viewer.Login, nil
+}
+
+// SameLogin reports whether two logins name the same account. GraphQL
+// names apps without the "[bot]" suffix REST gives them, and logins are
+// case-insensitive.
+func SameLogin(a, b string) bool {
+	return a != "" && strings.EqualFold(strings.TrimSuffix(a, "[bot]"), strings.TrimSuffix(b, "[bot]"))
+}
+
+// graphQL runs a GraphQL query and decodes its data into out.
+func (c *client) graphQL(query string, variables map[string]interface{}, out interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	body, _, err := c.do("POST", graphQLEndpoint, payload)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("GitHub GraphQL error: %s", resp.Errors[0].Message)
+	}
+	if err := json.Unmarshal(resp.Data, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
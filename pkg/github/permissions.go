@@ -0,0 +1,76 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// Permission is a kind of write a review can make to a pull request.
+type Permission string
+
+// Permissions a review can need.
+const (
+	PermissionComment Permission = "comment"
+	PermissionChecks  Permission = "checks"
+	PermissionReview  Permission = "review"
+)
+
+// Scope is the workflow permission that grants p.
+func (p Permission) Scope() string {
+	if p == PermissionChecks {
+		return "checks: write"
+	}
+	return "pull-requests: write"
+}
+
+// Action describes what p allows, for error messages.
+func (p Permission) Action() string {
+	switch p {
+	case PermissionComment:
+		return "comment on the pull request"
+	case PermissionChecks:
+		return "create check runs"
+	case PermissionReview:
+		return "post inline comments and reviews"
+	}
+	return string(p)
+}
+
+// CheckPermission probes whether the token has permission p on the pull
+// request by sending an invalid write, which GitHub rejects as invalid when
+// the token may write and as forbidden when it may not, so nothing is
+// created either way. It returns nil when the token has the permission and
+// an error wrapping ErrForbidden or ErrNotFound when it does not; other
+// errors leave the question open.
+func (c *client) CheckPermission(event types.PullRequestEvent, p Permission) error {
+	repo, number := event.Repository.FullName, event.PullRequest.Number
+	var url string
+	var payload map[string]string
+	switch p {
+	case PermissionComment:
+		url = fmt.Sprintf("https://api.github.com/repos/%s/issues/%d/comments", repo, number)
+		payload = map[string]string{}
+	case PermissionChecks:
+		url = fmt.Sprintf("https://api.github.com/repos/%s/check-runs", repo)
+		payload = map[string]string{}
+	case PermissionReview:
+		url = fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/reviews", repo, number)
+		payload = map[string]string{"event": "PREFLIGHT"}
+	default:
+		return fmt.Errorf("unknown permission %q", p)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	_, _, err = c.do("POST", url, data)
+	var validation *ErrValidation
+	if err == nil || errors.As(err, &validation) {
+		return nil
+	}
+	return err
+}
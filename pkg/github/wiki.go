@@ -0,0 +1,81 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/audit"
+)
+
+// wikiTimeout bounds the clone and push of a wiki repository.
+const wikiTimeout = 2 * time.Minute
+
+// PublishWikiPage writes a page to the wiki of repo, replacing any page
+// with the same title, and returns its URL. The wiki has no REST API, so
+// the page is pushed to the wiki's git repository, which only exists once
+// its first page has been created on GitHub.
+func (c *client) PublishWikiPage(repo, title, content string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wikiTimeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "repo-ranger-wiki-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The token is passed as a header through the environment rather than
+	// in the remote URL, where any user could read it in the process list.
+	remote := fmt.Sprintf("https://github.com/%s.wiki.git", repo)
+	credential := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + c.token))
+	env := append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.https://github.com/.extraheader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic "+credential,
+	)
+	git := func(args ...string) error {
+		args = append([]string{"-c", "user.name=repo-ranger", "-c", "user.email=repo-ranger@users.noreply.github.com"}, args...)
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		cmd.Env = env
+		if output, err := cmd.CombinedOutput(); err != nil {
+			message := strings.TrimSpace(string(output))
+			for _, secret := range []string{c.token, credential} {
+				message = strings.ReplaceAll(message, secret, "***")
+			}
+			return fmt.Errorf("git %s failed: %w: %s", args[4], err, message)
+		}
+		return nil
+	}
+
+	if err := git("clone", "--depth", "1", remote, "."); err != nil {
+		return "", err
+	}
+	page := strings.ReplaceAll(title, " ", "-")
+	if err := os.WriteFile(filepath.Join(dir, page+".md"), []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write wiki page: %w", err)
+	}
+	if err := git("add", page+".md"); err != nil {
+		return "", err
+	}
+	if err := git("commit", "--allow-empty", "-m", "Update "+title); err != nil {
+		return "", err
+	}
+	// The push bypasses the HTTP client, so it is audited here.
+	start := time.Now()
+	err = git("push", "origin", "HEAD")
+	if logger := audit.LoggerOf(c.httpClient); logger != nil {
+		logger.Transfer("POST", remote+"/git-receive-pack", []byte(content), start, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://github.com/%s/wiki/%s", repo, page), nil
+}
@@ -0,0 +1,129 @@
+// Package history records the reviews Repo Ranger performs and what
+// became of their findings, so activity can be summarized over time.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// Kinds of record.
+const (
+	// KindReview records a completed review and its findings.
+	KindReview = "review"
+	// KindAddressed records findings a later push addressed.
+	KindAddressed = "addressed"
+)
+
+// Record is one entry in the history.
+type Record struct {
+	Time        time.Time `json:"time"`
+	Kind        string    `json:"kind"`
+	Repo        string    `json:"repo"`
+	PullRequest int       `json:"pull_request,omitempty"`
+	HeadSHA     string    `json:"head_sha,omitempty"`
+	// Cost is the estimated spend of a review in US dollars.
+	Cost     float64   `json:"cost,omitempty"`
+	Findings []Finding `json:"findings,omitempty"`
+	// Addressed counts the findings an addressed record resolves.
+	Addressed int `json:"addressed,omitempty"`
+}
+
+// Finding is a finding of a review.
+type Finding struct {
+	File     string         `json:"file"`
+	Line     int            `json:"line,omitempty"`
+	Severity types.Severity `json:"severity,omitempty"`
+	Category types.Category `json:"category,omitempty"`
+	// Source is "review" for the model's findings and names the check
+	// for deterministic ones.
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
+// Store is a directory of monthly files, one per UTC month, each holding
+// one JSON record per line.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open opens the store in dir, creating the directory if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(at time.Time) string {
+	return filepath.Join(s.dir, at.UTC().Format("2006-01")+".jsonl")
+}
+
+// Append adds a record to the month of its time. Each record is a single
+// append-mode write, so the concurrent reviews of serve mode can share a
+// store.
+func (s *Store) Append(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history record: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path(r.Time), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return f.Close()
+}
+
+// Between returns the records from from up to, but not including, to, in
+// the order they were written. Lines that do not parse, such as one cut
+// short by a crash, are skipped.
+func (s *Store) Between(from, to time.Time) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []Record
+	month := time.Date(from.UTC().Year(), from.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	for ; month.Before(to); month = month.AddDate(0, 1, 0) {
+		f, err := os.Open(s.path(month))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var r Record
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				continue
+			}
+			if !r.Time.Before(from) && r.Time.Before(to) {
+				records = append(records, r)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,271 @@
+// Package i18n is an analyzer for localized projects. When a change
+// touches translation files (JSON under a locales directory, or gettext
+// .po catalogs), it checks that every language of the changed bundles has
+// the same keys; and when the repository has translations, it flags
+// user-facing strings that a change hardcodes in markup instead of reading
+// from them.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/analyzer"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+const source = "i18n"
+
+// maxListed bounds the keys named in a finding.
+const maxListed = 10
+
+// markupExts are the files whose added lines are searched for hardcoded
+// strings.
+var markupExts = map[string]bool{
+	".jsx": true, ".tsx": true, ".vue": true, ".svelte": true, ".html": true,
+}
+
+// skippedDirs are not searched for translation files.
+var skippedDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "dist": true, "build": true,
+}
+
+var (
+	// textNode matches text between tags, such as <button>Save</button>.
+	textNode = regexp.MustCompile(`>([^<>{}]+)<`)
+	// textAttr matches attributes shown to users.
+	textAttr = regexp.MustCompile(`\b(?:placeholder|title|alt|aria-label|label)="([^"{}]+)"`)
+	// translated matches lines that already read from translations.
+	translated = regexp.MustCompile(`\bt\(|\$t\(|\bi18n\b|\bintl\.|<Trans\b|<FormattedMessage\b|\{\{\s*['"].*['"]\s*\|\s*translate`)
+	// word matches a word of prose.
+	word = regexp.MustCompile(`\p{L}{2,}`)
+)
+
+// Analyzer checks translation files and hardcoded user-facing strings. It
+// reads files from the repository's checkout.
+type Analyzer struct{}
+
+// NewAnalyzer creates an i18n analyzer.
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{}
+}
+
+var _ analyzer.Analyzer = (*Analyzer)(nil)
+
+// Name implements analyzer.Analyzer.
+func (a *Analyzer) Name() string {
+	return source
+}
+
+// Analyze implements analyzer.Analyzer.
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) ([]types.Finding, error) {
+	if in.Root == "" {
+		// Without a checkout there are no translations to compare.
+		return nil, nil
+	}
+	var findings []types.Finding
+	checked := map[bundle]bool{}
+	var markup []diff.FileDiff
+	for _, f := range in.Files {
+		if f.IsBinary {
+			continue
+		}
+		switch {
+		case IsResource(f.Path()):
+			b, _, ok := bundleOf(f.Path())
+			if !ok || checked[b] {
+				continue
+			}
+			checked[b] = true
+			findings = append(findings, checkParity(in.Root, b, in.Files)...)
+		case markupExts[filepath.Ext(f.Path())] && !f.IsDeleted:
+			markup = append(markup, f)
+		}
+	}
+	if len(markup) == 0 {
+		return findings, ctx.Err()
+	}
+
+	values, localized := translations(in.Root)
+	if !localized {
+		return findings, ctx.Err()
+	}
+	for _, f := range markup {
+		findings = append(findings, hardcoded(f, values)...)
+	}
+	return findings, ctx.Err()
+}
+
+// checkParity reports the languages of a bundle in the checkout at root
+// missing keys that another language has, and the changed files of the
+// bundle that do not parse.
+func checkParity(root string, b bundle, changed []diff.FileDiff) []types.Finding {
+	touched := map[string]bool{}
+	for _, f := range changed {
+		if !f.IsDeleted {
+			touched[filepath.Clean(f.Path())] = true
+		}
+	}
+
+	var findings []types.Finding
+	entries := map[string]map[string]string{}
+	all := map[string]bool{}
+	files := b.files(root)
+	for lang, path := range files {
+		e, err := loadEntries(filepath.Join(root, path))
+		if err != nil {
+			if touched[path] {
+				findings = append(findings, types.Finding{
+					File:     filepath.ToSlash(path),
+					Severity: types.SeverityMajor,
+					Source:   source,
+					Message:  fmt.Sprintf("translation file does not parse: %v", err),
+					Category: types.CategoryBug,
+				})
+			}
+			continue
+		}
+		entries[lang] = e
+		for key := range e {
+			all[key] = true
+		}
+	}
+	if len(entries) < 2 {
+		return findings
+	}
+
+	langs := map[string]bool{}
+	for lang := range entries {
+		langs[lang] = true
+	}
+	for _, lang := range sortedKeys(langs) {
+		e := entries[lang]
+		missing := map[string]bool{}
+		for key := range all {
+			if _, ok := e[key]; !ok {
+				missing[key] = true
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		keys := sortedKeys(missing)
+		for i, key := range keys {
+			keys[i] = "`" + strings.ReplaceAll(key, "\x04", " | ") + "`"
+		}
+		if len(keys) > maxListed {
+			keys = append(keys[:maxListed], fmt.Sprintf("%d more", len(missing)-maxListed))
+		}
+		what := "key(s)"
+		if filepath.Ext(files[lang]) == ".po" {
+			what = "translation(s)"
+		}
+		findings = append(findings, types.Finding{
+			File:     filepath.ToSlash(files[lang]),
+			Severity: types.SeverityMinor,
+			Source:   source,
+			Message: fmt.Sprintf("%s is missing %d %s that other languages have: %s",
+				lang, len(missing), what, strings.Join(keys, ", ")),
+			Category: types.CategoryBug,
+		})
+	}
+	return findings
+}
+
+// translations returns the translated strings of the checkout at root,
+// mapped to their keys, and whether it has translation files at all.
+func translations(root string) (map[string]string, bool) {
+	values := map[string]string{}
+	localized := false
+	_ = filepath.WalkDir(root, func(full string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if full != root && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		path, err := filepath.Rel(root, full)
+		if err != nil || !IsResource(path) {
+			return nil
+		}
+		if _, _, ok := bundleOf(path); !ok {
+			return nil
+		}
+		localized = true
+		entries, err := loadEntries(full)
+		if err != nil {
+			return nil
+		}
+		for key, value := range entries {
+			if _, ok := values[value]; !ok && value != "" {
+				values[value] = key
+			}
+		}
+		return nil
+	})
+	return values, localized
+}
+
+// hardcoded reports the user-facing strings on a file's added lines that
+// are not read from translations, naming the key of a translation that
+// already holds the string.
+func hardcoded(f diff.FileDiff, values map[string]string) []types.Finding {
+	var findings []types.Finding
+	for _, l := range f.AddedLines() {
+		if translated.MatchString(l.Content) {
+			continue
+		}
+		var texts []string
+		for _, m := range textNode.FindAllStringSubmatch(l.Content, -1) {
+			texts = append(texts, m[1])
+		}
+		for _, m := range textAttr.FindAllStringSubmatch(l.Content, -1) {
+			texts = append(texts, m[1])
+		}
+		for _, text := range texts {
+			text = strings.Join(strings.Fields(text), " ")
+			if !isProse(text) {
+				continue
+			}
+			message := fmt.Sprintf("user-facing string %q is hardcoded and has no translation; add it to the translation files and read it from there", text)
+			if key, ok := values[text]; ok {
+				message = fmt.Sprintf("user-facing string %q is hardcoded, but translations already have it under `%s`; read it from there",
+					text, strings.ReplaceAll(key, "\x04", " | "))
+			}
+			findings = append(findings, types.Finding{
+				File:     f.Path(),
+				Line:     l.NewLine,
+				Severity: types.SeverityMinor,
+				Source:   source,
+				Message:  message,
+				Category: types.CategoryMaintainability,
+			})
+		}
+	}
+	return findings
+}
+
+// isProse reports whether text reads as words for people rather than
+// code, symbols, or an entity: several words, or one capitalized word.
+func isProse(text string) bool {
+	if strings.ContainsAny(text, "=;()[]$") {
+		return false
+	}
+	words := word.FindAllString(text, -1)
+	switch {
+	case len(words) == 0:
+		return false
+	case len(words) > 1:
+		return true
+	}
+	first := []rune(words[0])[0]
+	return strings.ToUpper(string(first)) == string(first) && strings.ToUpper(words[0]) != words[0]
+}
@@ -0,0 +1,202 @@
+package i18n
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// resourceDirs name the directories whose JSON files are translations.
+var resourceDirs = map[string]bool{
+	"locales": true, "locale": true, "i18n": true, "l10n": true, "translations": true, "lang": true,
+}
+
+// localeTag matches language tags such as "en", "pt-BR", or "zh_Hant".
+var localeTag = regexp.MustCompile(`^[a-z]{2,3}(?:[-_][A-Za-z0-9]{2,8})*$`)
+
+// bundle is a set of translation files holding the same keys, one per
+// language, such as locales/en.json and locales/de.json, or
+// locales/en/common.json and locales/de/common.json.
+type bundle struct {
+	// pattern globs every language's file, with the language as its only
+	// wildcard.
+	pattern string
+}
+
+// IsResource reports whether p is a translation file: a gettext .po file,
+// or a JSON file under a locales, i18n, or similar directory.
+func IsResource(p string) bool {
+	p = filepath.ToSlash(p)
+	switch filepath.Ext(p) {
+	case ".po":
+		return true
+	case ".json":
+		for _, dir := range strings.Split(p, "/")[:strings.Count(p, "/")] {
+			if resourceDirs[strings.ToLower(dir)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bundleOf returns the bundle a translation file belongs to, and the
+// file's language.
+func bundleOf(p string) (bundle, string, bool) {
+	dir, base := filepath.Split(p)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	parent := filepath.Base(dir)
+	switch {
+	case ext == ".po" && parent == "LC_MESSAGES":
+		// locale/de/LC_MESSAGES/messages.po
+		langDir := filepath.Dir(filepath.Clean(dir))
+		return bundle{filepath.Join(filepath.Dir(langDir), "*", "LC_MESSAGES", base)}, filepath.Base(langDir), true
+	case ext == ".po" && localeTag.MatchString(name):
+		// po/de.po
+		return bundle{filepath.Join(dir, "*"+ext)}, name, true
+	case localeTag.MatchString(parent) && !resourceDirs[parent]:
+		// locales/de/common.json
+		return bundle{filepath.Join(filepath.Dir(filepath.Clean(dir)), "*", base)}, parent, true
+	case localeTag.MatchString(name):
+		// locales/de.json
+		return bundle{filepath.Join(dir, "*"+ext)}, name, true
+	}
+	return bundle{}, "", false
+}
+
+// files returns the bundle's files in the checkout at root, relative to
+// it and keyed by language.
+func (b bundle) files(root string) map[string]string {
+	matches, _ := filepath.Glob(filepath.Join(root, b.pattern))
+	files := map[string]string{}
+	for _, m := range matches {
+		rel, err := filepath.Rel(root, m)
+		if err != nil {
+			continue
+		}
+		if _, lang, ok := bundleOf(rel); ok && localeTag.MatchString(lang) {
+			files[lang] = rel
+		}
+	}
+	return files
+}
+
+// loadEntries reads the translations of a file, keyed by their key: the
+// dotted path of a JSON value, or the context and msgid of a gettext
+// entry. Untranslated gettext entries are left out.
+func loadEntries(path string) (map[string]string, error) {
+	if filepath.Ext(path) == ".po" {
+		return loadPO(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	entries := map[string]string{}
+	flatten(entries, "", tree)
+	return entries, nil
+}
+
+func flatten(entries map[string]string, prefix string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flatten(entries, key, child)
+		}
+	case string:
+		entries[prefix] = v
+	default:
+		// Arrays, numbers, and plural objects' leaves are kept as keys.
+		entries[prefix] = ""
+	}
+}
+
+// loadPO reads the translated entries of a gettext catalog.
+func loadPO(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := map[string]string{}
+	var context, id, str string
+	var field *string
+	flush := func() {
+		if id != "" && str != "" {
+			key := id
+			if context != "" {
+				key = context + "\x04" + id
+			}
+			entries[key] = str
+		}
+		context, id, str, field = "", "", "", nil
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		keyword, value, _ := strings.Cut(line, " ")
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#"):
+			continue
+		case keyword == "msgctxt":
+			flush()
+			field = &context
+		case keyword == "msgid":
+			if id != "" || str != "" {
+				flush()
+			}
+			field = &id
+		case keyword == "msgid_plural":
+			field = nil
+		case keyword == "msgstr" || keyword == "msgstr[0]":
+			field = &str
+		case strings.HasPrefix(keyword, "msgstr["):
+			field = nil
+		case strings.HasPrefix(line, `"`):
+			// A continuation of the last string.
+			value = line
+		default:
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+		if field != nil && strings.HasPrefix(value, `"`) {
+			s, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", value)
+			}
+			*field += s
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return entries, nil
+}
+
+// sortedKeys returns the keys of a set in order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,183 @@
+// Package locale writes the numbers, amounts, and dates of reports the way
+// a configured locale does, in a configured time zone, for teams reading
+// digests and dashboards outside the US.
+package locale
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// style is how a locale writes numbers and dates. Dates use month names
+// only in English, so no translations are needed.
+type style struct {
+	decimal, group string
+	// currencyAfter writes amounts as "1.234,56 $" rather than "$1,234.56",
+	// and percentSpace percentages as "45 %" rather than "45%".
+	currencyAfter bool
+	percentSpace  bool
+	// date, shortDate, and dateTime are time.Format layouts for a day, a
+	// day without its year, and a moment.
+	date, shortDate, dateTime string
+}
+
+// nbsp keeps a number and its unit, or the groups of a number, on one line.
+const nbsp = "\u00a0"
+
+// styles are keyed by language, or by language and region where the region
+// changes the style.
+var styles = map[string]style{
+	"en":    {".", ",", false, false, "Jan 2, 2006", "Jan 2", "Jan 2, 2006 3:04 PM MST"},
+	"en-gb": {".", ",", false, false, "2 Jan 2006", "2 Jan", "2 Jan 2006 15:04 MST"},
+	"en-au": {".", ",", false, false, "2 Jan 2006", "2 Jan", "2 Jan 2006 15:04 MST"},
+	"en-ie": {".", ",", false, false, "2 Jan 2006", "2 Jan", "2 Jan 2006 15:04 MST"},
+	"en-nz": {".", ",", false, false, "2 Jan 2006", "2 Jan", "2 Jan 2006 15:04 MST"},
+	"en-in": {".", ",", false, false, "2 Jan 2006", "2 Jan", "2 Jan 2006 15:04 MST"},
+	"de":    {",", ".", true, true, "02.01.2006", "02.01.", "02.01.2006 15:04 MST"},
+	"de-ch": {".", "’", true, false, "02.01.2006", "02.01.", "02.01.2006 15:04 MST"},
+	"fr":    {",", nbsp, true, true, "02/01/2006", "02/01", "02/01/2006 15:04 MST"},
+	"es":    {",", ".", true, true, "02/01/2006", "02/01", "02/01/2006 15:04 MST"},
+	"it":    {",", ".", true, false, "02/01/2006", "02/01", "02/01/2006 15:04 MST"},
+	"nl":    {",", ".", false, false, "02-01-2006", "02-01", "02-01-2006 15:04 MST"},
+	"pt":    {",", ".", false, false, "02/01/2006", "02/01", "02/01/2006 15:04 MST"},
+	"pl":    {",", nbsp, true, false, "02.01.2006", "02.01", "02.01.2006 15:04 MST"},
+	"sv":    {",", nbsp, true, true, "2006-01-02", "01-02", "2006-01-02 15:04 MST"},
+	"da":    {",", ".", true, true, "02.01.2006", "02.01", "02.01.2006 15:04 MST"},
+	"nb":    {",", nbsp, true, true, "02.01.2006", "02.01", "02.01.2006 15:04 MST"},
+	"fi":    {",", nbsp, true, true, "2.1.2006", "2.1.", "2.1.2006 15:04 MST"},
+	"ja":    {".", ",", false, false, "2006/01/02", "01/02", "2006/01/02 15:04 MST"},
+	"zh":    {".", ",", false, false, "2006/01/02", "01/02", "2006/01/02 15:04 MST"},
+	"ko":    {".", ",", false, false, "2006. 01. 02.", "01. 02.", "2006. 01. 02. 15:04 MST"},
+}
+
+// Locale writes numbers and dates for a locale and time zone. The zero
+// Locale writes them as in the US, in UTC.
+type Locale struct {
+	name     string
+	style    *style
+	location *time.Location
+}
+
+// Parse reads a locale such as "de-DE", "en_GB", or "fr", and an IANA time
+// zone such as "Europe/Berlin". Empty strings leave the US style and UTC.
+func Parse(tag, zone string) (Locale, error) {
+	var l Locale
+	if tag = strings.TrimSpace(tag); tag != "" {
+		// POSIX locales such as "de_DE.UTF-8" name the same thing.
+		tag, _, _ = strings.Cut(tag, ".")
+		key := strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+		s, ok := styles[key]
+		if !ok {
+			language, _, _ := strings.Cut(key, "-")
+			if s, ok = styles[language]; !ok {
+				return Locale{}, fmt.Errorf("unsupported locale %q", tag)
+			}
+		}
+		l.name, l.style = tag, &s
+	}
+	if zone = strings.TrimSpace(zone); zone != "" {
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			return Locale{}, fmt.Errorf("unknown time zone %q", zone)
+		}
+		l.location = location
+	}
+	return l, nil
+}
+
+func (l Locale) get() style {
+	if l.style == nil {
+		return styles["en"]
+	}
+	return *l.style
+}
+
+// Location is the time zone of the locale, UTC by default.
+func (l Locale) Location() *time.Location {
+	if l.location == nil {
+		return time.UTC
+	}
+	return l.location
+}
+
+// String names the locale and time zone, such as "de-DE Europe/Berlin".
+func (l Locale) String() string {
+	name := l.name
+	if name == "" {
+		name = "en-US"
+	}
+	return name + " " + l.Location().String()
+}
+
+// Midnight returns the start of the day of t in the locale's time zone.
+func (l Locale) Midnight(t time.Time) time.Time {
+	y, m, d := t.In(l.Location()).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, l.Location())
+}
+
+// Int writes a whole number, such as "12,345".
+func (l Locale) Int(n int) string {
+	return l.Number(float64(n), 0)
+}
+
+// Number writes v with the given number of decimals, such as "1,234.5".
+func (l Locale) Number(v float64, decimals int) string {
+	s := l.get()
+	text := strconv.FormatFloat(v, 'f', decimals, 64)
+	if rounded, _ := strconv.ParseFloat(text, 64); rounded == 0 {
+		// A negative number rounding to zero is written without its sign.
+		text = strings.TrimPrefix(text, "-")
+	}
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	whole, fraction, _ := strings.Cut(text, ".")
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(s.group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(s.decimal + fraction)
+	}
+	return sign + b.String()
+}
+
+// Money writes an amount of US dollars with cents, such as "$1,234.56".
+func (l Locale) Money(v float64) string {
+	if l.get().currencyAfter {
+		return l.Number(v, 2) + nbsp + "$"
+	}
+	if v < 0 {
+		return "-$" + l.Number(-v, 2)
+	}
+	return "$" + l.Number(v, 2)
+}
+
+// Percent writes a share from 0 to 1 as a whole percentage, such as "45%".
+func (l Locale) Percent(share float64) string {
+	if l.get().percentSpace {
+		return l.Number(share*100, 0) + nbsp + "%"
+	}
+	return l.Number(share*100, 0) + "%"
+}
+
+// Date writes the day of t, such as "Jan 2, 2006".
+func (l Locale) Date(t time.Time) string {
+	return t.In(l.Location()).Format(l.get().date)
+}
+
+// ShortDate writes the day of t without its year, such as "Jan 2".
+func (l Locale) ShortDate(t time.Time) string {
+	return t.In(l.Location()).Format(l.get().shortDate)
+}
+
+// DateTime writes t to the minute, with its time zone.
+func (l Locale) DateTime(t time.Time) string {
+	return t.In(l.Location()).Format(l.get().dateTime)
+}
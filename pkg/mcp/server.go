@@ -0,0 +1,259 @@
+// Package mcp exposes the review pipeline as Model Context Protocol tools
+// over stdio, so IDE agents and chat clients can run reviews locally.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+)
+
+// ProtocolVersion is the MCP revision the server implements.
+const ProtocolVersion = "2024-11-05"
+
+// Reviewer runs reviews for the server's tools.
+type Reviewer interface {
+	ReviewDiff(ctx context.Context, diffText, focus string) (string, error)
+	Explain(ctx context.Context, diffText, target string) (string, error)
+}
+
+// Server answers MCP requests read as newline-delimited JSON-RPC messages.
+type Server struct {
+	reviewer Reviewer
+	version  string
+	readFile func(path string) ([]byte, error)
+
+	mu  sync.Mutex
+	out *json.Encoder
+}
+
+// ServerOption is a function that configures a server.
+type ServerOption func(*Server)
+
+// WithVersion sets the version reported to clients.
+func WithVersion(version string) ServerOption {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// NewServer creates a server whose tools are backed by reviewer.
+func NewServer(reviewer Reviewer, opts ...ServerOption) *Server {
+	s := &Server{
+		reviewer: reviewer,
+		version:  "dev",
+		readFile: os.ReadFile,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve reads requests from in and writes responses to out until in is
+// exhausted or ctx is done. Tool calls run concurrently, since reviews can
+// take minutes.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	s.out = json.NewEncoder(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			s.reply(response{ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+		if len(req.ID) == 0 {
+			// Notifications, such as notifications/initialized, need no answer.
+			continue
+		}
+		if req.Method == "tools/call" {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.reply(s.handle(ctx, req))
+			}()
+			continue
+		}
+		s.reply(s.handle(ctx, req))
+	}
+	return scanner.Err()
+}
+
+func (s *Server) reply(resp response) {
+	resp.JSONRPC = "2.0"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.out.Encode(resp)
+}
+
+func (s *Server) handle(ctx context.Context, req request) response {
+	resp := response{ID: req.ID}
+	switch req.Method {
+	case "initialize":
+		resp.Result = map[string]interface{}{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "repo-ranger", "version": s.version},
+		}
+	case "ping":
+		resp.Result = map[string]interface{}{}
+	case "tools/list":
+		resp.Result = map[string]interface{}{"tools": tools}
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			resp.Error = &rpcError{Code: codeInvalidParams, Message: err.Error()}
+			return resp
+		}
+		text, err := s.call(ctx, params.Name, params.Arguments)
+		if err != nil {
+			// Tool failures are reported in the result so the model can see them.
+			resp.Result = toolResult(err.Error(), true)
+		} else {
+			resp.Result = toolResult(text, false)
+		}
+	case "":
+		resp.Error = &rpcError{Code: codeInvalidRequest, Message: "missing method"}
+	default:
+		resp.Error = &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("unknown method %q", req.Method)}
+	}
+	return resp
+}
+
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// call runs the named tool.
+func (s *Server) call(ctx context.Context, name string, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Diff   string   `json:"diff"`
+		Paths  []string `json:"paths"`
+		Focus  string   `json:"focus"`
+		Target string   `json:"target"`
+	}
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	switch name {
+	case "review-diff":
+		if strings.TrimSpace(args.Diff) == "" {
+			return "", fmt.Errorf("diff is required")
+		}
+		return s.reviewer.ReviewDiff(ctx, args.Diff, args.Focus)
+	case "review-files":
+		if len(args.Paths) == 0 {
+			return "", fmt.Errorf("paths is required")
+		}
+		var sections []string
+		for _, path := range args.Paths {
+			content, err := s.readFile(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			sections = append(sections, diff.WholeFile(path, string(content)))
+		}
+		return s.reviewer.ReviewDiff(ctx, strings.Join(sections, "\n"), args.Focus)
+	case "explain-change":
+		if strings.TrimSpace(args.Diff) == "" || args.Target == "" {
+			return "", fmt.Errorf("diff and target are required")
+		}
+		return s.reviewer.Explain(ctx, args.Diff, args.Target)
+	}
+	return "", fmt.Errorf("unknown tool %q", name)
+}
+
+// tools describes the server's tools for tools/list.
+var tools = []map[string]interface{}{
+	{
+		"name":        "review-diff",
+		"description": "Review a unified git diff and return the findings as Markdown.",
+		"inputSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"diff":  map[string]string{"type": "string", "description": "Unified diff, e.g. the output of git diff"},
+				"focus": map[string]string{"type": "string", "description": "Optional concern to focus on, e.g. security"},
+			},
+			"required": []string{"diff"},
+		},
+	},
+	{
+		"name":        "review-files",
+		"description": "Review files in full, as if they were newly added, and return the findings as Markdown.",
+		"inputSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"paths": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Paths relative to the working directory"},
+				"focus": map[string]string{"type": "string", "description": "Optional concern to focus on, e.g. security"},
+			},
+			"required": []string{"paths"},
+		},
+	},
+	{
+		"name":        "explain-change",
+		"description": "Explain what a change in a unified diff does and why it might have been made.",
+		"inputSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"diff":   map[string]string{"type": "string", "description": "Unified diff containing the change"},
+				"target": map[string]string{"type": "string", "description": "File path, optionally with a line range, e.g. main.go:10-20"},
+			},
+			"required": []string{"diff", "target"},
+		},
+	},
+}
@@ -0,0 +1,98 @@
+// Package metrics emits run metrics to StatsD or the Datadog agent.
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPrefix is prepended to every metric name unless configured
+// otherwise.
+const DefaultPrefix = "repo_ranger"
+
+// Sink receives metrics. Tags are "key:value" pairs.
+type Sink interface {
+	Count(name string, value float64, tags ...string)
+	Gauge(name string, value float64, tags ...string)
+	Timing(name string, d time.Duration, tags ...string)
+	Close() error
+}
+
+// StatsD sends metrics over UDP in the DogStatsD line format, which plain
+// StatsD servers accept once tags are ignored.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+	tags   []string
+}
+
+// Option is a function that configures a StatsD sink.
+type Option func(*StatsD)
+
+// WithPrefix sets the prefix of every metric name.
+func WithPrefix(prefix string) Option {
+	return func(s *StatsD) {
+		s.prefix = strings.TrimSuffix(prefix, ".")
+	}
+}
+
+// WithTags adds tags sent with every metric.
+func WithTags(tags ...string) Option {
+	return func(s *StatsD) {
+		s.tags = append(s.tags, tags...)
+	}
+}
+
+// NewStatsD creates a sink sending to addr ("host:port"). UDP is
+// connectionless, so an unreachable agent does not fail the run.
+func NewStatsD(addr string, opts ...Option) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open StatsD connection: %w", err)
+	}
+	s := &StatsD{conn: conn, prefix: DefaultPrefix}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Count adds value to a counter.
+func (s *StatsD) Count(name string, value float64, tags ...string) {
+	s.send(name, formatValue(value), "c", tags)
+}
+
+// Gauge sets a gauge.
+func (s *StatsD) Gauge(name string, value float64, tags ...string) {
+	s.send(name, formatValue(value), "g", tags)
+}
+
+// Timing records a duration in milliseconds.
+func (s *StatsD) Timing(name string, d time.Duration, tags ...string) {
+	s.send(name, strconv.FormatInt(d.Milliseconds(), 10), "ms", tags)
+}
+
+// Close closes the connection.
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsD) send(name, value, kind string, tags []string) {
+	var b strings.Builder
+	if s.prefix != "" {
+		b.WriteString(s.prefix + ".")
+	}
+	b.WriteString(name + ":" + value + "|" + kind)
+	if all := append(append([]string{}, s.tags...), tags...); len(all) > 0 {
+		b.WriteString("|#" + strings.Join(all, ","))
+	}
+	// Metrics are best effort; a lost packet must never fail a review.
+	_, _ = s.conn.Write([]byte(b.String()))
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
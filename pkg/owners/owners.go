@@ -0,0 +1,104 @@
+// Package owners reads a repository's CODEOWNERS file to find who owns each
+// path, so findings can be routed to the teams responsible for the code.
+package owners
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/config"
+)
+
+// Paths are where GitHub looks for a CODEOWNERS file, in the order it
+// looks.
+var Paths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// rule assigns owners to the paths matching its patterns. A rule without
+// owners leaves its paths unowned.
+type rule struct {
+	patterns []string
+	owners   []string
+}
+
+// Owners maps paths to their owners.
+type Owners struct {
+	rules []rule
+}
+
+// Load reads the CODEOWNERS file of the repository checked out at root.
+// It returns nil, and no error, when the repository has none.
+func Load(root string) (*Owners, error) {
+	for _, p := range Paths {
+		data, err := os.ReadFile(filepath.Join(root, p))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		return Parse(string(data)), nil
+	}
+	return nil, nil
+}
+
+// Parse reads the contents of a CODEOWNERS file. Lines it cannot
+// understand are skipped, as GitHub skips them.
+func Parse(data string) *Owners {
+	o := &Owners{}
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 && (i == 0 || line[i-1] != '\\') {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "!") || strings.ContainsAny(fields[0], "[]") {
+			continue
+		}
+		pattern := strings.ReplaceAll(fields[0], `\#`, "#")
+		o.rules = append(o.rules, rule{patterns: globs(pattern), owners: fields[1:]})
+	}
+	return o
+}
+
+// Of returns the owners of path, such as "@acme/payments", from the last
+// rule matching it, or nil when nobody owns it.
+func (o *Owners) Of(path string) []string {
+	if o == nil {
+		return nil
+	}
+	for i := len(o.rules) - 1; i >= 0; i-- {
+		for _, pattern := range o.rules[i].patterns {
+			if config.MatchPath(pattern, path) {
+				return o.rules[i].owners
+			}
+		}
+	}
+	return nil
+}
+
+// globs translates a CODEOWNERS pattern, which follows gitignore rules,
+// into the path globs it stands for. A pattern starting with "/" or
+// containing one in the middle is anchored at the repository root, others
+// match at any depth, and a pattern naming a directory owns everything
+// below it, while one ending in "/*" owns only the directory's own files.
+func globs(pattern string) []string {
+	dir := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if strings.HasPrefix(pattern, "/") || strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else if pattern != "*" {
+		pattern = "**/" + pattern
+	}
+	switch {
+	case pattern == "*":
+		return []string{"**"}
+	case dir:
+		return []string{pattern + "/**"}
+	case strings.HasSuffix(pattern, "/*"):
+		return []string{pattern}
+	}
+	return []string{pattern, pattern + "/**"}
+}
@@ -0,0 +1,201 @@
+// Package pii finds personal data, such as email addresses, phone numbers,
+// and national ID numbers, committed in test fixtures and data files, so it
+// can be reported and masked before the diff is sent to a model.
+package pii
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+const source = "pii"
+
+// Kinds of personal data.
+const (
+	KindEmail      = "email address"
+	KindPhone      = "phone number"
+	KindNationalID = "national ID number"
+)
+
+// placeholders replace each kind of personal data in masked text.
+var placeholders = map[string]string{
+	KindEmail:      "[EMAIL]",
+	KindPhone:      "[PHONE]",
+	KindNationalID: "[NATIONAL-ID]",
+}
+
+// detector finds one kind of personal data; valid, when set, rejects
+// matches that cannot be real.
+type detector struct {
+	kind    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+var detectors = []detector{
+	{KindEmail, regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`), realEmail},
+	// US social security numbers and UK national insurance numbers.
+	{KindNationalID, regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), validSSN},
+	{KindNationalID, regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`), nil},
+	// International numbers, and numbers grouped like 555-123-4567 or
+	// (555) 123 4567; bare digit runs are too often IDs to count.
+	{KindPhone, regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]\d{3}[ .-]\d{4}\b|\+\d{10,14}\b`), nil},
+}
+
+// reservedDomains are set aside for documentation and tests, so addresses
+// at them belong to nobody.
+var reservedDomains = []string{"example.com", "example.org", "example.net", "example", "test", "invalid", "localhost", "local"}
+
+func realEmail(match string) bool {
+	domain := strings.ToLower(match[strings.LastIndex(match, "@")+1:])
+	for _, reserved := range reservedDomains {
+		if domain == reserved || strings.HasSuffix(domain, "."+reserved) {
+			return false
+		}
+	}
+	return true
+}
+
+// validSSN rejects numbers the Social Security Administration never
+// issues: area 000, 666, or 900 and up, group 00, or serial 0000.
+func validSSN(match string) bool {
+	area, group, serial := match[:3], match[4:6], match[7:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// dataExtensions are files that only ever hold data.
+var dataExtensions = map[string]bool{
+	".csv": true, ".tsv": true, ".jsonl": true, ".ndjson": true, ".dat": true, ".eml": true, ".vcf": true,
+}
+
+// dataSegments are directories holding fixtures and sample data.
+var dataSegments = []string{"testdata", "fixtures", "fixture", "__fixtures__", "seeds", "seed", "samples", "sample-data", "data"}
+
+// dataNames mark fixture and seed files kept among code.
+var dataNames = []string{"fixture", "seed", "sample"}
+
+// IsDataFile reports whether p is a test fixture or data file, where
+// personal data is likely real records copied in rather than code.
+func IsDataFile(p string) bool {
+	p = strings.ToLower(p)
+	if dataExtensions[path.Ext(p)] {
+		return true
+	}
+	for _, name := range dataNames {
+		if strings.Contains(path.Base(p), name) {
+			return true
+		}
+	}
+	for _, segment := range strings.Split(path.Dir(p), "/") {
+		for _, s := range dataSegments {
+			if segment == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Match is a piece of personal data found in a diff.
+type Match struct {
+	Kind  string
+	Value string
+}
+
+// Scan finds personal data in the test fixtures and data files of a diff.
+// It returns one finding per kind of data on each added line, and every
+// value found on any line, for Mask.
+func Scan(files []diff.FileDiff) ([]types.Finding, []Match) {
+	var findings []types.Finding
+	seen := map[string]bool{}
+	var matches []Match
+	for _, f := range files {
+		if f.IsBinary || !IsDataFile(f.Path()) {
+			continue
+		}
+		for _, h := range f.Hunks {
+			for _, l := range h.Lines {
+				kinds := map[string]bool{}
+				for _, m := range Find(l.Content) {
+					kinds[m.Kind] = true
+					if !seen[m.Value] {
+						seen[m.Value] = true
+						matches = append(matches, m)
+					}
+				}
+				if l.Kind != diff.LineAdded {
+					continue
+				}
+				for _, kind := range sortedKinds(kinds) {
+					findings = append(findings, finding(f.Path(), l.NewLine, kind))
+				}
+			}
+		}
+	}
+	return findings, matches
+}
+
+// Find returns the personal data in text, in the order of the detectors,
+// repeating values found more than once.
+func Find(text string) []Match {
+	var matches []Match
+	for _, d := range detectors {
+		for _, value := range d.pattern.FindAllString(text, -1) {
+			if d.valid == nil || d.valid(value) {
+				matches = append(matches, Match{Kind: d.kind, Value: value})
+			}
+		}
+	}
+	return matches
+}
+
+func sortedKinds(kinds map[string]bool) []string {
+	out := make([]string, 0, len(kinds))
+	for k := range kinds {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func finding(file string, line int, kind string) types.Finding {
+	severity := types.SeverityMinor
+	if kind == KindNationalID {
+		severity = types.SeverityMajor
+	}
+	return types.Finding{
+		File:     file,
+		Line:     line,
+		Severity: severity,
+		Source:   source,
+		Category: types.CategoryPrivacy,
+		Message:  fmt.Sprintf("Possible PII committed: this line contains what looks like a real %s. Replace it with synthetic data.", kind),
+	}
+}
+
+// MaskAll replaces every value Find finds in text with a placeholder for
+// its kind, for text, such as whole files, that Scan never saw.
+func MaskAll(text string) string {
+	return Mask(text, Find(text))
+}
+
+// Mask replaces every value found by Scan in text with a placeholder for
+// its kind, longest first so no value is left partly masked.
+func Mask(text string, matches []Match) string {
+	if len(matches) == 0 {
+		return text
+	}
+	sorted := append([]Match(nil), matches...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i].Value) > len(sorted[j].Value) })
+	pairs := make([]string, 0, 2*len(sorted))
+	for _, m := range sorted {
+		pairs = append(pairs, m.Value, placeholders[m.Kind])
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
@@ -0,0 +1,36 @@
+package pii
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Match
+	}{
+		{"nothing", "func main() {}", nil},
+		{"email", "contact: jane.doe@acme.io", []Match{{KindEmail, "jane.doe@acme.io"}}},
+		{"reserved domain", "user@example.com", nil},
+		{"ssn", "ssn=123-45-6789", []Match{{KindNationalID, "123-45-6789"}}},
+		{"invalid ssn", "ssn=000-45-6789", nil},
+		{"phone", "call (555) 123-4567", []Match{{KindPhone, "(555) 123-4567"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Find(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskAll(t *testing.T) {
+	got := MaskAll("name,email,ssn\nJane,jane@acme.io,123-45-6789\nTest,t@example.com,")
+	want := "name,email,ssn\nJane,[EMAIL],[NATIONAL-ID]\nTest,t@example.com,"
+	if got != want {
+		t.Errorf("MaskAll = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,159 @@
+// Package postprocess passes review findings through a user-supplied
+// executable before they are posted, so teams can filter or enrich them
+// without forking the action.
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// DefaultTimeout bounds a post-processor run when none is configured.
+const DefaultTimeout = 30 * time.Second
+
+// Document is the JSON exchanged with the post-processor: it is written to
+// the executable's stdin, and the executable writes the modified document to
+// stdout.
+type Document struct {
+	PullRequest  PullRequest     `json:"pull_request"`
+	Findings     []types.Finding `json:"findings"`
+	Comments     []Comment       `json:"comments"`
+	FileComments []FileComment   `json:"file_comments"`
+}
+
+// PullRequest identifies the reviewed pull request. It is informational;
+// changes to it are ignored.
+type PullRequest struct {
+	Repository string `json:"repository,omitempty"`
+	Number     int    `json:"number,omitempty"`
+	HeadSHA    string `json:"head_sha,omitempty"`
+}
+
+// Comment is an inline finding from the model.
+type Comment struct {
+	// ID is the finding's short identifier, such as "RR-001".
+	ID   string `json:"id,omitempty"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+	// StartLine is the first line of a comment on a range of lines.
+	StartLine  int            `json:"start_line,omitempty"`
+	Side       string         `json:"side,omitempty"`
+	Severity   types.Severity `json:"severity,omitempty"`
+	Suggestion string         `json:"suggestion"`
+	Reasoning  string         `json:"reasoning"`
+	// Confidence is the model's 0–1 confidence in the finding, when given.
+	Confidence *types.Confidence `json:"confidence,omitempty"`
+	Category   types.Category    `json:"category,omitempty"`
+	// Chunk is the correlation ID of the chunk the finding came from.
+	Chunk string `json:"chunk,omitempty"`
+}
+
+// FileComment is a remark from the model about a whole file.
+type FileComment struct {
+	File       string            `json:"file"`
+	Severity   types.Severity    `json:"severity,omitempty"`
+	Summary    string            `json:"summary"`
+	Confidence *types.Confidence `json:"confidence,omitempty"`
+	Category   types.Category    `json:"category,omitempty"`
+}
+
+// NewDocument builds the document for a set of findings.
+func NewDocument(event types.PullRequestEvent, findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) Document {
+	doc := Document{
+		PullRequest: PullRequest{
+			Repository: event.Repository.FullName,
+			Number:     event.PullRequest.Number,
+			HeadSHA:    event.PullRequest.Head.SHA,
+		},
+		Findings:     append([]types.Finding{}, findings...),
+		Comments:     []Comment{},
+		FileComments: []FileComment{},
+	}
+	for _, c := range comments {
+		doc.Comments = append(doc.Comments, Comment{
+			ID:         c.ID,
+			File:       c.File,
+			Line:       c.Line,
+			StartLine:  c.StartLine,
+			Side:       c.Side,
+			Severity:   c.Severity,
+			Suggestion: c.Suggestion,
+			Reasoning:  c.Reasoning,
+			Confidence: c.Confidence,
+			Category:   c.Category,
+			Chunk:      c.Chunk,
+		})
+	}
+	for _, c := range fileComments {
+		doc.FileComments = append(doc.FileComments, FileComment(c))
+	}
+	return doc
+}
+
+// Results converts the document back into review findings, inline
+// comments, and file comments.
+func (d Document) Results() ([]types.Finding, []types.InlineComment, []types.FileComment) {
+	var comments []types.InlineComment
+	for _, c := range d.Comments {
+		comments = append(comments, types.InlineComment{
+			ID:         c.ID,
+			File:       c.File,
+			Line:       c.Line,
+			StartLine:  c.StartLine,
+			Side:       c.Side,
+			Severity:   c.Severity,
+			Suggestion: c.Suggestion,
+			Reasoning:  c.Reasoning,
+			Confidence: c.Confidence,
+			Category:   c.Category,
+			Chunk:      c.Chunk,
+		})
+	}
+	var fileComments []types.FileComment
+	for _, c := range d.FileComments {
+		fileComments = append(fileComments, types.FileComment(c))
+	}
+	return d.Findings, comments, fileComments
+}
+
+// Run passes doc to the executable at command and returns the document it
+// writes back. A non-zero exit or malformed output is an error.
+func Run(ctx context.Context, command string, args []string, timeout time.Duration, doc Document) (Document, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	input, err := json.Marshal(doc)
+	if err != nil {
+		return doc, fmt.Errorf("failed to marshal findings: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return doc, fmt.Errorf("post-processor failed with stderr: %s: %w", bytes.TrimSpace(stderr.Bytes()), err)
+		}
+		return doc, fmt.Errorf("failed to run post-processor: %w", err)
+	}
+
+	var out Document
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return doc, fmt.Errorf("failed to parse post-processor output: %w", err)
+	}
+	out.PullRequest = doc.PullRequest
+	return out, nil
+}
@@ -0,0 +1,356 @@
+// Package queue is a durable, directory-backed work queue for server mode,
+// so accepted webhook deliveries survive restarts and failed reviews are
+// retried before landing in a dead-letter list.
+//
+// Jobs are plain files rather than rows in an embedded database such as
+// Badger or SQLite: a single server process owns the directory, every write
+// is a synced temporary file renamed into place, and the queue stays free of
+// cgo and third-party storage engines. Operators can inspect, back up, or
+// repair it with ordinary file tools.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/retry"
+)
+
+// DefaultRetryPolicy is used unless the queue is configured otherwise.
+var DefaultRetryPolicy = retry.Policy{
+	Attempts:  3,
+	BaseDelay: 30 * time.Second,
+	MaxDelay:  10 * time.Minute,
+}
+
+// ErrNotFound is returned for a job ID the queue does not hold.
+var ErrNotFound = errors.New("job not found")
+
+// Job is a unit of queued work: one webhook delivery to review.
+type Job struct {
+	ID         string          `json:"id"`
+	Event      string          `json:"event"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"last_error,omitempty"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+	// NotBefore delays a retry until the backoff has passed.
+	NotBefore time.Time `json:"not_before"`
+}
+
+// Queue stores pending jobs in one directory and dead-lettered jobs in
+// another, one JSON file per job. Jobs stay in the pending directory while
+// they run, so a crash re-runs them on the next start: delivery is at
+// least once.
+type Queue struct {
+	pending string
+	dead    string
+	retry   retry.Policy
+
+	mu      sync.Mutex
+	claimed map[string]bool
+	wake    chan struct{}
+}
+
+// Option is a function that configures a queue.
+type Option func(*Queue)
+
+// WithRetryPolicy sets how often and how quickly failed jobs are retried.
+func WithRetryPolicy(p retry.Policy) Option {
+	return func(q *Queue) {
+		q.retry = p
+	}
+}
+
+// Open opens or creates the queue stored under dir.
+func Open(dir string, opts ...Option) (*Queue, error) {
+	q := &Queue{
+		pending: filepath.Join(dir, "pending"),
+		dead:    filepath.Join(dir, "dead"),
+		retry:   DefaultRetryPolicy,
+		claimed: map[string]bool{},
+		wake:    make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	for _, d := range []string{q.pending, q.dead} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create queue directory: %w", err)
+		}
+	}
+	return q, nil
+}
+
+// validID matches IDs safe to use as file names, such as GitHub delivery
+// GUIDs.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// Enqueue adds a job. A job whose ID is already queued or dead-lettered is
+// ignored, so redelivered webhooks are not reviewed twice.
+func (q *Queue) Enqueue(job Job) error {
+	if !validID.MatchString(job.ID) {
+		return fmt.Errorf("invalid job ID %q", job.ID)
+	}
+	for _, d := range []string{q.pending, q.dead} {
+		if _, err := os.Stat(filepath.Join(d, job.ID+".json")); err == nil {
+			return nil
+		}
+	}
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now().UTC()
+	}
+	if err := write(q.pending, job); err != nil {
+		return err
+	}
+	q.signal()
+	return nil
+}
+
+// Claim blocks until a job is ready to run or ctx is done. The caller must
+// pass the job to Complete or Fail.
+func (q *Queue) Claim(ctx context.Context) (Job, error) {
+	for {
+		job, wait, err := q.next()
+		if err != nil {
+			return Job{}, err
+		}
+		if job != nil {
+			return *job, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Job{}, ctx.Err()
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// next claims the oldest job that is ready, or returns how long to wait
+// for the next one.
+func (q *Queue) next() (*Job, time.Duration, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs, err := q.list(q.pending)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now()
+	wait := time.Minute
+	for _, job := range jobs {
+		if q.claimed[job.ID] {
+			continue
+		}
+		if job.NotBefore.After(now) {
+			if d := job.NotBefore.Sub(now); d < wait {
+				wait = d
+			}
+			continue
+		}
+		q.claimed[job.ID] = true
+		job := job
+		return &job, 0, nil
+	}
+	return nil, wait, nil
+}
+
+// Complete removes a finished job.
+func (q *Queue) Complete(job Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.claimed, job.ID)
+	if err := os.Remove(filepath.Join(q.pending, job.ID+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove job: %w", err)
+	}
+	return nil
+}
+
+// Fail records a failed attempt. The job is retried after a backoff until
+// the retry policy's attempts are used up, then moved to the dead letters.
+// It reports whether the job was dead-lettered.
+func (q *Queue) Fail(job Job, cause error) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.claimed, job.ID)
+
+	job.Attempts++
+	job.LastError = cause.Error()
+	if job.Attempts < q.retry.Attempts {
+		job.NotBefore = time.Now().Add(q.retry.Delay(job.Attempts)).UTC()
+		if err := write(q.pending, job); err != nil {
+			return false, err
+		}
+		q.signal()
+		return false, nil
+	}
+
+	job.NotBefore = time.Time{}
+	if err := write(q.dead, job); err != nil {
+		return false, err
+	}
+	if err := os.Remove(filepath.Join(q.pending, job.ID+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return true, fmt.Errorf("failed to remove job: %w", err)
+	}
+	return true, nil
+}
+
+// Pending returns the queued jobs, oldest first.
+func (q *Queue) Pending() ([]Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.list(q.pending)
+}
+
+// DeadLetters returns the jobs that failed every attempt, oldest first.
+func (q *Queue) DeadLetters() ([]Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.list(q.dead)
+}
+
+// Requeue moves a dead-lettered job back to the queue with a fresh set of
+// attempts.
+func (q *Queue) Requeue(id string) error {
+	if !validID.MatchString(id) {
+		return ErrNotFound
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	path := filepath.Join(q.dead, id+".json")
+	job, err := read(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	job.Attempts = 0
+	job.NotBefore = time.Time{}
+	if err := write(q.pending, job); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove dead letter: %w", err)
+	}
+	q.signal()
+	return nil
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// write stores job in dir atomically, so a crash never leaves a partial
+// file behind.
+func write(dir string, job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, job.ID+".json")); err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	return nil
+}
+
+// quarantine moves the unreadable job file at path aside, keeping its
+// bytes as <id>.json.corrupt in the dead-letter directory, and records a
+// dead letter in its place so the admin listing shows what happened.
+func (q *Queue) quarantine(path string, cause error) (Job, error) {
+	id := strings.TrimSuffix(filepath.Base(path), ".json")
+	job := Job{ID: id, LastError: cause.Error(), EnqueuedAt: time.Now().UTC()}
+	if info, err := os.Stat(path); err == nil {
+		job.EnqueuedAt = info.ModTime().UTC()
+	}
+	delete(q.claimed, id)
+	if err := os.Rename(path, filepath.Join(q.dead, id+".json.corrupt")); err != nil {
+		return job, fmt.Errorf("failed to quarantine job %s: %w", id, err)
+	}
+	if err := write(q.dead, job); err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+func read(path string) (Job, error) {
+	var job Job
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return job, err
+	}
+	if err := json.Unmarshal(data, &job); err != nil {
+		return job, fmt.Errorf("failed to parse job %s: %w", filepath.Base(path), err)
+	}
+	return job, nil
+}
+
+// list reads every job in dir, oldest first. A file that cannot be read is
+// quarantined rather than failing the listing, so one corrupt job never
+// stalls the rest. The caller must hold q.mu.
+func (q *Queue) list(dir string) ([]Job, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	var jobs []Job
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		job, err := read(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			job, err = q.quarantine(path, err)
+			if err != nil {
+				return nil, err
+			}
+			if dir == q.dead {
+				jobs = append(jobs, job)
+			}
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].EnqueuedAt.Before(jobs[j].EnqueuedAt)
+	})
+	return jobs, nil
+}
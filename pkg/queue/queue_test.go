@@ -0,0 +1,166 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/retry"
+)
+
+func openTest(t *testing.T, attempts int) (*Queue, string) {
+	t.Helper()
+	dir := t.TempDir()
+	q, err := Open(dir, WithRetryPolicy(retry.Policy{Attempts: attempts, BaseDelay: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q, dir
+}
+
+func claim(t *testing.T, q *Queue) Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := q.Claim(ctx)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	return job
+}
+
+// ids returns the IDs of the jobs listed by list.
+func ids(t *testing.T, list func() ([]Job, error)) []string {
+	t.Helper()
+	jobs, err := list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, j := range jobs {
+		out = append(out, j.ID)
+	}
+	return out
+}
+
+func TestEnqueueClaimComplete(t *testing.T) {
+	q, _ := openTest(t, 3)
+	for _, id := range []string{"a", "b", "a"} {
+		if err := q.Enqueue(Job{ID: id, Payload: json.RawMessage(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Enqueue(Job{ID: "../x"}); err == nil {
+		t.Error("Enqueue accepted an ID that is not a file name")
+	}
+	if got := ids(t, q.Pending); len(got) != 2 {
+		t.Fatalf("pending = %v, want a and b once each", got)
+	}
+
+	job := claim(t, q)
+	if err := q.Complete(job); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, q.Pending); len(got) != 1 || got[0] == job.ID {
+		t.Errorf("pending after completing %s = %v", job.ID, got)
+	}
+}
+
+func TestFailRetriesThenDeadLetters(t *testing.T) {
+	q, _ := openTest(t, 2)
+	if err := q.Enqueue(Job{ID: "a", Payload: json.RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	job := claim(t, q)
+	dead, err := q.Fail(job, errors.New("first"))
+	if err != nil || dead {
+		t.Fatalf("first Fail = %v, %v, want a retry", dead, err)
+	}
+	pending, err := q.Pending()
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("pending = %+v, %v, want the retried job", pending, err)
+	}
+	if p := pending[0]; p.Attempts != 1 || p.LastError != "first" || p.NotBefore.IsZero() {
+		t.Errorf("retried job = %+v, want one attempt, its error, and a backoff", p)
+	}
+
+	job = claim(t, q)
+	if job.Attempts != 1 {
+		t.Errorf("claimed retry has %d attempts, want 1", job.Attempts)
+	}
+	dead, err = q.Fail(job, errors.New("second"))
+	if err != nil || !dead {
+		t.Fatalf("second Fail = %v, %v, want dead-lettered", dead, err)
+	}
+	if got := ids(t, q.Pending); len(got) != 0 {
+		t.Errorf("pending = %v, want none", got)
+	}
+	letters, err := q.DeadLetters()
+	if err != nil || len(letters) != 1 || letters[0].Attempts != 2 || letters[0].LastError != "second" {
+		t.Errorf("dead letters = %+v, %v, want job a after two attempts", letters, err)
+	}
+
+	// A redelivery of a dead-lettered job is not queued again.
+	if err := q.Enqueue(Job{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, q.Pending); len(got) != 0 {
+		t.Errorf("pending after redelivery = %v, want none", got)
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	q, _ := openTest(t, 1)
+	if err := q.Enqueue(Job{ID: "a", Payload: json.RawMessage(`{"n":1}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if dead, err := q.Fail(claim(t, q), errors.New("boom")); err != nil || !dead {
+		t.Fatalf("Fail = %v, %v, want dead-lettered", dead, err)
+	}
+
+	if err := q.Requeue("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Requeue(missing) = %v, want ErrNotFound", err)
+	}
+	if err := q.Requeue("../a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Requeue(../a) = %v, want ErrNotFound", err)
+	}
+	if err := q.Requeue("a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, q.DeadLetters); len(got) != 0 {
+		t.Errorf("dead letters = %v, want none", got)
+	}
+	job := claim(t, q)
+	if job.ID != "a" || job.Attempts != 0 || string(job.Payload) != `{"n":1}` {
+		t.Errorf("requeued job = %+v, want a with fresh attempts and its payload", job)
+	}
+}
+
+func TestCorruptJobQuarantined(t *testing.T) {
+	q, dir := openTest(t, 3)
+	if err := q.Enqueue(Job{ID: "good", Payload: json.RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pending", "bad.json"), []byte(`{"id":"bad",`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if job := claim(t, q); job.ID != "good" {
+		t.Errorf("claimed %q, want good", job.ID)
+	}
+	if got := ids(t, q.Pending); len(got) != 1 || got[0] != "good" {
+		t.Errorf("pending = %v, want only good", got)
+	}
+	letters, err := q.DeadLetters()
+	if err != nil || len(letters) != 1 || letters[0].ID != "bad" || letters[0].LastError == "" {
+		t.Errorf("dead letters = %+v, %v, want bad with its error", letters, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dead", "bad.json.corrupt")); err != nil {
+		t.Errorf("corrupt bytes not kept: %v", err)
+	}
+}
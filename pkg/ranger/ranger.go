@@ -0,0 +1,299 @@
+// Package ranger is Repo Ranger's review pipeline as a library. It reviews
+// a unified diff with a chat-completions model, running the same
+// deterministic checks, chunking, prompts, and parsing as the action, and
+// returns typed findings. It knows nothing of GitHub or any other host, so
+// other tools can embed reviews without running the action binary.
+//
+//	result, err := ranger.Review(ctx, ranger.Options{
+//		Diff:   diffText,
+//		APIURL: "https://api.openai.com/v1",
+//		APIKey: os.Getenv("OPENAI_API_KEY"),
+//		Model:  "gpt-4o",
+//	})
+//
+// The pipeline logs through logrus's standard logger.
+package ranger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/analyzer"
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/runner"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// Severity ranks how serious a finding is.
+type Severity = types.Severity
+
+// Severities, from most to least serious.
+const (
+	SeverityCritical = types.SeverityCritical
+	SeverityMajor    = types.SeverityMajor
+	SeverityMinor    = types.SeverityMinor
+	SeverityNit      = types.SeverityNit
+)
+
+// Category is the kind of problem a finding is about.
+type Category = types.Category
+
+// Categories of finding.
+const (
+	CategoryBug             = types.CategoryBug
+	CategorySecurity        = types.CategorySecurity
+	CategoryPerformance     = types.CategoryPerformance
+	CategoryStyle           = types.CategoryStyle
+	CategoryTests           = types.CategoryTests
+	CategoryDocs            = types.CategoryDocs
+	CategoryMaintainability = types.CategoryMaintainability
+)
+
+// Depths of review.
+const (
+	DepthSummary  = runner.DepthSummary
+	DepthStandard = runner.DepthStandard
+	DepthDeep     = runner.DepthDeep
+)
+
+// Client sends a prompt to a model and returns its reply.
+type Client = api.Client
+
+// ErrIncomplete is returned, with the partial result, when some chunks of
+// the diff could not be reviewed.
+var ErrIncomplete = runner.ErrIncomplete
+
+// Options configure a review. Diff and Model are required, as are APIURL
+// and APIKey unless Client is set.
+type Options struct {
+	// Diff is the unified diff to review.
+	Diff string
+
+	APIURL string
+	APIKey string
+	Model  string
+	// APIPath replaces the /chat/completions path, and Headers are added
+	// to every request, for gateways.
+	APIPath string
+	Headers map[string]string
+	// Client, when set, sends the prompts instead of a client built from
+	// APIURL and APIKey.
+	Client Client
+	// HTTPClient sends the model requests of the built client; the
+	// default is http.DefaultClient.
+	HTTPClient api.HTTPClient
+	// Temperature defaults to 0.7 when nil.
+	Temperature *float64
+	// MaxTokens caps each completion; the default is 2000.
+	MaxTokens int
+	// Timeout bounds the model calls of the review; the default is 30
+	// seconds.
+	Timeout time.Duration
+
+	// Depth is DepthSummary, DepthStandard (the default), or DepthDeep.
+	Depth string
+	// Focus narrows the review, e.g. "security".
+	Focus string
+	// Tone describes how the review should be written.
+	Tone string
+	// MinSeverity and MinConfidence drop the model's findings below them.
+	MinSeverity   Severity
+	MinConfidence float64
+	// Categories set categories "on", "off", or "warn-only".
+	Categories map[Category]string
+	// Analyzers run as deterministic checks in addition to the registered
+	// ones.
+	Analyzers []analyzer.Analyzer
+
+	// RepoPath is the top directory of the git repository the diff applies
+	// to. Changed files are read from its working tree, for file context,
+	// analyzers, and checks, and their history from BaseRef; the default
+	// is the working directory and HEAD.
+	RepoPath string
+	BaseRef  string
+	// CacheDir holds checkpoints of chunk reviews, so a retried review
+	// only re-sends failed chunks; the default is in the user cache
+	// directory.
+	CacheDir string
+}
+
+// Finding is a problem found in the diff.
+type Finding struct {
+	File string
+	// Line is 0 for findings about the whole file.
+	Line int
+	// Side is "RIGHT" for lines of the new file and "LEFT" for removed
+	// lines, whose Line is an old-file line number.
+	Side     string
+	Severity Severity
+	Category Category
+	// Confidence is how sure the model is, from 0 to 1, or 0 when it did
+	// not say.
+	Confidence float64
+	// Source is "review" for the model's findings and names the check for
+	// deterministic ones.
+	Source     string
+	Message    string
+	Suggestion string
+}
+
+// Usage is the tokens a review used.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Result is the outcome of a review.
+type Result struct {
+	// Report is the review as Markdown, as the action would post it.
+	Report string
+	// Findings are ordered from most to least severe, then by location.
+	Findings []Finding
+	// Skipped, when set, says why nothing was reviewed, such as a diff
+	// that only reformats code.
+	Skipped        string
+	Chunks         int
+	FailedChunks   int
+	DeclinedChunks int
+	Usage          Usage
+	// Cost is the estimated spend in US dollars.
+	Cost float64
+}
+
+// Review reviews opts.Diff.
+func Review(ctx context.Context, opts Options) (Result, error) {
+	if opts.Model == "" {
+		return Result{}, errors.New("no model set")
+	}
+	if opts.Client == nil && (opts.APIURL == "" || opts.APIKey == "") {
+		return Result{}, errors.New("no API URL and key or client set")
+	}
+	switch opts.Depth {
+	case "":
+		opts.Depth = DepthStandard
+	case DepthSummary, DepthStandard, DepthDeep:
+	default:
+		return Result{}, fmt.Errorf("unknown depth %q", opts.Depth)
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = 2000
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.RepoPath == "" {
+		opts.RepoPath = "."
+	}
+	if opts.BaseRef == "" {
+		opts.BaseRef = "HEAD"
+	}
+	if opts.CacheDir == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			dir = os.TempDir()
+		}
+		opts.CacheDir = filepath.Join(dir, "repo-ranger")
+	}
+	if err := os.MkdirAll(opts.CacheDir, 0755); err != nil {
+		return Result{}, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	usage := api.NewUsageMeter()
+	client := opts.Client
+	if client == nil {
+		temperature := 0.7
+		if opts.Temperature != nil {
+			temperature = *opts.Temperature
+		}
+		clientOpts := []api.ClientOption{
+			api.WithUsageMeter(usage),
+			api.WithTemperature(temperature),
+			api.WithMaxTokens(opts.MaxTokens),
+			api.WithPath(opts.APIPath),
+			api.WithHeaders(opts.Headers),
+		}
+		if opts.HTTPClient != nil {
+			clientOpts = append(clientOpts, api.WithHTTPClient(opts.HTTPClient))
+		}
+		client = api.NewClient(opts.APIURL, opts.APIKey, clientOpts...)
+	}
+
+	o := runner.New(runner.Config{
+		Model:               opts.Model,
+		MaxTokens:           opts.MaxTokens,
+		APITimeout:          opts.Timeout,
+		DiffTimeout:         opts.Timeout,
+		BaseRef:             opts.BaseRef,
+		Checkout:            opts.RepoPath,
+		ReviewDepth:         opts.Depth,
+		CategoryModes:       opts.Categories,
+		ChurnDays:           90,
+		Focus:               opts.Focus,
+		Tone:                opts.Tone,
+		MinSeverity:         opts.MinSeverity,
+		MinConfidence:       opts.MinConfidence,
+		CacheDir:            opts.CacheDir,
+		FormatDetectors:     diff.DefaultDetectors,
+		MinRenameSimilarity: 50,
+		Analyzers:           append(analyzer.Registered(), opts.Analyzers...),
+	}, diff.NewGitRunner(opts.RepoPath), client, nil, runner.WithUsageMeter(usage))
+
+	patch, err := o.ReviewPatch(ctx, opts.Diff, "")
+	if err != nil {
+		return Result{}, err
+	}
+	total := usage.Total()
+	result := Result{
+		Report:         patch.Report,
+		Findings:       findings(patch),
+		Skipped:        patch.Skipped,
+		Chunks:         patch.Chunks,
+		FailedChunks:   patch.FailedChunks,
+		DeclinedChunks: patch.DeclinedChunks,
+		Usage:          Usage{PromptTokens: total.PromptTokens, CompletionTokens: total.CompletionTokens},
+		Cost:           o.Cost(),
+	}
+	if patch.FailedChunks > 0 {
+		return result, fmt.Errorf("%w: %d of %d chunks failed", ErrIncomplete, patch.FailedChunks, patch.Chunks)
+	}
+	return result, nil
+}
+
+// findings flattens the results of the checks and the model.
+func findings(patch runner.PatchResult) []Finding {
+	var out []Finding
+	for _, f := range patch.Findings {
+		out = append(out, Finding{File: f.File, Line: f.Line, Side: types.SideRight, Severity: f.Severity, Source: f.Source, Message: f.Message})
+	}
+	for _, c := range patch.Comments {
+		f := Finding{File: c.File, Line: c.Line, Side: c.Side, Severity: c.Severity, Category: c.Category,
+			Source: "review", Message: c.Reasoning, Suggestion: c.Suggestion}
+		if c.Confidence != nil {
+			f.Confidence = float64(*c.Confidence)
+		}
+		out = append(out, f)
+	}
+	for _, c := range patch.FileComments {
+		f := Finding{File: c.File, Severity: c.Severity, Category: c.Category, Source: "review", Message: c.Summary}
+		if c.Confidence != nil {
+			f.Confidence = float64(*c.Confidence)
+		}
+		out = append(out, f)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Severity.Rank() != out[j].Severity.Rank() {
+			return out[i].Severity.Rank() > out[j].Severity.Rank()
+		}
+		if out[i].File != out[j].File {
+			return out[i].File < out[j].File
+		}
+		return out[i].Line < out[j].Line
+	})
+	return out
+}
@@ -0,0 +1,145 @@
+// Package retry implements the retry and backoff policy shared by the API
+// clients.
+package retry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Policy controls how often and how quickly a failed call is retried.
+type Policy struct {
+	// Attempts is the total number of calls, including the first.
+	Attempts int
+	// BaseDelay is the delay before the first retry; it doubles with each
+	// further retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries. Zero means no cap.
+	MaxDelay time.Duration
+	// RetryableStatus lists the HTTP status codes worth retrying.
+	RetryableStatus []int
+}
+
+// DefaultRetryableStatus are the HTTP status codes retried when a policy
+// does not list its own: rate limiting and transient server errors.
+var DefaultRetryableStatus = []int{429, 500, 502, 503, 504}
+
+// Retryable reports whether a response with the given status code should be
+// retried.
+func (p Policy) Retryable(status int) bool {
+	codes := p.RetryableStatus
+	if len(codes) == 0 {
+		codes = DefaultRetryableStatus
+	}
+	for _, code := range codes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns the backoff before the given retry, counting from 1.
+func (p Policy) Delay(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// MaxWait bounds the wait an error may ask for before its retry. A call
+// asked to wait longer is not retried, so a long rate limit fails fast with
+// its typed error instead of stalling the run.
+const MaxWait = time.Minute
+
+// Waiter is implemented by errors that say when the call may be retried,
+// such as rate limit errors carrying Retry-After.
+type Waiter interface {
+	// RetryAfter returns how long to wait before retrying, or 0 when the
+	// error does not say.
+	RetryAfter() time.Duration
+}
+
+// wait returns how long err asks to wait before a retry, and whether that
+// is within MaxWait.
+func wait(err error) (time.Duration, bool) {
+	var w Waiter
+	if !errors.As(err, &w) {
+		return 0, true
+	}
+	d := w.RetryAfter()
+	return d, d <= MaxWait
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls fn until it succeeds, returns a permanent error, the policy's
+// attempts are exhausted, or ctx is done. It returns the last error. A
+// retry waits the policy's backoff, or as long as a Waiter error asks when
+// that is longer; an error asking for more than MaxWait is returned at
+// once.
+func Do(ctx context.Context, p Policy, fn func() error) error {
+	attempts := p.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			delay := p.Delay(i)
+			if asked, ok := wait(err); !ok {
+				log.WithField("wait", asked).Warn("Call asked to wait longer than allowed; not retrying")
+				return err
+			} else if asked > delay {
+				delay = asked
+			}
+			log.WithFields(log.Fields{
+				"attempt": i + 1,
+				"delay":   delay,
+			}).Debug("Retrying call")
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"attempt": i + 1,
+			"error":   err,
+		}).Warn("Call failed")
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitError asks to wait the given duration before a retry.
+type waitError time.Duration
+
+func (e waitError) Error() string             { return "rate limited" }
+func (e waitError) RetryAfter() time.Duration { return time.Duration(e) }
+
+func TestDo(t *testing.T) {
+	errFailed := errors.New("failed")
+	policy := Policy{Attempts: 3, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		name  string
+		ctx   func() context.Context
+		errs  []error // returned by successive calls; nil after the last
+		calls int
+		err   error
+	}{
+		{name: "first call succeeds", calls: 1},
+		{name: "retry succeeds", errs: []error{errFailed, errFailed}, calls: 3},
+		{name: "attempts exhausted", errs: []error{errFailed, errFailed, errFailed, errFailed}, calls: 3, err: errFailed},
+		{name: "permanent error", errs: []error{Permanent(errFailed)}, calls: 1, err: errFailed},
+		{name: "short wait retried", errs: []error{waitError(2 * time.Millisecond)}, calls: 2},
+		{name: "long wait not retried", errs: []error{waitError(MaxWait + time.Second)}, calls: 1, err: waitError(MaxWait + time.Second)},
+		{
+			name: "context done",
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			errs:  []error{errFailed},
+			calls: 1,
+			err:   context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx()
+			}
+			calls := 0
+			err := Do(ctx, policy, func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.calls {
+				t.Errorf("Do() made %d call(s), want %d", calls, tt.calls)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Do() = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		retry  int
+		want   time.Duration
+	}{
+		{"first retry", Policy{BaseDelay: time.Second}, 1, time.Second},
+		{"doubles", Policy{BaseDelay: time.Second}, 4, 8 * time.Second},
+		{"capped", Policy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}, 4, 5 * time.Second},
+		{"cap above the delay", Policy{BaseDelay: time.Second, MaxDelay: time.Minute}, 2, 2 * time.Second},
+		{"no overflow", Policy{BaseDelay: time.Second, MaxDelay: time.Minute}, 200, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.retry); got != tt.want {
+				t.Errorf("Delay(%d) = %v, want %v", tt.retry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		status int
+		want   bool
+	}{
+		{"default rate limit", Policy{}, 429, true},
+		{"default server error", Policy{}, 503, true},
+		{"default client error", Policy{}, 404, false},
+		{"own list", Policy{RetryableStatus: []int{409}}, 409, true},
+		{"own list replaces the default", Policy{RetryableStatus: []int{409}}, 503, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Retryable(tt.status); got != tt.want {
+				t.Errorf("Retryable(%d) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
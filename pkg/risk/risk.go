@@ -0,0 +1,132 @@
+// Package risk ranks changed files by how much they need a careful review,
+// so that when budgets or comment caps force triage the most important
+// files come first.
+package risk
+
+import (
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/config"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+)
+
+// Kinds of file, by how much a review of them tends to matter.
+var (
+	codeExtensions = map[string]bool{
+		".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".java": true,
+		".kt": true, ".rb": true, ".rs": true, ".c": true, ".h": true, ".cc": true, ".cpp": true,
+		".cs": true, ".php": true, ".swift": true, ".scala": true, ".sh": true, ".sql": true,
+	}
+	configExtensions = map[string]bool{
+		".yml": true, ".yaml": true, ".json": true, ".toml": true, ".tf": true, ".hcl": true,
+		".ini": true, ".conf": true, ".proto": true, ".graphql": true,
+	}
+	lockFiles = map[string]bool{
+		"go.sum": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+		"Cargo.lock": true, "Gemfile.lock": true, "poetry.lock": true, "composer.lock": true,
+	}
+	// sensitiveSegments mark code where mistakes are costly.
+	sensitiveSegments = []string{"auth", "security", "crypto", "secret", "permission", "payment", "billing", "migration", "admin"}
+)
+
+// Scorer scores files from their change, path, and history.
+type Scorer struct {
+	priorities []config.Priority
+	churn      map[string]int
+}
+
+// Option is a function that configures a scorer.
+type Option func(*Scorer)
+
+// WithPriorities scales the score of files matching each priority's paths
+// by its weight.
+func WithPriorities(priorities []config.Priority) Option {
+	return func(s *Scorer) {
+		s.priorities = priorities
+	}
+}
+
+// WithChurn raises the score of files changed often recently, keyed by
+// path to the number of recent commits touching them.
+func WithChurn(churn map[string]int) Option {
+	return func(s *Scorer) {
+		s.churn = churn
+	}
+}
+
+// NewScorer creates a scorer.
+func NewScorer(opts ...Option) *Scorer {
+	s := &Scorer{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Score rates how much a file needs a careful review: larger changes to
+// frequently changed code in sensitive places score highest, tests and
+// generated files lowest. A score of 0 means the file should never be
+// reviewed in detail when triaging.
+func (s *Scorer) Score(f diff.FileDiff) float64 {
+	p := f.Path()
+	base := path.Base(p)
+	ext := strings.ToLower(path.Ext(p))
+	lower := strings.ToLower(p)
+
+	// Larger changes are riskier, with diminishing returns.
+	score := math.Log2(float64(len(f.AddedLines())+len(f.RemovedLines())) + 2)
+	switch {
+	case f.IsBinary || lockFiles[base] || strings.Contains(lower, "vendor/") || strings.Contains(lower, ".pb.") || strings.Contains(lower, "generated"):
+		score *= 0.1
+	case codeExtensions[ext] || base == "Dockerfile":
+		score *= 3
+	case configExtensions[ext]:
+		score *= 2
+	}
+	if f.IsDeleted {
+		score *= 0.25
+	}
+	if strings.HasSuffix(base, "_test.go") || strings.Contains(lower, "/test/") || strings.Contains(lower, "/tests/") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") {
+		score *= 0.5
+	}
+	for _, segment := range sensitiveSegments {
+		if strings.Contains(lower, segment) {
+			score *= 2
+			break
+		}
+	}
+	// Code that keeps changing keeps breaking.
+	if n := s.churn[p]; n > 0 {
+		score *= 1 + math.Log2(float64(n)+1)/2
+	}
+	for _, priority := range s.priorities {
+		for _, pattern := range priority.Paths {
+			if config.MatchPath(pattern, p) && priority.Weight != nil {
+				score *= *priority.Weight
+				break
+			}
+		}
+	}
+	return score
+}
+
+// Scores returns the score of each file, keyed by path.
+func (s *Scorer) Scores(files []diff.FileDiff) map[string]float64 {
+	scores := make(map[string]float64, len(files))
+	for _, f := range files {
+		scores[f.Path()] = s.Score(f)
+	}
+	return scores
+}
+
+// Rank returns files ordered from the highest score to the lowest, keeping
+// the diff order among equal scores.
+func Rank(files []diff.FileDiff, scores map[string]float64) []diff.FileDiff {
+	ranked := append([]diff.FileDiff{}, files...)
+	sort.SliceStable(ranked, func(i, j int) bool { return scores[ranked[i].Path()] > scores[ranked[j].Path()] })
+	return ranked
+}
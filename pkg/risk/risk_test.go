@@ -0,0 +1,66 @@
+package risk
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/config"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+)
+
+// change returns the diff of a file with the given number of added lines.
+func change(path string, added int) diff.FileDiff {
+	text := "diff --git a/" + path + " b/" + path + "\n--- a/" + path + "\n+++ b/" + path + "\n@@ -0,0 +1," + strconv.Itoa(added) + " @@\n" + strings.Repeat("+line\n", added)
+	return diff.Parse(text)[0]
+}
+
+func TestScore(t *testing.T) {
+	zero := 0.0
+	scorer := NewScorer(
+		WithChurn(map[string]int{"hot.go": 8}),
+		WithPriorities([]config.Priority{{Paths: []string{"docs/**"}, Weight: &zero}}),
+	)
+
+	tests := []struct {
+		name           string
+		riskier, safer diff.FileDiff
+	}{
+		{"code over docs", change("main.go", 2), change("README.md", 2)},
+		{"config over docs", change("deploy.yaml", 2), change("README.md", 2)},
+		{"larger change", change("main.go", 8), change("util.go", 1)},
+		{"sensitive path", change("auth/login.go", 2), change("ui/button.go", 2)},
+		{"code over its tests", change("main.go", 2), change("main_test.go", 2)},
+		{"code over lock files", change("main.go", 2), change("go.sum", 2)},
+		{"code over generated code", change("api.go", 2), change("api.pb.go", 2)},
+		{"frequently changed", change("hot.go", 2), change("cold.go", 2)},
+		{"priority weight", change("README.md", 2), change("docs/guide.md", 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			riskier, safer := scorer.Score(tt.riskier), scorer.Score(tt.safer)
+			if riskier <= safer {
+				t.Errorf("Score(%s) = %.2f, want more than Score(%s) = %.2f", tt.riskier.Path(), riskier, tt.safer.Path(), safer)
+			}
+		})
+	}
+	if got := scorer.Score(change("docs/guide.md", 2)); got != 0 {
+		t.Errorf("a zero-weight priority scored %.2f, want 0", got)
+	}
+}
+
+func TestRank(t *testing.T) {
+	files := []diff.FileDiff{change("a.md", 1), change("b.go", 1), change("c.md", 1), change("d.go", 1)}
+	scores := NewScorer().Scores(files)
+
+	var got []string
+	for _, f := range Rank(files, scores) {
+		got = append(got, f.Path())
+	}
+	if want := "b.go d.go a.md c.md"; strings.Join(got, " ") != want {
+		t.Errorf("Rank() = %v, want %s, keeping the diff order among equals", got, want)
+	}
+	if files[0].Path() != "a.md" {
+		t.Error("Rank reordered its input")
+	}
+}
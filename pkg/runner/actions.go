@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Identifiers of the buttons offered on the review's check run.
+const (
+	actionRerun   = "rerun"
+	actionDeep    = "deep"
+	actionDismiss = "dismiss"
+)
+
+// checkRunActions are the buttons added to check runs when
+// Config.CheckActions is set. GitHub limits labels to 20 characters and
+// descriptions to 40.
+var checkRunActions = []github.CheckRunAction{
+	{Label: "Re-run review", Description: "Review the pull request again", Identifier: actionRerun},
+	{Label: "Deep review", Description: "Review again with context and reflection", Identifier: actionDeep},
+	{Label: "Dismiss findings", Description: "Mark the findings as reviewed", Identifier: actionDismiss},
+}
+
+// checkRunEvent is the payload of a check_run event, sent when someone
+// clicks one of the check run's buttons.
+type checkRunEvent struct {
+	Action   string `json:"action"`
+	CheckRun struct {
+		Name         string `json:"name"`
+		HeadSHA      string `json:"head_sha"`
+		PullRequests []struct {
+			Number int `json:"number"`
+		} `json:"pull_requests"`
+	} `json:"check_run"`
+	RequestedAction struct {
+		Identifier string `json:"identifier"`
+	} `json:"requested_action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender github.User `json:"sender"`
+}
+
+// isCheckRunEvent reports whether the run was triggered by a check run
+// button.
+func (o *Orchestrator) isCheckRunEvent() bool {
+	return o.cfg.EventName == "check_run"
+}
+
+func (o *Orchestrator) parseCheckRunEvent() (checkRunEvent, error) {
+	var event checkRunEvent
+	if o.cfg.EventPath == "" {
+		return event, fmt.Errorf("no event payload path set")
+	}
+	data, err := os.ReadFile(o.cfg.EventPath)
+	if err != nil {
+		return event, fmt.Errorf("failed to read event file: %w", err)
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return event, fmt.Errorf("failed to parse event data: %w", err)
+	}
+	return event, nil
+}
+
+// pullRequest returns the pull request the check run belongs to.
+func (e checkRunEvent) pullRequest() (types.PullRequestEvent, error) {
+	var event types.PullRequestEvent
+	if len(e.CheckRun.PullRequests) == 0 {
+		return event, fmt.Errorf("check run %q belongs to no pull request in this repository", e.CheckRun.Name)
+	}
+	event.Action = e.Action
+	event.PullRequest.Number = e.CheckRun.PullRequests[0].Number
+	event.PullRequest.Head.SHA = e.CheckRun.HeadSHA
+	event.Repository.FullName = e.Repository.FullName
+	return event, nil
+}
+
+// handleCheckRunAction prepares the run for the button that was clicked.
+// It returns false when nothing more is to be done, such as after
+// dismissing the findings.
+func (o *Orchestrator) handleCheckRunAction() (bool, error) {
+	event, err := o.parseCheckRunEvent()
+	if err != nil {
+		return false, fmt.Errorf("failed to read check run event: %w", err)
+	}
+	if event.Action != "requested_action" {
+		log.WithField("action", event.Action).Info("Check run event is not a button click; nothing to do")
+		return false, nil
+	}
+	entry := log.WithFields(log.Fields{
+		"action": event.RequestedAction.Identifier,
+		"user":   event.Sender.Login,
+	})
+	switch event.RequestedAction.Identifier {
+	case actionRerun:
+	case actionDeep:
+		o.cfg.ReviewDepth = DepthDeep
+	case actionDismiss:
+		entry.Info("Handling check run action")
+		return false, o.dismissFindings(event)
+	default:
+		entry.Info("Unknown check run action; nothing to do")
+		return false, nil
+	}
+	entry.Info("Handling check run action")
+	// The checkout of a check_run workflow is the default branch, not the
+	// pull request.
+	if o.cfg.DiffFile == "" {
+		o.cfg.DiffFromPullRequest = true
+	}
+	return true, nil
+}
+
+// dismissFindings replaces the review's check run with a neutral one and
+// withdraws a request for changes.
+func (o *Orchestrator) dismissFindings(event checkRunEvent) error {
+	prEvent, err := event.pullRequest()
+	if err != nil {
+		return err
+	}
+	by := "A reviewer"
+	if event.Sender.Login != "" {
+		by = "@" + event.Sender.Login
+	}
+	run := github.CheckRun{
+		Name:       event.CheckRun.Name,
+		HeadSHA:    event.CheckRun.HeadSHA,
+		Conclusion: "neutral",
+		Title:      "Findings dismissed",
+		Summary:    by + " dismissed the findings of this review.",
+		Actions:    []github.CheckRunAction{checkRunActions[0], checkRunActions[1]},
+	}
+	if err := o.github.CreateCheckRun(prEvent, run); err != nil {
+		return fmt.Errorf("failed to create check run: %w", err)
+	}
+
+	previous, err := o.previousVerdict(prEvent)
+	if err != nil {
+		log.WithError(err).Warn("Failed to look up the previous verdict")
+	} else if previous != nil && previous.State == "CHANGES_REQUESTED" {
+		if err := o.github.DismissReview(prEvent, previous.ID, by+" dismissed the findings."); err != nil {
+			log.WithError(err).Warn("Failed to dismiss the request for changes")
+		}
+	}
+	log.WithField("name", event.CheckRun.Name).Info("Findings dismissed")
+	return nil
+}
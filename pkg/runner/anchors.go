@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// sectionFindingIndex lists every finding of the model by ID.
+const sectionFindingIndex = "Finding Index"
+
+// assignFindingIDs numbers the model's findings RR-001, RR-002, and so on,
+// in the order of the review, so each can be found from the summary.
+func assignFindingIDs(comments []types.InlineComment) {
+	for i := range comments {
+		comments[i].ID = fmt.Sprintf("RR-%03d", i+1)
+	}
+}
+
+// findingLinks returns the URLs of the inline comments posted for the head
+// of the pull request, keyed by finding ID. Earlier reviews reuse the same
+// IDs, so comments on other commits are ignored.
+func (o *Orchestrator) findingLinks(prEvent types.PullRequestEvent) map[string]string {
+	comments, err := o.github.ListReviewComments(prEvent)
+	if err != nil {
+		log.WithError(err).Warn("Failed to list review comments; not linking findings from the summary")
+		return nil
+	}
+	links := map[string]string{}
+	for _, c := range comments {
+		if !strings.Contains(c.Body, github.FindingMarker) || c.HTMLURL == "" {
+			continue
+		}
+		if head := prEvent.PullRequest.Head.SHA; head != "" && c.CommitID != head {
+			continue
+		}
+		if id := github.FindingID(c.Body); id != "" {
+			links[id] = c.HTMLURL
+		}
+	}
+	return links
+}
+
+// writeFindingIndex lists the model's findings by ID, the posted ones
+// first, linking each posted one to its inline comment when its URL is
+// known.
+func writeFindingIndex(b *strings.Builder, report reviewReport) {
+	if len(report.Comments) == 0 && len(report.Overflow) == 0 && len(report.Warnings) == 0 {
+		return
+	}
+	writeSection(b, sectionFindingIndex)
+	b.WriteString("| ID | Severity | Location |\n|----|----------|----------|\n")
+	for _, list := range [][]types.InlineComment{report.Comments, report.Overflow, report.Warnings} {
+		for _, c := range list {
+			id := c.ID
+			if url := report.Links[c.ID]; url != "" {
+				id = fmt.Sprintf("[%s](%s)", c.ID, url)
+			}
+			b.WriteString(fmt.Sprintf("| %s | %s | `%s` |\n", id, severityLabel(c.Severity), commentLocation(c)))
+		}
+	}
+}
+
+// commentLocation renders where an inline comment points, such as
+// "main.go:42" or, for a range, "main.go:40-42".
+func commentLocation(c types.InlineComment) string {
+	if c.StartLine > 0 && c.StartLine < c.Line {
+		return fmt.Sprintf("%s:%d-%d", c.File, c.StartLine, c.Line)
+	}
+	return fmt.Sprintf("%s:%d", c.File, c.Line)
+}
+
+// idTag introduces a finding listed in the summary with its ID, if any.
+func idTag(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "`" + id + "` "
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// workflowCommand maps a diagnostic severity onto the workflow command that
+// creates an annotation of that level.
+var workflowCommand = map[string]string{
+	"error":   "error",
+	"warning": "warning",
+	"info":    "notice",
+}
+
+// writeAnnotations writes one ::error, ::warning, or ::notice workflow
+// command per diagnostic, which GitHub shows as annotations on the pull
+// request's Files tab.
+func writeAnnotations(w io.Writer, diagnostics []Diagnostic) error {
+	for _, d := range diagnostics {
+		title := "Repo Ranger"
+		if d.Code != "" && d.Code != "review" {
+			title += " (" + d.Code + ")"
+		}
+		_, err := fmt.Fprintf(w, "::%s file=%s,line=%d,col=%d,title=%s::%s\n",
+			workflowCommand[d.Severity],
+			escapeProperty(d.File), d.Line, d.Column, escapeProperty(title),
+			escapeData(d.Message))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapeData escapes a workflow command message.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a workflow command property value.
+func escapeProperty(s string) string {
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(escapeData(s))
+}
+
+// annotate emits the findings as workflow annotations when enabled. Unlike
+// comments, annotations need no token permissions.
+func (o *Orchestrator) annotate(findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) {
+	if !o.cfg.Annotations {
+		return
+	}
+	if err := writeAnnotations(os.Stdout, collectDiagnostics(findings, comments, fileComments)); err != nil {
+		log.WithError(err).Warn("Failed to write annotations")
+	}
+}
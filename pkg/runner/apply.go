@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// writeAssociations are the commenters who may have suggestions committed
+// to a pull request, or have it reviewed past its daily limit: those who
+// could push to its branch themselves.
+var writeAssociations = map[string]bool{"OWNER": true, "MEMBER": true, "COLLABORATOR": true}
+
+// applySuggestions answers an apply slash command by committing the code
+// suggestions of repo-ranger's inline comments, or only those of the given
+// finding IDs, to the pull request's branch, and replying with a link to
+// the commit. Nothing is pushed to branches of forks, to protected
+// branches, or for commenters without write access; the reply says why.
+func (o *Orchestrator) applySuggestions(commentEvent types.IssueCommentEvent, ids []string) error {
+	reply := func(format string, args ...interface{}) error {
+		return o.replyToComment(commentEvent, fmt.Sprintf(format, args...))
+	}
+	if !writeAssociations[commentEvent.Comment.AuthorAssociation] {
+		return reply("only collaborators with write access may apply suggestions.")
+	}
+	event, err := o.parsePullRequestEvent()
+	if err != nil {
+		return err
+	}
+	prEvent, err := o.github.GetPullRequest(event.Repository.FullName, event.PullRequest.Number)
+	if err != nil {
+		return fmt.Errorf("failed to read pull request: %w", err)
+	}
+	pr := prEvent.PullRequest
+	if pr.State != "open" {
+		return reply("this pull request is closed, so no suggestions were applied.")
+	}
+	repo := prEvent.Repository.FullName
+	if pr.Head.Repo.FullName != repo {
+		return reply("this pull request comes from a fork, which Repo Ranger cannot push to, so no suggestions were applied. Download the review bundle's `suggestions.patch` and apply it locally instead.")
+	}
+	protected, err := o.github.BranchProtected(repo, pr.Head.Ref)
+	if err != nil {
+		return fmt.Errorf("failed to read branch %s: %w", pr.Head.Ref, err)
+	}
+	if protected {
+		return reply("`%s` is a protected branch, which may refuse pushes from Repo Ranger, so no suggestions were applied.", pr.Head.Ref)
+	}
+
+	comments, applied, missing, err := o.pickSuggestions(prEvent, ids)
+	if err != nil {
+		return fmt.Errorf("failed to list review comments: %w", err)
+	}
+	if len(comments) == 0 {
+		if len(missing) > 0 {
+			return reply("found no applicable suggestions for %s.", strings.Join(missing, ", "))
+		}
+		return reply("found no applicable suggestions on this pull request.")
+	}
+
+	byFile := map[string][]types.InlineComment{}
+	for _, c := range comments {
+		byFile[c.File] = append(byFile[c.File], c)
+	}
+	files := map[string]string{}
+	skipped := 0
+	for file, fileComments := range byFile {
+		content, err := o.github.FileContentsAt(repo, pr.Head.SHA, file)
+		if err != nil {
+			log.WithError(err).WithField("file", file).Warn("Failed to read file to apply suggestions")
+			skipped += len(fileComments)
+			continue
+		}
+		lines := len(strings.Split(strings.TrimSuffix(string(content), "\n"), "\n"))
+		edits, dropped := suggestionEdits(fileComments, lines)
+		skipped += dropped
+		if len(edits) > 0 {
+			files[file] = applyEdits(string(content), edits)
+		}
+	}
+	if len(files) == 0 {
+		return reply("none of the suggestions could be applied to the head of this pull request.")
+	}
+
+	message := "Apply Repo Ranger suggestions"
+	if len(applied) > 0 {
+		message += " " + strings.Join(applied, ", ")
+	}
+	message += fmt.Sprintf("\n\nRequested by @%s.", commentEvent.Comment.User.Login)
+	commit, err := o.github.CommitFiles(repo, pr.Head.Ref, pr.Head.SHA, message, files)
+	if errors.Is(err, github.ErrForbidden) {
+		return reply("the token may not push to `%s`; grant the workflow `contents: write` to apply suggestions.", pr.Head.Ref)
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to commit suggestions")
+		return reply("the suggestions could not be committed, possibly because `%s` moved on; try again.", pr.Head.Ref)
+	}
+	log.WithFields(log.Fields{
+		"commit": commit.SHA,
+		"files":  len(files),
+	}).Info("Suggestions committed")
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("applied %d suggestion(s) to %d file(s) in %s.", len(comments)-skipped, len(files), commit.HTMLURL))
+	if skipped > 0 {
+		b.WriteString(fmt.Sprintf(" %d suggestion(s) overlapped another or no longer fit the file and were left out.", skipped))
+	}
+	if len(missing) > 0 {
+		b.WriteString(fmt.Sprintf(" No applicable suggestion was found for %s.", strings.Join(missing, ", ")))
+	}
+	return reply("%s", b.String())
+}
+
+// pickSuggestions returns the suggestions of repo-ranger's inline comments
+// that still apply to the head of the pull request, only those of ids when
+// any are given, with the IDs of the findings picked and of the ids not
+// found.
+func (o *Orchestrator) pickSuggestions(prEvent types.PullRequestEvent, ids []string) ([]types.InlineComment, []string, []string, error) {
+	reviewComments, err := o.github.ListReviewComments(prEvent)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	comments, picked, missing := botSuggestions(reviewComments, o.botLogin(), ids)
+	return comments, picked, missing, nil
+}
+
+// botSuggestions picks the suggestions out of the review comments bot
+// posted, only those of ids when any are given. Anyone can copy a
+// finding's marker into a comment of their own, so comments by others are
+// never picked.
+func botSuggestions(reviewComments []github.ReviewComment, bot string, ids []string) ([]types.InlineComment, []string, []string) {
+	wanted := map[string]bool{}
+	for _, id := range ids {
+		wanted[strings.ToUpper(id)] = true
+	}
+
+	var comments []types.InlineComment
+	var picked []string
+	found := map[string]bool{}
+	for _, rc := range reviewComments {
+		// Outdated comments have no line on the current diff.
+		if !github.SameLogin(bot, rc.User.Login) || !strings.Contains(rc.Body, github.FindingMarker) || rc.Line == 0 || rc.Side == "LEFT" {
+			continue
+		}
+		id := strings.ToUpper(github.FindingID(rc.Body))
+		if len(wanted) > 0 && !wanted[id] {
+			continue
+		}
+		code, ok := suggestionCode(rc.Body)
+		if !ok {
+			continue
+		}
+		comments = append(comments, types.InlineComment{
+			ID:         id,
+			File:       rc.Path,
+			Line:       rc.Line,
+			StartLine:  rc.StartLine,
+			Side:       types.SideRight,
+			Suggestion: code,
+		})
+		if id != "" && !found[id] {
+			found[id] = true
+			picked = append(picked, id)
+		}
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if id = strings.ToUpper(id); !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	sort.Strings(picked)
+	return comments, picked, missing
+}
+
+// suggestionCode returns the code of the suggested change in a comment
+// body, as written by suggestionBlock in package github.
+func suggestionCode(body string) (string, bool) {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "```") || strings.TrimLeft(line, "`") != "suggestion" {
+			continue
+		}
+		fence := strings.TrimSuffix(line, "suggestion")
+		for j := i + 1; j < len(lines); j++ {
+			if lines[j] == fence {
+				return strings.Join(lines[i+1:j], "\n"), true
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+func TestBotSuggestions(t *testing.T) {
+	finding := func(login, id, code string, line int) github.ReviewComment {
+		return github.ReviewComment{
+			User: github.User{Login: login},
+			Path: "main.go",
+			Line: line,
+			Side: "RIGHT",
+			Body: "Use a constant.\n\n```suggestion\n" + code + "\n```\n" + github.FindingMarker + "<!-- repo-ranger:id=" + id + " -->",
+		}
+	}
+	suggestion := func(id, code string, line int) types.InlineComment {
+		return types.InlineComment{ID: id, File: "main.go", Line: line, Side: types.SideRight, Suggestion: code}
+	}
+
+	tests := []struct {
+		name     string
+		comments []github.ReviewComment
+		ids      []string
+		want     []types.InlineComment
+		picked   []string
+		missing  []string
+	}{
+		{
+			name:     "bot suggestion",
+			comments: []github.ReviewComment{finding("github-actions[bot]", "RR-001", "x := 1", 3)},
+			want:     []types.InlineComment{suggestion("RR-001", "x := 1", 3)},
+			picked:   []string{"RR-001"},
+		},
+		{
+			name:     "login as GraphQL names it",
+			comments: []github.ReviewComment{finding("GitHub-Actions", "RR-001", "x := 1", 3)},
+			want:     []types.InlineComment{suggestion("RR-001", "x := 1", 3)},
+			picked:   []string{"RR-001"},
+		},
+		{
+			name: "marker copied by someone else",
+			comments: []github.ReviewComment{
+				finding("mallory", "RR-002", "os.Exit(1)", 4),
+				finding("github-actions[bot]", "RR-001", "x := 1", 3),
+			},
+			want:   []types.InlineComment{suggestion("RR-001", "x := 1", 3)},
+			picked: []string{"RR-001"},
+		},
+		{
+			name:     "copied marker asked for by ID",
+			comments: []github.ReviewComment{finding("mallory", "RR-002", "os.Exit(1)", 4)},
+			ids:      []string{"rr-002"},
+			missing:  []string{"RR-002"},
+		},
+		{
+			name: "only the given IDs",
+			comments: []github.ReviewComment{
+				finding("github-actions[bot]", "RR-001", "x := 1", 3),
+				finding("github-actions[bot]", "RR-002", "y := 2", 5),
+			},
+			ids:    []string{"RR-002"},
+			want:   []types.InlineComment{suggestion("RR-002", "y := 2", 5)},
+			picked: []string{"RR-002"},
+		},
+		{
+			name:     "outdated comment",
+			comments: []github.ReviewComment{finding("github-actions[bot]", "RR-001", "x := 1", 0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, picked, missing := botSuggestions(tt.comments, "github-actions[bot]", tt.ids)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("suggestions = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(picked, tt.picked) || !reflect.DeepEqual(missing, tt.missing) {
+				t.Errorf("picked %v, missing %v, want %v, %v", picked, missing, tt.picked, tt.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package runner
+
+// ForEvent returns an orchestrator that handles another event with the
+// same configuration and clients, such as the next pull request of a
+// batch. Its cost, metrics, and history cover only its own run.
+func (o *Orchestrator) ForEvent(name, path string) *Orchestrator {
+	next := &Orchestrator{
+		cfg:            o.cfg,
+		diff:           o.diff,
+		api:            o.api,
+		github:         o.github,
+		results:        o.results,
+		metrics:        o.metrics,
+		usage:          o.usage,
+		stack:          o.stack,
+		history:        o.history,
+		artifacts:      o.artifacts,
+		bundleArtifact: o.bundleArtifact,
+		runID:          newRunID(),
+	}
+	next.cfg.EventName, next.cfg.EventPath = name, path
+	if o.usage != nil {
+		next.usageBefore, next.requestsBefore = o.usage.Total(), o.usage.Requests()
+		next.modelUsageBefore = o.usage.ByModel()
+	}
+	client := o.api
+	masking, masked := client.(maskingClient)
+	if masked {
+		client = masking.Client
+	}
+	if recording, ok := client.(recordingClient); ok {
+		next.transcript = &transcript{}
+		next.api = recordingClient{Client: recording.Client, transcript: next.transcript}
+		if masked {
+			next.api = maskingClient{Client: next.api}
+		}
+	}
+	return next
+}
+
+// Cost is the estimated spend of the run in US dollars, or 0 when usage is
+// not metered.
+func (o *Orchestrator) Cost() float64 {
+	return o.cost()
+}
+
+// Reviewed reports whether the run reviewed a diff, rather than skipping
+// or handling a command.
+func (o *Orchestrator) Reviewed() bool {
+	return o.stats.reviewed
+}
+
+// Findings is the number of findings the run reported.
+func (o *Orchestrator) Findings() int {
+	total := 0
+	for _, n := range o.stats.findings {
+		total += n
+	}
+	return total
+}
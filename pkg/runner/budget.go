@@ -0,0 +1,177 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// budgetDecision is how a review fits the run's cost cap.
+type budgetDecision struct {
+	// depth, when set, replaces the configured review depth.
+	depth string
+	// skip means not even a summary fits the cap.
+	skip bool
+	// estimate is the estimated cost of the configured review.
+	estimate float64
+}
+
+// WithUsageMeter reads the run's token usage from usage, which should also
+// be given to the API client, to report the cost of the run.
+func WithUsageMeter(usage *api.UsageMeter) Option {
+	return func(o *Orchestrator) {
+		o.usage = usage
+	}
+}
+
+// estimateTokens estimates the prompt and completion tokens of reviewing
+// size characters of diff at depth, in chunks of at most chunkSize. It
+// assumes every request uses its whole completion allowance, so it errs
+// high.
+func (o *Orchestrator) estimateTokens(size int, promptContext []string, depth string, chunkSize int) (prompt, completion int) {
+	overhead := api.EstimateTokens(buildDetailedPrompt("", promptContext))
+	if o.stack != "" {
+		overhead += api.EstimateTokens(o.stack)
+	}
+	if depth == DepthSummary {
+		// One summary per scope, each truncated to a chunk.
+		requests := 1
+		if n := len(o.cfg.Scopes); n > 1 {
+			requests = n
+		}
+		if size > chunkSize {
+			size = chunkSize
+		}
+		return requests * (overhead + size/api.CharsForTokens(1) + 1), requests * o.cfg.MaxTokens
+	}
+
+	split := splitSize(chunkSize, o.cfg.ChunkOverlap)
+	chunks := (size + split - 1) / split
+	if chunks == 0 {
+		chunks = 1
+	}
+	if chunks > 1 {
+		// Overlap fills each later chunk up to chunkSize at most.
+		size += (chunks - 1) * (chunkSize - split)
+	}
+	prompt = chunks*overhead + size/api.CharsForTokens(1) + 1
+	completion = chunks * o.cfg.MaxTokens
+	if depth == DepthDeep {
+		// File excerpts, then a reflection pass that repeats the diff
+		// along with the draft.
+		prompt += 2*size/api.CharsForTokens(1) + chunks*(overhead+o.cfg.MaxTokens)
+		completion *= 2
+	}
+	return prompt, completion
+}
+
+// estimateCost estimates the cost in US dollars of reviewing diffText at
+// depth.
+func (o *Orchestrator) estimateCost(diffText string, promptContext []string, depth string) float64 {
+	caps := api.CapabilitiesFor(o.cfg.Model, o.cfg.ModelCapabilities)
+	prompt, completion := o.estimateTokens(len(diffText), promptContext, depth, chunkBudget(caps, o.cfg.MaxTokens, promptContext, depth))
+	return float64(prompt)*caps.InputCost/1e6 + float64(completion)*caps.OutputCost/1e6
+}
+
+// fitBudget decides how to review within Config.MaxCostPerRun: at the
+// configured depth, degraded to a summary, or not at all.
+func (o *Orchestrator) fitBudget(diffText string, promptContext []string) budgetDecision {
+	if o.cfg.MaxCostPerRun <= 0 {
+		return budgetDecision{}
+	}
+	caps := api.CapabilitiesFor(o.cfg.Model, o.cfg.ModelCapabilities)
+	if caps.InputCost == 0 && caps.OutputCost == 0 {
+		log.WithField("model", o.cfg.Model).Warn("Model has no known prices, so the cost cap cannot be enforced; set input_cost and output_cost in INPUT_MODEL_CAPABILITIES")
+		return budgetDecision{}
+	}
+
+	depth := o.cfg.ReviewDepth
+	decision := budgetDecision{estimate: o.estimateCost(diffText, promptContext, depth)}
+	entry := log.WithFields(log.Fields{
+		"estimate": fmt.Sprintf("$%.4f", decision.estimate),
+		"cap":      fmt.Sprintf("$%.4f", o.cfg.MaxCostPerRun),
+	})
+	if decision.estimate <= o.cfg.MaxCostPerRun {
+		entry.Debug("Review fits the cost cap")
+		return decision
+	}
+	if depth != DepthSummary && o.estimateCost(diffText, promptContext, DepthSummary) <= o.cfg.MaxCostPerRun {
+		entry.Warn("Full review would exceed the cost cap; reviewing at summary depth")
+		decision.depth = DepthSummary
+		return decision
+	}
+	entry.Warn("Even a summary would exceed the cost cap; not reviewing")
+	decision.skip = true
+	return decision
+}
+
+// budgetCheckRun explains that a review was skipped because even a
+// summary would cost more than the cap on a run.
+func budgetCheckRun(estimate, limit float64) github.CheckRun {
+	return github.CheckRun{
+		Name:       "Repo Ranger",
+		Conclusion: "neutral",
+		Title:      "Review skipped: over budget",
+		Summary: fmt.Sprintf("Reviewing this diff was estimated to cost $%.2f, more than the $%.2f cap on a single run, even as a summary. "+
+			"Split the pull request or raise `max_cost_per_run` to review it.", estimate, limit),
+	}
+}
+
+// postSkipped leaves a check run explaining why the pull request was not
+// reviewed, when check runs are enabled.
+func (o *Orchestrator) postSkipped(run github.CheckRun) {
+	if !o.cfg.UseChecks {
+		return
+	}
+	prEvent, err := o.parsePullRequestEvent()
+	if err != nil || prEvent.PullRequest.Number == 0 {
+		return
+	}
+	if err := o.github.CreateCheckRun(prEvent, run); err != nil {
+		log.WithError(err).Error("Failed to create GitHub Check Run")
+	}
+}
+
+// reportCost sets the cost output to the estimated spend of the run, so
+// callers such as serve mode can track budgets.
+func (o *Orchestrator) reportCost() {
+	if _, requests := o.runUsage(); requests == 0 {
+		return
+	}
+	o.setOutput("cost", fmt.Sprintf("%.6f", o.cost()))
+}
+
+// cost is the estimated spend of the run so far, at the prices of each
+// model used, or 0 when usage is not metered.
+func (o *Orchestrator) cost() float64 {
+	total := 0.0
+	for model, usage := range o.modelUsage() {
+		total += api.Cost(api.CapabilitiesFor(model, o.cfg.ModelCapabilities), usage)
+	}
+	return total
+}
+
+// modelUsage is the token usage of the run by model, or nil when usage is
+// not metered.
+func (o *Orchestrator) modelUsage() map[string]types.Usage {
+	if o.usage == nil {
+		return nil
+	}
+	usage := o.usage.ByModel()
+	for model, before := range o.modelUsageBefore {
+		usage[model] = api.SubtractUsage(usage[model], before)
+	}
+	return usage
+}
+
+// runUsage is the token usage and number of requests of the run, or zero
+// when usage is not metered.
+func (o *Orchestrator) runUsage() (types.Usage, int) {
+	if o.usage == nil {
+		return types.Usage{}, 0
+	}
+	return api.SubtractUsage(o.usage.Total(), o.usageBefore), o.usage.Requests() - o.requestsBefore
+}
@@ -0,0 +1,160 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/crazywolf132/repo-ranger/pkg/postprocess"
+	log "github.com/sirupsen/logrus"
+)
+
+// Files written to a review bundle.
+const (
+	bundleSummary     = "summary.md"
+	bundleFindings    = "findings.json"
+	bundleSARIF       = "findings.sarif"
+	bundleTranscripts = "transcripts.jsonl"
+	bundleSuggestions = "suggestions.patch"
+	// bundleGap says why part of the diff was not reviewed line by line,
+	// when it was not.
+	bundleGap = "incomplete.txt"
+)
+
+// writeBundle writes the full results of a run to the review bundle
+// directory: the summary, the findings as JSON and SARIF, the model
+// transcripts, and the suggestion patch and review gap, if any. Secrets are
+// redacted from everything written.
+func (o *Orchestrator) writeBundle(dir, review string, doc postprocess.Document, diagnostics []Diagnostic, patch, gap string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+
+	write := func(name string, data []byte) error {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(o.redact(string(data))), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	}
+	writeJSON := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+		return write(name, append(data, '\n'))
+	}
+
+	if err := write(bundleSummary, []byte(review+"\n")); err != nil {
+		return err
+	}
+	if err := writeJSON(bundleFindings, doc); err != nil {
+		return err
+	}
+	if err := writeJSON(bundleSARIF, buildSARIF(diagnostics)); err != nil {
+		return err
+	}
+	if patch != "" {
+		if err := write(bundleSuggestions, []byte(patch)); err != nil {
+			return err
+		}
+	}
+	if gap != "" {
+		if err := write(bundleGap, []byte(gap+"\n")); err != nil {
+			return err
+		}
+	}
+
+	var transcripts []byte
+	if o.transcript != nil {
+		for _, entry := range o.transcript.Entries() {
+			line, err := json.Marshal(entry)
+			if err != nil {
+				return fmt.Errorf("failed to marshal transcript: %w", err)
+			}
+			transcripts = append(append(transcripts, line...), '\n')
+		}
+	}
+	return write(bundleTranscripts, transcripts)
+}
+
+// saveBundle writes the review bundle to dir, if set, and uploads it as a
+// workflow artifact when running in GitHub Actions.
+func (o *Orchestrator) saveBundle(ctx context.Context, dir, review string, doc postprocess.Document, diagnostics []Diagnostic, patch, gap string) {
+	if dir == "" {
+		return
+	}
+	if err := o.writeBundle(dir, review, doc, diagnostics, patch, gap); err != nil {
+		log.WithError(err).Error("Failed to write review bundle")
+		return
+	}
+	log.WithField("path", dir).Info("Review bundle written")
+	o.setOutput("bundle", dir)
+	if patch != "" {
+		o.setOutput("suggestion_patch", filepath.Join(dir, bundleSuggestions))
+	}
+
+	if o.artifacts == nil {
+		return
+	}
+	id, err := o.artifacts.UploadDir(ctx, o.bundleArtifact, dir)
+	if err != nil {
+		log.WithError(err).Error("Failed to upload review bundle")
+		return
+	}
+	log.WithFields(log.Fields{
+		"name": o.bundleArtifact,
+		"id":   id,
+	}).Info("Review bundle uploaded as a workflow artifact")
+}
+
+// sarifLevels maps diagnostic severities onto SARIF result levels.
+var sarifLevels = map[string]string{
+	"error":   "error",
+	"warning": "warning",
+	"info":    "note",
+}
+
+// buildSARIF returns a SARIF 2.1.0 log of the diagnostics, which code
+// scanning and most SARIF viewers accept.
+func buildSARIF(diagnostics []Diagnostic) map[string]interface{} {
+	results := []map[string]interface{}{}
+	rules := []map[string]interface{}{}
+	seen := map[string]bool{}
+	for _, d := range diagnostics {
+		rule := d.Code
+		if rule == "" {
+			rule = "review"
+		}
+		if !seen[rule] {
+			seen[rule] = true
+			rules = append(rules, map[string]interface{}{"id": rule})
+		}
+		results = append(results, map[string]interface{}{
+			"ruleId":  rule,
+			"level":   sarifLevels[d.Severity],
+			"message": map[string]string{"text": d.Message},
+			"locations": []map[string]interface{}{{
+				"physicalLocation": map[string]interface{}{
+					"artifactLocation": map[string]string{"uri": filepath.ToSlash(d.File)},
+					"region":           map[string]int{"startLine": d.Line, "startColumn": d.Column},
+				},
+			}},
+		})
+	}
+	return map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []map[string]interface{}{{
+			"tool": map[string]interface{}{
+				"driver": map[string]interface{}{
+					"name":           "Repo Ranger",
+					"informationUri": "https://github.com/crazywolf132/repo-ranger",
+					"rules":          rules,
+				},
+			},
+			"results": results,
+		}},
+	}
+}
@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/config"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// buildCategoryContext tells the model which categories of findings are
+// switched off, so it spends no tokens on them.
+func buildCategoryContext(modes map[types.Category]string) string {
+	var off []string
+	for _, c := range types.Categories {
+		if modes[c] == config.CategoryOff {
+			off = append(off, string(c))
+		}
+	}
+	if len(off) == 0 {
+		return ""
+	}
+	return "Do not report findings in these categories: " + strings.Join(off, ", ") + "."
+}
+
+// applyCategories drops the findings in categories switched off and caps
+// those in warn-only categories at minor, so they never block a merge.
+func applyCategories(comments []types.InlineComment, fileComments []types.FileComment, modes map[types.Category]string) ([]types.InlineComment, []types.FileComment) {
+	if len(modes) == 0 {
+		return comments, fileComments
+	}
+	capped := func(s types.Severity) types.Severity {
+		if s.Rank() > types.SeverityMinor.Rank() {
+			return types.SeverityMinor
+		}
+		return s
+	}
+	var kept []types.InlineComment
+	for _, c := range comments {
+		switch modes[c.Category] {
+		case config.CategoryOff:
+			continue
+		case config.CategoryWarnOnly:
+			c.Severity = capped(c.Severity)
+		}
+		kept = append(kept, c)
+	}
+	var keptFiles []types.FileComment
+	for _, c := range fileComments {
+		switch modes[c.Category] {
+		case config.CategoryOff:
+			continue
+		case config.CategoryWarnOnly:
+			c.Severity = capped(c.Severity)
+		}
+		keptFiles = append(keptFiles, c)
+	}
+	if dropped := len(comments) + len(fileComments) - len(kept) - len(keptFiles); dropped > 0 {
+		log.WithField("dropped", dropped).Info("Dropped findings in categories switched off")
+	}
+	return kept, keptFiles
+}
+
+// splitWarnings separates the findings in warn-only categories, which are
+// listed in the summary instead of posted as comments.
+func splitWarnings(comments []types.InlineComment, fileComments []types.FileComment, modes map[types.Category]string) (post []types.InlineComment, warnings []types.InlineComment, postFiles []types.FileComment, fileWarnings []types.FileComment) {
+	for _, c := range comments {
+		if modes[c.Category] == config.CategoryWarnOnly {
+			warnings = append(warnings, c)
+		} else {
+			post = append(post, c)
+		}
+	}
+	for _, c := range fileComments {
+		if modes[c.Category] == config.CategoryWarnOnly {
+			fileWarnings = append(fileWarnings, c)
+		} else {
+			postFiles = append(postFiles, c)
+		}
+	}
+	return post, warnings, postFiles, fileWarnings
+}
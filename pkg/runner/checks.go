@@ -0,0 +1,230 @@
+package runner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/apidoc"
+	"github.com/crazywolf132/repo-ranger/pkg/complexity"
+	"github.com/crazywolf132/repo-ranger/pkg/coverage"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/erraudit"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/schema"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	"github.com/crazywolf132/repo-ranger/pkg/xref"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkSchemaCompatibility compares every changed protobuf or OpenAPI file
+// in the checkout at root against its version at baseRef and returns the
+// structural differences.
+func checkSchemaCompatibility(ctx context.Context, runner diff.Runner, root string, files []diff.FileDiff, baseRef string) []types.Finding {
+	var findings []types.Finding
+	for _, f := range files {
+		path := f.Path()
+		if !schema.IsSchemaFile(path) {
+			continue
+		}
+
+		var before, after string
+		if !f.IsNew {
+			content, err := runner.FileAt(ctx, baseRef, f.OldPath)
+			if err != nil {
+				log.WithError(err).WithField("file", f.OldPath).Warn("Failed to load previous schema version")
+				continue
+			}
+			before = content
+		}
+		if !f.IsDeleted {
+			content, err := readWorkingFile(root, path)
+			if err != nil {
+				log.WithError(err).WithField("file", path).Warn("Failed to read updated schema")
+				continue
+			}
+			after = string(content)
+		}
+
+		result, err := schema.Compare(path, before, after)
+		if err != nil {
+			log.WithError(err).WithField("file", path).Warn("Failed to compare schema versions")
+			continue
+		}
+		findings = append(findings, result...)
+	}
+	return findings
+}
+
+// changedLines collects the added line numbers of every file in the diff.
+func changedLines(files []diff.FileDiff) coverage.Changes {
+	changes := coverage.Changes{}
+	for _, f := range files {
+		for _, l := range f.AddedLines() {
+			changes[f.Path()] = append(changes[f.Path()], l.NewLine)
+		}
+	}
+	return changes
+}
+
+// analyzeFunctions computes size and complexity metrics for the Go
+// functions touched by the diff in the checkout at root, comparing them
+// with their versions at baseRef.
+func analyzeFunctions(ctx context.Context, runner diff.Runner, root string, files []diff.FileDiff, baseRef string) []complexity.Function {
+	var functions []complexity.Function
+	for _, f := range files {
+		path := f.Path()
+		if f.IsDeleted || !complexity.IsGoFile(path) {
+			continue
+		}
+
+		after, err := readWorkingFile(root, path)
+		if err != nil {
+			log.WithError(err).WithField("file", path).Debug("Skipping complexity analysis")
+			continue
+		}
+		var before string
+		if !f.IsNew {
+			if content, err := runner.FileAt(ctx, baseRef, f.OldPath); err == nil {
+				before = content
+			}
+		}
+
+		result, err := complexity.Analyze(path, before, string(after), f.TouchedLines())
+		if err != nil {
+			log.WithError(err).WithField("file", path).Debug("Skipping complexity analysis")
+			continue
+		}
+		functions = append(functions, result...)
+	}
+	return functions
+}
+
+// findAPIChanges returns the exported Go symbols the diff adds or changes,
+// comparing each changed Go file in the checkout at root with its version
+// at baseRef.
+func findAPIChanges(ctx context.Context, runner diff.Runner, root string, files []diff.FileDiff, baseRef string) []apidoc.Symbol {
+	var symbols []apidoc.Symbol
+	for _, f := range files {
+		path := f.Path()
+		if f.IsDeleted || !apidoc.IsGoSource(path) {
+			continue
+		}
+
+		after, err := readWorkingFile(root, path)
+		if err != nil {
+			log.WithError(err).WithField("file", path).Debug("Skipping API documentation check")
+			continue
+		}
+		var before string
+		if !f.IsNew {
+			if content, err := runner.FileAt(ctx, baseRef, f.OldPath); err == nil {
+				before = content
+			}
+		}
+
+		changed, err := apidoc.Changes(path, before, string(after), f.TouchedLines())
+		if err != nil {
+			log.WithError(err).WithField("file", path).Debug("Skipping API documentation check")
+			continue
+		}
+		symbols = append(symbols, changed...)
+	}
+	return symbols
+}
+
+// findErrorHandling audits the error handling on the added lines of the
+// changed Go files in the checkout at root.
+func findErrorHandling(root string, files []diff.FileDiff) []erraudit.Issue {
+	var issues []erraudit.Issue
+	for _, f := range files {
+		path := f.Path()
+		if f.IsDeleted || !erraudit.IsGoSource(path) {
+			continue
+		}
+		var added []int
+		for _, l := range f.AddedLines() {
+			added = append(added, l.NewLine)
+		}
+		if len(added) == 0 {
+			continue
+		}
+		content, err := readWorkingFile(root, path)
+		if err != nil {
+			log.WithError(err).WithField("file", path).Debug("Skipping error handling audit")
+			continue
+		}
+		found, err := erraudit.Audit(path, string(content), added)
+		if err != nil {
+			log.WithError(err).WithField("file", path).Debug("Skipping error handling audit")
+			continue
+		}
+		issues = append(issues, found...)
+	}
+	return issues
+}
+
+// findStaleCallers detects exported Go functions whose signatures changed
+// and returns call sites elsewhere in the checkout at root that the diff
+// did not update.
+func findStaleCallers(ctx context.Context, runner diff.Runner, root string, files []diff.FileDiff, baseRef string) []xref.Caller {
+	var changes []xref.SignatureChange
+	touched := map[string][]int{}
+	for _, f := range files {
+		touched[f.Path()] = f.TouchedLines()
+		if f.IsNew || !strings.HasSuffix(f.OldPath, ".go") {
+			continue
+		}
+
+		before, err := runner.FileAt(ctx, baseRef, f.OldPath)
+		if err != nil {
+			continue
+		}
+		var after string
+		if !f.IsDeleted {
+			content, err := readWorkingFile(root, f.Path())
+			if err != nil {
+				continue
+			}
+			after = string(content)
+		}
+
+		changed, err := xref.ChangedSignatures(f.Path(), before, after)
+		if err != nil {
+			log.WithError(err).WithField("file", f.Path()).Debug("Skipping signature comparison")
+			continue
+		}
+		changes = append(changes, changed...)
+	}
+
+	callers, err := xref.FindCallers(root, changes, touched)
+	if err != nil {
+		log.WithError(err).Warn("Failed to search for callers of changed functions")
+	}
+	return callers
+}
+
+// findSkipMarker looks for a skip pattern in the pull request description
+// and its head commit message, case-insensitively. It returns the matched
+// pattern and where it was found, or empty strings.
+func findSkipMarker(githubClient github.Client, prEvent types.PullRequestEvent, patterns []string) (string, string) {
+	sources := []struct{ name, text string }{
+		{"pull request description", prEvent.PullRequest.Body},
+	}
+	if sha := prEvent.PullRequest.Head.SHA; sha != "" {
+		if message, err := githubClient.CommitMessage(prEvent, sha); err != nil {
+			log.WithError(err).Warn("Failed to read head commit message")
+		} else {
+			sources = append(sources, struct{ name, text string }{"head commit message", message})
+		}
+	}
+
+	for _, source := range sources {
+		text := strings.ToLower(source.text)
+		for _, pattern := range patterns {
+			if strings.Contains(text, strings.ToLower(pattern)) {
+				return pattern, source.name
+			}
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// How each review's summary comment relates to the earlier ones.
+const (
+	CommentAppend = "append" // a new comment, earlier ones minimized as outdated
+	CommentUpdate = "update" // the latest summary comment edited in place
+)
+
+// summaryMarker starts the head marker every summary comment ends with.
+const summaryMarker = "<!-- repo-ranger:head="
+
+// runMarker starts the hidden marker naming the run that wrote a summary
+// comment.
+const runMarker = "<!-- repo-ranger:run="
+
+// postSummary posts the review's summary comment in the configured comment
+// mode.
+func (o *Orchestrator) postSummary(prEvent types.PullRequestEvent, comment string) error {
+	if o.cfg.CommentMode == CommentUpdate {
+		previous, err := o.summaryComments(prEvent)
+		if err != nil {
+			log.WithError(err).Warn("Failed to find the previous summary comment; posting a new one")
+		} else if len(previous) > 0 {
+			return o.github.UpdatePRComment(prEvent, previous[len(previous)-1].DatabaseID, comment)
+		}
+		return o.github.PostPRComment(prEvent, comment)
+	}
+
+	if err := o.github.PostPRComment(prEvent, comment); err != nil {
+		return err
+	}
+	o.minimizeSupersededSummaries(prEvent)
+	return nil
+}
+
+// minimizeSupersededSummaries collapses every summary comment but the
+// latest, so only the current review is expanded on the pull request.
+func (o *Orchestrator) minimizeSupersededSummaries(prEvent types.PullRequestEvent) {
+	summaries, err := o.summaryComments(prEvent)
+	if err != nil {
+		log.WithError(err).Warn("Failed to list earlier summary comments; leaving them expanded")
+		return
+	}
+	if len(summaries) == 0 {
+		return
+	}
+	minimized := 0
+	for _, c := range summaries[:len(summaries)-1] {
+		if c.IsMinimized {
+			continue
+		}
+		if err := o.github.MinimizeComment(c.ID); err != nil {
+			log.WithError(err).WithField("comment", c.DatabaseID).Warn("Failed to minimize superseded summary comment")
+			continue
+		}
+		minimized++
+	}
+	if minimized > 0 {
+		log.WithField("count", minimized).Info("Minimized superseded summary comments")
+	}
+}
+
+// defaultBotLogin is the login of the token GitHub Actions gives workflows,
+// which cannot ask GitHub who it is.
+const defaultBotLogin = "github-actions[bot]"
+
+// botLogin returns the login repo-ranger posts as: Config.BotLogin, the
+// token's own login, or, when GitHub will not say, that of the Actions
+// token.
+func (o *Orchestrator) botLogin() string {
+	if o.cfg.BotLogin != "" {
+		return o.cfg.BotLogin
+	}
+	login, err := o.github.Login()
+	if err != nil {
+		log.WithError(err).WithField("login", defaultBotLogin).Debug("Could not read the token's login; assuming the Actions token")
+		return defaultBotLogin
+	}
+	return login
+}
+
+// summaryComments returns repo-ranger's summary comments on the pull
+// request, oldest first, so a person quoting a summary is left alone.
+func (o *Orchestrator) summaryComments(prEvent types.PullRequestEvent) ([]github.ConversationComment, error) {
+	comments, err := o.github.ListConversationComments(prEvent)
+	if err != nil {
+		return nil, err
+	}
+	return ownSummaries(comments, o.botLogin()), nil
+}
+
+// ownSummaries picks the summary comments bot posted out of comments.
+func ownSummaries(comments []github.ConversationComment, bot string) []github.ConversationComment {
+	var summaries []github.ConversationComment
+	for _, c := range comments {
+		if github.SameLogin(bot, c.Author) && strings.Contains(c.Body, summaryMarker) {
+			summaries = append(summaries, c)
+		}
+	}
+	return summaries
+}
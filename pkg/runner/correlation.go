@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// Correlation IDs tie a run's log lines, comments, and transcripts
+// together: every run gets a random ID, such as "3f9a1c0b7d2e", and every
+// chunk it sends to a model an ID numbered within the run, such as
+// "3f9a1c0b7d2e-3". A process reviews one pull request at a time, so the
+// IDs in use are kept here rather than threaded through every call.
+var correlation struct {
+	sync.Mutex
+	run    string
+	chunks int
+	chunk  string
+}
+
+// newRunID returns a random run ID.
+func newRunID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%012x", time.Now().UnixNano()&0xffffffffffff)
+	}
+	return hex.EncodeToString(b)
+}
+
+// beginCorrelation makes run the ID of the run in progress.
+func beginCorrelation(run string) {
+	correlation.Lock()
+	defer correlation.Unlock()
+	correlation.run, correlation.chunks, correlation.chunk = run, 0, ""
+}
+
+// endCorrelation clears the IDs once the run is over.
+func endCorrelation() {
+	beginCorrelation("")
+}
+
+// beginChunk numbers the next chunk of the run in progress and makes it
+// the chunk in progress, returning its ID, or "" outside a run.
+func beginChunk() string {
+	correlation.Lock()
+	defer correlation.Unlock()
+	if correlation.run == "" {
+		return ""
+	}
+	correlation.chunks++
+	correlation.chunk = fmt.Sprintf("%s-%d", correlation.run, correlation.chunks)
+	return correlation.chunk
+}
+
+// endChunk clears the chunk in progress.
+func endChunk() {
+	correlation.Lock()
+	defer correlation.Unlock()
+	correlation.chunk = ""
+}
+
+// currentIDs returns the IDs of the run and chunk in progress.
+func currentIDs() (run, chunk string) {
+	correlation.Lock()
+	defer correlation.Unlock()
+	return correlation.run, correlation.chunk
+}
+
+// CorrelationHook adds the run_id and chunk_id of the review in progress to
+// every log line.
+type CorrelationHook struct{}
+
+// Levels returns every level, so no line goes untagged.
+func (CorrelationHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire tags the entry with the IDs in use, if any.
+func (CorrelationHook) Fire(entry *log.Entry) error {
+	run, chunk := currentIDs()
+	if run != "" {
+		entry.Data["run_id"] = run
+	}
+	if chunk != "" {
+		entry.Data["chunk_id"] = chunk
+	}
+	return nil
+}
+
+// tagChunk heads a chunk's review with a hidden marker naming the chunk,
+// so the comments parsed from it can record where they came from.
+func tagChunk(id, review string) string {
+	if id == "" {
+		return review
+	}
+	return github.ChunkMarker(id) + "\n" + review
+}
+
+// stripChunkMarkers removes the chunk markers tagChunk added to a review.
+func stripChunkMarkers(review string) string {
+	if !strings.Contains(review, github.ChunkMarkerPrefix) {
+		return review
+	}
+	lines := strings.Split(review, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if github.ChunkID(line) == "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
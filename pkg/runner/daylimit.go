@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// reviewCountMarker starts the hidden marker in which a summary comment
+// counts the automatic reviews of its pull request on the day it was
+// posted, such as "<!-- repo-ranger:reviews=2024-01-02/3 -->". The latest
+// summary comment carries the count forward, so no other state is kept.
+const reviewCountMarker = "<!-- repo-ranger:reviews="
+
+// reviewCountTag returns the marker counting n automatic reviews on day.
+func reviewCountTag(day string, n int) string {
+	return fmt.Sprintf("%s%s/%d -->", reviewCountMarker, day, n)
+}
+
+// parseReviewCount reads the day and count of a comment's review count
+// marker, if it has one.
+func parseReviewCount(body string) (string, int, bool) {
+	i := strings.Index(body, reviewCountMarker)
+	if i < 0 {
+		return "", 0, false
+	}
+	rest := body[i+len(reviewCountMarker):]
+	end := strings.Index(rest, " -->")
+	if end < 0 {
+		return "", 0, false
+	}
+	day, count, ok := strings.Cut(rest[:end], "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n < 0 {
+		return "", 0, false
+	}
+	return day, n, true
+}
+
+// overDailyLimit reports whether the pull request has already had
+// Config.MaxReviewsPerDay automatic reviews today, leaving a
+// neutral check run when check runs are enabled. Reviews asked for with a
+// check run action or by a collaborator's slash command are never limited,
+// but the count is still read so their summary comment carries it forward.
+// Slash commands of other commenters count as automatic reviews.
+func (o *Orchestrator) overDailyLimit() bool {
+	if o.cfg.MaxReviewsPerDay <= 0 {
+		return false
+	}
+	prEvent, err := o.parsePullRequestEvent()
+	if err != nil || prEvent.PullRequest.Number == 0 {
+		return false
+	}
+	zone := o.cfg.TimeZone
+	if zone == nil {
+		zone = time.UTC
+	}
+	today := time.Now().In(zone).Format("2006-01-02")
+	if o.forkRestricted(prEvent) {
+		// The read-only token of a fork's run cannot post the count.
+		log.Warn("Pull request comes from a fork, so today's reviews cannot be counted; the daily review limit is not enforced")
+		return false
+	}
+	done, err := o.reviewsOn(prEvent, today)
+	if err != nil {
+		log.WithError(err).Warn("Failed to count today's reviews; reviewing anyway")
+		return false
+	}
+
+	manual := o.isCheckRunEvent()
+	if o.isCommentEvent() {
+		commentEvent, err := o.parseIssueCommentEvent()
+		manual = err == nil && writeAssociations[commentEvent.Comment.AuthorAssociation]
+	}
+	if manual || done < o.cfg.MaxReviewsPerDay {
+		if !manual {
+			done++
+		}
+		o.reviewCount = reviewCountTag(today, done)
+		return false
+	}
+
+	log.WithFields(log.Fields{
+		"reviews": done,
+		"limit":   o.cfg.MaxReviewsPerDay,
+	}).Info("Daily review limit reached; not reviewing")
+	if o.cfg.UseChecks {
+		run := github.CheckRun{
+			Name:       "Repo Ranger",
+			Conclusion: "neutral",
+			Title:      "Review skipped: daily limit reached",
+			Summary: fmt.Sprintf("This pull request has already been reviewed automatically %d times today, the daily limit. "+
+				"Comment `/ranger review` to review the latest changes anyway.", done),
+		}
+		if err := o.github.CreateCheckRun(prEvent, run); err != nil {
+			log.WithError(err).Error("Failed to create GitHub Check Run")
+		}
+	}
+	return true
+}
+
+// reviewsOn returns how many automatic reviews repo-ranger's latest
+// summary counts on day. Summaries are its summary comments and, in quiet
+// mode, the reviews that carry them.
+func (o *Orchestrator) reviewsOn(prEvent types.PullRequestEvent, day string) (int, error) {
+	comments, err := o.github.ListConversationComments(prEvent)
+	if err != nil {
+		return 0, err
+	}
+	var reviews []github.Review
+	if o.cfg.Quiet {
+		if reviews, err = o.github.ListReviews(prEvent); err != nil {
+			return 0, err
+		}
+	}
+	return reviewCountOn(comments, reviews, o.botLogin(), day), nil
+}
+
+// reviewCountOn returns the count of the latest review count marker on day
+// among the summary comments and summary reviews bot posted. Markers anyone
+// else posts are ignored, so participants cannot reset the count.
+func reviewCountOn(comments []github.ConversationComment, reviews []github.Review, bot, day string) int {
+	var latest string
+	var at time.Time
+	for _, c := range ownSummaries(comments, bot) {
+		if strings.Contains(c.Body, reviewCountMarker) && !c.CreatedAt.Before(at) {
+			latest, at = c.Body, c.CreatedAt
+		}
+	}
+	for _, r := range reviews {
+		if github.SameLogin(bot, r.User.Login) && strings.Contains(r.Body, summaryMarker) &&
+			strings.Contains(r.Body, reviewCountMarker) && !r.SubmittedAt.Before(at) {
+			latest, at = r.Body, r.SubmittedAt
+		}
+	}
+	marked, n, ok := parseReviewCount(latest)
+	if !ok || marked != day {
+		return 0
+	}
+	return n
+}
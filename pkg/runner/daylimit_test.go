@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+)
+
+func TestParseReviewCount(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		day  string
+		n    int
+		ok   bool
+	}{
+		{"round trip", "Summary\n" + reviewCountTag("2024-01-02", 3), "2024-01-02", 3, true},
+		{"zero", reviewCountTag("2024-01-02", 0), "2024-01-02", 0, true},
+		{"no marker", "Summary", "", 0, false},
+		{"unterminated", reviewCountMarker + "2024-01-02/3", "", 0, false},
+		{"no count", reviewCountMarker + "2024-01-02 -->", "", 0, false},
+		{"not a number", reviewCountMarker + "2024-01-02/x -->", "", 0, false},
+		{"negative", reviewCountMarker + "2024-01-02/-1 -->", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day, n, ok := parseReviewCount(tt.body)
+			if day != tt.day || n != tt.n || ok != tt.ok {
+				t.Errorf("parseReviewCount(%q) = %q, %d, %v, want %q, %d, %v", tt.body, day, n, ok, tt.day, tt.n, tt.ok)
+			}
+		})
+	}
+}
+
+func TestReviewCountOn(t *testing.T) {
+	const day = "2024-01-02"
+	at := func(minute int) time.Time {
+		return time.Date(2024, 1, 2, 10, minute, 0, 0, time.UTC)
+	}
+	summary := func(n int) string {
+		return summaryMarker + "abc -->\n" + reviewCountTag(day, n)
+	}
+	comment := func(author string, minute int, body string) github.ConversationComment {
+		return github.ConversationComment{Author: author, CreatedAt: at(minute), Body: body}
+	}
+	review := func(login string, minute int, body string) github.Review {
+		return github.Review{User: github.User{Login: login}, SubmittedAt: at(minute), Body: body}
+	}
+
+	tests := []struct {
+		name     string
+		comments []github.ConversationComment
+		reviews  []github.Review
+		want     int
+	}{
+		{name: "nothing posted", want: 0},
+		{
+			name:     "latest summary comment",
+			comments: []github.ConversationComment{comment("github-actions", 1, summary(1)), comment("github-actions", 2, summary(2))},
+			want:     2,
+		},
+		{
+			name:     "another day",
+			comments: []github.ConversationComment{comment("github-actions", 1, summaryMarker+"abc -->\n"+reviewCountTag("2024-01-01", 5))},
+			want:     0,
+		},
+		{
+			name:     "marker outside a summary",
+			comments: []github.ConversationComment{comment("github-actions", 1, summary(2)), comment("someone", 2, reviewCountTag(day, 0))},
+			want:     2,
+		},
+		{
+			name:     "summary by another author",
+			comments: []github.ConversationComment{comment("someone", 1, summary(0)), comment("github-actions", 2, summary(3))},
+			want:     3,
+		},
+		{
+			name:     "later summary by another author",
+			comments: []github.ConversationComment{comment("github-actions", 1, summary(3)), comment("someone", 2, summary(0))},
+			want:     3,
+		},
+		{
+			name:     "only summaries by another author",
+			comments: []github.ConversationComment{comment("someone", 1, summary(1))},
+			want:     0,
+		},
+		{
+			name:     "later summary review",
+			comments: []github.ConversationComment{comment("github-actions", 1, summary(1))},
+			reviews:  []github.Review{review("github-actions[bot]", 2, summary(2))},
+			want:     2,
+		},
+		{
+			name:     "earlier summary review",
+			comments: []github.ConversationComment{comment("github-actions", 3, summary(4))},
+			reviews:  []github.Review{review("github-actions[bot]", 2, summary(2))},
+			want:     4,
+		},
+		{
+			name:    "review by another author",
+			reviews: []github.Review{review("someone", 1, summary(0)), review("github-actions[bot]", 2, summary(2)), review("someone", 3, summary(0))},
+			want:    2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reviewCountOn(tt.comments, tt.reviews, "github-actions[bot]", day); got != tt.want {
+				t.Errorf("reviewCountOn = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/risk"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Markers around the section of the pull request description that
+// Repo Ranger manages. Anything outside them is left alone.
+const (
+	descriptionStart = "<!-- ranger:start -->"
+	descriptionEnd   = "<!-- ranger:end -->"
+)
+
+// riskGrade grades a change by its most severe finding: High for a
+// critical one, Medium for a major one, and Low otherwise.
+func riskGrade(findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) (string, string) {
+	counts := map[types.Severity]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	for _, c := range comments {
+		counts[c.Severity]++
+	}
+	for _, c := range fileComments {
+		counts[c.Severity]++
+	}
+	critical, major := counts[types.SeverityCritical], counts[types.SeverityMajor]
+	switch {
+	case critical > 0:
+		return "High", fmt.Sprintf("%d critical and %d major finding(s)", critical, major)
+	case major > 0:
+		return "Medium", fmt.Sprintf("%d major finding(s)", major)
+	}
+	return "Low", "no critical or major findings"
+}
+
+// describeChange renders the managed section of the pull request
+// description: the risk grade and a summary of the change.
+func describeChange(files []diff.FileDiff, scores map[string]float64, findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) string {
+	grade, reason := riskGrade(findings, comments, fileComments)
+	added, removed := 0, 0
+	for _, f := range files {
+		added += len(f.AddedLines())
+		removed += len(f.RemovedLines())
+	}
+
+	var b strings.Builder
+	b.WriteString("### Repo Ranger\n\n")
+	b.WriteString(fmt.Sprintf("**Risk:** %s — %s.\n\n", grade, reason))
+	b.WriteString(fmt.Sprintf("**Changes:** %d file(s), +%d/-%d.", len(files), added, removed))
+	const riskiest = 3
+	var names []string
+	for _, f := range risk.Rank(files, scores) {
+		if len(names) == riskiest || scores[f.Path()] == 0 {
+			break
+		}
+		names = append(names, "`"+f.Path()+"`")
+	}
+	if len(names) > 0 {
+		b.WriteString(" Riskiest: " + strings.Join(names, ", ") + ".")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// replaceManagedSection puts section between the markers in body,
+// replacing what was there, or appends it with the markers when body has
+// none.
+func replaceManagedSection(body, section string) string {
+	managed := descriptionStart + "\n" + section + descriptionEnd
+	start := strings.Index(body, descriptionStart)
+	end := strings.Index(body, descriptionEnd)
+	if start >= 0 && end > start {
+		return body[:start] + managed + body[end+len(descriptionEnd):]
+	}
+	if strings.TrimSpace(body) == "" {
+		return managed
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + managed
+}
+
+// updateDescription writes section to the managed part of the pull
+// request description.
+func (o *Orchestrator) updateDescription(prEvent types.PullRequestEvent, section string) {
+	body, err := o.github.PullRequestBody(prEvent)
+	if err != nil {
+		log.WithError(err).Error("Failed to read the pull request description")
+		return
+	}
+	if headSHA := prEvent.PullRequest.Head.SHA; headSHA != "" {
+		section += fmt.Sprintf("\n_Updated for %s._\n", headSHA)
+	}
+	updated := replaceManagedSection(body, section)
+	if updated == body {
+		return
+	}
+	if err := o.github.UpdatePullRequestBody(prEvent, updated); err != nil {
+		log.WithError(err).Error("Failed to update the pull request description")
+		return
+	}
+	log.Info("Pull request description updated")
+}
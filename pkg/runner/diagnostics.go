@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Output formats for local runs.
+const (
+	// FormatMarkdown prints the review as it would be posted.
+	FormatMarkdown = "markdown"
+	// FormatDiagnostics prints one "file:line:col: severity: message" line
+	// per finding, as compilers do.
+	FormatDiagnostics = "diagnostics"
+	// FormatDiagnosticsJSON prints the findings as a JSON array using the
+	// property names of a VS Code problem matcher.
+	FormatDiagnosticsJSON = "diagnostics-json"
+)
+
+// OutputFormats lists the supported output formats.
+var OutputFormats = []string{FormatMarkdown, FormatDiagnostics, FormatDiagnosticsJSON}
+
+// Diagnostic is a single finding located in a file. Line and Column are
+// 1-based; remarks about a whole file are placed at 1:1.
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+// diagnosticSeverity maps a finding severity onto the error, warning, and
+// info levels editors understand.
+func diagnosticSeverity(s types.Severity) string {
+	switch s {
+	case types.SeverityCritical, types.SeverityMajor:
+		return "error"
+	case types.SeverityMinor:
+		return "warning"
+	}
+	return "info"
+}
+
+// reviewCode is the diagnostic code, and SARIF rule ID, of a model finding
+// in category.
+func reviewCode(category types.Category) string {
+	if category == "" {
+		return "review"
+	}
+	return "review/" + string(category)
+}
+
+// collectDiagnostics converts static findings, inline comments, and file
+// comments into diagnostics sorted by location.
+func collectDiagnostics(findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	add := func(file string, line int, severity types.Severity, code, message string) {
+		if line < 1 {
+			line = 1
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			File:     file,
+			Line:     line,
+			Column:   1,
+			Severity: diagnosticSeverity(severity),
+			Code:     code,
+			Message:  strings.Join(strings.Fields(message), " "),
+		})
+	}
+
+	for _, f := range findings {
+		add(f.File, f.Line, f.Severity, f.Source, f.Message)
+	}
+	for _, c := range comments {
+		message := c.Reasoning
+		if message == "" {
+			message = c.Suggestion
+		}
+		line := c.Line
+		if c.Side == types.SideLeft {
+			// Editors show the current file; a remark on removed code has
+			// no line there.
+			line = 1
+		}
+		add(c.File, line, c.Severity, reviewCode(c.Category), message)
+	}
+	for _, c := range fileComments {
+		add(c.File, 1, c.Severity, reviewCode(c.Category), c.Summary)
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].File != diagnostics[j].File {
+			return diagnostics[i].File < diagnostics[j].File
+		}
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+	return diagnostics
+}
+
+// writeDiagnostics writes diagnostics to w in the given format.
+func writeDiagnostics(w io.Writer, format string, diagnostics []Diagnostic) error {
+	if format == FormatDiagnosticsJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diagnostics)
+	}
+	for _, d := range diagnostics {
+		if _, err := fmt.Fprintf(w, "%s:%d:%d: %s: %s\n", d.File, d.Line, d.Column, d.Severity, d.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printResults prints the outcome of a run to stdout in the configured
+// output format. It does nothing unless an output format is set.
+func (o *Orchestrator) printResults(review string, findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) {
+	var err error
+	switch o.cfg.OutputFormat {
+	case "":
+		return
+	case FormatMarkdown:
+		if review != "" {
+			_, err = fmt.Fprintln(os.Stdout, review)
+		}
+	default:
+		err = writeDiagnostics(os.Stdout, o.cfg.OutputFormat, collectDiagnostics(findings, comments, fileComments))
+	}
+	if err != nil {
+		log.WithError(err).Warn("Failed to print results")
+	}
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/apidoc"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxDocDrafts bounds the doc comments drafted in one review; further
+// undocumented symbols are reported without a suggestion.
+const maxDocDrafts = 20
+
+// draftDocComments asks the model to draft doc comments for undocumented
+// exported symbols declared on added lines, one request per file, and
+// turns each drafted comment into an inline comment suggesting it. The
+// findings of the symbols drafted for are dropped, so each symbol is
+// reported once; the rest keep their finding.
+func (o *Orchestrator) draftDocComments(ctx context.Context, files []diff.FileDiff, findings []types.Finding, symbols []apidoc.Symbol) ([]types.Finding, []types.InlineComment) {
+	added := map[string]map[int]bool{}
+	for _, f := range files {
+		added[f.Path()] = map[int]bool{}
+		for _, l := range f.AddedLines() {
+			added[f.Path()][l.NewLine] = true
+		}
+	}
+	byFile := map[string][]apidoc.Symbol{}
+	var order []string
+	n := 0
+	for _, s := range symbols {
+		if !added[s.File][s.Line] || n == maxDocDrafts {
+			continue
+		}
+		if _, ok := byFile[s.File]; !ok {
+			order = append(order, s.File)
+		}
+		byFile[s.File] = append(byFile[s.File], s)
+		n++
+	}
+
+	var comments []types.InlineComment
+	drafted := map[string]map[int]bool{}
+	for _, file := range order {
+		client, model := o.modelFor(file)
+		if client == nil {
+			continue
+		}
+		answer, err := client.Review(ctx, model, buildDocCommentPrompt(file, byFile[file]))
+		if err != nil {
+			log.WithError(err).WithField("file", file).Warn("Failed to draft doc comments")
+			continue
+		}
+		drafts := parseDocDrafts(answer)
+		for _, s := range byFile[file] {
+			lines, ok := drafts[s.Name]
+			if !ok {
+				continue
+			}
+			indent := s.Declaration[:len(s.Declaration)-len(strings.TrimLeft(s.Declaration, " \t"))]
+			var b strings.Builder
+			for _, line := range lines {
+				b.WriteString(indent + line + "\n")
+			}
+			b.WriteString(s.Declaration)
+			comments = append(comments, types.InlineComment{
+				File:       s.File,
+				Line:       s.Line,
+				Side:       types.SideRight,
+				Severity:   types.SeverityMinor,
+				Category:   types.CategoryDocs,
+				Suggestion: b.String(),
+				Reasoning: fmt.Sprintf("Exported %s `%s` has no doc comment. The suggested comment is a draft; "+
+					"check that it says what the code does.", s.Kind, s.Name),
+			})
+			if drafted[s.File] == nil {
+				drafted[s.File] = map[int]bool{}
+			}
+			drafted[s.File][s.Line] = true
+		}
+	}
+
+	var kept []types.Finding
+	for _, f := range findings {
+		if apidoc.IsFinding(f) && drafted[f.File][f.Line] {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	if len(comments) > 0 {
+		log.WithField("count", len(comments)).Debug("Drafted doc comments")
+	}
+	return kept, comments
+}
+
+// parseDocDrafts reads the doc comments of a doc comment prompt's answer,
+// keyed by symbol name. Lines that are not comments are ignored, so stray
+// prose or code fences do not reach a suggestion.
+func parseDocDrafts(answer string) map[string][]string {
+	drafts := map[string][]string{}
+	name := ""
+	for _, line := range strings.Split(answer, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "###"):
+			name = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "###")), "`")
+		case name != "" && strings.HasPrefix(line, "//"):
+			drafts[name] = append(drafts[name], line)
+		}
+	}
+	return drafts
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/config"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// EgressRule keeps the files matching its paths from the review model:
+// Client reviews them with Model instead, or, when Client is nil, nobody
+// does.
+type EgressRule struct {
+	Paths []string
+	// Provider names the client, for logs.
+	Provider string
+	Client   api.Client
+	Model    string
+}
+
+// Matches reports whether path falls under the rule.
+func (r EgressRule) Matches(path string) bool {
+	for _, pattern := range r.Paths {
+		if config.MatchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// egressRule returns the first rule matching path, or nil when path may go
+// to the review model.
+func (o *Orchestrator) egressRule(path string) *EgressRule {
+	for i := range o.cfg.Egress {
+		if o.cfg.Egress[i].Matches(path) {
+			return &o.cfg.Egress[i]
+		}
+	}
+	return nil
+}
+
+// modelFor returns the client and model allowed to read path, or a nil
+// client when no model may.
+func (o *Orchestrator) modelFor(path string) (api.Client, string) {
+	rule := o.egressRule(path)
+	if rule == nil {
+		return o.api, o.cfg.Model
+	}
+	return rule.Client, rule.Model
+}
+
+// shareable drops the files under any egress rule from a diff, for
+// summaries that only the review model writes.
+func (o *Orchestrator) shareable(diffText string) string {
+	if len(o.cfg.Egress) == 0 {
+		return diffText
+	}
+	return strings.TrimSpace(diff.Filter(diffText, func(path string) bool { return o.egressRule(path) == nil }))
+}
+
+// shareableFiles drops the files under any egress rule, for prompt context
+// built from the diff.
+func (o *Orchestrator) shareableFiles(files []diff.FileDiff) []diff.FileDiff {
+	if len(o.cfg.Egress) == 0 {
+		return files
+	}
+	var shared []diff.FileDiff
+	for _, f := range files {
+		if o.egressRule(f.Path()) == nil {
+			shared = append(shared, f)
+		}
+	}
+	return shared
+}
+
+// shareableFindings drops the findings on files under any egress rule, for
+// prompt context built from them.
+func (o *Orchestrator) shareableFindings(findings []types.Finding) []types.Finding {
+	if len(o.cfg.Egress) == 0 {
+		return findings
+	}
+	var shared []types.Finding
+	for _, f := range findings {
+		if o.egressRule(f.File) == nil {
+			shared = append(shared, f)
+		}
+	}
+	return shared
+}
+
+// routedDiff is the part of a diff an egress rule pins to its provider.
+type routedDiff struct {
+	rule *EgressRule
+	text string
+}
+
+// applyEgress splits the files the egress rules keep from the review model
+// off the diff. It returns the rest of the diff, the parts pinned to other
+// providers, and the files no model may read.
+func (o *Orchestrator) applyEgress(diffText string) (string, []routedDiff, []string) {
+	if len(o.cfg.Egress) == 0 {
+		return diffText, nil, nil
+	}
+	var routed []routedDiff
+	var excluded []string
+	byRule := map[*EgressRule]int{}
+	for _, f := range diff.Parse(diffText) {
+		rule := o.egressRule(f.Path())
+		switch {
+		case rule == nil:
+		case rule.Client == nil:
+			excluded = append(excluded, f.Path())
+		default:
+			if _, ok := byRule[rule]; !ok {
+				byRule[rule] = len(routed)
+				routed = append(routed, routedDiff{rule: rule})
+			}
+		}
+	}
+	for i, r := range routed {
+		routed[i].text = strings.TrimSpace(diff.Filter(diffText, func(path string) bool { return o.egressRule(path) == r.rule }))
+	}
+	rest := o.shareable(diffText)
+	if len(routed) > 0 || len(excluded) > 0 {
+		log.WithFields(log.Fields{"routed": len(routed), "excluded": len(excluded)}).Info("Egress policy kept files from the review model")
+	}
+	return rest, routed, excluded
+}
+
+// reviewRouted reviews each pinned part of the diff with its provider and
+// adds the reviews to result.
+func (o *Orchestrator) reviewRouted(ctx context.Context, routed []routedDiff, promptContext []string, depth string, result *diffReview) error {
+	if depth == "" {
+		depth = o.cfg.ReviewDepth
+	}
+	for _, r := range routed {
+		pinned := *o
+		pinned.api, pinned.cfg.Model = r.rule.Client, r.rule.Model
+		// Pinned files may reach no other model, not even for triage.
+		pinned.cfg.TriageModel = ""
+		caps := api.CapabilitiesFor(r.rule.Model, o.cfg.ModelCapabilities)
+		log.WithFields(log.Fields{"provider": r.rule.Provider, "model": r.rule.Model}).Info("Reviewing pinned files with their provider")
+		review, err := pinned.reviewDiff(ctx, r.text, diff.Parse(r.text), promptContext, depth,
+			chunkBudget(caps, o.cfg.MaxTokens, promptContext, depth), nil)
+		if err != nil {
+			return fmt.Errorf("failed during API call for provider %q: %w", r.rule.Provider, err)
+		}
+		result.add(review)
+		if review.Text != "" {
+			result.Text = strings.TrimSpace(result.Text + "\n\n" + review.Text)
+		}
+	}
+	return nil
+}
+
+// confidentialCheckRun explains that every changed file was kept from
+// review by the egress policy.
+func confidentialCheckRun(files []string) github.CheckRun {
+	return github.CheckRun{
+		Name:       "Repo Ranger",
+		Conclusion: "neutral",
+		Title:      "Not reviewed for confidentiality",
+		Summary: fmt.Sprintf("The egress policy keeps every file changed here from the review models: %s. "+
+			"Please review these changes manually.", fileList(files)),
+	}
+}
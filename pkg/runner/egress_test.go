@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+)
+
+// egressClient is an api.Client that is never called; it only marks a rule
+// as pinned to a provider.
+type egressClient struct{}
+
+func (egressClient) Review(ctx context.Context, model, prompt string) (string, error) {
+	return "", nil
+}
+
+func egressDiff(paths ...string) string {
+	var sections []string
+	for _, p := range paths {
+		sections = append(sections, "diff --git a/"+p+" b/"+p+"\n--- a/"+p+"\n+++ b/"+p+"\n@@ -1 +1 @@\n-old\n+new")
+	}
+	return strings.Join(sections, "\n")
+}
+
+// diffPaths returns the paths of the files in a diff, in order.
+func diffPaths(text string) []string {
+	var paths []string
+	for _, f := range diff.Parse(text) {
+		paths = append(paths, f.Path())
+	}
+	return paths
+}
+
+func TestEgressRule(t *testing.T) {
+	o := &Orchestrator{cfg: Config{Egress: []EgressRule{
+		{Paths: []string{"secrets/**"}, Provider: "local", Client: egressClient{}, Model: "local-model"},
+		{Paths: []string{"**/*.key", "secrets/private/**"}},
+	}}}
+
+	tests := []struct {
+		name string
+		path string
+		want int // index of the matching rule, or -1
+	}{
+		{"unmatched", "pkg/main.go", -1},
+		{"first rule", "secrets/db.yml", 0},
+		{"first match wins", "secrets/private/a.txt", 0},
+		{"second rule", "certs/server.key", 1},
+		{"nested glob", "a/b/c.key", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := o.egressRule(tt.path)
+			switch {
+			case tt.want < 0 && got != nil:
+				t.Errorf("egressRule(%q) = %v, want nil", tt.path, got.Paths)
+			case tt.want >= 0 && got != &o.cfg.Egress[tt.want]:
+				t.Errorf("egressRule(%q) = %v, want rule %d", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEgress(t *testing.T) {
+	rules := []EgressRule{
+		{Paths: []string{"secrets/**"}, Provider: "local", Client: egressClient{}, Model: "local-model"},
+		{Paths: []string{"**/*.key"}},
+	}
+
+	tests := []struct {
+		name     string
+		rules    []EgressRule
+		diff     string
+		rest     []string
+		routed   map[string][]string // model to the paths pinned to it
+		excluded []string
+	}{
+		{
+			name: "no rules",
+			diff: egressDiff("main.go", "secrets/db.yml"),
+			rest: []string{"main.go", "secrets/db.yml"},
+		},
+		{
+			name:  "nothing matched",
+			rules: rules,
+			diff:  egressDiff("main.go", "README.md"),
+			rest:  []string{"main.go", "README.md"},
+		},
+		{
+			name:   "routed to another provider",
+			rules:  rules,
+			diff:   egressDiff("main.go", "secrets/db.yml", "secrets/api.yml"),
+			rest:   []string{"main.go"},
+			routed: map[string][]string{"local-model": {"secrets/db.yml", "secrets/api.yml"}},
+		},
+		{
+			name:     "excluded from every model",
+			rules:    rules,
+			diff:     egressDiff("main.go", "certs/server.key"),
+			rest:     []string{"main.go"},
+			excluded: []string{"certs/server.key"},
+		},
+		{
+			name:     "everything kept back",
+			rules:    rules,
+			diff:     egressDiff("secrets/db.yml", "certs/server.key"),
+			routed:   map[string][]string{"local-model": {"secrets/db.yml"}},
+			excluded: []string{"certs/server.key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Orchestrator{cfg: Config{Egress: tt.rules}}
+			rest, routed, excluded := o.applyEgress(tt.diff)
+
+			if got := diffPaths(rest); !reflect.DeepEqual(got, tt.rest) {
+				t.Errorf("rest has %v, want %v", got, tt.rest)
+			}
+			gotRouted := map[string][]string{}
+			for _, r := range routed {
+				gotRouted[r.rule.Model] = diffPaths(r.text)
+			}
+			if len(gotRouted) == 0 {
+				gotRouted = nil
+			}
+			if !reflect.DeepEqual(gotRouted, tt.routed) {
+				t.Errorf("routed = %v, want %v", gotRouted, tt.routed)
+			}
+			if !reflect.DeepEqual(excluded, tt.excluded) {
+				t.Errorf("excluded = %v, want %v", excluded, tt.excluded)
+			}
+		})
+	}
+}
+
+func TestShareableFiles(t *testing.T) {
+	o := &Orchestrator{cfg: Config{Egress: []EgressRule{{Paths: []string{"secrets/**"}}}}}
+	files := diff.Parse(egressDiff("main.go", "secrets/db.yml", "pkg/a.go"))
+
+	var got []string
+	for _, f := range o.shareableFiles(files) {
+		got = append(got, f.Path())
+	}
+	if want := []string{"main.go", "pkg/a.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shareableFiles = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/command"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// isCommentEvent reports whether the workflow was triggered by a comment.
+func (o *Orchestrator) isCommentEvent() bool {
+	switch o.cfg.EventName {
+	case "issue_comment", "pull_request_review_comment":
+		return true
+	}
+	return false
+}
+
+func (o *Orchestrator) parsePullRequestEvent() (types.PullRequestEvent, error) {
+	var event types.PullRequestEvent
+	if o.isCheckRunEvent() {
+		checkRunEvent, err := o.parseCheckRunEvent()
+		if err != nil {
+			return event, err
+		}
+		return checkRunEvent.pullRequest()
+	}
+	if o.isCommentEvent() {
+		commentEvent, err := o.parseIssueCommentEvent()
+		if err != nil {
+			return event, err
+		}
+		if commentEvent.Issue.PullRequest == nil {
+			return event, fmt.Errorf("comment was not left on a pull request")
+		}
+		event.PullRequest.Number = commentEvent.Issue.Number
+		event.Repository.FullName = commentEvent.Repository.FullName
+		return event, nil
+	}
+
+	eventPath := o.cfg.EventPath
+	if eventPath == "" {
+		return event, fmt.Errorf("no event payload path set")
+	}
+
+	data, err := os.ReadFile(eventPath)
+	if err != nil {
+		return event, fmt.Errorf("failed to read event file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &event); err != nil {
+		return event, fmt.Errorf("failed to parse event data: %w", err)
+	}
+
+	return event, nil
+}
+
+func (o *Orchestrator) parseIssueCommentEvent() (types.IssueCommentEvent, error) {
+	var event types.IssueCommentEvent
+	eventPath := o.cfg.EventPath
+	if eventPath == "" {
+		return event, fmt.Errorf("no event payload path set")
+	}
+
+	data, err := os.ReadFile(eventPath)
+	if err != nil {
+		return event, fmt.Errorf("failed to read event file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &event); err != nil {
+		return event, fmt.Errorf("failed to parse event data: %w", err)
+	}
+
+	// Review comment payloads reference the pull request directly.
+	if event.Issue.Number == 0 && event.PullRequest.Number > 0 {
+		event.Issue.Number = event.PullRequest.Number
+		event.Issue.PullRequest = &struct {
+			URL string `json:"url"`
+		}{}
+	}
+
+	return event, nil
+}
+
+// explainChange answers an explain slash command by describing what the
+// targeted part of the diff does and posting the answer as a reply.
+func (o *Orchestrator) explainChange(ctx context.Context, files []diff.FileDiff, target command.Target, commentEvent types.IssueCommentEvent) error {
+	explanation, err := o.explain(ctx, files, target)
+	if err != nil {
+		return err
+	}
+	return o.replyToComment(commentEvent, explanation)
+}
+
+// explain describes what the targeted part of the diff does, as Markdown.
+func (o *Orchestrator) explain(ctx context.Context, files []diff.FileDiff, target command.Target) (string, error) {
+	var fileDiff *diff.FileDiff
+	for i := range files {
+		if files[i].Path() == target.Path || files[i].OldPath == target.Path {
+			fileDiff = &files[i]
+			break
+		}
+	}
+	if fileDiff == nil {
+		return fmt.Sprintf("`%s` is not changed in this pull request, so there is nothing to explain.", target.Path), nil
+	}
+
+	client, model := o.modelFor(fileDiff.Path())
+	if client == nil {
+		return fmt.Sprintf("`%s` is kept from the review models for confidentiality, so it cannot be explained.", target.Path), nil
+	}
+	answer, err := client.Review(ctx, model, buildExplainPrompt(*fileDiff, target, o.loadFileContext(target)))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate explanation: %w", err)
+	}
+	return fmt.Sprintf("**Explanation of `%s`**\n\n%s", target, answer), nil
+}
+
+// loadFileContext returns the current contents of the target file, limited
+// to the requested range plus surrounding lines when a range is given. The
+// path comes from a comment, so it is read with readWorkingFile, and not at
+// all in server mode, which has no checkout.
+func (o *Orchestrator) loadFileContext(target command.Target) string {
+	const surrounding = 20
+	if o.cfg.DiffFromPullRequest {
+		return ""
+	}
+	data, err := readWorkingFile(o.checkout(), target.Path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(data), "\n")
+	start, end := 1, len(lines)
+	if target.HasRange() {
+		if start = target.Start - surrounding; start < 1 {
+			start = 1
+		}
+		if end = target.End + surrounding; end > len(lines) {
+			end = len(lines)
+		}
+	}
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		b.WriteString(fmt.Sprintf("%d: %s\n", i, lines[i-1]))
+	}
+	return b.String()
+}
+
+// replyToComment answers a slash command. Review comments get a threaded
+// reply; issue comments, which cannot be threaded, get a reply quoting the
+// request.
+func (o *Orchestrator) replyToComment(commentEvent types.IssueCommentEvent, body string) error {
+	prEvent, err := o.parsePullRequestEvent()
+	if err != nil {
+		return err
+	}
+	if o.cfg.EventName == "pull_request_review_comment" {
+		return o.github.ReplyToReviewComment(prEvent, commentEvent.Comment.ID, body)
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(commentEvent.Comment.Body), "\n") {
+		b.WriteString("> " + line + "\n")
+	}
+	b.WriteString(fmt.Sprintf("\n@%s %s", commentEvent.Comment.User.Login, body))
+	return o.github.PostPRComment(prEvent, b.String())
+}
@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/command"
+)
+
+func TestLoadFileContext(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "environ")
+	if err := os.WriteFile(secret, []byte("TOKEN=hunter2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for i := 1; i <= 50; i++ {
+		lines = append(lines, "line")
+	}
+	inCheckout(t, map[string]string{"a.go": strings.Join(lines, "\n")}, map[string]string{"env": secret})
+
+	tests := []struct {
+		name        string
+		server      bool
+		target      command.Target
+		first, last string
+	}{
+		{name: "whole file", target: command.Target{Path: "a.go"}, first: "1: line", last: "50: line"},
+		{name: "range with surroundings", target: command.Target{Path: "a.go", Start: 25, End: 26}, first: "5: line", last: "46: line"},
+		{name: "symbolic link", target: command.Target{Path: "env"}},
+		{name: "outside the checkout", target: command.Target{Path: secret}},
+		{name: "server mode", server: true, target: command.Target{Path: "a.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Orchestrator{cfg: Config{DiffFromPullRequest: tt.server}}
+			got := strings.Split(strings.TrimSpace(o.loadFileContext(tt.target)), "\n")
+			if first, last := got[0], got[len(got)-1]; first != tt.first || last != tt.last {
+				t.Errorf("context runs from %q to %q, want %q to %q", first, last, tt.first, tt.last)
+			}
+		})
+	}
+}
+
+func TestRunIgnoresChangedComments(t *testing.T) {
+	for _, action := range []string{"edited", "deleted"} {
+		t.Run(action, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "event.json")
+			payload := `{"action": "` + action + `", "issue": {"number": 1, "pull_request": {"url": "u"}}, "comment": {"id": 1, "body": "/ranger apply"}}`
+			if err := os.WriteFile(path, []byte(payload), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			// No GitHub client or model: reaching either would panic.
+			o := New(Config{EventName: "issue_comment", EventPath: path}, nil, nil, nil)
+			if err := o.run(context.Background()); err != nil {
+				t.Errorf("run() = %v, want nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// forkRestricted reports whether the run was triggered by a pull request
+// from a fork. GitHub gives such pull_request runs a read-only token, so
+// every attempt to comment, create check runs, or submit reviews fails.
+func (o *Orchestrator) forkRestricted(event types.PullRequestEvent) bool {
+	if o.cfg.EventName != "pull_request" {
+		return false
+	}
+	head := event.PullRequest.Head.Repo.FullName
+	base := event.PullRequest.Base.Repo.FullName
+	if base == "" {
+		base = event.Repository.FullName
+	}
+	return head != "" && base != "" && head != base
+}
+
+// writeStepSummary appends the review to the job summary, which needs no
+// token permissions.
+func (o *Orchestrator) writeStepSummary(review string) {
+	path := o.cfg.StepSummaryPath
+	if path == "" {
+		return
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		log.WithError(err).Warn("Failed to open GITHUB_STEP_SUMMARY")
+		return
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%s\n", review); err != nil {
+		log.WithError(err).Warn("Failed to write step summary")
+	}
+}
+
+// bundleDir returns the directory to write the review bundle to. Runs on
+// forked pull requests always write one, to a temporary directory unless
+// INPUT_REVIEW_BUNDLE names one, so a privileged workflow can post it.
+func (o *Orchestrator) bundleDir(event types.PullRequestEvent) string {
+	if o.cfg.ReviewBundle != "" || !o.forkRestricted(event) {
+		return o.cfg.ReviewBundle
+	}
+	dir, err := os.MkdirTemp("", "repo-ranger-review-")
+	if err != nil {
+		log.WithError(err).Warn("Failed to create a directory for the review bundle")
+		return ""
+	}
+	return dir
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/history"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// WithHistory records every review and the findings later addressed in
+// store, for digests of review activity.
+func WithHistory(store *history.Store) Option {
+	return func(o *Orchestrator) {
+		o.history = store
+	}
+}
+
+// historyFindings converts the findings of a review for the history.
+func historyFindings(findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) []history.Finding {
+	var out []history.Finding
+	for _, f := range findings {
+		out = append(out, history.Finding{File: f.File, Line: f.Line, Severity: f.Severity, Source: f.Source, Message: f.Message})
+	}
+	for _, c := range comments {
+		out = append(out, history.Finding{File: c.File, Line: c.Line, Severity: c.Severity, Category: c.Category, Source: "review", Message: c.Reasoning})
+	}
+	for _, c := range fileComments {
+		out = append(out, history.Finding{File: c.File, Severity: c.Severity, Category: c.Category, Source: "review", Message: c.Summary})
+	}
+	return out
+}
+
+// appendHistory adds a record to the history, if the run keeps one.
+func (o *Orchestrator) appendHistory(r history.Record) {
+	if o.history == nil {
+		return
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	if err := o.history.Append(r); err != nil {
+		log.WithError(err).Warn("Failed to record review history")
+	}
+}
+
+// recordReview adds the run's review to the history once its cost is
+// known.
+func (o *Orchestrator) recordReview() {
+	if o.record == nil {
+		return
+	}
+	o.record.Cost = o.cost()
+	o.appendHistory(*o.record)
+}
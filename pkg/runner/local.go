@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/command"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// PatchResult is the outcome of ReviewPatch.
+type PatchResult struct {
+	// Report is the review as Markdown, as it would be posted.
+	Report string
+	// Findings are the results of the deterministic checks; Comments and
+	// FileComments are the model's findings.
+	Findings       []types.Finding
+	Comments       []types.InlineComment
+	FileComments   []types.FileComment
+	Chunks         int
+	FailedChunks   int
+	DeclinedChunks int
+	// Skipped, when set, says why nothing was reviewed.
+	Skipped string
+}
+
+// ReviewDiff reviews a unified diff without publishing anything and returns
+// the report as Markdown, for local tools such as the MCP server. A non-empty
+// focus narrows the review like the review slash command's --focus flag.
+func (o *Orchestrator) ReviewDiff(ctx context.Context, diffText, focus string) (string, error) {
+	result, err := o.ReviewPatch(ctx, diffText, focus)
+	return result.Report, err
+}
+
+// ReviewPatch is ReviewDiff with the findings parsed, for tools that
+// embed the review pipeline.
+func (o *Orchestrator) ReviewPatch(ctx context.Context, diffText, focus string) (PatchResult, error) {
+	diffText = strings.TrimSpace(diffText)
+	if diffText == "" {
+		return PatchResult{Report: "No code changes to review.", Skipped: "no code changes"}, nil
+	}
+	if focus == "" {
+		focus = o.cfg.Focus
+	}
+
+	outcome, err := o.reviewPatch(ctx, diffText, focus)
+	switch {
+	case err != nil:
+		return PatchResult{}, err
+	case outcome.formattingOnly:
+		return PatchResult{Report: formattingOnlySummary, Skipped: "formatting only"}, nil
+	case outcome.skipped != nil:
+		return PatchResult{Report: outcome.skipped.Summary, Skipped: outcome.skipped.Title}, nil
+	case outcome.result.Chunks == 0:
+		return PatchResult{Report: "No changes matched any configured scope.", Skipped: "no scope matched"}, nil
+	}
+	return PatchResult{
+		Report: o.formatReview(reviewReport{
+			Chunks:         outcome.result.Chunks,
+			FailedChunks:   outcome.result.Failed,
+			DeclinedChunks: outcome.result.Declined,
+			Review:         outcome.result.Text,
+			Findings:       outcome.checks.findings,
+			Metrics:        outcome.checks.metrics,
+			Functions:      outcome.checks.functions,
+			Severities:     countSeverities(outcome.checks.findings, outcome.comments, outcome.fileComments),
+		}),
+		Findings:       outcome.checks.findings,
+		Comments:       outcome.comments,
+		FileComments:   outcome.fileComments,
+		Chunks:         outcome.result.Chunks,
+		FailedChunks:   outcome.result.Failed,
+		DeclinedChunks: outcome.result.Declined,
+	}, nil
+}
+
+// Explain explains the change to target, a path optionally followed by a
+// line range ("main.go:10-20"), in a unified diff.
+func (o *Orchestrator) Explain(ctx context.Context, diffText, target string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.cfg.APITimeout)
+	defer cancel()
+	return o.explain(ctx, diff.Parse(strings.TrimSpace(diffText)), command.ParseTarget(target))
+}
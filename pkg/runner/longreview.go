@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Where the complete review goes when it is too long for a comment.
+const (
+	LongReviewTruncate = "truncate" // cut the comment, with no copy elsewhere
+	LongReviewGist     = "gist"     // a secret gist, linked from the comment
+	LongReviewWiki     = "wiki"     // a page of the repository's wiki
+)
+
+// fitComment returns review cut to fit a comment alongside reserve more
+// characters. The complete review is first published to the configured
+// long review target, and the cut comment links to it.
+func (o *Orchestrator) fitComment(prEvent types.PullRequestEvent, review string, reserve int) string {
+	limit := github.MaxCommentLength - reserve
+	if len(review) <= limit {
+		return review
+	}
+
+	repo, number := prEvent.Repository.FullName, prEvent.PullRequest.Number
+	var url string
+	var err error
+	switch o.cfg.LongReviewTarget {
+	case LongReviewGist:
+		url, err = o.github.CreateGist(
+			fmt.Sprintf("Repo Ranger review of %s#%d", repo, number),
+			fmt.Sprintf("repo-ranger-review-%d.md", number),
+			review)
+	case LongReviewWiki:
+		url, err = o.github.PublishWikiPage(repo, fmt.Sprintf("Repo Ranger Review %d", number), review)
+	}
+	if err != nil {
+		log.WithError(err).WithField("target", o.cfg.LongReviewTarget).Warn("Failed to publish the complete review; truncating the comment instead")
+	}
+
+	note := "\n\n---\n> **Truncated:** this review is longer than a comment allows."
+	if url != "" {
+		note += fmt.Sprintf(" Read the [complete review](%s).", url)
+		log.WithField("url", url).Info("Published the complete review")
+	}
+
+	cut := limit - len(note)
+	if cut < 0 {
+		cut = 0
+	}
+	// End on a line, or at least on a whole character.
+	if i := strings.LastIndexByte(review[:cut], '\n'); i > cut/2 {
+		cut = i
+	}
+	for cut > 0 && !utf8.RuneStart(review[cut]) {
+		cut--
+	}
+	return review[:cut] + note
+}
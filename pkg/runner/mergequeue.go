@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crazywolf132/repo-ranger/pkg/event"
+	log "github.com/sirupsen/logrus"
+)
+
+// isMergeGroupEvent reports whether the run checks a merge group of a
+// merge queue, which waits on the review before merging.
+func (o *Orchestrator) isMergeGroupEvent() bool {
+	return o.cfg.EventName == event.MergeGroup
+}
+
+// mergeQueueBudget bounds the review of a merge group by
+// MergeQueueTimeout.
+func (o *Orchestrator) mergeQueueBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	if !o.isMergeGroupEvent() || o.cfg.MergeQueueTimeout <= 0 {
+		return ctx, func() {}
+	}
+	log.WithField("budget", o.cfg.MergeQueueTimeout).Info("Reviewing a merge group within the merge queue budget")
+	return context.WithTimeout(ctx, o.cfg.MergeQueueTimeout)
+}
+
+// mergeQueueFailed is the outcome of a failed review, which for a merge
+// group that ran out of time is neutral, so the queue is not blocked.
+func (o *Orchestrator) mergeQueueFailed(ctx context.Context, err error) unreviewable {
+	if !o.isMergeGroupEvent() || ctx.Err() != context.DeadlineExceeded {
+		return reviewFailed(err)
+	}
+	return unreviewable{
+		conclusion: "neutral",
+		title:      "Review timed out",
+		explanation: fmt.Sprintf("The review of this merge group did not finish within the merge queue budget of %s, so it was not blocked. "+
+			"Raise `merge_queue_timeout` to allow longer reviews.", o.cfg.MergeQueueTimeout),
+	}
+}
+
+// mergeQueueOutcome keeps a merge group review that ran out of time from
+// failing the run, which would remove the group from the queue; whatever
+// was reviewed in time has been reported.
+func (o *Orchestrator) mergeQueueOutcome(ctx context.Context, err error) error {
+	if err == nil || !o.isMergeGroupEvent() || ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	log.WithError(err).WithField("budget", o.cfg.MergeQueueTimeout).Warn("Merge group review ran out of time; not blocking the queue")
+	return nil
+}
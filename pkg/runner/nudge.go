@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// NudgeMarker identifies nudge comments.
+const NudgeMarker = "<!-- repo-ranger:nudge -->"
+
+// defaultNudgeMessage opens a nudge unless the repository sets its own.
+const defaultNudgeMessage = "Is this still in progress? Here is where it stands against the current base branch, in case it helps pick it back up."
+
+// Nudge writes a gentle reminder for a pull request that has gone without
+// updates since idle ago: whether it conflicts with its base, how far the
+// base has moved on and which of its files the base changed since, and a
+// summary-only re-review that looks for risks the drift poses. message
+// replaces the default greeting when set.
+func (o *Orchestrator) Nudge(ctx context.Context, event types.PullRequestEvent, idle time.Duration, message string) (string, error) {
+	pr := event.PullRequest
+	entry := log.WithFields(log.Fields{"repo": event.Repository.FullName, "pr": pr.Number})
+
+	diffText, err := o.github.PullRequestDiff(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch pull request diff: %w", err)
+	}
+	files := diff.Parse(diffText)
+	changed := map[string]bool{}
+	for _, f := range files {
+		changed[f.Path()] = true
+	}
+
+	var drifted []string
+	behind := 0
+	if pr.Base.Ref != "" && pr.Head.SHA != "" {
+		comparison, err := o.github.CompareCommits(event.Repository.FullName, pr.Head.SHA, pr.Base.Ref)
+		if err != nil {
+			entry.WithError(err).Warn("Failed to compare the pull request with its base; leaving out the drift")
+		} else {
+			behind = comparison.AheadBy
+			for _, f := range comparison.Files {
+				if changed[f] {
+					drifted = append(drifted, f)
+				}
+			}
+		}
+	}
+	conflicts := pr.MergeableState == "dirty"
+
+	promptContext := []string{releaseContext(event), driftContext(pr.Base.Ref, behind, drifted, conflicts)}
+	caps := api.CapabilitiesFor(o.cfg.Model, o.cfg.ModelCapabilities)
+	var summary diffReview
+	if shared := o.shareable(diffText); shared != "" {
+		summary, err = o.reviewDiff(ctx, shared, diff.Parse(shared), promptContext, DepthSummary,
+			chunkBudget(caps, o.cfg.MaxTokens, promptContext, DepthSummary), nil)
+		if err != nil {
+			entry.WithError(err).Warn("Failed to re-review pull request; nudging without a summary")
+		}
+	}
+
+	if message == "" {
+		message = defaultNudgeMessage
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("👋 This pull request has not been updated in %d days. %s\n\n", int(idle.Hours()/24), message))
+	if conflicts {
+		b.WriteString(fmt.Sprintf("- **Merge conflicts:** it no longer merges cleanly into `%s`.\n", pr.Base.Ref))
+	}
+	if behind > 0 {
+		line := fmt.Sprintf("- **Base drift:** `%s` has moved %d commits ahead", pr.Base.Ref, behind)
+		if len(drifted) > 0 {
+			line += fmt.Sprintf(", changing %d of its files: %s", len(drifted), fileList(drifted))
+		}
+		b.WriteString(line + ".\n")
+	}
+	if conflicts || behind > 0 {
+		b.WriteString("\n")
+	}
+	if text := strings.TrimSpace(summary.Text); text != "" {
+		b.WriteString("### Summary\n\n" + text + "\n\n")
+	}
+	b.WriteString(NudgeMarker)
+	return b.String(), nil
+}
+
+// driftContext tells the summary prompt how the base moved on since the
+// pull request branched, so the summary can point out new risks.
+func driftContext(base string, behind int, drifted []string, conflicts bool) string {
+	if behind == 0 && !conflicts {
+		return fmt.Sprintf("The pull request is up to date with its base branch `%s`.", base)
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Since this pull request branched, %d commits landed on its base branch `%s`.", behind, base))
+	if len(drifted) > 0 {
+		b.WriteString(" They changed these files the pull request also changes: " + strings.Join(drifted, ", ") + ".")
+	}
+	if conflicts {
+		b.WriteString(" The pull request now conflicts with the base branch.")
+	}
+	b.WriteString(" Besides summarizing the change, point out any risk the drift of the base branch poses to it.")
+	return b.String()
+}
+
+// fileList names up to five files in Markdown, counting the rest.
+func fileList(files []string) string {
+	const listed = 5
+	var names []string
+	for i, f := range files {
+		if i == listed {
+			break
+		}
+		names = append(names, "`"+f+"`")
+	}
+	list := strings.Join(names, ", ")
+	if more := len(files) - len(names); more > 0 {
+		list += fmt.Sprintf(", and %d more", more)
+	}
+	return list
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/command"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// Verdicts a second opinion opens with.
+var opinionVerdicts = map[string]string{
+	"AGREE":    "Agree",
+	"DISAGREE": "Disagree",
+	"NUANCED":  "Nuanced",
+}
+
+// secondOpinion answers a second-opinion slash command by asking the model
+// whether it agrees with a reviewer's inline comment, given the hunk it was
+// left on and the replies in its thread, and replying with the answer. The
+// comment is the one named by the command's argument, an ID or a link to
+// it, or else the comment that starts the thread the command replies to.
+func (o *Orchestrator) secondOpinion(ctx context.Context, commentEvent types.IssueCommentEvent, args []string) error {
+	prEvent, err := o.parsePullRequestEvent()
+	if err != nil {
+		return err
+	}
+	targetID := commentEvent.Comment.InReplyToID
+	if len(args) > 0 {
+		if targetID = reviewCommentID(args[0]); targetID == 0 {
+			return o.replyToComment(commentEvent, fmt.Sprintf("`%s` is not a review comment ID or link.", args[0]))
+		}
+	}
+	if targetID == 0 {
+		return o.replyToComment(commentEvent, "reply with `/ranger second-opinion` in the thread of a review comment, or name the comment: `/ranger second-opinion <comment link>`.")
+	}
+
+	comments, err := o.github.ListReviewComments(prEvent)
+	if err != nil {
+		return fmt.Errorf("failed to list review comments: %w", err)
+	}
+	var target *github.ReviewComment
+	for i := range comments {
+		if comments[i].ID == targetID {
+			target = &comments[i]
+		}
+	}
+	if target == nil {
+		return o.replyToComment(commentEvent, "the review comment could not be found on this pull request.")
+	}
+	if strings.Contains(target.Body, github.FindingMarker) {
+		return o.replyToComment(commentEvent, "that comment is Repo Ranger's own finding; a second opinion is for comments by human reviewers.")
+	}
+
+	// The thread is the comment it starts from and the replies before the
+	// command.
+	root := target.ID
+	if target.InReplyToID != 0 {
+		root = target.InReplyToID
+	}
+	var thread []github.ReviewComment
+	for _, c := range comments {
+		if (c.ID == root || c.InReplyToID == root) && c.ID != commentEvent.Comment.ID {
+			if _, ok := command.Parse(c.Body); !ok {
+				thread = append(thread, c)
+			}
+		}
+	}
+
+	client, model := o.modelFor(target.Path)
+	if client == nil {
+		return o.replyToComment(commentEvent, fmt.Sprintf("`%s` is kept from the review models for confidentiality, so no second opinion can be given.", target.Path))
+	}
+	line := target.Line
+	fileContext := o.loadFileContext(command.Target{Path: target.Path, Start: line, End: line})
+	answer, err := client.Review(ctx, model, buildSecondOpinionPrompt(*target, thread, fileContext))
+	if err != nil {
+		return fmt.Errorf("failed to get a second opinion: %w", err)
+	}
+	verdict, reasoning := parseOpinion(answer)
+	heading := fmt.Sprintf("**Second opinion on @%s's comment on `%s`", target.User.Login, target.Path)
+	if verdict != "" {
+		heading += ": " + verdict
+	}
+	return o.replyToComment(commentEvent, heading+"**\n\n"+reasoning)
+}
+
+// reviewCommentID reads a review comment's ID from an argument: the ID
+// itself or a link ending in "#discussion_r<ID>" or "#r<ID>".
+func reviewCommentID(arg string) int64 {
+	if i := strings.LastIndex(arg, "#"); i >= 0 {
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg[i+1:], "discussion_"), "r")
+	}
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id <= 0 {
+		return 0
+	}
+	return id
+}
+
+// parseOpinion splits a second opinion into its verdict, if it opens with
+// one, and the rest of the answer.
+func parseOpinion(answer string) (string, string) {
+	answer = strings.TrimSpace(answer)
+	first, rest, _ := strings.Cut(answer, "\n")
+	word := strings.ToUpper(strings.Trim(strings.TrimSpace(first), "*_#:. "))
+	verdict, ok := opinionVerdicts[word]
+	if !ok {
+		return "", answer
+	}
+	return verdict, strings.TrimSpace(rest)
+}
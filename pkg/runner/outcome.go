@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// unreviewable explains why a pull request could not be reviewed, so the
+// pull request shows it instead of the run passing or failing silently.
+type unreviewable struct {
+	// conclusion is "neutral" when nothing needs fixing and
+	// "action_required" when the setup does.
+	conclusion string
+	title      string
+	// explanation says what happened and how to fix it.
+	explanation string
+}
+
+// emptyDiff is the outcome of a diff with no changes.
+func (o *Orchestrator) emptyDiff() unreviewable {
+	fix := "If the pull request does change files, check that `diff_command` compares the base branch with the head " +
+		"and that the base branch is fetched, e.g. with `fetch-depth: 0` on `actions/checkout`."
+	if o.cfg.DiffFile != "" || o.cfg.DiffFromPullRequest {
+		fix = "If the pull request does change files, re-run the review."
+	}
+	return unreviewable{
+		conclusion:  "neutral",
+		title:       "Nothing to review",
+		explanation: "The diff of this pull request is empty, so there was nothing to review. " + fix,
+	}
+}
+
+// diffFailed is the outcome of failing to produce the diff.
+func (o *Orchestrator) diffFailed(err error) unreviewable {
+	var fix string
+	switch {
+	case o.cfg.DiffFile != "":
+		fix = fmt.Sprintf("Check that `%s` exists and is readable.", o.cfg.DiffFile)
+	case o.cfg.DiffFromPullRequest:
+		fix = "Check that the app can read pull requests in this repository, then re-run the review."
+	default:
+		fix = fmt.Sprintf("Check that `%s` runs in the checkout and that the revisions it compares are fetched, "+
+			"e.g. with `fetch-depth: 0` on `actions/checkout`.", o.cfg.DiffCommand)
+	}
+	return unreviewable{
+		conclusion:  "action_required",
+		title:       "Review failed: no diff",
+		explanation: fmt.Sprintf("The diff of this pull request could not be produced: `%v`. %s", err, fix),
+	}
+}
+
+// createUnreviewableCheckRun creates a check run on the head of prEvent
+// explaining why it was not reviewed.
+func (o *Orchestrator) createUnreviewableCheckRun(prEvent types.PullRequestEvent, state unreviewable, body string) {
+	run := github.CheckRun{
+		Name:       "Repo Ranger",
+		HeadSHA:    prEvent.PullRequest.Head.SHA,
+		Conclusion: state.conclusion,
+		Title:      state.title,
+		Summary:    body,
+		DetailsURL: o.cfg.RunURL,
+	}
+	if run.HeadSHA == "" {
+		run.HeadSHA = o.cfg.HeadSHA
+	}
+	if run.Conclusion == "action_required" && run.DetailsURL == "" {
+		// GitHub rejects action_required without a link to follow up.
+		run.Conclusion = "failure"
+	}
+	if err := o.github.CreateCheckRun(prEvent, run); err != nil {
+		log.WithError(err).Error("Failed to create GitHub Check Run")
+	}
+}
+
+// reviewFailed is the outcome of a review that produced nothing.
+func reviewFailed(err error) unreviewable {
+	fix := "Check `api_url` and `model`, then re-run the job."
+	switch {
+	case errors.Is(err, api.ErrAuth):
+		fix = "The model API rejected the credentials; check `api_key` and `api_url`."
+	case errors.Is(err, api.ErrContextLengthExceeded):
+		fix = "The diff does not fit the model's context window; set `context_window` in `model_capabilities`, or split the pull request."
+	case errors.Is(err, api.ErrTransient):
+		fix = "The model provider is unavailable or rate limiting; re-run the job later."
+	}
+	return unreviewable{
+		conclusion:  "action_required",
+		title:       "Review failed",
+		explanation: fmt.Sprintf("The review could not be completed: `%v`. %s", err, fix),
+	}
+}
+
+// postUnreviewable leaves a check run and a comment on the pull request
+// explaining why it was not reviewed.
+func (o *Orchestrator) postUnreviewable(state unreviewable) {
+	body := fmt.Sprintf("**%s.** %s", state.title, o.redact(state.explanation))
+	prEvent, err := o.parsePullRequestEvent()
+	if err != nil || prEvent.PullRequest.Number == 0 {
+		if target, ok := o.checkRunTarget(); ok {
+			o.createUnreviewableCheckRun(target, state, body)
+		}
+		return
+	}
+	if o.forkRestricted(prEvent) {
+		o.writeStepSummary(body)
+		return
+	}
+	if o.cfg.UseChecks {
+		o.createUnreviewableCheckRun(prEvent, state, body)
+	}
+	if o.cfg.PostPRComment {
+		if o.cfg.RunURL != "" && state.conclusion != "neutral" {
+			body += fmt.Sprintf(" See the [workflow run](%s) for details.", o.cfg.RunURL)
+		}
+		if err := o.github.PostPRComment(prEvent, body); err != nil {
+			log.WithError(err).Error("Failed to post PR comment")
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/owners"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// unowned groups the findings in paths CODEOWNERS assigns to nobody.
+const unowned = "unowned"
+
+// ownership is the share of a review's findings in one owner's area.
+type ownership struct {
+	Owner      string
+	Severities map[types.Severity]int
+	// Critical locates the owner's critical findings, as "file:line".
+	Critical []string
+}
+
+// loadOwners reads the CODEOWNERS file of the checkout, or in server mode,
+// which has none, of the pull request's base branch, as GitHub does. It
+// returns nil when there is none or it cannot be read.
+func (o *Orchestrator) loadOwners() *owners.Owners {
+	if root := o.checkout(); root != "" {
+		codeowners, err := owners.Load(root)
+		if err != nil {
+			log.WithError(err).Warn("Failed to read CODEOWNERS; findings are not routed to owners")
+		}
+		return codeowners
+	}
+
+	prEvent, err := o.parsePullRequestEvent()
+	if err != nil || prEvent.Repository.FullName == "" {
+		return nil
+	}
+	for _, p := range owners.Paths {
+		data, err := o.github.FileContentsAt(prEvent.Repository.FullName, prEvent.PullRequest.Base.Ref, p)
+		if errors.Is(err, github.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			log.WithError(err).Warn("Failed to read CODEOWNERS; findings are not routed to owners")
+			return nil
+		}
+		return owners.Parse(string(data))
+	}
+	return nil
+}
+
+// countOwnership counts the deterministic findings and the model's
+// comments and file comments by the owners of their files, most critical
+// findings first. A finding in a path with several owners counts for each.
+// It returns nil when no finding has an owner.
+func countOwnership(codeowners *owners.Owners, findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) []ownership {
+	if codeowners == nil {
+		return nil
+	}
+	byOwner := map[string]*ownership{}
+	owned := false
+	add := func(file string, line int, severity types.Severity) {
+		names := codeowners.Of(file)
+		if len(names) == 0 {
+			names = []string{unowned}
+		} else {
+			owned = true
+		}
+		for _, name := range names {
+			o := byOwner[name]
+			if o == nil {
+				o = &ownership{Owner: name, Severities: map[types.Severity]int{}}
+				byOwner[name] = o
+			}
+			o.Severities[severity]++
+			if severity == types.SeverityCritical {
+				location := file
+				if line > 0 {
+					location = fmt.Sprintf("%s:%d", file, line)
+				}
+				o.Critical = append(o.Critical, location)
+			}
+		}
+	}
+	for _, f := range findings {
+		add(f.File, f.Line, f.Severity)
+	}
+	for _, c := range comments {
+		add(c.File, c.Line, c.Severity)
+	}
+	for _, c := range fileComments {
+		add(c.File, 0, c.Severity)
+	}
+	if !owned {
+		return nil
+	}
+
+	out := make([]ownership, 0, len(byOwner))
+	for _, o := range byOwner {
+		out = append(out, *o)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].Owner == unowned) != (out[j].Owner == unowned) {
+			return out[j].Owner == unowned
+		}
+		if len(out[i].Critical) != len(out[j].Critical) {
+			return len(out[i].Critical) > len(out[j].Critical)
+		}
+		return out[i].Owner < out[j].Owner
+	})
+	return out
+}
+
+// writeOwnershipTable writes the number of findings of each severity in
+// each owner's area, and, when cc is set, mentions the owners of critical
+// findings so they are notified.
+func writeOwnershipTable(b *strings.Builder, ownerships []ownership, cc bool) {
+	if len(ownerships) == 0 {
+		return
+	}
+	b.WriteString("| Owner | 🔴 | 🟠 | 🟡 | 🔵 |\n|-------|----|----|----|----|\n")
+	for _, o := range ownerships {
+		name := o.Owner
+		if name != unowned {
+			// Keep the table from notifying everyone it lists.
+			name = "`" + name + "`"
+		}
+		b.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %d |\n", name,
+			o.Severities[types.SeverityCritical], o.Severities[types.SeverityMajor],
+			o.Severities[types.SeverityMinor], o.Severities[types.SeverityNit]))
+	}
+	b.WriteString("\n")
+	if !cc {
+		return
+	}
+	for _, o := range ownerships {
+		if o.Owner != unowned && len(o.Critical) > 0 {
+			b.WriteString(fmt.Sprintf("**cc** %s: critical findings in your area at %s\n\n", o.Owner, fileList(o.Critical)))
+		}
+	}
+}
+
+// ownerTag names the owners of file after a finding listed in the PR
+// comment, without mentioning them.
+func ownerTag(codeowners *owners.Owners, file string) string {
+	names := codeowners.Of(file)
+	if len(names) == 0 {
+		return ""
+	}
+	return " (owned by `" + strings.Join(names, "`, `") + "`)"
+}
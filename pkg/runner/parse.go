@@ -0,0 +1,268 @@
+package runner
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// Fields of the comment blocks a review is asked to write.
+const (
+	fieldFile       = "file"
+	fieldLine       = "line"
+	fieldSeverity   = "severity"
+	fieldCategory   = "category"
+	fieldConfidence = "confidence"
+	fieldSuggestion = "suggestion"
+	fieldReasoning  = "reasoning"
+	fieldSummary    = "summary"
+)
+
+// Kinds of comment block, keyed by their normalized header.
+const (
+	blockInline = "inlinecomment"
+	blockFile   = "filecomment"
+)
+
+// fieldLabels map the labels models write for each field onto the field,
+// keyed by the label as normalizeLabel leaves it.
+var fieldLabels = map[string]string{
+	"file": fieldFile, "path": fieldFile, "filepath": fieldFile, "filename": fieldFile,
+	"line": fieldLine, "linenumber": fieldLine, "lines": fieldLine,
+	"severity":       fieldSeverity,
+	"category":       fieldCategory,
+	"confidence":     fieldConfidence,
+	"codesuggestion": fieldSuggestion, "suggestion": fieldSuggestion, "suggestedcode": fieldSuggestion,
+	"suggestedchange": fieldSuggestion, "suggestedfix": fieldSuggestion,
+	"reasoning": fieldReasoning, "reason": fieldReasoning, "rationale": fieldReasoning, "explanation": fieldReasoning,
+	"summary": fieldSummary, "remark": fieldSummary,
+}
+
+// normalizeLabel strips the Markdown a model may dress a label in, such as
+// list markers, headings, bold, or code spans, and folds case, spaces,
+// dashes, and underscores, so "**Code suggestion**" reads as
+// "codesuggestion".
+func normalizeLabel(label string) string {
+	label = strings.TrimSpace(label)
+	label = strings.TrimLeft(label, "#>-*+ \t")
+	label = strings.Trim(label, "*_` \t")
+	label = strings.ToLower(label)
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(label)
+}
+
+// splitLabel splits a "Label: value" line whose label names a block or a
+// field, returning the normalized label and the value.
+func splitLabel(line string) (string, string, bool) {
+	i := strings.Index(line, ":")
+	if i <= 0 || i > 40 {
+		return "", "", false
+	}
+	label := normalizeLabel(line[:i])
+	if _, ok := fieldLabels[label]; !ok && label != blockInline && label != blockFile {
+		return "", "", false
+	}
+	// "**File:** x" leaves the closing bold after the colon.
+	value := strings.TrimSpace(line[i+1:])
+	value = strings.TrimSpace(strings.TrimLeft(value, "*_"))
+	return label, value, true
+}
+
+// isFence reports whether line opens or closes a fenced code block.
+func isFence(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")
+}
+
+// commentBlock is a comment block being read.
+type commentBlock struct {
+	kind   string
+	fields map[string][]string
+	// field is the field later lines continue, or "" once a blank line
+	// has ended it.
+	field string
+}
+
+func (b *commentBlock) value(field string) string {
+	return strings.TrimSpace(strings.Join(b.fields[field], "\n"))
+}
+
+// parseComments reads the inline and file comment blocks of a review. It
+// tolerates what models do to the requested format: labels in any case or
+// dressed in Markdown, fields in any order, values running over several
+// lines, and suggestions in fenced code blocks. Blocks that cannot be
+// placed, with no file or line, are dropped and counted. Comments record
+// the chunk named by the last chunk marker before them.
+func parseComments(review string) ([]types.InlineComment, []types.FileComment, int) {
+	var comments []types.InlineComment
+	var fileComments []types.FileComment
+	dropped := 0
+	var block *commentBlock
+	inFence := false
+	chunk := ""
+
+	finish := func() {
+		if block == nil {
+			return
+		}
+		switch block.kind {
+		case blockInline:
+			if c, ok := block.inlineComment(); ok {
+				c.Chunk = chunk
+				comments = append(comments, c)
+			} else {
+				dropped++
+			}
+		case blockFile:
+			if c, ok := block.fileComment(); ok {
+				fileComments = append(fileComments, c)
+			} else {
+				dropped++
+			}
+		}
+		block = nil
+	}
+
+	for _, line := range strings.Split(review, "\n") {
+		if id := github.ChunkID(line); id != "" {
+			// The review of the next chunk begins.
+			finish()
+			chunk, inFence = id, false
+			continue
+		}
+		label, value, labelled := splitLabel(line)
+		if labelled && (label == blockInline || label == blockFile) {
+			// A new block ends any fence the last one left open.
+			finish()
+			block = &commentBlock{kind: label, fields: map[string][]string{}}
+			inFence = false
+			continue
+		}
+		if block == nil {
+			continue
+		}
+		if isFence(line) {
+			inFence = !inFence
+			if block.field != "" {
+				block.fields[block.field] = append(block.fields[block.field], line)
+			}
+			continue
+		}
+		switch {
+		case inFence:
+			if block.field != "" {
+				block.fields[block.field] = append(block.fields[block.field], line)
+			}
+		case labelled:
+			block.field = fieldLabels[label]
+			block.fields[block.field] = append(block.fields[block.field], value)
+			inFence = isFence(value) && !strings.HasSuffix(value[3:], value[:3])
+		case strings.TrimSpace(line) == "":
+			// A blank line ends a value that has begun, but not one whose
+			// text starts on a later line.
+			if block.field != "" && block.value(block.field) != "" {
+				block.field = ""
+			}
+		case strings.HasPrefix(strings.TrimSpace(line), "#"):
+			block.field = ""
+		case block.field != "":
+			block.fields[block.field] = append(block.fields[block.field], line)
+		}
+	}
+	finish()
+
+	if dropped > 0 {
+		log.WithField("dropped", dropped).Warn("Dropped review comment blocks without a file or line")
+	}
+	return comments, fileComments, dropped
+}
+
+// inlineComment builds the block's inline comment, or reports that it has
+// no file or line to attach to.
+func (b *commentBlock) inlineComment() (types.InlineComment, bool) {
+	c := types.InlineComment{
+		File:       strings.Trim(b.value(fieldFile), "`"),
+		Severity:   types.Severity(strings.ToLower(b.value(fieldSeverity))),
+		Suggestion: unfence(b.value(fieldSuggestion)),
+		Reasoning:  b.value(fieldReasoning),
+	}
+	c.Category, _ = types.ParseCategory(b.value(fieldCategory))
+	if confidence, ok := types.ParseConfidence(b.value(fieldConfidence)); ok {
+		c.Confidence = &confidence
+	}
+	var ok bool
+	c.StartLine, c.Line, c.Side, ok = parseLine(b.value(fieldLine))
+	return c, ok && c.File != ""
+}
+
+// fileComment builds the block's file comment, or reports that it has no
+// file or remark.
+func (b *commentBlock) fileComment() (types.FileComment, bool) {
+	c := types.FileComment{
+		File:     strings.Trim(b.value(fieldFile), "`"),
+		Severity: types.Severity(strings.ToLower(b.value(fieldSeverity))),
+		Summary:  b.value(fieldSummary),
+	}
+	c.Category, _ = types.ParseCategory(b.value(fieldCategory))
+	if confidence, ok := types.ParseConfidence(b.value(fieldConfidence)); ok {
+		c.Confidence = &confidence
+	}
+	return c, c.File != "" && c.Summary != ""
+}
+
+// parseLine reads a line, such as "42" or "L42", or a range of lines, such
+// as "42-45", of the new file, or "-42", a line of the old file. It
+// returns the first line of a range, or 0 for a single line, and the last.
+func parseLine(s string) (int, int, string, bool) {
+	s = strings.TrimSpace(s)
+	side := types.SideRight
+	if strings.HasPrefix(s, "-") {
+		side, s = types.SideLeft, s[1:]
+	}
+	first, rest := leadingNumber(strings.TrimLeft(s, "Ll"))
+	if first <= 0 {
+		return 0, 0, side, false
+	}
+	rest = strings.TrimSpace(rest)
+	if side == types.SideRight && (strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, "–")) {
+		rest = strings.TrimLeft(strings.TrimLeft(rest, "-–"), " Ll")
+		if last, _ := leadingNumber(rest); last > first {
+			return first, last, side, true
+		}
+	}
+	return 0, first, side, true
+}
+
+// leadingNumber reads the number s starts with, returning 0 when it starts
+// with none, and the rest of s.
+func leadingNumber(s string) (int, string) {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, err := strconv.Atoi(s[:end])
+	if err != nil {
+		return 0, s
+	}
+	return n, s[end:]
+}
+
+// unfence returns the code inside a suggestion wrapped in a fenced code
+// block.
+func unfence(s string) string {
+	if !isFence(s) {
+		return s
+	}
+	if !strings.Contains(s, "\n") {
+		// A fence opened and closed on one line.
+		return strings.TrimSpace(strings.Trim(s, "`~"))
+	}
+	lines := strings.Split(s, "\n")
+	lines = lines[1:]
+	if len(lines) > 0 && isFence(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+func TestParseComments(t *testing.T) {
+	high := types.Confidence(0.9)
+
+	tests := []struct {
+		name    string
+		review  string
+		inline  []types.InlineComment
+		file    []types.FileComment
+		dropped int
+	}{
+		{
+			name:   "no blocks",
+			review: "Looks good overall.",
+		},
+		{
+			name: "plain block",
+			review: `InlineComment:
+File: main.go
+Line: 12
+Severity: major
+Category: bug
+Confidence: high
+Reasoning: The error is ignored.`,
+			inline: []types.InlineComment{{
+				File: "main.go", Line: 12, Side: types.SideRight, Severity: "major",
+				Category: types.CategoryBug, Confidence: &high, Reasoning: "The error is ignored.",
+			}},
+		},
+		{
+			name: "markdown labels in any order",
+			review: `### **Inline Comment:**
+- **Reasoning:** Shadowed variable.
+- **Line:** L7
+- **File path:** ` + "`pkg/a.go`" + `
+- **severity**: Minor`,
+			inline: []types.InlineComment{{
+				File: "pkg/a.go", Line: 7, Side: types.SideRight, Severity: "minor", Reasoning: "Shadowed variable.",
+			}},
+		},
+		{
+			name:   "multi-line values and fenced suggestion",
+			review: "InlineComment:\nFile: a.go\nLine: 3-4\nReasoning:\nFirst line.\nSecond line.\n\nCode suggestion:\n```go\nx := 1\n\ny := 2\n```",
+			inline: []types.InlineComment{{
+				File: "a.go", StartLine: 3, Line: 4, Side: types.SideRight,
+				Reasoning: "First line.\nSecond line.", Suggestion: "x := 1\n\ny := 2",
+			}},
+		},
+		{
+			name:   "old file line",
+			review: "InlineComment:\nFile: a.go\nLine: -9\nReasoning: Removed check.",
+			inline: []types.InlineComment{{File: "a.go", Line: 9, Side: types.SideLeft, Reasoning: "Removed check."}},
+		},
+		{
+			name:   "file comment",
+			review: "FileComment:\nFile: go.mod\nSeverity: nit\nSummary: Tidy the module.",
+			file:   []types.FileComment{{File: "go.mod", Severity: "nit", Summary: "Tidy the module."}},
+		},
+		{
+			name:    "blocks without a file or line are dropped",
+			review:  "InlineComment:\nFile: a.go\nReasoning: No line.\n\nInlineComment:\nLine: 3\n\nFileComment:\nFile: b.go",
+			dropped: 3,
+		},
+		{
+			name:   "chunk markers",
+			review: "InlineComment:\nFile: a.go\nLine: 1\n" + github.ChunkMarker("c2") + "\nInlineComment:\nFile: b.go\nLine: 2",
+			inline: []types.InlineComment{
+				{File: "a.go", Line: 1, Side: types.SideRight},
+				{File: "b.go", Line: 2, Side: types.SideRight, Chunk: "c2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inline, file, dropped := parseComments(tt.review)
+			if !reflect.DeepEqual(inline, tt.inline) {
+				t.Errorf("inline comments = %+v, want %+v", inline, tt.inline)
+			}
+			if !reflect.DeepEqual(file, tt.file) {
+				t.Errorf("file comments = %+v, want %+v", file, tt.file)
+			}
+			if dropped != tt.dropped {
+				t.Errorf("dropped = %d, want %d", dropped, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		in          string
+		start, line int
+		side        string
+		ok          bool
+	}{
+		{"42", 0, 42, types.SideRight, true},
+		{"L42", 0, 42, types.SideRight, true},
+		{" 42 (the loop)", 0, 42, types.SideRight, true},
+		{"42-45", 42, 45, types.SideRight, true},
+		{"L42 – L45", 42, 45, types.SideRight, true},
+		{"45-42", 0, 45, types.SideRight, true},
+		{"-42", 0, 42, types.SideLeft, true},
+		{"0", 0, 0, types.SideRight, false},
+		{"n/a", 0, 0, types.SideRight, false},
+	}
+	for _, tt := range tests {
+		start, line, side, ok := parseLine(tt.in)
+		if start != tt.start || line != tt.line || side != tt.side || ok != tt.ok {
+			t.Errorf("parseLine(%q) = %d, %d, %s, %v, want %d, %d, %s, %v",
+				tt.in, start, line, side, ok, tt.start, tt.line, tt.side, tt.ok)
+		}
+	}
+}
+
+// fuzzPath matches file paths that survive a "File:" label unchanged.
+var fuzzPath = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+func FuzzParseInlineComments(f *testing.F) {
+	f.Add("", "main.go", uint16(12), "The error is ignored.", uint8(1))
+	f.Add("Looks good.\n```go\nx := 1", "pkg/a.go", uint16(3), "Shadowed: x.", uint8(3))
+	f.Add("InlineComment:\nFile: b.go\n"+github.ChunkMarker("c2"), "a.go", uint16(1), "**bold** reasoning", uint8(2))
+	f.Add("FileComment:\nFile: go.mod\nSummary:", "x", uint16(9), "", uint8(0))
+
+	f.Fuzz(func(t *testing.T, noise, path string, line uint16, reasoning string, n uint8) {
+		// Anything may come before the blocks; parsing must not panic on it.
+		parseComments(noise)
+
+		if !fuzzPath.MatchString(path) || line == 0 || strings.ContainsAny(reasoning, "\n`~") || strings.Contains(reasoning, "<!--") {
+			return
+		}
+		// What the parser makes of the value once the label is stripped.
+		reasoning = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(reasoning), "*_"))
+
+		var review strings.Builder
+		review.WriteString(noise)
+		var want []types.InlineComment
+		for i := 0; i < int(n%4)+1; i++ {
+			c := types.InlineComment{File: path, Line: int(line) + i, Side: types.SideRight, Severity: "minor", Reasoning: reasoning}
+			fmt.Fprintf(&review, "\n\nInlineComment:\nFile: %s\nLine: %d\nSeverity: minor\nReasoning: %s\n", c.File, c.Line, reasoning)
+			want = append(want, c)
+		}
+
+		inline, _, _ := parseComments(review.String())
+		if len(inline) < len(want) {
+			t.Fatalf("parsed %d inline comments from %q, want at least %d", len(inline), review.String(), len(want))
+		}
+		got := inline[len(inline)-len(want):]
+		for i := range got {
+			// The noise may name a chunk the blocks then belong to.
+			got[i].Chunk = ""
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parseComments(%q) ends with %+v, want %+v", review.String(), got, want)
+		}
+	})
+}
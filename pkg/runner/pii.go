@@ -0,0 +1,19 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/pii"
+)
+
+// maskingClient masks the personal data in every prompt, so file excerpts,
+// file contexts, and analysis context read from the checkout are masked as
+// well as the diff.
+type maskingClient struct {
+	api.Client
+}
+
+func (c maskingClient) Review(ctx context.Context, model, prompt string) (string, error) {
+	return c.Client.Review(ctx, model, pii.MaskAll(prompt))
+}
@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"context"
+	"testing"
+)
+
+// promptRecorder is an api.Client that keeps the last prompt it is sent.
+type promptRecorder struct {
+	prompt *string
+}
+
+func (c promptRecorder) Review(ctx context.Context, model, prompt string) (string, error) {
+	*c.prompt = prompt
+	return "", nil
+}
+
+func TestMaskingClient(t *testing.T) {
+	var sent string
+	o := New(Config{PIICheck: true, Egress: []EgressRule{{Paths: []string{"secrets/**"}, Client: promptRecorder{&sent}}}},
+		nil, promptRecorder{&sent}, nil)
+
+	excerpt := "Surrounding code:\n1: jane@acme.io,123-45-6789"
+	want := "Surrounding code:\n1: [EMAIL],[NATIONAL-ID]"
+	if _, err := o.api.Review(context.Background(), "m", excerpt); err != nil || sent != want {
+		t.Errorf("review model was sent %q, want %q", sent, want)
+	}
+	client, _ := o.modelFor("secrets/users.csv")
+	if _, err := client.Review(context.Background(), "m", excerpt); err != nil || sent != want {
+		t.Errorf("egress model was sent %q, want %q", sent, want)
+	}
+	if next := o.ForEvent("push", ""); next.api == nil {
+		t.Fatal("ForEvent dropped the client")
+	} else if _, ok := next.api.(maskingClient); !ok {
+		t.Errorf("ForEvent client is %T, want maskingClient", next.api)
+	}
+}
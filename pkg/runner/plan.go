@@ -0,0 +1,236 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/risk"
+	log "github.com/sirupsen/logrus"
+)
+
+// reviewPlan divides a diff between a review at the configured depth and
+// a single summary, so the run stays within Config.TokenBudget.
+type reviewPlan struct {
+	// detailed is reviewed at the configured depth.
+	detailed string
+	// summarized is only summarized, truncated to summaryLimit characters.
+	summarized      string
+	summarizedFiles []string
+	summaryLimit    int
+	// skip means not even a summary fits the budget.
+	skip bool
+	// tokens is the estimated size of the planned review.
+	tokens int
+}
+
+// planReview fits the review into Config.TokenBudget. The riskiest files,
+// by scores, are reviewed at the configured depth as long as the estimate,
+// including a summary of the rest, stays within the budget; the rest are
+// only summarized. It returns nil when the whole diff fits.
+func (o *Orchestrator) planReview(diffText string, files []diff.FileDiff, promptContext []string, scores map[string]float64) *reviewPlan {
+	budget := o.cfg.TokenBudget
+	if budget <= 0 {
+		return nil
+	}
+	caps := api.CapabilitiesFor(o.cfg.Model, o.cfg.ModelCapabilities)
+	depth := o.cfg.ReviewDepth
+	for _, scope := range o.cfg.Scopes {
+		// Plan for the most expensive depth any scope reviews at.
+		if strings.EqualFold(scope.Depth, DepthDeep) || (depth == DepthSummary && scope.Depth != "") {
+			depth = strings.ToLower(scope.Depth)
+		}
+	}
+	detailChunk := chunkBudget(caps, o.cfg.MaxTokens, promptContext, depth)
+	summaryChunk := chunkBudget(caps, o.cfg.MaxTokens, promptContext, DepthSummary)
+	tokens := func(size int, depth string, chunkSize int) int {
+		if size == 0 {
+			return 0
+		}
+		prompt, completion := o.estimateTokens(size, promptContext, depth, chunkSize)
+		return prompt + completion
+	}
+	if depth == DepthSummary && tokens(len(diffText), DepthSummary, summaryChunk) <= budget {
+		return nil
+	}
+	if depth != DepthSummary && tokens(len(diffText), depth, detailChunk) <= budget {
+		return nil
+	}
+
+	// The summary request itself costs its prompt and completion, and it
+	// should see at least minSummary characters of the files it covers.
+	const minSummary = 1000
+	summaryFixed := tokens(1, DepthSummary, 1)
+	if budget-summaryFixed <= 0 {
+		log.WithField("budget", budget).Warn("Token budget is too small for even a summary; not reviewing")
+		return &reviewPlan{skip: true, tokens: summaryFixed}
+	}
+
+	sections := diff.SplitFiles(diffText)
+
+	detailed := map[string]bool{}
+	detailedSize, summarizedSize := 0, len(diffText)
+	if depth != DepthSummary {
+		for _, f := range risk.Rank(files, scores) {
+			size := len(sections[f.Path()])
+			if scores[f.Path()] == 0 || size == 0 {
+				continue
+			}
+			need := tokens(detailedSize+size, depth, detailChunk)
+			if rest := summarizedSize - size; rest > 0 {
+				if rest > minSummary {
+					rest = minSummary
+				}
+				need += summaryFixed + rest/api.CharsForTokens(1)
+			}
+			if need > budget {
+				continue
+			}
+			detailed[f.Path()] = true
+			detailedSize += size
+			summarizedSize -= size
+		}
+	}
+
+	plan := &reviewPlan{}
+	plan.detailed = diff.Filter(diffText, func(p string) bool { return detailed[p] })
+	plan.summarized = diff.Filter(diffText, func(p string) bool { return !detailed[p] })
+	// The summary gets whatever the detailed review leaves.
+	plan.summaryLimit = summaryChunk
+	if fit := api.CharsForTokens(budget - tokens(len(plan.detailed), depth, detailChunk) - summaryFixed); fit < plan.summaryLimit {
+		plan.summaryLimit = fit
+	}
+	for _, f := range files {
+		if !detailed[f.Path()] {
+			plan.summarizedFiles = append(plan.summarizedFiles, f.Path())
+		}
+	}
+	plan.tokens = tokens(len(plan.detailed), depth, detailChunk) + tokens(len(plan.summarized), DepthSummary, plan.summaryLimit)
+	log.WithFields(log.Fields{
+		"budget":     budget,
+		"estimate":   plan.tokens,
+		"detailed":   len(files) - len(plan.summarizedFiles),
+		"summarized": len(plan.summarizedFiles),
+	}).Info("Planned review within the token budget")
+	return plan
+}
+
+// summarizeRest summarizes the files the plan left out of the detailed
+// review and adds the summary to result. It returns the summarized files.
+func (o *Orchestrator) summarizeRest(ctx context.Context, plan *reviewPlan, promptContext []string, result *diffReview) ([]string, error) {
+	text, files := plan.summarized, plan.summarizedFiles
+	if len(o.cfg.Scopes) > 0 {
+		// Files outside every scope are not reviewed at all.
+		inScope := func(p string) bool {
+			for _, scope := range o.cfg.Scopes {
+				if scope.Matches(p) {
+					return true
+				}
+			}
+			return false
+		}
+		text = strings.TrimSpace(diff.Filter(text, inScope))
+		files = nil
+		for _, p := range plan.summarizedFiles {
+			if inScope(p) {
+				files = append(files, p)
+			}
+		}
+		if text == "" {
+			return nil, nil
+		}
+	}
+
+	log.WithField("files", len(files)).Info("Summarizing files left out by the token budget")
+	summary, err := o.reviewDiff(ctx, text, diff.Parse(text), promptContext, DepthSummary, plan.summaryLimit, nil)
+	switch {
+	case err != nil && result.Chunks == 0:
+		return nil, fmt.Errorf("failed during API call: %w", err)
+	case err != nil:
+		log.WithError(err).Error("Failed to summarize files left out by the token budget")
+		result.Chunks++
+		result.Failed++
+		return files, nil
+	}
+	result.Chunks += summary.Chunks
+	result.Declined += summary.Declined
+	if summary.Text != "" {
+		section := "## Summarized Files\n\n" + summary.Text
+		if result.Text == "" {
+			result.Text = section
+		} else {
+			result.Text += "\n\n" + section
+		}
+	}
+	return files, nil
+}
+
+// tokenBudgetCheckRun explains that a review was skipped because even a
+// summary would not fit the token budget.
+func tokenBudgetCheckRun(estimate, budget int) github.CheckRun {
+	return github.CheckRun{
+		Name:       "Repo Ranger",
+		Conclusion: "neutral",
+		Title:      "Review skipped: over token budget",
+		Summary: fmt.Sprintf("Even a summary of this diff needs about %d tokens, more than the token budget of %d. "+
+			"Raise `token_budget` to review it.", estimate, budget),
+	}
+}
+
+// scoreFiles rates the risk of each changed file, including how often it
+// changed in the last Config.ChurnDays when the history is available. In
+// server mode, git's history would be the server's own, so it is not.
+func (o *Orchestrator) scoreFiles(ctx context.Context, files []diff.FileDiff) map[string]float64 {
+	opts := []risk.Option{risk.WithPriorities(o.cfg.Priorities)}
+	if o.cfg.ChurnDays > 0 && o.checkout() != "" {
+		var paths []string
+		for _, f := range files {
+			if !f.IsNew {
+				paths = append(paths, f.Path())
+			}
+		}
+		ref := o.cfg.BaseRef
+		if ref == "" {
+			ref = "HEAD"
+		}
+		since := time.Now().AddDate(0, 0, -o.cfg.ChurnDays)
+		if churn, err := o.diff.Churn(ctx, ref, paths, since); err != nil {
+			log.WithError(err).Debug("Failed to read file history; ranking files without churn")
+		} else {
+			opts = append(opts, risk.WithChurn(churn))
+		}
+	}
+	scores := risk.NewScorer(opts...).Scores(files)
+	if log.IsLevelEnabled(log.DebugLevel) {
+		for i, f := range risk.Rank(files, scores) {
+			log.WithFields(log.Fields{"rank": i + 1, "file": f.Path(), "score": fmt.Sprintf("%.2f", scores[f.Path()])}).Debug("Ranked file by risk")
+		}
+	}
+	return scores
+}
+
+// orderByRisk reorders the sections of diffText from the riskiest file to
+// the safest, so the riskiest files land in the first chunks.
+func orderByRisk(diffText string, files []diff.FileDiff, scores map[string]float64) string {
+	sections := diff.SplitFiles(diffText)
+	if len(files) == 0 || len(sections) == 0 {
+		// Nothing Parse could place; keep the diff as it was.
+		return diffText
+	}
+	ordered := make([]string, 0, len(sections))
+	for _, f := range risk.Rank(files, scores) {
+		if section, ok := sections[f.Path()]; ok {
+			ordered = append(ordered, section)
+			delete(sections, f.Path())
+		}
+	}
+	if len(sections) > 0 || len(ordered) == 0 {
+		// Sections Parse could not place; keep the diff as it was.
+		return diffText
+	}
+	return strings.Join(ordered, "\n")
+}
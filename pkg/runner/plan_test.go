@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+)
+
+func TestOrderByRisk(t *testing.T) {
+	const safe = "diff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-old\n+new"
+	const risky = "diff --git a/auth.go b/auth.go\n--- a/auth.go\n+++ b/auth.go\n@@ -1 +1 @@\n-old\n+new"
+	const svn = "Index: auth.go\n===================================================================\n--- auth.go\t(revision 1)\n+++ auth.go\t(working copy)\n@@ -1 +1 @@\n-old\n+new"
+	const hg = "diff -r 1111111 -r 2222222 auth.go\n--- a/auth.go\tThu Jan 01 00:00:00 1970 +0000\n+++ b/auth.go\tThu Jan 01 00:00:00 1970 +0000\n@@ -1 +1 @@\n-old\n+new"
+	const preamble = "From 1111111 Mon Sep 17 00:00:00 2001\nSubject: [PATCH] nothing to see"
+
+	scores := map[string]float64{"auth.go": 2, "README.md": 1}
+	tests := []struct {
+		name string
+		diff string
+		want string
+	}{
+		{"git reordered", safe + "\n" + risky, risky + "\n" + safe},
+		{"git already ordered", risky + "\n" + safe, risky + "\n" + safe},
+		{"svn", svn, svn},
+		{"hg", hg, hg},
+		{"preamble only", preamble, preamble},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := orderByRisk(tt.diff, diff.Parse(tt.diff), scores)
+			if got != tt.want {
+				t.Errorf("orderByRisk() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
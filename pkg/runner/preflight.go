@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrMissingPermission is returned when the token cannot write an output
+// the run is configured to produce.
+var ErrMissingPermission = errors.New("GitHub token is missing permissions")
+
+// preflight checks, before any model call, that the token can write every
+// output the run is configured to produce, so a missing permission fails
+// the run in seconds rather than after a paid review. Runs without a pull
+// request, and pull requests from forks, which are reviewed without
+// writing to them, are not checked.
+func (o *Orchestrator) preflight() error {
+	if !o.cfg.Preflight {
+		return nil
+	}
+	prEvent, err := o.parsePullRequestEvent()
+	if err != nil || prEvent.PullRequest.Number == 0 || o.forkRestricted(prEvent) {
+		return nil
+	}
+
+	var needed []github.Permission
+	if o.cfg.PostPRComment && !o.cfg.Quiet {
+		needed = append(needed, github.PermissionComment)
+	}
+	if o.cfg.UseChecks {
+		needed = append(needed, github.PermissionChecks)
+	}
+	if o.cfg.Quiet || o.cfg.InlineComments || o.cfg.FileComments || o.cfg.SubmitVerdict {
+		needed = append(needed, github.PermissionReview)
+	}
+
+	var missing []string
+	scopes := map[string]bool{}
+	for _, p := range needed {
+		err := o.github.CheckPermission(prEvent, p)
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, github.ErrForbidden), errors.Is(err, github.ErrNotFound):
+			log.WithError(err).WithField("permission", p.Scope()).Debug("Permission probe failed")
+			missing = append(missing, p.Action())
+			scopes[p.Scope()] = true
+		default:
+			log.WithError(err).WithField("permission", p.Scope()).Warn("Could not check token permission; continuing")
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var grants []string
+	for _, scope := range []string{"pull-requests: write", "checks: write"} {
+		if scopes[scope] {
+			grants = append(grants, "`"+scope+"`")
+		}
+	}
+	return fmt.Errorf("%w: it cannot %s on %s#%d; grant %s in the workflow's permissions block, or turn off the outputs that need them",
+		ErrMissingPermission, strings.Join(missing, " or "), prEvent.Repository.FullName, prEvent.PullRequest.Number, strings.Join(grants, " and "))
+}
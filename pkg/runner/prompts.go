@@ -0,0 +1,289 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/apidoc"
+	"github.com/crazywolf132/repo-ranger/pkg/command"
+	"github.com/crazywolf132/repo-ranger/pkg/complexity"
+	"github.com/crazywolf132/repo-ranger/pkg/coverage"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/erraudit"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	"github.com/crazywolf132/repo-ranger/pkg/xref"
+)
+
+func buildDetailedPrompt(diff string, context []string) string {
+	var b strings.Builder
+	b.WriteString("Perform a detailed, line-by-line review of the following code changes. ")
+	b.WriteString("For each changed line, output your review in the following format (each on a separate line):\n")
+	b.WriteString("InlineComment:\n")
+	b.WriteString("File: <file path>\n")
+	b.WriteString("Line: <line number in the new file, or the range of lines a suggestion replaces, e.g. 42-45; for removed code, the old-file line number prefixed with -, e.g. -42>\n")
+	b.WriteString("Severity: <critical|major|minor|nit>\n")
+	b.WriteString("Category: <bug|security|privacy|performance|style|tests|docs|maintainability>\n")
+	b.WriteString("Confidence: <0.0-1.0, how sure you are that this is a real problem>\n")
+	b.WriteString("Code Suggestion: <the code replacing those lines, on the lines after this label in a ```suggestion fenced block, which may span several lines>\n")
+	b.WriteString("Reasoning: <explanation for the suggestion>\n")
+	b.WriteString("For remarks about a file as a whole, such as its design or structure, use instead:\n")
+	b.WriteString("FileComment:\n")
+	b.WriteString("File: <file path>\n")
+	b.WriteString("Severity: <critical|major|minor|nit>\n")
+	b.WriteString("Category: <bug|security|privacy|performance|style|tests|docs|maintainability>\n")
+	b.WriteString("Confidence: <0.0-1.0>\n")
+	b.WriteString("Summary: <your remark>\n")
+	b.WriteString("\nThen, provide an aggregated summary at the top.\n\n")
+	for _, c := range context {
+		b.WriteString(c)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(diff)
+	return b.String()
+}
+
+func buildSchemaContext(findings []types.Finding) string {
+	var b strings.Builder
+	b.WriteString("A structural comparison of the changed API schema files found these differences:\n")
+	for _, f := range findings {
+		b.WriteString(fmt.Sprintf("- [%s] %s: %s\n", f.Severity, f.File, f.Message))
+	}
+	b.WriteString("For each one, explain the impact on existing clients. Treat breaking changes as critical findings.")
+	return b.String()
+}
+
+func buildCoverageContext(result coverage.Result) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Test coverage of the changed lines is %.1f%%. ", result.Percent()))
+	b.WriteString("The following changed lines are NOT covered by tests; prioritize reviewing these regions ")
+	b.WriteString("and point out missing tests where the risk warrants it:\n")
+	for _, f := range result.Files {
+		if len(f.Uncovered) > 0 {
+			b.WriteString(fmt.Sprintf("- %s: lines %s\n", f.File, strings.Join(coverage.Ranges(f.Uncovered), ", ")))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func buildComplexityContext(functions []complexity.Function) string {
+	var b strings.Builder
+	b.WriteString("Size and cyclomatic complexity of the functions touched by this change (before → after):\n")
+	for _, f := range functions {
+		b.WriteString(fmt.Sprintf("- %s:%d %s\n", f.File, f.Line, f.Describe()))
+	}
+	b.WriteString("Where a function grew substantially in length or complexity, cite these numbers and suggest how to split or simplify it.")
+	return b.String()
+}
+
+func buildCallerContext(callers []xref.Caller) string {
+	var b strings.Builder
+	b.WriteString("These exported functions changed signature, but the following call sites were not updated in this diff:\n")
+	for _, c := range callers {
+		b.WriteString(fmt.Sprintf("- %s:%d calls %s (was `%s`)\n", c.File, c.Line, c.Change.Qualified(), c.Change.Before))
+	}
+	b.WriteString("Mention which of these call sites still need edits and what the required change is.")
+	return b.String()
+}
+
+// maxErrorAuditContext bounds the error handling issues listed in the
+// prompt.
+const maxErrorAuditContext = 30
+
+func buildErrorAuditContext(issues []erraudit.Issue) string {
+	var b strings.Builder
+	b.WriteString("An error handling audit found these issues on the changed lines; each is already reported:\n")
+	for i, issue := range issues {
+		if i == maxErrorAuditContext {
+			b.WriteString(fmt.Sprintf("- and %d more\n", len(issues)-maxErrorAuditContext))
+			break
+		}
+		b.WriteString(fmt.Sprintf("- %s:%d %s: `%s`\n", issue.File, issue.Line, issue.Kind, issue.Code))
+	}
+	b.WriteString("Do not repeat them as findings. Where one hides a failure that matters, explain what goes wrong in this code ")
+	b.WriteString("and suggest the handling, such as the error to return, the context to add, or why it is safe to ignore.")
+	return b.String()
+}
+
+func buildStyleGuideContext(rules string) string {
+	var b strings.Builder
+	b.WriteString("This project has its own style guide. Align your suggestions with these house rules ")
+	b.WriteString("rather than generic best practices, and cite the rule when a change violates it:\n")
+	b.WriteString(rules)
+	return b.String()
+}
+
+func buildFocusContext(focus string) string {
+	var b strings.Builder
+	b.WriteString("The reviewer asked you to focus on: ")
+	b.WriteString(focus)
+	b.WriteString("\nPrioritize findings related to this concern, examine the changes specifically through that lens, ")
+	b.WriteString("and keep unrelated feedback brief.")
+	return b.String()
+}
+
+func buildReflectionPrompt(diff, draft string) string {
+	var b strings.Builder
+	b.WriteString("Below is a draft code review followed by the diff it reviews. Critically re-check every finding ")
+	b.WriteString("against the diff: remove false positives and speculative comments, correct wrong file paths or line ")
+	b.WriteString("numbers, and add any important issues the draft missed. Output the final review in exactly the same ")
+	b.WriteString("format as the draft, with no commentary about the revision.\n\n")
+	b.WriteString("Draft review:\n")
+	b.WriteString(draft)
+	b.WriteString("\n\nDiff:\n")
+	b.WriteString(diff)
+	return b.String()
+}
+
+// buildTriagePrompt asks a cheap model whether a chunk needs a careful
+// review, erring towards risky.
+func buildTriagePrompt(diff string) string {
+	var b strings.Builder
+	b.WriteString("Rate the risk of the following code change for a code reviewer. Answer RISKY if it could plausibly ")
+	b.WriteString("introduce a bug, a security issue, data loss, a concurrency or performance problem, or a breaking ")
+	b.WriteString("change, or if you are unsure. Answer LOW RISK only for changes such as documentation, comments, ")
+	b.WriteString("formatting, renames, or simple configuration. Start your answer with exactly RISKY or LOW RISK, ")
+	b.WriteString("followed by one short sentence explaining why.\n\n")
+	b.WriteString(diff)
+	return b.String()
+}
+
+// buildSummaryPrompt asks for a single high-level summary of the change.
+// Large diffs are truncated after a per-file overview.
+func buildSummaryPrompt(files []diff.FileDiff, diffText string, context []string, maxChunkSize int) string {
+	var b strings.Builder
+	b.WriteString("Summarize the following code changes for a pull request reviewer. Describe what changed and why ")
+	b.WriteString("it matters, then list only the most significant risks or bugs, if any. ")
+	b.WriteString("Do not produce line-by-line comments and skip style nits.\n\n")
+	for _, c := range context {
+		b.WriteString(c)
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString("Changed files:\n")
+	for _, f := range files {
+		b.WriteString(fmt.Sprintf("- %s (+%d/-%d)\n", f.Path(), len(f.AddedLines()), len(f.RemovedLines())))
+	}
+	b.WriteString("\n")
+
+	if len(diffText) > maxChunkSize {
+		b.WriteString(diffText[:maxChunkSize])
+		b.WriteString("\n[diff truncated]")
+	} else {
+		b.WriteString(diffText)
+	}
+	return b.String()
+}
+
+// buildReleaseNotesPrompt asks for user-facing release notes from the
+// summaries of merged pull requests, grouped under the given sections.
+func buildReleaseNotesPrompt(entries []releaseEntry) string {
+	const maxSummary = 1500
+	var b strings.Builder
+	b.WriteString("Write user-facing release notes in Markdown from the merged pull requests below. Keep the section ")
+	b.WriteString("headings and their order, and put each pull request under its heading as one bullet of one or two ")
+	b.WriteString("sentences describing the change from a user's point of view, ending with its reference, such as (#123). ")
+	b.WriteString("Leave out changes that do not affect users, such as refactors and test changes, and omit sections ")
+	b.WriteString("left empty. Output only the release notes.\n\n")
+	for _, group := range groupReleaseEntries(entries) {
+		b.WriteString("## " + group[0].group + "\n\n")
+		for _, e := range group {
+			b.WriteString(fmt.Sprintf("#%d %s\n", e.number, e.title))
+			if summary := []rune(e.summary); len(summary) > maxSummary {
+				b.WriteString(string(summary[:maxSummary]) + "…\n")
+			} else if len(summary) > 0 {
+				b.WriteString(e.summary + "\n")
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func buildExplainPrompt(fileDiff diff.FileDiff, target command.Target, fileContext string) string {
+	var b strings.Builder
+	b.WriteString("A reviewer asked you to explain a change in a pull request. ")
+	b.WriteString(fmt.Sprintf("Explain what the changed code in %s does", target))
+	b.WriteString(" and why it might have been changed. Be concise, reference line numbers, ")
+	b.WriteString("and call out anything surprising. Do not produce review comments.\n\n")
+	b.WriteString("Changes to the file:\n")
+	for _, h := range fileDiff.Hunks {
+		if target.HasRange() && (h.NewStart > target.End || h.NewStart+h.NewLines-1 < target.Start) {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@ %s\n", h.OldStart, h.OldLines, h.NewStart, h.NewLines, h.Header))
+		for _, l := range h.Lines {
+			prefix := " "
+			switch l.Kind {
+			case diff.LineAdded:
+				prefix = "+"
+			case diff.LineRemoved:
+				prefix = "-"
+			}
+			b.WriteString(prefix + l.Content + "\n")
+		}
+	}
+	if fileContext != "" {
+		b.WriteString("\nCurrent file contents for context:\n")
+		b.WriteString(fileContext)
+	}
+	return b.String()
+}
+
+func buildSecondOpinionPrompt(target github.ReviewComment, thread []github.ReviewComment, fileContext string) string {
+	var b strings.Builder
+	b.WriteString("A reviewer left the comment below on a pull request, and the participants want a second opinion ")
+	b.WriteString("to settle it. Judge the comment on its merits against the code, not by who wrote it.\n\n")
+	b.WriteString(fmt.Sprintf("File: %s, line %d\n", target.Path, target.Line))
+	b.WriteString("Diff hunk the comment was left on, ending at its line:\n")
+	b.WriteString(target.DiffHunk + "\n\n")
+	b.WriteString(fmt.Sprintf("Comment by @%s:\n%s\n", target.User.Login, strings.TrimSpace(target.Body)))
+	if len(thread) > 1 {
+		b.WriteString("\nThe discussion so far:\n")
+		for _, c := range thread {
+			b.WriteString(fmt.Sprintf("@%s: %s\n", c.User.Login, strings.TrimSpace(c.Body)))
+		}
+	}
+	if fileContext != "" {
+		b.WriteString("\nCurrent file contents for context:\n")
+		b.WriteString(fileContext + "\n")
+	}
+	b.WriteString("\nOn the first line, answer with exactly AGREE, DISAGREE, or NUANCED: whether the comment is right, ")
+	b.WriteString("wrong, or right only in part or under conditions. Then explain why in a few sentences, ")
+	b.WriteString("referencing the code. Do not produce review comments.")
+	return b.String()
+}
+
+func buildDocCommentPrompt(file string, symbols []apidoc.Symbol) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("The exported Go declarations below, from %s, have no doc comments. ", file))
+	b.WriteString("Write a doc comment for each, following Go conventions: begin with the declared name, ")
+	b.WriteString("write full sentences, say what it does or represents rather than how, and keep it to one to three lines.\n\n")
+	b.WriteString("For each declaration, answer with a line \"### <name>\" followed by the comment lines, each starting with \"//\". ")
+	b.WriteString("Write nothing else.\n")
+	for _, s := range symbols {
+		b.WriteString(fmt.Sprintf("\n### %s\n%s\n", s.Name, s.Source))
+	}
+	return b.String()
+}
+
+func buildToneContext(tone string) string {
+	return "Tone of the review: " + tone
+}
+
+func buildAddressedPrompt(fileDiff diff.FileDiff, finding, fileContext string) string {
+	finding = strings.TrimSpace(strings.ReplaceAll(finding, github.FindingMarker, ""))
+
+	var b strings.Builder
+	b.WriteString("An earlier review of this pull request left the following finding on ")
+	b.WriteString(fileDiff.Path())
+	b.WriteString(", and the lines it refers to have since changed.\n\n")
+	b.WriteString("Finding:\n" + finding + "\n\n")
+	if fileContext != "" {
+		b.WriteString("Current file contents around the finding:\n")
+		b.WriteString(fileContext)
+		b.WriteString("\n")
+	}
+	b.WriteString("Does the current code address the finding? Answer with exactly ADDRESSED or NOT ADDRESSED.")
+	return b.String()
+}
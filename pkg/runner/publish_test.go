@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+// postRecorder is a github.Client recording the summary comments and
+// reviews it is asked to post.
+type postRecorder struct {
+	github.Client
+	posted []string
+}
+
+func (r *postRecorder) PullRequestHead(types.PullRequestEvent) (string, error) {
+	return "abc123", nil
+}
+
+func (r *postRecorder) PostPRComment(_ types.PullRequestEvent, comment string) error {
+	r.posted = append(r.posted, comment)
+	return nil
+}
+
+func (r *postRecorder) PostReview(_ types.PullRequestEvent, body string, _ []types.InlineComment) error {
+	r.posted = append(r.posted, body)
+	return nil
+}
+
+func (r *postRecorder) ListConversationComments(types.PullRequestEvent) ([]github.ConversationComment, error) {
+	return nil, nil
+}
+
+func TestPublishRunIDFooter(t *testing.T) {
+	tests := []struct {
+		name  string
+		quiet bool
+	}{
+		{"summary comment", false},
+		{"quiet review", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &postRecorder{}
+			o := New(Config{PostPRComment: true, Quiet: tt.quiet, BotLogin: "repo-ranger"}, nil, nil, client)
+			var event types.PullRequestEvent
+			event.PullRequest.Head.SHA = "abc123"
+
+			o.publishTo(context.Background(), event, nil, publication{review: "Looks good."})
+
+			if len(client.posted) != 1 {
+				t.Fatalf("posted %d comment(s), want 1", len(client.posted))
+			}
+			body := client.posted[0]
+			for _, marker := range []string{runIDMarker(o.runID), headMarker("abc123")} {
+				if !strings.Contains(body, marker) {
+					t.Errorf("posted %q, want the marker %q", body, marker)
+				}
+			}
+		})
+	}
+}
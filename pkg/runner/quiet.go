@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// What a review finished during quiet hours posts.
+const (
+	QuietChecks = "checks" // check runs only, no comments
+	QuietDefer  = "defer"  // nothing, leaving the review bundle to be published once the hours end
+)
+
+// QuietHours is a daily window in which reviews still run but do not post
+// comments, so teams elsewhere in the world are not notified at night.
+type QuietHours struct {
+	// start and end are minutes after midnight. A window ending before it
+	// starts runs past midnight.
+	start, end int
+	location   *time.Location
+}
+
+// ParseQuietHours reads a window such as "22:00-07:00" in the named IANA
+// time zone, UTC when zone is empty. It returns nil for an empty spec.
+func ParseQuietHours(spec, zone string) (*QuietHours, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, fmt.Errorf("%q is not a window like 22:00-07:00", spec)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, fmt.Errorf("%q starts and ends at the same time", spec)
+	}
+	location := time.UTC
+	if zone = strings.TrimSpace(zone); zone != "" {
+		if location, err = time.LoadLocation(zone); err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", zone)
+		}
+	}
+	return &QuietHours{start: start, end: end, location: location}, nil
+}
+
+// parseClock reads a time of day such as "07:00" or "7", returning minutes
+// after midnight.
+func parseClock(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	hours, minutes, _ := strings.Cut(s, ":")
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("%q is not a time of day like 07:00", s)
+	}
+	m := 0
+	if minutes != "" {
+		if m, err = strconv.Atoi(minutes); err != nil || m < 0 || m > 59 || len(minutes) != 2 {
+			return 0, fmt.Errorf("%q is not a time of day like 07:00", s)
+		}
+	}
+	if h*60+m > 24*60 {
+		return 0, fmt.Errorf("%q is not a time of day like 07:00", s)
+	}
+	return (h*60 + m) % (24 * 60), nil
+}
+
+// Until reports whether t falls in quiet hours and, if so, when they end.
+func (q *QuietHours) Until(t time.Time) (time.Time, bool) {
+	if q == nil {
+		return time.Time{}, false
+	}
+	local := t.In(q.location)
+	y, m, d := local.Date()
+	now := local.Hour()*60 + local.Minute()
+	// time.Date normalizes the minutes, and keeps the wall clock across
+	// daylight saving changes.
+	end := func(day int) time.Time { return time.Date(y, m, day, 0, q.end, 0, 0, q.location) }
+	switch {
+	case q.start < q.end && now >= q.start && now < q.end:
+		return end(d), true
+	case q.start > q.end && now >= q.start:
+		return end(d + 1), true
+	case q.start > q.end && now < q.end:
+		return end(d), true
+	}
+	return time.Time{}, false
+}
+
+// String renders the window as configured, such as "22:00-07:00 UTC".
+func (q *QuietHours) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d %s", q.start/60, q.start%60, q.end/60, q.end%60, q.location)
+}
+
+// publishQuietly stands in for publishing during quiet hours, until they
+// end. Check runs are created as usual when enabled. In QuietDefer mode the
+// review is held in its bundle, and the post_after output tells the server
+// when to publish it; a publication with no bundle to hold it, or any
+// publication in QuietChecks mode, is reported with check runs instead of
+// comments.
+func (o *Orchestrator) publishQuietly(prEvent types.PullRequestEvent, out publication, until time.Time) {
+	entry := log.WithFields(log.Fields{"quietHours": o.cfg.QuietHours.String(), "until": until.Format(time.RFC3339)})
+	if o.cfg.QuietHoursMode == QuietDefer && o.cfg.ReviewBundle != "" && out.report != nil {
+		entry.Info("Quiet hours; holding the review until they end")
+		o.setOutput("post_after", until.UTC().Format(time.RFC3339))
+		if o.cfg.UseChecks {
+			o.createCheckRuns(prEvent, out)
+		}
+		return
+	}
+	entry.Info("Quiet hours; reporting with check runs instead of comments")
+	if len(out.checkRuns) == 0 {
+		out.checkRuns = []github.CheckRun{{
+			Name:    "Repo Ranger",
+			Title:   "Code review",
+			Summary: fmt.Sprintf("_Posted as a check run during quiet hours (%s)._\n\n%s", o.cfg.QuietHours, out.review),
+		}}
+	}
+	o.createCheckRuns(prEvent, out)
+}
@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/postprocess"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// workflowRunEvent is the part of a workflow_run payload needed to find
+// the pull request an unprivileged run reviewed.
+type workflowRunEvent struct {
+	WorkflowRun struct {
+		Event          string `json:"event"`
+		HeadSHA        string `json:"head_sha"`
+		HeadBranch     string `json:"head_branch"`
+		HeadRepository struct {
+			Owner struct {
+				Login string `json:"login"`
+			} `json:"owner"`
+		} `json:"head_repository"`
+		PullRequests []struct {
+			Number int `json:"number"`
+			Head   struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		} `json:"pull_requests"`
+	} `json:"workflow_run"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// PublishBundle posts a review bundle written by an unprivileged run, such
+// as one on a forked pull request. Run from a workflow_run workflow, the
+// pull request and commit are taken from the triggering run rather than
+// the bundle, which the fork's code could have tampered with; the bundle
+// must agree with them.
+func (o *Orchestrator) PublishBundle(ctx context.Context, dir string) error {
+	summary, err := os.ReadFile(filepath.Join(dir, bundleSummary))
+	if err != nil {
+		return fmt.Errorf("failed to read review summary: %w", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, bundleFindings))
+	if err != nil {
+		return fmt.Errorf("failed to read findings: %w", err)
+	}
+	var doc postprocess.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse findings: %w", err)
+	}
+
+	prEvent, err := o.relayTarget(doc.PullRequest)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"repository": prEvent.Repository.FullName,
+		"number":     prEvent.PullRequest.Number,
+		"sha":        prEvent.PullRequest.Head.SHA,
+	}).Info("Publishing review bundle")
+
+	findings, reviewComments, fileComments := doc.Results()
+	postable, _, postableFiles, _ := splitWarnings(reviewComments, fileComments, o.cfg.CategoryModes)
+	var comments []types.InlineComment
+	if o.cfg.InlineComments {
+		comments, _ = capInlineComments(postable, o.cfg.MaxInlineComments, nil)
+	}
+	out := publication{
+		review:       strings.TrimSpace(string(summary)),
+		comments:     comments,
+		fileComments: postableFiles,
+		labels:       categoryLabels(countCategories(findings, reviewComments, fileComments), o.cfg.CategoryLabels),
+	}
+	// A bundle from workflow_run was written by the fork's own code, so
+	// nothing in it can vouch for a complete review and earn an approval.
+	gap := "the review bundle came from an untrusted workflow"
+	if o.cfg.EventName != "workflow_run" {
+		if data, err := os.ReadFile(filepath.Join(dir, bundleGap)); err == nil {
+			gap = strings.TrimSpace(string(data))
+		} else {
+			gap = ""
+		}
+	}
+	out.verdict, out.verdictSummary = reviewVerdict(findings, reviewComments, gap)
+	o.publishTo(ctx, prEvent, nil, out)
+	return nil
+}
+
+// relayTarget identifies the pull request to publish to. Under workflow_run
+// it comes from the triggering run, and the bundle's own claim must match.
+// Elsewhere the bundle is trusted, which is only safe for bundles from
+// trusted runs.
+func (o *Orchestrator) relayTarget(claimed postprocess.PullRequest) (types.PullRequestEvent, error) {
+	var prEvent types.PullRequestEvent
+	if o.cfg.EventName != "workflow_run" {
+		if claimed.Repository == "" || claimed.Number == 0 {
+			return prEvent, fmt.Errorf("bundle does not name a pull request")
+		}
+		log.Warn("Not running under workflow_run; publishing to the pull request named in the bundle")
+		prEvent.Repository.FullName = claimed.Repository
+		prEvent.PullRequest.Number = claimed.Number
+		prEvent.PullRequest.Head.SHA = claimed.HeadSHA
+		return prEvent, nil
+	}
+
+	data, err := os.ReadFile(o.cfg.EventPath)
+	if err != nil {
+		return prEvent, fmt.Errorf("failed to read workflow_run event: %w", err)
+	}
+	var event workflowRunEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return prEvent, fmt.Errorf("failed to parse workflow_run event: %w", err)
+	}
+	run := event.WorkflowRun
+	if run.Event != "pull_request" && run.Event != "pull_request_target" {
+		return prEvent, fmt.Errorf("triggering run was for a %q event, not a pull request", run.Event)
+	}
+	if claimed.HeadSHA != run.HeadSHA {
+		return prEvent, fmt.Errorf("bundle was produced for commit %s, but the triggering run was for %s", claimed.HeadSHA, run.HeadSHA)
+	}
+	if claimed.Repository != "" && claimed.Repository != event.Repository.FullName {
+		return prEvent, fmt.Errorf("bundle names repository %s, but the triggering run belongs to %s", claimed.Repository, event.Repository.FullName)
+	}
+
+	// Runs from forks list no pull requests, so look the pull request up
+	// by its head branch.
+	number := 0
+	for _, pr := range run.PullRequests {
+		if pr.Head.SHA == run.HeadSHA {
+			number = pr.Number
+			break
+		}
+	}
+	if number == 0 {
+		head := run.HeadRepository.Owner.Login + ":" + run.HeadBranch
+		number, err = o.github.FindPullRequest(event.Repository.FullName, head, run.HeadSHA)
+		if err != nil {
+			return prEvent, fmt.Errorf("failed to find the pull request for %s: %w", head, err)
+		}
+	}
+	if claimed.Number != 0 && claimed.Number != number {
+		return prEvent, fmt.Errorf("bundle names pull request #%d, but the triggering run was for #%d", claimed.Number, number)
+	}
+
+	prEvent.Repository.FullName = event.Repository.FullName
+	prEvent.PullRequest.Number = number
+	prEvent.PullRequest.Head.SHA = run.HeadSHA
+	return prEvent, nil
+}
@@ -0,0 +1,226 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// releaseGroups are the sections of release notes, in order, with the
+// labels and conventional-commit title types that put a pull request in
+// each. Pull requests matching none are other changes.
+var releaseGroups = []struct {
+	title  string
+	labels []string
+	types  []string
+}{
+	{"Breaking Changes", []string{"breaking", "breaking-change", "breaking change"}, nil},
+	{"Features", []string{"enhancement", "feature"}, []string{"feat"}},
+	{"Bug Fixes", []string{"bug", "bugfix", "fix"}, []string{"fix"}},
+	{"Performance", []string{"performance"}, []string{"perf"}},
+	{"Documentation", []string{"documentation", "docs"}, []string{"docs"}},
+}
+
+const otherChanges = "Other Changes"
+
+// releaseSkipLabels leave a pull request out of release notes.
+var releaseSkipLabels = []string{"skip-changelog", "no-release-notes"}
+
+// Merge commits and squash merges name their pull request on their first
+// line.
+var (
+	mergeCommitPattern = regexp.MustCompile(`^Merge pull request #(\d+)`)
+	squashMergePattern = regexp.MustCompile(`\(#(\d+)\)\s*$`)
+	// conventionalPattern matches titles such as "feat(api)!: add tokens".
+	conventionalPattern = regexp.MustCompile(`^(\w+)(\([^)]*\))?(!)?:`)
+)
+
+// releaseEntry is a merged pull request in release notes.
+type releaseEntry struct {
+	number  int
+	title   string
+	group   string
+	summary string
+}
+
+// ReleaseNotes writes user-facing release notes in Markdown for the pull
+// requests of repo merged between two git refs. Each pull request is
+// summarized from its diff like a summary review, and the summaries are
+// then rewritten for users under grouped headings.
+func (o *Orchestrator) ReleaseNotes(ctx context.Context, repo, from, to string) (string, error) {
+	messages, err := o.diff.Messages(ctx, from, to)
+	if err != nil {
+		return "", fmt.Errorf("failed to list commits: %w", err)
+	}
+	numbers := mergedPullRequests(messages)
+	if len(numbers) == 0 {
+		return "", fmt.Errorf("no pull requests were merged between %s and %s", from, to)
+	}
+	log.WithFields(log.Fields{"from": from, "to": to, "pullRequests": len(numbers)}).Info("Summarizing merged pull requests")
+
+	caps := api.CapabilitiesFor(o.cfg.Model, o.cfg.ModelCapabilities)
+	var entries []releaseEntry
+	for _, number := range numbers {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		entry := log.WithField("pr", number)
+		event, err := o.github.GetPullRequest(repo, number)
+		if err != nil {
+			entry.WithError(err).Warn("Failed to fetch merged pull request; leaving it out")
+			continue
+		}
+		if hasAnyLabel(event, releaseSkipLabels) {
+			continue
+		}
+		e := releaseEntry{number: number, title: event.PullRequest.Title, group: releaseGroup(event)}
+
+		diffText, err := o.github.PullRequestDiff(event)
+		if err != nil {
+			entry.WithError(err).Warn("Failed to fetch pull request diff; using its title")
+			entries = append(entries, e)
+			continue
+		}
+		if diffText = o.shareable(diffText); diffText == "" {
+			entries = append(entries, e)
+			continue
+		}
+		promptContext := []string{releaseContext(event)}
+		summary, err := o.reviewDiff(ctx, diffText, diff.Parse(diffText), promptContext, DepthSummary,
+			chunkBudget(caps, o.cfg.MaxTokens, promptContext, DepthSummary), nil)
+		if err != nil {
+			entry.WithError(err).Warn("Failed to summarize pull request; using its title")
+		}
+		e.summary = strings.TrimSpace(summary.Text)
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no merged pull requests between %s and %s could be read", from, to)
+	}
+
+	notes, err := o.api.Review(ctx, o.cfg.Model, buildReleaseNotesPrompt(entries))
+	if err != nil || strings.TrimSpace(notes) == "" {
+		log.WithError(err).Warn("Failed to write release notes; listing pull request titles instead")
+		return renderReleaseEntries(entries), nil
+	}
+	return strings.TrimSpace(notes) + "\n", nil
+}
+
+// mergedPullRequests returns the pull requests named by merge commit
+// messages, oldest first, without duplicates.
+func mergedPullRequests(messages []string) []int {
+	var numbers []int
+	seen := map[int]bool{}
+	for i := len(messages) - 1; i >= 0; i-- {
+		first, _, _ := strings.Cut(messages[i], "\n")
+		m := mergeCommitPattern.FindStringSubmatch(first)
+		if m == nil {
+			m = squashMergePattern.FindStringSubmatch(first)
+		}
+		if m == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(m[1]); err == nil && !seen[n] {
+			seen[n] = true
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+func hasAnyLabel(event types.PullRequestEvent, labels []string) bool {
+	for _, l := range event.PullRequest.Labels {
+		for _, want := range labels {
+			if strings.EqualFold(l.Name, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// releaseGroup picks the section of a pull request by its labels, then
+// its conventional-commit title.
+func releaseGroup(event types.PullRequestEvent) string {
+	m := conventionalPattern.FindStringSubmatch(event.PullRequest.Title)
+	if m != nil && m[3] == "!" {
+		return releaseGroups[0].title
+	}
+	for _, g := range releaseGroups {
+		if hasAnyLabel(event, g.labels) {
+			return g.title
+		}
+	}
+	if m != nil {
+		kind := strings.ToLower(m[1])
+		for _, g := range releaseGroups {
+			for _, t := range g.types {
+				if kind == t {
+					return g.title
+				}
+			}
+		}
+	}
+	return otherChanges
+}
+
+// releaseContext describes a pull request to the summary prompt.
+func releaseContext(event types.PullRequestEvent) string {
+	const maxBody = 2000
+	body := strings.TrimSpace(event.PullRequest.Body)
+	if r := []rune(body); len(r) > maxBody {
+		body = string(r[:maxBody]) + "…"
+	}
+	context := "Pull request title: " + event.PullRequest.Title
+	if body != "" {
+		context += "\n\nPull request description:\n" + body
+	}
+	return context
+}
+
+// groupReleaseEntries orders entries by section, then by pull request.
+func groupReleaseEntries(entries []releaseEntry) [][]releaseEntry {
+	order := map[string]int{otherChanges: len(releaseGroups)}
+	for i, g := range releaseGroups {
+		order[g.title] = i
+	}
+	sorted := append([]releaseEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if order[sorted[i].group] != order[sorted[j].group] {
+			return order[sorted[i].group] < order[sorted[j].group]
+		}
+		return sorted[i].number < sorted[j].number
+	})
+	var groups [][]releaseEntry
+	for i, e := range sorted {
+		if i == 0 || e.group != sorted[i-1].group {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], e)
+	}
+	return groups
+}
+
+// renderReleaseEntries lists the pull requests by title under their
+// sections, for when the model cannot write the notes.
+func renderReleaseEntries(entries []releaseEntry) string {
+	var b strings.Builder
+	for i, group := range groupReleaseEntries(entries) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("## " + group[0].group + "\n\n")
+		for _, e := range group {
+			b.WriteString(fmt.Sprintf("- %s (#%d)\n", e.title, e.number))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	log "github.com/sirupsen/logrus"
+)
+
+// splitMisdetectedRenames rewrites renames below minSimilarity as a deletion
+// plus an addition, since git paired two mostly unrelated files and the
+// edit hunks would be misleading. The renamed file is read from the
+// checkout with readWorkingFile, so a rename to a symbolic link cannot put
+// its target in the prompt. Renames that cannot be rewritten are left as
+// they are, and so are all renames in server mode, which has no checkout.
+func (o *Orchestrator) splitMisdetectedRenames(ctx context.Context, diffText string, minSimilarity int) string {
+	root := o.checkout()
+	if root == "" {
+		return diffText
+	}
+	return diff.Rewrite(diffText, func(f diff.FileDiff, section string) string {
+		if !f.IsRenamed || f.Similarity >= minSimilarity {
+			return section
+		}
+		before, err := o.diff.FileAt(ctx, o.cfg.BaseRef, f.OldPath)
+		if err != nil {
+			log.WithError(err).WithField("file", f.OldPath).Warn("Failed to load renamed file; reviewing it as a rename")
+			return section
+		}
+		after, err := readWorkingFile(root, f.NewPath)
+		if err != nil {
+			log.WithError(err).WithField("file", f.NewPath).Warn("Failed to read renamed file; reviewing it as a rename")
+			return section
+		}
+		log.WithFields(log.Fields{
+			"from":       f.OldPath,
+			"to":         f.NewPath,
+			"similarity": f.Similarity,
+		}).Info("Reviewing low-similarity rename as a deletion and an addition")
+		return diff.DeleteAndAdd(f, before, string(after))
+	})
+}
+
+// buildRenameContext tells the model which files were renamed, so it
+// reviews the edits rather than the move.
+func buildRenameContext(files []diff.FileDiff) string {
+	var renames []string
+	for _, f := range files {
+		if f.IsRenamed {
+			renames = append(renames, fmt.Sprintf("- %s -> %s (%d%% similar)", f.OldPath, f.NewPath, f.Similarity))
+		}
+	}
+	if len(renames) == 0 {
+		return ""
+	}
+	return "These files were renamed; only the lines edited alongside the rename are shown. " +
+		"Review those edits and do not comment on the rename itself or on code that was only moved:\n" +
+		strings.Join(renames, "\n")
+}
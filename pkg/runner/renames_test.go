@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+)
+
+// baseRunner is a diff.Runner serving files at the base ref from a map.
+type baseRunner struct {
+	diff.Runner
+	files map[string]string
+}
+
+func (r baseRunner) FileAt(ctx context.Context, ref, path string) (string, error) {
+	content, ok := r.files[path]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return content, nil
+}
+
+func TestSplitMisdetectedRenames(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("TOKEN=hunter2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	inCheckout(t, map[string]string{"new.go": "package fresh\n"}, map[string]string{"link.go": secret})
+
+	rename := func(from, to string) string {
+		return "diff --git a/" + from + " b/" + to + "\nsimilarity index 10%\nrename from " + from + "\nrename to " + to
+	}
+	base := baseRunner{files: map[string]string{"old.go": "package stale\n", "gone.go": "package gone\n"}}
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		diff   string
+		want   []string
+		absent []string
+	}{
+		{"rewritten", Config{}, rename("old.go", "new.go"), []string{"-package stale", "+package fresh"}, nil},
+		{"symbolic link", Config{}, rename("gone.go", "link.go"), []string{"rename to link.go"}, []string{"hunter2"}},
+		{"server mode", Config{DiffFromPullRequest: true}, rename("old.go", "new.go"), []string{"rename to new.go"}, []string{"+package fresh"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := New(tt.cfg, base, nil, nil)
+			got := o.splitMisdetectedRenames(context.Background(), tt.diff, 50)
+			for _, s := range tt.want {
+				if !strings.Contains(got, s) {
+					t.Errorf("rewritten diff misses %q:\n%s", s, got)
+				}
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(got, s) {
+					t.Errorf("rewritten diff has %q:\n%s", s, got)
+				}
+			}
+		})
+	}
+}
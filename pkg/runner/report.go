@@ -0,0 +1,461 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/complexity"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/owners"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// reviewReport collects everything rendered into the PR comment.
+type reviewReport struct {
+	Chunks       int
+	FailedChunks int
+	// DeclinedChunks were filtered by the provider or refused by the model.
+	DeclinedChunks int
+	// Triage, when set, is how the triage model routed the chunks.
+	Triage    *triageReport
+	Review    string
+	Findings  []types.Finding
+	Metrics   []summaryMetric
+	Functions []complexity.Function
+	// Comments are the findings posted inline and Overflow those listed
+	// only in the summary. Links holds the URLs of the posted comments,
+	// keyed by finding ID, once they are known.
+	Comments []types.InlineComment
+	Overflow []types.InlineComment
+	Links    map[string]string
+	// Degraded reports that a full review, estimated at Estimate, would
+	// have exceeded CostCap, so only a summary was requested.
+	Degraded bool
+	Estimate float64
+	CostCap  float64
+	// Summarized are the files TokenBudget left only summarized.
+	Summarized  []string
+	TokenBudget int
+	// Confidential are the files the egress policy kept from every model.
+	Confidential []string
+	// Categories count the model's findings by category.
+	Categories map[types.Category]int
+	// Warnings and FileWarnings are findings in warn-only categories.
+	Warnings     []types.InlineComment
+	FileWarnings []types.FileComment
+	// Severities count every finding of the review by severity.
+	Severities map[types.Severity]int
+	// Codeowners, when the repository has a CODEOWNERS file, tags the
+	// listed findings with their owners, and Ownership counts every
+	// finding by owner. CCOwners mentions the owners of critical findings.
+	Codeowners *owners.Owners
+	Ownership  []ownership
+	CCOwners   bool
+}
+
+// countSeverities counts the deterministic findings and the model's
+// comments and file comments by severity.
+func countSeverities(findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) map[types.Severity]int {
+	counts := map[types.Severity]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	for _, c := range comments {
+		counts[c.Severity]++
+	}
+	for _, c := range fileComments {
+		counts[c.Severity]++
+	}
+	return counts
+}
+
+// severityLabel renders a severity as its badge and name, the way every
+// finding in the PR comment is introduced.
+func severityLabel(s types.Severity) string {
+	if s == "" {
+		return "**UNRATED**"
+	}
+	return s.Badge() + " **" + strings.ToUpper(string(s)) + "**"
+}
+
+// sectionAnchor returns the anchor of a section of the PR comment, which
+// stays the same whatever else the comment holds.
+func sectionAnchor(title string) string {
+	return "repo-ranger-" + strings.ReplaceAll(strings.ToLower(title), " ", "-")
+}
+
+// writeSection starts a section of the PR comment with an anchored
+// heading.
+func writeSection(b *strings.Builder, title string) {
+	b.WriteString(fmt.Sprintf("\n\n### <a id=\"%s\"></a>%s\n\n", sectionAnchor(title), title))
+}
+
+// Sections of the PR comment that list findings.
+const (
+	sectionAdditionalFindings = "Additional Findings"
+	sectionWarnings           = "Warnings"
+	sectionAutomatedChecks    = "Automated Checks"
+)
+
+// writeSeverityTable writes the number of findings of each severity, and
+// links to the sections listing findings, at the top of the PR comment.
+func writeSeverityTable(b *strings.Builder, report reviewReport) {
+	total := 0
+	for _, n := range report.Severities {
+		total += n
+	}
+	if total == 0 {
+		return
+	}
+	b.WriteString("| Severity | Findings |\n|----------|----------|\n")
+	for _, s := range []types.Severity{types.SeverityCritical, types.SeverityMajor, types.SeverityMinor, types.SeverityNit, ""} {
+		if n := report.Severities[s]; n > 0 {
+			b.WriteString(fmt.Sprintf("| %s | %d |\n", severityLabel(s), n))
+		}
+	}
+	var links []string
+	for _, section := range []struct {
+		title string
+		shown bool
+	}{
+		{sectionFindingIndex, len(report.Comments) > 0 || len(report.Overflow) > 0 || len(report.Warnings) > 0},
+		{sectionAdditionalFindings, len(report.Overflow) > 0},
+		{sectionWarnings, len(report.Warnings) > 0 || len(report.FileWarnings) > 0},
+		{sectionAutomatedChecks, len(report.Findings) > 0},
+	} {
+		if section.shown {
+			links = append(links, fmt.Sprintf("[%s](#%s)", section.title, sectionAnchor(section.title)))
+		}
+	}
+	if len(links) > 0 {
+		b.WriteString("\nJump to: " + strings.Join(links, " · ") + "\n")
+	}
+	b.WriteString("\n")
+}
+
+// countCategories counts the categorized findings among the deterministic
+// findings, comments, and file comments.
+func countCategories(findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) map[types.Category]int {
+	counts := map[types.Category]int{}
+	for _, f := range findings {
+		if f.Category != "" {
+			counts[f.Category]++
+		}
+	}
+	for _, c := range comments {
+		if c.Category != "" {
+			counts[c.Category]++
+		}
+	}
+	for _, c := range fileComments {
+		if c.Category != "" {
+			counts[c.Category]++
+		}
+	}
+	return counts
+}
+
+// categoryLabels returns the pull request labels mapped to the categories
+// of the findings, in category order.
+func categoryLabels(counts map[types.Category]int, labels map[types.Category]string) []string {
+	var out []string
+	for _, c := range types.Categories {
+		if label := labels[c]; label != "" && counts[c] > 0 {
+			out = append(out, label)
+		}
+	}
+	return out
+}
+
+// summaryMetric is a single row in the summary table of the PR comment.
+type summaryMetric struct {
+	Name  string
+	Value string
+}
+
+// formatReviewForPR combines the model's review with the deterministic
+// findings and summary tables, as the default comment template lays them
+// out.
+func formatReviewForPR(report reviewReport) string {
+	return renderComment(nil, commentData{reviewReport: report})
+}
+
+// writeNotices writes the notes on what the review left out or reduced,
+// which head the PR comment.
+func writeNotices(b *strings.Builder, report reviewReport) {
+	if report.Degraded {
+		b.WriteString(fmt.Sprintf("> **Summary only:** a full review was estimated to cost $%.2f, more than the $%.2f cap on a single run, ", report.Estimate, report.CostCap))
+		b.WriteString("so only a summary was requested.\n\n")
+	}
+	if len(report.Summarized) > 0 {
+		const listed = 10
+		names := make([]string, 0, listed)
+		for i, f := range report.Summarized {
+			if i == listed {
+				break
+			}
+			names = append(names, "`"+f+"`")
+		}
+		list := strings.Join(names, ", ")
+		if more := len(report.Summarized) - len(names); more > 0 {
+			list += fmt.Sprintf(", and %d more", more)
+		}
+		b.WriteString(fmt.Sprintf("> **Summarized:** to stay within the token budget of %d, these files were only summarized: %s.\n\n", report.TokenBudget, list))
+	}
+	if len(report.Confidential) > 0 {
+		b.WriteString(fmt.Sprintf("> **Not reviewed for confidentiality:** the egress policy keeps these files from the review models: %s. ", fileList(report.Confidential)))
+		b.WriteString("Please review them manually.\n\n")
+	}
+	if report.FailedChunks > 0 {
+		b.WriteString(fmt.Sprintf("> **Partially reviewed:** %d of %d chunks of this diff could not be reviewed. ", report.FailedChunks, report.Chunks))
+		b.WriteString("Re-run the workflow to review only the remaining chunks.\n\n")
+	}
+	if report.DeclinedChunks > 0 {
+		b.WriteString(fmt.Sprintf("> **Not reviewed:** %d of %d chunks of this diff could not be reviewed because the provider declined them, ", report.DeclinedChunks, report.Chunks))
+		b.WriteString("even with string literals redacted. Please review those changes manually.\n\n")
+	}
+	writeTriageNote(b, report.Triage)
+}
+
+// writeMetricsTable writes the summary metrics of the deterministic checks.
+func writeMetricsTable(b *strings.Builder, report reviewReport) {
+	if len(report.Metrics) == 0 {
+		return
+	}
+	b.WriteString("| Metric | Value |\n|--------|-------|\n")
+	for _, m := range report.Metrics {
+		b.WriteString(fmt.Sprintf("| %s | %s |\n", m.Name, m.Value))
+	}
+	b.WriteString("\n")
+}
+
+// writeCategoryTable writes the number of findings in each category.
+func writeCategoryTable(b *strings.Builder, report reviewReport) {
+	if len(report.Categories) == 0 {
+		return
+	}
+	b.WriteString("\n\n### Findings by Category\n\n")
+	b.WriteString("| Category | Findings |\n|----------|----------|\n")
+	for _, c := range types.Categories {
+		if n := report.Categories[c]; n > 0 {
+			b.WriteString(fmt.Sprintf("| %s | %d |\n", c, n))
+		}
+	}
+}
+
+// writeFunctionTable writes the size and complexity of the changed
+// functions, before and after for those that already existed.
+func writeFunctionTable(b *strings.Builder, report reviewReport) {
+	if len(report.Functions) == 0 {
+		return
+	}
+	b.WriteString("\n\n### Function Metrics\n\n")
+	b.WriteString("| Function | Lines | Complexity |\n|----------|-------|------------|\n")
+	for _, f := range report.Functions {
+		lines, cc := strconv.Itoa(f.Lines), strconv.Itoa(f.Complexity)
+		if !f.IsNew {
+			lines = fmt.Sprintf("%d → %d", f.PrevLines, f.Lines)
+			cc = fmt.Sprintf("%d → %d", f.PrevComplexity, f.Complexity)
+		}
+		b.WriteString(fmt.Sprintf("| `%s` (%s:%d) | %s | %s |\n", f.Name, f.File, f.Line, lines, cc))
+	}
+}
+
+// writeOverflowList lists the findings the inline comment cap kept from
+// being posted inline.
+func writeOverflowList(b *strings.Builder, report reviewReport) {
+	if len(report.Overflow) == 0 {
+		return
+	}
+	writeSection(b, sectionAdditionalFindings)
+	b.WriteString("These lower-severity findings were not posted inline to keep notifications manageable.\n\n")
+	for _, c := range report.Overflow {
+		line := fmt.Sprintf("- %s%s `%s` %s", idTag(c.ID), severityLabel(c.Severity), commentLocation(c), c.Reasoning)
+		if c.Confidence != nil {
+			line += fmt.Sprintf(" (%s confidence)", c.Confidence)
+		}
+		b.WriteString(line + ownerTag(report.Codeowners, c.File) + "\n")
+		writeSuggestion(b, c.Suggestion)
+	}
+}
+
+// writeWarningList lists the findings in warn-only categories.
+func writeWarningList(b *strings.Builder, report reviewReport) {
+	if len(report.Warnings) == 0 && len(report.FileWarnings) == 0 {
+		return
+	}
+	writeSection(b, sectionWarnings)
+	b.WriteString("These findings are in categories configured as warn-only, so they are not posted as comments and do not block the pull request.\n\n")
+	for _, c := range report.Warnings {
+		b.WriteString(fmt.Sprintf("- %s**%s** `%s` %s%s\n", idTag(c.ID), c.Category, commentLocation(c), c.Reasoning, ownerTag(report.Codeowners, c.File)))
+	}
+	for _, c := range report.FileWarnings {
+		b.WriteString(fmt.Sprintf("- **%s** `%s` %s%s\n", c.Category, c.File, c.Summary, ownerTag(report.Codeowners, c.File)))
+	}
+}
+
+// writeFindingList lists the findings of the deterministic checks.
+func writeFindingList(b *strings.Builder, report reviewReport) {
+	if len(report.Findings) == 0 {
+		return
+	}
+	writeSection(b, sectionAutomatedChecks)
+	for _, f := range report.Findings {
+		location := f.File
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		b.WriteString(fmt.Sprintf("- %s `%s` %s%s\n", severityLabel(f.Severity), location, f.Message, ownerTag(report.Codeowners, f.File)))
+	}
+}
+
+// directoryCheckRuns builds one check run per directory touched by the diff,
+// grouping paths by their first depth segments. Each run fails when its
+// directory has a critical finding, so branch protection can require the
+// runs of the directories a team owns.
+func directoryCheckRuns(files []diff.FileDiff, findings []types.Finding, comments []types.InlineComment, depth int) []github.CheckRun {
+	type directory struct {
+		findings []types.Finding
+		files    int
+	}
+	dirs := map[string]*directory{}
+	lookup := func(path string) *directory {
+		name := checkDirectory(path, depth)
+		d, ok := dirs[name]
+		if !ok {
+			d = &directory{}
+			dirs[name] = d
+		}
+		return d
+	}
+
+	for _, f := range files {
+		lookup(f.Path()).files++
+	}
+	for _, f := range findings {
+		if f.File != "" {
+			lookup(f.File).findings = append(lookup(f.File).findings, f)
+		}
+	}
+	for _, c := range comments {
+		if c.File == "" {
+			continue
+		}
+		d := lookup(c.File)
+		d.findings = append(d.findings, types.Finding{
+			File:     c.File,
+			Line:     c.Line,
+			Severity: c.Severity,
+			Source:   "review",
+			Message:  c.Reasoning,
+		})
+	}
+
+	names := make([]string, 0, len(dirs))
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	runs := make([]github.CheckRun, 0, len(names))
+	for _, name := range names {
+		d := dirs[name]
+		run := github.CheckRun{
+			Name:       "Repo Ranger: " + name,
+			Conclusion: "success",
+			Title:      fmt.Sprintf("%d files reviewed, no findings", d.files),
+			Summary:    "No findings in this directory.",
+		}
+		if len(d.findings) > 0 {
+			run.Conclusion = "neutral"
+			run.Title = fmt.Sprintf("%d findings", len(d.findings))
+			var b strings.Builder
+			for _, f := range d.findings {
+				if f.Severity == types.SeverityCritical {
+					run.Conclusion = "failure"
+				}
+				location := f.File
+				if f.Line > 0 {
+					location = fmt.Sprintf("%s:%d", f.File, f.Line)
+				}
+				b.WriteString(fmt.Sprintf("- %s `%s` %s\n", severityLabel(f.Severity), location, f.Message))
+			}
+			run.Summary = b.String()
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// checkDirectory returns the first depth directories of path, or "/" for
+// files at the repository root.
+func checkDirectory(path string, depth int) string {
+	if depth < 1 {
+		depth = 1
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) == 1 {
+		return "/"
+	}
+	parts = parts[:len(parts)-1]
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return strings.Join(parts, "/")
+}
+
+// headMarker returns a hidden marker recording the commit a comment reviews.
+func headMarker(sha string) string {
+	return summaryMarker + sha + " -->"
+}
+
+// runIDMarker returns a hidden marker recording the run that wrote a
+// comment, so a reported comment can be traced to the run's logs and
+// transcripts.
+func runIDMarker(id string) string {
+	return runMarker + id + " -->"
+}
+
+// setOutput writes a step output for the GitHub Action when running in Actions.
+func (o *Orchestrator) setOutput(name, value string) {
+	outputPath := o.cfg.OutputPath
+	if outputPath == "" {
+		return
+	}
+
+	f, err := os.OpenFile(outputPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		log.WithError(err).WithField("output", name).Warn("Failed to open GITHUB_OUTPUT")
+		return
+	}
+	defer f.Close()
+
+	delimiter := fmt.Sprintf("ghadelimiter_%d", time.Now().UnixNano())
+	if _, err := fmt.Fprintf(f, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter); err != nil {
+		log.WithError(err).WithField("output", name).Warn("Failed to write output")
+	}
+}
+
+// writeSuggestion writes a finding's suggested code as a fenced block
+// indented under its list entry, keeping the code's own lines.
+func writeSuggestion(b *strings.Builder, suggestion string) {
+	code := strings.Trim(suggestion, "\n")
+	if strings.TrimSpace(code) == "" {
+		return
+	}
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	b.WriteString("\n  " + fence + "\n")
+	for _, l := range strings.Split(code, "\n") {
+		b.WriteString("  " + l + "\n")
+	}
+	b.WriteString("  " + fence + "\n\n")
+}
@@ -0,0 +1,474 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/checkpoint"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// chunkBudget returns the largest diff chunk that fits in the model's
+// context window alongside the completion and the rest of the prompt.
+func chunkBudget(caps api.ModelCapabilities, maxTokens int, promptContext []string, depth string) int {
+	budget := api.DiffBudget(caps, maxTokens, buildDetailedPrompt("", promptContext))
+	if depth == DepthDeep {
+		// Deep reviews add file excerpts and a reflection pass that repeats the diff.
+		budget /= 2
+	}
+	log.WithField("maxChunkSize", budget).Debug("Computed diff budget")
+	return budget
+}
+
+// splitSize is the size a diff is split at so each chunk still fits
+// maxChunkSize once overlap lines from the chunk before are added.
+func splitSize(maxChunkSize, overlap int) int {
+	if overlap <= 0 {
+		return maxChunkSize
+	}
+	return maxChunkSize - maxChunkSize/5
+}
+
+// diffReview is the outcome of reviewing a diff that may span several chunks.
+type diffReview struct {
+	Text     string
+	Chunks   int
+	Failed   int // chunks that could not be reviewed
+	Declined int // chunks the provider filtered or the model refused
+	// Triaged chunks were rated by the triage model, and Cleared of them
+	// were rated low risk and not reviewed, avoiding an estimated Avoided
+	// US dollars of review.
+	Triaged int
+	Cleared int
+	Avoided float64
+	// Summaries counts the chunks only summarized, at summary depth.
+	Summaries int
+}
+
+// add counts another review's chunks in r.
+func (r *diffReview) add(other diffReview) {
+	r.Chunks += other.Chunks
+	r.Failed += other.Failed
+	r.Declined += other.Declined
+	r.Triaged += other.Triaged
+	r.Cleared += other.Cleared
+	r.Avoided += other.Avoided
+	r.Summaries += other.Summaries
+}
+
+// isDeclined reports whether err means the provider or model declined to
+// review, which re-running will not change.
+func isDeclined(err error) bool {
+	return errors.Is(err, api.ErrContentFiltered) || errors.Is(err, api.ErrRefused)
+}
+
+// reviewDiff reviews a diff at the given depth, splitting it into chunks
+// when it does not fit in a single request. A failed chunk does not abort
+// the review as long as another chunk succeeds; successful chunks are saved
+// to store, when non-nil, so a re-run only reviews the failed ones. With a
+// triage model set, line-by-line reviews skip the chunks it rates low risk.
+func (o *Orchestrator) reviewDiff(ctx context.Context, diffText string, files []diff.FileDiff, promptContext []string, depth string, maxChunkSize int, store *checkpoint.Store) (diffReview, error) {
+	defer endChunk()
+	switch {
+	case depth == DepthSummary:
+		beginChunk()
+		log.WithField("diffSize", len(diffText)).Info("Summary review depth; skipping line-by-line review")
+		review, err := o.api.Review(ctx, o.cfg.Model, buildSummaryPrompt(files, diffText, promptContext, maxChunkSize))
+		if isDeclined(err) {
+			log.WithError(err).Warn("Diff could not be reviewed")
+			return diffReview{Chunks: 1, Declined: 1}, nil
+		}
+		return diffReview{Text: review, Chunks: 1, Summaries: 1}, err
+	case len(diffText) <= maxChunkSize:
+		id := beginChunk()
+		log.WithField("diffSize", len(diffText)).Debug("Diff size is within limits")
+		result := diffReview{Chunks: 1}
+		if !o.routeChunk(ctx, diffText, promptContext, depth, maxChunkSize, &result) {
+			return result, nil
+		}
+		review, err := o.reviewChunkWithShrink(ctx, diffText, promptContext, depth)
+		if isDeclined(err) {
+			log.WithError(err).Warn("Diff could not be reviewed")
+			result.Declined++
+			return result, nil
+		}
+		result.Text = tagChunk(id, review)
+		return result, err
+	}
+
+	log.WithField("diffSize", len(diffText)).Info("Large diff detected; performing multi-step review")
+
+	chunks := o.diff.SplitIntoChunks(diffText, splitSize(maxChunkSize, o.cfg.ChunkOverlap))
+	chunks = diff.Overlap(chunks, o.cfg.ChunkOverlap, maxChunkSize)
+	result := diffReview{Chunks: len(chunks)}
+	// Every chunk is sent with the same instructions and context, so mark
+	// them for the provider to cache.
+	promptContext = append(append([]string{}, promptContext...), api.CacheBreakpoint)
+	var reviews []string
+	var lastErr error
+	for i, chunk := range chunks {
+		id := beginChunk()
+		key := depth + "\x00" + chunk
+		if store != nil {
+			if review, ok := store.Get(key); ok {
+				log.WithField("chunk", i+1).Info("Reusing review of chunk from checkpoint")
+				reviews = append(reviews, review)
+				continue
+			}
+		}
+		if !o.routeChunk(ctx, chunk, promptContext, depth, maxChunkSize, &result) {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"chunk": i + 1,
+			"total": len(chunks),
+			"size":  len(chunk),
+		}).Info("Reviewing chunk")
+
+		review, err := o.reviewChunkWithShrink(ctx, chunk, promptContext, depth)
+		if errors.Is(err, api.ErrAuth) {
+			// Every other chunk would fail the same way.
+			return result, err
+		}
+		if isDeclined(err) {
+			log.WithError(err).WithField("chunk", i+1).Warn("Chunk could not be reviewed")
+			result.Declined++
+			continue
+		}
+		if err != nil {
+			log.WithError(err).WithField("chunk", i+1).Error("Failed to review chunk")
+			lastErr = err
+			result.Failed++
+			continue
+		}
+		// The checkpoint keeps the tag, so a reused review still names the
+		// run that produced it.
+		review = tagChunk(id, review)
+		reviews = append(reviews, review)
+		if store != nil {
+			if err := store.Put(key, review); err != nil {
+				log.WithError(err).Warn("Failed to save checkpoint")
+			}
+		}
+	}
+
+	if len(reviews) == 0 && result.Failed > 0 {
+		return result, fmt.Errorf("failed to review all %d chunks: %w", len(chunks), lastErr)
+	}
+	result.Text = strings.Join(reviews, "\n\n")
+	return result, nil
+}
+
+// maxShrinkDepth caps how often a chunk overflowing the model's context
+// window is halved, so it is reviewed in at most 16 pieces.
+const maxShrinkDepth = 4
+
+// reviewChunkWithShrink reviews a chunk and, while it still overflows the
+// model's context window, splits it in half and reviews the halves, up to
+// maxShrinkDepth times.
+func (o *Orchestrator) reviewChunkWithShrink(ctx context.Context, chunk string, promptContext []string, depth string) (string, error) {
+	return o.reviewShrinking(ctx, chunk, promptContext, depth, 0)
+}
+
+func (o *Orchestrator) reviewShrinking(ctx context.Context, chunk string, promptContext []string, depth string, level int) (string, error) {
+	review, err := o.reviewChunk(ctx, chunk, promptContext, depth)
+	if !errors.Is(err, api.ErrContextLengthExceeded) {
+		return review, err
+	}
+	entry := log.WithFields(log.Fields{"size": len(chunk), "splits": level})
+	if level >= maxShrinkDepth {
+		entry.Error("Chunk still exceeds the model's context window after splitting; giving up on it")
+		return "", err
+	}
+
+	// One line of overlap heads each later piece with its file and hunk
+	// headers, so no piece loses track of the file it changes.
+	pieces := diff.Overlap(o.diff.SplitIntoChunks(chunk, len(chunk)/2), 1, len(chunk))
+	if len(pieces) < 2 {
+		entry.Error("Chunk exceeds the model's context window and cannot be split further")
+		return "", err
+	}
+	entry.WithField("pieces", len(pieces)).Warn("Chunk exceeded the model's context window; retrying in smaller pieces")
+
+	var reviews []string
+	for _, piece := range pieces {
+		review, err := o.reviewShrinking(ctx, piece, promptContext, depth, level+1)
+		if err != nil {
+			return "", err
+		}
+		reviews = append(reviews, review)
+	}
+	return strings.Join(reviews, "\n\n"), nil
+}
+
+// reviewChunk runs the line-by-line review of a single diff chunk. Deep
+// reviews add surrounding file context, when there is a checkout to read it
+// from, and a reflection pass.
+func (o *Orchestrator) reviewChunk(ctx context.Context, chunk string, promptContext []string, depth string) (string, error) {
+	if depth != DepthDeep {
+		return o.api.Review(ctx, o.cfg.Model, buildDetailedPrompt(chunk, promptContext))
+	}
+
+	expanded := promptContext
+	// Server mode has no checkout: its working directory is the server's
+	// own.
+	if !o.cfg.DiffFromPullRequest {
+		if excerpts := buildFileExcerpts(o.checkout(), diff.Parse(chunk)); excerpts != "" {
+			expanded = append(append([]string{}, promptContext...), excerpts)
+		}
+	}
+	draft, err := o.api.Review(ctx, o.cfg.Model, buildDetailedPrompt(chunk, expanded))
+	if err != nil {
+		return "", err
+	}
+
+	log.Debug("Running reflection pass")
+	return o.api.Review(ctx, o.cfg.Model, buildReflectionPrompt(chunk, draft))
+}
+
+// buildFileExcerpts returns the current contents surrounding each changed
+// hunk, read from the checkout at root, so the model can see code the
+// diff does not show.
+func buildFileExcerpts(root string, files []diff.FileDiff) string {
+	const surrounding = 30
+	var b strings.Builder
+	for _, f := range files {
+		if f.IsDeleted || f.IsBinary {
+			continue
+		}
+		data, err := readWorkingFile(root, f.Path())
+		if err != nil {
+			continue
+		}
+		lines := strings.Split(string(data), "\n")
+		for _, h := range f.Hunks {
+			start, end := h.NewStart-surrounding, h.NewStart+h.NewLines+surrounding
+			if start < 1 {
+				start = 1
+			}
+			if end > len(lines) {
+				end = len(lines)
+			}
+			b.WriteString(fmt.Sprintf("%s (lines %d-%d):\n", f.Path(), start, end))
+			for i := start; i <= end; i++ {
+				b.WriteString(fmt.Sprintf("%d: %s\n", i, lines[i-1]))
+			}
+			b.WriteString("\n")
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "Surrounding code from the updated files, for context:\n\n" + strings.TrimSpace(b.String())
+}
+
+// readWorkingFile reads a file of the checkout at root by a path taken
+// from a pull request or comment. Paths leaving the checkout or passing
+// through a symbolic link are refused, so a pull request adding a link to
+// /proc/self/environ or a runner secret cannot have it read into a prompt.
+func readWorkingFile(root, path string) ([]byte, error) {
+	if !filepath.IsLocal(path) {
+		return nil, fmt.Errorf("%s is outside the checkout", path)
+	}
+	base, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	full := filepath.Join(root, path)
+	resolved, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		return nil, err
+	}
+	if resolved != filepath.Join(base, path) {
+		return nil, fmt.Errorf("%s is a symbolic link", path)
+	}
+	return os.ReadFile(full)
+}
+
+// checkout returns the directory of the repository's working tree, or ""
+// in server mode, which has none: its working directory is the server's
+// own.
+func (o *Orchestrator) checkout() string {
+	if o.cfg.DiffFromPullRequest {
+		return ""
+	}
+	if o.cfg.Checkout == "" {
+		return "."
+	}
+	return o.cfg.Checkout
+}
+
+// filterBySeverity drops comments rated below min. Unrated comments are kept.
+func filterBySeverity(comments []types.InlineComment, min types.Severity) []types.InlineComment {
+	if min.Rank() == 0 {
+		return comments
+	}
+	var kept []types.InlineComment
+	for _, c := range comments {
+		if c.Severity.Rank() == 0 || c.Severity.Rank() >= min.Rank() {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// dedupeInlineComments collapses comments on the same line, such as those
+// made twice when overlapping chunks both show the line, keeping the most
+// severe.
+func dedupeInlineComments(comments []types.InlineComment) []types.InlineComment {
+	type location struct {
+		file string
+		side string
+		line int
+	}
+	index := map[location]int{}
+	var kept []types.InlineComment
+	for _, c := range comments {
+		key := location{c.File, c.Side, c.Line}
+		i, seen := index[key]
+		switch {
+		case !seen:
+			index[key] = len(kept)
+			kept = append(kept, c)
+		case c.Severity.Rank() > kept[i].Severity.Rank():
+			kept[i] = c
+		}
+	}
+	if dropped := len(comments) - len(kept); dropped > 0 {
+		log.WithField("duplicates", dropped).Debug("Collapsed duplicate inline comments")
+	}
+	return kept
+}
+
+// dedupeFileComments drops file comments repeating an earlier remark about
+// the same file, keeping the most severe rating.
+func dedupeFileComments(comments []types.FileComment) []types.FileComment {
+	index := map[string]int{}
+	var kept []types.FileComment
+	for _, c := range comments {
+		key := c.File + "\x00" + strings.ToLower(strings.Join(strings.Fields(c.Summary), " "))
+		i, seen := index[key]
+		switch {
+		case !seen:
+			index[key] = len(kept)
+			kept = append(kept, c)
+		case c.Severity.Rank() > kept[i].Severity.Rank():
+			kept[i].Severity = c.Severity
+		}
+	}
+	return kept
+}
+
+// filterByConfidence drops comments the model is less than min confident
+// in, along with file comments. Unrated comments are kept.
+func filterByConfidence(comments []types.InlineComment, fileComments []types.FileComment, min float64) ([]types.InlineComment, []types.FileComment) {
+	if min <= 0 {
+		return comments, fileComments
+	}
+	var kept []types.InlineComment
+	for _, c := range comments {
+		if c.Confidence == nil || float64(*c.Confidence) >= min {
+			kept = append(kept, c)
+		}
+	}
+	var keptFiles []types.FileComment
+	for _, c := range fileComments {
+		if c.Confidence == nil || float64(*c.Confidence) >= min {
+			keptFiles = append(keptFiles, c)
+		}
+	}
+	if dropped := len(comments) + len(fileComments) - len(kept) - len(keptFiles); dropped > 0 {
+		log.WithFields(log.Fields{"dropped": dropped, "min": min}).Info("Dropped findings below the confidence threshold")
+	}
+	return kept, keptFiles
+}
+
+// filterFileComments drops file comments rated below min. Unrated comments
+// are kept.
+func filterFileComments(comments []types.FileComment, min types.Severity) []types.FileComment {
+	if min.Rank() == 0 {
+		return comments
+	}
+	var kept []types.FileComment
+	for _, c := range comments {
+		if c.Severity.Rank() == 0 || c.Severity.Rank() >= min.Rank() {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// capInlineComments keeps the max most severe comments, preferring
+// comments on riskier files, by scores, among equal severities, and returns
+// the remainder separately. A max of zero or less disables the cap.
+func capInlineComments(comments []types.InlineComment, max int, scores map[string]float64) ([]types.InlineComment, []types.InlineComment) {
+	if max <= 0 || len(comments) <= max {
+		return comments, nil
+	}
+
+	sorted := append([]types.InlineComment{}, comments...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if a, b := sorted[i].Severity.Rank(), sorted[j].Severity.Rank(); a != b {
+			return a > b
+		}
+		return scores[sorted[i].File] > scores[sorted[j].File]
+	})
+	return sorted[:max], sorted[max:]
+}
+
+// placeInlineComments checks each comment's side against the diff. A
+// comment on removed code must point at a removed line of the old file;
+// when it does not but the line exists in the new file, the model most
+// likely meant the new file and the comment is moved to the right side. A
+// comment on a range of lines the diff does not wholly show is narrowed to
+// its last line.
+func placeInlineComments(comments []types.InlineComment, files []diff.FileDiff) []types.InlineComment {
+	removed := map[string]map[int]bool{}
+	current := map[string]map[int]bool{}
+	for _, f := range files {
+		removed[f.Path()] = map[int]bool{}
+		current[f.Path()] = map[int]bool{}
+		for _, h := range f.Hunks {
+			for _, l := range h.Lines {
+				if l.Kind == diff.LineRemoved {
+					removed[f.Path()][l.OldLine] = true
+				} else {
+					current[f.Path()][l.NewLine] = true
+				}
+			}
+		}
+	}
+
+	for i, c := range comments {
+		if c.Side == types.SideLeft && !removed[c.File][c.Line] && current[c.File][c.Line] {
+			comments[i].Side = types.SideRight
+		}
+		// A suggestion can only replace a run of lines the diff shows.
+		if c.StartLine > 0 && (comments[i].Side == types.SideLeft || !spans(current[c.File], c.StartLine, c.Line)) {
+			comments[i].StartLine = 0
+		}
+	}
+	return comments
+}
+
+// spans reports whether lines holds every line from first to last.
+func spans(lines map[int]bool, first, last int) bool {
+	if first >= last {
+		return false
+	}
+	for n := first; n <= last; n++ {
+		if !lines[n] {
+			return false
+		}
+	}
+	return true
+}
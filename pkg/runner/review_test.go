@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+)
+
+// inCheckout runs the test in a temporary directory holding files, with
+// links mapping link paths to their targets, and returns the directory.
+func inCheckout(t *testing.T, files map[string]string, links map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skipf("symbolic links unsupported: %v", err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func TestReadWorkingFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("TOKEN=hunter2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dir := inCheckout(t,
+		map[string]string{"main.go": "package main", "pkg/a.go": "package pkg"},
+		map[string]string{"leak": secret, "dir": filepath.Dir(secret), "alias.go": "main.go"})
+
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"main.go", "package main", true},
+		{"pkg/a.go", "package pkg", true},
+		{"./pkg/../main.go", "package main", true},
+		{"missing.go", "", false},
+		{"leak", "", false},
+		{"alias.go", "", false},
+		{"dir/secret", "", false},
+		{"../main.go", "", false},
+		{secret, "", false},
+	}
+	for _, root := range []string{".", dir} {
+		for _, tt := range tests {
+			data, err := readWorkingFile(root, tt.path)
+			if (err == nil) != tt.ok || string(data) != tt.want {
+				t.Errorf("readWorkingFile(%q, %q) = %q, %v, want %q, ok %v", root, tt.path, data, err, tt.want, tt.ok)
+			}
+		}
+	}
+}
+
+func TestBuildFileExcerptsSkipsLinks(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "environ")
+	if err := os.WriteFile(secret, []byte("TOKEN=hunter2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	inCheckout(t, map[string]string{"main.go": "package main\n\nfunc main() {}\n"}, map[string]string{"env": secret})
+
+	files := diff.Parse("diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -3 +3 @@\n-func main() { }\n+func main() {}\n" +
+		"diff --git a/env b/env\nnew file mode 120000\n--- /dev/null\n+++ b/env\n@@ -0,0 +1 @@\n+" + secret)
+	excerpts := buildFileExcerpts(".", files)
+	if !strings.Contains(excerpts, "3: func main() {}") {
+		t.Errorf("excerpts miss main.go:\n%s", excerpts)
+	}
+	if strings.Contains(excerpts, "hunter2") {
+		t.Errorf("excerpts read through a symbolic link:\n%s", excerpts)
+	}
+}
@@ -0,0 +1,1392 @@
+// Package runner orchestrates a review: it gathers the diff, runs the
+// deterministic checks, asks the model for a review, and publishes the
+// results to GitHub.
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/analyzer"
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/apidoc"
+	"github.com/crazywolf132/repo-ranger/pkg/artifact"
+	"github.com/crazywolf132/repo-ranger/pkg/checkpoint"
+	"github.com/crazywolf132/repo-ranger/pkg/command"
+	"github.com/crazywolf132/repo-ranger/pkg/complexity"
+	"github.com/crazywolf132/repo-ranger/pkg/config"
+	"github.com/crazywolf132/repo-ranger/pkg/coverage"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/erraudit"
+	"github.com/crazywolf132/repo-ranger/pkg/event"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/history"
+	"github.com/crazywolf132/repo-ranger/pkg/metrics"
+	"github.com/crazywolf132/repo-ranger/pkg/pii"
+	"github.com/crazywolf132/repo-ranger/pkg/postprocess"
+	"github.com/crazywolf132/repo-ranger/pkg/spelling"
+	"github.com/crazywolf132/repo-ranger/pkg/styleguide"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	"github.com/crazywolf132/repo-ranger/pkg/webhook"
+	"github.com/crazywolf132/repo-ranger/pkg/xref"
+	log "github.com/sirupsen/logrus"
+)
+
+// Review depths trade cost against thoroughness.
+const (
+	DepthSummary  = "summary"  // a single summary call, no line-by-line pass
+	DepthStandard = "standard" // line-by-line review of each chunk
+	DepthDeep     = "deep"     // line-by-line review with file context and a reflection pass
+)
+
+// ErrIncomplete is returned by Run when some chunks of the diff could not be
+// reviewed. The partial review has still been published.
+var ErrIncomplete = errors.New("review is incomplete")
+
+// Config holds the settings of a single run.
+type Config struct {
+	Model string
+	// TriageModel, when set, first rates the risk of each chunk of a
+	// line-by-line review, so only the risky ones are reviewed by Model.
+	TriageModel       string
+	MaxTokens         int
+	ModelCapabilities map[string]api.CapabilityOverride
+	APITimeout        time.Duration
+
+	// DiffCommand is passed to the diff runner, unless DiffFile is set, in
+	// which case the diff is read from that file ("-" for stdin).
+	DiffCommand string
+	DiffFile    string
+	DiffTimeout time.Duration
+	// DiffFromPullRequest fetches the pull request's diff from the GitHub
+	// API instead, for server mode, which has no checkout.
+	DiffFromPullRequest bool
+	// Checkout is the directory of the repository's working tree, which
+	// the new contents of changed files are read from; the default is the
+	// working directory.
+	Checkout string
+	// OutputFormat, when set, prints the results to stdout for local runs:
+	// FormatMarkdown, FormatDiagnostics, or FormatDiagnosticsJSON.
+	OutputFormat string
+	// BaseRef is the revision previous versions of changed files are read from.
+	BaseRef string
+
+	ReviewDepth string
+	// ChunkOverlap is how many lines from the end of each chunk of a large
+	// diff are repeated at the start of the next.
+	ChunkOverlap int
+	// MaxCostPerRun caps the estimated cost of a review in US dollars.
+	// Reviews over the cap are degraded to a summary or skipped.
+	MaxCostPerRun float64
+	// TokenBudget caps the estimated tokens of a review. The riskiest
+	// files are reviewed at ReviewDepth and the rest only summarized.
+	TokenBudget int
+	// Priorities weight the risk of files, which orders the review and
+	// decides what TokenBudget and MaxInlineComments keep.
+	Priorities []config.Priority
+	// ChurnDays is how far back commits count towards a file's risk; 0
+	// ignores history.
+	ChurnDays int
+	// StackContext prepends the project's detected languages and
+	// frameworks to every prompt.
+	StackContext     bool
+	Focus            string
+	CoverageFile     string
+	SpellingCheck    bool
+	SpellingWordList string
+	StyleGuides      []string
+	CacheDir         string
+	// FormatDetectors name the detectors of cosmetic hunks (see
+	// diff.DefaultDetectors) that are dropped before review.
+	FormatDetectors []string
+	// MinRenameSimilarity is the similarity index below which a rename is
+	// reviewed as a deletion and an addition.
+	MinRenameSimilarity int
+	Scopes              []config.Scope
+	// PostProcessor, when its command is set, filters and enriches the
+	// findings before they are posted.
+	PostProcessor config.PostProcessor
+	// Analyzers are custom deterministic checks run alongside the
+	// built-in ones.
+	Analyzers []analyzer.Analyzer
+	// Egress keeps confidential files from the review model, pinning them
+	// to other providers or leaving them unreviewed.
+	Egress []EgressRule
+
+	PostPRComment bool
+	// CommentMode is CommentAppend or CommentUpdate.
+	CommentMode string
+	// Quiet posts the summary and inline comments as a single review
+	// instead of a summary comment and a review, so a review sends one
+	// notification.
+	Quiet bool
+	// BotLogin is the login repo-ranger posts as, which only its own
+	// comments carry. When empty it is asked of GitHub.
+	BotLogin string
+	// PIICheck reports personal data committed in fixtures and data files
+	// and masks it before prompting.
+	PIICheck bool
+	// APIDocCheck reports exported Go symbols added or changed without doc
+	// comments, suggesting comments the model drafts, and API changes made
+	// without a README, changelog, or docs update.
+	APIDocCheck bool
+	// ErrorAudit reports discarded errors, errors formatted without %w,
+	// and fmt.Errorf calls without context on changed Go lines, and asks
+	// the model to elaborate on them.
+	ErrorAudit bool
+	// CCOwners mentions the CODEOWNERS owners of files with critical
+	// findings in the summary comment.
+	CCOwners bool
+	// QuietHours, when set, keeps reviews finished within it from posting
+	// comments; QuietHoursMode, QuietChecks or QuietDefer, says what they
+	// post instead.
+	QuietHours     *QuietHours
+	QuietHoursMode string
+	// Preflight checks that the token can write the configured outputs
+	// before reviewing.
+	Preflight bool
+	// MergeQueueTimeout bounds the review of a merge group, which the
+	// merge queue waits on; a review running out of time reports what it
+	// finished without blocking the queue. 0 sets no bound.
+	MergeQueueTimeout time.Duration
+	UseChecks         bool
+	// CheckActions adds re-run, deep review, and dismiss buttons to the
+	// review's check runs.
+	CheckActions bool
+	// LongReviewTarget is where a review too long for a comment is
+	// published in full: LongReviewTruncate, LongReviewGist, or
+	// LongReviewWiki.
+	LongReviewTarget string
+	// UpdateDescription keeps a summary of the change and its risk grade
+	// between markers in the pull request description.
+	UpdateDescription bool
+	// TrackFindings opens an issue labelled TrackingLabel for each critical
+	// finding left unresolved when a pull request merges, and closes it once
+	// a later merge removes the flagged code. The issues go into
+	// TrackingMilestone and the TrackingProject board, when set.
+	TrackFindings        bool
+	TrackingLabel        string
+	TrackingMilestone    int
+	TrackingProject      string
+	ChecksPerDirectory   bool
+	ChecksDirectoryDepth int
+	InlineComments       bool
+	MaxInlineComments    int
+	// ResolveThreads resolves the threads of earlier findings that a new
+	// push addresses.
+	ResolveThreads bool
+	// FileComments posts the model's remarks about whole files as
+	// file-level review comments.
+	FileComments bool
+	// SubmitVerdict submits an approving or changes-requested review,
+	// updating the previous verdict on later pushes.
+	SubmitVerdict bool
+	// Annotations emits findings as workflow commands, which GitHub shows
+	// as annotations without any token permissions.
+	Annotations bool
+	// ReviewBundle is a directory to write the full results of the run to:
+	// the summary, findings as JSON and SARIF, and model transcripts.
+	ReviewBundle string
+	// SuggestionPatch writes the review's code suggestions to the review
+	// bundle as one patch against the head, to apply with git apply.
+	SuggestionPatch bool
+	// SaveTranscripts is a directory to write every prompt and raw model
+	// response of each run to, for debugging prompts.
+	SaveTranscripts string
+	// Secrets are redacted from everything written to the review bundle
+	// and saved transcripts.
+	Secrets []string
+	// MinSeverity drops inline findings below this severity; findings the
+	// model did not rate are kept.
+	MinSeverity types.Severity
+	// CategoryModes switch categories of findings off or to warn-only;
+	// see the config.Category constants.
+	CategoryModes map[types.Category]string
+	// CategoryLabels are added to the pull request when the review has
+	// findings in their category.
+	CategoryLabels map[types.Category]string
+	// MinConfidence drops findings the model is less confident in, from 0
+	// to 1; findings the model did not rate are kept.
+	MinConfidence float64
+	// Tone sets the register of the review, e.g. encouraging or rigorous.
+	Tone         string
+	SkipPatterns []string
+	SettleDelay  time.Duration
+	// CommentTemplate, when set, lays out the PR comment instead of the
+	// default template.
+	CommentTemplate *template.Template
+	// MaxReviewsPerDay limits the automatic reviews of a pull request per
+	// day, or 0 for no limit. Days begin at midnight in TimeZone, UTC when
+	// it is nil.
+	MaxReviewsPerDay int
+	TimeZone         *time.Location
+
+	// EventName, EventPath, HeadSHA, and OutputPath describe the GitHub
+	// Actions environment (GITHUB_EVENT_NAME, GITHUB_EVENT_PATH, GITHUB_SHA,
+	// and GITHUB_OUTPUT). They may be empty outside of Actions.
+	EventName  string
+	EventPath  string
+	HeadSHA    string
+	OutputPath string
+	// StepSummaryPath is GITHUB_STEP_SUMMARY, where the review is written
+	// when the token cannot post it.
+	StepSummaryPath string
+	// RunURL links to the workflow run, for check runs that ask for action.
+	RunURL string
+}
+
+// Orchestrator runs reviews with injected dependencies.
+type Orchestrator struct {
+	cfg     Config
+	diff    diff.Runner
+	api     api.Client
+	github  github.Client
+	results *webhook.Client
+	metrics metrics.Sink
+	usage   *api.UsageMeter
+	// usageBefore, modelUsageBefore, and requestsBefore are what the meter
+	// had recorded when the run started, for the runs of a batch that
+	// share it.
+	usageBefore      types.Usage
+	modelUsageBefore map[string]types.Usage
+	requestsBefore   int
+	stats            runStats
+	// stack is the detected project stack every prompt starts with.
+	stack string
+	// history, when set, records reviews; record is this run's review,
+	// appended once its cost is known.
+	history *history.Store
+	record  *history.Record
+
+	transcript     *transcript
+	artifacts      *artifact.Client
+	bundleArtifact string
+	// runID correlates the run's log lines, comments, and transcripts.
+	runID string
+	// reviewCount, when set, is the review count marker the summary
+	// comment carries.
+	reviewCount string
+}
+
+// runStats describes what a run did, for metrics.
+type runStats struct {
+	reviewed bool
+	files    int
+	chunks   int
+	failed   int
+	declined int
+	// dropped counts comment blocks the review parser could not read.
+	dropped  int
+	findings map[types.Severity]int
+}
+
+// Option is a function that configures an orchestrator.
+type Option func(*Orchestrator)
+
+// WithResultsWebhook delivers the structured result of every review to a
+// webhook.
+func WithResultsWebhook(c *webhook.Client) Option {
+	return func(o *Orchestrator) {
+		o.results = c
+	}
+}
+
+// WithMetrics emits run metrics to sink. Token usage and cost are read from
+// usage, which should also be given to the API client.
+func WithMetrics(sink metrics.Sink, usage *api.UsageMeter) Option {
+	return func(o *Orchestrator) {
+		o.metrics = sink
+		o.usage = usage
+	}
+}
+
+// WithBundleUpload uploads the review bundle as a workflow artifact called
+// name.
+func WithBundleUpload(c *artifact.Client, name string) Option {
+	return func(o *Orchestrator) {
+		o.artifacts = c
+		o.bundleArtifact = name
+	}
+}
+
+// New creates an orchestrator.
+func New(cfg Config, diffRunner diff.Runner, apiClient api.Client, githubClient github.Client, opts ...Option) *Orchestrator {
+	o := &Orchestrator{
+		cfg:    cfg,
+		diff:   diffRunner,
+		api:    apiClient,
+		github: githubClient,
+		runID:  newRunID(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if cfg.StackContext {
+		o.stack = o.detectStack().String()
+		if o.stack != "" {
+			o.api = stackClient{Client: o.api, header: o.stack}
+		}
+	}
+	if cfg.ReviewBundle != "" || cfg.SaveTranscripts != "" {
+		o.transcript = &transcript{}
+		o.api = recordingClient{Client: o.api, transcript: o.transcript}
+	}
+	if cfg.PIICheck {
+		// Outermost, so transcripts record the masked prompts too.
+		o.api = maskingClient{Client: o.api}
+		o.cfg.Egress = append([]EgressRule(nil), cfg.Egress...)
+		for i, rule := range o.cfg.Egress {
+			if rule.Client != nil {
+				o.cfg.Egress[i].Client = maskingClient{Client: rule.Client}
+			}
+		}
+	}
+	return o
+}
+
+// publication is everything a review posts to the pull request.
+type publication struct {
+	review         string
+	checkRuns      []github.CheckRun
+	comments       []types.InlineComment
+	fileComments   []types.FileComment
+	verdict        string
+	verdictSummary string
+	// labels are added to the pull request.
+	labels []string
+	// description, when set, is written to the managed section of the pull
+	// request description.
+	description string
+	// report, when set, re-renders review with links to the inline
+	// comments once they are posted.
+	report *reviewReport
+}
+
+// analysis is the outcome of the deterministic checks.
+type analysis struct {
+	findings      []types.Finding
+	metrics       []summaryMetric
+	functions     []complexity.Function
+	promptContext []string
+	// personal is the personal data found in the diff, masked before the
+	// diff reaches a model.
+	personal []pii.Match
+	// undocumented are the exported symbols the diff adds or changes
+	// without doc comments, whose comments the model drafts.
+	undocumented []apidoc.Symbol
+}
+
+// Run performs a full review. It returns nil without reviewing when there
+// is nothing to do, such as an empty diff or a comment without a command.
+func (o *Orchestrator) Run(ctx context.Context) error {
+	start := time.Now()
+	beginCorrelation(o.runID)
+	defer endCorrelation()
+	o.setOutput("run_id", o.runID)
+	ctx, cancel := o.mergeQueueBudget(ctx)
+	defer cancel()
+	err := o.mergeQueueOutcome(ctx, o.run(ctx))
+	o.reportCost()
+	o.recordReview()
+	o.saveTranscripts()
+	if o.metrics != nil {
+		o.emitMetrics(time.Since(start), err)
+	}
+	return err
+}
+
+// emitMetrics reports the outcome, duration, spend, and findings of a run.
+func (o *Orchestrator) emitMetrics(elapsed time.Duration, err error) {
+	outcome := "reviewed"
+	switch {
+	case errors.Is(err, ErrIncomplete):
+		outcome = "incomplete"
+	case err != nil:
+		outcome = "failed"
+	case !o.stats.reviewed:
+		outcome = "skipped"
+	}
+	tags := []string{"model:" + o.cfg.Model}
+	if prEvent, err := o.parsePullRequestEvent(); err == nil && prEvent.Repository.FullName != "" {
+		tags = append(tags, "repo:"+prEvent.Repository.FullName)
+	}
+
+	o.metrics.Count("runs", 1, append(tags, "outcome:"+outcome)...)
+	o.metrics.Timing("run.duration", elapsed, append(tags, "outcome:"+outcome)...)
+	if o.usage != nil {
+		usage, requests := o.runUsage()
+		o.metrics.Count("requests", float64(requests), tags...)
+		o.metrics.Count("tokens.prompt", float64(usage.PromptTokens), tags...)
+		o.metrics.Count("tokens.completion", float64(usage.CompletionTokens), tags...)
+		o.metrics.Count("tokens.cached", float64(usage.PromptTokensDetails.CachedTokens), tags...)
+		o.metrics.Count("cost.usd", o.cost(), tags...)
+	}
+	if o.stats.reviewed {
+		o.metrics.Count("files", float64(o.stats.files), tags...)
+		o.metrics.Count("chunks", float64(o.stats.chunks), tags...)
+		o.metrics.Count("chunks.failed", float64(o.stats.failed), tags...)
+		o.metrics.Count("chunks.declined", float64(o.stats.declined), tags...)
+		o.metrics.Count("comments.dropped", float64(o.stats.dropped), tags...)
+		for severity, n := range o.stats.findings {
+			if severity == "" {
+				severity = "unrated"
+			}
+			o.metrics.Count("findings", float64(n), append(tags, "severity:"+string(severity))...)
+		}
+	}
+}
+
+func (o *Orchestrator) run(ctx context.Context) error {
+	focus := o.cfg.Focus
+
+	// Comments on a pull request only trigger a run when they are newly
+	// created and carry a repo-ranger slash command. Edits and deletions
+	// would otherwise replay a command, such as apply, that already ran.
+	var commentEvent types.IssueCommentEvent
+	var explainTarget *command.Target
+	var applyIDs, opinionArgs []string
+	applying, opining := false, false
+	if o.isCommentEvent() {
+		var err error
+		commentEvent, err = o.parseIssueCommentEvent()
+		if err != nil {
+			return fmt.Errorf("failed to read issue comment event: %w", err)
+		}
+		if commentEvent.Action != "created" {
+			log.WithField("action", commentEvent.Action).Info("Comment was not newly created; nothing to do")
+			return nil
+		}
+		cmd, ok := command.Parse(commentEvent.Comment.Body)
+		if !ok {
+			log.Info("Comment does not contain a repo-ranger command; nothing to do")
+			return nil
+		}
+		switch cmd.Name {
+		case "review":
+			if f := cmd.Flag("focus"); f != "" {
+				focus = f
+			}
+		case "explain":
+			if len(cmd.Args) == 0 {
+				log.Info("explain command requires a file argument; nothing to do")
+				return nil
+			}
+			target := command.ParseTarget(cmd.Args[0])
+			explainTarget = &target
+		case "apply":
+			applying, applyIDs = true, cmd.Args
+		case "second-opinion":
+			opining, opinionArgs = true, cmd.Args
+		default:
+			log.WithField("command", cmd.Name).Info("Unsupported repo-ranger command; nothing to do")
+			return nil
+		}
+		log.WithFields(log.Fields{
+			"command": cmd.Name,
+			"user":    commentEvent.Comment.User.Login,
+		}).Info("Handling slash command")
+		if applying {
+			return o.applySuggestions(commentEvent, applyIDs)
+		}
+		if opining {
+			apiCtx, cancel := context.WithTimeout(ctx, o.cfg.APITimeout)
+			defer cancel()
+			return o.secondOpinion(apiCtx, commentEvent, opinionArgs)
+		}
+	}
+
+	if o.isCheckRunEvent() {
+		if review, err := o.handleCheckRunAction(); err != nil || !review {
+			return err
+		}
+	}
+
+	// Closed pull requests are not reviewed; merging one updates the
+	// tracking issues instead.
+	if o.cfg.TrackFindings && !o.isCommentEvent() && !o.isCheckRunEvent() {
+		if prEvent, err := o.parsePullRequestEvent(); err == nil && prEvent.Action == "closed" {
+			if !prEvent.PullRequest.Merged {
+				log.Info("Pull request closed without merging; nothing to track")
+				return nil
+			}
+			return o.trackFindings(prEvent)
+		}
+	}
+
+	if o.settle() || o.skip() || o.overDailyLimit() {
+		return nil
+	}
+	if err := o.preflight(); err != nil {
+		return err
+	}
+
+	// Get diff
+	diffCtx, cancel := context.WithTimeout(ctx, o.cfg.DiffTimeout)
+	defer cancel()
+
+	var diffOutput string
+	var err error
+	if o.cfg.DiffFile != "" {
+		log.WithField("file", o.cfg.DiffFile).Info("Reading diff from file")
+		diffOutput, err = diff.Load(o.cfg.DiffFile)
+		if err != nil {
+			o.postUnreviewable(o.diffFailed(err))
+			return fmt.Errorf("failed to read diff file: %w", err)
+		}
+	} else if o.cfg.DiffFromPullRequest {
+		prEvent, err := o.parsePullRequestEvent()
+		if err != nil {
+			return fmt.Errorf("failed to read pull request event: %w", err)
+		}
+		log.WithField("number", prEvent.PullRequest.Number).Info("Fetching pull request diff")
+		diffOutput, err = o.github.PullRequestDiff(prEvent)
+		if err != nil {
+			o.postUnreviewable(o.diffFailed(err))
+			return fmt.Errorf("failed to fetch pull request diff: %w", err)
+		}
+	} else {
+		log.WithFields(log.Fields{
+			"command": o.cfg.DiffCommand,
+			"timeout": o.cfg.DiffTimeout,
+		}).Info("Executing diff command")
+
+		diffOutput, err = o.diff.Run(diffCtx, o.cfg.DiffCommand)
+		if err != nil {
+			o.postUnreviewable(o.diffFailed(err))
+			return fmt.Errorf("failed to execute diff command: %w", err)
+		}
+	}
+
+	trimmedDiff := strings.TrimSpace(diffOutput)
+	if trimmedDiff == "" {
+		log.Info("No code changes detected")
+		o.printResults("", nil, nil, nil)
+		if explainTarget == nil {
+			o.postUnreviewable(o.emptyDiff())
+		}
+		return nil
+	}
+	files := diff.Parse(trimmedDiff)
+
+	if explainTarget != nil {
+		apiCtx, cancel := context.WithTimeout(ctx, o.cfg.APITimeout)
+		defer cancel()
+		if err := o.explainChange(apiCtx, files, *explainTarget, commentEvent); err != nil {
+			return fmt.Errorf("failed to explain change: %w", err)
+		}
+		log.WithField("target", explainTarget.String()).Info("Explanation posted successfully")
+		return nil
+	}
+
+	outcome, err := o.reviewPatch(ctx, trimmedDiff, focus)
+	if err != nil {
+		o.postUnreviewable(o.mergeQueueFailed(ctx, err))
+		return err
+	}
+	switch {
+	case outcome.skipped != nil:
+		o.postSkipped(*outcome.skipped)
+		return nil
+	case outcome.formattingOnly:
+		o.setOutput("review", formattingOnlySummary)
+		o.printResults(formattingOnlySummary, nil, nil, nil)
+		o.publish(ctx, nil, publication{review: formattingOnlySummary, verdict: github.VerdictApprove, verdictSummary: formattingOnlySummary})
+		return nil
+	case outcome.result.Chunks == 0:
+		log.Info("No changes matched any configured scope")
+		o.printResults("", nil, nil, nil)
+		return nil
+	}
+	files, result, checks, store, checkRuns := outcome.files, outcome.result, outcome.checks, outcome.store, outcome.checkRuns
+	reviewComments, fileComments := outcome.comments, outcome.fileComments
+	assignFindingIDs(reviewComments)
+	postable, warnings, postableFiles, fileWarnings := splitWarnings(reviewComments, fileComments, o.cfg.CategoryModes)
+
+	if o.cfg.ChecksPerDirectory {
+		checkRuns = directoryCheckRuns(files, checks.findings, reviewComments, o.cfg.ChecksDirectoryDepth)
+	}
+
+	var comments, overflow []types.InlineComment
+	if o.cfg.InlineComments {
+		comments, overflow = capInlineComments(postable, o.cfg.MaxInlineComments, outcome.scores)
+		if len(overflow) > 0 {
+			log.WithFields(log.Fields{
+				"posted":   len(comments),
+				"overflow": len(overflow),
+			}).Info("Inline comment cap reached; moving remaining findings to the summary")
+		}
+	}
+
+	if result.Failed == 0 && store != nil {
+		if err := store.Remove(); err != nil {
+			log.WithError(err).Warn("Failed to remove checkpoint")
+		}
+	}
+
+	codeowners := o.loadOwners()
+	report := reviewReport{
+		Chunks:         result.Chunks,
+		FailedChunks:   result.Failed,
+		DeclinedChunks: result.Declined,
+		Triage:         o.summarizeTriage(result),
+		Review:         result.Text,
+		Findings:       checks.findings,
+		Metrics:        checks.metrics,
+		Functions:      checks.functions,
+		Comments:       comments,
+		Overflow:       overflow,
+		Degraded:       outcome.budget.depth != "",
+		Estimate:       outcome.budget.estimate,
+		CostCap:        o.cfg.MaxCostPerRun,
+		Summarized:     outcome.summarized,
+		TokenBudget:    o.cfg.TokenBudget,
+		Confidential:   outcome.confidential,
+		Categories:     countCategories(checks.findings, reviewComments, fileComments),
+		Warnings:       warnings,
+		FileWarnings:   fileWarnings,
+		Severities:     countSeverities(checks.findings, reviewComments, fileComments),
+		Codeowners:     codeowners,
+		Ownership:      countOwnership(codeowners, checks.findings, reviewComments, fileComments),
+		CCOwners:       o.cfg.CCOwners,
+	}
+	finalReview := o.formatReview(report)
+	o.setOutput("review", finalReview)
+	o.printResults(finalReview, checks.findings, reviewComments, fileComments)
+	o.annotate(checks.findings, reviewComments, fileComments)
+
+	log.Debug("Review output generated successfully")
+
+	o.stats = runStats{
+		reviewed: true,
+		files:    len(files),
+		chunks:   result.Chunks,
+		failed:   result.Failed,
+		declined: result.Declined,
+		dropped:  outcome.dropped,
+		findings: countSeverities(checks.findings, reviewComments, fileComments),
+	}
+
+	out := publication{
+		review:       finalReview,
+		checkRuns:    checkRuns,
+		comments:     comments,
+		fileComments: postableFiles,
+		labels:       categoryLabels(countCategories(checks.findings, reviewComments, fileComments), o.cfg.CategoryLabels),
+		report:       &report,
+	}
+	gap := reviewGap(outcome)
+	out.verdict, out.verdictSummary = reviewVerdict(checks.findings, reviewComments, gap)
+	if o.cfg.UpdateDescription {
+		out.description = describeChange(files, outcome.scores, checks.findings, reviewComments, fileComments)
+	}
+	o.publish(ctx, files, out)
+
+	prEvent, _ := o.parsePullRequestEvent()
+	if o.history != nil && prEvent.Repository.FullName != "" {
+		o.record = &history.Record{
+			Kind:        history.KindReview,
+			Repo:        prEvent.Repository.FullName,
+			PullRequest: prEvent.PullRequest.Number,
+			HeadSHA:     prEvent.PullRequest.Head.SHA,
+			Findings:    historyFindings(checks.findings, reviewComments, fileComments),
+		}
+	}
+	doc := postprocess.NewDocument(prEvent, checks.findings, reviewComments, fileComments)
+	bundleDir := o.bundleDir(prEvent)
+	var patch string
+	if o.cfg.SuggestionPatch && bundleDir != "" {
+		patch = o.suggestionPatch(ctx, prEvent, reviewComments)
+	}
+	o.saveBundle(ctx, bundleDir, finalReview, doc, collectDiagnostics(checks.findings, reviewComments, fileComments), patch, gap)
+
+	if o.results != nil {
+		payload := webhook.Result{
+			Document: doc,
+			Stats: webhook.Stats{
+				Files:          len(files),
+				Chunks:         result.Chunks,
+				FailedChunks:   result.Failed,
+				DeclinedChunks: result.Declined,
+			},
+			Verdict: out.verdict,
+			Review:  finalReview,
+		}
+		if err := o.results.Send(ctx, payload); err != nil {
+			log.WithError(err).Error("Failed to deliver results to webhook")
+		} else {
+			log.Info("Results delivered to webhook")
+		}
+	}
+
+	if result.Failed > 0 {
+		// Fail the run so it can be re-run; the checkpoint limits the re-run
+		// to the chunks that failed.
+		return fmt.Errorf("%w: %d of %d chunks failed; re-run to review the remaining chunks",
+			ErrIncomplete, result.Failed, result.Chunks)
+	}
+	return nil
+}
+
+// formattingOnlySummary is posted instead of a review when every hunk is
+// cosmetic.
+const formattingOnlySummary = "**Formatting only.** This pull request only changes whitespace, import order, or formatting, so there is nothing to review."
+
+// patchReview is the outcome of reviewing a diff, before anything is
+// published.
+type patchReview struct {
+	// formattingOnly reports that every hunk was cosmetic, so nothing was
+	// reviewed.
+	formattingOnly bool
+	// skipped, when set, is the check run explaining why nothing was
+	// reviewed.
+	skipped *github.CheckRun
+	budget  budgetDecision
+	// summarized are the files the token budget left only summarized.
+	summarized []string
+	// confidential are the files the egress policy kept from every model.
+	confidential []string
+	// scores rate the risk of each file, keyed by path.
+	scores map[string]float64
+	// dropped counts the comment blocks of the review that could not be
+	// read.
+	dropped      int
+	files        []diff.FileDiff
+	result       diffReview
+	checks       analysis
+	comments     []types.InlineComment
+	fileComments []types.FileComment
+	checkRuns    []github.CheckRun
+	store        *checkpoint.Store
+}
+
+// reviewPatch runs the deterministic checks and the model review over a
+// diff and parses the findings. A result with no chunks means no scope
+// matched the diff.
+func (o *Orchestrator) reviewPatch(ctx context.Context, trimmedDiff, focus string) (patchReview, error) {
+	files := diff.Parse(trimmedDiff)
+	diffCtx, cancelDiff := context.WithTimeout(ctx, o.cfg.DiffTimeout)
+	defer cancelDiff()
+
+	// Formatter churn costs tokens without giving the model anything to
+	// review; a pull request with nothing else gets a one-line summary.
+	if stripped, removed := diff.StripCosmetic(trimmedDiff, o.cfg.FormatDetectors); removed > 0 {
+		log.WithField("hunks", removed).Info("Dropped whitespace-only and formatting-only hunks")
+		trimmedDiff = strings.TrimSpace(stripped)
+		if trimmedDiff == "" {
+			return patchReview{formattingOnly: true}, nil
+		}
+		files = diff.Parse(trimmedDiff)
+	}
+
+	if o.cfg.MinRenameSimilarity > 0 {
+		if rewritten := o.splitMisdetectedRenames(diffCtx, trimmedDiff, o.cfg.MinRenameSimilarity); rewritten != trimmedDiff {
+			trimmedDiff = rewritten
+			files = diff.Parse(trimmedDiff)
+		}
+	}
+
+	checks := o.analyze(diffCtx, files)
+	// The riskiest files go first, so they land in the first chunks and
+	// win when budgets or caps force triage.
+	scores := o.scoreFiles(diffCtx, files)
+	trimmedDiff = orderByRisk(trimmedDiff, files, scores)
+	trimmedDiff = pii.Mask(trimmedDiff, checks.personal)
+	if renames := buildRenameContext(o.shareableFiles(files)); renames != "" {
+		checks.promptContext = append(checks.promptContext, renames)
+	}
+	if focus != "" {
+		checks.promptContext = append(checks.promptContext, buildFocusContext(focus))
+	}
+	if o.cfg.Tone != "" {
+		checks.promptContext = append(checks.promptContext, buildToneContext(o.cfg.Tone))
+	}
+	if categories := buildCategoryContext(o.cfg.CategoryModes); categories != "" {
+		checks.promptContext = append(checks.promptContext, categories)
+	}
+
+	// Confidential files never reach the review model, nor its checkpoint.
+	trimmedDiff, routed, confidential := o.applyEgress(trimmedDiff)
+	shared := o.shareableFiles(files)
+
+	apiCtx, cancel := context.WithTimeout(ctx, o.cfg.APITimeout)
+	defer cancel()
+
+	if len(o.cfg.StyleGuides) > 0 {
+		summarizer := styleguide.NewSummarizer(o.api, o.cfg.Model, o.cfg.CacheDir)
+		if rules, err := summarizer.Summarize(apiCtx, o.cfg.StyleGuides); err != nil {
+			log.WithError(err).Warn("Failed to summarize style guides")
+		} else {
+			checks.promptContext = append([]string{buildStyleGuideContext(rules)}, checks.promptContext...)
+		}
+	}
+
+	// Chunk reviews are checkpointed so that re-running after a partial
+	// failure only reviews the chunks that failed.
+	store, err := checkpoint.Open(o.cfg.CacheDir, o.cfg.Model+"\x00"+trimmedDiff)
+	if err != nil {
+		log.WithError(err).Warn("Failed to open checkpoint; earlier chunk reviews will not be reused")
+		store = nil
+	} else if store.Len() > 0 {
+		log.WithField("chunks", store.Len()).Info("Resuming review from checkpoint")
+	}
+
+	budget := o.fitBudget(trimmedDiff, checks.promptContext)
+	if budget.skip {
+		run := budgetCheckRun(budget.estimate, o.cfg.MaxCostPerRun)
+		return patchReview{skipped: &run}, nil
+	}
+	var plan *reviewPlan
+	if budget.depth == "" {
+		plan = o.planReview(trimmedDiff, shared, checks.promptContext, scores)
+	}
+	if plan != nil && plan.skip {
+		run := tokenBudgetCheckRun(plan.tokens, o.cfg.TokenBudget)
+		return patchReview{skipped: &run}, nil
+	}
+
+	reviewText, reviewFiles := trimmedDiff, shared
+	if plan != nil {
+		reviewText, reviewFiles = plan.detailed, diff.Parse(plan.detailed)
+	}
+	var result diffReview
+	var checkRuns []github.CheckRun
+	if reviewText != "" {
+		result, checkRuns, err = o.review(apiCtx, reviewText, reviewFiles, checks.promptContext, budget.depth, store)
+		if err != nil {
+			return patchReview{}, err
+		}
+	}
+	var summarized []string
+	if plan != nil && plan.summarized != "" {
+		summarized, err = o.summarizeRest(apiCtx, plan, checks.promptContext, &result)
+		if err != nil {
+			return patchReview{}, err
+		}
+	}
+	if err := o.reviewRouted(apiCtx, routed, checks.promptContext, budget.depth, &result); err != nil {
+		return patchReview{}, err
+	}
+	if result.Chunks == 0 {
+		if len(confidential) > 0 && trimmedDiff == "" {
+			run := confidentialCheckRun(confidential)
+			return patchReview{skipped: &run}, nil
+		}
+		return patchReview{files: files, result: result, budget: budget}, nil
+	}
+
+	parsed, parsedFiles, dropped := parseComments(result.Text)
+	result.Text = stripChunkMarkers(result.Text)
+	if len(checks.undocumented) > 0 {
+		var drafted []types.InlineComment
+		checks.findings, drafted = o.draftDocComments(apiCtx, files, checks.findings, checks.undocumented)
+		parsed = append(parsed, drafted...)
+	}
+	reviewComments := filterBySeverity(dedupeInlineComments(placeInlineComments(parsed, files)), o.cfg.MinSeverity)
+	fileComments := filterFileComments(dedupeFileComments(parsedFiles), o.cfg.MinSeverity)
+	reviewComments, fileComments = filterByConfidence(reviewComments, fileComments, o.cfg.MinConfidence)
+	reviewComments, fileComments = applyCategories(reviewComments, fileComments, o.cfg.CategoryModes)
+	if o.cfg.PostProcessor.Command != "" {
+		checks.findings, reviewComments, fileComments, err = o.postProcess(ctx, checks.findings, reviewComments, fileComments)
+		if err != nil {
+			return patchReview{}, err
+		}
+	}
+	return patchReview{
+		files:        files,
+		result:       result,
+		checks:       checks,
+		comments:     reviewComments,
+		fileComments: fileComments,
+		checkRuns:    checkRuns,
+		store:        store,
+		budget:       budget,
+		summarized:   summarized,
+		confidential: confidential,
+		scores:       scores,
+		dropped:      dropped,
+	}, nil
+}
+
+// settle waits for the pull request to settle after a push and reports
+// whether a newer push has since superseded this run. Rapid pushes (e.g.
+// during a rebase) each trigger a run; waiting lets all but the last exit early.
+func (o *Orchestrator) settle() bool {
+	if o.cfg.SettleDelay <= 0 {
+		return false
+	}
+	prEvent, err := o.parsePullRequestEvent()
+	if err != nil || prEvent.Action != "synchronize" || prEvent.PullRequest.Head.SHA == "" {
+		return false
+	}
+
+	log.WithField("delay", o.cfg.SettleDelay).Info("Waiting for the pull request to settle")
+	time.Sleep(o.cfg.SettleDelay)
+	current, err := o.github.PullRequestHead(prEvent)
+	if err != nil {
+		log.WithError(err).Warn("Failed to re-check the pull request head; reviewing anyway")
+		return false
+	}
+	if current != prEvent.PullRequest.Head.SHA {
+		log.WithFields(log.Fields{
+			"pushed":  prEvent.PullRequest.Head.SHA,
+			"current": current,
+		}).Info("Pull request head moved while settling; leaving the review to the newer run")
+		return true
+	}
+	return false
+}
+
+// skip reports whether the author opted the pull request out of review,
+// like [skip ci], leaving a neutral check run when check runs are enabled.
+func (o *Orchestrator) skip() bool {
+	if o.isCommentEvent() || o.isCheckRunEvent() {
+		return false
+	}
+	prEvent, err := o.parsePullRequestEvent()
+	if err != nil || prEvent.PullRequest.Number == 0 {
+		return false
+	}
+	pattern, source := findSkipMarker(o.github, prEvent, o.cfg.SkipPatterns)
+	if pattern == "" {
+		return false
+	}
+
+	log.WithFields(log.Fields{
+		"pattern": pattern,
+		"source":  source,
+	}).Info("Skip marker found; not reviewing")
+	if o.cfg.UseChecks {
+		run := github.CheckRun{
+			Name:       "Repo Ranger",
+			Conclusion: "neutral",
+			Title:      "Review skipped",
+			Summary:    fmt.Sprintf("Review skipped because the %s contains `%s`.", source, pattern),
+		}
+		if err := o.github.CreateCheckRun(prEvent, run); err != nil {
+			log.WithError(err).Error("Failed to create GitHub Check Run")
+		}
+	}
+	return true
+}
+
+// analyze runs the deterministic checks over the parsed diff. The checks
+// cover every file, but the prompt context only tells of the files the
+// egress policy lets reach the review model.
+func (o *Orchestrator) analyze(ctx context.Context, files []diff.FileDiff) analysis {
+	var a analysis
+	shared := o.shareableFiles(files)
+	// Server mode has no checkout, so the checks reading the new versions
+	// of changed files are left out rather than run on the server's own.
+	root := o.checkout()
+
+	if root != "" {
+		schemaFindings := checkSchemaCompatibility(ctx, o.diff, root, files, o.cfg.BaseRef)
+		a.findings = append(a.findings, schemaFindings...)
+		if sharedFindings := o.shareableFindings(schemaFindings); len(sharedFindings) > 0 {
+			a.promptContext = append(a.promptContext, buildSchemaContext(sharedFindings))
+		}
+	}
+
+	if o.cfg.CoverageFile != "" {
+		if profile, err := coverage.Load(o.cfg.CoverageFile); err != nil {
+			log.WithError(err).Warn("Failed to load coverage report")
+		} else if result := profile.Evaluate(changedLines(files)); result.Instrumented() > 0 {
+			covered := fmt.Sprintf("%.1f%%", result.Percent())
+			a.metrics = append(a.metrics, summaryMetric{Name: "Changed lines covered", Value: covered})
+			if sharedResult := profile.Evaluate(changedLines(shared)); sharedResult.Instrumented() > 0 {
+				a.promptContext = append(a.promptContext, buildCoverageContext(sharedResult))
+			}
+			o.setOutput("changed_lines_coverage", fmt.Sprintf("%.1f", result.Percent()))
+			log.WithField("coverage", covered).Info("Evaluated coverage of changed lines")
+		}
+	}
+
+	if o.cfg.SpellingCheck {
+		checker := spelling.NewChecker()
+		if o.cfg.SpellingWordList != "" {
+			if err := checker.LoadWordList(o.cfg.SpellingWordList); err != nil {
+				log.WithError(err).Warn("Failed to load spelling word list")
+			}
+		}
+		spellingFindings := checker.Check(files)
+		log.WithField("count", len(spellingFindings)).Debug("Spelling and naming pass complete")
+		a.findings = append(a.findings, spellingFindings...)
+	}
+
+	if o.cfg.PIICheck {
+		found, personal := pii.Scan(files)
+		a.personal = personal
+		if mode := o.cfg.CategoryModes[types.CategoryPrivacy]; mode != config.CategoryOff {
+			for _, f := range found {
+				if mode == config.CategoryWarnOnly && f.Severity.Rank() > types.SeverityMinor.Rank() {
+					f.Severity = types.SeverityMinor
+				}
+				a.findings = append(a.findings, f)
+			}
+		}
+		log.WithFields(log.Fields{"findings": len(found), "masked": len(personal)}).Debug("Personal data pass complete")
+	}
+
+	if o.cfg.APIDocCheck && root != "" && o.cfg.CategoryModes[types.CategoryDocs] != config.CategoryOff {
+		symbols := findAPIChanges(ctx, o.diff, root, files, o.cfg.BaseRef)
+		docsUpdated := false
+		for _, f := range files {
+			docsUpdated = docsUpdated || apidoc.IsDocFile(f.Path())
+		}
+		for _, s := range symbols {
+			if !s.Documented {
+				a.undocumented = append(a.undocumented, s)
+			}
+		}
+		a.findings = append(a.findings, apidoc.Undocumented(symbols)...)
+		a.findings = append(a.findings, apidoc.UndescribedChanges(symbols, docsUpdated)...)
+		log.WithFields(log.Fields{"symbols": len(symbols), "undocumented": len(a.undocumented)}).Debug("API documentation pass complete")
+	}
+
+	if o.cfg.ErrorAudit && root != "" {
+		issues := findErrorHandling(root, files)
+		a.findings = append(a.findings, erraudit.Findings(issues)...)
+		// Confidential code must not reach the model through the issues'
+		// source lines.
+		var sharedIssues []erraudit.Issue
+		for _, issue := range issues {
+			if o.egressRule(issue.File) == nil {
+				sharedIssues = append(sharedIssues, issue)
+			}
+		}
+		if len(sharedIssues) > 0 {
+			a.promptContext = append(a.promptContext, buildErrorAuditContext(sharedIssues))
+		}
+		log.WithField("issues", len(issues)).Debug("Error handling audit complete")
+	}
+
+	if root != "" {
+		callers := findStaleCallers(ctx, o.diff, root, files, o.cfg.BaseRef)
+		a.findings = append(a.findings, xref.Findings(callers)...)
+		var sharedCallers []xref.Caller
+		for _, c := range callers {
+			if o.egressRule(c.File) == nil && o.egressRule(c.Change.File) == nil {
+				sharedCallers = append(sharedCallers, c)
+			}
+		}
+		if len(sharedCallers) > 0 {
+			a.promptContext = append(a.promptContext, buildCallerContext(sharedCallers))
+		}
+	}
+
+	if len(o.cfg.Analyzers) > 0 {
+		found := analyzer.Run(ctx, o.cfg.Analyzers, analyzer.Input{Files: files, BaseRef: o.cfg.BaseRef, Root: root}, func(a analyzer.Analyzer, err error) {
+			log.WithError(err).WithField("analyzer", a.Name()).Warn("Analyzer failed; leaving out its findings")
+		})
+		log.WithFields(log.Fields{"analyzers": len(o.cfg.Analyzers), "findings": len(found)}).Debug("Custom analyzers complete")
+		a.findings = append(a.findings, found...)
+	}
+
+	if root != "" {
+		a.functions = analyzeFunctions(ctx, o.diff, root, files, o.cfg.BaseRef)
+	}
+	var sharedFunctions []complexity.Function
+	for _, f := range a.functions {
+		if o.egressRule(f.File) == nil {
+			sharedFunctions = append(sharedFunctions, f)
+		}
+	}
+	if len(sharedFunctions) > 0 {
+		a.promptContext = append(a.promptContext, buildComplexityContext(sharedFunctions))
+	}
+	return a
+}
+
+// postProcess passes the findings through the configured post-processor.
+// A failing post-processor fails the run rather than posting findings the
+// team meant to filter.
+func (o *Orchestrator) postProcess(ctx context.Context, findings []types.Finding, comments []types.InlineComment, fileComments []types.FileComment) ([]types.Finding, []types.InlineComment, []types.FileComment, error) {
+	prEvent, _ := o.parsePullRequestEvent()
+	pp := o.cfg.PostProcessor
+	doc := postprocess.NewDocument(prEvent, findings, comments, fileComments)
+
+	log.WithField("command", pp.Command).Info("Running findings post-processor")
+	doc, err := postprocess.Run(ctx, pp.Command, pp.Args, pp.Timeout, doc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to post-process findings: %w", err)
+	}
+	findings, comments, fileComments = doc.Results()
+	log.WithFields(log.Fields{
+		"findings":      len(findings),
+		"comments":      len(comments),
+		"file_comments": len(fileComments),
+	}).Debug("Post-processor returned findings")
+	return findings, comments, fileComments, nil
+}
+
+// review asks the model to review the diff, either as a whole or once per
+// configured scope. Scoped reviews also return one check run per scope. A
+// result with no chunks means no scope matched the diff. forceDepth, when
+// set, overrides the configured and per-scope depths.
+func (o *Orchestrator) review(ctx context.Context, diffText string, files []diff.FileDiff, promptContext []string, forceDepth string, store *checkpoint.Store) (diffReview, []github.CheckRun, error) {
+	// Size chunks from the model's context window, leaving room for the
+	// completion and everything else in the prompt.
+	caps := api.CapabilitiesFor(o.cfg.Model, o.cfg.ModelCapabilities)
+	log.WithField("contextWindow", caps.ContextWindow).Debug("Resolved model capabilities")
+
+	if len(o.cfg.Scopes) == 0 {
+		depth := o.cfg.ReviewDepth
+		if forceDepth != "" {
+			depth = forceDepth
+		}
+		maxChunkSize := chunkBudget(caps, o.cfg.MaxTokens, promptContext, depth)
+		result, err := o.reviewDiff(ctx, diffText, files, promptContext, depth, maxChunkSize, store)
+		if err != nil {
+			return result, nil, fmt.Errorf("failed during API call: %w", err)
+		}
+		return result, nil, nil
+	}
+
+	var total diffReview
+	var sections []string
+	var checkRuns []github.CheckRun
+	for _, scope := range o.cfg.Scopes {
+		scopeDiff := strings.TrimSpace(diff.Filter(diffText, scope.Matches))
+		if scopeDiff == "" {
+			log.WithField("scope", scope.Name).Debug("No changes in scope")
+			continue
+		}
+		scopeDepth := o.cfg.ReviewDepth
+		if scope.Depth != "" {
+			scopeDepth = strings.ToLower(scope.Depth)
+		}
+		if forceDepth != "" {
+			scopeDepth = forceDepth
+		}
+		scopeContext := promptContext
+		if scope.Focus != "" {
+			scopeContext = append(append([]string{}, promptContext...), buildFocusContext(scope.Focus))
+		}
+
+		log.WithFields(log.Fields{
+			"scope": scope.Name,
+			"depth": scopeDepth,
+		}).Info("Reviewing scope")
+		maxChunkSize := chunkBudget(caps, o.cfg.MaxTokens, scopeContext, scopeDepth)
+		result, err := o.reviewDiff(ctx, scopeDiff, diff.Parse(scopeDiff), scopeContext, scopeDepth, maxChunkSize, store)
+		if err != nil {
+			return total, nil, fmt.Errorf("failed during API call for scope %q: %w", scope.Name, err)
+		}
+		total.add(result)
+		sections = append(sections, fmt.Sprintf("## %s\n\n%s", scope.Name, result.Text))
+		checkRuns = append(checkRuns, github.CheckRun{
+			Name:    "Repo Ranger: " + scope.Name,
+			Title:   scope.Name + " review",
+			Summary: stripChunkMarkers(result.Text),
+		})
+	}
+	total.Text = strings.Join(sections, "\n\n")
+	return total, checkRuns, nil
+}
+
+// publish posts the review to the pull request, if the run has one, or
+// as check runs on the reviewed commit for events without one.
+func (o *Orchestrator) publish(ctx context.Context, files []diff.FileDiff, out publication) {
+	prEvent, err := o.parsePullRequestEvent()
+	if err == nil && prEvent.PullRequest.Number != 0 {
+		if until, quiet := o.cfg.QuietHours.Until(time.Now()); quiet && !o.forkRestricted(prEvent) {
+			o.publishQuietly(prEvent, out, until)
+			return
+		}
+		o.publishTo(ctx, prEvent, files, out)
+		return
+	}
+	target, ok := o.checkRunTarget()
+	if !ok {
+		log.WithError(err).Debug("No valid pull request event detected")
+		return
+	}
+	log.WithFields(log.Fields{"event": o.cfg.EventName, "head": target.PullRequest.Head.SHA}).Info("No pull request to comment on; reporting with check runs")
+	o.createCheckRuns(target, out)
+}
+
+// checkRunTarget returns the repository and commit to report on with check
+// runs for events without a pull request, such as pushes and merge groups.
+// With no pull request to comment on, the review goes to check runs when
+// either comments or check runs are enabled.
+func (o *Orchestrator) checkRunTarget() (types.PullRequestEvent, bool) {
+	var target types.PullRequestEvent
+	if !o.cfg.UseChecks && !o.cfg.PostPRComment {
+		return target, false
+	}
+	ev, err := event.Load(o.cfg.EventName, o.cfg.EventPath)
+	if err != nil || ev.Output != event.OutputCheckRun {
+		return target, false
+	}
+	target.Repository.FullName = ev.Repo
+	target.PullRequest.Head.SHA = o.cfg.HeadSHA
+	if ev.Name != event.WorkflowDispatch && ev.Head != "" {
+		target.PullRequest.Head.SHA = ev.Head
+	}
+	return target, true
+}
+
+// createCheckRuns creates the review's check runs, or a single one with the
+// whole review, on the head of prEvent.
+func (o *Orchestrator) createCheckRuns(prEvent types.PullRequestEvent, out publication) {
+	checkRuns := out.checkRuns
+	if len(checkRuns) == 0 {
+		checkRuns = []github.CheckRun{{Name: "Repo Ranger", Title: "Code review", Summary: out.review}}
+	}
+	for _, run := range checkRuns {
+		if o.cfg.CheckActions {
+			run.Actions = checkRunActions
+		}
+		run.HeadSHA = prEvent.PullRequest.Head.SHA
+		if run.HeadSHA == "" {
+			run.HeadSHA = o.cfg.HeadSHA
+		}
+		if err := o.github.CreateCheckRun(prEvent, run); err != nil {
+			log.WithError(err).WithField("name", run.Name).Error("Failed to create GitHub Check Run")
+		} else {
+			log.WithField("name", run.Name).Info("GitHub Check Run created successfully")
+		}
+	}
+}
+
+// postInlineComments posts the review's inline comments, when enabled, and
+// reports whether any were posted.
+func (o *Orchestrator) postInlineComments(prEvent types.PullRequestEvent, comments []types.InlineComment) bool {
+	if !o.cfg.InlineComments {
+		return false
+	}
+	if len(comments) == 0 {
+		log.Debug("No inline comments found in the aggregated review")
+		return false
+	}
+	if err := o.github.PostInlineComments(prEvent, comments); err != nil {
+		log.WithError(err).Error("Failed to post inline comments")
+		return false
+	}
+	log.WithField("count", len(comments)).Info("Inline comments posted successfully")
+	return true
+}
+
+// reviewMarkers returns the hidden markers ending the comment or review
+// that carries the summary: the run ID, the daily review count, and the
+// reviewed head.
+func (o *Orchestrator) reviewMarkers(prEvent types.PullRequestEvent) string {
+	var marker string
+	if o.runID != "" {
+		marker = "\n\n" + runIDMarker(o.runID)
+	}
+	if o.reviewCount != "" {
+		marker += "\n\n" + o.reviewCount
+	}
+	if headSHA := prEvent.PullRequest.Head.SHA; headSHA != "" {
+		marker += "\n\n" + headMarker(headSHA)
+	}
+	return marker
+}
+
+// publishTo posts the review to the given pull request.
+func (o *Orchestrator) publishTo(ctx context.Context, prEvent types.PullRequestEvent, files []diff.FileDiff, out publication) {
+	if o.forkRestricted(prEvent) {
+		log.WithField("head", prEvent.PullRequest.Head.Repo.FullName).Warn(
+			"Pull request comes from a fork, so the token is read-only; writing the review to the step summary and bundle instead of posting it")
+		o.writeStepSummary(out.review)
+		return
+	}
+
+	// A newer push starts its own run; don't let this one post results
+	// for a commit that is no longer the head of the pull request.
+	if headSHA := prEvent.PullRequest.Head.SHA; headSHA != "" {
+		current, err := o.github.PullRequestHead(prEvent)
+		switch {
+		case err != nil:
+			log.WithError(err).Warn("Failed to check for a newer pull request head; posting anyway")
+		case current != headSHA:
+			log.WithFields(log.Fields{
+				"reviewed": headSHA,
+				"current":  current,
+			}).Info("Pull request head moved during the review; a newer run supersedes this one")
+			return
+		}
+	}
+
+	if o.cfg.Quiet {
+		// One review carries the summary and the inline comments, so the
+		// pull request notifies once.
+		var body string
+		var inline []types.InlineComment
+		if o.cfg.PostPRComment {
+			// The markers are those of summary comments, so later runs
+			// find the summary and support can trace it to this run.
+			marker := o.reviewMarkers(prEvent)
+			body = o.fitComment(prEvent, out.review, len(marker)) + marker
+		}
+		if o.cfg.InlineComments {
+			inline = out.comments
+		}
+		if body == "" && len(inline) == 0 {
+			log.Debug("Nothing to post in the review")
+		} else if err := o.github.PostReview(prEvent, body, inline); errors.Is(err, github.ErrForbidden) {
+			log.WithError(err).Error("Failed to post review; the token needs pull-requests: write permission")
+		} else if err != nil {
+			log.WithError(err).Error("Failed to post review")
+		} else {
+			log.WithField("comments", len(inline)).Info("Review posted successfully")
+		}
+	} else {
+		// Posting the inline comments first lets the summary link to them.
+		if o.postInlineComments(prEvent, out.comments) && o.cfg.PostPRComment && out.report != nil {
+			if links := o.findingLinks(prEvent); len(links) > 0 {
+				linked := *out.report
+				linked.Links = links
+				out.review = o.formatReview(linked)
+			}
+		}
+	}
+	if o.cfg.PostPRComment && !o.cfg.Quiet {
+		marker := o.reviewMarkers(prEvent)
+		comment := o.fitComment(prEvent, out.review, len(marker)) + marker
+		if err := o.postSummary(prEvent, comment); errors.Is(err, github.ErrForbidden) {
+			log.WithError(err).Error("Failed to post PR comment; the token needs pull-requests: write permission")
+		} else if err != nil {
+			log.WithError(err).Error("Failed to post PR comment")
+		} else {
+			log.Info("PR comment posted successfully")
+		}
+	}
+
+	if out.description != "" {
+		o.updateDescription(prEvent, out.description)
+	}
+
+	if o.cfg.UseChecks {
+		o.createCheckRuns(prEvent, out)
+	}
+
+	if o.cfg.FileComments && len(out.fileComments) > 0 {
+		if err := o.github.PostFileComments(prEvent, out.fileComments); err != nil {
+			log.WithError(err).Error("Failed to post file comments")
+		} else {
+			log.WithField("count", len(out.fileComments)).Info("File comments posted successfully")
+		}
+	}
+
+	if len(out.labels) > 0 {
+		if err := o.github.AddLabels(prEvent, out.labels); err != nil {
+			log.WithError(err).Error("Failed to label pull request")
+		} else {
+			log.WithField("labels", strings.Join(out.labels, ", ")).Info("Pull request labelled by finding category")
+		}
+	}
+
+	if o.cfg.SubmitVerdict {
+		o.submitVerdict(prEvent, out.verdict, out.verdictSummary)
+	}
+
+	if o.cfg.ResolveThreads && prEvent.Action == "synchronize" && prEvent.PullRequest.Head.SHA != "" {
+		o.resolveAddressedThreads(ctx, prEvent, files)
+	}
+}
@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/stack"
+	log "github.com/sirupsen/logrus"
+)
+
+// stackClient prefixes every prompt with the project's detected stack.
+type stackClient struct {
+	api.Client
+	header string
+}
+
+func (c stackClient) Review(ctx context.Context, model, prompt string) (string, error) {
+	return c.Client.Review(ctx, model, c.header+"\n\n"+prompt)
+}
+
+// detectStack reads the project's manifests from the checkout, or from the
+// default branch of the repository in server mode, which has none.
+func (o *Orchestrator) detectStack() stack.Stack {
+	read := stack.ReadFunc(func(path string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(o.checkout(), path))
+	})
+	if o.cfg.DiffFromPullRequest {
+		prEvent, err := o.parsePullRequestEvent()
+		if err != nil || prEvent.Repository.FullName == "" {
+			return stack.Stack{}
+		}
+		read = func(path string) ([]byte, error) {
+			return o.github.FileContents(prEvent.Repository.FullName, path)
+		}
+	}
+	s := stack.Detect(read)
+	if !s.Empty() {
+		log.WithFields(log.Fields{
+			"languages":  strings.Join(s.Languages, ", "),
+			"frameworks": strings.Join(s.Frameworks, ", "),
+		}).Info("Detected project stack")
+	}
+	return s
+}
@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// suggestionPatch renders the code suggestions of the review as one
+// unified patch against the head of the pull request, so they can be
+// applied locally with git apply. Suggestions that overlap an earlier one,
+// fall outside their file, or whose file's patch does not apply cleanly to
+// the head are left out and counted in a warning.
+func (o *Orchestrator) suggestionPatch(ctx context.Context, prEvent types.PullRequestEvent, comments []types.InlineComment) string {
+	ref := prEvent.PullRequest.Head.SHA
+	if ref == "" {
+		ref = o.cfg.HeadSHA
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	read := func(file string) (string, error) {
+		return o.diff.FileAt(ctx, ref, file)
+	}
+	if o.checkout() == "" {
+		// Server mode has no repository to read the head from.
+		read = func(file string) (string, error) {
+			content, err := o.github.FileContentsAt(prEvent.Repository.FullName, ref, file)
+			return string(content), err
+		}
+	}
+
+	byFile := map[string][]types.InlineComment{}
+	var files []string
+	for _, c := range comments {
+		if c.Suggestion == "" || c.Side == types.SideLeft {
+			continue
+		}
+		if _, ok := byFile[c.File]; !ok {
+			files = append(files, c.File)
+		}
+		byFile[c.File] = append(byFile[c.File], c)
+	}
+	sort.Strings(files)
+
+	var patch strings.Builder
+	skipped := 0
+	for _, file := range files {
+		content, err := read(file)
+		if err != nil {
+			log.WithError(err).WithField("file", file).Warn("Failed to read file for the suggestion patch")
+			skipped += len(byFile[file])
+			continue
+		}
+		lines := len(strings.Split(strings.TrimSuffix(content, "\n"), "\n"))
+		edits, dropped := suggestionEdits(byFile[file], lines)
+		skipped += dropped
+		section, err := diff.EditPatch(file, content, edits)
+		if err == nil {
+			err = checkPatch(content, section, edits)
+		}
+		if err != nil {
+			log.WithError(err).WithField("file", file).Warn("Leaving file out of the suggestion patch")
+			skipped += len(edits)
+			continue
+		}
+		patch.WriteString(section)
+	}
+	if skipped > 0 {
+		log.WithField("skipped", skipped).Warn("Left suggestions that do not apply cleanly out of the suggestion patch")
+	}
+	return patch.String()
+}
+
+// suggestionEdits orders the suggestions of one file as edits, dropping
+// and counting those past its last line or overlapping an earlier edit.
+func suggestionEdits(comments []types.InlineComment, lines int) ([]diff.Edit, int) {
+	var edits []diff.Edit
+	for _, c := range comments {
+		start := c.StartLine
+		if start == 0 {
+			start = c.Line
+		}
+		edits = append(edits, diff.Edit{
+			Start: start,
+			End:   c.Line,
+			Lines: strings.Split(strings.TrimSuffix(c.Suggestion, "\n"), "\n"),
+		})
+	}
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+
+	kept := edits[:0]
+	last := 0
+	for _, e := range edits {
+		if e.Start <= last || e.End > lines {
+			continue
+		}
+		kept = append(kept, e)
+		last = e.End
+	}
+	return kept, len(edits) - len(kept)
+}
+
+// checkPatch applies a file's patch section to content, as git apply
+// would, and compares the result with content edited directly.
+func checkPatch(content, section string, edits []diff.Edit) error {
+	files := diff.Parse(section)
+	if len(files) != 1 {
+		return fmt.Errorf("patch has %d file sections", len(files))
+	}
+	applied, err := diff.Apply(content, files[0])
+	if err != nil {
+		return err
+	}
+	if applied != applyEdits(content, edits) {
+		return fmt.Errorf("patch does not reproduce the suggestions")
+	}
+	return nil
+}
+
+// applyEdits returns content with the edits made, which must be in order,
+// not overlap, and lie within content.
+func applyEdits(content string, edits []diff.Edit) string {
+	lines := strings.Split(content, "\n")
+	var out []string
+	next := 1
+	for _, e := range edits {
+		out = append(out, lines[next-1:e.Start-1]...)
+		out = append(out, e.Lines...)
+		next = e.End + 1
+	}
+	out = append(out, lines[next-1:]...)
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+func TestSuggestionEdits(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []types.InlineComment
+		lines    int
+		want     []diff.Edit
+		skipped  int
+	}{
+		{
+			name:     "single line",
+			comments: []types.InlineComment{{Line: 3, Suggestion: "x := 1\n"}},
+			lines:    5,
+			want:     []diff.Edit{{Start: 3, End: 3, Lines: []string{"x := 1"}}},
+		},
+		{
+			name:     "range of lines",
+			comments: []types.InlineComment{{StartLine: 2, Line: 4, Suggestion: "a\nb"}},
+			lines:    5,
+			want:     []diff.Edit{{Start: 2, End: 4, Lines: []string{"a", "b"}}},
+		},
+		{
+			name: "ordered by start",
+			comments: []types.InlineComment{
+				{Line: 5, Suggestion: "e"},
+				{StartLine: 1, Line: 2, Suggestion: "ab"},
+			},
+			lines: 5,
+			want: []diff.Edit{
+				{Start: 1, End: 2, Lines: []string{"ab"}},
+				{Start: 5, End: 5, Lines: []string{"e"}},
+			},
+		},
+		{
+			name: "overlapping edit dropped",
+			comments: []types.InlineComment{
+				{StartLine: 2, Line: 4, Suggestion: "first"},
+				{Line: 4, Suggestion: "second"},
+				{Line: 5, Suggestion: "third"},
+			},
+			lines: 5,
+			want: []diff.Edit{
+				{Start: 2, End: 4, Lines: []string{"first"}},
+				{Start: 5, End: 5, Lines: []string{"third"}},
+			},
+			skipped: 1,
+		},
+		{
+			name:     "past the last line dropped",
+			comments: []types.InlineComment{{Line: 6, Suggestion: "x"}, {StartLine: 4, Line: 7, Suggestion: "y"}},
+			lines:    5,
+			want:     []diff.Edit{},
+			skipped:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skipped := suggestionEdits(tt.comments, tt.lines)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("edits = %+v, want %+v", got, tt.want)
+			}
+			if skipped != tt.skipped {
+				t.Errorf("skipped = %d, want %d", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	const content = "one\ntwo\nthree\nfour\nfive\n"
+	tests := []struct {
+		name  string
+		edits []diff.Edit
+		want  string
+	}{
+		{"no edits", nil, content},
+		{"replace a line", []diff.Edit{{Start: 2, End: 2, Lines: []string{"TWO"}}}, "one\nTWO\nthree\nfour\nfive\n"},
+		{"replace a range with more lines", []diff.Edit{{Start: 2, End: 3, Lines: []string{"a", "b", "c"}}}, "one\na\nb\nc\nfour\nfive\n"},
+		{"first and last lines", []diff.Edit{
+			{Start: 1, End: 1, Lines: []string{"ONE"}},
+			{Start: 5, End: 5, Lines: []string{"FIVE"}},
+		}, "ONE\ntwo\nthree\nfour\nFIVE\n"},
+		{"adjacent edits", []diff.Edit{
+			{Start: 2, End: 2, Lines: []string{"x"}},
+			{Start: 3, End: 4, Lines: []string{"y"}},
+		}, "one\nx\ny\nfive\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyEdits(content, tt.edits); got != tt.want {
+				t.Errorf("applyEdits = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
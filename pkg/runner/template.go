@@ -0,0 +1,145 @@
+package runner
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultCommentTemplate lays out the PR comment unless
+// Config.CommentTemplate replaces it.
+//
+//go:embed comment.tmpl
+var defaultCommentTemplate string
+
+var defaultComment = template.Must(parseCommentTemplate("default", defaultCommentTemplate))
+
+// commentFuncs are the functions a comment template may call besides the
+// text/template builtins.
+var commentFuncs = template.FuncMap{
+	"severity": severityLabel,
+	"location": commentLocation,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"join":     strings.Join,
+}
+
+// commentData is what a comment template renders: every field of the
+// review report, such as Review, Comments, Findings, and Severities,
+// metadata about the pull request and run, and methods rendering each
+// section of the default comment.
+type commentData struct {
+	reviewReport
+	Repository  string
+	PullRequest int
+	Title       string
+	Head        string
+	Base        string
+	Model       string
+	RunID       string
+}
+
+func (d commentData) section(write func(*strings.Builder, reviewReport)) string {
+	var b strings.Builder
+	write(&b, d.reviewReport)
+	return b.String()
+}
+
+// Notices are the notes on what the review left out or reduced.
+func (d commentData) Notices() string { return d.section(writeNotices) }
+
+// SeverityTable counts the findings by severity and links to the lists.
+func (d commentData) SeverityTable() string { return d.section(writeSeverityTable) }
+
+// OwnershipTable counts the findings by code owner.
+func (d commentData) OwnershipTable() string {
+	var b strings.Builder
+	writeOwnershipTable(&b, d.Ownership, d.CCOwners)
+	return b.String()
+}
+
+// MetricsTable lists the summary metrics of the deterministic checks.
+func (d commentData) MetricsTable() string { return d.section(writeMetricsTable) }
+
+// CategoryTable counts the findings by category.
+func (d commentData) CategoryTable() string { return d.section(writeCategoryTable) }
+
+// FindingIndex lists the model's findings by ID.
+func (d commentData) FindingIndex() string { return d.section(writeFindingIndex) }
+
+// FunctionTable lists the size and complexity of the changed functions.
+func (d commentData) FunctionTable() string { return d.section(writeFunctionTable) }
+
+// OverflowList lists the findings not posted inline.
+func (d commentData) OverflowList() string { return d.section(writeOverflowList) }
+
+// WarningList lists the findings in warn-only categories.
+func (d commentData) WarningList() string { return d.section(writeWarningList) }
+
+// FindingList lists the findings of the deterministic checks.
+func (d commentData) FindingList() string { return d.section(writeFindingList) }
+
+// OwnerTag mentions the code owners of a file, if any.
+func (d commentData) OwnerTag(file string) string { return ownerTag(d.Codeowners, file) }
+
+func parseCommentTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(commentFuncs).Parse(text)
+}
+
+// LoadCommentTemplate reads a Go text/template laying out the PR comment.
+// It returns nil for an empty path. The template is tried on an empty
+// review, so a misspelled field fails here rather than on a pull request.
+func LoadCommentTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read comment template: %w", err)
+	}
+	tmpl, err := parseCommentTemplate(path, string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment template: %w", err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, commentData{}); err != nil {
+		return nil, fmt.Errorf("invalid comment template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderComment renders the PR comment with tmpl, or the default template
+// when tmpl is nil or fails.
+func renderComment(tmpl *template.Template, data commentData) string {
+	if tmpl != nil {
+		var b strings.Builder
+		err := tmpl.Execute(&b, data)
+		if err == nil {
+			return b.String()
+		}
+		log.WithError(err).Warn("Failed to render the comment template; using the default")
+	}
+	var b strings.Builder
+	if err := defaultComment.Execute(&b, data); err != nil {
+		// The default template only calls methods that cannot fail.
+		panic(err)
+	}
+	return b.String()
+}
+
+// formatReview renders the PR comment for the review report with the
+// configured template and the pull request's metadata.
+func (o *Orchestrator) formatReview(report reviewReport) string {
+	data := commentData{reviewReport: report, Model: o.cfg.Model, RunID: o.runID}
+	if prEvent, err := o.parsePullRequestEvent(); err == nil {
+		data.Repository = prEvent.Repository.FullName
+		data.PullRequest = prEvent.PullRequest.Number
+		data.Title = prEvent.PullRequest.Title
+		data.Head = prEvent.PullRequest.Head.SHA
+		data.Base = prEvent.PullRequest.Base.Ref
+	}
+	return renderComment(o.cfg.CommentTemplate, data)
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/command"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/history"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// resolveAddressedThreads resolves the open threads of earlier repo-ranger
+// findings whose lines this push changed, when the model agrees the new code
+// addresses the finding. Each resolved thread gets an "addressed in" reply.
+func (o *Orchestrator) resolveAddressedThreads(ctx context.Context, prEvent types.PullRequestEvent, files []diff.FileDiff) {
+	threads, err := o.github.ListReviewThreads(prEvent)
+	if err != nil {
+		log.WithError(err).Warn("Failed to list review threads; not resolving addressed findings")
+		return
+	}
+
+	changed := map[string]diff.FileDiff{}
+	for _, f := range files {
+		changed[f.Path()] = f
+	}
+
+	resolved := 0
+	for _, thread := range threads {
+		// GitHub marks a thread outdated once the lines it comments on change.
+		if thread.IsResolved || !thread.IsOutdated || len(thread.Comments) == 0 {
+			continue
+		}
+		finding := thread.Comments[0]
+		if !strings.Contains(finding.Body, github.FindingMarker) {
+			continue
+		}
+		fileDiff, ok := changed[thread.Path]
+		if !ok {
+			continue
+		}
+
+		client, model := o.modelFor(thread.Path)
+		if client == nil {
+			continue
+		}
+		line := thread.OriginalLine
+		target := command.Target{Path: thread.Path, Start: line, End: line}
+		answer, err := client.Review(ctx, model, buildAddressedPrompt(fileDiff, finding.Body, o.loadFileContext(target)))
+		if err != nil {
+			log.WithError(err).WithField("file", thread.Path).Warn("Failed to check whether a finding was addressed")
+			continue
+		}
+		if !isAddressed(answer) {
+			continue
+		}
+
+		entry := log.WithFields(log.Fields{"file": thread.Path, "line": line})
+		reply := fmt.Sprintf("Addressed in %s.", prEvent.PullRequest.Head.SHA)
+		if err := o.github.ReplyToReviewComment(prEvent, finding.DatabaseID, reply); err != nil {
+			entry.WithError(err).Warn("Failed to reply to an addressed finding")
+			continue
+		}
+		if err := o.github.ResolveReviewThread(thread.ID); err != nil {
+			entry.WithError(err).Warn("Failed to resolve an addressed finding")
+			continue
+		}
+		resolved++
+	}
+	if resolved > 0 {
+		log.WithField("count", resolved).Info("Resolved threads of addressed findings")
+		o.appendHistory(history.Record{
+			Kind:        history.KindAddressed,
+			Repo:        prEvent.Repository.FullName,
+			PullRequest: prEvent.PullRequest.Number,
+			HeadSHA:     prEvent.PullRequest.Head.SHA,
+			Addressed:   resolved,
+		})
+	}
+}
+
+// isAddressed reads the verdict of an addressed prompt.
+func isAddressed(answer string) bool {
+	verdict := strings.ToUpper(strings.TrimSpace(answer))
+	return strings.HasPrefix(verdict, "ADDRESSED")
+}
@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// trackedFinding is what a tracking issue records about its finding, in a
+// hidden marker in the issue body.
+type trackedFinding struct {
+	PullRequest int
+	File        string
+	Line        int
+	// Code is the flagged line, empty when it was not in the diff.
+	Code string
+}
+
+const trackedPrefix = "<!-- repo-ranger:tracked "
+
+func (t trackedFinding) marker() string {
+	return fmt.Sprintf("%spr=%d line=%d file=%s code=%s -->", trackedPrefix, t.PullRequest, t.Line,
+		base64.StdEncoding.EncodeToString([]byte(t.File)), base64.StdEncoding.EncodeToString([]byte(t.Code)))
+}
+
+// parseTrackedFinding reads the marker of a tracking issue body.
+func parseTrackedFinding(body string) (trackedFinding, bool) {
+	var t trackedFinding
+	i := strings.Index(body, trackedPrefix)
+	if i < 0 {
+		return t, false
+	}
+	rest := body[i+len(trackedPrefix):]
+	end := strings.Index(rest, "-->")
+	if end < 0 {
+		return t, false
+	}
+	for _, field := range strings.Fields(rest[:end]) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "pr":
+			t.PullRequest, _ = strconv.Atoi(value)
+		case "line":
+			t.Line, _ = strconv.Atoi(value)
+		case "file", "code":
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return t, false
+			}
+			if key == "file" {
+				t.File = string(decoded)
+			} else {
+				t.Code = string(decoded)
+			}
+		}
+	}
+	return t, t.File != ""
+}
+
+// trackFindings runs when a pull request is merged. It closes the tracking
+// issues whose flagged code the merge removed, then opens one for each
+// critical finding the pull request leaves unresolved.
+func (o *Orchestrator) trackFindings(prEvent types.PullRequestEvent) error {
+	diffText, err := o.github.PullRequestDiff(prEvent)
+	if err != nil {
+		return fmt.Errorf("failed to fetch pull request diff: %w", err)
+	}
+	files := diff.Parse(diffText)
+
+	issues, err := o.github.ListIssues(prEvent.Repository.FullName, o.cfg.TrackingLabel)
+	if err != nil {
+		return fmt.Errorf("failed to list tracking issues: %w", err)
+	}
+	o.closeRemovedFindings(prEvent, files, issues)
+	o.openTrackingIssues(prEvent, files, issues)
+	return nil
+}
+
+// closeRemovedFindings closes the tracking issues whose flagged code is no
+// longer on the default branch after the merge.
+func (o *Orchestrator) closeRemovedFindings(prEvent types.PullRequestEvent, files []diff.FileDiff, issues []github.Issue) {
+	changed := map[string]bool{}
+	for _, f := range files {
+		changed[f.OldPath] = true
+		changed[f.NewPath] = true
+	}
+	repo := prEvent.Repository.FullName
+	for _, issue := range issues {
+		tracked, ok := parseTrackedFinding(issue.Body)
+		if !ok || !changed[tracked.File] || tracked.PullRequest == prEvent.PullRequest.Number {
+			continue
+		}
+		contents, err := o.github.FileContents(repo, tracked.File)
+		switch {
+		case errors.Is(err, github.ErrNotFound):
+		case err != nil:
+			log.WithError(err).WithField("file", tracked.File).Warn("Failed to read a tracked file")
+			continue
+		case tracked.Code == "" || containsLine(string(contents), tracked.Code):
+			continue
+		}
+		comment := fmt.Sprintf("The flagged code was removed in #%d.", prEvent.PullRequest.Number)
+		if err := o.github.CloseIssue(repo, issue.Number, comment); err != nil {
+			log.WithError(err).WithField("issue", issue.Number).Warn("Failed to close tracking issue")
+			continue
+		}
+		log.WithField("issue", issue.Number).Info("Closed tracking issue; the flagged code was removed")
+	}
+}
+
+// openTrackingIssues opens an issue for each unresolved critical finding
+// on the pull request, skipping those already tracked.
+func (o *Orchestrator) openTrackingIssues(prEvent types.PullRequestEvent, files []diff.FileDiff, issues []github.Issue) {
+	threads, err := o.github.ListReviewThreads(prEvent)
+	if err != nil {
+		log.WithError(err).Warn("Failed to list review threads; not tracking findings")
+		return
+	}
+	tracked := map[trackedFinding]bool{}
+	for _, issue := range issues {
+		if t, ok := parseTrackedFinding(issue.Body); ok {
+			tracked[trackedFinding{PullRequest: t.PullRequest, File: t.File, Line: t.Line}] = true
+		}
+	}
+	changed := map[string]diff.FileDiff{}
+	for _, f := range files {
+		changed[f.Path()] = f
+	}
+
+	repo, number := prEvent.Repository.FullName, prEvent.PullRequest.Number
+	for _, thread := range threads {
+		// Outdated threads are on code that changed after the finding.
+		if thread.IsResolved || thread.IsOutdated || len(thread.Comments) == 0 {
+			continue
+		}
+		body := thread.Comments[0].Body
+		if !strings.Contains(body, github.FindingMarker) || github.FindingSeverity(body) != types.SeverityCritical {
+			continue
+		}
+		finding := trackedFinding{PullRequest: number, File: thread.Path, Line: thread.Line}
+		if tracked[finding] {
+			continue
+		}
+		finding.Code = lineAt(changed[thread.Path], thread.Line)
+
+		location := thread.Path
+		if thread.Line > 0 {
+			location = fmt.Sprintf("%s:%d", thread.Path, thread.Line)
+		}
+		summary, _, _ := strings.Cut(body, github.FindingMarker)
+		issue := github.NewIssue{
+			Title: fmt.Sprintf("Critical finding in %s (#%d)", location, number),
+			Body: fmt.Sprintf("Repo Ranger flagged this critical finding in #%d, and it was still unresolved when the pull request was merged.\n\n"+
+				"**Location:** `%s`\n\n%s\n\nThis issue closes itself once a merged pull request removes the flagged code.\n\n%s",
+				number, location, strings.TrimSpace(summary), finding.marker()),
+			Labels:    []string{o.cfg.TrackingLabel},
+			Milestone: o.cfg.TrackingMilestone,
+		}
+		created, err := o.github.CreateIssue(repo, issue)
+		if err != nil {
+			log.WithError(err).WithField("location", location).Error("Failed to open tracking issue")
+			continue
+		}
+		entry := log.WithFields(log.Fields{"issue": created.Number, "location": location})
+		entry.Info("Opened tracking issue for an unresolved critical finding")
+		if o.cfg.TrackingProject != "" {
+			if err := o.github.AddToProject(o.cfg.TrackingProject, created.NodeID); err != nil {
+				entry.WithError(err).Warn("Failed to add tracking issue to the project")
+			}
+		}
+	}
+}
+
+// lineAt returns the content of a new-file line shown in the diff of f.
+func lineAt(f diff.FileDiff, line int) string {
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.NewLine == line && l.Kind != diff.LineRemoved {
+				return l.Content
+			}
+		}
+	}
+	return ""
+}
+
+// containsLine reports whether contents has a line equal to line, ignoring
+// surrounding whitespace.
+func containsLine(contents, line string) bool {
+	want := strings.TrimSpace(line)
+	for _, l := range strings.Split(contents, "\n") {
+		if strings.TrimSpace(l) == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// transcriptEntry is one prompt sent to the model and its raw response.
+type transcriptEntry struct {
+	Seq int `json:"seq"`
+	// Run and Chunk are the correlation IDs of the run and of the chunk
+	// the exchange reviewed, if any.
+	Run        string `json:"run,omitempty"`
+	Chunk      string `json:"chunk,omitempty"`
+	Model      string `json:"model"`
+	Prompt     string `json:"prompt"`
+	Response   string `json:"response,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// transcript collects every model exchange of a run.
+type transcript struct {
+	mu      sync.Mutex
+	entries []transcriptEntry
+}
+
+func (t *transcript) add(e transcriptEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e.Seq = len(t.entries) + 1
+	t.entries = append(t.entries, e)
+}
+
+// Entries returns the exchanges recorded so far, in order.
+func (t *transcript) Entries() []transcriptEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]transcriptEntry(nil), t.entries...)
+}
+
+// recordingClient records every review request made through it.
+type recordingClient struct {
+	api.Client
+	transcript *transcript
+}
+
+func (c recordingClient) Review(ctx context.Context, model, prompt string) (string, error) {
+	start := time.Now()
+	response, err := c.Client.Review(ctx, model, prompt)
+	run, chunk := currentIDs()
+	entry := transcriptEntry{
+		Run:        run,
+		Chunk:      chunk,
+		Model:      model,
+		Prompt:     api.StripCacheBreakpoint(prompt),
+		Response:   response,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	c.transcript.add(entry)
+	return response, err
+}
+
+// transcriptIndexEntry describes one exchange saved by saveTranscripts,
+// naming the files holding its prompt and response.
+type transcriptIndexEntry struct {
+	Seq        int    `json:"seq"`
+	Chunk      string `json:"chunk,omitempty"`
+	Model      string `json:"model"`
+	Prompt     string `json:"prompt"`
+	Response   string `json:"response,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// saveTranscripts writes every exchange of the run to its own directory
+// under Config.SaveTranscripts: each prompt and raw response in a numbered
+// file, such as 001-prompt.txt and 001-response.txt, named with the chunk
+// ID when the exchange reviewed a chunk, and an index.jsonl describing
+// them. Secrets are redacted from everything written.
+func (o *Orchestrator) saveTranscripts() {
+	if o.cfg.SaveTranscripts == "" || o.transcript == nil {
+		return
+	}
+	entries := o.transcript.Entries()
+	if len(entries) == 0 {
+		return
+	}
+	dir := filepath.Join(o.cfg.SaveTranscripts, o.transcriptRunName(time.Now()))
+	if err := o.writeTranscripts(dir, entries); err != nil {
+		log.WithError(err).Warn("Failed to save transcripts")
+		return
+	}
+	log.WithFields(log.Fields{"dir": dir, "exchanges": len(entries)}).Info("Saved transcripts")
+}
+
+// transcriptRunName names a run's transcript directory after when it ran,
+// the pull request it reviewed, and its run ID, such as
+// "20240102T150405Z-acme-api-pr42-3f9a1c0b7d2e", so runs sort by time.
+func (o *Orchestrator) transcriptRunName(now time.Time) string {
+	name := now.UTC().Format("20060102T150405Z")
+	if prEvent, err := o.parsePullRequestEvent(); err == nil && prEvent.Repository.FullName != "" {
+		name += "-" + strings.ReplaceAll(prEvent.Repository.FullName, "/", "-")
+		if prEvent.PullRequest.Number > 0 {
+			name += fmt.Sprintf("-pr%d", prEvent.PullRequest.Number)
+		}
+	}
+	return name + "-" + o.runID
+}
+
+func (o *Orchestrator) writeTranscripts(dir string, entries []transcriptEntry) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create transcript directory: %w", err)
+	}
+	write := func(name, data string) error {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(o.redact(data)), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	}
+
+	var index strings.Builder
+	for _, e := range entries {
+		base := fmt.Sprintf("%03d", e.Seq)
+		if e.Chunk != "" {
+			base += "-" + e.Chunk
+		}
+		item := transcriptIndexEntry{
+			Seq:        e.Seq,
+			Chunk:      e.Chunk,
+			Model:      e.Model,
+			Prompt:     base + "-prompt.txt",
+			Error:      e.Error,
+			DurationMs: e.DurationMs,
+		}
+		if err := write(item.Prompt, e.Prompt); err != nil {
+			return err
+		}
+		if e.Error == "" {
+			item.Response = base + "-response.txt"
+			if err := write(item.Response, e.Response); err != nil {
+				return err
+			}
+		}
+		line, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal transcript index: %w", err)
+		}
+		index.Write(line)
+		index.WriteByte('\n')
+	}
+	return write("index.jsonl", index.String())
+}
+
+// secretPatterns match credentials that commonly turn up in diffs.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36,}`),
+	regexp.MustCompile(`github_pat_[A-Za-z0-9_]{22,}`),
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{20,}`),
+	regexp.MustCompile(`xox[abprs]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`(?:AKIA|ASIA)[0-9A-Z]{16}`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+}
+
+// redacted replaces secrets in written artifacts.
+const redacted = "[REDACTED]"
+
+// redact replaces the configured secrets and anything that looks like a
+// credential in s.
+func (o *Orchestrator) redact(s string) string {
+	for _, secret := range o.cfg.Secrets {
+		// Very short values would mangle ordinary text.
+		if len(secret) >= 8 {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, redacted)
+	}
+	return s
+}
@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// triageReport is how the triage model routed a review's chunks.
+type triageReport struct {
+	TriageModel string
+	ReviewModel string
+	// Triaged chunks were rated by TriageModel, and Cleared of them were
+	// rated low risk and not reviewed.
+	Triaged int
+	Cleared int
+	// Avoided is the estimated cost of reviewing the cleared chunks with
+	// ReviewModel, and Cost what the triage itself cost.
+	Avoided float64
+	Cost    float64
+}
+
+// Saved is the estimated net saving of triage, negative when rating the
+// chunks cost more than the reviews it avoided.
+func (t triageReport) Saved() float64 {
+	return t.Avoided - t.Cost
+}
+
+// routeChunk reports whether a chunk should be reviewed by the review
+// model. With a triage model set, the chunk is reviewed only if the triage
+// model rates it risky, and the routing is counted in result.
+func (o *Orchestrator) routeChunk(ctx context.Context, chunk string, promptContext []string, depth string, maxChunkSize int, result *diffReview) bool {
+	if o.cfg.TriageModel == "" {
+		return true
+	}
+	result.Triaged++
+	if o.triageChunk(ctx, chunk) {
+		return true
+	}
+	result.Cleared++
+	caps := api.CapabilitiesFor(o.cfg.Model, o.cfg.ModelCapabilities)
+	prompt, completion := o.estimateTokens(len(chunk), promptContext, depth, maxChunkSize)
+	result.Avoided += float64(prompt)*caps.InputCost/1e6 + float64(completion)*caps.OutputCost/1e6
+	log.WithFields(log.Fields{"size": len(chunk), "model": o.cfg.TriageModel}).Info("Triage rated chunk low risk; skipping review")
+	return false
+}
+
+// triageChunk asks the triage model whether a chunk is risky. A chunk it
+// cannot rate counts as risky, so it is still reviewed.
+func (o *Orchestrator) triageChunk(ctx context.Context, chunk string) bool {
+	answer, err := o.api.Review(ctx, o.cfg.TriageModel, buildTriagePrompt(chunk))
+	if err != nil {
+		log.WithError(err).Warn("Failed to triage chunk; reviewing it")
+		return true
+	}
+	log.WithField("answer", firstLine(answer)).Debug("Triaged chunk")
+	return !lowRisk(answer)
+}
+
+// lowRisk reports whether a triage answer rates the change low risk. Any
+// other answer counts as risky.
+func lowRisk(answer string) bool {
+	answer = strings.ToUpper(strings.TrimLeft(strings.TrimSpace(answer), "*_`#> "))
+	return strings.HasPrefix(answer, "LOW RISK") || strings.HasPrefix(answer, "LOW-RISK")
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// summarizeTriage reports the routing of a review and sets the
+// triage_savings output, or returns nil when no chunk was triaged.
+func (o *Orchestrator) summarizeTriage(result diffReview) *triageReport {
+	if result.Triaged == 0 {
+		return nil
+	}
+	report := &triageReport{
+		TriageModel: o.cfg.TriageModel,
+		ReviewModel: o.cfg.Model,
+		Triaged:     result.Triaged,
+		Cleared:     result.Cleared,
+		Avoided:     result.Avoided,
+	}
+	if usage, ok := o.modelUsage()[o.cfg.TriageModel]; ok {
+		report.Cost = api.Cost(api.CapabilitiesFor(o.cfg.TriageModel, o.cfg.ModelCapabilities), usage)
+	}
+	log.WithFields(log.Fields{
+		"triaged": report.Triaged,
+		"cleared": report.Cleared,
+		"saved":   fmt.Sprintf("%.4f", report.Saved()),
+	}).Info("Triage routed the review")
+	o.setOutput("triage_savings", fmt.Sprintf("%.6f", report.Saved()))
+	return report
+}
+
+// writeTriageNote explains which chunks the review model was spared.
+func writeTriageNote(b *strings.Builder, t *triageReport) {
+	if t == nil {
+		return
+	}
+	b.WriteString(fmt.Sprintf("> **Triaged:** `%s` rated %d of %d chunks of this diff low risk, so only the other %d went to `%s` for review",
+		t.TriageModel, t.Cleared, t.Triaged, t.Triaged-t.Cleared, t.ReviewModel))
+	if t.Cleared > 0 && t.Avoided > 0 {
+		b.WriteString(fmt.Sprintf(", saving an estimated $%.2f", t.Saved()))
+	}
+	b.WriteString(".\n\n")
+}
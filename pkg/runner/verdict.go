@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// verdictMarker identifies reviews submitted by repo-ranger, so later runs
+// update them rather than stacking new verdicts.
+const verdictMarker = "<!-- repo-ranger:verdict -->"
+
+// reviewVerdict requests changes when any finding is critical or major.
+// Otherwise it approves, unless gap says why part of the diff was not
+// reviewed line by line; then it only comments, since that part may hide
+// a blocking issue. It returns the verdict and a one-line explanation.
+func reviewVerdict(findings []types.Finding, comments []types.InlineComment, gap string) (string, string) {
+	blocking := 0
+	for _, f := range findings {
+		if f.Severity.Rank() >= types.SeverityMajor.Rank() {
+			blocking++
+		}
+	}
+	for _, c := range comments {
+		if c.Severity.Rank() >= types.SeverityMajor.Rank() {
+			blocking++
+		}
+	}
+	if blocking > 0 {
+		return github.VerdictRequestChanges, fmt.Sprintf("Repo Ranger requests changes: %d critical or major finding(s).", blocking)
+	}
+	if gap != "" {
+		return github.VerdictComment, "Repo Ranger found no critical or major issues, but did not review all of the diff line by line: " + gap + "."
+	}
+	return github.VerdictApprove, "Repo Ranger found no critical or major issues."
+}
+
+// reviewGap says why part of a reviewed diff was not reviewed line by
+// line, or returns "" when all of it was.
+func reviewGap(outcome patchReview) string {
+	var gaps []string
+	result := outcome.result
+	if result.Failed > 0 {
+		gaps = append(gaps, fmt.Sprintf("%d chunk(s) failed", result.Failed))
+	}
+	if result.Declined > 0 {
+		gaps = append(gaps, fmt.Sprintf("%d chunk(s) were declined", result.Declined))
+	}
+	if result.Cleared > 0 {
+		gaps = append(gaps, fmt.Sprintf("%d chunk(s) were cleared by triage", result.Cleared))
+	}
+	if result.Summaries > 0 {
+		gaps = append(gaps, "the review was reduced to a summary")
+	}
+	if len(outcome.summarized) > 0 {
+		gaps = append(gaps, fmt.Sprintf("%d file(s) were only summarized to fit the token budget", len(outcome.summarized)))
+	}
+	if len(outcome.confidential) > 0 {
+		gaps = append(gaps, fmt.Sprintf("%d confidential file(s) were kept from every model", len(outcome.confidential)))
+	}
+	return strings.Join(gaps, ", ")
+}
+
+// submitVerdict records the verdict on the pull request. An earlier
+// repo-ranger verdict is updated in place when it still holds; a request for
+// changes that no longer holds is dismissed before approving. A comment
+// leaves the earlier verdict as it is, since a partial review can neither
+// confirm nor overturn it.
+func (o *Orchestrator) submitVerdict(prEvent types.PullRequestEvent, verdict, summary string) {
+	headSHA := prEvent.PullRequest.Head.SHA
+	body := summary
+	if headSHA != "" {
+		body += fmt.Sprintf("\n\nReviewed at %s.", headSHA)
+	}
+	body += "\n\n" + verdictMarker
+
+	previous, err := o.previousVerdict(prEvent)
+	if err != nil {
+		log.WithError(err).Warn("Failed to look up the previous verdict; submitting a new one")
+	}
+
+	switch {
+	case previous == nil, verdict == github.VerdictComment:
+	case previous.State == "CHANGES_REQUESTED" && verdict == github.VerdictRequestChanges,
+		previous.State == "APPROVED" && verdict == github.VerdictApprove:
+		if err := o.github.UpdateReview(prEvent, previous.ID, body); err != nil {
+			log.WithError(err).Error("Failed to update the previous verdict")
+		} else {
+			log.WithField("verdict", verdict).Info("Previous verdict still holds; updated it")
+		}
+		return
+	case previous.State == "CHANGES_REQUESTED":
+		message := "The requested changes have been addressed"
+		if headSHA != "" {
+			message += " in " + headSHA
+		}
+		if err := o.github.DismissReview(prEvent, previous.ID, message+"."); err != nil {
+			log.WithError(err).Warn("Failed to dismiss the previous request for changes")
+		}
+	}
+
+	if err := o.github.SubmitReview(prEvent, verdict, body); err != nil {
+		log.WithError(err).Error("Failed to submit review verdict")
+	} else {
+		log.WithField("verdict", verdict).Info("Review verdict submitted")
+	}
+}
+
+// previousVerdict returns the latest verdict repo-ranger submitted on the
+// pull request that is still in effect, or nil if there is none.
+func (o *Orchestrator) previousVerdict(prEvent types.PullRequestEvent) (*github.Review, error) {
+	reviews, err := o.github.ListReviews(prEvent)
+	if err != nil {
+		return nil, err
+	}
+	var latest *github.Review
+	for i := range reviews {
+		r := &reviews[i]
+		if !strings.Contains(r.Body, verdictMarker) {
+			continue
+		}
+		if r.State != "APPROVED" && r.State != "CHANGES_REQUESTED" {
+			continue
+		}
+		if latest == nil || r.SubmittedAt.After(latest.SubmittedAt) {
+			latest = r
+		}
+	}
+	return latest, nil
+}
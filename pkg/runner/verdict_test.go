@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+func TestReviewVerdict(t *testing.T) {
+	major := []types.InlineComment{{File: "a.go", Line: 1, Severity: types.SeverityMajor}}
+	minor := []types.InlineComment{{File: "a.go", Line: 1, Severity: types.SeverityMinor}}
+
+	tests := []struct {
+		name     string
+		outcome  patchReview
+		comments []types.InlineComment
+		want     string
+	}{
+		{"clean", patchReview{result: diffReview{Chunks: 2}}, minor, github.VerdictApprove},
+		{"blocking", patchReview{result: diffReview{Chunks: 2}}, major, github.VerdictRequestChanges},
+		{"blocking and failed", patchReview{result: diffReview{Chunks: 2, Failed: 1}}, major, github.VerdictRequestChanges},
+		{"failed", patchReview{result: diffReview{Chunks: 2, Failed: 1}}, minor, github.VerdictComment},
+		{"declined", patchReview{result: diffReview{Chunks: 2, Declined: 1}}, nil, github.VerdictComment},
+		{"cleared by triage", patchReview{result: diffReview{Chunks: 2, Triaged: 2, Cleared: 1}}, nil, github.VerdictComment},
+		{"summary depth", patchReview{result: diffReview{Chunks: 1, Summaries: 1}}, nil, github.VerdictComment},
+		{"summarized files", patchReview{result: diffReview{Chunks: 1}, summarized: []string{"b.go"}}, nil, github.VerdictComment},
+		{"confidential files", patchReview{result: diffReview{Chunks: 1}, confidential: []string{"secret.go"}}, nil, github.VerdictComment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, summary := reviewVerdict(nil, tt.comments, reviewGap(tt.outcome))
+			if got != tt.want {
+				t.Errorf("verdict = %s (%q), want %s", got, summary, tt.want)
+			}
+		})
+	}
+}
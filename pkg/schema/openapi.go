@@ -0,0 +1,191 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// parseOpenAPI decodes an OpenAPI/Swagger document. YAML is a superset of
+// JSON so both formats go through the same decoder.
+func parseOpenAPI(src string) (map[string]interface{}, error) {
+	spec := map[string]interface{}{}
+	if strings.TrimSpace(src) == "" {
+		return spec, nil
+	}
+	if err := yaml.Unmarshal([]byte(src), &spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func compareOpenAPI(path string, before, after map[string]interface{}) []types.Finding {
+	var findings []types.Finding
+
+	oldPaths, newPaths := mapAt(before, "paths"), mapAt(after, "paths")
+	for _, route := range sortedKeys(oldPaths) {
+		newItem, ok := newPaths[route].(map[string]interface{})
+		if !ok {
+			findings = append(findings, finding(path, types.SeverityCritical, "path %s was removed", route))
+			continue
+		}
+		oldItem, _ := oldPaths[route].(map[string]interface{})
+		for _, method := range httpMethods {
+			oldOp, ok := oldItem[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			newOp, ok := newItem[method].(map[string]interface{})
+			if !ok {
+				findings = append(findings, finding(path, types.SeverityCritical,
+					"operation %s %s was removed", strings.ToUpper(method), route))
+				continue
+			}
+			op := fmt.Sprintf("%s %s", strings.ToUpper(method), route)
+			findings = append(findings, compareParameters(path, op, oldOp, newOp)...)
+		}
+	}
+
+	oldSchemas := mapAt(mapAt(before, "components"), "schemas")
+	newSchemas := mapAt(mapAt(after, "components"), "schemas")
+	if len(oldSchemas) == 0 && len(newSchemas) == 0 {
+		oldSchemas, newSchemas = mapAt(before, "definitions"), mapAt(after, "definitions")
+	}
+	for _, name := range sortedKeys(oldSchemas) {
+		newSchema, ok := newSchemas[name].(map[string]interface{})
+		if !ok {
+			findings = append(findings, finding(path, types.SeverityCritical, "schema %s was removed", name))
+			continue
+		}
+		oldSchema, _ := oldSchemas[name].(map[string]interface{})
+		findings = append(findings, compareSchema(path, name, oldSchema, newSchema)...)
+	}
+
+	return findings
+}
+
+func compareParameters(path, op string, oldOp, newOp map[string]interface{}) []types.Finding {
+	var findings []types.Finding
+	oldParams, newParams := parameters(oldOp), parameters(newOp)
+
+	for _, key := range sortedKeys(newParams) {
+		cur := newParams[key]
+		old, existed := oldParams[key]
+		switch {
+		case !existed && boolAt(cur, "required"):
+			findings = append(findings, finding(path, types.SeverityCritical,
+				"%s adds required parameter %s; existing clients do not send it", op, key))
+		case existed && !boolAt(old, "required") && boolAt(cur, "required"):
+			findings = append(findings, finding(path, types.SeverityCritical,
+				"%s parameter %s became required", op, key))
+		case existed && schemaType(mapAt(old, "schema")) != schemaType(mapAt(cur, "schema")):
+			findings = append(findings, finding(path, types.SeverityCritical,
+				"%s parameter %s changed type from %s to %s", op, key,
+				schemaType(mapAt(old, "schema")), schemaType(mapAt(cur, "schema"))))
+		}
+	}
+	for _, key := range sortedKeys(oldParams) {
+		if _, ok := newParams[key]; !ok {
+			findings = append(findings, finding(path, types.SeverityMajor,
+				"%s parameter %s was removed", op, key))
+		}
+	}
+
+	return findings
+}
+
+func compareSchema(path, name string, before, after map[string]interface{}) []types.Finding {
+	var findings []types.Finding
+
+	if oldType, newType := schemaType(before), schemaType(after); oldType != newType {
+		findings = append(findings, finding(path, types.SeverityCritical,
+			"schema %s changed type from %s to %s", name, oldType, newType))
+	}
+
+	oldProps, newProps := mapAt(before, "properties"), mapAt(after, "properties")
+	for _, prop := range sortedKeys(oldProps) {
+		newProp, ok := newProps[prop].(map[string]interface{})
+		if !ok {
+			findings = append(findings, finding(path, types.SeverityCritical,
+				"property %s.%s was removed", name, prop))
+			continue
+		}
+		oldProp, _ := oldProps[prop].(map[string]interface{})
+		if oldType, newType := schemaType(oldProp), schemaType(newProp); oldType != newType {
+			findings = append(findings, finding(path, types.SeverityCritical,
+				"property %s.%s changed type from %s to %s", name, prop, oldType, newType))
+		}
+	}
+
+	oldRequired := stringSet(before["required"])
+	for _, prop := range sortedKeys(stringSet(after["required"])) {
+		if !oldRequired[prop] {
+			findings = append(findings, finding(path, types.SeverityCritical,
+				"property %s.%s is now required", name, prop))
+		}
+	}
+
+	return findings
+}
+
+// parameters indexes an operation's parameters by "name (in)".
+func parameters(op map[string]interface{}) map[string]map[string]interface{} {
+	params := map[string]map[string]interface{}{}
+	list, _ := op["parameters"].([]interface{})
+	for _, p := range list {
+		param, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		if name == "" {
+			continue
+		}
+		params[fmt.Sprintf("%s (%s)", name, in)] = param
+	}
+	return params
+}
+
+// schemaType describes a schema's type, following $ref and array items.
+func schemaType(schema map[string]interface{}) string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return ref
+	}
+	t, _ := schema["type"].(string)
+	if t == "array" {
+		return "array<" + schemaType(mapAt(schema, "items")) + ">"
+	}
+	if f, ok := schema["format"].(string); ok && t != "" {
+		return t + "(" + f + ")"
+	}
+	if t == "" {
+		return "any"
+	}
+	return t
+}
+
+func mapAt(m map[string]interface{}, key string) map[string]interface{} {
+	v, _ := m[key].(map[string]interface{})
+	return v
+}
+
+func boolAt(m map[string]interface{}, key string) bool {
+	v, _ := m[key].(bool)
+	return v
+}
+
+func stringSet(v interface{}) map[string]bool {
+	set := map[string]bool{}
+	list, _ := v.([]interface{})
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			set[s] = true
+		}
+	}
+	return set
+}
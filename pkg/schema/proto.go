@@ -0,0 +1,236 @@
+package schema
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+var (
+	protoBlockRe       = regexp.MustCompile(`^(message|enum|service|oneof)\s+(\w+)\s*\{`)
+	protoFieldRe       = regexp.MustCompile(`^(?:(repeated|optional|required)\s+)?(map\s*<[^>]+>|[\w.]+)\s+(\w+)\s*=\s*(\d+)`)
+	protoEnumRe        = regexp.MustCompile(`^(\w+)\s*=\s*(-?\d+)`)
+	protoRPCRe         = regexp.MustCompile(`^rpc\s+(\w+)\s*\(\s*(stream\s+)?([\w.]+)\s*\)\s*returns\s*\(\s*(stream\s+)?([\w.]+)\s*\)`)
+	protoBlockComments = regexp.MustCompile(`(?s)/\*.*?\*/`)
+)
+
+type protoField struct {
+	Name  string
+	Type  string
+	Label string
+}
+
+type protoRPC struct {
+	Request  string
+	Response string
+}
+
+type protoFile struct {
+	Messages map[string]map[string]protoField // message -> field number -> field
+	Enums    map[string]map[string]string     // enum -> value number -> name
+	Services map[string]map[string]protoRPC   // service -> rpc name -> rpc
+}
+
+// parseProto extracts messages, enums, and services from a .proto source.
+// It is deliberately lenient: anything it does not recognise is ignored.
+func parseProto(src string) protoFile {
+	pf := protoFile{
+		Messages: map[string]map[string]protoField{},
+		Enums:    map[string]map[string]string{},
+		Services: map[string]map[string]protoRPC{},
+	}
+
+	type block struct{ kind, name string }
+	var stack []block
+	qualified := func(name string) string {
+		var parts []string
+		for _, b := range stack {
+			if b.kind == "message" || b.kind == "enum" {
+				parts = append(parts, b.name)
+			}
+		}
+		return strings.Join(append(parts, name), ".")
+	}
+	enclosing := func() (block, string) {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i].kind != "oneof" {
+				return stack[i], qualified("")
+			}
+		}
+		return block{}, ""
+	}
+
+	for _, stmt := range protoStatements(src) {
+		if stmt == "}" {
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		if m := protoBlockRe.FindStringSubmatch(stmt); m != nil {
+			name := qualified(m[2])
+			switch m[1] {
+			case "message":
+				pf.Messages[name] = map[string]protoField{}
+			case "enum":
+				pf.Enums[name] = map[string]string{}
+			case "service":
+				pf.Services[m[2]] = map[string]protoRPC{}
+			}
+			stack = append(stack, block{kind: m[1], name: m[2]})
+			continue
+		}
+		if strings.HasSuffix(stmt, "{") {
+			// Option blocks and rpc bodies: track nesting but ignore contents.
+			stack = append(stack, block{kind: "other"})
+			if m := protoRPCRe.FindStringSubmatch(stmt); m != nil && len(stack) > 1 && stack[len(stack)-2].kind == "service" {
+				pf.Services[stack[len(stack)-2].name][m[1]] = protoRPC{Request: m[2] + m[3], Response: m[4] + m[5]}
+			}
+			continue
+		}
+
+		parent, scope := enclosing()
+		scope = strings.TrimSuffix(scope, ".")
+		switch parent.kind {
+		case "message":
+			if m := protoFieldRe.FindStringSubmatch(stmt); m != nil && m[2] != "reserved" && m[2] != "option" {
+				pf.Messages[scope][m[4]] = protoField{Name: m[3], Type: strings.ReplaceAll(m[2], " ", ""), Label: m[1]}
+			}
+		case "enum":
+			if m := protoEnumRe.FindStringSubmatch(stmt); m != nil && m[1] != "option" {
+				pf.Enums[scope][m[2]] = m[1]
+			}
+		case "service":
+			if m := protoRPCRe.FindStringSubmatch(stmt); m != nil {
+				pf.Services[parent.name][m[1]] = protoRPC{Request: m[2] + m[3], Response: m[4] + m[5]}
+			}
+		}
+	}
+
+	return pf
+}
+
+// protoStatements strips comments and splits src into statements. Block
+// openers keep their trailing "{" and block closers are returned as "}".
+func protoStatements(src string) []string {
+	src = protoBlockComments.ReplaceAllString(src, "")
+	var cleaned strings.Builder
+	for _, line := range strings.Split(src, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		cleaned.WriteString(line)
+		cleaned.WriteString(" ")
+	}
+
+	var stmts []string
+	var cur strings.Builder
+	emit := func(suffix string) {
+		stmt := strings.Join(strings.Fields(cur.String()), " ")
+		cur.Reset()
+		if suffix == "{" {
+			stmts = append(stmts, stmt+" {")
+			return
+		}
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		if suffix == "}" {
+			stmts = append(stmts, "}")
+		}
+	}
+	for _, r := range cleaned.String() {
+		switch r {
+		case '{', '}', ';':
+			emit(string(r))
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	return stmts
+}
+
+func compareProto(path string, before, after protoFile) []types.Finding {
+	var findings []types.Finding
+
+	for _, msg := range sortedKeys(before.Messages) {
+		newFields, ok := after.Messages[msg]
+		if !ok {
+			findings = append(findings, finding(path, types.SeverityCritical, "message %s was removed", msg))
+			continue
+		}
+		oldFields := before.Messages[msg]
+		for _, num := range sortedKeys(oldFields) {
+			old := oldFields[num]
+			cur, ok := newFields[num]
+			switch {
+			case !ok:
+				findings = append(findings, finding(path, types.SeverityCritical,
+					"field %s.%s (#%s) was removed; existing clients may still send or expect it", msg, old.Name, num))
+			case cur.Type != old.Type:
+				findings = append(findings, finding(path, types.SeverityCritical,
+					"field %s.%s (#%s) changed type from %s to %s", msg, old.Name, num, old.Type, cur.Type))
+			case cur.Label != old.Label:
+				findings = append(findings, finding(path, types.SeverityCritical,
+					"field %s.%s (#%s) changed label from %q to %q", msg, old.Name, num, old.Label, cur.Label))
+			case cur.Name != old.Name:
+				findings = append(findings, finding(path, types.SeverityMajor,
+					"field %s #%s was renamed from %s to %s; this breaks JSON and text-format clients", msg, num, old.Name, cur.Name))
+			}
+		}
+		for _, num := range sortedKeys(newFields) {
+			if _, existed := oldFields[num]; !existed && newFields[num].Label == "required" {
+				findings = append(findings, finding(path, types.SeverityCritical,
+					"new required field %s.%s (#%s) will reject messages from existing clients", msg, newFields[num].Name, num))
+			}
+		}
+	}
+
+	for _, enum := range sortedKeys(before.Enums) {
+		newValues, ok := after.Enums[enum]
+		if !ok {
+			findings = append(findings, finding(path, types.SeverityCritical, "enum %s was removed", enum))
+			continue
+		}
+		for _, num := range sortedKeys(before.Enums[enum]) {
+			if _, ok := newValues[num]; !ok {
+				findings = append(findings, finding(path, types.SeverityCritical,
+					"enum value %s.%s (%s) was removed", enum, before.Enums[enum][num], num))
+			}
+		}
+	}
+
+	for _, svc := range sortedKeys(before.Services) {
+		newRPCs, ok := after.Services[svc]
+		if !ok {
+			findings = append(findings, finding(path, types.SeverityCritical, "service %s was removed", svc))
+			continue
+		}
+		for _, name := range sortedKeys(before.Services[svc]) {
+			old := before.Services[svc][name]
+			cur, ok := newRPCs[name]
+			switch {
+			case !ok:
+				findings = append(findings, finding(path, types.SeverityCritical, "rpc %s.%s was removed", svc, name))
+			case cur != old:
+				findings = append(findings, finding(path, types.SeverityCritical,
+					"rpc %s.%s signature changed from (%s) returns (%s) to (%s) returns (%s)",
+					svc, name, old.Request, old.Response, cur.Request, cur.Response))
+			}
+		}
+	}
+
+	return findings
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+const source = "schema"
+
+// IsSchemaFile reports whether path is a protobuf or OpenAPI definition.
+func IsSchemaFile(path string) bool {
+	return isProto(path) || isOpenAPI(path)
+}
+
+func isProto(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".proto")
+}
+
+func isOpenAPI(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	switch filepath.Ext(base) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return false
+	}
+	return strings.HasPrefix(base, "openapi") || strings.HasPrefix(base, "swagger")
+}
+
+// Compare performs a structural comparison of the before and after contents
+// of a schema file and returns findings describing client-visible changes.
+// Breaking changes are reported as critical findings.
+func Compare(path, before, after string) ([]types.Finding, error) {
+	switch {
+	case isProto(path):
+		return compareProto(path, parseProto(before), parseProto(after)), nil
+	case isOpenAPI(path):
+		oldSpec, err := parseOpenAPI(before)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse previous %s: %w", path, err)
+		}
+		newSpec, err := parseOpenAPI(after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse updated %s: %w", path, err)
+		}
+		return compareOpenAPI(path, oldSpec, newSpec), nil
+	}
+	return nil, fmt.Errorf("unsupported schema file: %s", path)
+}
+
+func finding(path string, severity types.Severity, format string, args ...interface{}) types.Finding {
+	return types.Finding{
+		File:     path,
+		Severity: severity,
+		Source:   source,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
@@ -0,0 +1,172 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+func TestCompare(t *testing.T) {
+	const proto = `syntax = "proto3";
+message User {
+  string name = 1;
+  int64 id = 2; // the key
+}
+enum Role {
+  ROLE_UNSPECIFIED = 0;
+  ROLE_ADMIN = 1;
+}
+service Users {
+  rpc Get(GetRequest) returns (User);
+}`
+	const openapi = `openapi: 3.0.0
+paths:
+  /users:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+components:
+  schemas:
+    User:
+      type: object
+      properties:
+        name:
+          type: string
+`
+
+	tests := []struct {
+		name   string
+		path   string
+		before string
+		after  string
+		want   []string
+		level  types.Severity
+		err    string
+	}{
+		{name: "proto unchanged", path: "api/user.proto", before: proto, after: proto},
+		{
+			name:   "proto field removed",
+			path:   "api/user.proto",
+			before: proto,
+			after:  strings.Replace(proto, "  int64 id = 2; // the key\n", "", 1),
+			want:   []string{"field User.id (#2) was removed"},
+			level:  types.SeverityCritical,
+		},
+		{
+			name:   "proto field type changed",
+			path:   "api/user.proto",
+			before: proto,
+			after:  strings.Replace(proto, "int64 id = 2", "string id = 2", 1),
+			want:   []string{"field User.id (#2) changed type from int64 to string"},
+			level:  types.SeverityCritical,
+		},
+		{
+			name:   "proto field renamed",
+			path:   "api/user.proto",
+			before: proto,
+			after:  strings.Replace(proto, "string name = 1", "string full_name = 1", 1),
+			want:   []string{"field User #1 was renamed from name to full_name"},
+			level:  types.SeverityMajor,
+		},
+		{
+			name:   "proto enum value removed",
+			path:   "api/user.proto",
+			before: proto,
+			after:  strings.Replace(proto, "  ROLE_ADMIN = 1;\n", "", 1),
+			want:   []string{"enum value Role.ROLE_ADMIN (1) was removed"},
+			level:  types.SeverityCritical,
+		},
+		{
+			name:   "proto rpc signature changed",
+			path:   "api/user.proto",
+			before: proto,
+			after:  strings.Replace(proto, "returns (User)", "returns (stream User)", 1),
+			want:   []string{"rpc Users.Get signature changed"},
+			level:  types.SeverityCritical,
+		},
+		{
+			name:   "proto field added",
+			path:   "api/user.proto",
+			before: proto,
+			after:  strings.Replace(proto, "  int64 id = 2;", "  int64 id = 2;\n  string email = 3;", 1),
+		},
+		{name: "openapi unchanged", path: "openapi.yaml", before: openapi, after: openapi},
+		{
+			name:   "openapi operation removed",
+			path:   "openapi.yaml",
+			before: openapi,
+			after:  strings.Replace(openapi, "    get:", "    post:", 1),
+			want:   []string{"operation GET /users was removed"},
+			level:  types.SeverityCritical,
+		},
+		{
+			name:   "openapi parameter became required",
+			path:   "openapi.yaml",
+			before: openapi,
+			after:  strings.Replace(openapi, "          in: query\n", "          in: query\n          required: true\n", 1),
+			want:   []string{"GET /users parameter limit (query) became required"},
+			level:  types.SeverityCritical,
+		},
+		{
+			name:   "openapi property type changed",
+			path:   "openapi.yaml",
+			before: openapi,
+			after:  strings.Replace(openapi, "          type: string", "          type: integer", 1),
+			want:   []string{"property User.name changed type from string to integer"},
+			level:  types.SeverityCritical,
+		},
+		{
+			name:   "openapi property now required",
+			path:   "openapi.yaml",
+			before: openapi,
+			after:  strings.Replace(openapi, "      type: object\n", "      type: object\n      required: [name]\n", 1),
+			want:   []string{"property User.name is now required"},
+			level:  types.SeverityCritical,
+		},
+		{name: "invalid openapi", path: "openapi.yaml", before: openapi, after: "paths: [", err: "failed to parse updated openapi.yaml"},
+		{name: "unsupported file", path: "schema.graphql", err: "unsupported schema file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := Compare(tt.path, tt.before, tt.after)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Compare() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(findings) != len(tt.want) {
+				t.Fatalf("Compare() = %v, want %d finding(s) %q", findings, len(tt.want), tt.want)
+			}
+			for i, f := range findings {
+				if !strings.HasPrefix(f.Message, tt.want[i]) || f.Severity != tt.level || f.File != tt.path {
+					t.Errorf("finding %d = %+v, want %s %q in %s", i, f, tt.level, tt.want[i], tt.path)
+				}
+			}
+		})
+	}
+}
+
+func TestIsSchemaFile(t *testing.T) {
+	tests := map[string]bool{
+		"api/user.proto":        true,
+		"api/USER.PROTO":        true,
+		"openapi.yaml":          true,
+		"docs/swagger.json":     true,
+		"openapi/components.md": false,
+		"config.yaml":           false,
+		"main.go":               false,
+	}
+	for path, want := range tests {
+		if got := IsSchemaFile(path); got != want {
+			t.Errorf("IsSchemaFile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("aws", newAWSSource)
+}
+
+// awsSource reads a secret from AWS Secrets Manager, assuming an IAM role
+// with the workflow's OIDC token.
+type awsSource struct {
+	cfg        Config
+	httpClient HTTPClient
+	now        func() time.Time
+}
+
+type awsCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+}
+
+func newAWSSource(cfg Config, httpClient HTTPClient) (Source, error) {
+	if cfg.AWSRoleARN == "" || cfg.AWSRegion == "" {
+		return nil, fmt.Errorf("aws secret source requires a role ARN and region")
+	}
+	if cfg.Audience == "" {
+		cfg.Audience = "sts.amazonaws.com"
+	}
+	return &awsSource{cfg: cfg, httpClient: httpClient, now: time.Now}, nil
+}
+
+func (s *awsSource) Fetch(ctx context.Context) (string, error) {
+	token, err := fetchOIDCToken(ctx, s.httpClient, s.cfg.Audience)
+	if err != nil {
+		return "", err
+	}
+	creds, err := s.assumeRole(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": s.cfg.SecretID})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	endpoint := fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", s.cfg.AWSRegion)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signV4(req, body, creds, s.cfg.AWSRegion, "secretsmanager", s.now().UTC())
+
+	var resp struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := doJSON(s.httpClient, req, &resp); err != nil {
+		return "", fmt.Errorf("failed to read secret from AWS Secrets Manager: %w", err)
+	}
+	return extractKey(resp.SecretString, s.cfg.SecretKey)
+}
+
+func (s *awsSource) assumeRole(ctx context.Context, token string) (awsCredentials, error) {
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {s.cfg.AWSRoleARN},
+		"RoleSessionName":  {"repo-ranger"},
+		"WebIdentityToken": {token},
+		"DurationSeconds":  {"900"},
+	}
+	endpoint := fmt.Sprintf("https://sts.%s.amazonaws.com/?%s", s.cfg.AWSRegion, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("AWS STS returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Credentials awsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to decode STS response: %w", err)
+	}
+	return result.Credentials, nil
+}
+
+// signV4 signs req with AWS Signature Version 4.
+func signV4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	var names []string
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+	req.Header.Del("Host")
+	req.Host = req.URL.Host
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
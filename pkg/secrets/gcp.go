@@ -0,0 +1,123 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	Register("gcp", newGCPSource)
+}
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// gcpSource reads a secret from GCP Secret Manager using workload identity
+// federation, optionally impersonating a service account.
+type gcpSource struct {
+	cfg        Config
+	httpClient HTTPClient
+}
+
+func newGCPSource(cfg Config, httpClient HTTPClient) (Source, error) {
+	if cfg.GCPWorkloadIdentityProvider == "" {
+		return nil, fmt.Errorf("gcp secret source requires a workload identity provider")
+	}
+	if cfg.Audience == "" {
+		cfg.Audience = "//iam.googleapis.com/" + strings.TrimPrefix(cfg.GCPWorkloadIdentityProvider, "//iam.googleapis.com/")
+	}
+	if !strings.Contains(cfg.SecretID, "/versions/") {
+		cfg.SecretID += "/versions/latest"
+	}
+	return &gcpSource{cfg: cfg, httpClient: httpClient}, nil
+}
+
+func (s *gcpSource) Fetch(ctx context.Context) (string, error) {
+	token, err := fetchOIDCToken(ctx, s.httpClient, s.cfg.Audience)
+	if err != nil {
+		return "", err
+	}
+	accessToken, err := s.exchangeToken(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	if s.cfg.GCPServiceAccount != "" {
+		if accessToken, err = s.impersonate(ctx, accessToken); err != nil {
+			return "", err
+		}
+	}
+
+	endpoint := fmt.Sprintf("https://secretmanager.googleapis.com/v1/%s:access", s.cfg.SecretID)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var resp struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := doJSON(s.httpClient, req, &resp); err != nil {
+		return "", fmt.Errorf("failed to read secret from GCP Secret Manager: %w", err)
+	}
+	value, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret payload: %w", err)
+	}
+	return extractKey(string(value), s.cfg.SecretKey)
+}
+
+// exchangeToken trades the OIDC token for a federated access token.
+func (s *gcpSource) exchangeToken(ctx context.Context, oidcToken string) (string, error) {
+	payload := map[string]string{
+		"audience":           s.cfg.Audience,
+		"grantType":          "urn:ietf:params:oauth:grant-type:token-exchange",
+		"requestedTokenType": "urn:ietf:params:oauth:token-type:access_token",
+		"scope":              cloudPlatformScope,
+		"subjectTokenType":   "urn:ietf:params:oauth:token-type:jwt",
+		"subjectToken":       oidcToken,
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := s.postJSON(ctx, "https://sts.googleapis.com/v1/token", "", payload, &resp); err != nil {
+		return "", fmt.Errorf("failed to exchange OIDC token with GCP STS: %w", err)
+	}
+	return resp.AccessToken, nil
+}
+
+// impersonate obtains an access token for the configured service account.
+func (s *gcpSource) impersonate(ctx context.Context, federatedToken string) (string, error) {
+	endpoint := fmt.Sprintf("https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken",
+		s.cfg.GCPServiceAccount)
+	payload := map[string][]string{"scope": {cloudPlatformScope}}
+	var resp struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := s.postJSON(ctx, endpoint, federatedToken, payload, &resp); err != nil {
+		return "", fmt.Errorf("failed to impersonate service account: %w", err)
+	}
+	return resp.AccessToken, nil
+}
+
+func (s *gcpSource) postJSON(ctx context.Context, endpoint, bearer string, payload, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	return doJSON(s.httpClient, req, out)
+}
@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Source fetches a secret value at runtime.
+type Source interface {
+	Fetch(ctx context.Context) (string, error)
+}
+
+// HTTPClient represents the interface for making HTTP requests.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Config holds the settings for every built-in source; each source reads
+// only the fields it needs.
+type Config struct {
+	SecretID  string // secret name, ARN, resource name, or path
+	SecretKey string // field to extract when the secret is a JSON object
+	Audience  string // OIDC audience; each source has a sensible default
+
+	AWSRoleARN string
+	AWSRegion  string
+
+	GCPWorkloadIdentityProvider string
+	GCPServiceAccount           string
+
+	VaultAddr      string
+	VaultRole      string
+	VaultAuthPath  string
+	VaultNamespace string
+}
+
+// Factory builds a source from configuration.
+type Factory func(cfg Config, httpClient HTTPClient) (Source, error)
+
+var factories = map[string]Factory{}
+
+// Register makes a source available under name. Built-in sources register
+// themselves; additional sources can be registered before calling New.
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// Names returns the registered source names.
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the named source.
+func New(name string, cfg Config, httpClient HTTPClient) (Source, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown secret source %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	if cfg.SecretID == "" {
+		return nil, fmt.Errorf("secret source %q requires a secret id", name)
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return factory(cfg, httpClient)
+}
+
+// fetchOIDCToken requests an ID token for the workflow from the GitHub
+// Actions token service. The job needs the id-token: write permission.
+func fetchOIDCToken(ctx context.Context, httpClient HTTPClient, audience string) (string, error) {
+	requestURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return "", fmt.Errorf("OIDC token unavailable; grant the workflow the id-token: write permission")
+	}
+
+	if audience != "" {
+		requestURL += "&audience=" + url.QueryEscape(audience)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create OIDC token request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+
+	var resp struct {
+		Value string `json:"value"`
+	}
+	if err := doJSON(httpClient, req, &resp); err != nil {
+		return "", fmt.Errorf("failed to fetch OIDC token: %w", err)
+	}
+	if resp.Value == "" {
+		return "", fmt.Errorf("OIDC token response was empty")
+	}
+	return resp.Value, nil
+}
+
+// doJSON sends req and decodes a JSON response into out.
+func doJSON(httpClient HTTPClient, req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d: %s", req.URL.Host, resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// extractKey returns value itself, or the named field when key is set and
+// value is a JSON object.
+func extractKey(value, key string) (string, error) {
+	if key == "" {
+		return value, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, cannot extract %q", key)
+	}
+	return fieldString(fields, key)
+}
+
+func fieldString(fields map[string]interface{}, key string) (string, error) {
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %q", key)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("secret field %q is not a string", key)
+	}
+	return s, nil
+}
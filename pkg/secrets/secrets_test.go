@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// routes is an HTTPClient answering each request URL, without its query,
+// with a canned status and body, and recording the requests.
+type routes struct {
+	responses map[string]string
+	requests  []*http.Request
+}
+
+func (r *routes) Do(req *http.Request) (*http.Response, error) {
+	r.requests = append(r.requests, req)
+	u := *req.URL
+	u.RawQuery = ""
+	body, ok := r.responses[req.Method+" "+u.String()]
+	status := http.StatusOK
+	if !ok {
+		status, body = http.StatusNotFound, "not found"
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		cfg    Config
+		err    string
+	}{
+		{name: "vault", source: "vault", cfg: Config{SecretID: "secret/app", VaultAddr: "https://vault", VaultRole: "ci"}},
+		{name: "unknown source", source: "keychain", cfg: Config{SecretID: "x"}, err: `unknown secret source "keychain" (available: aws, gcp, vault)`},
+		{name: "no secret id", source: "vault", cfg: Config{VaultAddr: "https://vault", VaultRole: "ci"}, err: "requires a secret id"},
+		{name: "vault without role", source: "vault", cfg: Config{SecretID: "secret/app", VaultAddr: "https://vault"}, err: "requires an address and role"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.source, tt.cfg, nil)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("New() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExtractKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		key   string
+		want  string
+		err   string
+	}{
+		{name: "plain value", value: "sk-123", want: "sk-123"},
+		{name: "field", value: `{"api_key": "sk-123", "other": "x"}`, key: "api_key", want: "sk-123"},
+		{name: "missing field", value: `{"other": "x"}`, key: "api_key", err: `no field "api_key"`},
+		{name: "not a string", value: `{"api_key": 123}`, key: "api_key", err: "is not a string"},
+		{name: "not JSON", value: "sk-123", key: "api_key", err: "not a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractKey(tt.value, tt.key)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("extractKey() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("extractKey() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultFetch(t *testing.T) {
+	const (
+		oidc  = "GET https://token.actions.example/oidc"
+		login = "POST https://vault.example/v1/auth/jwt/login"
+		read  = "GET https://vault.example/v1/secret/data/app"
+	)
+	tests := []struct {
+		name      string
+		oidcURL   string
+		responses map[string]string
+		want      string
+		err       string
+	}{
+		{
+			name:    "kv version 2",
+			oidcURL: "https://token.actions.example/oidc?api-version=2.0",
+			responses: map[string]string{
+				oidc:  `{"value": "id-token"}`,
+				login: `{"auth": {"client_token": "vault-token"}}`,
+				read:  `{"data": {"data": {"api_key": "sk-123"}}}`,
+			},
+			want: "sk-123",
+		},
+		{
+			name:    "kv version 1",
+			oidcURL: "https://token.actions.example/oidc?api-version=2.0",
+			responses: map[string]string{
+				oidc:  `{"value": "id-token"}`,
+				login: `{"auth": {"client_token": "vault-token"}}`,
+				read:  `{"data": {"api_key": "sk-123"}}`,
+			},
+			want: "sk-123",
+		},
+		{
+			name:    "login refused",
+			oidcURL: "https://token.actions.example/oidc?api-version=2.0",
+			responses: map[string]string{
+				oidc: `{"value": "id-token"}`,
+			},
+			err: "failed to log in to Vault",
+		},
+		{name: "no OIDC permission", err: "id-token: write"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", tt.oidcURL)
+			t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+			client := &routes{responses: tt.responses}
+			source, err := New("vault", Config{
+				SecretID:       "/secret/data/app",
+				VaultAddr:      "https://vault.example/",
+				VaultRole:      "ci",
+				VaultNamespace: "team",
+			}, client)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := source.Fetch(context.Background())
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Fetch() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("Fetch() = %q, %v, want %q", got, err, tt.want)
+			}
+			last := client.requests[len(client.requests)-1]
+			if last.Header.Get("X-Vault-Token") != "vault-token" || last.Header.Get("X-Vault-Namespace") != "team" {
+				t.Errorf("secret read with headers %v, want the client token and namespace", last.Header)
+			}
+		})
+	}
+}
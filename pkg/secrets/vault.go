@@ -0,0 +1,98 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	Register("vault", newVaultSource)
+}
+
+// vaultSource reads a secret from HashiCorp Vault after logging in through
+// the JWT auth method with the workflow's OIDC token.
+type vaultSource struct {
+	cfg        Config
+	httpClient HTTPClient
+}
+
+func newVaultSource(cfg Config, httpClient HTTPClient) (Source, error) {
+	if cfg.VaultAddr == "" || cfg.VaultRole == "" {
+		return nil, fmt.Errorf("vault secret source requires an address and role")
+	}
+	if cfg.VaultAuthPath == "" {
+		cfg.VaultAuthPath = "jwt"
+	}
+	if cfg.SecretKey == "" {
+		cfg.SecretKey = "api_key"
+	}
+	cfg.VaultAddr = strings.TrimRight(cfg.VaultAddr, "/")
+	return &vaultSource{cfg: cfg, httpClient: httpClient}, nil
+}
+
+func (s *vaultSource) Fetch(ctx context.Context) (string, error) {
+	token, err := fetchOIDCToken(ctx, s.httpClient, s.cfg.Audience)
+	if err != nil {
+		return "", err
+	}
+	clientToken, err := s.login(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s", s.cfg.VaultAddr, strings.TrimLeft(s.cfg.SecretID, "/"))
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	s.setHeaders(req)
+	req.Header.Set("X-Vault-Token", clientToken)
+
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := doJSON(s.httpClient, req, &resp); err != nil {
+		return "", fmt.Errorf("failed to read secret from Vault: %w", err)
+	}
+
+	// KV version 2 nests the secret under data.data.
+	fields := resp.Data
+	if nested, ok := fields["data"].(map[string]interface{}); ok {
+		fields = nested
+	}
+	return fieldString(fields, s.cfg.SecretKey)
+}
+
+func (s *vaultSource) login(ctx context.Context, oidcToken string) (string, error) {
+	body, err := json.Marshal(map[string]string{"role": s.cfg.VaultRole, "jwt": oidcToken})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	endpoint := fmt.Sprintf("%s/v1/auth/%s/login", s.cfg.VaultAddr, strings.Trim(s.cfg.VaultAuthPath, "/"))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	s.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := doJSON(s.httpClient, req, &resp); err != nil {
+		return "", fmt.Errorf("failed to log in to Vault: %w", err)
+	}
+	return resp.Auth.ClientToken, nil
+}
+
+func (s *vaultSource) setHeaders(req *http.Request) {
+	if s.cfg.VaultNamespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.cfg.VaultNamespace)
+	}
+}
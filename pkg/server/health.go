@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"time"
+)
+
+// readinessTTL is how long a readiness result is reused, so frequent probes
+// don't turn into a stream of requests to the model provider and GitHub.
+const readinessTTL = 10 * time.Second
+
+// checkTimeout bounds each readiness check.
+const checkTimeout = 5 * time.Second
+
+// CheckFunc reports whether a dependency is usable.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+type readinessCache struct {
+	mu      sync.Mutex
+	checked time.Time
+	results map[string]string
+	ready   bool
+}
+
+// handleHealth reports that the process is alive.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReady runs the readiness checks and reports 503 if any fails.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	ready, results := s.ready(r.Context())
+	status := "ok"
+	code := http.StatusOK
+	if !ready {
+		status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"checks": results,
+	})
+}
+
+// ready runs every check concurrently, reusing a recent result.
+func (s *Server) ready(ctx context.Context) (bool, map[string]string) {
+	c := &s.readiness
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checked) < readinessTTL && c.results != nil {
+		return c.ready, c.results
+	}
+
+	checks := append([]check{{name: "queue", fn: func(context.Context) error {
+		_, err := s.queue.Pending()
+		return err
+	}}}, s.checks...)
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	results := make(map[string]string, len(checks))
+	ready := true
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, ch := range checks {
+		wg.Add(1)
+		go func(ch check) {
+			defer wg.Done()
+			result := "ok"
+			if err := ch.fn(ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[ch.name] = result
+			if result != "ok" {
+				ready = false
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	c.checked, c.results, c.ready = time.Now(), results, ready
+	return ready, results
+}
+
+// HTTPClient represents the interface for making HTTP requests.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// HTTPCheck returns a check that requests url and passes when healthy
+// accepts the response status.
+func HTTPCheck(client HTTPClient, method, url string, header http.Header, healthy func(status int) bool) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return err
+		}
+		for name, values := range header {
+			for _, v := range values {
+				req.Header.Add(name, v)
+			}
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if !healthy(resp.StatusCode) {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// pprofHandler serves the net/http/pprof endpoints.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
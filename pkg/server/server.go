@@ -0,0 +1,382 @@
+// Package server receives GitHub webhooks and reviews pull requests from a
+// durable queue, for running repo-ranger as a long-lived service instead of
+// a GitHub Action.
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/queue"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxPayload bounds the size of an accepted webhook delivery.
+const maxPayload = 25 << 20
+
+// ReviewFunc reviews the pull request a queued webhook delivery refers to.
+type ReviewFunc func(ctx context.Context, job queue.Job) error
+
+// reviewedActions lists, per event, the actions that trigger a review.
+var reviewedActions = map[string][]string{
+	"pull_request":                {"opened", "synchronize", "reopened", "ready_for_review"},
+	"issue_comment":               {"created"},
+	"pull_request_review_comment": {"created"},
+	"check_run":                   {"requested_action"},
+}
+
+// Server accepts webhooks into a queue and runs reviews from it.
+type Server struct {
+	queue      *queue.Queue
+	review     ReviewFunc
+	secret     []byte
+	adminToken string
+	workers    int
+	pprofAddr  string
+	checks     []check
+	readiness  readinessCache
+	admit      AdmitFunc
+}
+
+// AdmitFunc decides whether a delivery is accepted into the queue. A
+// non-nil error rejects it with that reason.
+type AdmitFunc func(event string, payload []byte) error
+
+// Option is a function that configures a server.
+type Option func(*Server)
+
+// WithWebhookSecret verifies the X-Hub-Signature-256 header of every
+// delivery with secret.
+func WithWebhookSecret(secret string) Option {
+	return func(s *Server) {
+		s.secret = []byte(secret)
+	}
+}
+
+// WithAdminToken enables the /admin endpoints for requests bearing token.
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
+	}
+}
+
+// WithWorkers sets how many reviews run concurrently.
+func WithWorkers(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.workers = n
+		}
+	}
+}
+
+// WithAdmission rejects deliveries that admit refuses, such as those from
+// accounts the server does not serve.
+func WithAdmission(admit AdmitFunc) Option {
+	return func(s *Server) {
+		s.admit = admit
+	}
+}
+
+// WithPprof serves the net/http/pprof endpoints on a separate address,
+// such as localhost:6060, so profiles are never exposed with the webhook.
+func WithPprof(addr string) Option {
+	return func(s *Server) {
+		s.pprofAddr = addr
+	}
+}
+
+// WithReadinessCheck adds a dependency check to /readyz.
+func WithReadinessCheck(name string, fn CheckFunc) Option {
+	return func(s *Server) {
+		s.checks = append(s.checks, check{name: name, fn: fn})
+	}
+}
+
+// New creates a server that reviews jobs from q with review.
+func New(q *queue.Queue, review ReviewFunc, opts ...Option) *Server {
+	s := &Server{
+		queue:   q,
+		review:  review,
+		workers: 1,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Handler returns the server's HTTP routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", s.handleWebhook)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/readyz", s.handleReady)
+	mux.HandleFunc("/admin/queue", s.admin(s.handleQueue))
+	mux.HandleFunc("/admin/requeue", s.admin(s.handleRequeue))
+	return mux
+}
+
+// ListenAndServe serves on addr and runs the review workers until ctx is
+// done, then shuts down gracefully, letting running reviews finish.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		log.WithField("addr", addr).Info("Listening for webhooks")
+		errc <- srv.ListenAndServe()
+	}()
+	var debug *http.Server
+	if s.pprofAddr != "" {
+		debug = &http.Server{
+			Addr:              s.pprofAddr,
+			Handler:           pprofHandler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.WithField("addr", s.pprofAddr).Info("Serving pprof endpoints")
+			errc <- debug.ListenAndServe()
+		}()
+	}
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if serr := srv.Shutdown(shutdownCtx); err == nil {
+		err = serr
+	}
+	if debug != nil {
+		_ = debug.Shutdown(shutdownCtx)
+	}
+	wg.Wait()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// work runs queued reviews until ctx is done.
+func (s *Server) work(ctx context.Context) {
+	for {
+		job, err := s.queue.Claim(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("Failed to read from the review queue")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		entry := log.WithFields(log.Fields{
+			"delivery": job.ID,
+			"event":    job.Event,
+			"attempt":  job.Attempts + 1,
+		})
+		entry.Info("Starting queued review")
+		// Reviews run to completion on shutdown rather than being cut off
+		// mid-post; an interrupted one would be re-run on restart anyway.
+		if err := s.review(context.Background(), job); err != nil {
+			dead, qerr := s.queue.Fail(job, err)
+			switch {
+			case qerr != nil:
+				entry.WithError(qerr).Error("Failed to record review failure")
+			case dead:
+				entry.WithError(err).Error("Review failed on every attempt; moved to dead letters")
+			default:
+				entry.WithError(err).Warn("Review failed; it will be retried")
+			}
+			continue
+		}
+		if err := s.queue.Complete(job); err != nil {
+			entry.WithError(err).Error("Failed to remove completed review from the queue")
+			continue
+		}
+		entry.Info("Queued review finished")
+	}
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayload+1))
+	if err != nil || len(body) > maxPayload {
+		http.Error(w, "unreadable or oversized payload", http.StatusBadRequest)
+		return
+	}
+	if len(s.secret) > 0 && !validSignature(s.secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	delivery := r.Header.Get("X-GitHub-Delivery")
+	if event == "ping" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var payload struct {
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if !contains(reviewedActions[event], payload.Action) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if s.admit != nil {
+		if err := s.admit(event, body); err != nil {
+			log.WithError(err).WithField("delivery", delivery).Info("Rejected webhook delivery")
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	err = s.queue.Enqueue(queue.Job{ID: delivery, Event: event, Payload: body})
+	if err != nil {
+		log.WithError(err).WithField("delivery", delivery).Error("Failed to queue webhook delivery")
+		http.Error(w, "failed to queue delivery", http.StatusInternalServerError)
+		return
+	}
+	log.WithFields(log.Fields{
+		"delivery": delivery,
+		"event":    event,
+		"action":   payload.Action,
+	}).Info("Queued webhook delivery")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// admin guards an admin endpoint with the bearer token. Without a token
+// configured the endpoints are disabled.
+func (s *Server) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.adminToken == "" || !hmac.Equal([]byte(token), []byte(s.adminToken)) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// queueEntry summarizes a job for the admin listing; payloads are omitted.
+type queueEntry struct {
+	ID         string     `json:"id"`
+	Event      string     `json:"event"`
+	Attempts   int        `json:"attempts"`
+	LastError  string     `json:"last_error,omitempty"`
+	EnqueuedAt time.Time  `json:"enqueued_at"`
+	NotBefore  *time.Time `json:"not_before,omitempty"`
+}
+
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pending, err := s.queue.Pending()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dead, err := s.queue.DeadLetters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	summarize := func(jobs []queue.Job) []queueEntry {
+		entries := []queueEntry{}
+		for _, j := range jobs {
+			entry := queueEntry{
+				ID:         j.ID,
+				Event:      j.Event,
+				Attempts:   j.Attempts,
+				LastError:  j.LastError,
+				EnqueuedAt: j.EnqueuedAt,
+			}
+			if !j.NotBefore.IsZero() {
+				notBefore := j.NotBefore
+				entry.NotBefore = &notBefore
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]queueEntry{
+		"pending": summarize(pending),
+		"dead":    summarize(dead),
+	})
+}
+
+func (s *Server) handleRequeue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	switch err := s.queue.Requeue(id); {
+	case errors.Is(err, queue.ErrNotFound):
+		http.Error(w, fmt.Sprintf("no dead letter %q", id), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		log.WithField("delivery", id).Info("Requeued dead letter")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validSignature checks a "sha256=<hex>" webhook signature.
+func validSignature(secret, body []byte, signature string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/queue"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidSignature(t *testing.T) {
+	const body = `{"action":"opened"}`
+	tests := []struct {
+		name      string
+		signature string
+		want      bool
+	}{
+		{"valid", sign("secret", body), true},
+		{"other secret", sign("other", body), false},
+		{"other body", sign("secret", body+" "), false},
+		{"missing prefix", strings.TrimPrefix(sign("secret", body), "sha256="), false},
+		{"sha1", "sha1=" + strings.TrimPrefix(sign("secret", body), "sha256="), false},
+		{"not hex", "sha256=zz", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validSignature([]byte("secret"), []byte(body), tt.signature); got != tt.want {
+				t.Errorf("validSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	const opened = `{"action":"opened","repository":{"owner":{"login":"acme"}}}`
+	admitAcme := func(event string, payload []byte) error {
+		if !strings.Contains(string(payload), `"login":"acme"`) {
+			return errors.New("account is not served")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		event     string
+		body      string
+		signature string
+		status    int
+		queued    bool
+	}{
+		{name: "queued", event: "pull_request", body: opened, status: http.StatusAccepted, queued: true},
+		{name: "comment created", event: "issue_comment", body: `{"action":"created","repository":{"owner":{"login":"acme"}}}`, status: http.StatusAccepted, queued: true},
+		{name: "wrong method", method: http.MethodGet, event: "pull_request", body: opened, status: http.StatusMethodNotAllowed},
+		{name: "bad signature", event: "pull_request", body: opened, signature: sign("other", opened), status: http.StatusUnauthorized},
+		{name: "ping", event: "ping", body: `{}`, status: http.StatusOK},
+		{name: "invalid JSON", event: "pull_request", body: `{`, status: http.StatusBadRequest},
+		{name: "ignored action", event: "pull_request", body: `{"action":"closed","repository":{"owner":{"login":"acme"}}}`, status: http.StatusNoContent},
+		{name: "comment edited", event: "issue_comment", body: `{"action":"edited","repository":{"owner":{"login":"acme"}}}`, status: http.StatusNoContent},
+		{name: "ignored event", event: "push", body: `{"action":""}`, status: http.StatusNoContent},
+		{name: "not admitted", event: "pull_request", body: `{"action":"opened","repository":{"owner":{"login":"other"}}}`, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := queue.Open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := New(q, nil, WithWebhookSecret("secret"), WithAdmission(admitAcme))
+
+			method, signature := tt.method, tt.signature
+			if method == "" {
+				method = http.MethodPost
+			}
+			if signature == "" {
+				signature = sign("secret", tt.body)
+			}
+			req := httptest.NewRequest(method, "/webhook", strings.NewReader(tt.body))
+			req.Header.Set("X-GitHub-Event", tt.event)
+			req.Header.Set("X-GitHub-Delivery", "delivery-1")
+			req.Header.Set("X-Hub-Signature-256", signature)
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			pending, err := q.Pending()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if queued := len(pending) == 1 && pending[0].ID == "delivery-1"; queued != tt.queued {
+				t.Errorf("queued = %v (pending %d), want %v", queued, len(pending), tt.queued)
+			}
+		})
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	tests := []struct {
+		name          string
+		token, bearer string
+		status        int
+	}{
+		{"authorized", "admin", "admin", http.StatusOK},
+		{"wrong token", "admin", "guess", http.StatusUnauthorized},
+		{"no token sent", "admin", "", http.StatusUnauthorized},
+		{"disabled", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := queue.Open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := New(q, nil, WithAdminToken(tt.token))
+			req := httptest.NewRequest(http.MethodGet, "/admin/queue", nil)
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package spelling
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+const source = "spelling"
+
+//go:embed misspellings.txt
+var builtinMisspellings string
+
+var (
+	wordRe = regexp.MustCompile(`[A-Za-z][A-Za-z0-9_]*`)
+	urlRe  = regexp.MustCompile(`https?://\S+`)
+)
+
+// initialisms are words Go style expects to be written in a consistent case.
+var initialisms = map[string]string{
+	"api": "API", "dns": "DNS", "html": "HTML", "http": "HTTP", "https": "HTTPS",
+	"id": "ID", "ip": "IP", "json": "JSON", "sql": "SQL", "ssh": "SSH",
+	"tcp": "TCP", "tls": "TLS", "ui": "UI", "uri": "URI", "url": "URL",
+	"uuid": "UUID", "xml": "XML", "yaml": "YAML",
+}
+
+// Checker finds misspelled words and inconsistent naming in added lines.
+type Checker struct {
+	misspellings map[string]string
+	allowed      map[string]bool
+}
+
+// NewChecker creates a checker using the built-in dictionary.
+func NewChecker() *Checker {
+	c := &Checker{misspellings: map[string]string{}, allowed: map[string]bool{}}
+	c.addWords(builtinMisspellings)
+	return c
+}
+
+// LoadWordList extends the checker with a project word list. Each line is
+// either an allowed word that should never be flagged or a "wrong=right" pair
+// adding a project-specific misspelling. Lines starting with # are ignored.
+func (c *Checker) LoadWordList(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read word list: %w", err)
+	}
+	c.addWords(string(data))
+	return nil
+}
+
+func (c *Checker) addWords(list string) {
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if wrong, right, ok := strings.Cut(line, "="); ok {
+			c.misspellings[strings.ToLower(strings.TrimSpace(wrong))] = strings.TrimSpace(right)
+			continue
+		}
+		word := strings.ToLower(line)
+		c.allowed[word] = true
+		delete(c.misspellings, word)
+	}
+}
+
+// Check scans the added lines of the given files and returns nit-level
+// findings. Each distinct problem is reported once per file.
+func (c *Checker) Check(files []diff.FileDiff) []types.Finding {
+	var findings []types.Finding
+	for _, f := range files {
+		if f.IsDeleted || f.IsBinary {
+			continue
+		}
+		isGo := strings.HasSuffix(f.Path(), ".go")
+		seen := map[string]bool{}
+
+		for _, line := range f.AddedLines() {
+			content := urlRe.ReplaceAllString(line.Content, "")
+			for _, tok := range wordRe.FindAllString(content, -1) {
+				for _, word := range splitIdentifier(tok) {
+					lower := strings.ToLower(word)
+					if c.allowed[lower] {
+						continue
+					}
+					if right, ok := c.misspellings[lower]; ok && !seen["typo:"+lower] {
+						seen["typo:"+lower] = true
+						findings = append(findings, types.Finding{
+							File:     f.Path(),
+							Line:     line.NewLine,
+							Severity: types.SeverityNit,
+							Source:   source,
+							Message:  fmt.Sprintf("%q looks like a misspelling of %q", word, right),
+						})
+					}
+				}
+			}
+
+			if !isGo {
+				continue
+			}
+			for _, ident := range goIdentifiers(line.Content) {
+				if want, ok := inconsistentInitialism(ident); ok && !seen["name:"+ident] && !c.allowed[strings.ToLower(ident)] {
+					seen["name:"+ident] = true
+					findings = append(findings, types.Finding{
+						File:     f.Path(),
+						Line:     line.NewLine,
+						Severity: types.SeverityNit,
+						Source:   source,
+						Message:  fmt.Sprintf("identifier %s should be written %s to keep initialisms consistent", ident, want),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+// goIdentifiers returns the identifiers on a line of Go source, leaving out
+// string literals, struct tags, and comments, whose spelling is not the
+// code's to choose. The line is scanned on its own, so a line inside a
+// multi-line raw string is read as code.
+func goIdentifiers(line string) []string {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(line))
+	var s scanner.Scanner
+	s.Init(file, []byte(line), func(token.Position, string) {}, 0)
+
+	var idents []string
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return idents
+		}
+		if tok == token.IDENT {
+			idents = append(idents, lit)
+		}
+	}
+}
+
+// splitIdentifier breaks camelCase, PascalCase, and snake_case identifiers
+// into their component words.
+func splitIdentifier(ident string) []string {
+	var words []string
+	for _, part := range strings.Split(ident, "_") {
+		runes := []rune(part)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			boundary := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+				unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) ||
+				unicode.IsLetter(prev) != unicode.IsLetter(cur)
+			if boundary {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			words = append(words, string(runes[start:]))
+		}
+	}
+	return words
+}
+
+// inconsistentInitialism reports camel-cased identifiers that spell an
+// initialism in mixed case, such as userId or parseUrl, returning the
+// conventional spelling.
+func inconsistentInitialism(ident string) (string, bool) {
+	if strings.Contains(ident, "_") {
+		return "", false
+	}
+	words := splitIdentifier(ident)
+	if len(words) < 2 {
+		return "", false
+	}
+
+	changed := false
+	for i, w := range words {
+		want, ok := initialisms[strings.ToLower(w)]
+		if !ok || w == want || (i == 0 && w == strings.ToLower(w)) {
+			continue
+		}
+		words[i] = want
+		changed = true
+	}
+	if !changed {
+		return "", false
+	}
+	return strings.Join(words, ""), true
+}
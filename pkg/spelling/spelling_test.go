@@ -0,0 +1,47 @@
+package spelling
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+)
+
+func TestCheck(t *testing.T) {
+	added := func(path, line string) []diff.FileDiff {
+		return diff.Parse("diff --git a/" + path + " b/" + path + "\n--- a/" + path + "\n+++ b/" + path + "\n@@ -0,0 +1 @@\n+" + line)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		line string
+		want []string
+	}{
+		{"identifier", "user.go", "var userId int", []string{"identifier userId should be written userID"}},
+		{"selector", "user.go", "return req.parseUrl(s)", []string{"identifier parseUrl should be written parseURL"}},
+		{"struct tag", "user.go", "UserID int `json:\"userId\"`", nil},
+		{"string literal", "user.go", `key := "userId"`, nil},
+		{"raw string", "user.go", "query := `SELECT userId FROM users`", nil},
+		{"comment", "user.go", "x := 1 // set the userId", nil},
+		{"conventional", "user.go", "var userID, apiURL string", nil},
+		{"not Go", "user.js", "const userId = 1", nil},
+		{"misspelling in a string", "user.go", `msg := "wrong adress"`, []string{`"adress" looks like a misspelling of "address"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range NewChecker().Check(added(tt.path, tt.line)) {
+				got = append(got, f.Message)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Check() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("Check()[%d] = %q, want prefix %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// Package stack detects a project's languages and frameworks from the
+// manifests at the root of its repository, so prompts can tell the model
+// which idioms and conventions apply.
+package stack
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ReadFunc reads a file relative to the root of the repository. Any error
+// is treated as the file not existing.
+type ReadFunc func(path string) ([]byte, error)
+
+// Stack is what the manifests reveal about a project.
+type Stack struct {
+	// Languages are language names, with the version the project targets
+	// when the manifest states one, e.g. "Go 1.21".
+	Languages []string
+	// Frameworks are the notable frameworks and libraries it depends on.
+	Frameworks []string
+	// Manifests are the files the stack was detected from.
+	Manifests []string
+}
+
+// Empty reports whether nothing was detected.
+func (s Stack) Empty() bool {
+	return len(s.Languages) == 0 && len(s.Frameworks) == 0
+}
+
+// String renders the stack as a compact block for prompts.
+func (s Stack) String() string {
+	if s.Empty() {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Project context (detected from %s):\n", strings.Join(s.Manifests, ", ")))
+	if len(s.Languages) > 0 {
+		b.WriteString("- Languages: " + strings.Join(s.Languages, ", ") + "\n")
+	}
+	if len(s.Frameworks) > 0 {
+		b.WriteString("- Frameworks and libraries: " + strings.Join(s.Frameworks, ", ") + "\n")
+	}
+	b.WriteString("Follow the idioms of each file's own language and the conventions of these frameworks; ")
+	b.WriteString("do not suggest idioms from other languages.")
+	return b.String()
+}
+
+// dependency maps a dependency name, as it appears in a manifest, to the
+// framework it indicates.
+type dependency struct {
+	name      string
+	framework string
+}
+
+var (
+	goDependencies = []dependency{
+		{"github.com/gin-gonic/gin", "Gin"}, {"github.com/labstack/echo", "Echo"}, {"github.com/go-chi/chi", "chi"},
+		{"github.com/gofiber/fiber", "Fiber"}, {"github.com/gorilla/mux", "gorilla/mux"}, {"gorm.io/gorm", "GORM"},
+		{"github.com/jmoiron/sqlx", "sqlx"}, {"github.com/spf13/cobra", "Cobra"}, {"google.golang.org/grpc", "gRPC"},
+		{"github.com/sirupsen/logrus", "logrus"}, {"go.uber.org/zap", "zap"}, {"github.com/stretchr/testify", "testify"},
+		{"k8s.io/client-go", "client-go"}, {"sigs.k8s.io/controller-runtime", "controller-runtime"},
+	}
+	nodeDependencies = []dependency{
+		{"next", "Next.js"}, {"react", "React"}, {"nuxt", "Nuxt"}, {"vue", "Vue"}, {"@angular/core", "Angular"},
+		{"svelte", "Svelte"}, {"express", "Express"}, {"@nestjs/core", "NestJS"}, {"fastify", "Fastify"},
+		{"prisma", "Prisma"}, {"jest", "Jest"}, {"vitest", "Vitest"},
+	}
+	rustDependencies = []dependency{
+		{"tokio", "Tokio"}, {"actix-web", "Actix Web"}, {"axum", "axum"}, {"rocket", "Rocket"},
+		{"serde", "Serde"}, {"diesel", "Diesel"}, {"sqlx", "SQLx"},
+	}
+	pythonDependencies = []dependency{
+		{"django", "Django"}, {"flask", "Flask"}, {"fastapi", "FastAPI"}, {"pydantic", "Pydantic"},
+		{"sqlalchemy", "SQLAlchemy"}, {"pytest", "pytest"}, {"pandas", "pandas"},
+	}
+	rubyDependencies = []dependency{{"rails", "Rails"}, {"sinatra", "Sinatra"}, {"rspec", "RSpec"}}
+	jvmDependencies  = []dependency{{"spring-boot", "Spring Boot"}, {"quarkus", "Quarkus"}, {"micronaut", "Micronaut"}, {"junit", "JUnit"}}
+	phpDependencies  = []dependency{{"laravel/framework", "Laravel"}, {"symfony/", "Symfony"}, {"phpunit/phpunit", "PHPUnit"}}
+)
+
+var (
+	goVersion       = regexp.MustCompile(`(?m)^go\s+(\d+\.\d+)`)
+	rustEdition     = regexp.MustCompile(`(?m)^edition\s*=\s*"(\d+)"`)
+	pythonVersion   = regexp.MustCompile(`(?m)^requires-python\s*=\s*"([^"]+)"`)
+	rubyVersion     = regexp.MustCompile(`(?m)^ruby\s+["']([^"']+)["']`)
+	requirementName = regexp.MustCompile(`(?m)^\s*([A-Za-z0-9_.-]+)`)
+)
+
+// Detect reads the manifests at the root of a repository.
+func Detect(read ReadFunc) Stack {
+	var s Stack
+	var frameworks []string
+	file := func(path string) (string, bool) {
+		data, err := read(path)
+		if err != nil {
+			return "", false
+		}
+		s.Manifests = append(s.Manifests, path)
+		return string(data), true
+	}
+
+	if data, ok := file("go.mod"); ok {
+		s.Languages = append(s.Languages, versioned("Go", goVersion, data))
+		frameworks = append(frameworks, matchDependencies(goDependencies, func(name string) bool {
+			return strings.Contains(data, name)
+		})...)
+	}
+	if data, ok := file("package.json"); ok {
+		var manifest struct {
+			Dependencies    map[string]string `json:"dependencies"`
+			DevDependencies map[string]string `json:"devDependencies"`
+			Engines         struct {
+				Node string `json:"node"`
+			} `json:"engines"`
+		}
+		_ = json.Unmarshal([]byte(data), &manifest)
+		has := func(name string) bool {
+			_, dep := manifest.Dependencies[name]
+			_, dev := manifest.DevDependencies[name]
+			return dep || dev
+		}
+		if version, ok := manifest.DevDependencies["typescript"]; ok {
+			s.Languages = append(s.Languages, "TypeScript "+strings.TrimLeft(version, "^~>=v "))
+		} else if version, ok := manifest.Dependencies["typescript"]; ok {
+			s.Languages = append(s.Languages, "TypeScript "+strings.TrimLeft(version, "^~>=v "))
+		}
+		node := "JavaScript (Node.js)"
+		if manifest.Engines.Node != "" {
+			node = "JavaScript (Node.js " + manifest.Engines.Node + ")"
+		}
+		s.Languages = append(s.Languages, node)
+		frameworks = append(frameworks, matchDependencies(nodeDependencies, has)...)
+	}
+	if data, ok := file("Cargo.toml"); ok {
+		language := "Rust"
+		if m := rustEdition.FindStringSubmatch(data); m != nil {
+			language += " (" + m[1] + " edition)"
+		}
+		s.Languages = append(s.Languages, language)
+		frameworks = append(frameworks, matchDependencies(rustDependencies, tomlHas(data))...)
+	}
+
+	var python []string
+	if data, ok := file("pyproject.toml"); ok {
+		python = append(python, data)
+	}
+	if data, ok := file("requirements.txt"); ok {
+		for _, m := range requirementName.FindAllStringSubmatch(data, -1) {
+			python = append(python, m[1]+" = ")
+		}
+	}
+	if len(python) > 0 {
+		data := strings.ToLower(strings.Join(python, "\n"))
+		s.Languages = append(s.Languages, versioned("Python", pythonVersion, data))
+		frameworks = append(frameworks, matchDependencies(pythonDependencies, func(name string) bool {
+			return regexp.MustCompile(`(?m)(^|["'\s])` + regexp.QuoteMeta(name) + `\b`).MatchString(data)
+		})...)
+	}
+
+	if data, ok := file("Gemfile"); ok {
+		s.Languages = append(s.Languages, versioned("Ruby", rubyVersion, data))
+		frameworks = append(frameworks, matchDependencies(rubyDependencies, func(name string) bool {
+			return strings.Contains(data, `"`+name+`"`) || strings.Contains(data, `'`+name+`'`)
+		})...)
+	}
+	var jvm []string
+	for _, path := range []string{"pom.xml", "build.gradle", "build.gradle.kts"} {
+		if data, ok := file(path); ok {
+			jvm = append(jvm, data)
+		}
+	}
+	if len(jvm) > 0 {
+		data := strings.Join(jvm, "\n")
+		language := "Java"
+		if strings.Contains(data, "kotlin") {
+			language = "Kotlin"
+		}
+		s.Languages = append(s.Languages, language)
+		frameworks = append(frameworks, matchDependencies(jvmDependencies, func(name string) bool {
+			return strings.Contains(data, name)
+		})...)
+	}
+	if data, ok := file("composer.json"); ok {
+		s.Languages = append(s.Languages, "PHP")
+		frameworks = append(frameworks, matchDependencies(phpDependencies, func(name string) bool {
+			return strings.Contains(data, `"`+name)
+		})...)
+	}
+
+	s.Frameworks = dedupe(frameworks)
+	return s
+}
+
+// versioned names a language with the version pattern finds in data.
+func versioned(language string, pattern *regexp.Regexp, data string) string {
+	if m := pattern.FindStringSubmatch(data); m != nil {
+		return language + " " + m[1]
+	}
+	return language
+}
+
+// tomlHas reports whether a Cargo manifest declares a dependency.
+func tomlHas(data string) func(string) bool {
+	return func(name string) bool {
+		return regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(name) + `\s*=`).MatchString(data)
+	}
+}
+
+func matchDependencies(deps []dependency, has func(name string) bool) []string {
+	var found []string
+	for _, d := range deps {
+		if has(d.name) {
+			found = append(found, d.framework)
+		}
+	}
+	return found
+}
+
+func dedupe(values []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,124 @@
+package styleguide
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+const maxChunkSize = 8000 // maximum characters of guide text per summarization call
+
+// Summarizer condenses a project's style guides into a compact set of rules
+// that can be prepended to every review prompt.
+type Summarizer struct {
+	client   api.Client
+	model    string
+	cacheDir string
+}
+
+// NewSummarizer creates a summarizer. Summaries are cached in cacheDir,
+// keyed by the guide contents and model, when cacheDir is non-empty.
+func NewSummarizer(client api.Client, model, cacheDir string) *Summarizer {
+	return &Summarizer{client: client, model: model, cacheDir: cacheDir}
+}
+
+// Summarize reads the given guide files and returns their summary, using the
+// cache when the guides have not changed since the last run.
+func (s *Summarizer) Summarize(ctx context.Context, paths []string) (string, error) {
+	var guides strings.Builder
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return "", fmt.Errorf("failed to read style guide %s: %w", p, err)
+		}
+		guides.WriteString(fmt.Sprintf("# %s\n\n%s\n\n", p, data))
+	}
+
+	cachePath := s.cachePath(guides.String())
+	if cachePath != "" {
+		if cached, err := os.ReadFile(cachePath); err == nil {
+			log.WithField("cache", cachePath).Debug("Using cached style guide summary")
+			return string(cached), nil
+		}
+	}
+
+	var notes []string
+	for i, chunk := range splitParagraphs(guides.String(), maxChunkSize) {
+		log.WithField("chunk", i+1).Debug("Summarizing style guide chunk")
+		note, err := s.client.Review(ctx, s.model, buildChunkPrompt(chunk))
+		if err != nil {
+			return "", fmt.Errorf("failed to summarize style guide: %w", err)
+		}
+		notes = append(notes, strings.TrimSpace(note))
+	}
+
+	summary := strings.Join(notes, "\n")
+	if len(notes) > 1 {
+		merged, err := s.client.Review(ctx, s.model, buildMergePrompt(summary))
+		if err != nil {
+			return "", fmt.Errorf("failed to merge style guide summaries: %w", err)
+		}
+		summary = strings.TrimSpace(merged)
+	}
+
+	if cachePath != "" {
+		if err := os.MkdirAll(s.cacheDir, 0755); err == nil {
+			if err := os.WriteFile(cachePath, []byte(summary), 0644); err != nil {
+				log.WithError(err).Warn("Failed to cache style guide summary")
+			}
+		}
+	}
+	return summary, nil
+}
+
+func (s *Summarizer) cachePath(guides string) string {
+	if s.cacheDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s.model + "\x00" + guides))
+	return filepath.Join(s.cacheDir, "styleguide-"+hex.EncodeToString(sum[:8])+".md")
+}
+
+func buildChunkPrompt(chunk string) string {
+	var b strings.Builder
+	b.WriteString("The following is part of a project's contributing or style guide. ")
+	b.WriteString("Extract the concrete, checkable rules a code reviewer should enforce ")
+	b.WriteString("(naming, formatting, error handling, testing, documentation, architecture). ")
+	b.WriteString("Output a terse bulleted list with one rule per line and no commentary.\n\n")
+	b.WriteString(chunk)
+	return b.String()
+}
+
+func buildMergePrompt(notes string) string {
+	var b strings.Builder
+	b.WriteString("Merge the following bulleted style rules into a single deduplicated bulleted list. ")
+	b.WriteString("Keep every distinct rule, drop duplicates, and output only the list.\n\n")
+	b.WriteString(notes)
+	return b.String()
+}
+
+// splitParagraphs splits text on blank lines into chunks of at most max
+// characters. Paragraphs longer than max become their own chunk.
+func splitParagraphs(text string, max int) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, para := range strings.Split(text, "\n\n") {
+		if current.Len() > 0 && current.Len()+len(para)+2 > max {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		current.WriteString(para)
+		current.WriteString("\n\n")
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
@@ -0,0 +1,236 @@
+// Package tenant holds per-organization configuration for server mode, so
+// one deployment can serve several organizations with their own models,
+// policies, and credentials.
+package tenant
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OrgConfigPath is the file read from an organization's .github repository
+// when a tenant enables org_config.
+const OrgConfigPath = "repo-ranger.yml"
+
+// Tenant is the configuration of one organization or installation, read
+// from <name>.yml in the tenants directory. The directory belongs to the
+// operator, so it may name credentials.
+type Tenant struct {
+	Name string `yaml:"-"`
+	// Owners are the account logins the tenant covers; the file name is
+	// used when empty.
+	Owners []string `yaml:"owners"`
+	// Installations are GitHub App installation IDs the tenant covers.
+	Installations []int64 `yaml:"installations"`
+	// AllowedRepos are "owner/name" globs; when set, other repositories
+	// are not reviewed.
+	AllowedRepos []string `yaml:"allowed_repos"`
+	// APIKeyEnv and GitHubTokenEnv name the server environment variables
+	// holding the tenant's model API key and GitHub token.
+	APIKeyEnv      string `yaml:"api_key_env"`
+	GitHubTokenEnv string `yaml:"github_token_env"`
+	// Inputs override the server's INPUT_* settings, keyed by input name,
+	// e.g. model or review_depth.
+	Inputs map[string]string `yaml:"inputs"`
+	// MonthlyBudget caps what each of the tenant's repositories may spend
+	// per calendar month, in US dollars, overriding the server's default.
+	MonthlyBudget float64 `yaml:"monthly_budget"`
+	// OrgConfig also reads review preferences from repo-ranger.yml in the
+	// organization's .github repository. The tenant file takes precedence
+	// over them. The file's policies are enforced either way.
+	OrgConfig bool `yaml:"org_config"`
+}
+
+// Registry holds the configured tenants.
+type Registry struct {
+	tenants []Tenant
+}
+
+// LoadDir reads every *.yml and *.yaml file in dir.
+func LoadDir(dir string) (*Registry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants directory: %w", err)
+	}
+	r := &Registry{}
+	owners := map[string]string{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+		t, err := load(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		t.Name = strings.TrimSuffix(e.Name(), ext)
+		if len(t.Owners) == 0 && len(t.Installations) == 0 {
+			t.Owners = []string{t.Name}
+		}
+		for _, owner := range t.Owners {
+			key := strings.ToLower(owner)
+			if other, ok := owners[key]; ok {
+				return nil, fmt.Errorf("owner %q is claimed by both tenants %q and %q", owner, other, t.Name)
+			}
+			owners[key] = t.Name
+		}
+		r.tenants = append(r.tenants, t)
+	}
+	sort.Slice(r.tenants, func(i, j int) bool { return r.tenants[i].Name < r.tenants[j].Name })
+	return r, nil
+}
+
+func load(file string) (Tenant, error) {
+	var t Tenant
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return t, fmt.Errorf("failed to read tenant config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&t); err != nil && !errors.Is(err, io.EOF) {
+		return t, fmt.Errorf("invalid tenant config %s: %w", filepath.Base(file), err)
+	}
+	for _, pattern := range t.AllowedRepos {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return t, fmt.Errorf("invalid tenant config %s: bad allowed_repos glob %q", filepath.Base(file), pattern)
+		}
+	}
+	return t, nil
+}
+
+// Len returns the number of tenants.
+func (r *Registry) Len() int {
+	return len(r.tenants)
+}
+
+// Lookup finds the tenant for an installation ID or account login.
+// Installations take precedence, since an App can be installed on accounts
+// whose logins later change.
+func (r *Registry) Lookup(owner string, installation int64) (Tenant, bool) {
+	if installation != 0 {
+		for _, t := range r.tenants {
+			for _, id := range t.Installations {
+				if id == installation {
+					return t, true
+				}
+			}
+		}
+	}
+	for _, t := range r.tenants {
+		for _, o := range t.Owners {
+			if strings.EqualFold(o, owner) {
+				return t, true
+			}
+		}
+	}
+	return Tenant{}, false
+}
+
+// Allows reports whether the tenant reviews the repository ("owner/name").
+func (t Tenant) Allows(repo string) bool {
+	if len(t.AllowedRepos) == 0 {
+		return true
+	}
+	for _, pattern := range t.AllowedRepos {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(repo)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Env returns the INPUT_* variables that apply the tenant's settings on top
+// of orgInputs, which come from the organization's own configuration.
+func (t Tenant) Env(orgInputs map[string]string) map[string]string {
+	env := map[string]string{}
+	for name, value := range orgInputs {
+		env["INPUT_"+strings.ToUpper(name)] = value
+	}
+	for name, value := range t.Inputs {
+		env["INPUT_"+strings.ToUpper(name)] = value
+	}
+	if t.APIKeyEnv != "" {
+		env["INPUT_API_KEY"] = os.Getenv(t.APIKeyEnv)
+	}
+	if t.GitHubTokenEnv != "" {
+		env["INPUT_GITHUB_TOKEN"] = os.Getenv(t.GitHubTokenEnv)
+	}
+	return env
+}
+
+// orgInputs are the inputs an organization may set in its own .github
+// repository. Anything naming credentials, endpoints, commands, or local
+// paths is reserved for the operator.
+var orgInputs = map[string]bool{
+	"model":                  true,
+	"review_model":           true,
+	"triage_model":           true,
+	"profile":                true,
+	"review_depth":           true,
+	"focus":                  true,
+	"tone":                   true,
+	"min_severity":           true,
+	"min_confidence":         true,
+	"temperature":            true,
+	"max_tokens":             true,
+	"post_pr_comment":        true,
+	"comment_mode":           true,
+	"quiet":                  true,
+	"cc_owners":              true,
+	"quiet_hours":            true,
+	"quiet_hours_timezone":   true,
+	"quiet_hours_mode":       true,
+	"use_checks":             true,
+	"check_actions":          true,
+	"update_description":     true,
+	"track_findings":         true,
+	"checks_per_directory":   true,
+	"checks_directory_depth": true,
+	"inline_comments":        true,
+	"max_inline_comments":    true,
+	"file_comments":          true,
+	"submit_verdict":         true,
+	"resolve_threads":        true,
+	"annotations":            true,
+	"spelling_check":         true,
+	"pii_check":              true,
+	"api_doc_check":          true,
+	"i18n_check":             true,
+	"error_audit":            true,
+	"skip_patterns":          true,
+	"ignore_formatting":      true,
+	"rename_similarity":      true,
+}
+
+// ParseOrgConfig reads an organization's repo-ranger.yml, which has the
+// form "inputs: {name: value}". Inputs an organization may not set are
+// returned as ignored rather than failing the review.
+func ParseOrgConfig(data []byte) (inputs map[string]string, ignored []string, err error) {
+	var file struct {
+		Inputs map[string]string `yaml:"inputs"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", OrgConfigPath, err)
+	}
+	inputs = map[string]string{}
+	for name, value := range file.Inputs {
+		name = strings.ToLower(name)
+		if !orgInputs[name] {
+			ignored = append(ignored, name)
+			continue
+		}
+		inputs[name] = value
+	}
+	sort.Strings(ignored)
+	return inputs, ignored, nil
+}
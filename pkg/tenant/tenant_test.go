@@ -0,0 +1,171 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTenants writes tenant files to a new directory and returns it.
+func writeTenants(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  int
+		err   string
+	}{
+		{name: "tenants", files: map[string]string{"acme.yml": "owners: [acme]\n", "globex.yaml": "installations: [42]\n", "README.md": "ignored"}, want: 2},
+		{name: "empty file", files: map[string]string{"acme.yml": ""}, want: 1},
+		{name: "unknown field", files: map[string]string{"acme.yml": "ownres: [acme]\n"}, err: "invalid tenant config acme.yml"},
+		{name: "bad glob", files: map[string]string{"acme.yml": "allowed_repos: ['acme/[']\n"}, err: "bad allowed_repos glob"},
+		{name: "owner claimed twice", files: map[string]string{"a.yml": "owners: [Acme]\n", "b.yml": "owners: [acme]\n"}, err: "claimed by both tenants"},
+		{name: "owner claimed by file name", files: map[string]string{"acme.yml": "", "b.yml": "owners: [acme]\n"}, err: "claimed by both tenants"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := LoadDir(writeTenants(t, tt.files))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("LoadDir() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Len() != tt.want {
+				t.Errorf("Len() = %d, want %d", r.Len(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r, err := LoadDir(writeTenants(t, map[string]string{
+		"acme.yml":    "",
+		"globex.yml":  "owners: [globex, globex-labs]\n",
+		"initech.yml": "owners: [initech]\ninstallations: [42]\n",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		owner        string
+		installation int64
+		want         string
+	}{
+		{"owner from file name", "acme", 0, "acme"},
+		{"case-insensitive", "ACME", 0, "acme"},
+		{"second owner", "globex-labs", 0, "globex"},
+		{"installation", "", 42, "initech"},
+		{"installation over owner", "acme", 42, "initech"},
+		{"unknown installation falls back to owner", "globex", 7, "globex"},
+		{"unknown", "hooli", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := r.Lookup(tt.owner, tt.installation)
+			if ok != (tt.want != "") || got.Name != tt.want {
+				t.Errorf("Lookup(%q, %d) = %q, %v, want %q", tt.owner, tt.installation, got.Name, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		repo    string
+		want    bool
+	}{
+		{"no list", nil, "acme/app", true},
+		{"exact", []string{"acme/app"}, "acme/app", true},
+		{"glob", []string{"acme/svc-*"}, "acme/svc-billing", true},
+		{"case-insensitive", []string{"Acme/App"}, "acme/APP", true},
+		{"not listed", []string{"acme/svc-*"}, "acme/app", false},
+		{"glob stays in the owner", []string{"acme/*"}, "other/app", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Tenant{AllowedRepos: tt.allowed}).Allows(tt.repo); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.repo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("ACME_API_KEY", "sk-acme")
+	tenant := Tenant{
+		Inputs:    map[string]string{"model": "big", "review_depth": "deep"},
+		APIKeyEnv: "ACME_API_KEY",
+	}
+	env := tenant.Env(map[string]string{"model": "small", "tone": "kind"})
+
+	want := map[string]string{
+		"INPUT_MODEL":        "big",
+		"INPUT_REVIEW_DEPTH": "deep",
+		"INPUT_TONE":         "kind",
+		"INPUT_API_KEY":      "sk-acme",
+	}
+	if len(env) != len(want) {
+		t.Errorf("Env() = %v, want %v", env, want)
+	}
+	for name, value := range want {
+		if env[name] != value {
+			t.Errorf("Env()[%s] = %q, want %q", name, env[name], value)
+		}
+	}
+}
+
+func TestParseOrgConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		inputs  map[string]string
+		ignored []string
+		err     bool
+	}{
+		{name: "allowed", data: "inputs:\n  model: big\n  Review_Depth: deep\n", inputs: map[string]string{"model": "big", "review_depth": "deep"}},
+		{name: "reserved", data: "inputs:\n  model: big\n  api_url: https://evil.example\n  api_key: x\n", inputs: map[string]string{"model": "big"}, ignored: []string{"api_key", "api_url"}},
+		{name: "policies only", data: "policies:\n  max_temperature: 0.3\n", inputs: map[string]string{}},
+		{name: "invalid", data: "inputs: [", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs, ignored, err := ParseOrgConfig([]byte(tt.data))
+			if (err != nil) != tt.err {
+				t.Fatalf("ParseOrgConfig() error = %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			if len(inputs) != len(tt.inputs) {
+				t.Errorf("inputs = %v, want %v", inputs, tt.inputs)
+			}
+			for name, value := range tt.inputs {
+				if inputs[name] != value {
+					t.Errorf("inputs[%s] = %q, want %q", name, inputs[name], value)
+				}
+			}
+			if strings.Join(ignored, ",") != strings.Join(tt.ignored, ",") {
+				t.Errorf("ignored = %v, want %v", ignored, tt.ignored)
+			}
+		})
+	}
+}
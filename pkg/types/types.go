@@ -1,5 +1,13 @@
 package types
 
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // ReviewPayload is the JSON structure sent to the review API.
 type ReviewPayload struct {
 	Model  string `json:"model"`
@@ -15,14 +23,43 @@ type ReviewResponse struct {
 type OpenAIMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	Refusal string `json:"refusal,omitempty"` // set instead of Content when the model declines
+	// Parts, when set, are sent as the content instead of Content.
+	Parts []ContentPart `json:"-"`
+}
+
+// MarshalJSON sends the message's Parts as its content when it has any.
+func (m OpenAIMessage) MarshalJSON() ([]byte, error) {
+	type message OpenAIMessage
+	if len(m.Parts) == 0 {
+		return json.Marshal(message(m))
+	}
+	return json.Marshal(struct {
+		Role    string        `json:"role"`
+		Content []ContentPart `json:"content"`
+	}{m.Role, m.Parts})
+}
+
+// ContentPart is a text block of a message's content, for providers that
+// take the content as a list so parts of it can be marked for caching.
+type ContentPart struct {
+	Type         string        `json:"type"`
+	Text         string        `json:"text"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// CacheControl marks the end of a prompt prefix the provider should cache.
+type CacheControl struct {
+	Type string `json:"type"`
 }
 
 // OpenAIRequest represents the request structure for OpenAI's chat completion API
 type OpenAIRequest struct {
-	Model       string          `json:"model"`
-	Messages    []OpenAIMessage `json:"messages"`
-	Temperature float64         `json:"temperature,omitempty"`
-	MaxTokens   int            `json:"max_tokens,omitempty"`
+	Model               string          `json:"model"`
+	Messages            []OpenAIMessage `json:"messages"`
+	Temperature         float64         `json:"temperature,omitempty"`
+	MaxTokens           int             `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"` // used instead of MaxTokens by reasoning models
 }
 
 // OpenAIResponse represents the response structure from OpenAI's chat completion API
@@ -44,15 +81,52 @@ type Choice struct {
 
 // Usage represents token usage in the OpenAI response
 type Usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens        int                 `json:"prompt_tokens"`
+	CompletionTokens    int                 `json:"completion_tokens"`
+	TotalTokens         int                 `json:"total_tokens"`
+	PromptTokensDetails PromptTokensDetails `json:"prompt_tokens_details"`
+}
+
+// PromptTokensDetails breaks down the prompt tokens of a response.
+type PromptTokensDetails struct {
+	// CachedTokens were read from the provider's prompt cache, which bills
+	// them at a discount.
+	CachedTokens int `json:"cached_tokens"`
 }
 
 // PullRequestEvent is used to parse the GitHub event payload.
 type PullRequestEvent struct {
+	Action      string `json:"action"`
 	PullRequest struct {
-		Number int `json:"number"`
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Body   string `json:"body"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+		// State is open or closed.
+		State string `json:"state"`
+		Draft bool   `json:"draft"`
+		// Merged is set on closed events when the pull request was merged.
+		Merged    bool      `json:"merged"`
+		UpdatedAt time.Time `json:"updated_at"`
+		// MergeableState is "dirty" when the pull request conflicts with
+		// its base. GitHub only reports it when the pull request is fetched
+		// by itself.
+		MergeableState string `json:"mergeable_state"`
+		Head           struct {
+			SHA  string `json:"sha"`
+			Ref  string `json:"ref"`
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"head"`
+		Base struct {
+			Ref  string `json:"ref"`
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"base"`
 	} `json:"pull_request"`
 	Repository struct {
 		FullName string `json:"full_name"` // e.g., "owner/repo"
@@ -61,8 +135,218 @@ type PullRequestEvent struct {
 
 // InlineComment represents a structured inline review comment.
 type InlineComment struct {
-	File       string
-	Line       int
+	// ID is the short identifier of the finding, such as "RR-001", linking
+	// its comment to its entry in the summary.
+	ID   string
+	File string
+	Line int
+	// StartLine is the first line of a comment spanning several lines,
+	// ending at Line, or 0 for a comment on Line alone.
+	StartLine int
+	// Side is SideRight for comments on the new file and SideLeft for
+	// comments on removed code, whose Line is an old-file line number.
+	Side     string
+	Severity Severity
+	// Suggestion is the code replacing the commented lines, newlines
+	// included.
 	Suggestion string
 	Reasoning  string
+	// Confidence is how sure the model is of the finding, or nil when it
+	// did not say.
+	Confidence *Confidence
+	Category   Category
+	// Chunk is the correlation ID of the chunk of the diff whose review
+	// produced the comment, when known.
+	Chunk string
+}
+
+// FileComment is a review remark about a file as a whole, such as an
+// architectural concern that belongs on no single line.
+type FileComment struct {
+	File       string
+	Severity   Severity
+	Summary    string
+	Confidence *Confidence
+	Category   Category
+}
+
+// Sides of the diff an inline comment can be attached to.
+const (
+	SideLeft  = "LEFT"
+	SideRight = "RIGHT"
+)
+
+// Category is the kind of problem a finding is about.
+type Category string
+
+const (
+	CategoryBug             Category = "bug"
+	CategorySecurity        Category = "security"
+	CategoryPerformance     Category = "performance"
+	CategoryStyle           Category = "style"
+	CategoryTests           Category = "tests"
+	CategoryDocs            Category = "docs"
+	CategoryMaintainability Category = "maintainability"
+	CategoryPrivacy         Category = "privacy"
+)
+
+// Categories lists every category, in the order summaries show them.
+var Categories = []Category{
+	CategoryBug, CategorySecurity, CategoryPrivacy, CategoryPerformance, CategoryMaintainability,
+	CategoryTests, CategoryDocs, CategoryStyle,
+}
+
+// Valid reports whether c is one of Categories.
+func (c Category) Valid() bool {
+	for _, known := range Categories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryAliases map other names models use onto the categories.
+var categoryAliases = map[string]Category{
+	"correctness":   CategoryBug,
+	"bugs":          CategoryBug,
+	"perf":          CategoryPerformance,
+	"test":          CategoryTests,
+	"testing":       CategoryTests,
+	"doc":           CategoryDocs,
+	"documentation": CategoryDocs,
+	"readability":   CategoryMaintainability,
+	"design":        CategoryMaintainability,
+	"formatting":    CategoryStyle,
+	"pii":           CategoryPrivacy,
+}
+
+// ParseCategory reads a category, accepting common synonyms such as
+// "documentation" for docs.
+func ParseCategory(s string) (Category, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c := Category(s); c.Valid() {
+		return c, true
+	}
+	c, ok := categoryAliases[s]
+	return c, ok
+}
+
+// Confidence is the model's confidence in a finding, from 0 to 1.
+type Confidence float64
+
+// ParseConfidence reads a confidence given as a fraction ("0.8"), a
+// percentage ("80%"), or a word (high, medium, low).
+func ParseConfidence(s string) (Confidence, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "high":
+		return 0.9, true
+	case "medium":
+		return 0.6, true
+	case "low":
+		return 0.3, true
+	}
+	percent := strings.HasSuffix(s, "%")
+	v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	if percent || v > 1 {
+		v /= 100
+	}
+	if v > 1 {
+		return 0, false
+	}
+	return Confidence(v), true
+}
+
+// String formats the confidence as a whole percentage.
+func (c Confidence) String() string {
+	return strconv.Itoa(int(math.Round(float64(c)*100))) + "%"
+}
+
+// Severity ranks how serious a finding is.
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityMajor    Severity = "major"
+	SeverityMinor    Severity = "minor"
+	SeverityNit      Severity = "nit"
+)
+
+// Rank returns a sortable weight for the severity; higher is more severe.
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityCritical:
+		return 4
+	case SeverityMajor:
+		return 3
+	case SeverityMinor:
+		return 2
+	case SeverityNit:
+		return 1
+	}
+	return 0
+}
+
+// Badge returns the colored circle marking the severity in comments, or
+// "" for findings the model did not rate.
+func (s Severity) Badge() string {
+	switch s {
+	case SeverityCritical:
+		return "🔴"
+	case SeverityMajor:
+		return "🟠"
+	case SeverityMinor:
+		return "🟡"
+	case SeverityNit:
+		return "🔵"
+	}
+	return ""
+}
+
+// Finding is a deterministic review result produced without an LLM call.
+type Finding struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+	Severity Severity `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+	// Category is set by the checks whose findings belong to one.
+	Category Category `json:"category,omitempty"`
+}
+
+// IssueCommentEvent is used to parse issue_comment event payloads, which
+// carry slash commands left on pull requests. It also parses
+// pull_request_review_comment payloads, which identify the pull request at
+// the top level rather than through the issue.
+type IssueCommentEvent struct {
+	Action string `json:"action"`
+	Issue  struct {
+		Number      int `json:"number"`
+		PullRequest *struct {
+			URL string `json:"url"`
+		} `json:"pull_request"`
+	} `json:"issue"`
+	PullRequest struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Comment struct {
+		ID   int64  `json:"id"`
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		// AuthorAssociation is the commenter's relation to the repository,
+		// such as OWNER, MEMBER, COLLABORATOR, or CONTRIBUTOR.
+		AuthorAssociation string `json:"author_association"`
+		// InReplyToID is the comment a review comment replies to, which
+		// starts its thread.
+		InReplyToID int64 `json:"in_reply_to_id"`
+	} `json:"comment"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
 }
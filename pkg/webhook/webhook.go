@@ -0,0 +1,127 @@
+// Package webhook delivers run results to an HTTP endpoint, signed so the
+// receiver can verify they came from repo-ranger.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/postprocess"
+	"github.com/crazywolf132/repo-ranger/pkg/retry"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, keyed
+// with the shared secret and prefixed with "sha256=", like GitHub's own
+// webhook signatures.
+const SignatureHeader = "X-Repo-Ranger-Signature-256"
+
+// DefaultRetryPolicy is used unless the client is configured otherwise.
+var DefaultRetryPolicy = retry.Policy{
+	Attempts:  3,
+	BaseDelay: time.Second,
+	MaxDelay:  10 * time.Second,
+}
+
+// Result is the payload posted for each run.
+type Result struct {
+	postprocess.Document
+	Stats   Stats  `json:"stats"`
+	Verdict string `json:"verdict,omitempty"`
+	Review  string `json:"review"`
+}
+
+// Stats summarizes how much of the diff was reviewed.
+type Stats struct {
+	Files          int `json:"files"`
+	Chunks         int `json:"chunks"`
+	FailedChunks   int `json:"failed_chunks"`
+	DeclinedChunks int `json:"declined_chunks"`
+}
+
+// HTTPClient represents the interface for making HTTP requests.
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Client posts results to a single endpoint.
+type Client struct {
+	url        string
+	secret     []byte
+	httpClient HTTPClient
+	retry      retry.Policy
+}
+
+// ClientOption is a function that configures a client.
+type ClientOption func(*Client)
+
+// WithRetryPolicy sets the retry policy for deliveries.
+func WithRetryPolicy(p retry.Policy) ClientOption {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// NewClient creates a client posting to url. An empty secret sends
+// unsigned requests.
+func NewClient(url, secret string, httpClient HTTPClient, opts ...ClientOption) *Client {
+	c := &Client{
+		url:        url,
+		secret:     []byte(secret),
+		httpClient: httpClient,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Send posts the result.
+func (c *Client) Send(ctx context.Context, result Result) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	return retry.Do(ctx, c.retry, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(body))
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("failed to create request: %w", err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "repo-ranger")
+		if len(c.secret) > 0 {
+			req.Header.Set(SignatureHeader, Sign(c.secret, body))
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			err := fmt.Errorf("webhook returned status code %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+			if !c.retry.Retryable(resp.StatusCode) {
+				return retry.Permanent(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// Sign returns the signature header value for body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/retry"
+)
+
+// statuses is an HTTPClient answering successive requests with the given
+// status codes, recording each request and its body.
+type statuses struct {
+	codes  []int
+	reqs   []*http.Request
+	bodies []string
+}
+
+func (s *statuses) Do(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	s.reqs = append(s.reqs, req)
+	s.bodies = append(s.bodies, string(body))
+	code := s.codes[len(s.reqs)-1]
+	if code == 0 {
+		return nil, errors.New("connection refused")
+	}
+	return &http.Response{StatusCode: code, Body: io.NopCloser(strings.NewReader("busy"))}, nil
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		codes    []int
+		attempts int
+		err      string
+	}{
+		{name: "delivered", secret: "secret", codes: []int{http.StatusNoContent}, attempts: 1},
+		{name: "unsigned", codes: []int{http.StatusOK}, attempts: 1},
+		{name: "retried", secret: "secret", codes: []int{http.StatusServiceUnavailable, 0, http.StatusOK}, attempts: 3},
+		{name: "not retried", secret: "secret", codes: []int{http.StatusBadRequest}, attempts: 1, err: "status code 400: busy"},
+		{name: "gave up", secret: "secret", codes: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, attempts: 3, err: "status code 502"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &statuses{codes: tt.codes}
+			c := NewClient("https://hooks.example/ranger", tt.secret, client,
+				WithRetryPolicy(retry.Policy{Attempts: 3, BaseDelay: time.Millisecond}))
+			err := c.Send(context.Background(), Result{Verdict: "APPROVE", Review: "Looks good."})
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Send() error = %v, want %q", err, tt.err)
+			}
+			if len(client.reqs) != tt.attempts {
+				t.Fatalf("Send() made %d request(s), want %d", len(client.reqs), tt.attempts)
+			}
+			for i, req := range client.reqs {
+				want := ""
+				if tt.secret != "" {
+					want = Sign([]byte(tt.secret), []byte(client.bodies[i]))
+				}
+				if got := req.Header.Get(SignatureHeader); got != want {
+					t.Errorf("request %d signature = %q, want %q", i, got, want)
+				}
+				if !strings.Contains(client.bodies[i], `"verdict":"APPROVE"`) {
+					t.Errorf("request %d body = %s, want the result", i, client.bodies[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// The example from GitHub's webhook validation documentation.
+	got := Sign([]byte("It's a Secret to Everybody"), []byte("Hello, World!"))
+	const want = "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,279 @@
+// Package workload correlates Repo Ranger's reviews with how long pull
+// requests wait for human review and approval, to show where AI pre-review
+// saves reviewers time and which repositories it does not yet cover.
+package workload
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/repo-ranger/pkg/github"
+	"github.com/crazywolf132/repo-ranger/pkg/history"
+	"github.com/crazywolf132/repo-ranger/pkg/locale"
+)
+
+// PullRequest is the human review of one merged pull request.
+type PullRequest struct {
+	Repo   string
+	Number int
+	// Created, FirstReview, and Approved are when the pull request was
+	// opened, first reviewed by a person other than its author, and first
+	// approved; FirstReview and Approved are zero when that never happened.
+	Created     time.Time
+	FirstReview time.Time
+	Approved    time.Time
+	// ChangesRequested counts the reviews requesting changes.
+	ChangesRequested int
+	// PreReviewed reports that Repo Ranger reviewed the pull request, and
+	// Findings counts what it found.
+	PreReviewed bool
+	Findings    int
+}
+
+// Observe reads the human review of a pull request from its reviews,
+// ignoring bots and the author's own replies.
+func Observe(pr github.PullRequestActivity, reviews []github.Review) PullRequest {
+	out := PullRequest{Repo: pr.Repo, Number: pr.Number, Created: pr.CreatedAt}
+	sort.SliceStable(reviews, func(i, j int) bool { return reviews[i].SubmittedAt.Before(reviews[j].SubmittedAt) })
+	for _, r := range reviews {
+		if r.User.Type == "Bot" || r.User.Login == pr.Author.Login || r.SubmittedAt.IsZero() || r.State == "PENDING" {
+			continue
+		}
+		if out.FirstReview.IsZero() {
+			out.FirstReview = r.SubmittedAt
+		}
+		switch r.State {
+		case "APPROVED":
+			if out.Approved.IsZero() {
+				out.Approved = r.SubmittedAt
+			}
+		case "CHANGES_REQUESTED":
+			out.ChangesRequested++
+		}
+	}
+	return out
+}
+
+// Correlate marks the pull requests Repo Ranger reviewed, by the review
+// records of the history.
+func Correlate(prs []PullRequest, records []history.Record) {
+	findings := map[string]int{}
+	reviewed := map[string]bool{}
+	for _, r := range records {
+		if r.Kind != history.KindReview {
+			continue
+		}
+		key := fmt.Sprintf("%s#%d", strings.ToLower(r.Repo), r.PullRequest)
+		reviewed[key] = true
+		// Re-reviews repeat the findings; the latest review counts.
+		findings[key] = len(r.Findings)
+	}
+	for i, pr := range prs {
+		key := fmt.Sprintf("%s#%d", strings.ToLower(pr.Repo), pr.Number)
+		prs[i].PreReviewed = reviewed[key]
+		prs[i].Findings = findings[key]
+	}
+}
+
+// Cohort summarizes the human review of a group of pull requests.
+type Cohort struct {
+	PullRequests int
+	// FirstReview and Approval are the median waits from opening to the
+	// first human review and to approval, over the pull requests that got
+	// one, or 0 when none did.
+	FirstReview time.Duration
+	Approval    time.Duration
+	// ChangesRequested is the mean number of change requests per pull
+	// request, and Findings the mean number of Repo Ranger findings.
+	ChangesRequested float64
+	Findings         float64
+}
+
+func cohort(prs []PullRequest) Cohort {
+	c := Cohort{PullRequests: len(prs)}
+	if len(prs) == 0 {
+		return c
+	}
+	var firstReview, approval []time.Duration
+	requested, findings := 0, 0
+	for _, pr := range prs {
+		if !pr.FirstReview.IsZero() {
+			firstReview = append(firstReview, pr.FirstReview.Sub(pr.Created))
+		}
+		if !pr.Approved.IsZero() {
+			approval = append(approval, pr.Approved.Sub(pr.Created))
+		}
+		requested += pr.ChangesRequested
+		findings += pr.Findings
+	}
+	c.FirstReview, c.Approval = median(firstReview), median(approval)
+	c.ChangesRequested = float64(requested) / float64(len(prs))
+	c.Findings = float64(findings) / float64(len(prs))
+	return c
+}
+
+func median(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	mid := len(durations) / 2
+	if len(durations)%2 == 0 {
+		return (durations[mid-1] + durations[mid]) / 2
+	}
+	return durations[mid]
+}
+
+// Comparison sets the pull requests Repo Ranger reviewed against the rest.
+type Comparison struct {
+	PreReviewed Cohort
+	Others      Cohort
+}
+
+func compare(prs []PullRequest) Comparison {
+	var reviewed, others []PullRequest
+	for _, pr := range prs {
+		if pr.PreReviewed {
+			reviewed = append(reviewed, pr)
+		} else {
+			others = append(others, pr)
+		}
+	}
+	return Comparison{PreReviewed: cohort(reviewed), Others: cohort(others)}
+}
+
+// Coverage is the share of pull requests Repo Ranger reviewed, from 0 to 1.
+func (c Comparison) Coverage() float64 {
+	total := c.PreReviewed.PullRequests + c.Others.PullRequests
+	if total == 0 {
+		return 0
+	}
+	return float64(c.PreReviewed.PullRequests) / float64(total)
+}
+
+// ApprovalSaved is how much sooner pre-reviewed pull requests are approved
+// than the rest, by median, or a negative duration when they take longer.
+// It is 0 unless both groups have approved pull requests.
+func (c Comparison) ApprovalSaved() time.Duration {
+	if c.PreReviewed.Approval == 0 || c.Others.Approval == 0 {
+		return 0
+	}
+	return c.Others.Approval - c.PreReviewed.Approval
+}
+
+// Repo is the human review workload of one repository.
+type Repo struct {
+	Repo string
+	Comparison
+}
+
+// Report is the reviewer workload of an organization over a period.
+type Report struct {
+	From, To time.Time
+	// Title names whose workload this is, such as an organization.
+	Title   string
+	Overall Comparison
+	Repos   []Repo
+	// Locale writes the report's numbers and dates.
+	Locale locale.Locale
+}
+
+// Build compares the human review of the pull requests Repo Ranger
+// reviewed with the rest, overall and by repository, busiest first.
+func Build(prs []PullRequest, from, to time.Time) Report {
+	r := Report{From: from, To: to, Overall: compare(prs)}
+	byRepo := map[string][]PullRequest{}
+	for _, pr := range prs {
+		byRepo[pr.Repo] = append(byRepo[pr.Repo], pr)
+	}
+	for name, list := range byRepo {
+		r.Repos = append(r.Repos, Repo{Repo: name, Comparison: compare(list)})
+	}
+	sort.Slice(r.Repos, func(i, j int) bool {
+		a, b := r.Repos[i], r.Repos[j]
+		if n, m := a.PreReviewed.PullRequests+a.Others.PullRequests, b.PreReviewed.PullRequests+b.Others.PullRequests; n != m {
+			return n > m
+		}
+		return a.Repo < b.Repo
+	})
+	return r
+}
+
+// Render formats the report as Markdown.
+func (r Report) Render() string {
+	var b strings.Builder
+	l := r.Locale
+	title := "Reviewer workload"
+	if r.Title != "" {
+		title += ": " + r.Title
+	}
+	b.WriteString(fmt.Sprintf("# %s\n\n", title))
+	b.WriteString(fmt.Sprintf("Pull requests merged from %s to %s.\n\n", l.Date(r.From), l.Date(r.To.Add(-time.Second))))
+	total := r.Overall.PreReviewed.PullRequests + r.Overall.Others.PullRequests
+	if total == 0 {
+		b.WriteString("No pull requests were merged in this period.\n")
+		return b.String()
+	}
+	b.WriteString(fmt.Sprintf("Repo Ranger reviewed **%s of %s** (%s) across %s repositories.\n\n",
+		l.Int(r.Overall.PreReviewed.PullRequests), l.Int(total), l.Percent(r.Overall.Coverage()), l.Int(len(r.Repos))))
+
+	b.WriteString("| Pull requests | Count | Median to first review | Median to approval | Change requests per PR |\n")
+	b.WriteString("|---------------|-------|------------------------|--------------------|------------------------|\n")
+	for _, row := range []struct {
+		name string
+		c    Cohort
+	}{{"Pre-reviewed", r.Overall.PreReviewed}, {"Not pre-reviewed", r.Overall.Others}} {
+		b.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n", row.name, l.Int(row.c.PullRequests),
+			formatWait(l, row.c.FirstReview), formatWait(l, row.c.Approval), l.Number(row.c.ChangesRequested, 1)))
+	}
+	if saved := r.Overall.ApprovalSaved(); saved != 0 {
+		b.WriteString("\n" + savedSentence(l, saved) + "\n")
+	}
+
+	b.WriteString("\n## Where pre-review saves time\n\n")
+	b.WriteString("| Repository | Merged | Coverage | Findings per PR | Approval, pre-reviewed | Approval, others | Change requests, pre-reviewed / others |\n")
+	b.WriteString("|------------|--------|----------|-----------------|------------------------|------------------|----------------------------------------|\n")
+	var uncovered []Repo
+	for _, repo := range r.Repos {
+		if repo.PreReviewed.PullRequests == 0 {
+			uncovered = append(uncovered, repo)
+			continue
+		}
+		b.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s / %s |\n", repo.Repo,
+			l.Int(repo.PreReviewed.PullRequests+repo.Others.PullRequests), l.Percent(repo.Coverage()), l.Number(repo.PreReviewed.Findings, 1),
+			formatWait(l, repo.PreReviewed.Approval), formatWait(l, repo.Others.Approval),
+			l.Number(repo.PreReviewed.ChangesRequested, 1), l.Number(repo.Others.ChangesRequested, 1)))
+	}
+
+	if len(uncovered) > 0 {
+		b.WriteString("\n## Repositories without coverage\n\n")
+		b.WriteString("Repo Ranger reviewed none of the pull requests merged in these repositories:\n\n")
+		for _, repo := range uncovered {
+			b.WriteString(fmt.Sprintf("- %s: %s merged, median approval %s\n", repo.Repo, l.Int(repo.Others.PullRequests), formatWait(l, repo.Others.Approval)))
+		}
+	}
+	return b.String()
+}
+
+func savedSentence(l locale.Locale, saved time.Duration) string {
+	if saved > 0 {
+		return fmt.Sprintf("Pre-reviewed pull requests were approved **%s sooner** by median.", formatWait(l, saved))
+	}
+	return fmt.Sprintf("Pre-reviewed pull requests were approved %s later by median.", formatWait(l, -saved))
+}
+
+// formatWait renders a wait in the largest sensible unit, such as "45m",
+// "6.5h", or "2.3d".
+func formatWait(l locale.Locale, d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "–"
+	case d < time.Hour:
+		return l.Number(d.Minutes(), 0) + "m"
+	case d < 48*time.Hour:
+		return l.Number(d.Hours(), 1) + "h"
+	}
+	return l.Number(d.Hours()/24, 1) + "d"
+}
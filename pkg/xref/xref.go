@@ -0,0 +1,296 @@
+package xref
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+)
+
+const source = "xref"
+
+// SignatureChange describes an exported Go function or method whose
+// signature changed or which was removed.
+type SignatureChange struct {
+	File     string
+	Package  string // directory of the declaring package, relative to the repo root
+	Receiver string // empty for plain functions
+	Name     string
+	Before   string
+	After    string // empty if removed
+}
+
+// Qualified returns the display name of the symbol.
+func (c SignatureChange) Qualified() string {
+	if c.Receiver != "" {
+		return fmt.Sprintf("(%s).%s", c.Receiver, c.Name)
+	}
+	return c.Name
+}
+
+// Caller is a call site of a changed symbol that was not touched by the diff.
+type Caller struct {
+	File   string
+	Line   int
+	Change SignatureChange
+}
+
+type signature struct {
+	receiver string
+	name     string
+	text     string
+}
+
+// ChangedSignatures compares the exported functions of a Go file before and
+// after a change and returns those whose signatures changed or disappeared.
+func ChangedSignatures(file, before, after string) ([]SignatureChange, error) {
+	if before == "" {
+		return nil, nil
+	}
+	old, err := exportedSignatures(file, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse previous %s: %w", file, err)
+	}
+	cur := map[string]signature{}
+	if after != "" {
+		if cur, err = exportedSignatures(file, after); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+	}
+
+	var changes []SignatureChange
+	for key, sig := range old {
+		next, ok := cur[key]
+		if ok && next.text == sig.text {
+			continue
+		}
+		change := SignatureChange{
+			File:     file,
+			Package:  filepath.ToSlash(filepath.Dir(file)),
+			Receiver: sig.receiver,
+			Name:     sig.name,
+			Before:   sig.text,
+		}
+		if ok {
+			change.After = next.text
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Qualified() < changes[j].Qualified() })
+	return changes, nil
+}
+
+func exportedSignatures(file, src string) (map[string]signature, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := map[string]signature{}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || !fn.Name.IsExported() {
+			continue
+		}
+		recv := receiverName(fn)
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, fn.Type); err != nil {
+			return nil, err
+		}
+		text := strings.TrimPrefix(buf.String(), "func")
+		sigs[recv+"."+fn.Name.Name] = signature{receiver: recv, name: fn.Name.Name, text: fn.Name.Name + text}
+	}
+	return sigs, nil
+}
+
+func receiverName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	t := fn.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch x := t.(type) {
+	case *ast.IndexExpr:
+		t = x.X
+	case *ast.IndexListExpr:
+		t = x.X
+	}
+	if ident, ok := t.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// FindCallers walks the Go files under root and returns call sites of the
+// changed symbols whose lines were not touched by the diff. touched maps a
+// repo-relative file path to the new-file line numbers changed in it.
+func FindCallers(root string, changes []SignatureChange, touched map[string][]int) ([]Caller, error) {
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	modulePath := readModulePath(filepath.Join(root, "go.mod"))
+
+	var callers []Caller
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if p != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		found, err := callersInFile(p, rel, modulePath, changes, touched[rel])
+		if err != nil {
+			// Unparseable files are not the caller check's concern.
+			return nil
+		}
+		callers = append(callers, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan repository: %w", err)
+	}
+
+	sort.Slice(callers, func(i, j int) bool {
+		if callers[i].File != callers[j].File {
+			return callers[i].File < callers[j].File
+		}
+		return callers[i].Line < callers[j].Line
+	})
+	return callers, nil
+}
+
+func callersInFile(fullPath, rel, modulePath string, changes []SignatureChange, touched []int) ([]Caller, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fullPath, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := path.Dir(rel)
+	// Local names under which each changed package is imported in this file.
+	imported := map[string]string{}
+	for _, imp := range f.Imports {
+		importPath, _ := strconv.Unquote(imp.Path.Value)
+		for _, c := range changes {
+			if modulePath == "" || importPath != path.Join(modulePath, c.Package) {
+				continue
+			}
+			name := path.Base(importPath)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			imported[c.Package] = name
+		}
+	}
+
+	isTouched := func(line int) bool {
+		for _, l := range touched {
+			if l == line {
+				return true
+			}
+		}
+		return false
+	}
+
+	var callers []Caller
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		line := fset.Position(call.Pos()).Line
+		if isTouched(line) {
+			return true
+		}
+		for _, c := range changes {
+			samePackage := c.Package == dir
+			local, importsPkg := imported[c.Package]
+			match := false
+			switch fun := call.Fun.(type) {
+			case *ast.Ident:
+				match = samePackage && c.Receiver == "" && fun.Name == c.Name
+			case *ast.SelectorExpr:
+				if fun.Sel.Name != c.Name {
+					break
+				}
+				if c.Receiver != "" {
+					match = samePackage || importsPkg
+				} else if x, ok := fun.X.(*ast.Ident); ok {
+					match = importsPkg && x.Name == local
+				}
+			}
+			if match {
+				callers = append(callers, Caller{File: rel, Line: line, Change: c})
+			}
+		}
+		return true
+	})
+	return callers, nil
+}
+
+func readModulePath(goMod string) string {
+	f, err := os.Open(goMod)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module")), `"`)
+		}
+	}
+	return ""
+}
+
+// Findings converts call sites into major findings.
+func Findings(callers []Caller) []types.Finding {
+	var findings []types.Finding
+	for _, c := range callers {
+		msg := fmt.Sprintf("call to %s was not updated; its signature changed from `%s` to `%s`",
+			c.Change.Qualified(), c.Change.Before, c.Change.After)
+		if c.Change.After == "" {
+			msg = fmt.Sprintf("call to %s was not updated but the function was removed", c.Change.Qualified())
+		}
+		if c.Change.Receiver != "" {
+			msg += " (method match by name; verify the receiver type)"
+		}
+		findings = append(findings, types.Finding{
+			File:     c.File,
+			Line:     c.Line,
+			Severity: types.SeverityMajor,
+			Source:   source,
+			Message:  msg,
+		})
+	}
+	return findings
+}
@@ -0,0 +1,134 @@
+package xref
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChangedSignatures(t *testing.T) {
+	const before = `package a
+
+func Get(id string) error { return nil }
+func (c *Client) Do(n int) {}
+func (s Set[T]) Add(v T) {}
+func helper(x int) {}
+`
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []string
+	}{
+		{name: "unchanged", before: before, after: before},
+		{name: "new file", after: before},
+		{
+			name:   "parameter changed",
+			before: before,
+			after:  strings.Replace(before, "Get(id string)", "Get(id int)", 1),
+			want:   []string{"Get: Get(id string) error -> Get(id int) error"},
+		},
+		{
+			name:   "method removed",
+			before: before,
+			after:  strings.Replace(before, "func (c *Client) Do(n int) {}\n", "", 1),
+			want:   []string{"(Client).Do: Do(n int) -> "},
+		},
+		{
+			name:   "generic method changed",
+			before: before,
+			after:  strings.Replace(before, "Add(v T)", "Add(v ...T)", 1),
+			want:   []string{"(Set).Add: Add(v T) -> Add(v ...T)"},
+		},
+		{
+			name:   "unexported changed",
+			before: before,
+			after:  strings.Replace(before, "helper(x int)", "helper(x string)", 1),
+		},
+		{
+			name:   "file deleted",
+			before: "package a\n\nfunc Get() {}\n",
+			want:   []string{"Get: Get() -> "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := ChangedSignatures("pkg/a/a.go", tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range changes {
+				if c.Package != "pkg/a" {
+					t.Errorf("Package = %q, want pkg/a", c.Package)
+				}
+				got = append(got, c.Qualified()+": "+c.Before+" -> "+c.After)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("ChangedSignatures() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindCallers(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com/app\n\ngo 1.20\n",
+		"pkg/a/a.go": "package a\n\nfunc Get(id int) error { return nil }\n\nfunc use() { Get(1) }\n",
+		"main.go": `package main
+
+import "example.com/app/pkg/a"
+
+func main() {
+	a.Get(1)
+	a.Get(2)
+}
+`,
+		"cmd/tool/tool.go": `package main
+
+import alias "example.com/app/pkg/a"
+
+func main() { alias.Get(3) }
+`,
+		"cmd/other/other.go": `package main
+
+import "example.com/other/a"
+
+func main() { a.Get(4) }
+`,
+		"vendor/example.com/x/x.go": "package x\n\nimport \"example.com/app/pkg/a\"\n\nfunc X() { a.Get(5) }\n",
+		"broken.go":                 "package main\nfunc {",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes := []SignatureChange{{File: "pkg/a/a.go", Package: "pkg/a", Name: "Get", Before: "Get(id string) error", After: "Get(id int) error"}}
+	// The diff already updated the first call in main.go.
+	callers, err := FindCallers(root, changes, map[string][]int{"main.go": {6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range callers {
+		got = append(got, fmt.Sprintf("%s:%d", c.File, c.Line))
+	}
+	want := []string{"cmd/tool/tool.go:5", "main.go:7", "pkg/a/a.go:5"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("FindCallers() = %v, want %v", got, want)
+	}
+
+	findings := Findings(callers)
+	if len(findings) != len(want) || !strings.Contains(findings[0].Message, "signature changed from `Get(id string) error` to `Get(id int) error`") {
+		t.Errorf("Findings() = %+v", findings)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/crazywolf132/repo-ranger/pkg/api"
+	"github.com/crazywolf132/repo-ranger/pkg/config"
+	"github.com/crazywolf132/repo-ranger/pkg/diff"
+	"github.com/crazywolf132/repo-ranger/pkg/locale"
+	"github.com/crazywolf132/repo-ranger/pkg/runner"
+	"github.com/crazywolf132/repo-ranger/pkg/secrets"
+	"github.com/crazywolf132/repo-ranger/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// actionMetadata is the action definition, which lists every supported input.
+//
+//go:embed action.yml
+var actionMetadata []byte
+
+// Inputs that must parse as a particular type when set.
+var (
+	intInputs   = []string{"diff_timeout", "api_timeout", "max_inline_comments", "max_tokens", "settle_seconds", "checks_directory_depth", "rename_similarity", "token_budget", "churn_days", "chunk_overlap", "tracking_milestone", "merge_queue_timeout", "max_reviews_per_day"}
+	boolInputs  = []string{"post_pr_comment", "use_checks", "inline_comments", "spelling_check", "checks_per_directory", "resolve_threads", "submit_verdict", "file_comments", "annotations", "stack_context", "check_actions", "update_description", "track_findings", "quiet", "preflight", "cc_owners", "pii_check", "suggestion_patch", "api_doc_check", "i18n_check", "error_audit"}
+	floatInputs = []string{"temperature", "max_cost_per_run", "min_confidence"}
+)
+
+// validateConfiguration checks the INPUT_* environment and the repository
+// configuration file, returning every problem found. The model settings
+// are only required when requireModel is set.
+func validateConfiguration(configFile string, requireModel bool) []config.Problem {
+	problems := config.Validate(configFile)
+	return append(problems, validateInputs(requireModel)...)
+}
+
+// validateInputs checks the INPUT_* environment variables for unknown
+// names, malformed values, and conflicting options.
+func validateInputs(requireModel bool) []config.Problem {
+	var problems []config.Problem
+	add := func(input, message string, warning bool) {
+		problems = append(problems, config.Problem{Field: "INPUT_" + strings.ToUpper(input), Message: message, Warning: warning})
+	}
+	input := func(name string) string {
+		return strings.TrimSpace(os.Getenv("INPUT_" + strings.ToUpper(name)))
+	}
+
+	known := knownInputs()
+	var unknown []string
+	for _, kv := range os.Environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if !strings.HasPrefix(name, "INPUT_") {
+			continue
+		}
+		if !known[strings.ToLower(strings.TrimPrefix(name, "INPUT_"))] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, config.Problem{Field: name, Message: "unknown input; it is ignored (check the spelling against action.yml)", Warning: true})
+	}
+
+	for _, name := range intInputs {
+		if v := input(name); v != "" {
+			if _, err := strconv.Atoi(v); err != nil {
+				add(name, fmt.Sprintf("%q is not a whole number", v), false)
+			}
+		}
+	}
+	for _, name := range boolInputs {
+		if v := input(name); v != "" {
+			if _, err := strconv.ParseBool(v); err != nil {
+				add(name, fmt.Sprintf("%q is not true or false", v), false)
+			}
+		}
+	}
+	for _, name := range floatInputs {
+		if v := input(name); v != "" {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				add(name, fmt.Sprintf("%q is not a number", v), false)
+			}
+		}
+	}
+
+	model := input("model")
+	if reviewModel := input("review_model"); reviewModel != "" {
+		model = reviewModel
+	}
+	if input("api_url") == "" && requireModel {
+		add("api_url", "required; set it to your provider's chat completions endpoint, e.g. https://api.openai.com/v1/chat/completions", false)
+	}
+	if model == "" && requireModel {
+		add("model", "required; set it to the model to review with, e.g. gpt-4o", false)
+	} else if !api.KnownModel(model) {
+		if _, ok := overriddenModels()[model]; !ok {
+			add("model", fmt.Sprintf("%q is not in the built-in capability table; requests assume an 8192-token context window and chat-style parameters. Describe it with INPUT_MODEL_CAPABILITIES if that is wrong", model), true)
+		}
+	}
+	if triage := input("triage_model"); triage != "" {
+		switch {
+		case triage == model:
+			add("triage_model", "is the review model, so triage only adds requests; set it to a cheaper model", true)
+		case strings.ToLower(input("review_depth")) == runner.DepthSummary:
+			add("triage_model", "has no effect with INPUT_REVIEW_DEPTH summary, which reviews the diff in one request", true)
+		case !api.KnownModel(triage):
+			if _, ok := overriddenModels()[triage]; !ok {
+				add("triage_model", fmt.Sprintf("%q is not in the built-in capability table, so the savings it reports cannot be priced. Describe it with INPUT_MODEL_CAPABILITIES", triage), true)
+			}
+		}
+	}
+
+	source := input("api_key_source")
+	switch {
+	case source == "" && input("api_key") == "" && requireModel:
+		add("api_key", "required unless INPUT_API_KEY_SOURCE fetches the key from a secret manager", false)
+	case source != "" && !contains(secrets.Names(), source):
+		add("api_key_source", fmt.Sprintf("unknown source %q; use one of %s", source, strings.Join(secrets.Names(), ", ")), false)
+	case source != "" && input("secret_id") == "":
+		add("secret_id", fmt.Sprintf("required when INPUT_API_KEY_SOURCE is %q", source), false)
+	case source != "" && input("api_key") != "":
+		add("api_key", "ignored because INPUT_API_KEY_SOURCE is set", true)
+	}
+
+	if v := input("model_capabilities"); v != "" {
+		var overrides map[string]api.CapabilityOverride
+		if err := json.Unmarshal([]byte(v), &overrides); err != nil {
+			add("model_capabilities", fmt.Sprintf("invalid JSON object keyed by model name: %v", err), false)
+		}
+		for name, o := range overrides {
+			if o.PromptCache != nil && *o.PromptCache != api.PromptCacheAutomatic && *o.PromptCache != api.PromptCacheControl {
+				add("model_capabilities", fmt.Sprintf("unknown prompt_cache %q for %s; use %s or %s", *o.PromptCache, name, api.PromptCacheAutomatic, api.PromptCacheControl), false)
+			}
+		}
+	}
+	if v := input("extra_headers"); v != "" {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(v), &headers); err != nil {
+			add("extra_headers", fmt.Sprintf("invalid JSON object of header names to values: %v", err), false)
+		}
+	}
+
+	switch depth := strings.ToLower(input("review_depth")); depth {
+	case "", runner.DepthSummary, runner.DepthStandard, runner.DepthDeep:
+	default:
+		add("review_depth", fmt.Sprintf("unknown depth %q; use summary, standard, or deep", depth), false)
+	}
+	if name := input("profile"); name != "" {
+		if _, ok := config.LookupProfile(name); !ok {
+			add("profile", fmt.Sprintf("unknown profile %q; use one of %s", name, strings.Join(config.ProfileNames(), ", ")), false)
+		}
+	}
+	switch severity := types.Severity(strings.ToLower(input("min_severity"))); {
+	case severity == "", severity.Rank() > 0:
+	default:
+		add("min_severity", fmt.Sprintf("unknown severity %q; use critical, major, minor, or nit", severity), false)
+	}
+	for _, detector := range strings.FieldsFunc(input("ignore_formatting"), func(r rune) bool { return r == ',' || r == '\n' }) {
+		switch detector = strings.TrimSpace(detector); detector {
+		case "", "none", diff.DetectWhitespace, diff.DetectImports, diff.DetectFormatter:
+		default:
+			add("ignore_formatting", fmt.Sprintf("unknown detector %q; use whitespace, imports, formatter, or none", detector), false)
+		}
+	}
+	switch target := strings.ToLower(input("long_review_target")); target {
+	case "", runner.LongReviewTruncate, runner.LongReviewWiki:
+	case runner.LongReviewGist:
+		if input("gist_token") == "" {
+			add("gist_token", "required when INPUT_LONG_REVIEW_TARGET is gist; long reviews are truncated instead", true)
+		}
+	default:
+		add("long_review_target", fmt.Sprintf("unknown target %q; use truncate, gist, or wiki", target), false)
+	}
+	switch mode := strings.ToLower(input("comment_mode")); mode {
+	case "", runner.CommentAppend, runner.CommentUpdate:
+	default:
+		add("comment_mode", fmt.Sprintf("unknown mode %q; use append or update", mode), false)
+	}
+	if _, err := runner.ParseQuietHours(input("quiet_hours"), input("quiet_hours_timezone")); err != nil {
+		add("quiet_hours", err.Error(), false)
+	}
+	if _, err := locale.Parse(input("locale"), ""); err != nil {
+		add("locale", err.Error(), false)
+	}
+	if _, err := locale.Parse("", input("timezone")); err != nil {
+		add("timezone", err.Error(), false)
+	}
+	if _, err := runner.LoadCommentTemplate(input("comment_template")); err != nil {
+		add("comment_template", err.Error(), false)
+	}
+	if on, _ := strconv.ParseBool(input("suggestion_patch")); on && input("review_bundle") == "" {
+		add("suggestion_patch", "the patch is written to the review bundle, but INPUT_REVIEW_BUNDLE is not set; it is only written on forked pull requests", true)
+	}
+	if n, _ := strconv.Atoi(input("max_reviews_per_day")); n > 0 {
+		// The count is saved in the summary, which these leave unposted.
+		if post, err := strconv.ParseBool(input("post_pr_comment")); err == nil && !post {
+			add("max_reviews_per_day", "the daily review count is kept in the summary comment, so INPUT_POST_PR_COMMENT must be true", false)
+		}
+		if input("quiet_hours") != "" {
+			add("max_reviews_per_day", "reviews published during quiet hours are not counted, so the limit cannot be combined with INPUT_QUIET_HOURS", false)
+		}
+	}
+	switch mode := strings.ToLower(input("quiet_hours_mode")); mode {
+	case "", runner.QuietChecks:
+	case runner.QuietDefer:
+		if input("review_bundle") == "" {
+			add("quiet_hours_mode", "defer holds reviews in the review bundle, but INPUT_REVIEW_BUNDLE is not set; reviews in quiet hours are reported with check runs instead", true)
+		}
+	default:
+		add("quiet_hours_mode", fmt.Sprintf("unknown mode %q; use checks or defer", mode), false)
+	}
+	switch mode := input("diff_mode"); mode {
+	case "", "shell", "go-git":
+	default:
+		add("diff_mode", fmt.Sprintf("unknown mode %q; use shell or go-git", mode), false)
+	}
+	switch shell := input("diff_shell"); shell {
+	case diff.ShellAuto, diff.ShellSh, diff.ShellBash, diff.ShellCmd, diff.ShellPowerShell, diff.ShellPwsh, diff.ShellNone:
+	default:
+		add("diff_shell", fmt.Sprintf("unknown shell %q; use sh, bash, cmd, powershell, pwsh, or none", shell), false)
+	}
+
+	if input("diff_file") != "" && input("diff_mode") == "go-git" {
+		add("diff_mode", "ignored because INPUT_DIFF_FILE is set", true)
+	}
+	if v, err := strconv.ParseFloat(input("max_cost_per_run"), 64); err == nil && v < 0 {
+		add("max_cost_per_run", "must not be negative; use 0 for no cap", false)
+	}
+	if v, err := strconv.ParseFloat(input("min_confidence"), 64); err == nil && (v < 0 || v > 1) {
+		add("min_confidence", "must be between 0 and 1", false)
+	}
+	if v := input("statsd_addr"); v != "" {
+		if _, _, err := net.SplitHostPort(v); err != nil {
+			add("statsd_addr", fmt.Sprintf("%q is not a host:port address", v), false)
+		}
+	}
+	if v := input("results_webhook"); v != "" {
+		if u, err := url.Parse(v); err != nil || u.Host == "" {
+			add("results_webhook", fmt.Sprintf("%q is not a valid URL", v), false)
+		} else if u.Scheme != "https" {
+			add("results_webhook", "must use https, since results include code and review text", false)
+		}
+		if input("results_webhook_secret") == "" {
+			add("results_webhook_secret", "not set, so webhook requests are unsigned and the receiver cannot verify them", true)
+		}
+	}
+	if isTrue(input("checks_per_directory")) && !isTrue(input("use_checks")) {
+		add("checks_per_directory", "has no effect unless INPUT_USE_CHECKS is true", true)
+	}
+	if p := input("tracking_project"); p != "" && !strings.HasPrefix(p, "https://github.com/orgs/") && !strings.HasPrefix(p, "https://github.com/users/") && !strings.HasPrefix(p, "PVT_") {
+		add("tracking_project", fmt.Sprintf("invalid project %q; use a project URL such as https://github.com/orgs/acme/projects/3 or a project node ID", p), false)
+	}
+	if isTrue(input("check_actions")) && !isTrue(input("use_checks")) {
+		add("check_actions", "has no effect unless INPUT_USE_CHECKS is true", true)
+	}
+	if input("max_inline_comments") != "" && !isTrue(input("inline_comments")) {
+		add("max_inline_comments", "has no effect unless INPUT_INLINE_COMMENTS is true", true)
+	}
+	return problems
+}
+
+// knownInputs returns the lower-case names of the inputs in action.yml.
+func knownInputs() map[string]bool {
+	var metadata struct {
+		Inputs map[string]interface{} `yaml:"inputs"`
+	}
+	known := map[string]bool{}
+	if err := yaml.Unmarshal(actionMetadata, &metadata); err != nil {
+		return known
+	}
+	for name := range metadata.Inputs {
+		known[strings.ToLower(name)] = true
+	}
+	return known
+}
+
+func overriddenModels() map[string]api.CapabilityOverride {
+	var overrides map[string]api.CapabilityOverride
+	_ = json.Unmarshal([]byte(os.Getenv("INPUT_MODEL_CAPABILITIES")), &overrides)
+	return overrides
+}
+
+func isTrue(v string) bool {
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}